@@ -0,0 +1,535 @@
+// Command cspi runs programs written in the notation of communicating
+// sequential processes, as implemented by package lang.
+//
+// Usage:
+//
+//   cspi [flags] file.csp
+//   cspi fmt [-w] file.csp...
+//   cspi dot [-main name] file.csp
+//   cspi check [flags] file.csp
+//
+// The names a program inputs from or outputs to which are not labels of
+// its processes, such as cardfile and lineprinter, are external. They
+// are mapped to files by the flags -in and -out, where - is standard
+// input or output. A single unmapped source reads from standard input,
+// a single unmapped destination writes to standard output:
+//
+//   cspi -in cardfile:lines=cards.txt -out lineprinter=lines.txt reformat.csp
+//
+// A source reads the characters of its file by default. The format
+// lines reads every line as an array of characters, values evaluates
+// every line as an expression such as 42, 'a', "text" or P(). A
+// destination writes characters as they are, arrays of characters as
+// lines, and other values in the notation of the paper, one per line.
+//
+// The flag -trace prints every communication to standard error. The
+// flag -seed runs a program deterministically, taking its steps at
+// random as by lang.Random: runs with the same seed and inputs print
+// the same trace. The flag -tui animates the run on standard error, as
+// by package tui, pausing for the given delay after every communication
+// so that it can be followed:
+//
+//   cspi -tui 200ms reformat.csp
+//
+// A program is checked by lang.Check before it runs, its syntax and
+// static errors are printed there with the lines they refer to.
+//
+// The command cspi fmt formats programs in the canonical notation of
+// the paper, as printed by lang.FormatSource, writing them to standard
+// output, or back to their files with -w:
+//
+//   cspi fmt -w reformat.csp
+//
+// The command cspi dot writes the processes of a program and the links
+// between them as a graph in the DOT language of Graphviz, as written
+// by lang.Dot:
+//
+//   cspi dot reformat.csp | dot -Tsvg > reformat.svg
+//
+// The command cspi check verifies the assertions of a program, such as
+// assert SYSTEM :[deadlock free] or assert SPEC [T= SYSTEM], as by the
+// Verify method of lang.Analyzer, without running it. It prints the
+// verdict on every assertion, with a counterexample of those that do
+// not hold, and fails unless all of them are known to hold. The flag
+// -in gives the values of an external source as for a run, read in
+// full, other sources input nothing; the flag -max-states bounds the
+// states explored of every process:
+//
+//   cspi check -in west=cards.txt -max-states 1000000 reformat.csp
+//
+// A file ending in .cspm is a script of machine-readable CSP, as written
+// for the refinement checker FDR, which is imported by package cspm. Its
+// process named by the flag -main is run, or its last process:
+//
+//   cspi -main SYSTEM buffer.cspm
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/changkun/gobase/csp/lang"
+	"github.com/changkun/gobase/csp/lang/cspm"
+	"github.com/changkun/gobase/csp/trace"
+	"github.com/changkun/gobase/csp/trace/tui"
+)
+
+func main() {
+	if err := run(context.Background(), os.Args[1:], os.Stdin, os.Stdout, os.Stderr); err != nil {
+		fmt.Fprintf(os.Stderr, "cspi: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// mapping maps external names to files.
+type mapping map[string]string
+
+func (m mapping) String() string {
+	pairs := []string{}
+	for name, path := range m {
+		pairs = append(pairs, name+"="+path)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (m mapping) Set(s string) error {
+	name, path, ok := strings.Cut(s, "=")
+	if !ok || name == "" || path == "" {
+		return fmt.Errorf("invalid mapping %q, expected name=file", s)
+	}
+	m[name] = path
+	return nil
+}
+
+// formats read the values of a source.
+var formats = map[string]func(r io.Reader, emit func(lang.Value) bool) error{
+	"chars": func(r io.Reader, emit func(lang.Value) bool) error {
+		br := bufio.NewReader(r)
+		for {
+			c, _, err := br.ReadRune()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if !emit(c) {
+				return nil
+			}
+		}
+	},
+	"lines": func(r io.Reader, emit func(lang.Value) bool) error {
+		s := bufio.NewScanner(r)
+		for s.Scan() {
+			if !emit(lang.Chars(s.Text())) {
+				return nil
+			}
+		}
+		return s.Err()
+	},
+	"values": func(r io.Reader, emit func(lang.Value) bool) error {
+		s := bufio.NewScanner(r)
+		for s.Scan() {
+			if strings.TrimSpace(s.Text()) == "" {
+				continue
+			}
+			x, err := lang.ParseExpr(s.Text())
+			if err != nil {
+				return err
+			}
+			v, err := lang.Eval(x)
+			if err != nil {
+				return err
+			}
+			if !emit(v) {
+				return nil
+			}
+		}
+		return s.Err()
+	},
+}
+
+// write writes v to w.
+func write(w io.Writer, v lang.Value) {
+	switch v := v.(type) {
+	case rune:
+		fmt.Fprintf(w, "%c", v)
+	case lang.Array:
+		if len(v) > 0 {
+			if _, ok := v[0].(rune); ok {
+				fmt.Fprintln(w, v.String())
+				return
+			}
+		}
+		fmt.Fprintln(w, lang.Format(v))
+	default:
+		fmt.Fprintln(w, lang.Format(v))
+	}
+}
+
+func run(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	if len(args) > 0 && args[0] == "fmt" {
+		return format(args[1:], stdout, stderr)
+	}
+	if len(args) > 0 && args[0] == "dot" {
+		return dot(args[1:], stdout, stderr)
+	}
+	if len(args) > 0 && args[0] == "check" {
+		return check(ctx, args[1:], stdin, stdout, stderr)
+	}
+	fs := flag.NewFlagSet("cspi", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	ins, outs := mapping{}, mapping{}
+	fs.Var(ins, "in", "map the external source `name[:format]=file`, the format is chars, lines or values")
+	fs.Var(outs, "out", "map the external destination `name=file`")
+	printTrace := fs.Bool("trace", false, "print every communication to standard error")
+	seed := fs.Int64("seed", 0, "run deterministically, taking steps drawn from a generator seeded with `n`")
+	entry := fs.String("main", "", "run the process `name` of a CSPm script")
+	delay := fs.Duration("tui", 0, "animate the run on standard error, pausing `delay` after every communication")
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "usage: cspi [flags] file.csp\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("expected a single file")
+	}
+
+	prog, err := load(fs.Arg(0), *entry, stderr)
+	if err != nil {
+		return err
+	}
+
+	in := &lang.Interpreter{
+		Program: prog,
+		Inputs:  map[string]<-chan lang.Value{},
+		Outputs: map[string]chan<- lang.Value{},
+	}
+	if *printTrace {
+		in.Trace = func(c lang.Comm) { fmt.Fprintln(stderr, c) }
+	}
+	animate := false
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "seed":
+			in.Schedule = lang.Random(*seed)
+		case "tui":
+			animate = true
+		}
+	})
+	inputs, outputs := lang.Externals(prog)
+
+	// the format of a source is given with its name.
+	sources, srcFormats := mapping{}, map[string]string{}
+	for key, path := range ins {
+		name, format, _ := strings.Cut(key, ":")
+		if format == "" {
+			format = "chars"
+		}
+		if _, ok := formats[format]; !ok {
+			return fmt.Errorf("unknown format %s of source %s", format, name)
+		}
+		sources[name] = path
+		srcFormats[name] = format
+	}
+	files, err := resolve("source", "-in", inputs, sources)
+	if err != nil {
+		return err
+	}
+	dests, err := resolve("destination", "-out", outputs, outs)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	errs := make(chan error, len(inputs)+len(outputs))
+	wg := sync.WaitGroup{}
+	for _, name := range inputs {
+		r, closer, err := open(files[name], stdin)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ch := make(chan lang.Value)
+		in.Inputs[name] = ch
+		format := srcFormats[name]
+		if format == "" {
+			format = "chars"
+		}
+		go func() {
+			defer close(ch)
+			errs <- formats[format](r, func(v lang.Value) bool {
+				select {
+				case ch <- v:
+					return true
+				case <-ctx.Done():
+					return false
+				}
+			})
+		}()
+	}
+	for _, name := range outputs {
+		w, closer, err := create(dests[name], stdout)
+		if err != nil {
+			return err
+		}
+		ch := make(chan lang.Value)
+		in.Outputs[name] = ch
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			bw := bufio.NewWriter(w)
+			for v := range ch {
+				write(bw, v)
+			}
+			if err := bw.Flush(); err != nil {
+				errs <- err
+			}
+			if err := closer(); err != nil {
+				errs <- err
+			}
+		}()
+	}
+
+	rctx := ctx
+	if animate {
+		r, d := trace.NewRecorder(), tui.New(stderr)
+		d.Delay = *delay
+		r.Notify(d.Record)
+		dctx, stop := context.WithCancel(ctx)
+		done := make(chan error, 1)
+		go func() { done <- d.Run(dctx) }()
+		defer func() {
+			stop()
+			<-done
+		}()
+		rctx = trace.NewContext(ctx, r)
+	}
+
+	err = in.Run(rctx)
+	wg.Wait()
+	if err != nil {
+		return err
+	}
+	for {
+		select {
+		case err := <-errs:
+			if err != nil {
+				return err
+			}
+		default:
+			return nil
+		}
+	}
+}
+
+// resolve maps the external names of a program to files. A single
+// unmapped name is mapped to -.
+func resolve(kind, flag string, names []string, m mapping) (map[string]string, error) {
+	files := map[string]string{}
+	unmapped := []string{}
+	for _, name := range names {
+		path, ok := m[name]
+		if !ok {
+			unmapped = append(unmapped, name)
+			path = "-"
+		}
+		files[name] = path
+	}
+	if len(unmapped) > 1 {
+		return nil, fmt.Errorf("%ss %s are not mapped, use %s", kind, strings.Join(unmapped, ", "), flag)
+	}
+	for name := range m {
+		if _, ok := files[name]; !ok {
+			return nil, fmt.Errorf("program has no %s %s", kind, name)
+		}
+	}
+	return files, nil
+}
+
+func open(path string, stdin io.Reader) (io.Reader, func() error, error) {
+	if path == "-" {
+		return stdin, func() error { return nil }, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return f, f.Close, nil
+}
+
+// load parses and checks the program of file, a CSPm script of which
+// the process entry is loaded if file ends in .cspm, printing its errors
+// to stderr.
+func load(file, entry string, stderr io.Writer) (*lang.Program, error) {
+	src, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var prog *lang.Program
+	if strings.HasSuffix(file, ".cspm") {
+		prog, err = cspm.Parse(string(src), entry)
+	} else if prog, err = lang.Parse(string(src)); err == nil {
+		err = lang.Check(prog)
+	}
+	if err != nil {
+		lang.PrintError(stderr, file, string(src), err)
+		return nil, fmt.Errorf("%s:%v", file, err)
+	}
+	return prog, nil
+}
+
+func create(path string, stdout io.Writer) (io.Writer, func() error, error) {
+	if path == "-" {
+		return stdout, func() error { return nil }, nil
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return f, f.Close, nil
+}
+
+// format formats the programs of the files of args.
+func format(args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("cspi fmt", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	write := fs.Bool("w", false, "write the result to the file instead of standard output")
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "usage: cspi fmt [flags] file.csp...\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return errors.New("expected files")
+	}
+	for _, file := range fs.Args() {
+		src, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		out, err := lang.FormatSource(src)
+		if err != nil {
+			lang.PrintError(stderr, file, string(src), err)
+			return fmt.Errorf("%s:%v", file, err)
+		}
+		if !*write {
+			if _, err := stdout.Write(out); err != nil {
+				return err
+			}
+			continue
+		}
+		if bytes.Equal(src, out) {
+			continue
+		}
+		if err := os.WriteFile(file, out, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dot writes the topology of the program of args.
+func dot(args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("cspi dot", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	entry := fs.String("main", "", "draw the process `name` of a CSPm script")
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "usage: cspi dot [flags] file.csp\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("expected a single file")
+	}
+	prog, err := load(fs.Arg(0), *entry, stderr)
+	if err != nil {
+		return err
+	}
+	return lang.Dot(stdout, prog)
+}
+
+// check verifies the assertions of the program of args.
+func check(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("cspi check", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	ins := mapping{}
+	fs.Var(ins, "in", "input the values of the external source `name[:format]=file`, the format is chars, lines or values")
+	maxStates := fs.Int("max-states", lang.DefaultMaxStates, "explore at most `n` states of every process")
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "usage: cspi check [flags] file.csp\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("expected a single file")
+	}
+	prog, err := load(fs.Arg(0), "", stderr)
+	if err != nil {
+		return err
+	}
+
+	a := &lang.Analyzer{Program: prog, Inputs: map[string][]lang.Value{}, MaxStates: *maxStates}
+	for key, path := range ins {
+		name, format, _ := strings.Cut(key, ":")
+		if format == "" {
+			format = "chars"
+		}
+		read, ok := formats[format]
+		if !ok {
+			return fmt.Errorf("unknown format %s of source %s", format, name)
+		}
+		r, closer, err := open(path, stdin)
+		if err != nil {
+			return err
+		}
+		err = read(r, func(v lang.Value) bool {
+			a.Inputs[name] = append(a.Inputs[name], v)
+			return true
+		})
+		closer()
+		if err != nil {
+			return err
+		}
+	}
+
+	verdicts, err := a.Verify(ctx)
+	if err != nil {
+		return err
+	}
+	failed := 0
+	for _, v := range verdicts {
+		fmt.Fprintf(stdout, "%s:%v: %v\n", fs.Arg(0), v.Assertion.Pos(), v)
+		if !v.Holds() {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d assertions do not hold", failed, len(verdicts))
+	}
+	return nil
+}
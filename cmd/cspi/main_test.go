@@ -0,0 +1,250 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const reformat = `DISASSEMBLE = (
+  *[cardimage:(1..8)character; cardfile?cardimage ->
+      i:integer; i := 1;
+      *[i <= 8 -> X!cardimage(i); i := i+1 ];
+      X!space
+  ]
+)
+COPY = (*[c:character; west?c -> east!c])
+ASSEMBLE = (
+  lineimage:(1..6)character;
+  i:integer; i:=1;
+  *[c:character; X?c ->
+      lineimage(i) := c;
+      [i <= 5 -> i := i+1
+      □ i = 6 -> lineprinter!lineimage; i:=1
+  ]   ];
+  [ i = 1 -> skip
+  □ i > 1 -> *[i <= 6 -> lineimage(i) := space; i := i+1];
+    lineprinter!lineimage
+  ]
+)
+[west::DISASSEMBLE||X::COPY||east::ASSEMBLE]
+`
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("%v: %v", t.Name(), err)
+		}
+		return path
+	}
+	squash := write("squash.csp", "X::*[c:character; west?c -> [c ≠ asterisk -> east!c □ c = asterisk -> east!upward arrow]]")
+	square := write("square.csp", "X::*[n:integer; in?n -> out!n*n]")
+	reformat := write("reformat.csp", reformat)
+	buffer := write("buffer.cspm", "channel in, mid, out : Int\nL = in?x -> mid!x -> L\nR = mid?x -> out!x+1 -> R\nSYSTEM = L [| {| mid |} |] R\n")
+	cards := write("cards.txt", "abcdefgh\n12345678\n")
+	lines := filepath.Join(dir, "lines.txt")
+
+	tests := []struct {
+		args           []string
+		stdin          string
+		stdout, stderr string
+		file           string
+	}{
+		{args: []string{squash}, stdin: "a*b", stdout: "a↑b"},
+		{args: []string{"-in", "in:values=-", square}, stdin: "1\n2+1\n", stdout: "1\n9\n"},
+		{
+			args:   []string{"-trace", "-in", "in:values=-", square},
+			stdin:  "4",
+			stdout: "16\n",
+			stderr: "in→X: 4\nX→out: 16\n",
+		},
+		{args: []string{"-in", "in:values=-", buffer}, stdin: "1\n2\n", stdout: "2\n3\n"},
+		{
+			args:   []string{"-seed", "1", "-trace", "-in", "in:values=-", buffer},
+			stdin:  "1\n2\n",
+			stdout: "2\n3\n",
+			stderr: "in→L: 1\nL→R: 1\nR→out: 2\nin→L: 2\nL→R: 2\nR→out: 3\n",
+		},
+		{args: []string{"-main", "L", "-in", "in:values=-", "-out", "mid=-", buffer}, stdin: "1\n", stdout: "1\n"},
+		{
+			args: []string{"-in", "cardfile:lines=" + cards, "-out", "lineprinter=" + lines, reformat},
+			file: "abcdef\ngh 123\n45678 \n",
+		},
+	}
+	for _, tt := range tests {
+		stdout, stderr := bytes.Buffer{}, bytes.Buffer{}
+		err := run(context.Background(), tt.args, strings.NewReader(tt.stdin), &stdout, &stderr)
+		if err != nil {
+			t.Fatalf("%v: %v: unexpected error: %v", t.Name(), tt.args, err)
+		}
+		if stdout.String() != tt.stdout {
+			t.Fatalf("%v: %v: expected: %q, got: %q", t.Name(), tt.args, tt.stdout, stdout.String())
+		}
+		if stderr.String() != tt.stderr {
+			t.Fatalf("%v: %v: expected trace: %q, got: %q", t.Name(), tt.args, tt.stderr, stderr.String())
+		}
+		if tt.file == "" {
+			continue
+		}
+		got, err := os.ReadFile(lines)
+		if err != nil {
+			t.Fatalf("%v: %v", t.Name(), err)
+		}
+		if string(got) != tt.file {
+			t.Fatalf("%v: %v: expected: %q, got: %q", t.Name(), tt.args, tt.file, got)
+		}
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "prog.csp")
+	if err := os.WriteFile(path, []byte("[X::*[c:character; a?c -> b!c] || Y::*[c:character; c?c -> d!c]]"), 0644); err != nil {
+		t.Fatalf("%v: %v", t.Name(), err)
+	}
+	self := filepath.Join(dir, "self.csp")
+	if err := os.WriteFile(self, []byte("[X::*[c:character; X?c -> Y!c] || Y::skip]"), 0644); err != nil {
+		t.Fatalf("%v: %v", t.Name(), err)
+	}
+	bad := filepath.Join(dir, "bad.csp")
+	if err := os.WriteFile(bad, []byte("X::*[c:character; west?c"), 0644); err != nil {
+		t.Fatalf("%v: %v", t.Name(), err)
+	}
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{args: []string{path}, want: "sources a, c are not mapped, use -in"},
+		{args: []string{"-in", "a=-", "-in", "x=-", path}, want: "program has no source x"},
+		{args: []string{"-in", "a:csv=-", path}, want: "unknown format csv of source a"},
+		{args: []string{bad}, want: bad + ":1:25: expected →, found EOF"},
+		{args: []string{self}, want: self + ":1:20: process X names itself"},
+		{args: []string{}, want: "expected a single file"},
+		{args: []string{"fmt", bad}, want: bad + ":1:25: expected →, found EOF"},
+		{args: []string{"fmt"}, want: "expected files"},
+	}
+	for _, tt := range tests {
+		err := run(context.Background(), tt.args, strings.NewReader(""), &bytes.Buffer{}, &bytes.Buffer{})
+		if err == nil || err.Error() != tt.want {
+			t.Fatalf("%v: %v: expected: %v, got: %v", t.Name(), tt.args, tt.want, err)
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "copy.csp")
+	if err := os.WriteFile(path, []byte("X :: *[c:character; west?c -> east!c] -- copy\n"), 0644); err != nil {
+		t.Fatalf("%v: %v", t.Name(), err)
+	}
+	want := "-- copy\nX::*[c:character; west?c → east!c]\n"
+
+	stdout := bytes.Buffer{}
+	if err := run(context.Background(), []string{"fmt", path}, strings.NewReader(""), &stdout, &bytes.Buffer{}); err != nil {
+		t.Fatalf("%v: unexpected error: %v", t.Name(), err)
+	}
+	if stdout.String() != want {
+		t.Fatalf("%v: expected: %q, got: %q", t.Name(), want, stdout.String())
+	}
+
+	stdout.Reset()
+	if err := run(context.Background(), []string{"fmt", "-w", path}, strings.NewReader(""), &stdout, &bytes.Buffer{}); err != nil {
+		t.Fatalf("%v: unexpected error: %v", t.Name(), err)
+	}
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v: %v", t.Name(), err)
+	}
+	if string(got) != want || stdout.Len() != 0 {
+		t.Fatalf("%v: expected file: %q, got: %q, output: %q", t.Name(), want, got, stdout.String())
+	}
+}
+
+func TestDot(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "reformat.csp")
+	if err := os.WriteFile(path, []byte(reformat), 0644); err != nil {
+		t.Fatalf("%v: %v", t.Name(), err)
+	}
+	want := `digraph {
+    p1 [label="west::DISASSEMBLE"];
+    p2 [label="X::COPY"];
+    p3 [label="east::ASSEMBLE"];
+    p4 [label="cardfile", shape=none];
+    p5 [label="lineprinter", shape=none];
+    p4 -> p1;
+    p1 -> p2;
+    p2 -> p3;
+    p3 -> p5;
+}
+`
+
+	stdout := bytes.Buffer{}
+	if err := run(context.Background(), []string{"dot", path}, strings.NewReader(""), &stdout, &bytes.Buffer{}); err != nil {
+		t.Fatalf("%v: unexpected error: %v", t.Name(), err)
+	}
+	if stdout.String() != want {
+		t.Fatalf("%v: expected:\n%v\ngot:\n%v", t.Name(), want, stdout.String())
+	}
+}
+
+func TestCheck(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "copy.csp")
+	src := `COPY = (*[c:character; west?c -> east!c])
+SPEC = (*[c:character; west?c -> east!c □ c:character; west?c -> skip])
+assert COPY :[deadlock free]
+assert SPEC [T= COPY]
+assert COPY [T= SPEC]
+[X::COPY]
+`
+	if err := os.WriteFile(path, []byte(src), 0644); err != nil {
+		t.Fatalf("%v: %v", t.Name(), err)
+	}
+	want := path + ":3:1: assert COPY :[deadlock free]: holds\n" +
+		path + ":4:1: assert SPEC [T= COPY]: holds\n" +
+		path + ":5:1: assert COPY [T= SPEC]: fails: after west.'a': west.'b'\n"
+
+	stdout := bytes.Buffer{}
+	err := run(context.Background(), []string{"check", "-in", "west=-", path}, strings.NewReader("ab"), &stdout, &bytes.Buffer{})
+	if err == nil || err.Error() != "1 of 3 assertions do not hold" {
+		t.Fatalf("%v: expected: an assertion that does not hold, got: %v", t.Name(), err)
+	}
+	if stdout.String() != want {
+		t.Fatalf("%v: expected:\n%v\ngot:\n%v", t.Name(), want, stdout.String())
+	}
+
+	// without inputs, all assertions hold.
+	stdout.Reset()
+	if err := run(context.Background(), []string{"check", path}, strings.NewReader(""), &stdout, &bytes.Buffer{}); err != nil {
+		t.Fatalf("%v: unexpected error: %v\n%s", t.Name(), err, stdout.String())
+	}
+}
+
+func TestTUI(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "square.csp")
+	if err := os.WriteFile(path, []byte("X::*[n:integer; in?n -> out!n*n]"), 0644); err != nil {
+		t.Fatalf("%v: %v", t.Name(), err)
+	}
+	stdout, stderr := bytes.Buffer{}, bytes.Buffer{}
+	args := []string{"-tui", "0", "-in", "in:values=-", path}
+	if err := run(context.Background(), args, strings.NewReader("2\n4\n"), &stdout, &stderr); err != nil {
+		t.Fatalf("%v: unexpected error: %v", t.Name(), err)
+	}
+	if stdout.String() != "4\n16\n" {
+		t.Fatalf("%v: expected: %q, got: %q", t.Name(), "4\n16\n", stdout.String())
+	}
+
+	// the last frame shows the counters of the channels.
+	frames := strings.Split(stderr.String(), "\x1b[H\x1b[2J")
+	last := frames[len(frames)-1]
+	for _, want := range []string{"│ X │", "   2 4\n", "   2 16\n"} {
+		if !strings.Contains(last, want) {
+			t.Fatalf("%v: expected %q in frame: %q", t.Name(), want, last)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+// Command go2csp prints approximate descriptions of Go functions in the
+// notation of communicating sequential processes, as extracted by
+// package extract.
+//
+// Usage:
+//
+//   go2csp [-func name] file.go
+//
+// Without -func, all functions of the file which communicate on
+// channels are printed as definitions, which cspi runs once a program
+// refers to them:
+//
+//   go2csp pipeline.go > pipeline.csp
+//
+// The flag -cspm prints the processes as a script of machine-readable
+// CSP instead, as exported by package cspm, which FDR checks:
+//
+//   go2csp -cspm -func Copy pipeline.go > copy.cspm
+//
+// The flag -promela prints them as a Promela model, as exported by
+// package promela, which SPIN verifies:
+//
+//   go2csp -promela -func Copy pipeline.go > copy.pml
+//
+// The flag -tla prints them as a TLA+ module of a PlusCal algorithm, as
+// exported by package pluscal, which TLC checks once translated:
+//
+//   go2csp -tla -func Copy pipeline.go > CSP.tla
+//
+// The flag -pat prints them as a CSP# model, as exported by package
+// pat, which PAT verifies:
+//
+//   go2csp -pat -func Copy pipeline.go > copy.csp
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io"
+	"os"
+
+	"github.com/changkun/gobase/csp/lang"
+	"github.com/changkun/gobase/csp/lang/cspm"
+	"github.com/changkun/gobase/csp/lang/extract"
+	"github.com/changkun/gobase/csp/lang/pat"
+	"github.com/changkun/gobase/csp/lang/pluscal"
+	"github.com/changkun/gobase/csp/lang/promela"
+)
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout, os.Stderr); err != nil {
+		fmt.Fprintf(os.Stderr, "go2csp: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("go2csp", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fn := fs.String("func", "", "print the process of the function `name` only")
+	toCSPM := fs.Bool("cspm", false, "print a script of machine-readable CSP")
+	toPromela := fs.Bool("promela", false, "print a Promela model")
+	toTLA := fs.Bool("tla", false, "print a TLA+ module of a PlusCal algorithm")
+	toPAT := fs.Bool("pat", false, "print a CSP# model")
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "usage: go2csp [flags] file.go\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("expected a single file")
+	}
+	formats := 0
+	for _, f := range []bool{*toCSPM, *toPromela, *toTLA, *toPAT} {
+		if f {
+			formats++
+		}
+	}
+	if formats > 1 {
+		return errors.New("flags -cspm, -promela, -tla and -pat are exclusive")
+	}
+
+	f, err := parser.ParseFile(token.NewFileSet(), fs.Arg(0), nil, 0)
+	if err != nil {
+		return err
+	}
+	prog := extract.File(f)
+	if *fn != "" {
+		prog = nil
+		for _, decl := range f.Decls {
+			if d, ok := decl.(*ast.FuncDecl); ok && d.Recv == nil && d.Name.Name == *fn {
+				prog = extract.Func(d)
+			}
+		}
+		if prog == nil {
+			return fmt.Errorf("%s has no function %s", fs.Arg(0), *fn)
+		}
+	}
+	var src []byte
+	switch {
+	case *toCSPM:
+		src, err = cspm.Export(prog, cspm.Config{})
+	case *toPromela:
+		src, err = promela.Export(prog, promela.Config{})
+	case *toTLA:
+		src, err = pluscal.Export(prog, pluscal.Config{})
+	case *toPAT:
+		src, err = pat.Export(prog, pat.Config{})
+	default:
+		src = []byte(lang.Source(prog))
+	}
+	if err != nil {
+		return err
+	}
+	_, err = stdout.Write(src)
+	return err
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "copy.go")
+	src := `package p
+
+func Copy(west <-chan rune, east chan<- rune) {
+	for c := range west {
+		east <- c
+	}
+}
+
+func Twice(x int) int { return 2 * x }
+`
+	if err := os.WriteFile(path, []byte(src), 0644); err != nil {
+		t.Fatalf("%v: %v", t.Name(), err)
+	}
+
+	tests := []struct {
+		args []string
+		want string
+		err  string
+	}{
+		{args: []string{path}, want: "Copy = (*[c:character; west?c → east!c])\n"},
+		{args: []string{"-func", "Copy", path}, want: "Copy::*[c:character; west?c → east!c]\n"},
+		{args: []string{"-cspm", "-func", "Copy", path}, want: "channel west : Char\nchannel east : Char\n\nCopy = west?c -> east!c -> Copy\n"},
+		{args: []string{"-cspm", path}, want: "channel west : Char\nchannel east : Char\n\nCopy = west?c -> east!c -> Copy\n"},
+		{args: []string{"-promela", "-func", "Copy", path}, want: "chan west = [0] of { int };\nchan east = [0] of { int };\n\nactive proctype Copy() {\n\tint c;\n\tdo\n\t:: west?c ->\n\t\teast!c\n\tod\n}\n"},
+		{args: []string{"-tla", "-func", "Copy", path}, want: "---- MODULE CSP ----\nEXTENDS Integers, Sequences\n\n(* --algorithm CSP {\nvariables\n  west = <<>>,\n  east = <<>>;\n\nprocess (Copy = \"Copy\")\nvariables c = 0;\n{\n  Copy_1:\n    while (TRUE) {\n      await west # <<>>;\n      c := Head(west)[1];\n      west := <<>>;\n      Copy_2:\n        await east = <<>>;\n        east := << <<c>> >>;\n      Copy_3:\n        await east = <<>>;\n    }\n}\n} *)\n\\* BEGIN TRANSLATION\n\\* END TRANSLATION\n====\n"},
+		{args: []string{"-pat", "-func", "Copy", path}, want: "channel west 0;\nchannel east 0;\nvar c = 0;\n\nCopy() = Copy_1();\nCopy_1() = west?c_in -> tau{c = c_in} -> east!c -> Copy_1();\nSYSTEM() = Copy();\n"},
+		{args: []string{"-cspm", "-promela", path}, err: "flags -cspm, -promela, -tla and -pat are exclusive"},
+		{args: []string{"-promela", "-tla", path}, err: "flags -cspm, -promela, -tla and -pat are exclusive"},
+		{args: []string{"-tla", "-pat", path}, err: "flags -cspm, -promela, -tla and -pat are exclusive"},
+		{args: []string{"-func", "Move", path}, err: path + " has no function Move"},
+		{args: []string{}, err: "expected a single file"},
+	}
+	for _, tt := range tests {
+		stdout := bytes.Buffer{}
+		err := run(tt.args, &stdout, &bytes.Buffer{})
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Fatalf("%v: %v: expected: %v, got: %v", t.Name(), tt.args, tt.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%v: %v: unexpected error: %v", t.Name(), tt.args, err)
+		}
+		if stdout.String() != tt.want {
+			t.Fatalf("%v: %v: expected: %q, got: %q", t.Name(), tt.args, tt.want, stdout.String())
+		}
+	}
+}
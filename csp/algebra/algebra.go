@@ -0,0 +1,693 @@
+// Package algebra implements processes in the algebraic style of CSP,
+// as terms built from the operators of the process algebra:
+//
+//   P := STOP | SKIP | e → P | P □ P | P ⊓ P | P [|A|] P | P [A||B] P | P ; P
+//      | P ||| P | P △ P | P \ A | P[[R]] | μ X • P | X | WAIT d | P [d> P
+//      | P [w ⊕ v] P | prioritise(P, ⟨A, ...⟩)
+//
+// such as the buffer of one value
+//
+//   buf := algebra.Rec("B", algebra.Prefix("in", algebra.Prefix("out", algebra.Var("B"))))
+//
+// whose transition system, by the operational semantics of CSP, is
+// checked by package lts:
+//
+//   l, err := algebra.LTS(buf)
+//
+// An Event is the name of a channel, such as in, or of a channel and
+// the values it communicates, such as in.0, other than lts.Tau and
+// lts.Tick. A process terminates by engaging in lts.Tick to a state
+// without transitions, Ω. Recursion unfolds by a Tau transition, as in
+// FDR, such that an unguarded recursion, such as μ X • X, diverges.
+//
+// Time passes by the event Tock, as in tock-CSP, which WAIT d and the
+// timeout P [d> Q count. A Machine executes a process by the same
+// semantics, engaging in its events with an environment of Go channels,
+// in wall-clock or virtual time. A probabilistic choice, P [w ⊕ v] Q,
+// is an internal choice weighted for a Machine, whose outcomes Estimate
+// estimates by many runs.
+package algebra
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/changkun/gobase/csp/lts"
+)
+
+// DefaultMaxStates is the number of states LTS explores at most.
+const DefaultMaxStates = 100000
+
+// Event is an event a process engages in: the name of a channel, such
+// as in, followed by the values it communicates, if any, such as in.0.
+type Event string
+
+// NewEvent returns the event of the channel ch communicating values,
+// such as in.0 of NewEvent("in", 0).
+func NewEvent(ch string, values ...interface{}) Event {
+	e := ch
+	for _, v := range values {
+		e += "." + fmt.Sprint(v)
+	}
+	return Event(e)
+}
+
+// Chan returns the channel of e, such as in of in.0.
+func (e Event) Chan() string {
+	ch, _, _ := strings.Cut(string(e), ".")
+	return ch
+}
+
+// Value returns the values e communicates, such as 0 of in.0, empty if
+// none.
+func (e Event) Value() string {
+	_, v, _ := strings.Cut(string(e), ".")
+	return v
+}
+
+// Process is a term of the process algebra.
+type Process interface {
+	// String formats the process, such as a → (b → STOP □ SKIP).
+	String() string
+
+	// steps returns the transitions of the process, in order.
+	steps() []step
+	// subst substitutes p for the free variable name.
+	subst(name string, p Process) Process
+}
+
+// step is a transition of a process. The weight of an internal
+// transition is the probability a Machine takes it with, relative to
+// other internal transitions, 1 but for a probabilistic choice.
+type step struct {
+	event  string
+	next   Process
+	weight float64
+}
+
+type (
+	stop   struct{}
+	skip   struct{}
+	omega  struct{}
+	prefix struct {
+		event string
+		next  Process
+	}
+	extChoice struct{ p, q Process }
+	intChoice struct{ p, q Process }
+	par       struct {
+		p    Process
+		sync []string // sorted
+		q    Process
+	}
+	parAlpha struct {
+		p, q Process
+		a, b []string // sorted
+	}
+	seq       struct{ p, q Process }
+	interrupt struct{ p, q Process }
+	hide      struct {
+		p      Process
+		events []string // sorted
+	}
+	rename struct {
+		p     Process
+		pairs [][2]string // sorted
+	}
+	prioritise struct {
+		p     Process
+		order [][]string // sorted
+	}
+	rec struct {
+		name string
+		body Process
+	}
+	variable struct{ name string }
+)
+
+// Stop returns STOP, the process which engages in no events.
+func Stop() Process { return stop{} }
+
+// Skip returns SKIP, the process which terminates.
+func Skip() Process { return skip{} }
+
+// Prefix returns e → p, the process which engages in the event e, and
+// then behaves as p.
+func Prefix(e Event, p Process) Process {
+	if e == lts.Tau || e == lts.Tick {
+		panic(fmt.Sprintf("algebra: prefix of %s", e))
+	}
+	return prefix{event: string(e), next: p}
+}
+
+// ExtChoice returns p □ q, the external choice of p and q, which
+// behaves as p or q, as chosen by the first event the environment
+// engages in.
+func ExtChoice(p, q Process) Process { return extChoice{p, q} }
+
+// IntChoice returns p ⊓ q, the internal choice of p and q, which
+// behaves as p or q, as chosen by the process itself, by a Machine at
+// random.
+func IntChoice(p, q Process) Process { return intChoice{p, q} }
+
+// Par returns p [|sync|] q, the parallel composition of p and q, which
+// engage in the events of sync together, and in other events each on
+// its own. The composition terminates once both p and q terminated.
+func Par(p Process, sync []Event, q Process) Process {
+	return par{p: p, sync: set(sync), q: q}
+}
+
+// Interleave returns p ||| q, the interleaving of p and q, which
+// engage in their events each on its own, p [|{}|] q.
+func Interleave(p, q Process) Process { return Par(p, nil, q) }
+
+// ParAlpha returns p [a || b] q, the alphabetised parallel composition
+// of p and q, as of CSPm: p engages in the events of its alphabet a
+// only, and q in those of b, which they engage in together if in both
+// alphabets, and otherwise each on its own. The composition terminates
+// once both p and q terminated.
+func ParAlpha(p Process, a []Event, q Process, b []Event) Process {
+	return parAlpha{p: p, a: set(a), q: q, b: set(b)}
+}
+
+// Seq returns p ; q, the sequential composition of p and q, which
+// behaves as p until it terminates, and then as q.
+func Seq(p, q Process) Process { return seq{p, q} }
+
+// Interrupt returns p △ q, the process which behaves as p until q
+// engages in its first event, which aborts p, and then as q. It
+// terminates once p or q terminates. For a Machine, the environment
+// interrupts p by receiving a first event of q, which it may as soon as
+// it is ready to, such as the cancellation of
+//
+//   algebra.Interrupt(work, algebra.Prefix("cancel", algebra.Skip()))
+func Interrupt(p, q Process) Process { return interrupt{p, q} }
+
+// Hide returns p \ events, the process which behaves as p, except that
+// the events of p in events are internal, Tau transitions. Termination
+// and the passing of time cannot be hidden.
+func Hide(p Process, events []Event) Process {
+	return hide{p: p, events: set(events)}
+}
+
+// Rename returns p[[a <- b, ...]], the process which behaves as p, except
+// that it engages in the event b, or b.1, where p engages in the
+// event a, or a.1 of the channel a, for every event a renamed to b.
+func Rename(p Process, renaming map[Event]Event) Process {
+	var pairs [][2]string
+	for from, to := range renaming {
+		for _, e := range []Event{from, to} {
+			if e == lts.Tau || e == lts.Tick || e == Tock {
+				panic(fmt.Sprintf("algebra: renaming of %s", e))
+			}
+		}
+		pairs = append(pairs, [2]string{string(from), string(to)})
+	}
+	sort.Slice(pairs, func(i, j int) bool { return pairs[i][0] < pairs[j][0] })
+	return rename{p: p, pairs: pairs}
+}
+
+// Prioritise returns prioritise(p, ⟨order[0], order[1], ...⟩), the
+// process which behaves as p, except that p engages in an event of
+// order[i] only if it can engage in no event of order[j], j < i, as
+// prioritise of FDR. Internal transitions and termination are of the
+// priority of order[0], and events in no set of order are unaffected.
+// For a Machine, the environment can engage only in the events of
+// the highest priority offered, such as v of a semaphore preferring
+// v to p:
+//
+//   algebra.Prioritise(sem, []algebra.Event{"v"}, []algebra.Event{"p"})
+func Prioritise(p Process, order ...[]Event) Process {
+	sets := make([][]string, len(order))
+	for i, events := range order {
+		for _, e := range events {
+			if e == lts.Tau || e == lts.Tick {
+				panic(fmt.Sprintf("algebra: priority of %s", e))
+			}
+		}
+		sets[i] = set(events)
+	}
+	return prioritise{p: p, order: sets}
+}
+
+// Rec returns μ name • body, the process which behaves as body in
+// which the variable name stands for the process itself.
+func Rec(name string, body Process) Process { return rec{name: name, body: body} }
+
+// Var returns the variable name, which stands for the process of the
+// recursion binding it.
+func Var(name string) Process { return variable{name} }
+
+func (stop) String() string     { return "STOP" }
+func (skip) String() string     { return "SKIP" }
+func (omega) String() string    { return "Ω" }
+func (p prefix) String() string { return p.event + " → " + p.next.String() }
+func (p extChoice) String() string {
+	return "(" + p.p.String() + " □ " + p.q.String() + ")"
+}
+func (p intChoice) String() string {
+	return "(" + p.p.String() + " ⊓ " + p.q.String() + ")"
+}
+func (p par) String() string {
+	if len(p.sync) == 0 {
+		return "(" + p.p.String() + " ||| " + p.q.String() + ")"
+	}
+	return "(" + p.p.String() + " [|{" + strings.Join(p.sync, ", ") + "}|] " + p.q.String() + ")"
+}
+func (p parAlpha) String() string {
+	return "(" + p.p.String() + " [{" + strings.Join(p.a, ", ") + "} || {" + strings.Join(p.b, ", ") + "}] " + p.q.String() + ")"
+}
+func (p seq) String() string { return "(" + p.p.String() + " ; " + p.q.String() + ")" }
+func (p interrupt) String() string {
+	return "(" + p.p.String() + " △ " + p.q.String() + ")"
+}
+func (p hide) String() string {
+	return "(" + p.p.String() + " \\ {" + strings.Join(p.events, ", ") + "})"
+}
+func (p rename) String() string {
+	pairs := make([]string, len(p.pairs))
+	for i, r := range p.pairs {
+		pairs[i] = r[0] + " <- " + r[1]
+	}
+	s := p.p.String()
+	if _, ok := p.p.(prefix); ok {
+		s = "(" + s + ")"
+	}
+	return s + "[[" + strings.Join(pairs, ", ") + "]]"
+}
+func (p prioritise) String() string {
+	sets := make([]string, len(p.order))
+	for i, events := range p.order {
+		sets[i] = "{" + strings.Join(events, ", ") + "}"
+	}
+	return "prioritise(" + p.p.String() + ", ⟨" + strings.Join(sets, ", ") + "⟩)"
+}
+func (p rec) String() string      { return "(μ " + p.name + " • " + p.body.String() + ")" }
+func (p variable) String() string { return p.name }
+
+func (stop) steps() []step  { return nil }
+func (skip) steps() []step  { return []step{{lts.Tick, omega{}, 1}} }
+func (omega) steps() []step { return nil }
+
+func (p prefix) steps() []step { return []step{{p.event, p.next, 1}} }
+
+// the first visible event of either process resolves the choice.
+func (p extChoice) steps() []step {
+	var steps []step
+	ps, qs := p.p.steps(), p.q.steps()
+	for _, s := range ps {
+		switch s.event {
+		case lts.Tau:
+			s.next = extChoice{s.next, p.q}
+		case Tock:
+			continue
+		}
+		steps = append(steps, s)
+	}
+	for _, s := range qs {
+		switch s.event {
+		case lts.Tau:
+			s.next = extChoice{p.p, s.next}
+		case Tock:
+			continue
+		}
+		steps = append(steps, s)
+	}
+	return append(steps, tocks(p.p, ps, p.q, qs, func(p, q Process) Process { return extChoice{p, q} })...)
+}
+
+func (p intChoice) steps() []step { return []step{{lts.Tau, p.p, 1}, {lts.Tau, p.q, 1}} }
+
+// a process of the composition which terminates becomes Ω by a Tau
+// transition, and the composition of Ω with Ω terminates.
+func (p par) steps() []step {
+	var steps []step
+	ps, qs := p.p.steps(), p.q.steps()
+	for _, s := range ps {
+		switch {
+		case s.event == Tock:
+		case s.event == lts.Tick:
+			steps = append(steps, step{lts.Tau, par{omega{}, p.sync, p.q}, 1})
+		case !contains(p.sync, s.event):
+			steps = append(steps, step{s.event, par{s.next, p.sync, p.q}, s.weight})
+		default:
+			for _, t := range qs {
+				if t.event == s.event {
+					steps = append(steps, step{s.event, par{s.next, p.sync, t.next}, 1})
+				}
+			}
+		}
+	}
+	for _, t := range qs {
+		switch {
+		case t.event == Tock:
+		case t.event == lts.Tick:
+			steps = append(steps, step{lts.Tau, par{p.p, p.sync, omega{}}, 1})
+		case !contains(p.sync, t.event):
+			steps = append(steps, step{t.event, par{p.p, p.sync, t.next}, t.weight})
+		}
+	}
+	steps = append(steps, tocks(p.p, ps, p.q, qs, func(q, r Process) Process { return par{q, p.sync, r} })...)
+	_, pdone := p.p.(omega)
+	_, qdone := p.q.(omega)
+	if pdone && qdone {
+		steps = append(steps, step{lts.Tick, omega{}, 1})
+	}
+	return steps
+}
+
+// as for par, events outside the alphabet of a process are blocked.
+func (p parAlpha) steps() []step {
+	var steps []step
+	ps, qs := p.p.steps(), p.q.steps()
+	for _, s := range ps {
+		switch {
+		case s.event == Tock:
+		case s.event == lts.Tick:
+			steps = append(steps, step{lts.Tau, parAlpha{omega{}, p.q, p.a, p.b}, 1})
+		case s.event == lts.Tau || contains(p.a, s.event) && !contains(p.b, s.event):
+			steps = append(steps, step{s.event, parAlpha{s.next, p.q, p.a, p.b}, s.weight})
+		case contains(p.a, s.event):
+			for _, t := range qs {
+				if t.event == s.event {
+					steps = append(steps, step{s.event, parAlpha{s.next, t.next, p.a, p.b}, 1})
+				}
+			}
+		}
+	}
+	for _, t := range qs {
+		switch {
+		case t.event == Tock:
+		case t.event == lts.Tick:
+			steps = append(steps, step{lts.Tau, parAlpha{p.p, omega{}, p.a, p.b}, 1})
+		case t.event == lts.Tau || contains(p.b, t.event) && !contains(p.a, t.event):
+			steps = append(steps, step{t.event, parAlpha{p.p, t.next, p.a, p.b}, t.weight})
+		}
+	}
+	steps = append(steps, tocks(p.p, ps, p.q, qs, func(q, r Process) Process { return parAlpha{q, r, p.a, p.b} })...)
+	_, pdone := p.p.(omega)
+	_, qdone := p.q.(omega)
+	if pdone && qdone {
+		steps = append(steps, step{lts.Tick, omega{}, 1})
+	}
+	return steps
+}
+
+func (p seq) steps() []step {
+	var steps []step
+	for _, s := range p.p.steps() {
+		if s.event == lts.Tick {
+			steps = append(steps, step{lts.Tau, p.q, 1})
+			continue
+		}
+		steps = append(steps, step{s.event, seq{s.next, p.q}, s.weight})
+	}
+	return steps
+}
+
+func (p interrupt) steps() []step {
+	var steps []step
+	ps, qs := p.p.steps(), p.q.steps()
+	for _, s := range ps {
+		switch s.event {
+		case Tock:
+			continue
+		case lts.Tick:
+		default:
+			s.next = interrupt{s.next, p.q}
+		}
+		steps = append(steps, s)
+	}
+	for _, s := range qs {
+		switch s.event {
+		case Tock:
+			continue
+		case lts.Tau:
+			s.next = interrupt{p.p, s.next}
+		}
+		steps = append(steps, s)
+	}
+	return append(steps, tocks(p.p, ps, p.q, qs, func(p, q Process) Process { return interrupt{p, q} })...)
+}
+
+func (p hide) steps() []step {
+	var steps []step
+	for _, s := range p.p.steps() {
+		if s.event != lts.Tick {
+			if s.event != Tock && contains(p.events, s.event) {
+				s.event = lts.Tau
+			}
+			s.next = hide{s.next, p.events}
+		}
+		steps = append(steps, s)
+	}
+	return steps
+}
+
+func (p rename) steps() []step {
+	var steps []step
+	for _, s := range p.p.steps() {
+		if s.event != lts.Tick {
+			s.event = p.rename(s.event)
+			s.next = rename{s.next, p.pairs}
+		}
+		steps = append(steps, s)
+	}
+	return steps
+}
+
+// rename returns the event e renamed, as an event rather than of a
+// channel if renamed both ways.
+func (p rename) rename(e string) string {
+	for _, r := range p.pairs {
+		if e == r[0] {
+			return r[1]
+		}
+	}
+	for _, r := range p.pairs {
+		if strings.HasPrefix(e, r[0]+".") {
+			return r[1] + e[len(r[0]):]
+		}
+	}
+	return e
+}
+
+// the transitions of the highest priority are kept, with those of
+// events of no priority.
+func (p prioritise) steps() []step {
+	ps := p.p.steps()
+	top := len(p.order)
+	for _, s := range ps {
+		if i := p.priority(s.event); i >= 0 && i < top {
+			top = i
+		}
+	}
+	var steps []step
+	for _, s := range ps {
+		if p.priority(s.event) > top {
+			continue
+		}
+		if s.event != lts.Tick {
+			s.next = prioritise{s.next, p.order}
+		}
+		steps = append(steps, s)
+	}
+	return steps
+}
+
+// priority returns the index of the set of the event e in the order,
+// 0 for internal transitions and termination, -1 if of no set.
+func (p prioritise) priority(e string) int {
+	if e == lts.Tau || e == lts.Tick {
+		return 0
+	}
+	for i, events := range p.order {
+		if contains(events, e) {
+			return i
+		}
+	}
+	return -1
+}
+
+func (p rec) steps() []step { return []step{{lts.Tau, p.body.subst(p.name, p), 1}} }
+
+func (p variable) steps() []step { panic(unbound(p.name)) }
+
+func (p stop) subst(string, Process) Process  { return p }
+func (p skip) subst(string, Process) Process  { return p }
+func (p omega) subst(string, Process) Process { return p }
+func (p prefix) subst(name string, r Process) Process {
+	return prefix{p.event, p.next.subst(name, r)}
+}
+func (p extChoice) subst(name string, r Process) Process {
+	return extChoice{p.p.subst(name, r), p.q.subst(name, r)}
+}
+func (p intChoice) subst(name string, r Process) Process {
+	return intChoice{p.p.subst(name, r), p.q.subst(name, r)}
+}
+func (p par) subst(name string, r Process) Process {
+	return par{p.p.subst(name, r), p.sync, p.q.subst(name, r)}
+}
+func (p parAlpha) subst(name string, r Process) Process {
+	return parAlpha{p.p.subst(name, r), p.q.subst(name, r), p.a, p.b}
+}
+func (p seq) subst(name string, r Process) Process {
+	return seq{p.p.subst(name, r), p.q.subst(name, r)}
+}
+func (p interrupt) subst(name string, r Process) Process {
+	return interrupt{p.p.subst(name, r), p.q.subst(name, r)}
+}
+func (p hide) subst(name string, r Process) Process {
+	return hide{p.p.subst(name, r), p.events}
+}
+func (p rename) subst(name string, r Process) Process {
+	return rename{p.p.subst(name, r), p.pairs}
+}
+func (p prioritise) subst(name string, r Process) Process {
+	return prioritise{p.p.subst(name, r), p.order}
+}
+func (p rec) subst(name string, r Process) Process {
+	if p.name == name {
+		return p
+	}
+	return rec{p.name, p.body.subst(name, r)}
+}
+func (p variable) subst(name string, r Process) Process {
+	if p.name == name {
+		return r
+	}
+	return p
+}
+
+// unbound is the failure of a process with a free variable.
+type unbound string
+
+// LTS returns the labelled transition system of p, whose states are the
+// processes p may become, the initial state p itself. It fails if p
+// has more than DefaultMaxStates states, or a free variable.
+func LTS(p Process) (l *lts.LTS, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			name, ok := r.(unbound)
+			if !ok {
+				panic(r)
+			}
+			l, err = nil, fmt.Errorf("algebra: unbound variable %s", string(name))
+		}
+	}()
+	l = &lts.LTS{Trans: [][]lts.Transition{nil}}
+	procs := []Process{p}
+	seen := map[string]int{p.String(): 0}
+	for i := 0; i < len(procs); i++ {
+		added := map[lts.Transition]bool{}
+		for _, s := range procs[i].steps() {
+			k := s.next.String()
+			j, ok := seen[k]
+			if !ok {
+				if len(procs) == DefaultMaxStates {
+					return nil, fmt.Errorf("algebra: process has more than %d states", DefaultMaxStates)
+				}
+				j = len(procs)
+				seen[k] = j
+				procs = append(procs, s.next)
+				l.Trans = append(l.Trans, nil)
+			}
+			t := lts.Transition{Event: s.event, To: j}
+			if !added[t] {
+				added[t] = true
+				l.Trans[i] = append(l.Trans[i], t)
+			}
+		}
+	}
+	return l, nil
+}
+
+// AlphabetOf returns the alphabet of p, sorted: the events which occur
+// in the term, other than those hidden, renamed as by its renamings,
+// or outside the alphabets of an alphabetised parallel composition.
+// It contains every event p can engage in, other than Tock, such as
+// those of the alphabetised composition of processes in their own
+// alphabets
+//
+//   algebra.ParAlpha(p, algebra.AlphabetOf(p), q, algebra.AlphabetOf(q))
+func AlphabetOf(p Process) []Event {
+	events := alphabet(p)
+	alpha := make([]Event, len(events))
+	for i, e := range events {
+		alpha[i] = Event(e)
+	}
+	return alpha
+}
+
+// alphabet returns the alphabet of p, as a set.
+func alphabet(p Process) []string {
+	var events []Event
+	add := func(set []string, in func(string) bool) {
+		for _, e := range set {
+			if in == nil || in(e) {
+				events = append(events, Event(e))
+			}
+		}
+	}
+	switch p := p.(type) {
+	case prefix:
+		events = append(events, Event(p.event))
+		add(alphabet(p.next), nil)
+	case extChoice:
+		add(alphabet(p.p), nil)
+		add(alphabet(p.q), nil)
+	case intChoice:
+		add(alphabet(p.p), nil)
+		add(alphabet(p.q), nil)
+	case probChoice:
+		add(alphabet(p.p), nil)
+		add(alphabet(p.q), nil)
+	case par:
+		add(alphabet(p.p), nil)
+		add(alphabet(p.q), nil)
+	case parAlpha:
+		add(alphabet(p.p), func(e string) bool { return contains(p.a, e) })
+		add(alphabet(p.q), func(e string) bool { return contains(p.b, e) })
+	case seq:
+		add(alphabet(p.p), nil)
+		add(alphabet(p.q), nil)
+	case interrupt:
+		add(alphabet(p.p), nil)
+		add(alphabet(p.q), nil)
+	case timeout:
+		add(alphabet(p.p), nil)
+		add(alphabet(p.q), nil)
+	case hide:
+		add(alphabet(p.p), func(e string) bool { return !contains(p.events, e) })
+	case rename:
+		for _, e := range alphabet(p.p) {
+			events = append(events, Event(p.rename(e)))
+		}
+	case prioritise:
+		add(alphabet(p.p), nil)
+	case rec:
+		add(alphabet(p.body), nil)
+	}
+	return set(events)
+}
+
+// set returns the events, sorted and without duplicates.
+func set(events []Event) []string {
+	seen := map[string]bool{}
+	var set []string
+	for _, e := range events {
+		if !seen[string(e)] {
+			seen[string(e)] = true
+			set = append(set, string(e))
+		}
+	}
+	sort.Strings(set)
+	return set
+}
+
+// contains reports whether the sorted events contain e.
+func contains(events []string, e string) bool {
+	i := sort.SearchStrings(events, e)
+	return i < len(events) && events[i] == e
+}
@@ -0,0 +1,161 @@
+package algebra_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/changkun/gobase/csp/algebra"
+	"github.com/changkun/gobase/csp/lts"
+)
+
+var (
+	a, b = algebra.Prefix("a", algebra.Stop()), algebra.Prefix("b", algebra.Skip())
+
+	// buf is a buffer of one value.
+	buf = algebra.Rec("B", algebra.Prefix("in", algebra.Prefix("out", algebra.Var("B"))))
+)
+
+// transitions formats the transitions of l, such as 0 -a-> 1.
+func transitions(l *lts.LTS) string {
+	var ts []string
+	for s, trans := range l.Trans {
+		for _, t := range trans {
+			ts = append(ts, fmt.Sprintf("%d -%s-> %d", s, t.Event, t.To))
+		}
+	}
+	return strings.Join(ts, ", ")
+}
+
+func TestEvent(t *testing.T) {
+	tests := []struct {
+		e                algebra.Event
+		want, ch, values string
+	}{
+		{algebra.NewEvent("tick"), "tick", "tick", ""},
+		{algebra.NewEvent("in", 0), "in.0", "in", "0"},
+		{algebra.NewEvent("c", 1, 'x', "y", true), "c.1.120.y.true", "c", "1.120.y.true"},
+		{"out.0", "out.0", "out", "0"},
+	}
+	for _, tt := range tests {
+		if got := string(tt.e); got != tt.want {
+			t.Fatalf("%v: expected: %v, got: %v", t.Name(), tt.want, got)
+		}
+		if got := tt.e.Chan(); got != tt.ch {
+			t.Fatalf("%v: %v: expected channel: %v, got: %v", t.Name(), tt.e, tt.ch, got)
+		}
+		if got := tt.e.Value(); got != tt.values {
+			t.Fatalf("%v: %v: expected value: %v, got: %v", t.Name(), tt.e, tt.values, got)
+		}
+	}
+}
+
+func TestString(t *testing.T) {
+	tests := []struct {
+		p    algebra.Process
+		want string
+	}{
+		{algebra.Stop(), "STOP"},
+		{algebra.Prefix("a", b), "a → b → SKIP"},
+		{algebra.Prefix(algebra.NewEvent("in", 0), algebra.Prefix(algebra.NewEvent("out", 0), algebra.Stop())), "in.0 → out.0 → STOP"},
+		{algebra.ExtChoice(a, b), "(a → STOP □ b → SKIP)"},
+		{algebra.IntChoice(a, algebra.Skip()), "(a → STOP ⊓ SKIP)"},
+		{algebra.Par(a, []algebra.Event{"b", "a", "b"}, b), "(a → STOP [|{a, b}|] b → SKIP)"},
+		{algebra.Interleave(a, b), "(a → STOP ||| b → SKIP)"},
+		{algebra.ParAlpha(a, []algebra.Event{"a"}, b, []algebra.Event{"b", "a"}), "(a → STOP [{a} || {a, b}] b → SKIP)"},
+		{algebra.Seq(b, a), "(b → SKIP ; a → STOP)"},
+		{algebra.Interrupt(a, b), "(a → STOP △ b → SKIP)"},
+		{algebra.Hide(buf, []algebra.Event{"out", "in", "out"}), "((μ B • in → out → B) \\ {in, out})"},
+		{buf, "(μ B • in → out → B)"},
+		{algebra.Rename(a, map[algebra.Event]algebra.Event{"b": "c", "a": "b"}), "(a → STOP)[[a <- b, b <- c]]"},
+		{algebra.Rename(buf, map[algebra.Event]algebra.Event{"in": "out"}), "(μ B • in → out → B)[[in <- out]]"},
+		{algebra.Prioritise(algebra.ExtChoice(a, b), []algebra.Event{"b", "a"}, nil), "prioritise((a → STOP □ b → SKIP), ⟨{a, b}, {}⟩)"},
+	}
+	for _, tt := range tests {
+		if got := tt.p.String(); got != tt.want {
+			t.Fatalf("%v: expected: %v, got: %v", t.Name(), tt.want, got)
+		}
+	}
+}
+
+func TestLTS(t *testing.T) {
+	tests := []struct {
+		p    algebra.Process
+		want string
+	}{
+		{algebra.Stop(), ""},
+		{algebra.Skip(), "0 -✓-> 1"},
+		{algebra.Prefix("a", b), "0 -a-> 1, 1 -b-> 2, 2 -✓-> 3"},
+		// the first event resolves the choice, but not a Tau.
+		{algebra.ExtChoice(algebra.IntChoice(a, a), b), "0 -τ-> 1, 0 -b-> 2, 1 -a-> 3, 1 -b-> 2, 2 -✓-> 4"},
+		{algebra.IntChoice(a, b), "0 -τ-> 1, 0 -τ-> 2, 1 -a-> 3, 2 -b-> 4, 4 -✓-> 5"},
+		// a and b synchronize, and the composition terminates once
+		// both processes terminated.
+		{algebra.Par(algebra.Prefix("a", b), []algebra.Event{"b"}, algebra.Prefix("b", algebra.Skip())), "0 -a-> 1, 1 -b-> 2, 2 -τ-> 3, 2 -τ-> 4, 3 -τ-> 5, 4 -τ-> 5, 5 -✓-> 6"},
+		{algebra.Interleave(a, algebra.Prefix("a", algebra.Stop())), "0 -a-> 1, 0 -a-> 2, 1 -a-> 3, 2 -a-> 3"},
+		// a synchronizes, c is outside the alphabet of its process.
+		{algebra.ParAlpha(algebra.Prefix("a", algebra.Prefix("c", algebra.Stop())), []algebra.Event{"a"}, algebra.Prefix("b", a), []algebra.Event{"a", "b"}), "0 -b-> 1, 1 -a-> 2"},
+		{algebra.ParAlpha(b, []algebra.Event{"b"}, algebra.Skip(), nil), "0 -b-> 1, 0 -τ-> 2, 1 -τ-> 3, 1 -τ-> 4, 2 -b-> 4, 3 -τ-> 5, 4 -τ-> 5, 5 -✓-> 6"},
+		{algebra.Seq(b, a), "0 -b-> 1, 1 -τ-> 2, 2 -a-> 3"},
+		// b aborts a → c → STOP in any of its states.
+		{algebra.Interrupt(algebra.Prefix("a", algebra.Prefix("c", algebra.Stop())), b), "0 -a-> 1, 0 -b-> 2, 1 -c-> 3, 1 -b-> 2, 2 -✓-> 4, 3 -b-> 2"},
+		{buf, "0 -τ-> 1, 1 -in-> 2, 2 -out-> 0"},
+		// hiding all events of a recursion diverges, termination is
+		// not hidden.
+		{algebra.Hide(buf, []algebra.Event{"out"}), "0 -τ-> 1, 1 -in-> 2, 2 -τ-> 0"},
+		{algebra.Hide(buf, []algebra.Event{"in", "out"}), "0 -τ-> 1, 1 -τ-> 2, 2 -τ-> 0"},
+		{algebra.Hide(algebra.Prefix("a", b), []algebra.Event{"a", "b"}), "0 -τ-> 1, 1 -τ-> 2, 2 -✓-> 3"},
+		// events and channels are renamed simultaneously.
+		{algebra.Rename(algebra.Prefix("a", b), map[algebra.Event]algebra.Event{"a": "b", "b": "a"}), "0 -b-> 1, 1 -a-> 2, 2 -✓-> 3"},
+		{algebra.Rename(algebra.Prefix("in.0", algebra.Prefix("in", algebra.Prefix("in.1", algebra.Stop()))), map[algebra.Event]algebra.Event{"in": "out", "in.1": "x"}), "0 -out.0-> 1, 1 -out-> 2, 2 -x-> 3"},
+		{algebra.Prioritise(algebra.ExtChoice(a, b), []algebra.Event{"b"}, []algebra.Event{"a"}), "0 -b-> 1, 1 -✓-> 2"},
+		// events of no priority are unaffected, internal transitions are
+		// of the first priority.
+		{algebra.Prioritise(algebra.ExtChoice(a, b), []algebra.Event{"c"}, []algebra.Event{"b"}), "0 -a-> 1, 0 -b-> 2, 2 -✓-> 3"},
+		{algebra.Prioritise(algebra.ExtChoice(a, algebra.IntChoice(b, b)), []algebra.Event{"b"}, []algebra.Event{"a"}), "0 -τ-> 1, 1 -b-> 2, 2 -✓-> 3"},
+		{algebra.Rec("X", algebra.Var("X")), "0 -τ-> 0"},
+	}
+	for _, tt := range tests {
+		l, err := algebra.LTS(tt.p)
+		if err != nil {
+			t.Fatalf("%v: %v: unexpected error: %v", t.Name(), tt.p, err)
+		}
+		if got := transitions(l); got != tt.want {
+			t.Fatalf("%v: %v: expected: %v, got: %v", t.Name(), tt.p, tt.want, got)
+		}
+	}
+}
+
+func TestAlphabetOf(t *testing.T) {
+	tests := []struct {
+		p    algebra.Process
+		want string
+	}{
+		{algebra.Stop(), "[]"},
+		{buf, "[in out]"},
+		{algebra.ExtChoice(a, algebra.Seq(b, algebra.Wait(1))), "[a b]"},
+		{algebra.ParAlpha(algebra.Prefix("a", algebra.Prefix("c", algebra.Stop())), []algebra.Event{"a"}, b, []algebra.Event{"a", "b"}), "[a b]"},
+		{algebra.Hide(algebra.Interleave(a, b), []algebra.Event{"a"}), "[b]"},
+		{algebra.Rename(algebra.Prefix("in.0", a), map[algebra.Event]algebra.Event{"in": "out", "a": "b"}), "[b out.0]"},
+	}
+	for _, tt := range tests {
+		if got := fmt.Sprint(algebra.AlphabetOf(tt.p)); got != tt.want {
+			t.Fatalf("%v: %v: expected: %v, got: %v", t.Name(), tt.p, tt.want, got)
+		}
+	}
+}
+
+func TestLTSErrors(t *testing.T) {
+	tests := []struct {
+		p   algebra.Process
+		err string
+	}{
+		{algebra.Prefix("a", algebra.Var("X")), "algebra: unbound variable X"},
+		{algebra.Rec("X", algebra.Prefix("a", algebra.Par(algebra.Var("X"), nil, algebra.Var("X")))), "algebra: process has more than 100000 states"},
+	}
+	for _, tt := range tests {
+		if _, err := algebra.LTS(tt.p); err == nil || err.Error() != tt.err {
+			t.Fatalf("%v: %v: expected: %v, got: %v", t.Name(), tt.p, tt.err, err)
+		}
+	}
+}
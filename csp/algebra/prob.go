@@ -0,0 +1,146 @@
+package algebra
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"strconv"
+
+	"github.com/changkun/gobase/csp/lts"
+)
+
+// DefaultMaxSteps is the number of transitions a run of Estimate takes
+// at most.
+const DefaultMaxSteps = 10000
+
+// ErrUnfinished reports that a run of Estimate took DefaultMaxSteps
+// transitions without terminating or deadlocking, such as a run of a
+// process which runs forever.
+var ErrUnfinished = errors.New("algebra: run unfinished")
+
+type probChoice struct {
+	p    Process
+	w, v float64
+	q    Process
+}
+
+// ProbChoice returns p [w ⊕ v] q, the probabilistic choice of p and q,
+// which behaves as p with probability w / (w + v), and otherwise as q.
+// In the transition system, it is the internal choice p ⊓ q, which a
+// Machine resolves by the weights, such as the backoff of a retry
+//
+//   algebra.ProbChoice(algebra.Wait(1), 1, algebra.Wait(2), 1)
+//
+// which waits one or two units of time, equally likely. The weights
+// must be positive.
+func ProbChoice(p Process, w float64, q Process, v float64) Process {
+	if !(w > 0 && v > 0) {
+		panic(fmt.Sprintf("algebra: probabilistic choice of weights %v and %v", w, v))
+	}
+	return probChoice{p, w, v, q}
+}
+
+func (p probChoice) String() string {
+	return "(" + p.p.String() + " [" + strconv.FormatFloat(p.w, 'g', -1, 64) + " ⊕ " +
+		strconv.FormatFloat(p.v, 'g', -1, 64) + "] " + p.q.String() + ")"
+}
+
+func (p probChoice) steps() []step {
+	return []step{{lts.Tau, p.p, p.w / (p.w + p.v)}, {lts.Tau, p.q, p.v / (p.w + p.v)}}
+}
+
+func (p probChoice) subst(name string, r Process) Process {
+	return probChoice{p.p.subst(name, r), p.w, p.v, p.q.subst(name, r)}
+}
+
+// pick returns one of the steps at random, as likely as its weight.
+func pick(r *rand.Rand, steps []step) step {
+	total := 0.0
+	for _, s := range steps {
+		total += s.weight
+	}
+	x := r.Float64() * total
+	for _, s := range steps {
+		if x < s.weight {
+			return s
+		}
+		x -= s.weight
+	}
+	return steps[len(steps)-1]
+}
+
+// Estimate estimates the probability of every outcome of p by runs of
+// it, the fraction of the runs of the outcome, a Monte-Carlo
+// simulation. A run is seeded by its number, from 0, such that the
+// estimate is reproducible.
+//
+// The environment of a run engages in any event, and a run resolves
+// every choice at random: of the events and internal transitions of p,
+// as likely as their weights, and after them of its Tock transitions,
+// in virtual time. It ends once p terminates, deadlocks, or took
+// DefaultMaxSteps transitions, and outcome returns its outcome of its
+// timed trace and how it ended: nil, ErrDeadlock, or ErrUnfinished. If
+// outcome is nil, the outcome is the timed trace, such as
+// [(0, a) (2, b)], followed by the error, if any.
+//
+// Estimate fails if p has a free variable.
+func Estimate(p Process, runs int, outcome func(trace []TimedEvent, err error) string) (probs map[string]float64, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			name, ok := r.(unbound)
+			if !ok {
+				panic(r)
+			}
+			probs, err = nil, fmt.Errorf("algebra: unbound variable %s", string(name))
+		}
+	}()
+	if outcome == nil {
+		outcome = func(trace []TimedEvent, err error) string {
+			if err != nil {
+				return fmt.Sprint(trace) + ": " + err.Error()
+			}
+			return fmt.Sprint(trace)
+		}
+	}
+	probs = map[string]float64{}
+	for i := 0; i < runs; i++ {
+		trace, err := simulate(p, rand.New(rand.NewSource(int64(i))))
+		probs[outcome(trace, err)] += 1 / float64(runs)
+	}
+	return probs, nil
+}
+
+// simulate runs p once, as of Estimate.
+func simulate(p Process, r *rand.Rand) ([]TimedEvent, error) {
+	var trace []TimedEvent
+	now := 0
+	for i := 0; i < DefaultMaxSteps; i++ {
+		var steps, tocks []step
+		for _, s := range p.steps() {
+			if s.event == Tock {
+				tocks = append(tocks, s)
+				continue
+			}
+			steps = append(steps, s)
+		}
+		switch {
+		case len(steps) > 0:
+		case len(tocks) > 0:
+			p = tocks[r.Intn(len(tocks))].next
+			now++
+			continue
+		default:
+			return trace, ErrDeadlock
+		}
+		s := pick(r, steps)
+		switch s.event {
+		case lts.Tick:
+			return trace, nil
+		case lts.Tau:
+		default:
+			trace = append(trace, TimedEvent{now, Event(s.event)})
+		}
+		p = s.next
+	}
+	return trace, ErrUnfinished
+}
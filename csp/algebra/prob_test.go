@@ -0,0 +1,95 @@
+package algebra_test
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"strconv"
+	"testing"
+
+	"github.com/changkun/gobase/csp/algebra"
+)
+
+func TestProbChoice(t *testing.T) {
+	p := algebra.ProbChoice(a, 1, b, 3)
+	if want := "(a → STOP [1 ⊕ 3] b → SKIP)"; p.String() != want {
+		t.Fatalf("%v: expected: %v, got: %v", t.Name(), want, p.String())
+	}
+	l, err := algebra.LTS(p)
+	if err != nil {
+		t.Fatalf("%v: unexpected error: %v", t.Name(), err)
+	}
+	if got, want := transitions(l), "0 -τ-> 1, 0 -τ-> 2, 1 -a-> 3, 2 -b-> 4, 4 -✓-> 5"; got != want {
+		t.Fatalf("%v: expected: %v, got: %v", t.Name(), want, got)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("%v: expected a panic of weight 0", t.Name())
+		}
+	}()
+	algebra.ProbChoice(a, 0, b, 1)
+}
+
+func TestMachineProbChoice(t *testing.T) {
+	// the machine chooses a once in ten runs.
+	p := algebra.ProbChoice(algebra.Prefix("a", algebra.Skip()), 1, algebra.Prefix("b", algebra.Skip()), 9)
+	n := 0
+	for seed := int64(0); seed < 200; seed++ {
+		chA, chB := make(chan algebra.Event, 1), make(chan algebra.Event, 1)
+		m := &algebra.Machine{Process: p, Env: map[string]chan<- algebra.Event{"a": chA, "b": chB}, Rand: rand.New(rand.NewSource(seed))}
+		if err := m.Run(context.Background()); err != nil {
+			t.Fatalf("%v: unexpected error: %v", t.Name(), err)
+		}
+		n += len(chA)
+	}
+	if n < 5 || n > 40 {
+		t.Fatalf("%v: expected about 20 runs of a, got: %v", t.Name(), n)
+	}
+}
+
+func TestEstimate(t *testing.T) {
+	// a send fails half of the time, and is retried after a backoff of
+	// one or two units of time.
+	retry := algebra.Rec("X", algebra.ProbChoice(algebra.Prefix("ok", algebra.Skip()), 1,
+		algebra.Prefix("fail", algebra.Seq(algebra.ProbChoice(algebra.Wait(1), 1, algebra.Wait(2), 1), algebra.Var("X"))), 1))
+	fails := func(trace []algebra.TimedEvent, err error) string {
+		return strconv.Itoa(len(trace) - 1)
+	}
+	// the time of the first retry.
+	backoff := func(trace []algebra.TimedEvent, err error) string {
+		if len(trace) < 2 {
+			return "none"
+		}
+		return strconv.Itoa(trace[1].Time)
+	}
+	tests := []struct {
+		p       algebra.Process
+		outcome func([]algebra.TimedEvent, error) string
+		want    map[string]float64
+	}{
+		{algebra.ProbChoice(a, 1, b, 3), nil, map[string]float64{"[(0, a)]: algebra: deadlock": 0.25, "[(0, b)]": 0.75}},
+		{retry, fails, map[string]float64{"0": 0.5, "1": 0.25, "2": 0.125}},
+		{retry, backoff, map[string]float64{"none": 0.5, "1": 0.25, "2": 0.25}},
+	}
+	for _, tt := range tests {
+		got, err := algebra.Estimate(tt.p, 2000, tt.outcome)
+		if err != nil {
+			t.Fatalf("%v: %v: unexpected error: %v", t.Name(), tt.p, err)
+		}
+		for outcome, want := range tt.want {
+			if math.Abs(got[outcome]-want) > 0.05 {
+				t.Fatalf("%v: %v: expected %v with probability: %v, got: %v", t.Name(), tt.p, outcome, want, got)
+			}
+		}
+	}
+	got, err := algebra.Estimate(buf, 1, func(_ []algebra.TimedEvent, err error) string {
+		return strconv.FormatBool(errors.Is(err, algebra.ErrUnfinished))
+	})
+	if err != nil || got["true"] != 1 {
+		t.Fatalf("%v: %v: expected unfinished runs, got: %v, %v", t.Name(), buf, got, err)
+	}
+	if _, err := algebra.Estimate(algebra.Var("X"), 1, nil); err == nil || err.Error() != "algebra: unbound variable X" {
+		t.Fatalf("%v: expected: %v, got: %v", t.Name(), "algebra: unbound variable X", err)
+	}
+}
@@ -0,0 +1,126 @@
+package algebra
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/changkun/gobase/csp/alt"
+	"github.com/changkun/gobase/csp/lts"
+)
+
+// ErrDeadlock reports that a running process can engage in none of its
+// events, such as STOP.
+var ErrDeadlock = errors.New("algebra: deadlock")
+
+// Machine executes a process with its environment, as a csp.Process.
+// The process engages in an event, such as in.0, by sending it on the
+// channel Env maps its channel in to, once the environment receives
+// it. Events of channels not in Env are refused.
+//
+// The environment resolves an external choice by the event it receives
+// first, the machine an internal choice by a coin flip, weighted for a
+// probabilistic choice: a process offering both events and internal
+// transitions, such as a □ (b ⊓ c), engages in an event the environment
+// is ready to receive, if any, and otherwise takes one of its internal
+// transitions at random. For the buffer of one value
+//
+//   in, out := make(chan algebra.Event), make(chan algebra.Event)
+//   m := &algebra.Machine{Process: buf, Env: map[string]chan<- algebra.Event{"in": in, "out": out}}
+//   go m.Run(ctx)
+//   <-in  // in
+//   <-out // out
+//
+// Time passes by the Tock transitions of the process, in units of Unit
+// of wall-clock time, such that WAIT 2 terminates within two units of
+// time after it started. Without a Unit, time is virtual: it passes at
+// once whenever the process can take no other transition, after its
+// internal ones, the maximal progress of Timed CSP.
+type Machine struct {
+	Process Process
+	Env     map[string]chan<- Event
+	Rand    *rand.Rand    // of the internal choices, seeded by the time if nil
+	Unit    time.Duration // of time, virtual if zero
+
+	// Trace, if not nil, is called with every event the process
+	// engages in, at the time it engages in it.
+	Trace func(TimedEvent)
+}
+
+// Run executes m.Process until it terminates. It fails if the process
+// deadlocks, has a free variable, or ctx is done.
+func (m *Machine) Run(ctx context.Context) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			name, ok := r.(unbound)
+			if !ok {
+				panic(r)
+			}
+			err = fmt.Errorf("algebra: unbound variable %s", string(name))
+		}
+	}()
+	r := m.Rand
+	if r == nil {
+		r = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	p := m.Process
+	start, now, timed := time.Now(), 0, false
+	for {
+		if m.Unit > 0 && !timed {
+			// time passed without changing the process.
+			now = int(time.Since(start) / m.Unit)
+		}
+		var internal, external, tocks []step
+		for _, s := range p.steps() {
+			switch {
+			case s.event == lts.Tau || s.event == lts.Tick:
+				internal = append(internal, s)
+			case s.event == Tock:
+				tocks = append(tocks, s)
+			case m.Env[Event(s.event).Chan()] != nil:
+				external = append(external, s)
+			}
+		}
+		if len(internal)+len(external)+len(tocks) == 0 {
+			return ErrDeadlock
+		}
+		timed = len(tocks) > 0
+
+		const tock = -2
+		a, next := alt.New(), -1
+		for i, s := range external {
+			i, e := i, Event(s.event)
+			a.Add(alt.Send(m.Env[e.Chan()], func() Event { return e }, func() { next = i }))
+		}
+		switch {
+		case len(internal) > 0:
+			a.Add(alt.Skip(nil))
+		case timed && m.Unit == 0:
+			a.Add(alt.Skip(func() { next = tock }))
+		case timed:
+			a.Add(alt.After(time.Until(start.Add(time.Duration(now+1)*m.Unit)), func() { next = tock }))
+		}
+		if _, ok := a.SelectContext(ctx); !ok {
+			return ctx.Err()
+		}
+		switch {
+		case next >= 0:
+			p = external[next].next
+			if m.Trace != nil {
+				m.Trace(TimedEvent{now, Event(external[next].event)})
+			}
+			continue
+		case next == tock:
+			p = tocks[r.Intn(len(tocks))].next
+			now++
+			continue
+		}
+		s := pick(r, internal)
+		if s.event == lts.Tick {
+			return nil
+		}
+		p = s.next
+	}
+}
@@ -0,0 +1,120 @@
+package algebra_test
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/changkun/gobase/csp"
+	"github.com/changkun/gobase/csp/algebra"
+)
+
+func TestMachineExtChoice(t *testing.T) {
+	// the environment receives b.1 rather than a, the process
+	// deadlocks afterwards.
+	p := algebra.ExtChoice(algebra.Prefix("a", algebra.Skip()), algebra.Prefix("b.1", algebra.Stop()))
+	chA, chB := make(chan algebra.Event), make(chan algebra.Event)
+	r := csp.Go(context.Background(), &algebra.Machine{Process: p, Env: map[string]chan<- algebra.Event{"a": chA, "b": chB}})
+	if got := <-chB; got != "b.1" {
+		t.Fatalf("%v: expected: %v, got: %v", t.Name(), "b.1", got)
+	}
+	if err := r.Wait(); !errors.Is(err, algebra.ErrDeadlock) {
+		t.Fatalf("%v: expected: %v, got: %v", t.Name(), algebra.ErrDeadlock, err)
+	}
+}
+
+func TestMachineIntChoice(t *testing.T) {
+	// the machine chooses a or b, whichever the environment is
+	// ready for.
+	p := algebra.IntChoice(algebra.Prefix("a", algebra.Skip()), algebra.Prefix("b", algebra.Skip()))
+	seen := map[algebra.Event]bool{}
+	for seed := int64(0); seed < 20; seed++ {
+		chA, chB := make(chan algebra.Event), make(chan algebra.Event)
+		m := &algebra.Machine{Process: p, Env: map[string]chan<- algebra.Event{"a": chA, "b": chB}, Rand: rand.New(rand.NewSource(seed))}
+		r := csp.Go(context.Background(), m)
+		select {
+		case e := <-chA:
+			seen[e] = true
+		case e := <-chB:
+			seen[e] = true
+		}
+		if err := r.Wait(); err != nil {
+			t.Fatalf("%v: unexpected error: %v", t.Name(), err)
+		}
+	}
+	if !seen["a"] || !seen["b"] {
+		t.Fatalf("%v: expected both a and b, got: %v", t.Name(), seen)
+	}
+}
+
+func TestMachineInterrupt(t *testing.T) {
+	// the environment cancels the buffer by receiving cancel.
+	p := algebra.Interrupt(buf, algebra.Prefix("cancel", algebra.Skip()))
+	in, out, cancel := make(chan algebra.Event), make(chan algebra.Event), make(chan algebra.Event)
+	r := csp.Go(context.Background(), &algebra.Machine{Process: p, Env: map[string]chan<- algebra.Event{"in": in, "out": out, "cancel": cancel}})
+	for _, ch := range []chan algebra.Event{in, out, in, cancel} {
+		<-ch
+	}
+	if err := r.Wait(); err != nil {
+		t.Fatalf("%v: unexpected error: %v", t.Name(), err)
+	}
+}
+
+func TestMachinePrioritise(t *testing.T) {
+	// the semaphore prefers v to p, whenever the environment is ready
+	// for both.
+	sem := algebra.Prioritise(algebra.ExtChoice(algebra.Prefix("p", algebra.Skip()), algebra.Prefix("v", algebra.Skip())),
+		[]algebra.Event{"v"}, []algebra.Event{"p"})
+	for i := 0; i < 20; i++ {
+		chP, chV := make(chan algebra.Event, 1), make(chan algebra.Event, 1)
+		if err := (&algebra.Machine{Process: sem, Env: map[string]chan<- algebra.Event{"p": chP, "v": chV}}).Run(context.Background()); err != nil {
+			t.Fatalf("%v: unexpected error: %v", t.Name(), err)
+		}
+		if len(chP) != 0 || len(chV) != 1 {
+			t.Fatalf("%v: expected v, got: %d p, %d v", t.Name(), len(chP), len(chV))
+		}
+	}
+}
+
+func TestMachineCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	in, out := make(chan algebra.Event), make(chan algebra.Event)
+	r := csp.Go(ctx, &algebra.Machine{Process: buf, Env: map[string]chan<- algebra.Event{"in": in, "out": out}})
+	for i := 0; i < 3; i++ {
+		if got := <-in; got != "in" {
+			t.Fatalf("%v: expected: %v, got: %v", t.Name(), "in", got)
+		}
+		if got := <-out; got != "out" {
+			t.Fatalf("%v: expected: %v, got: %v", t.Name(), "out", got)
+		}
+	}
+	cancel()
+	if err := r.Wait(); !errors.Is(err, context.Canceled) {
+		t.Fatalf("%v: expected: %v, got: %v", t.Name(), context.Canceled, err)
+	}
+}
+
+func TestMachineErrors(t *testing.T) {
+	tests := []struct {
+		p    algebra.Process
+		want string
+	}{
+		{algebra.Stop(), "algebra: deadlock"},
+		// the environment refuses c.
+		{algebra.Prefix("c", algebra.Skip()), "algebra: deadlock"},
+		{algebra.Prefix("a", algebra.Var("X")), "algebra: unbound variable X"},
+		// an unguarded recursion diverges until ctx is done.
+		{algebra.Rec("X", algebra.Var("X")), "context deadline exceeded"},
+	}
+	for _, tt := range tests {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		ch := make(chan algebra.Event, 1)
+		err := (&algebra.Machine{Process: tt.p, Env: map[string]chan<- algebra.Event{"a": ch}}).Run(ctx)
+		cancel()
+		if err == nil || err.Error() != tt.want {
+			t.Fatalf("%v: %v: expected: %v, got: %v", t.Name(), tt.p, tt.want, err)
+		}
+	}
+}
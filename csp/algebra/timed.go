@@ -0,0 +1,149 @@
+package algebra
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/changkun/gobase/csp/lts"
+)
+
+// Tock is the event of the passing of a unit of time, as tock of FDR's
+// tock-CSP. Time passes for all processes of a term at once: processes
+// composed in parallel, or offered by a choice, engage in Tock together,
+// and a process without a Tock transition, such as a → P, lets time
+// pass without changing. Tock resolves no choice, and is neither hidden
+// nor renamed.
+const Tock = "tock"
+
+type (
+	wait    struct{ d int }
+	timeout struct {
+		p Process
+		d int
+		q Process
+	}
+)
+
+// Wait returns WAIT d, the process which terminates once d units of
+// time passed.
+func Wait(d int) Process {
+	if d < 0 {
+		panic(fmt.Sprintf("algebra: wait of %d", d))
+	}
+	return wait{d}
+}
+
+// Timeout returns p [d> q, the process which behaves as p if p engages
+// in an event or terminates within d units of time, and otherwise as q.
+// Internal transitions of p do not resolve the timeout. For d = 0, it
+// behaves as p or q, as p ▷ q of CSPm.
+func Timeout(p Process, d int, q Process) Process {
+	if d < 0 {
+		panic(fmt.Sprintf("algebra: timeout of %d", d))
+	}
+	return timeout{p, d, q}
+}
+
+func (p wait) String() string { return "WAIT " + strconv.Itoa(p.d) }
+func (p timeout) String() string {
+	return "(" + p.p.String() + " [" + strconv.Itoa(p.d) + "> " + p.q.String() + ")"
+}
+
+func (p wait) steps() []step {
+	if p.d == 0 {
+		return []step{{lts.Tick, omega{}, 1}}
+	}
+	return []step{{Tock, wait{p.d - 1}, 1}}
+}
+
+func (p timeout) steps() []step {
+	var steps []step
+	var next []Process
+	for _, s := range p.p.steps() {
+		switch s.event {
+		case lts.Tau:
+			s.next = timeout{s.next, p.d, p.q}
+		case Tock:
+			next = append(next, s.next)
+			continue
+		}
+		steps = append(steps, s)
+	}
+	if p.d == 0 {
+		return append(steps, step{lts.Tau, p.q, 1})
+	}
+	if next == nil {
+		next = []Process{p.p}
+	}
+	for _, n := range next {
+		steps = append(steps, step{Tock, timeout{n, p.d - 1, p.q}, 1})
+	}
+	return steps
+}
+
+func (p wait) subst(string, Process) Process { return p }
+func (p timeout) subst(name string, r Process) Process {
+	return timeout{p.p.subst(name, r), p.d, p.q.subst(name, r)}
+}
+
+// tocks returns the Tock transitions of the composition of p and q, of
+// the steps ps and qs, in which time passes for both: by Tock
+// transitions of both, or of one of them while the other one does not
+// change. compose returns the composition of the next processes.
+func tocks(p Process, ps []step, q Process, qs []step, compose func(p, q Process) Process) []step {
+	pn, qn := next(ps, Tock), next(qs, Tock)
+	if pn == nil && qn == nil {
+		return nil
+	}
+	if pn == nil {
+		pn = []Process{p}
+	}
+	if qn == nil {
+		qn = []Process{q}
+	}
+	var steps []step
+	for _, p := range pn {
+		for _, q := range qn {
+			steps = append(steps, step{Tock, compose(p, q), 1})
+		}
+	}
+	return steps
+}
+
+// next returns the processes the steps of the event e lead to.
+func next(steps []step, e string) []Process {
+	var next []Process
+	for _, s := range steps {
+		if s.event == e {
+			next = append(next, s.next)
+		}
+	}
+	return next
+}
+
+// TimedEvent is an event of a timed trace, at the time it happened.
+type TimedEvent struct {
+	Time  int // units of time passed before the event
+	Event Event
+}
+
+// String formats e as of Timed CSP, such as (2, a).
+func (e TimedEvent) String() string {
+	return fmt.Sprintf("(%d, %s)", e.Time, e.Event)
+}
+
+// Timed returns the timed trace of a trace of a process, such as the
+// Trace of an lts.Counterexample, whose Tock events are the passing of
+// time: the timed trace of ⟨a, tock, tock, b⟩ is ⟨(0, a), (2, b)⟩.
+func Timed(trace []string) []TimedEvent {
+	var timed []TimedEvent
+	now := 0
+	for _, e := range trace {
+		if e == Tock {
+			now++
+			continue
+		}
+		timed = append(timed, TimedEvent{now, Event(e)})
+	}
+	return timed
+}
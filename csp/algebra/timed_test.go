@@ -0,0 +1,93 @@
+package algebra_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/changkun/gobase/csp/algebra"
+)
+
+func TestTimedLTS(t *testing.T) {
+	tests := []struct {
+		p    algebra.Process
+		s    string
+		want string
+	}{
+		{algebra.Wait(2), "WAIT 2", "0 -tock-> 1, 1 -tock-> 2, 2 -✓-> 3"},
+		// a resolves the timeout within a unit of time, which
+		// passes without changing a → SKIP.
+		{algebra.Timeout(algebra.Prefix("a", algebra.Skip()), 1, b), "(a → SKIP [1> b → SKIP)",
+			"0 -a-> 1, 0 -tock-> 2, 1 -✓-> 3, 2 -a-> 1, 2 -τ-> 4, 4 -b-> 1"},
+		// time passes for both processes at once, and does not resolve
+		// the choice.
+		{algebra.ExtChoice(algebra.Wait(1), a), "(WAIT 1 □ a → STOP)", "0 -a-> 1, 0 -tock-> 2, 2 -✓-> 3, 2 -a-> 1"},
+		{algebra.Interleave(algebra.Wait(1), algebra.Wait(1)), "(WAIT 1 ||| WAIT 1)",
+			"0 -tock-> 1, 1 -τ-> 2, 1 -τ-> 3, 2 -τ-> 4, 3 -τ-> 4, 4 -✓-> 5"},
+		{algebra.Hide(algebra.Wait(1), []algebra.Event{algebra.Tock}), "(WAIT 1 \\ {tock})", "0 -tock-> 1, 1 -✓-> 2"},
+	}
+	for _, tt := range tests {
+		if got := tt.p.String(); got != tt.s {
+			t.Fatalf("%v: expected: %v, got: %v", t.Name(), tt.s, got)
+		}
+		l, err := algebra.LTS(tt.p)
+		if err != nil {
+			t.Fatalf("%v: %v: unexpected error: %v", t.Name(), tt.p, err)
+		}
+		if got := transitions(l); got != tt.want {
+			t.Fatalf("%v: %v: expected: %v, got: %v", t.Name(), tt.p, tt.want, got)
+		}
+	}
+}
+
+func TestTimed(t *testing.T) {
+	got := fmt.Sprint(algebra.Timed([]string{"a", algebra.Tock, algebra.Tock, "b", "c", algebra.Tock}))
+	if want := "[(0, a) (2, b) (2, c)]"; got != want {
+		t.Fatalf("%v: expected: %v, got: %v", t.Name(), want, got)
+	}
+}
+
+// runTimed runs p with an environment always ready to receive the
+// events of the channels, and returns its timed trace.
+func runTimed(t *testing.T, p algebra.Process, unit time.Duration, channels ...string) string {
+	env := map[string]chan<- algebra.Event{}
+	for _, name := range channels {
+		env[name] = make(chan algebra.Event, 10)
+	}
+	var trace []string
+	m := &algebra.Machine{Process: p, Env: env, Unit: unit, Trace: func(e algebra.TimedEvent) {
+		trace = append(trace, e.String())
+	}}
+	if err := m.Run(context.Background()); err != nil {
+		t.Fatalf("%v: %v: unexpected error: %v", t.Name(), p, err)
+	}
+	return strings.Join(trace, " ")
+}
+
+func TestMachineTimed(t *testing.T) {
+	beats := algebra.Prefix("beat", algebra.Seq(algebra.Wait(2), algebra.Prefix("beat", algebra.Seq(algebra.Wait(2), algebra.Prefix("beat", algebra.Skip())))))
+	// the acknowledgement is refused, hence the retry times out.
+	retry := algebra.Timeout(algebra.Prefix("ack", algebra.Skip()), 3, algebra.Prefix("retry", algebra.Skip()))
+	tests := []struct {
+		p        algebra.Process
+		channels []string
+		want     string
+	}{
+		{beats, []string{"beat"}, "(0, beat) (2, beat) (4, beat)"},
+		{retry, []string{"retry"}, "(3, retry)"},
+		{retry, []string{"ack", "retry"}, "(0, ack)"},
+	}
+	for _, unit := range []time.Duration{0, 5 * time.Millisecond} {
+		for _, tt := range tests {
+			start := time.Now()
+			if got := runTimed(t, tt.p, unit, tt.channels...); got != tt.want {
+				t.Fatalf("%v: %v: expected: %v, got: %v", t.Name(), tt.p, tt.want, got)
+			}
+			if unit > 0 && tt.want != "(0, ack)" && time.Since(start) < 3*unit {
+				t.Fatalf("%v: %v: expected at least %v, took: %v", t.Name(), tt.p, 3*unit, time.Since(start))
+			}
+		}
+	}
+}
@@ -0,0 +1,429 @@
+// Package alt implements the alternative command of CSP with a guard
+// set whose size is only known at runtime:
+//
+//   <alternative cmd>   ::= [<guarded cmd> { □ <guarded cmd> }]
+//   <guarded cmd>       ::= <guard> → <cmd list>
+//   <guard>             ::= <guard list> | <guard list>;<input cmd> | <input cmd>
+//
+// Go's select statement can express an alternative command only if the
+// number of guards is known at compile time. An Alt instead accepts an
+// arbitrary slice of guards, each an input or output command guarded
+// by an optional boolean condition, and selects among them using
+// reflect.Select:
+//
+//   a := alt.New(
+//       alt.Recv(producer, func(v int) { ... }).When(func() bool { return in < out+10 }),
+//       alt.Send(consumer, func() int { ... }, func() { ... }).When(func() bool { return out < in }),
+//   )
+//   a.Select()
+//
+// As in the paper, a guard fails if its boolean condition is false or if
+// the process named in its input command has terminated, i.e. its
+// channel is closed. An alternative command fails if all its guards
+// fail.
+//
+// Select chooses fairly among ready guards of the highest priority, as
+// set by Priority, whereas PriSelect always prefers the earliest ready
+// guard of the highest priority. Besides input and output guards,
+// After and Skip guards let an alternative command time out or fall
+// through if no communication is possible. Repeat executes an
+// alternative command as a repetitive command.
+package alt
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+)
+
+// Guard is a guarded command of an alternative command: an input or
+// output command guarded by an optional boolean condition, followed by
+// the command to execute after the communication.
+type Guard struct {
+	cond func() bool
+	dir  reflect.SelectDir
+	ch   reflect.Value
+	send func() reflect.Value
+	body func(v reflect.Value)
+
+	// after is the timeout of a timeout guard.
+	after time.Duration
+	// ready marks a guard without input command, which is always
+	// ready.
+	ready bool
+
+	// closed records that the channel of the guard is closed, hence
+	// the guard fails from now on.
+	closed bool
+
+	// priority of the guard, the higher the more preferred.
+	priority int
+}
+
+// Recv returns an input guard ch?v -> body(v).
+func Recv[T any](ch <-chan T, body func(v T)) *Guard {
+	return &Guard{
+		dir: reflect.SelectRecv,
+		ch:  reflect.ValueOf(ch),
+		body: func(v reflect.Value) {
+			if body != nil {
+				body(v.Interface().(T))
+			}
+		},
+	}
+}
+
+// RecvAny is the untyped variant of Recv for channels whose element
+// type is only known at runtime: ch must be a channel that can be
+// received from, body receives the value as an interface{}.
+func RecvAny(ch interface{}, body func(v interface{})) *Guard {
+	c := reflect.ValueOf(ch)
+	if c.Kind() != reflect.Chan || c.Type().ChanDir()&reflect.RecvDir == 0 {
+		panic(fmt.Sprintf("alt: %T is not a receive channel", ch))
+	}
+	return &Guard{
+		dir: reflect.SelectRecv,
+		ch:  c,
+		body: func(v reflect.Value) {
+			if body != nil {
+				body(v.Interface())
+			}
+		},
+	}
+}
+
+// Send returns an output guard ch!value() -> body(). The value is
+// evaluated each time the guard is considered for selection. As a
+// receiver never closes a channel in Go, an output guard only fails
+// because of its boolean condition.
+func Send[T any](ch chan<- T, value func() T, body func()) *Guard {
+	return &Guard{
+		dir:  reflect.SelectSend,
+		ch:   reflect.ValueOf(ch),
+		send: func() reflect.Value { return reflect.ValueOf(value()) },
+		body: func(reflect.Value) {
+			if body != nil {
+				body()
+			}
+		},
+	}
+}
+
+// After returns a timeout guard, which becomes ready d after the
+// alternative command started waiting, and then executes body. It
+// allows an alternative command to give up waiting for communication:
+//
+//   [X?v -> ... □ after(d) -> ...]
+func After(d time.Duration, body func()) *Guard {
+	return &Guard{
+		dir:   reflect.SelectRecv,
+		after: d,
+		body: func(reflect.Value) {
+			if body != nil {
+				body()
+			}
+		},
+	}
+}
+
+// Ready returns a guard without input or output command, which is
+// ready whenever it is enabled, and then executes body. Together with
+// When it is the purely boolean guarded command of the paper:
+//
+//   [i <= 124 -> i := i+1 □ i = 125 -> ...]
+func Ready(body func()) *Guard {
+	return &Guard{
+		dir:   reflect.SelectRecv,
+		ch:    reflect.ValueOf(always),
+		ready: true,
+		body: func(reflect.Value) {
+			if body != nil {
+				body()
+			}
+		},
+	}
+}
+
+// always is the always ready channel of Ready guards.
+var always = func() chan struct{} {
+	ch := make(chan struct{})
+	close(ch)
+	return ch
+}()
+
+// Skip returns a guard which is selected only if no other enabled guard
+// is ready, and then executes body. It turns an alternative command
+// into a poll that never waits. Of several Skip guards only the first
+// enabled one is considered. PriSelect selects a Skip guard as soon as
+// no guard preceding it is ready.
+func Skip(body func()) *Guard {
+	return &Guard{
+		dir: reflect.SelectDefault,
+		body: func(reflect.Value) {
+			if body != nil {
+				body()
+			}
+		},
+	}
+}
+
+// When adds the boolean condition cond to the guard, the guard is only
+// enabled if cond reports true at the time of selection. It returns g.
+func (g *Guard) When(cond func() bool) *Guard {
+	g.cond = cond
+	return g
+}
+
+// Priority sets the priority of the guard, 0 by default. Of several
+// ready guards, an alternative command selects one of the highest
+// priority, as prioritise of FDR, such as a semaphore which prefers V
+// to P:
+//
+//   alt.New(
+//       alt.Recv(v, func(struct{}) { n++ }).Priority(1),
+//       alt.Recv(p, func(struct{}) { n-- }).When(func() bool { return n > 0 }),
+//   )
+//
+// A guard of a lower priority is selected only if no guard of a higher
+// priority is ready once the alternative command polls them. It returns
+// g.
+func (g *Guard) Priority(p int) *Guard {
+	g.priority = p
+	return g
+}
+
+// enabled reports whether g may be selected.
+func (g *Guard) enabled() bool {
+	return !g.closed && (g.cond == nil || g.cond())
+}
+
+// Alt is an alternative command [g1 → c1 □ g2 → c2 □ ...].
+type Alt struct {
+	guards []*Guard
+}
+
+// New returns an alternative command of the given guards.
+func New(guards ...*Guard) *Alt {
+	return &Alt{guards: guards}
+}
+
+// Add appends a guard to the alternative command.
+func (a *Alt) Add(g *Guard) {
+	a.guards = append(a.guards, g)
+}
+
+// Len returns the number of guards of the alternative command.
+func (a *Alt) Len() int {
+	return len(a.guards)
+}
+
+// Select executes the alternative command: it waits until any enabled
+// guard is ready, executes it and returns its index. If several guards
+// are ready, one of them of the highest priority is chosen uniformly at
+// random. Select returns false if all guards fail, in which case
+// nothing is executed.
+func (a *Alt) Select() (int, bool) {
+	for {
+		cases, index := a.cases()
+		if len(cases) == 0 {
+			return -1, false
+		}
+		chosen, v, ok, polled := a.poll(cases, index)
+		if !polled {
+			chosen, v, ok = reflect.Select(cases)
+		}
+		if i, ok := a.exec(index[chosen], v, ok); ok {
+			return i, true
+		}
+	}
+}
+
+// SelectContext is like Select, but also fails if ctx is done before
+// any guard is ready, in which case nothing is executed. The caller
+// distinguishes both failures by ctx.Err().
+func (a *Alt) SelectContext(ctx context.Context) (int, bool) {
+	for {
+		cases, index := a.cases()
+		if len(cases) == 0 {
+			return -1, false
+		}
+		chosen, v, ok, polled := a.poll(cases, index)
+		if !polled {
+			cases = append(cases, reflect.SelectCase{
+				Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ctx.Done()),
+			})
+			chosen, v, ok = reflect.Select(cases)
+			if chosen == len(index) {
+				return -1, false
+			}
+		}
+		if i, ok := a.exec(index[chosen], v, ok); ok {
+			return i, true
+		}
+	}
+}
+
+// PriSelect is the prioritized variant of Select, as occam's PRI ALT:
+// if several enabled guards are ready, the one of the highest priority
+// added first is chosen. If no guard is ready, PriSelect waits for the
+// first one to become ready.
+func (a *Alt) PriSelect() (int, bool) {
+	for {
+		cases, index := a.cases()
+		if len(cases) == 0 {
+			return -1, false
+		}
+		order := make([]int, len(cases))
+		for c := range order {
+			order[c] = c
+		}
+		sort.SliceStable(order, func(i, j int) bool {
+			return a.guards[index[order[i]]].priority > a.guards[index[order[j]]].priority
+		})
+
+		// poll the guards in order of priority.
+		polled := false
+		for _, c := range order {
+			if cases[c].Dir == reflect.SelectDefault {
+				return a.exec(index[c], reflect.Value{}, false)
+			}
+			chosen, v, ok := reflect.Select([]reflect.SelectCase{
+				cases[c], {Dir: reflect.SelectDefault},
+			})
+			if chosen == 1 {
+				continue
+			}
+			polled = true
+			if i, ok := a.exec(index[c], v, ok); ok {
+				return i, true
+			}
+			break
+		}
+		if polled {
+			// a closed channel was found, reevaluate the guards.
+			continue
+		}
+
+		chosen, v, ok := reflect.Select(cases)
+		if i, ok := a.exec(index[chosen], v, ok); ok {
+			return i, true
+		}
+	}
+}
+
+// Repeat executes the repetitive command *[a]: it executes the
+// alternative command a until all of its guards fail, and returns the
+// number of iterations. Following the paper's termination rule, a loop
+// whose guards are all input guards of otherwise true conditions
+// terminates once all the processes it reads from have terminated,
+// i.e. all their channels are closed:
+//
+//   *[c:character; west?c -> east!c]
+//
+// is written as
+//
+//   alt.Repeat(alt.New(alt.Recv(west, func(c rune) { east <- c })))
+func Repeat(a *Alt) int {
+	n := 0
+	for {
+		if _, ok := a.Select(); !ok {
+			return n
+		}
+		n++
+	}
+}
+
+// poll selects a ready case of the highest priority, if the guards of
+// the cases are of several priorities: it polls the cases of every
+// priority in turn, highest first. It reports whether it found a ready
+// case, or the Skip guard among the cases once none is.
+func (a *Alt) poll(cases []reflect.SelectCase, index []int) (chosen int, v reflect.Value, ok, polled bool) {
+	var priorities []int
+	for _, i := range index {
+		if p := a.guards[i].priority; !contains(priorities, p) {
+			priorities = append(priorities, p)
+		}
+	}
+	if len(priorities) < 2 {
+		return 0, reflect.Value{}, false, false
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(priorities)))
+	skip := -1
+	for _, p := range priorities {
+		var sub []reflect.SelectCase
+		var subIndex []int
+		for c, i := range index {
+			switch {
+			case cases[c].Dir == reflect.SelectDefault:
+				skip = c
+			case a.guards[i].priority == p:
+				sub = append(sub, cases[c])
+				subIndex = append(subIndex, c)
+			}
+		}
+		if len(sub) == 0 {
+			continue
+		}
+		chosen, v, ok := reflect.Select(append(sub, reflect.SelectCase{Dir: reflect.SelectDefault}))
+		if chosen < len(sub) {
+			return subIndex[chosen], v, ok, true
+		}
+	}
+	if skip >= 0 {
+		return skip, reflect.Value{}, false, true
+	}
+	return 0, reflect.Value{}, false, false
+}
+
+func contains(s []int, x int) bool {
+	for _, y := range s {
+		if y == x {
+			return true
+		}
+	}
+	return false
+}
+
+// cases returns the select cases of all enabled guards, and the index
+// of the guard of each case.
+func (a *Alt) cases() ([]reflect.SelectCase, []int) {
+	cases := make([]reflect.SelectCase, 0, len(a.guards))
+	index := make([]int, 0, len(a.guards))
+	skip := false
+	for i, g := range a.guards {
+		if !g.enabled() {
+			continue
+		}
+		c := reflect.SelectCase{Dir: g.dir, Chan: g.ch}
+		switch {
+		case g.dir == reflect.SelectSend:
+			c.Send = g.send()
+		case g.dir == reflect.SelectDefault:
+			if skip {
+				continue
+			}
+			skip = true
+		case g.after > 0 || !g.ch.IsValid():
+			c.Chan = reflect.ValueOf(time.After(g.after))
+		}
+		cases = append(cases, c)
+		index = append(index, i)
+	}
+	return cases, index
+}
+
+// exec executes the i-th guard after its communication took place,
+// receiving v if it is an input guard. It reports false if the channel
+// of an input guard turned out to be closed, in which case the guard
+// fails from now on.
+func (a *Alt) exec(i int, v reflect.Value, ok bool) (int, bool) {
+	g := a.guards[i]
+	if g.dir == reflect.SelectRecv && !ok && !g.ready {
+		g.closed = true
+		return i, false
+	}
+	g.body(v)
+	return i, true
+}
@@ -0,0 +1,348 @@
+package alt_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/changkun/gobase/csp/alt"
+)
+
+func TestAltDynamicGuards(t *testing.T) {
+	n := 10
+	chans := make([]chan int, n)
+	received := make([]int, n)
+	a := alt.New()
+	for i := range chans {
+		i := i
+		chans[i] = make(chan int)
+		a.Add(alt.Recv(chans[i], func(v int) { received[i] += v }))
+		go func() {
+			for j := 0; j < 10; j++ {
+				chans[i] <- i
+			}
+			close(chans[i])
+		}()
+	}
+	if a.Len() != n {
+		t.Fatalf("%v: expected %v guards, got %v", t.Name(), n, a.Len())
+	}
+
+	count := 0
+	for {
+		if _, ok := a.Select(); !ok {
+			break
+		}
+		count++
+	}
+	if count != 10*n {
+		t.Fatalf("%v: expected %v selections, got %v", t.Name(), 10*n, count)
+	}
+	for i, v := range received {
+		if v != 10*i {
+			t.Fatalf("%v: expected %v from guard %v, got %v", t.Name(), 10*i, i, v)
+		}
+	}
+}
+
+func TestAltCondition(t *testing.T) {
+	a, b := make(chan int, 1), make(chan int, 1)
+	a <- 1
+	b <- 2
+
+	enabled := false
+	got := 0
+	x := alt.New(
+		alt.Recv(a, func(v int) { got = v }).When(func() bool { return enabled }),
+		alt.Recv(b, func(v int) { got = v }),
+	)
+	if i, ok := x.Select(); !ok || i != 1 || got != 2 {
+		t.Fatalf("%v: expected guard 1 with 2, got guard %v with %v", t.Name(), i, got)
+	}
+	enabled = true
+	if i, ok := x.Select(); !ok || i != 0 || got != 1 {
+		t.Fatalf("%v: expected guard 0 with 1, got guard %v with %v", t.Name(), i, got)
+	}
+
+	// all guards fail.
+	enabled = false
+	close(b)
+	if i, ok := x.Select(); ok {
+		t.Fatalf("%v: expected alternative to fail, got guard %v", t.Name(), i)
+	}
+}
+
+func TestAltFairness(t *testing.T) {
+	// both guards are always ready.
+	a, b := make(chan int, 1000), make(chan int, 1000)
+	for i := 0; i < 1000; i++ {
+		a <- 0
+		b <- 1
+	}
+
+	count := [2]int{}
+	x := alt.New(alt.Recv(a, nil), alt.Recv(b, nil))
+	for i := 0; i < 1000; i++ {
+		chosen, _ := x.Select()
+		count[chosen]++
+	}
+	if count[0] < 400 || count[1] < 400 {
+		t.Fatalf("%v: expected fair selection, got %v", t.Name(), count)
+	}
+}
+
+func TestAltPriSelect(t *testing.T) {
+	a, b := make(chan int, 10), make(chan int, 10)
+	for i := 0; i < 10; i++ {
+		a <- i
+		b <- i
+	}
+
+	x := alt.New(alt.Recv(a, nil), alt.Recv(b, nil))
+	for i := 0; i < 10; i++ {
+		if chosen, ok := x.PriSelect(); !ok || chosen != 0 {
+			t.Fatalf("%v: expected guard 0, got %v", t.Name(), chosen)
+		}
+	}
+	for i := 0; i < 10; i++ {
+		if chosen, ok := x.PriSelect(); !ok || chosen != 1 {
+			t.Fatalf("%v: expected guard 1, got %v", t.Name(), chosen)
+		}
+	}
+
+	// no guard is ready, the first one becoming ready is chosen.
+	go func() { b <- 0 }()
+	if chosen, ok := x.PriSelect(); !ok || chosen != 1 {
+		t.Fatalf("%v: expected guard 1, got %v", t.Name(), chosen)
+	}
+
+	close(a)
+	close(b)
+	if chosen, ok := x.PriSelect(); ok {
+		t.Fatalf("%v: expected alternative to fail, got guard %v", t.Name(), chosen)
+	}
+}
+
+func TestAltPriority(t *testing.T) {
+	// a semaphore prefers V to P, both of which are ready.
+	v, p := make(chan struct{}, 10), make(chan struct{}, 10)
+	for i := 0; i < 10; i++ {
+		v <- struct{}{}
+		p <- struct{}{}
+	}
+	n := 0
+	sem := alt.New(
+		alt.Recv(p, func(struct{}) { n-- }).When(func() bool { return n > 0 }),
+		alt.Recv(v, func(struct{}) { n++ }).Priority(1),
+	)
+	for i := 0; i < 10; i++ {
+		if chosen, ok := sem.Select(); !ok || chosen != 1 {
+			t.Fatalf("%v: expected guard 1, got %v", t.Name(), chosen)
+		}
+	}
+	for i := 0; i < 10; i++ {
+		if chosen, ok := sem.SelectContext(context.Background()); !ok || chosen != 0 {
+			t.Fatalf("%v: expected guard 0, got %v", t.Name(), chosen)
+		}
+	}
+	if n != 0 {
+		t.Fatalf("%v: expected: %v, got: %v", t.Name(), 0, n)
+	}
+
+	// the priority precedes the order of the guards.
+	v <- struct{}{}
+	p <- struct{}{}
+	n = 1
+	if chosen, ok := sem.PriSelect(); !ok || chosen != 1 {
+		t.Fatalf("%v: expected guard 1, got %v", t.Name(), chosen)
+	}
+
+	// of lower priority, a guard ready is selected before Skip.
+	skipped := false
+	x := alt.New(alt.Recv(v, nil).Priority(1), alt.Recv(p, nil), alt.Skip(func() { skipped = true }))
+	if chosen, ok := x.Select(); !ok || chosen != 1 {
+		t.Fatalf("%v: expected guard 1, got %v", t.Name(), chosen)
+	}
+	if chosen, ok := x.Select(); !ok || chosen != 2 || !skipped {
+		t.Fatalf("%v: expected guard 2, got %v", t.Name(), chosen)
+	}
+}
+
+func TestAltSend(t *testing.T) {
+	in, out := make(chan int), make(chan int)
+	go func() {
+		for i := 0; i < 10; i++ {
+			in <- i
+		}
+		close(in)
+	}()
+
+	// a one-slot buffer: [full = false; in?v -> full := true □ full; out!v -> full := false]
+	v, full := 0, false
+	x := alt.New(
+		alt.Recv(in, func(x int) { v, full = x, true }).When(func() bool { return !full }),
+		alt.Send(out, func() int { return v }, func() { full = false }).When(func() bool { return full }),
+	)
+	go func() {
+		for {
+			if _, ok := x.Select(); !ok {
+				break
+			}
+		}
+		close(out)
+	}()
+
+	i := 0
+	for got := range out {
+		if got != i {
+			t.Fatalf("%v: expected %v, got %v", t.Name(), i, got)
+		}
+		i++
+	}
+	if i != 10 {
+		t.Fatalf("%v: expected 10 values, got %v", t.Name(), i)
+	}
+}
+
+func TestAltAfter(t *testing.T) {
+	ch := make(chan int)
+	timedout := false
+	x := alt.New(
+		alt.Recv(ch, nil),
+		alt.After(10*time.Millisecond, func() { timedout = true }),
+	)
+
+	start := time.Now()
+	if chosen, ok := x.Select(); !ok || chosen != 1 || !timedout {
+		t.Fatalf("%v: expected timeout guard, got %v", t.Name(), chosen)
+	}
+	if elapsed := time.Since(start); elapsed < 10*time.Millisecond {
+		t.Fatalf("%v: expected to wait at least 10ms, waited %v", t.Name(), elapsed)
+	}
+
+	go func() { ch <- 1 }()
+	if chosen, ok := x.PriSelect(); !ok || chosen != 0 {
+		t.Fatalf("%v: expected input guard, got %v", t.Name(), chosen)
+	}
+	timedout = false
+	if chosen, ok := x.PriSelect(); !ok || chosen != 1 || !timedout {
+		t.Fatalf("%v: expected timeout guard, got %v", t.Name(), chosen)
+	}
+}
+
+func TestAltSkip(t *testing.T) {
+	ch := make(chan int, 1)
+	skipped := 0
+	x := alt.New(
+		alt.Recv(ch, nil),
+		alt.Skip(func() { skipped++ }),
+		alt.Skip(func() { t.Fatalf("%v: unexpected second skip guard", t.Name()) }),
+	)
+
+	if chosen, ok := x.Select(); !ok || chosen != 1 || skipped != 1 {
+		t.Fatalf("%v: expected skip guard, got %v", t.Name(), chosen)
+	}
+	ch <- 1
+	if chosen, ok := x.Select(); !ok || chosen != 0 {
+		t.Fatalf("%v: expected input guard, got %v", t.Name(), chosen)
+	}
+	if chosen, ok := x.PriSelect(); !ok || chosen != 1 || skipped != 2 {
+		t.Fatalf("%v: expected skip guard, got %v", t.Name(), chosen)
+	}
+
+	// the skip guard precedes a ready guard.
+	ch <- 1
+	y := alt.New(alt.Skip(nil), alt.Recv(ch, nil))
+	if chosen, ok := y.PriSelect(); !ok || chosen != 0 {
+		t.Fatalf("%v: expected skip guard, got %v", t.Name(), chosen)
+	}
+}
+
+func TestRepeat(t *testing.T) {
+	a, b := make(chan int), make(chan int)
+	go func() {
+		for i := 0; i < 10; i++ {
+			a <- i
+		}
+		close(a)
+	}()
+	go func() {
+		for i := 0; i < 5; i++ {
+			b <- i
+		}
+		close(b)
+	}()
+
+	sum := 0
+	n := alt.Repeat(alt.New(
+		alt.Recv(a, func(v int) { sum += v }),
+		alt.Recv(b, func(v int) { sum += v }),
+	))
+	if n != 15 {
+		t.Fatalf("%v: expected: %v iterations, got: %v", t.Name(), 15, n)
+	}
+	if want := 45 + 10; sum != want {
+		t.Fatalf("%v: expected: %v, got: %v", t.Name(), want, sum)
+	}
+}
+
+func TestRepeatCondition(t *testing.T) {
+	out := make(chan int, 10)
+	i := 0
+	n := alt.Repeat(alt.New(
+		alt.Send(out, func() int { return i }, func() { i++ }).When(func() bool { return i < 10 }),
+	))
+	if n != 10 || len(out) != 10 {
+		t.Fatalf("%v: expected: %v iterations, got: %v", t.Name(), 10, n)
+	}
+}
+
+func TestAltReady(t *testing.T) {
+	ch := make(chan int)
+	i := 0
+	x := alt.New(
+		alt.Recv(ch, nil),
+		alt.Ready(func() { i++ }).When(func() bool { return i < 3 }),
+	)
+	for k := 0; k < 3; k++ {
+		if chosen, ok := x.Select(); !ok || chosen != 1 {
+			t.Fatalf("%v: expected ready guard, got %v", t.Name(), chosen)
+		}
+	}
+	close(ch)
+	if chosen, ok := x.Select(); ok {
+		t.Fatalf("%v: expected all guards to fail, got %v", t.Name(), chosen)
+	}
+}
+
+func TestAltRecvAny(t *testing.T) {
+	ch := make(chan string, 1)
+	ch <- "csp"
+	var got interface{}
+	if chosen, ok := alt.New(alt.RecvAny(ch, func(v interface{}) { got = v })).Select(); !ok || chosen != 0 {
+		t.Fatalf("%v: expected input guard, got %v", t.Name(), chosen)
+	}
+	if got != "csp" {
+		t.Fatalf("%v: expected: %v, got: %v", t.Name(), "csp", got)
+	}
+}
+
+func TestAltSelectContext(t *testing.T) {
+	ch := make(chan int, 1)
+	x := alt.New(alt.Recv(ch, nil))
+	ch <- 1
+	if chosen, ok := x.SelectContext(context.Background()); !ok || chosen != 0 {
+		t.Fatalf("%v: expected input guard, got %v", t.Name(), chosen)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if chosen, ok := x.SelectContext(ctx); ok || ctx.Err() == nil {
+		t.Fatalf("%v: expected cancellation, got %v", t.Name(), chosen)
+	}
+
+	close(ch)
+	if chosen, ok := x.SelectContext(context.Background()); ok {
+		t.Fatalf("%v: expected all guards to fail, got %v", t.Name(), chosen)
+	}
+}
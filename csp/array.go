@@ -0,0 +1,44 @@
+package csp
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Array returns the array of processes
+//
+//   [X(i:1..n)::proc(i)]
+//
+// as a parallel command, together with the n+1 channels it created to
+// connect neighbouring processes: X(i) communicates with X(i-1) on
+// chans[i-1] and with X(i+1) on chans[i], hence chans[0] and chans[n]
+// connect the array with its environment. The channels are
+// unbuffered and may carry messages in both directions, as in the
+// factorial chain of Section 4.2.
+//
+// If proc(i) is labelled X by Named, the process is labelled X(i).
+func Array[T any](n int, proc func(i int, left, right chan T) Process) (Process, []chan T) {
+	if n < 0 {
+		panic("csp: negative array size")
+	}
+	chans := make([]chan T, n+1)
+	for i := range chans {
+		chans[i] = make(chan T)
+	}
+	procs := make([]Process, n)
+	for i := 1; i <= n; i++ {
+		p := proc(i, chans[i-1], chans[i])
+		if l, ok := p.(named); ok {
+			p = Named(fmt.Sprintf("%s(%d)", l.label, i), l.Process)
+		}
+		procs[i-1] = p
+	}
+	return array{par{procs}, reflect.TypeOf((*T)(nil)).Elem()}, chans
+}
+
+// array is the parallel command of Array, whose neighbouring processes
+// communicate on channels of elem.
+type array struct {
+	par
+	elem reflect.Type
+}
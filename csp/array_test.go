@@ -0,0 +1,71 @@
+package csp_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/changkun/gobase/csp"
+)
+
+func TestArrayFactorial(t *testing.T) {
+	limit := 10
+
+	// fac(i:1..limit)::
+	// *[n:integer;fac(i-1)?n ->
+	//   [n=0 -> fac(i-1)!1
+	//   □ n>0 -> fac(i+1)!n-1;
+	//     r:integer;fac(i+1)?r; fac(i-1)!(n*r)
+	// ]]
+	fac, chans := csp.Array(limit, func(i int, left, right chan int) csp.Process {
+		return csp.Named("fac", csp.ProcessFunc(func(ctx context.Context) error {
+			defer close(right)
+			for n := range left {
+				if n == 0 {
+					left <- 1
+					continue
+				}
+				right <- n - 1
+				left <- n * <-right
+			}
+			return nil
+		}))
+	})
+	if len(chans) != limit+1 {
+		t.Fatalf("%v: expected: %v channels, got: %v", t.Name(), limit+1, len(chans))
+	}
+
+	r := csp.Go(context.Background(), fac)
+	want := []int{1, 1, 2, 6, 24, 120, 720, 5040, 40320, 362880}
+	got := []int{}
+	for n := 0; n < limit; n++ {
+		chans[0] <- n
+		got = append(got, <-chans[0])
+	}
+	close(chans[0])
+	if err := r.Wait(); err != nil {
+		t.Fatalf("%v: unexpected error: %v", t.Name(), err)
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Fatalf("%v: expected: %v, got: %v", t.Name(), want, got)
+	}
+}
+
+func TestArrayLabel(t *testing.T) {
+	errFailed := errors.New("failed")
+	p, _ := csp.Array(3, func(i int, left, right chan struct{}) csp.Process {
+		return csp.Named("X", csp.ProcessFunc(func(ctx context.Context) error {
+			if i == 2 {
+				return errFailed
+			}
+			return nil
+		}))
+	})
+
+	err := csp.Go(context.Background(), p).Wait()
+	var perr *csp.ProcessError
+	if !errors.As(err, &perr) || perr.Label != "X(2)" {
+		t.Fatalf("%v: expected error of X(2), got: %v", t.Name(), err)
+	}
+}
@@ -0,0 +1,14 @@
+package csp
+
+import "github.com/changkun/gobase/csp/alt"
+
+// Copy implements the COPY process of Section 3.1 for any element type:
+//
+//   X :: *[c:T; west?c -> east!c]
+//
+// It copies elements from in to out until in is closed, then closes
+// out.
+func Copy[T any](in <-chan T, out chan<- T) {
+	alt.Repeat(alt.New(alt.Recv(in, func(c T) { out <- c })))
+	close(out)
+}
@@ -0,0 +1,48 @@
+package csp_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/changkun/gobase/csp"
+)
+
+func TestCopy(t *testing.T) {
+	cards := [][]rune{[]rune("Hello,CSP"), []rune("Hello,CSP2")}
+	west, east := make(chan []rune), make(chan []rune)
+	go csp.Copy(west, east)
+	go func() {
+		for _, c := range cards {
+			west <- c
+		}
+		close(west)
+	}()
+	received := [][]rune{}
+	for c := range east {
+		received = append(received, c)
+	}
+	if !reflect.DeepEqual(cards, received) {
+		t.Fatalf("%v: expected: %v, got: %v", t.Name(), cards, received)
+	}
+
+	type portion struct {
+		id   int
+		line string
+	}
+	portions := []portion{{1, "Hello"}, {2, "CSP"}}
+	in, out := make(chan portion), make(chan portion)
+	go csp.Copy(in, out)
+	go func() {
+		for _, p := range portions {
+			in <- p
+		}
+		close(in)
+	}()
+	got := []portion{}
+	for p := range out {
+		got = append(got, p)
+	}
+	if !reflect.DeepEqual(portions, got) {
+		t.Fatalf("%v: expected: %v, got: %v", t.Name(), portions, got)
+	}
+}
@@ -84,11 +84,17 @@
 package csp
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"math/rand"
+	"reflect"
 	"runtime"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/changkun/gobase/csp/alt"
 )
 
 // S31_COPY implements Section 3.1 COPY problem:
@@ -98,11 +104,10 @@ import (
 // Solution:
 //
 //   X :: *[c:character; west?c -> east!c]
-func S31_COPY(west, east chan rune) {
-	for c := range west {
-		east <- c
-	}
-	close(east)
+//
+// S31_COPY is the rune instance of Copy.
+func S31_COPY(west <-chan rune, east chan<- rune) {
+	Copy(west, east)
 }
 
 // S32_SQUASH implements Section 3.2 SQUASH problem:
@@ -118,29 +123,24 @@ func S31_COPY(west, east chan rune) {
 //            [ c != asterisk -> east!asterisk; east!c
 //             □ c = asterisk -> east!upward arrow
 //     ] ]    ]
-func S32_SQUASH(west, east chan rune) {
-	for {
+func S32_SQUASH(west <-chan rune, east chan<- rune) {
+	alt.Repeat(alt.New(alt.Recv(west, func(c rune) {
+		if c != '*' {
+			east <- c
+			return
+		}
 		c, ok := <-west
 		if !ok {
-			break
+			return
 		}
-		if string(c) != "*" {
+		if c != '*' {
+			east <- '*'
 			east <- c
 		}
-		if string(c) == "*" {
-			c, ok = <-west
-			if !ok {
-				break
-			}
-			if string(c) != "*" {
-				east <- '*'
-				east <- c
-			}
-			if string(c) == "*" {
-				east <- '↑'
-			}
+		if c == '*' {
+			east <- '↑'
 		}
-	}
+	})))
 	close(east)
 }
 
@@ -157,30 +157,25 @@ func S32_SQUASH(west, east chan rune) {
 //             □ c = asterisk -> east!upward arrow
 //            ] □ east!asterisk
 //     ]   ]
-func S32_SQUASH_EX(west, east chan rune) {
-	for {
+func S32_SQUASH_EX(west <-chan rune, east chan<- rune) {
+	alt.Repeat(alt.New(alt.Recv(west, func(c rune) {
+		if c != '*' {
+			east <- c
+			return
+		}
 		c, ok := <-west
 		if !ok {
-			break
+			east <- '*'
+			return
 		}
 		if c != '*' {
+			east <- '*'
 			east <- c
 		}
 		if c == '*' {
-			c, ok = <-west
-			if !ok {
-				east <- '*'
-				break
-			}
-			if c != '*' {
-				east <- '*'
-				east <- c
-			}
-			if c == '*' {
-				east <- '↑'
-			}
+			east <- '↑'
 		}
-	}
+	})))
 	close(east)
 }
 
@@ -196,30 +191,16 @@ func S32_SQUASH_EX(west, east chan rune) {
 //       *[i <= 80 -> X!cardimage(i); i := i+1 ]
 //       X!space
 //   ]
-func S33_DISASSEMBLE(cardfile chan []rune, X chan rune) {
-	cardimage := make([]rune, 0, 80)
-	for tmp := range cardfile {
-		if len(tmp) > 80 {
-			cardimage = append(cardimage, tmp[:80]...)
-		} else {
-			cardimage = append(cardimage, tmp[:len(tmp)]...)
-		}
-		for i := 0; i < len(cardimage); i++ {
+//
+// Characters of a card beyond the 80th are ignored.
+func S33_DISASSEMBLE(cardfile <-chan []rune, X chan<- rune) {
+	alt.Repeat(alt.New(alt.Recv(cardfile, func(cardimage []rune) {
+		for i := 0; i < len(cardimage) && i < 80; i++ {
 			X <- cardimage[i]
 		}
 		X <- ' '
-		cardimage = cardimage[:0]
-	}
+	})))
 	close(X)
-
-	// Alternative solution (But wrong):
-	// for cardimage := range cardfile {
-	// 	for _, c := range cardimage {
-	// 		X <- c
-	// 	}
-	// 	X <- ' '
-	// }
-	// close(X)
 }
 
 // S34_ASSEMBLE implements Section 3.4 ASSEMBLE problem:
@@ -240,21 +221,18 @@ func S33_DISASSEMBLE(cardfile chan []rune, X chan rune) {
 //   □ i > 1 -> *[i <= 125 -> lineimage(i) := space; i := i+1];
 //     lineprinter!lineimage
 //   ]
-func S34_ASSEMBLE(X chan rune, lineprinter chan string) {
+func S34_ASSEMBLE(X <-chan rune, lineprinter chan<- string) {
 	lineimage := make([]rune, 125)
 
 	i := 0
-	for c := range X {
+	alt.Repeat(alt.New(alt.Recv(X, func(c rune) {
 		lineimage[i] = c
-		if i <= 124 {
-			i++
-		}
+		i++
 		if i == 125 {
-			lineimage[i-1] = c
 			lineprinter <- string(lineimage)
 			i = 0
 		}
-	}
+	})))
 	if i > 0 {
 		for i <= 124 {
 			lineimage[i] = ' '
@@ -264,7 +242,6 @@ func S34_ASSEMBLE(X chan rune, lineprinter chan string) {
 	}
 
 	close(lineprinter)
-	return
 }
 
 // S35_Reformat implements Section 3.5 Reformat problem:
@@ -276,11 +253,15 @@ func S34_ASSEMBLE(X chan rune, lineprinter chan string) {
 // Solution:
 //
 //   [west::DISASSEMBLE||X:COPY||east::ASSEMBLE]
-func S35_Reformat(cardfile chan []rune, lineprinter chan string) {
-	west, east := make(chan rune), make(chan rune)
-	go S33_DISASSEMBLE(cardfile, west)
-	go S31_COPY(west, east)
-	S34_ASSEMBLE(east, lineprinter)
+//
+// S35_Reformat panics with the error of the pipeline if a stage fails.
+func S35_Reformat(cardfile <-chan []rune, lineprinter chan<- string) {
+	err := Pipeline().Source(cardfile).
+		Stage(S33_DISASSEMBLE).Stage(S31_COPY).Stage(S34_ASSEMBLE).
+		Sink(lineprinter).Run(context.Background())
+	if err != nil {
+		panic(err)
+	}
 }
 
 // S36_ConwayProblem implements Section 3.6 Conway's Problem:
@@ -290,11 +271,13 @@ func S35_Reformat(cardfile chan []rune, lineprinter chan string) {
 // Solution:
 //
 //   [west::DISASSEMBLE||X::SQUASH||east::ASSEMBLE]
-func S36_ConwayProblem(cardfile chan []rune, lineprinter chan string) {
+func S36_ConwayProblem(cardfile <-chan []rune, lineprinter chan<- string) {
 	west, east := make(chan rune), make(chan rune)
-	go S33_DISASSEMBLE(cardfile, west)
-	go S32_SQUASH_EX(west, east)
-	S34_ASSEMBLE(east, lineprinter)
+	Par(
+		Named("west", task(func() { S33_DISASSEMBLE(cardfile, west) })),
+		Named("X", task(func() { S32_SQUASH_EX(west, east) })),
+		Named("east", task(func() { S34_ASSEMBLE(east, lineprinter) })),
+	).Run(context.Background())
 }
 
 type S41_In struct {
@@ -318,7 +301,7 @@ type S41_Out struct {
 //         X!(quot,rem)
 //         ]
 //   ||X::USER]
-func S41_DivisionWithRemainder(in chan S41_In, out chan S41_Out) {
+func S41_DivisionWithRemainder(in <-chan S41_In, out chan<- S41_Out) {
 	v := <-in
 	x, y := v.X, v.Y
 
@@ -330,6 +313,37 @@ func S41_DivisionWithRemainder(in chan S41_In, out chan S41_Out) {
 	out <- S41_Out{quot, rem}
 }
 
+// S41_DIV implements the Section 4.1 DIV process as a coroutine acting
+// as a subroutine. Unlike S41_DivisionWithRemainder which serves a single
+// call, S41_DIV repeatedly serves its user X until X terminates, i.e.
+// until X is closed, and closes out afterwards:
+//
+//   [DIV::*[x,y:integer; X?(x,y)->
+//         quot,rem:integer; quot := 0; rem := x;
+//         *[rem >= y -> rem := rem - y; quot := quot + 1;]
+//         X!(quot,rem)
+//         ]
+//   ||X::USER]
+//
+// The USER calls the subroutine by a pair of commands:
+//
+//   X <- S41_In{x, y}; ...; r := <-out
+//
+// where any commands between the two are executed concurrently with
+// DIV. As in the paper, the dividend must not be negative and the
+// divisor must be positive.
+func S41_DIV(X <-chan S41_In, out chan<- S41_Out) {
+	for v := range X {
+		quot, rem := 0, v.X
+		for rem >= v.Y {
+			rem -= v.Y
+			quot++
+		}
+		out <- S41_Out{quot, rem}
+	}
+	close(out)
+}
+
 // S42_Factorial implements Section 4.2 Factorial
 // "Compute a factorial by the recursive method, to a given limit."
 //
@@ -366,6 +380,88 @@ func S42_Factorial(fac []chan int, limit int) {
 	}
 }
 
+// S42_FACTORIAL implements the Section 4.2 factorial as a chain of
+// repetitive processes fac(1..limit+1), each serving requests from its
+// predecessor fac(i-1) and delegating to its successor fac(i+1):
+//
+//   [fac(i:1..limit+1)::
+//   *[n:integer;fac(i-1)?n ->
+//     [n=0 -> fac(i-1)!1
+//     □ n>0 -> fac(i+1)!n-1;
+//       r:integer; fac(i+1)?r; fac(i-1)!(n*r)
+//   ]] || fac(0)::USER ]
+//
+// The paper names fac(1..limit), which computes n! only for n < limit,
+// since fac(limit) would have to call the non-existent fac(limit+1).
+// S42_FACTORIAL adds one more process so that n! can be computed up to
+// and including the limit. Each link of the chain uses a pair of
+// channels, one for requests and one for results.
+type S42_FACTORIAL struct {
+	mu     sync.Mutex
+	req    chan int
+	res    chan int
+	limit  int
+	closed bool
+}
+
+// ErrS42_Closed is the error of Factorial of a closed chain.
+var ErrS42_Closed = errors.New("csp: factorial chain closed")
+
+// NewS42_FACTORIAL builds the chain of processes up to the given limit.
+// The chain terminates when Close is called.
+func NewS42_FACTORIAL(limit int) *S42_FACTORIAL {
+	req := make([]chan int, limit+2)
+	res := make([]chan int, limit+2)
+	for i := range req {
+		req[i], res[i] = make(chan int), make(chan int)
+	}
+	for i := 1; i <= limit+1; i++ {
+		go func(i int) {
+			// fac(i) terminates with its predecessor, and so does its
+			// successor.
+			defer close(req[i])
+			for n := range req[i-1] {
+				if n == 0 {
+					res[i-1] <- 1
+					continue
+				}
+				req[i] <- n - 1
+				r := <-res[i]
+				res[i-1] <- n * r
+			}
+		}(i)
+	}
+	return &S42_FACTORIAL{req: req[0], res: res[0], limit: limit}
+}
+
+// Factorial is the USER fac(0) of the chain. It computes n! by sending n
+// to fac(1) and receiving the result from it. It fails with
+// ErrS42_Closed once the chain is closed.
+func (f *S42_FACTORIAL) Factorial(n int) (int, error) {
+	if n < 0 || n > f.limit {
+		return 0, fmt.Errorf("csp: factorial of %d is out of range [0, %d]", n, f.limit)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return 0, ErrS42_Closed
+	}
+	f.req <- n
+	return <-f.res, nil
+}
+
+// Close terminates all processes of the chain. Closing a closed chain
+// has no effect.
+func (f *S42_FACTORIAL) Close() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.closed {
+		f.closed = true
+		close(f.req)
+	}
+}
+
 // S43_SmallSetOfIntegers implements Section 4.3 Small Set Of Integers.
 // "To represent a set of not more than 100 integers as a process, S,
 // which accepts two kinds of instruction from its calling process X:
@@ -412,7 +508,7 @@ func (s *S43_SmallSetOfIntegers) SEARCH(n int) int {
 }
 
 // Has searches in the set given n, has receives true if n is found.
-func (s *S43_SmallSetOfIntegers) Has(n int, has chan bool) {
+func (s *S43_SmallSetOfIntegers) Has(n int, has chan<- bool) {
 	defer close(has)
 
 	if s.SEARCH(n) < s.size {
@@ -424,7 +520,7 @@ func (s *S43_SmallSetOfIntegers) Has(n int, has chan bool) {
 }
 
 // Insert inserts given n, done recieves true if n is inserted.
-func (s *S43_SmallSetOfIntegers) Insert(n int, done chan bool) {
+func (s *S43_SmallSetOfIntegers) Insert(n int, done chan<- bool) {
 	defer close(done)
 
 	i := s.SEARCH(n)
@@ -444,6 +540,117 @@ func (s *S43_SmallSetOfIntegers) Insert(n int, done chan bool) {
 	return
 }
 
+// S43_Insert is the insert(n) instruction of S43_SMALLSET.
+type S43_Insert struct {
+	N int
+}
+
+// S43_Has is the has(n) instruction of S43_SMALLSET, the answer is
+// sent on Response.
+type S43_Has struct {
+	N        int
+	Response chan<- bool
+}
+
+// S43_SMALLSET implements the Section 4.3 set process S holding up to n
+// integers. Unlike the S43_SmallSetOfIntegers methods it is a single
+// repetitive process, which serves insert(n) and has(n) instructions
+// until both instruction channels are closed:
+//
+//   S::
+//   content(0..n-1)integer; size:integer; size := 0;
+//   *[n:integer; X?has(n) -> SEARCH; X!(i<size)
+//   □ n:integer; X?insert(n) -> SEARCH;
+//         [i<size -> skip
+//         □i = size; size<n ->
+//            content(size) := n; size := size+1
+//   ]]
+//
+// An insertion into a full set is ignored, whereas in the paper the
+// alternative command would fail.
+func S43_SMALLSET(n int, insert <-chan S43_Insert, has <-chan S43_Has) {
+	smallset(n, insert, has, nil)
+}
+
+// S44_Least is the least() instruction of S44_REMOVE_LEAST, the least
+// member is sent on Response, or NoneLeft if the set is empty.
+type S44_Least struct {
+	Response chan<- S46_Least
+}
+
+// S44_REMOVE_LEAST extends S43_SMALLSET by the least() instruction,
+// which yields the least member of the set and removes it from the set,
+// as the exercise of Section 4.6 asks for:
+//
+//   □ X?least() ->
+//       [size = 0 -> X!noneleft()
+//       □ size > 0 -> i:integer; i := index of least member;
+//           X!content(i); size := size-1; content(i) := content(size)
+//       ]
+//
+// The process terminates once all instruction channels are closed.
+func S44_REMOVE_LEAST(n int, insert <-chan S43_Insert, has <-chan S43_Has, least <-chan S44_Least) {
+	smallset(n, insert, has, least)
+}
+
+// smallset is the set process shared by S43_SMALLSET and
+// S44_REMOVE_LEAST, a nil least channel disables the least()
+// instruction.
+func smallset(n int, insert <-chan S43_Insert, has <-chan S43_Has, least <-chan S44_Least) {
+	content := make([]int, n)
+	size := 0
+
+	// SEARCH:
+	//
+	//   i:integer; i := 0;
+	//   *[i<size; content(i) != n -> i:=i+1]
+	search := func(v int) int {
+		i := 0
+		for i < size && content[i] != v {
+			i++
+		}
+		return i
+	}
+
+	for insert != nil || has != nil || least != nil {
+		select {
+		case h, ok := <-has:
+			if !ok {
+				has = nil
+				continue
+			}
+			h.Response <- search(h.N) < size
+		case in, ok := <-insert:
+			if !ok {
+				insert = nil
+				continue
+			}
+			if i := search(in.N); i == size && size < n {
+				content[size] = in.N
+				size++
+			}
+		case l, ok := <-least:
+			if !ok {
+				least = nil
+				continue
+			}
+			if size == 0 {
+				l.Response <- S46_Least{NoneLeft: true}
+				continue
+			}
+			i := 0
+			for j := 1; j < size; j++ {
+				if content[j] < content[i] {
+					i = j
+				}
+			}
+			l.Response <- S46_Least{Least: content[i]}
+			size--
+			content[i] = content[size]
+		}
+	}
+}
+
 // S44_Scan implements Section 4.4 Scanning a Set
 // "Extend the solution to 4.3 by providing a fast method for scanning
 // all members of the set without changing the value of the set. The
@@ -455,7 +662,7 @@ func (s *S43_SmallSetOfIntegers) Insert(n int, done chan bool) {
 //   *[more;x:integer;S?next(x)->...deal with x ... .
 //   □ more;S?noneleft()->more:=false]
 //
-func (s *S43_SmallSetOfIntegers) S44_Scan(recv chan int) {
+func (s *S43_SmallSetOfIntegers) S44_Scan(recv chan<- int) {
 	for _, v := range s.content {
 		recv <- v
 	}
@@ -582,7 +789,7 @@ func S45_S46_NewRecursiveSmallSetOfIntegers() (chan S45_Has, chan int, chan chan
 
 type S45_Has struct {
 	V        int
-	Response chan bool
+	Response chan<- bool
 }
 
 type S46_Least struct {
@@ -590,6 +797,91 @@ type S46_Least struct {
 	NoneLeft bool
 }
 
+// S45_RECURSIVE_SMALLSET implements the Section 4.5 recursive small set
+// as a linear array of n processes S(1..n), fed by the user S(0) over
+// insert and has. Each process holds at most one number, the i-th
+// process the i-th least number of the set, and passes larger numbers
+// on to its successor:
+//
+//   S(i:1..n)::
+//   *[m:integer; S(i-1)?has(m)->S(0)!false
+//   □ v:integer; S(i-1)?insert(v)->
+//      *[m:integer; S(i-1)?has(m)->
+//         [m<=v->S(0)!(m=v)
+//         □m>v->S(i+1)!has(m)
+//       ]
+//      □m:integer; S(i-1)?insert(m)->
+//       [m<v->S(i+1)!insert(v); v:=m
+//       □m=v->skip
+//       □m>v->S(i+1)!insert(m)
+//   ]]]
+//
+// Answers to has(m) are sent directly to S(0) on the Response channel
+// of the instruction. Unlike S45_S46_NewRecursiveSmallSetOfIntegers, the
+// last process answers false instead of calling the non-existent
+// S(n+1), and an insertion into a full set is dropped there. Closing
+// both insert and has terminates all processes one after another.
+func S45_RECURSIVE_SMALLSET(n int, insert <-chan int, has <-chan S45_Has) {
+	// S(i) receives from ins[i-1] and hs[i-1], and sends to inserts[i]
+	// and hass[i].
+	ins, hs := make([]<-chan int, n+1), make([]<-chan S45_Has, n+1)
+	inserts, hass := make([]chan int, n+1), make([]chan S45_Has, n+1)
+	ins[0], hs[0] = insert, has
+	for i := 1; i <= n; i++ {
+		inserts[i], hass[i] = make(chan int), make(chan S45_Has)
+		ins[i], hs[i] = inserts[i], hass[i]
+	}
+	for i := 1; i <= n; i++ {
+		go func(i int) {
+			insert, has := ins[i-1], hs[i-1]
+			last := i == n
+			if !last {
+				defer close(inserts[i])
+				defer close(hass[i])
+			}
+
+			v, empty := 0, true
+			for insert != nil || has != nil {
+				select {
+				case h, ok := <-has:
+					if !ok {
+						has = nil
+						continue
+					}
+					switch {
+					case empty:
+						h.Response <- false
+					case h.V <= v:
+						h.Response <- h.V == v
+					case last:
+						h.Response <- false
+					default:
+						hass[i] <- h
+					}
+				case m, ok := <-insert:
+					if !ok {
+						insert = nil
+						continue
+					}
+					switch {
+					case empty:
+						v, empty = m, false
+					case m < v:
+						if !last {
+							inserts[i] <- v
+						}
+						v = m
+					case m > v:
+						if !last {
+							inserts[i] <- m
+						}
+					}
+				}
+			}
+		}(i)
+	}
+}
+
 // S51_BoundedBuffer implements Section 5.1 Bounded Buffer
 // "Construct a buffering process X to smooth variations in the speed of
 // output of portions by a producer process and input by a consumer
@@ -606,7 +898,7 @@ type S46_Least struct {
 //     *[in < out + 10; producer?buffer(in mod 10) -> in := in + 1
 //     □ out < in; consumer?more() -> consumer!buffer(out mod 10);
 //        out := out + 1 ]
-func S51_BoundedBuffer() (chan int, chan int) {
+func S51_BoundedBuffer() (chan<- int, <-chan int) {
 	in, out := 0, 0
 	size := 10
 	buffer := make([]int, size)
@@ -635,6 +927,48 @@ func S51_BoundedBuffer() (chan int, chan int) {
 	return producer, consumer
 }
 
+// S51_BOUNDED_BUFFER implements the Section 5.1 buffering process X
+// holding up to size portions. Unlike S51_BoundedBuffer, it does not
+// poll: the producer input guard and the consumer output guard of the
+// alternative command are enabled or disabled by their boolean
+// conditions:
+//
+//   X::
+//   buffer:(0..size-1)portion;
+//   in,out:integer; in:=0; out := 0;
+//   comment 0 <= out <= in <= out+size;
+//     *[in < out + size; producer?buffer(in mod size) -> in := in + 1
+//     □ out < in; consumer!buffer(out mod size) -> out := out + 1 ]
+//
+// The paper's consumer?more() handshake is replaced by an output guard,
+// as discussed in Section 7.8. Once producer is closed, the remaining
+// portions are delivered, all guards fail, and consumer is closed.
+//
+// A non-positive size is rejected with a panic.
+func S51_BOUNDED_BUFFER(size int, producer <-chan int, consumer chan<- int) {
+	if size <= 0 {
+		panic("csp: non-positive buffer size")
+	}
+
+	buffer := make([]int, size)
+	in, out := 0, 0
+	X := alt.New(
+		alt.Recv(producer, func(v int) {
+			buffer[in%size] = v
+			in++
+		}).When(func() bool { return in < out+size }),
+		alt.Send(consumer, func() int { return buffer[out%size] }, func() {
+			out++
+		}).When(func() bool { return out < in }),
+	)
+	for {
+		if _, ok := X.Select(); !ok {
+			break
+		}
+	}
+	close(consumer)
+}
+
 // S52_IntegerSemaphore implements Section 5.2 Integer Semaphore.
 // "To implement an integer semaphore, S, shared among an array
 // X(i:1..100) of client processes. Each process many increment the
@@ -704,6 +1038,107 @@ func (s *S52_IntegerSemaphore) Close() {
 	close(s.done)
 }
 
+// S52_Client is the pair of channels over which the client process X(i)
+// sends its V() and P() commands to S52_SEMAPHORE.
+type S52_Client struct {
+	V, P chan struct{}
+}
+
+// S52_SEMAPHORE implements the Section 5.2 integer semaphore S shared
+// among an array of client processes X(i:1..n):
+//
+//   S::val:integer; val:=0;
+//   *[(i:1..n)X(i)?V()->val:=val+1
+//   □ (i:1..n)val>0;X(i)?P()->val:=val-1]
+//
+// Unlike S52_IntegerSemaphore, every client owns its own channels, and
+// the guards of all clients are selected over by a single alternative.
+// A client terminates by closing its channels, the semaphore terminates
+// once all clients have terminated, or once all clients have closed
+// their V() channels while val is zero.
+func S52_SEMAPHORE(clients []S52_Client) {
+	// cases[2i] is the V() guard, cases[2i+1] the P() guard of X(i).
+	cases := make([]reflect.SelectCase, 2*len(clients))
+	chans := make([]reflect.Value, 2*len(clients))
+	for i, c := range clients {
+		chans[2*i], chans[2*i+1] = reflect.ValueOf(c.V), reflect.ValueOf(c.P)
+	}
+	for i := range cases {
+		cases[i].Dir = reflect.SelectRecv
+	}
+
+	val := 0
+	for {
+		// as in the paper, the repetitive command terminates once all
+		// guards fail, i.e. all sources have terminated or val>0 is
+		// false for the remaining P() guards.
+		enabled := 0
+		for i := range cases {
+			cases[i].Chan = chans[i]
+			if i%2 == 1 && val <= 0 {
+				cases[i].Chan = reflect.Value{}
+			}
+			if cases[i].Chan.IsValid() {
+				enabled++
+			}
+		}
+		if enabled == 0 {
+			return
+		}
+		chosen, _, ok := reflect.Select(cases)
+		if !ok {
+			chans[chosen] = reflect.Value{}
+			continue
+		}
+		if chosen%2 == 0 {
+			val++
+		} else {
+			val--
+		}
+	}
+}
+
+// S52_MutualExclusion demonstrates mutual exclusion among n clients of
+// S52_SEMAPHORE. The semaphore is initialized to one by a V() of the
+// first client, then each client enters its critical section rounds
+// times, bracketed by P() and V(). It returns the maximum number of
+// clients observed inside their critical section at the same time,
+// which is 1.
+func S52_MutualExclusion(n, rounds int) int {
+	clients := make([]S52_Client, n)
+	for i := range clients {
+		clients[i] = S52_Client{V: make(chan struct{}), P: make(chan struct{})}
+	}
+	go S52_SEMAPHORE(clients)
+	clients[0].V <- struct{}{}
+
+	var inside, max int32
+	wg := sync.WaitGroup{}
+	wg.Add(n)
+	for i := range clients {
+		go func(c S52_Client) {
+			defer wg.Done()
+			for r := 0; r < rounds; r++ {
+				c.P <- struct{}{}
+				cur := atomic.AddInt32(&inside, 1)
+				for {
+					m := atomic.LoadInt32(&max)
+					if cur <= m || atomic.CompareAndSwapInt32(&max, m, cur) {
+						break
+					}
+				}
+				runtime.Gosched()
+				atomic.AddInt32(&inside, -1)
+				c.V <- struct{}{}
+			}
+			close(c.V)
+			close(c.P)
+		}(clients[i])
+	}
+	wg.Wait()
+	return int(max)
+}
+
 // S53_DiningPhilosophers implements Section 5.3 Dining Philosophers
 // "Five philosophers spend their lives thinking and eating. The
 // philosophers share a common dining room where there is a curcular
@@ -806,6 +1241,117 @@ func S53_DiningPhilosophers() {
 	wg.Wait() // wait until all philosophers are finished.
 }
 
+// S53_DINING_PHILOSOPHERS implements the Section 5.3 dining philosophers
+// with n philosophers, each of which eats the given number of meals
+// before leaving the table for good:
+//
+//   PHIL = *[...during ith lifetime ... ->
+//            THINK;
+//            room!enter();
+//            fork(i)!pickup();fork((i+1)mod n)!pickup();
+//            EAT;
+//            fork(i)!putdown();fork((i+1)mod n)!putdown();
+//            room!exit()]
+//
+//   FORK = *[phil(i)?pickup()->phil(i)?putdown()
+//          □ phil((i-1)mod n)?pickup()->phil((i-1)mod n)?putdown()]
+//
+//   ROOM = occupancy:integer; occupancy := 0;
+//          *[(i:0..n-1)occupancy<n-1;phil(i)?enter()->occupancy:=occupancy+1
+//          □ (i:0..n-1)phil(i)?exit()->occupancy:=occupancy-1]
+//
+//   [room::ROOM||fork(i:0..n-1)::FORK||phil(i:0..n-1)::PHIL]
+//
+// Unlike S53_DiningPhilosophers, the room admits at most n-1
+// philosophers at a time, as proposed by the exercise of the paper,
+// which prevents the deadlock of all philosophers holding their left
+// fork. It returns the number of meals eaten by each philosopher, and
+// the maximum occupancy of the room.
+//
+// Less than two philosophers are rejected with a panic.
+func S53_DINING_PHILOSOPHERS(n, meals int) ([]int, int) {
+	if n < 2 {
+		panic("csp: less than two philosophers")
+	}
+
+	enter, exit := make(chan int), make(chan int)
+	pickup, putdown := make([]chan int, n), make([]chan int, n)
+	for i := 0; i < n; i++ {
+		pickup[i], putdown[i] = make(chan int), make(chan int)
+	}
+
+	eaten := make([]int, n)
+	PHIL := func(i int) {
+		for m := 0; m < meals; m++ {
+			// THINK
+			time.Sleep(time.Microsecond * time.Duration(rand.Intn(100)))
+			enter <- i
+			pickup[i] <- i
+			pickup[(i+1)%n] <- i
+			// EAT
+			eaten[i]++
+			time.Sleep(time.Microsecond * time.Duration(rand.Intn(100)))
+			putdown[i] <- i
+			putdown[(i+1)%n] <- i
+			exit <- i
+		}
+	}
+	FORK := func(i int) {
+		// the fork is put down by the philosopher who picked it up.
+		for range pickup[i] {
+			<-putdown[i]
+		}
+	}
+	maxOccupancy := make(chan int)
+	ROOM := func() {
+		occupancy, max := 0, 0
+		in, out := enter, exit
+		for in != nil || out != nil {
+			e := in
+			if occupancy >= n-1 {
+				e = nil
+			}
+			select {
+			case _, ok := <-e:
+				if !ok {
+					in = nil
+					continue
+				}
+				occupancy++
+				if occupancy > max {
+					max = occupancy
+				}
+			case _, ok := <-out:
+				if !ok {
+					out = nil
+					continue
+				}
+				occupancy--
+			}
+		}
+		maxOccupancy <- max
+	}
+
+	go ROOM()
+	wg := sync.WaitGroup{}
+	wg.Add(n)
+	for i := 0; i < n; i++ {
+		go FORK(i)
+		go func(i int) {
+			PHIL(i)
+			wg.Done()
+		}(i)
+	}
+	wg.Wait()
+
+	for i := 0; i < n; i++ {
+		close(pickup[i])
+	}
+	close(enter)
+	close(exit)
+	return eaten, <-maxOccupancy
+}
+
 // S61_TheSieveOfEratosthenes implements Section 6.1 Prime Numbers: The
 // Sieve of Eratosthenes.
 // "To print in ascending order all primes less than 10000. Use an array
@@ -887,6 +1433,122 @@ func S61_TheSieveOfEratosthenes(np int) {
 	}
 }
 
+// S61_SIEVE implements the Section 6.1 sieve of Eratosthenes as a fixed
+// array of np SIEVE processes sending all primes less than n to print,
+// which is closed once all processes have terminated:
+//
+//   [SIEVE(i:1..np)::
+//    p,mp:integer;
+//    SIEVE(i-1)?p;
+//    print!p;
+//    mp:=p; comment mp is a multiple of p;
+//   *[m:integer; SIEVE(i-1)?m->
+//      *[m>mp->mp:=mp+p];
+//       [m=mp->skip □ m<mp->SIEVE(i+1)!m ]
+//    ]
+//   || SIEVE(0)::print!2; m:integer; m:=3;
+//         *[m<n->SIEVE(1)!m;m:=m+2]
+//   || SIEVE(np+1)::*[m:integer;SIEVE(np)?m->print!m]
+//   || print::*[(i:0..np+1)m:integer;SIEVE(i)?m->...]
+//   ]
+//
+// Numbers reaching SIEVE(np+1) are only guaranteed to be primes if n
+// does not exceed the square of the np-th prime, e.g. 100 processes
+// suffice for n = 10000 as in the paper. All processes output to print
+// directly, hence the output is only in ascending order as long as
+// print is unbuffered; S61_SIEVE_ORDERED guarantees the order.
+func S61_SIEVE(n, np int, print chan<- int) {
+	out := make([]chan<- int, np+2)
+	for i := range out {
+		out[i] = print
+	}
+	sieve(n, np, out, false)
+	close(print)
+}
+
+// S61_SIEVE_ORDERED is the variant of S61_SIEVE asked for by the
+// exercise of the paper, which prints the primes in ascending order
+// regardless of the buffering of print. Each SIEVE(i) outputs to its
+// own channel, and the print process inputs from them in order of i:
+//
+//   print::*[(i:0..np+1)m:integer;SIEVE(i)?m->...]
+//
+// becomes a sequence of repetitive commands, one per SIEVE(i).
+func S61_SIEVE_ORDERED(n, np int, print chan<- int) {
+	out, in := make([]chan<- int, np+2), make([]<-chan int, np+2)
+	for i := range out {
+		ch := make(chan int)
+		out[i], in[i] = ch, ch
+	}
+	go sieve(n, np, out, true)
+	for i := range in {
+		for m := range in[i] {
+			print <- m
+		}
+	}
+	close(print)
+}
+
+// sieve runs SIEVE(0..np+1), where SIEVE(i) outputs to out[i], and
+// returns once all processes have terminated. If closeOut is set, each
+// SIEVE(i) closes out[i] once it has nothing more to output, that is
+// after its prime for i <= np.
+func sieve(n, np int, out []chan<- int, closeOut bool) {
+	in := make([]chan int, np+2)
+	for i := range in {
+		in[i] = make(chan int)
+	}
+	printed := func(i int) {
+		if closeOut {
+			close(out[i])
+		}
+	}
+	wg := sync.WaitGroup{}
+	wg.Add(np + 2)
+	go func() {
+		defer wg.Done()
+		if n > 2 {
+			out[0] <- 2
+		}
+		printed(0)
+		for m := 3; m < n; m += 2 {
+			in[1] <- m
+		}
+		close(in[1])
+	}()
+	for i := 1; i <= np; i++ {
+		go func(i int) {
+			defer wg.Done()
+			defer close(in[i+1])
+
+			p, ok := <-in[i]
+			if !ok {
+				printed(i)
+				return
+			}
+			out[i] <- p
+			printed(i)
+			mp := p // mp is a multiple of p
+			for m := range in[i] {
+				for m > mp {
+					mp += p
+				}
+				if m < mp {
+					in[i+1] <- m
+				}
+			}
+		}(i)
+	}
+	go func() {
+		defer wg.Done()
+		for m := range in[np+1] {
+			out[np+1] <- m
+		}
+		printed(np + 1)
+	}()
+	wg.Wait()
+}
+
 // S62_MatrixMultiplication implements Section 6.2 An interative Array:
 // Matrix Multiplication.
 // "A square matrix A of order 3 is given. Three streams are to be input,
@@ -1009,3 +1671,121 @@ func (m *S62_Matrix) S62_Multiply(IN [][]int) (OUT [][]int) {
 	wg.Wait()
 	return
 }
+
+// S62_MATMUL builds the Section 6.2 iterative array generalized to a
+// square matrix A of order n, and returns the channels of the WEST and
+// SOUTH border nodes. The i-th input stream is sent to west[i], i.e. a
+// row of IN is sent component-wise to all west channels, and the j-th
+// component of the corresponding row of IN x A is received from
+// south[j]:
+//
+//   [M(i:1..n,0)::WEST
+//   ||M(0,j:1..n)::NORTH
+//   ||M(i:1..n,n+1)::EAST
+//   ||M(n+1,j:1..n)::SOUTH
+//   ||M(i:1..n,j:1..n)::CENTER]
+//
+//   NORTH = *[true -> M(1,j)!0]
+//   EAST = *[x:real; M(i,n)?x->skip]
+//   CENTER = *[x:real;M(i,j-1)?x->
+//             M(i,j+1)!x;sum:real;
+//             M(i-1,j)?sum;M(i+1,j)!(A(i,j)*x+sum)]
+//
+// Unlike S62_NewMatrix, the network terminates: once all west channels
+// are closed, every node terminates after its west neighbour, and the
+// south channels are closed.
+func S62_MATMUL(A [][]int) (west []chan<- int, south []<-chan int) {
+	n := len(A)
+
+	// h[i][j] carries x from M(i,j-1) to M(i,j), v[i][j] carries the
+	// partial sum from M(i-1,j) to M(i,j), with 0-based i and j.
+	h, v := make([][]chan int, n), make([][]chan int, n+1)
+	for i := 0; i < n; i++ {
+		h[i] = make([]chan int, n+1)
+		for j := range h[i] {
+			h[i][j] = make(chan int)
+		}
+	}
+	for i := 0; i <= n; i++ {
+		v[i] = make([]chan int, n)
+		for j := range v[i] {
+			v[i][j] = make(chan int)
+		}
+	}
+
+	west, south = make([]chan<- int, n), make([]<-chan int, n)
+	for i := 0; i < n; i++ {
+		west[i], south[i] = h[i][0], v[n][i]
+	}
+
+	for j := 0; j < n; j++ {
+		done := make(chan struct{})
+		// NORTH
+		go func(j int) {
+			for {
+				select {
+				case v[0][j] <- 0:
+				case <-done:
+					return
+				}
+			}
+		}(j)
+		for i := 0; i < n; i++ {
+			// CENTER
+			go func(i, j int) {
+				for x := range h[i][j] {
+					h[i][j+1] <- x
+					sum := <-v[i][j]
+					v[i+1][j] <- A[i][j]*x + sum
+				}
+				close(h[i][j+1])
+				close(v[i+1][j])
+				if i == 0 {
+					close(done)
+				}
+			}(i, j)
+		}
+	}
+	for i := 0; i < n; i++ {
+		// EAST
+		go func(i int) {
+			for range h[i][n] {
+			}
+		}(i)
+	}
+	return west, south
+}
+
+// S62_MatMul computes IN x A by streaming the rows of IN through the
+// iterative array of S62_MATMUL.
+func S62_MatMul(A, IN [][]int) [][]int {
+	west, south := S62_MATMUL(A)
+
+	for i := range west {
+		go func(i int) {
+			for k := range IN {
+				west[i] <- IN[k][i]
+			}
+			close(west[i])
+		}(i)
+	}
+
+	OUT := make([][]int, len(IN))
+	for k := range OUT {
+		OUT[k] = make([]int, len(A))
+	}
+	wg := sync.WaitGroup{}
+	wg.Add(len(south))
+	for j := range south {
+		go func(j int) {
+			k := 0
+			for sum := range south[j] {
+				OUT[k][j] = sum
+				k++
+			}
+			wg.Done()
+		}(j)
+	}
+	wg.Wait()
+	return OUT
+}
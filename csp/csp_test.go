@@ -1,9 +1,12 @@
 package csp_test
 
 import (
+	"errors"
+	"math/rand"
 	"reflect"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/changkun/gobase/csp"
 )
@@ -204,6 +207,22 @@ func TestS36_Reformat(t *testing.T) {
 	}
 }
 
+func TestS35_ReformatPanic(t *testing.T) {
+	// ASSEMBLE fails to print on a closed lineprinter.
+	cardfile, lineprinter := make(chan []rune, 1), make(chan string)
+	cardfile <- []rune("Hello, CSP.")
+	close(cardfile)
+	close(lineprinter)
+
+	defer func() {
+		var perr *csp.PanicError
+		if err, ok := recover().(error); !ok || !errors.As(err, &perr) {
+			t.Fatalf("%v: expected a panic of a PanicError, got: %v", t.Name(), err)
+		}
+	}()
+	csp.S35_Reformat(cardfile, lineprinter)
+}
+
 func TestS36_ConwayProblem(t *testing.T) {
 	tests := []struct {
 		cardfile [][]rune
@@ -271,6 +290,32 @@ func TestS41_DivisionWithRemainder(t *testing.T) {
 	}
 }
 
+func TestS41_DIV(t *testing.T) {
+	tests := []struct {
+		input csp.S41_In
+		want  csp.S41_Out
+	}{
+		{input: csp.S41_In{10, 5}, want: csp.S41_Out{2, 0}},
+		{input: csp.S41_In{3, 2}, want: csp.S41_Out{1, 1}},
+		{input: csp.S41_In{10, 3}, want: csp.S41_Out{3, 1}},
+		{input: csp.S41_In{0, 3}, want: csp.S41_Out{0, 0}},
+	}
+
+	X, out := make(chan csp.S41_In), make(chan csp.S41_Out)
+	go csp.S41_DIV(X, out)
+	for _, tt := range tests {
+		X <- tt.input
+		got := <-out
+		if !reflect.DeepEqual(tt.want, got) {
+			t.Fatalf("%v: expected: %v, got: %v", t.Name(), tt.want, got)
+		}
+	}
+	close(X)
+	if _, ok := <-out; ok {
+		t.Fatalf("%v: expected DIV to terminate with its user", t.Name())
+	}
+}
+
 func TestS42_Factorial(t *testing.T) {
 	tests := []struct {
 		limit int
@@ -335,6 +380,37 @@ func TestS42_Factorial(t *testing.T) {
 	}
 }
 
+func TestS42_FACTORIAL(t *testing.T) {
+	limit := 10
+	fac := csp.NewS42_FACTORIAL(limit)
+	defer fac.Close()
+
+	want := 1
+	for n := 0; n <= limit; n++ {
+		if n > 0 {
+			want *= n
+		}
+		got, err := fac.Factorial(n)
+		if err != nil {
+			t.Fatalf("%v: unexpected error: %v", t.Name(), err)
+		}
+		if got != want {
+			t.Fatalf("%v: %v! expected: %v, got: %v", t.Name(), n, want, got)
+		}
+	}
+
+	for _, n := range []int{-1, limit + 1} {
+		if _, err := fac.Factorial(n); err == nil {
+			t.Fatalf("%v: expected error for %v!", t.Name(), n)
+		}
+	}
+
+	fac.Close()
+	if _, err := fac.Factorial(1); !errors.Is(err, csp.ErrS42_Closed) {
+		t.Fatalf("%v: expected: %v, got: %v", t.Name(), csp.ErrS42_Closed, err)
+	}
+}
+
 func TestS43_SmallSetOfIntegers(t *testing.T) {
 	set := csp.NewS43_SmallSetOfIntegers()
 
@@ -368,6 +444,74 @@ func TestS43_SmallSetOfIntegers(t *testing.T) {
 	}
 }
 
+func TestS43_SMALLSET(t *testing.T) {
+	n := 10
+	insert, has := make(chan csp.S43_Insert), make(chan csp.S43_Has)
+	go csp.S43_SMALLSET(n, insert, has)
+	defer close(insert)
+	defer close(has)
+
+	check := func(v int, want bool) {
+		response := make(chan bool)
+		has <- csp.S43_Has{N: v, Response: response}
+		if got := <-response; got != want {
+			t.Fatalf("%v: has(%v) expected: %v, got: %v", t.Name(), v, want, got)
+		}
+	}
+
+	for i := 0; i < n; i++ {
+		check(i, false)
+		insert <- csp.S43_Insert{N: i}
+		insert <- csp.S43_Insert{N: i}
+		check(i, true)
+	}
+	for i := n; i < 2*n; i++ {
+		insert <- csp.S43_Insert{N: i}
+		check(i, false)
+	}
+}
+
+func TestS44_REMOVE_LEAST(t *testing.T) {
+	insert, has, least := make(chan csp.S43_Insert), make(chan csp.S43_Has), make(chan csp.S44_Least)
+	go csp.S44_REMOVE_LEAST(10, insert, has, least)
+	defer close(insert)
+	defer close(has)
+	defer close(least)
+
+	check := func(v int, want bool) {
+		response := make(chan bool)
+		has <- csp.S43_Has{N: v, Response: response}
+		if got := <-response; got != want {
+			t.Fatalf("%v: has(%v) expected: %v, got: %v", t.Name(), v, want, got)
+		}
+	}
+	pop := func(want csp.S46_Least) {
+		response := make(chan csp.S46_Least)
+		least <- csp.S44_Least{Response: response}
+		if got := <-response; got != want {
+			t.Fatalf("%v: least() expected: %v, got: %v", t.Name(), want, got)
+		}
+	}
+
+	pop(csp.S46_Least{NoneLeft: true})
+	for _, v := range []int{5, 3, 8} {
+		insert <- csp.S43_Insert{N: v}
+	}
+	check(3, true)
+	pop(csp.S46_Least{Least: 3})
+	check(3, false)
+	check(5, true)
+	insert <- csp.S43_Insert{N: 1}
+	insert <- csp.S43_Insert{N: 3}
+	pop(csp.S46_Least{Least: 1})
+	pop(csp.S46_Least{Least: 3})
+	check(8, true)
+	pop(csp.S46_Least{Least: 5})
+	pop(csp.S46_Least{Least: 8})
+	pop(csp.S46_Least{NoneLeft: true})
+	check(8, false)
+}
+
 func TestS44_Scan(t *testing.T) {
 	set := csp.NewS43_SmallSetOfIntegers()
 
@@ -432,6 +576,38 @@ func TestS45_RecursiveSmallSetOfIntegers(t *testing.T) {
 	close(least)
 }
 
+func TestS45_RECURSIVE_SMALLSET(t *testing.T) {
+	n := 10
+	insert, has := make(chan int), make(chan csp.S45_Has)
+	csp.S45_RECURSIVE_SMALLSET(n, insert, has)
+	defer close(insert)
+	defer close(has)
+
+	check := func(v int, want bool) {
+		response := make(chan bool)
+		has <- csp.S45_Has{V: v, Response: response}
+		if got := <-response; got != want {
+			t.Fatalf("%v: has(%v) expected: %v, got: %v", t.Name(), v, want, got)
+		}
+	}
+
+	// insert in descending order, each insertion shifts all members.
+	for i := n - 1; i >= 0; i-- {
+		check(i, false)
+		insert <- i
+		check(i, true)
+	}
+	for i := 0; i < n; i++ {
+		insert <- i
+		check(i, true)
+	}
+	// the set is full.
+	insert <- -1
+	check(-1, true)
+	check(n-1, false)
+	check(n, false)
+}
+
 func TestS51_BoundedBuffer(t *testing.T) {
 	pro, con := csp.S51_BoundedBuffer()
 	go func() {
@@ -447,6 +623,46 @@ func TestS51_BoundedBuffer(t *testing.T) {
 	}
 }
 
+func TestS51_BOUNDED_BUFFER(t *testing.T) {
+	size := 10
+	producer, consumer := make(chan int), make(chan int)
+	go csp.S51_BOUNDED_BUFFER(size, producer, consumer)
+
+	// the consumer blocks on an empty buffer.
+	select {
+	case v := <-consumer:
+		t.Fatalf("%v: expected consumer to block, got %v", t.Name(), v)
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	// the producer blocks on a full buffer.
+	for i := 0; i < size; i++ {
+		producer <- i
+	}
+	select {
+	case producer <- size:
+		t.Fatalf("%v: expected producer to block on a full buffer", t.Name())
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	go func() {
+		for i := size; i < 100; i++ {
+			producer <- i
+		}
+		close(producer)
+	}()
+	i := 0
+	for v := range consumer {
+		if i != v {
+			t.Fatalf("%v: expected %v, got %v", t.Name(), i, v)
+		}
+		i++
+	}
+	if i != 100 {
+		t.Fatalf("%v: expected 100 portions, got %v", t.Name(), i)
+	}
+}
+
 func TestS52_IntegerSemaphore(t *testing.T) {
 	sem := csp.NewS52_IntegerSemaphore()
 
@@ -469,13 +685,127 @@ func TestS52_IntegerSemaphore(t *testing.T) {
 	sem.Close()
 }
 
+func TestS52_SEMAPHORE(t *testing.T) {
+	n := 10
+	clients := make([]csp.S52_Client, n)
+	for i := range clients {
+		clients[i] = csp.S52_Client{V: make(chan struct{}), P: make(chan struct{})}
+	}
+	done := make(chan struct{})
+	go func() {
+		csp.S52_SEMAPHORE(clients)
+		close(done)
+	}()
+
+	// the number of completed P() never exceeds the number of completed
+	// V(). A client holds mu while it V()s until it counted the V(),
+	// hence a P() the V() enabled is counted after it. The clients start
+	// to P() before they V(), which catches a P() accepted too early.
+	var vs, ps int
+	var mu sync.Mutex
+	wg := sync.WaitGroup{}
+	wg.Add(2 * n)
+	for i := range clients {
+		go func(c csp.S52_Client) {
+			defer wg.Done()
+			for r := 0; r < 100; r++ {
+				c.P <- struct{}{}
+				mu.Lock()
+				if ps++; ps > vs {
+					t.Errorf("%v: P() exceeds V(): %v > %v", t.Name(), ps, vs)
+				}
+				mu.Unlock()
+			}
+			close(c.P)
+		}(clients[i])
+	}
+	for i := range clients {
+		go func(c csp.S52_Client) {
+			defer wg.Done()
+			for r := 0; r < 100; r++ {
+				mu.Lock()
+				c.V <- struct{}{}
+				vs++
+				mu.Unlock()
+			}
+			close(c.V)
+		}(clients[i])
+	}
+	wg.Wait()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("%v: semaphore did not terminate with its clients", t.Name())
+	}
+}
+
+func TestS52_MutualExclusion(t *testing.T) {
+	if got := csp.S52_MutualExclusion(10, 100); got != 1 {
+		t.Fatalf("%v: expected at most 1 client in critical section, got %v", t.Name(), got)
+	}
+}
+
 func TestS53_DiningPhilosophers(t *testing.T) {
 	csp.S53_DiningPhilosophers()
 }
 
+func TestS53_DINING_PHILOSOPHERS(t *testing.T) {
+	for _, n := range []int{2, 5, 10} {
+		meals, max := csp.S53_DINING_PHILOSOPHERS(n, 50)
+		for i, m := range meals {
+			if m != 50 {
+				t.Fatalf("%v: philosopher %v of %v expected 50 meals, got %v", t.Name(), i, n, m)
+			}
+		}
+		if max > n-1 {
+			t.Fatalf("%v: expected at most %v philosophers in the room, got %v", t.Name(), n-1, max)
+		}
+	}
+}
+
 func TestS61_TheSieveOfEratosthenes(t *testing.T) {
 	csp.S61_TheSieveOfEratosthenes(100)
 }
+
+func TestS61_SIEVE(t *testing.T) {
+	n := 1000
+	want := []int{}
+	for m := 2; m < n; m++ {
+		prime := true
+		for d := 2; d*d <= m; d++ {
+			if m%d == 0 {
+				prime = false
+				break
+			}
+		}
+		if prime {
+			want = append(want, m)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		sieve func(n, np int, print chan<- int)
+		print chan int
+	}{
+		{name: "S61_SIEVE", sieve: csp.S61_SIEVE, print: make(chan int)},
+		{name: "S61_SIEVE_ORDERED", sieve: csp.S61_SIEVE_ORDERED, print: make(chan int)},
+		{name: "S61_SIEVE_ORDERED buffered", sieve: csp.S61_SIEVE_ORDERED, print: make(chan int, 100)},
+	}
+	for _, tt := range tests {
+		// the 20th prime is 71, and 71*71 > 1000.
+		go tt.sieve(n, 20, tt.print)
+		got := []int{}
+		for p := range tt.print {
+			got = append(got, p)
+		}
+		if !reflect.DeepEqual(want, got) {
+			t.Fatalf("%v: %v expected: %v, got: %v", t.Name(), tt.name, want, got)
+		}
+	}
+}
+
 func TestS62_MatrixMultiplication(t *testing.T) {
 	A := [][]int{
 		[]int{1, 2, 3},
@@ -494,3 +824,34 @@ func TestS62_MatrixMultiplication(t *testing.T) {
 		t.Fatalf("matrix multiplication result is incorrect")
 	}
 }
+
+func TestS62_MATMUL(t *testing.T) {
+	for _, n := range []int{1, 3, 5} {
+		A, IN := make([][]int, n), make([][]int, 2*n)
+		for i := range A {
+			A[i] = make([]int, n)
+			for j := range A[i] {
+				A[i][j] = rand.Intn(10)
+			}
+		}
+		for k := range IN {
+			IN[k] = make([]int, n)
+			for i := range IN[k] {
+				IN[k][i] = rand.Intn(10)
+			}
+		}
+		want := make([][]int, len(IN))
+		for k := range IN {
+			want[k] = make([]int, n)
+			for j := 0; j < n; j++ {
+				for i := 0; i < n; i++ {
+					want[k][j] += IN[k][i] * A[i][j]
+				}
+			}
+		}
+
+		if got := csp.S62_MatMul(A, IN); !reflect.DeepEqual(want, got) {
+			t.Fatalf("%v: %v x %v expected: %v, got: %v", t.Name(), IN, A, want, got)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package csp
+
+import "context"
+
+// recv receives from ch unless ctx is done first. ok is false if ch is
+// closed or ctx is done.
+func recv[T any](ctx context.Context, ch <-chan T) (v T, ok bool) {
+	select {
+	case v, ok = <-ch:
+		return v, ok
+	case <-ctx.Done():
+		return v, false
+	}
+}
+
+// send sends v to ch unless ctx is done first, in which case it
+// reports false.
+func send[T any](ctx context.Context, ch chan<- T, v T) bool {
+	select {
+	case ch <- v:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// S31_COPYCtx is like S31_COPY, but abandons copying and closes east
+// once ctx is done.
+func S31_COPYCtx(ctx context.Context, west <-chan rune, east chan<- rune) {
+	defer close(east)
+	for {
+		c, ok := recv(ctx, west)
+		if !ok || !send(ctx, east, c) {
+			return
+		}
+	}
+}
+
+// S32_SQUASHCtx is like S32_SQUASH_EX, but abandons squashing and closes
+// east once ctx is done.
+func S32_SQUASHCtx(ctx context.Context, west <-chan rune, east chan<- rune) {
+	defer close(east)
+	for {
+		c, ok := recv(ctx, west)
+		if !ok {
+			return
+		}
+		if c != '*' {
+			if !send(ctx, east, c) {
+				return
+			}
+			continue
+		}
+		c, ok = recv(ctx, west)
+		if !ok {
+			if ctx.Err() == nil {
+				send(ctx, east, '*')
+			}
+			return
+		}
+		if c == '*' {
+			if !send(ctx, east, '↑') {
+				return
+			}
+			continue
+		}
+		if !send(ctx, east, '*') || !send(ctx, east, c) {
+			return
+		}
+	}
+}
+
+// S33_DISASSEMBLECtx is like S33_DISASSEMBLE, but abandons the current
+// card and closes X once ctx is done.
+func S33_DISASSEMBLECtx(ctx context.Context, cardfile <-chan []rune, X chan<- rune) {
+	defer close(X)
+	for {
+		cardimage, ok := recv(ctx, cardfile)
+		if !ok {
+			return
+		}
+		if len(cardimage) > 80 {
+			cardimage = cardimage[:80]
+		}
+		for _, c := range cardimage {
+			if !send(ctx, X, c) {
+				return
+			}
+		}
+		if !send(ctx, X, ' ') {
+			return
+		}
+	}
+}
+
+// S34_ASSEMBLECtx is like S34_ASSEMBLE, but abandons the current line and
+// closes lineprinter once ctx is done. A partially assembled line is
+// not printed on cancellation.
+func S34_ASSEMBLECtx(ctx context.Context, X <-chan rune, lineprinter chan<- string) {
+	defer close(lineprinter)
+
+	lineimage := make([]rune, 125)
+	i := 0
+	for {
+		c, ok := recv(ctx, X)
+		if !ok {
+			break
+		}
+		lineimage[i] = c
+		i++
+		if i == 125 {
+			if !send(ctx, lineprinter, string(lineimage)) {
+				return
+			}
+			i = 0
+		}
+	}
+	if ctx.Err() != nil || i == 0 {
+		return
+	}
+	for ; i < 125; i++ {
+		lineimage[i] = ' '
+	}
+	send(ctx, lineprinter, string(lineimage))
+}
+
+// S35_ReformatCtx is like S35_Reformat, but all three processes abandon
+// their work once ctx is done, and lineprinter is closed.
+//
+//   [west::DISASSEMBLE||X:COPY||east::ASSEMBLE]
+func S35_ReformatCtx(ctx context.Context, cardfile <-chan []rune, lineprinter chan<- string) {
+	west, east := make(chan rune), make(chan rune)
+	go S33_DISASSEMBLECtx(ctx, cardfile, west)
+	go S31_COPYCtx(ctx, west, east)
+	S34_ASSEMBLECtx(ctx, east, lineprinter)
+}
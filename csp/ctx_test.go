@@ -0,0 +1,105 @@
+package csp_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/changkun/gobase/csp"
+	"github.com/changkun/gobase/leaktest"
+)
+
+func TestCtxProcesses(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		process func(west <-chan rune, east chan<- rune)
+		stream  string
+		want    string
+	}{
+		{
+			name:    "S31_COPYCtx",
+			process: func(west <-chan rune, east chan<- rune) { csp.S31_COPYCtx(ctx, west, east) },
+			stream:  "Hello, CSP.",
+			want:    "Hello, CSP.",
+		},
+		{
+			name:    "S32_SQUASHCtx",
+			process: func(west <-chan rune, east chan<- rune) { csp.S32_SQUASHCtx(ctx, west, east) },
+			stream:  "Hello,* ** *CSP.***",
+			want:    "Hello,* ↑ *CSP.↑*",
+		},
+	}
+	for _, tt := range tests {
+		west, east := make(chan rune), make(chan rune)
+		go tt.process(west, east)
+		go func(stream string) {
+			for _, c := range stream {
+				west <- c
+			}
+			close(west)
+		}(tt.stream)
+		received := []rune{}
+		for c := range east {
+			received = append(received, c)
+		}
+		if string(received) != tt.want {
+			t.Fatalf("%v: %v expected: %v, got: %v", t.Name(), tt.name, tt.want, string(received))
+		}
+	}
+
+	cardfile, lineprinter := make(chan []rune), make(chan string)
+	go csp.S35_ReformatCtx(ctx, cardfile, lineprinter)
+	go func() {
+		cardfile <- []rune("1234567890123456789012345678901234567890123456789012345678901234567890")
+		cardfile <- []rune("1234567890123456789012345678901234567890123456789012345678901234567890")
+		close(cardfile)
+	}()
+	want := []string{
+		"1234567890123456789012345678901234567890123456789012345678901234567890 123456789012345678901234567890123456789012345678901234",
+		"5678901234567890                                                                                                             ",
+	}
+	received := []string{}
+	for l := range lineprinter {
+		received = append(received, l)
+	}
+	if !reflect.DeepEqual(want, received) {
+		t.Fatalf("%v: S35_ReformatCtx expected: %v, got: %v", t.Name(), want, received)
+	}
+}
+
+func TestCtxProcessesCancel(t *testing.T) {
+	lctx, lcancel := context.WithTimeout(context.Background(), time.Second)
+	defer lcancel()
+	defer leaktest.CheckContext(lctx, t)()
+
+	tests := []struct {
+		name    string
+		process func(ctx context.Context, west <-chan rune, east chan<- rune)
+	}{
+		{name: "S31_COPYCtx", process: csp.S31_COPYCtx},
+		{name: "S32_SQUASHCtx", process: csp.S32_SQUASHCtx},
+	}
+	for _, tt := range tests {
+		// a stuck upstream never sends.
+		ctx, cancel := context.WithCancel(context.Background())
+		west, east := make(chan rune), make(chan rune)
+		go tt.process(ctx, west, east)
+		cancel()
+		if _, ok := <-east; ok {
+			t.Fatalf("%v: %v expected east to be closed", t.Name(), tt.name)
+		}
+	}
+
+	// a stuck downstream never receives a full line.
+	ctx, cancel := context.WithCancel(context.Background())
+	cardfile, lineprinter := make(chan []rune), make(chan string)
+	go csp.S35_ReformatCtx(ctx, cardfile, lineprinter)
+	cardfile <- []rune("Hello, CSP.")
+	cancel()
+	if l, ok := <-lineprinter; ok {
+		t.Fatalf("%v: S35_ReformatCtx expected lineprinter to be closed, got %v", t.Name(), l)
+	}
+}
@@ -0,0 +1,227 @@
+// Package dashboard serves a live view of the process networks of a
+// running program over HTTP, for pipelines of package csp that run
+// inside long-lived services. The view lists the live labelled
+// processes with their states, highlighting the blocked ones, the
+// occupancy of the channels of a network, and the recent events of a
+// recorder, and is fed by a WebSocket:
+//
+//   n := csp.NewNetworkController(ctx)
+//   r := trace.NewRecorder()
+//   http.Handle("/debug/csp/", http.StripPrefix("/debug/csp", dashboard.New(n, r)))
+//   n.Go(p) // under trace.NewContext for the events
+//
+// The handler serves the page at /, the WebSocket at /ws, and a single
+// snapshot as JSON at /state.
+package dashboard
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/changkun/gobase/csp"
+	"github.com/changkun/gobase/csp/trace"
+	"golang.org/x/net/websocket"
+)
+
+// Handler is the http.Handler of the dashboard.
+type Handler struct {
+	// Interval is the time between two updates of the view, one second
+	// if zero.
+	Interval time.Duration
+	// Events is the number of recent events shown, 20 if zero.
+	Events int
+
+	n *csp.NetworkController
+	r *trace.Recorder
+}
+
+// New returns the dashboard of the network n and of the events
+// recorded by r, either of which may be nil.
+func New(n *csp.NetworkController, r *trace.Recorder) *Handler {
+	return &Handler{n: n, r: r}
+}
+
+// Snapshot is the state of the view at a time.
+type Snapshot struct {
+	Time      time.Time `json:"time"`
+	Processes []Process `json:"processes"`
+	// Blocked is the number of blocked processes.
+	Blocked  int       `json:"blocked"`
+	Channels []Channel `json:"channels"`
+	// Events are the recent events, formatted as by trace.Event.
+	Events []string `json:"events"`
+}
+
+// Process is a live process of a snapshot, as by csp.Processes.
+type Process struct {
+	Label    string `json:"label"`
+	State    string `json:"state"`
+	Blocked  bool   `json:"blocked"`
+	Location string `json:"location,omitempty"`
+}
+
+// Channel is a channel of a snapshot, as by the Channels of the network.
+type Channel struct {
+	Name string `json:"name"`
+	Len  int    `json:"len"`
+	Cap  int    `json:"cap"`
+}
+
+// Snapshot returns the current state of the view.
+func (h *Handler) Snapshot() Snapshot {
+	s := Snapshot{Time: time.Now(), Processes: []Process{}, Channels: []Channel{}, Events: []string{}}
+	for _, p := range csp.Processes() {
+		blocked := p.State != csp.StateRunning
+		if blocked {
+			s.Blocked++
+		}
+		s.Processes = append(s.Processes, Process{Label: p.Label, State: p.State.String(), Blocked: blocked, Location: p.Location})
+	}
+	if h.n != nil {
+		for _, c := range h.n.Channels() {
+			s.Channels = append(s.Channels, Channel{Name: c.Name, Len: c.Len, Cap: c.Cap})
+		}
+	}
+	if h.r != nil {
+		events := h.r.Events()
+		n := h.Events
+		if n == 0 {
+			n = 20
+		}
+		if len(events) > n {
+			events = events[len(events)-n:]
+		}
+		for _, e := range events {
+			s.Events = append(s.Events, e.String())
+		}
+	}
+	return s
+}
+
+// ServeHTTP serves the page, the WebSocket or the snapshot of the
+// dashboard.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/ws":
+		h.serveWS(w, r)
+	case "/state":
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(h.Snapshot())
+	case "/", "":
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(page))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// serveWS sends a snapshot every Interval over the WebSocket of r,
+// until the client goes away. The WebSocket accepts only the clients of
+// the origin of the dashboard, and those which send no origin, which are
+// not browsers, such that the pages of other sites cannot read it.
+func (h *Handler) serveWS(w http.ResponseWriter, r *http.Request) {
+	websocket.Server{Handshake: sameOrigin, Handler: h.stream}.ServeHTTP(w, r)
+}
+
+// sameOrigin fails the handshake of r if its origin is not the host of
+// r.
+func sameOrigin(config *websocket.Config, r *http.Request) error {
+	origin, err := websocket.Origin(config, r)
+	if err != nil {
+		return err
+	}
+	if origin != nil && origin.Host != r.Host {
+		return fmt.Errorf("dashboard: origin %v is not the host %v", origin, r.Host)
+	}
+	config.Origin = origin
+	return nil
+}
+
+// stream sends the snapshots over ws.
+func (h *Handler) stream(ws *websocket.Conn) {
+	// the messages of the client are read only to notice that it went
+	// away; the pings and the close of the client are answered by ws.
+	gone := make(chan struct{})
+	go func() {
+		defer close(gone)
+		var msg []byte
+		for websocket.Message.Receive(ws, &msg) == nil {
+		}
+	}()
+
+	interval := h.Interval
+	if interval == 0 {
+		interval = time.Second
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		b, err := json.Marshal(h.Snapshot())
+		if err != nil {
+			return
+		}
+		if err := websocket.Message.Send(ws, string(b)); err != nil {
+			return
+		}
+		select {
+		case <-t.C:
+		case <-gone:
+			return
+		}
+	}
+}
+
+// page is the page of the dashboard, which renders the snapshots it
+// receives over the WebSocket.
+const page = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>csp dashboard</title>
+<style>
+body { font-family: monospace; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+td, th { padding: 0.2em 1em; text-align: left; }
+tr.blocked { color: #b00; }
+.bar { display: inline-block; height: 0.8em; background: #48c; }
+</style>
+</head>
+<body>
+<h1>csp dashboard</h1>
+<p id="status">connecting…</p>
+<h2>Processes</h2>
+<table><thead><tr><th>label</th><th>state</th><th>location</th></tr></thead><tbody id="procs"></tbody></table>
+<h2>Channels</h2>
+<table><thead><tr><th>name</th><th>occupancy</th><th></th></tr></thead><tbody id="chans"></tbody></table>
+<h2>Recent events</h2>
+<pre id="events"></pre>
+<script>
+function esc(s) {
+  return String(s).replace(/[&<>"]/g, function(c) {
+    return {"&": "&amp;", "<": "&lt;", ">": "&gt;", '"': "&quot;"}[c];
+  });
+}
+var base = location.pathname.replace(/\/?$/, "/");
+var ws = new WebSocket((location.protocol === "https:" ? "wss://" : "ws://") + location.host + base + "ws");
+ws.onclose = function() { document.getElementById("status").textContent = "disconnected"; };
+ws.onmessage = function(m) {
+  var s = JSON.parse(m.data);
+  document.getElementById("status").textContent =
+    s.processes.length + " processes, " + s.blocked + " blocked, at " + s.time;
+  document.getElementById("procs").innerHTML = s.processes.map(function(p) {
+    return '<tr class="' + (p.blocked ? "blocked" : "") + '"><td>' + esc(p.label) + "</td><td>" +
+      esc(p.state) + "</td><td>" + esc(p.location || "") + "</td></tr>";
+  }).join("");
+  document.getElementById("chans").innerHTML = s.channels.map(function(c) {
+    var w = c.cap ? Math.round(100 * c.len / c.cap) : 0;
+    return "<tr><td>" + esc(c.name) + "</td><td>" + c.len + "/" + c.cap +
+      '</td><td><span class="bar" style="width:' + w + 'px"></span></td></tr>';
+  }).join("");
+  document.getElementById("events").textContent = s.events.join("\n");
+};
+</script>
+</body>
+</html>
+`
@@ -0,0 +1,120 @@
+package dashboard_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/changkun/gobase/csp"
+	"github.com/changkun/gobase/csp/dashboard"
+	"github.com/changkun/gobase/csp/trace"
+	"golang.org/x/net/websocket"
+)
+
+// blocked starts a network whose sink never receives, and returns its
+// dashboard once the source is blocked on the full buffer.
+func blocked(t *testing.T) (*dashboard.Handler, func()) {
+	n := csp.NewNetworkController(context.Background())
+	r := trace.NewRecorder()
+	sent := make(chan struct{})
+	p := csp.Pipeline().
+		Source(func(ctx context.Context, out chan<- rune) {
+			defer close(out)
+			out <- 'a'
+			close(sent)
+			select {
+			case out <- 'b':
+			case <-ctx.Done():
+			}
+		}).Buffer(1).
+		Sink(func(ctx context.Context, in <-chan rune) {
+			<-ctx.Done()
+		})
+	r.Record(trace.Event{Kind: trace.Spawn, Process: "dashboard"})
+	n.Go(csp.Named("blocked", p))
+	<-sent
+	return dashboard.New(n, r), func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		if err := n.Shutdown(ctx); err != nil {
+			t.Fatalf("%v: unexpected error: %v", t.Name(), err)
+		}
+	}
+}
+
+func check(t *testing.T, s dashboard.Snapshot) {
+	labels := map[string]bool{}
+	for _, p := range s.Processes {
+		labels[p.Label] = true
+	}
+	if !labels["blocked"] || s.Blocked == 0 {
+		t.Fatalf("%v: expected blocked processes, got: %+v", t.Name(), s.Processes)
+	}
+	if len(s.Channels) != 1 || s.Channels[0].Len != 1 || s.Channels[0].Cap != 1 {
+		t.Fatalf("%v: expected a full channel, got: %+v", t.Name(), s.Channels)
+	}
+	if len(s.Events) != 1 || s.Events[0] != "1 dashboard spawn" {
+		t.Fatalf("%v: unexpected events: %v", t.Name(), s.Events)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	h, shutdown := blocked(t)
+	defer shutdown()
+	srv := httptest.NewServer(http.StripPrefix("/debug/csp", h))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/debug/csp/state")
+	if err != nil {
+		t.Fatalf("%v: %v", t.Name(), err)
+	}
+	var s dashboard.Snapshot
+	err = json.NewDecoder(resp.Body).Decode(&s)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatalf("%v: %v", t.Name(), err)
+	}
+	check(t, s)
+
+	resp, err = http.Get(srv.URL + "/debug/csp/")
+	if err != nil {
+		t.Fatalf("%v: %v", t.Name(), err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html") {
+		t.Fatalf("%v: unexpected page: %v %v", t.Name(), resp.Status, resp.Header.Get("Content-Type"))
+	}
+}
+
+func TestHandlerWebSocket(t *testing.T) {
+	h, shutdown := blocked(t)
+	defer shutdown()
+	h.Interval = 10 * time.Millisecond
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/ws"
+
+	ws, err := websocket.Dial(url, "", srv.URL)
+	if err != nil {
+		t.Fatalf("%v: %v", t.Name(), err)
+	}
+	defer ws.Close()
+	// two snapshots in text messages.
+	for i := 0; i < 2; i++ {
+		var s dashboard.Snapshot
+		if err := websocket.JSON.Receive(ws, &s); err != nil {
+			t.Fatalf("%v: %v", t.Name(), err)
+		}
+		check(t, s)
+	}
+
+	// the pages of other sites cannot connect.
+	if ws, err := websocket.Dial(url, "", "http://example.com"); err == nil {
+		ws.Close()
+		t.Fatalf("%v: expected a failed handshake of another origin", t.Name())
+	}
+}
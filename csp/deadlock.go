@@ -0,0 +1,186 @@
+package csp
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DeadlockError is the error of a deadlocked network, whose labelled
+// processes are all blocked on communications.
+type DeadlockError struct {
+	// Cycle is a cycle of processes of which each waits for the next,
+	// and the last for the first, if the channels of the network tell.
+	Cycle []ProcessInfo
+	// Blocked are all the labelled processes of the network, in the
+	// order they were started.
+	Blocked []ProcessInfo
+}
+
+func (e *DeadlockError) Error() string {
+	if len(e.Cycle) == 0 {
+		procs := make([]string, len(e.Blocked))
+		for i, info := range e.Blocked {
+			procs[i] = waiting(info)
+		}
+		return fmt.Sprintf("csp: deadlock of %d blocked processes: %s", len(procs), strings.Join(procs, ", "))
+	}
+	procs := make([]string, len(e.Cycle)+1)
+	for i, info := range e.Cycle {
+		procs[i] = waiting(info)
+	}
+	procs[len(e.Cycle)] = e.Cycle[0].Label
+	return "csp: deadlock: " + strings.Join(procs, " → ")
+}
+
+// waiting formats a blocked process, such as
+//
+//   west (blocked-on-send at /src/west.go:12)
+func waiting(info ProcessInfo) string {
+	return fmt.Sprintf("%s (%v at %s)", info.Label, info.State, info.Location)
+}
+
+// Deadlock returns the deadlock of the network, or nil if it is not
+// deadlocked: if it has labelled processes, and all of them are blocked
+// on communications, input, output or alternative commands.
+//
+// The runtime does not tell the channels processes wait for. The cycle
+// of a deadlock is found by the names of the channels of the network,
+// such as X→Y, which pipelines give their channels and TrackChan those
+// of other processes: a process blocked on an output waits for the
+// processes it has channels to, one blocked on an input for those it
+// has channels from, one blocked on an alternative command for both.
+// Unlabelled processes of the network are assumed to be parallel
+// commands and pipelines, which only wait for their components.
+func (n *NetworkController) Deadlock() *DeadlockError {
+	procs := map[*Running]bool{}
+	for _, r := range n.Processes() {
+		procs[r] = true
+	}
+	infos := processes(func(r *Running) bool { return procs[r] })
+	if len(infos) == 0 {
+		return nil
+	}
+	for _, info := range infos {
+		if info.State != StateSend && info.State != StateRecv && info.State != StateSelect {
+			return nil
+		}
+	}
+
+	// the processes labelled by each label, and the channels between
+	// the labels.
+	labels := map[string][]int{}
+	for i, info := range infos {
+		labels[info.Label] = append(labels[info.Label], i)
+	}
+	n.mu.Lock()
+	var links [][2]string
+	for _, c := range n.chans {
+		if src, dst, ok := strings.Cut(c.name, "→"); ok {
+			links = append(links, [2]string{src, dst})
+		}
+	}
+	n.mu.Unlock()
+	waits := make([][]int, len(infos))
+	for i, info := range infos {
+		for _, l := range links {
+			if l[0] == info.Label && info.State != StateRecv {
+				waits[i] = append(waits[i], labels[l[1]]...)
+			}
+			if l[1] == info.Label && info.State != StateSend {
+				waits[i] = append(waits[i], labels[l[0]]...)
+			}
+		}
+	}
+	return &DeadlockError{Cycle: cycle(infos, waits), Blocked: infos}
+}
+
+// cycle returns the first cycle of the graph of the processes infos, in
+// which waits are the processes each process waits for.
+func cycle(infos []ProcessInfo, waits [][]int) []ProcessInfo {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	marks := make([]int, len(infos))
+	var path []int
+	var visit func(i int) []ProcessInfo
+	visit = func(i int) []ProcessInfo {
+		marks[i] = visiting
+		path = append(path, i)
+		for _, j := range waits[i] {
+			switch marks[j] {
+			case visiting:
+				for k, p := range path {
+					if p == j {
+						c := make([]ProcessInfo, 0, len(path)-k)
+						for _, p := range path[k:] {
+							c = append(c, infos[p])
+						}
+						return c
+					}
+				}
+			case unvisited:
+				if c := visit(j); c != nil {
+					return c
+				}
+			}
+		}
+		path = path[:len(path)-1]
+		marks[i] = visited
+		return nil
+	}
+	for i := range infos {
+		if marks[i] == unvisited {
+			if c := visit(i); c != nil {
+				return c
+			}
+		}
+	}
+	return nil
+}
+
+// Watch watches the network for a deadlock every interval, until ctx is
+// done, in which case it returns ctx.Err(). It returns the deadlock
+// once the network is found deadlocked in the same state twice in a
+// row, hence with the processes blocked on the same communications:
+//
+//   go func() {
+//       if err := n.Watch(ctx, time.Second); errors.As(err, new(*csp.DeadlockError)) {
+//           log.Print(err)
+//           r.Dump(os.Stderr) // of a trace.NewFlightRecorder
+//       }
+//   }()
+func (n *NetworkController) Watch(ctx context.Context, interval time.Duration) error {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	var last *DeadlockError
+	for {
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		d := n.Deadlock()
+		if d != nil && last != nil && sameState(d.Blocked, last.Blocked) {
+			return d
+		}
+		last = d
+	}
+}
+
+// sameState reports whether the processes a and b are the same
+// goroutines in the same states.
+func sameState(a, b []ProcessInfo) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Goroutine != b[i].Goroutine || a[i].State != b[i].State || a[i].Location != b[i].Location {
+			return false
+		}
+	}
+	return true
+}
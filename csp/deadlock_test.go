@@ -0,0 +1,126 @@
+package csp_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/changkun/gobase/csp"
+)
+
+func TestNetworkControllerWatch(t *testing.T) {
+	n := csp.NewNetworkController(context.Background())
+	toEast, toWest := make(chan rune), make(chan rune)
+	n.TrackChan("west→east", toEast)
+	n.TrackChan("east→west", toWest)
+
+	// each process waits for the other to output first.
+	recv := func(ctx context.Context, in <-chan rune, out chan<- rune) error {
+		select {
+		case c := <-in:
+			out <- c
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	n.Go(csp.Named("west", csp.ProcessFunc(func(ctx context.Context) error { return recv(ctx, toWest, toEast) })))
+	n.Go(csp.Named("east", csp.ProcessFunc(func(ctx context.Context) error { return recv(ctx, toEast, toWest) })))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	defer n.Shutdown(ctx)
+	err := n.Watch(ctx, 10*time.Millisecond)
+	var d *csp.DeadlockError
+	if !errors.As(err, &d) {
+		t.Fatalf("%v: expected a DeadlockError, got: %v", t.Name(), err)
+	}
+
+	// the cycle starts with either process.
+	var cycle []string
+	for _, info := range d.Cycle {
+		cycle = append(cycle, info.Label)
+		if !strings.Contains(info.Location, "deadlock_test.go") {
+			t.Fatalf("%v: expected location in deadlock_test.go, got: %v", t.Name(), info.Location)
+		}
+	}
+	if got := strings.Join(cycle, " "); got != "west east" && got != "east west" {
+		t.Fatalf("%v: expected: %v, got: %v", t.Name(), "west east", got)
+	}
+	if msg, want := err.Error(), "csp: deadlock: "+cycle[0]+" (blocked-on-select at "; !strings.HasPrefix(msg, want) || !strings.HasSuffix(msg, " → "+cycle[0]) {
+		t.Fatalf("%v: unexpected error: %v", t.Name(), msg)
+	}
+
+	if err := n.Shutdown(ctx); err != nil {
+		t.Fatalf("%v: unexpected error: %v", t.Name(), err)
+	}
+	if d := n.Deadlock(); d != nil {
+		t.Fatalf("%v: expected no deadlock of a network that shut down, got: %v", t.Name(), d)
+	}
+}
+
+func TestNetworkControllerDeadlock(t *testing.T) {
+	n := csp.NewNetworkController(context.Background())
+	ch := make(chan int)
+	release := make(chan struct{})
+	defer close(release)
+	n.Go(csp.Named("sender", csp.ProcessFunc(func(ctx context.Context) error {
+		select {
+		case ch <- 1:
+		case <-release:
+		}
+		return nil
+	})))
+	var mu sync.Mutex
+	mu.Lock()
+	locking := n.Go(csp.Named("locker", csp.ProcessFunc(func(ctx context.Context) error {
+		mu.Lock()
+		return nil
+	})))
+
+	// a process blocked on something else than a communication may
+	// still proceed.
+	waitStates(t, map[string]csp.State{"sender": csp.StateSelect, "locker": csp.StateBlocked})
+	if d := n.Deadlock(); d != nil {
+		t.Fatalf("%v: unexpected deadlock: %v", t.Name(), d)
+	}
+
+	// without channels between them, blocked processes are a deadlock
+	// without a cycle.
+	mu.Unlock()
+	locking.Wait()
+	d := n.Deadlock()
+	if d == nil || len(d.Cycle) != 0 || len(d.Blocked) != 1 {
+		t.Fatalf("%v: expected a deadlock of 1 process without a cycle, got: %v", t.Name(), d)
+	}
+	if msg := d.Error(); !strings.HasPrefix(msg, "csp: deadlock of 1 blocked processes: sender (blocked-on-select at ") {
+		t.Fatalf("%v: unexpected error: %v", t.Name(), msg)
+	}
+}
+
+// waitStates waits for the labelled processes to be in the given
+// states.
+func waitStates(t *testing.T, want map[string]csp.State) {
+	for deadline := time.Now().Add(5 * time.Second); ; {
+		got := map[string]csp.State{}
+		for _, info := range csp.Processes() {
+			if _, ok := want[info.Label]; ok {
+				got[info.Label] = info.State
+			}
+		}
+		ok := len(got) == len(want)
+		for label, s := range want {
+			ok = ok && got[label] == s
+		}
+		if ok {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%v: expected: %v, got: %v", t.Name(), want, got)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,137 @@
+package csp
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Dot writes the topology of the process network p in the DOT language
+// of Graphviz, which renders by
+//
+//   dot -Tsvg network.dot > network.svg
+//
+// Processes are nodes, labelled as by Named, and the channels between
+// them directed edges, labelled by their element type and buffer size:
+//
+//   digraph {
+//       p1 [label="S33_DISASSEMBLE"];
+//       p2 [label="S31_COPY"];
+//       p1 -> p2 [label="rune, buffer 80"];
+//       ...
+//   }
+//
+// Dot knows the channels of the networks built by Pipeline and Array,
+// and draws the channels connecting them with their environment as
+// edges from or to a point. A labelled parallel command is a cluster of
+// its processes. The channels of any other process are unknown to Dot,
+// which draws the process as a node of its own.
+func Dot(w io.Writer, p Process) error {
+	d := &dot{w: bufio.NewWriter(w)}
+	fmt.Fprintln(d.w, "digraph {")
+	d.process(p, "", 1)
+	fmt.Fprintln(d.w, "}")
+	return d.w.Flush()
+}
+
+// dot is the state of Dot.
+type dot struct {
+	w   *bufio.Writer
+	ids int
+}
+
+// printf writes a statement indented by depth.
+func (d *dot) printf(depth int, format string, args ...interface{}) {
+	fmt.Fprintf(d.w, strings.Repeat("    ", depth)+format+"\n", args...)
+}
+
+// node writes a node labelled label, and returns its id. A point is a
+// node of the environment.
+func (d *dot) node(label string, depth int) string {
+	d.ids++
+	id := fmt.Sprintf("p%d", d.ids)
+	if label == "" {
+		d.printf(depth, "%s [shape=point];", id)
+	} else {
+		d.printf(depth, "%s [label=%s];", id, strconv.Quote(label))
+	}
+	return id
+}
+
+// process writes the nodes and edges of p, labelled label unless p is
+// labelled itself.
+func (d *dot) process(p Process, label string, depth int) {
+	if n, ok := p.(named); ok {
+		label, p = n.label, n.Process
+	}
+	if label != "" {
+		switch p.(type) {
+		case par, array, pipeline:
+			d.ids++
+			d.printf(depth, "subgraph cluster_%d {", d.ids)
+			d.printf(depth+1, "label=%s;", strconv.Quote(label))
+			defer d.printf(depth, "}")
+			depth++
+		}
+	}
+
+	switch p := p.(type) {
+	case par:
+		for _, q := range p.procs {
+			d.process(q, "", depth)
+		}
+	case array:
+		// X(i) communicates with X(i-1) and X(i+1) in both directions.
+		prev := d.node("", depth)
+		for i, q := range p.procs {
+			label := Label(q)
+			if label == "" {
+				label = fmt.Sprintf("(%d)", i+1)
+			}
+			id := d.node(label, depth)
+			d.printf(depth, "%s -> %s [label=%s, dir=both];", prev, id, strconv.Quote(p.elem.String()))
+			prev = id
+		}
+		d.printf(depth, "%s -> %s [label=%s, dir=both];", prev, d.node("", depth), strconv.Quote(p.elem.String()))
+	case pipeline:
+		var prev string
+		if p.src.IsValid() {
+			prev = d.node("", depth)
+		}
+		for i, e := range p.elems {
+			id := d.node(e.label, depth)
+			if prev != "" {
+				d.edge(prev, id, p.elems, i-1, depth)
+			}
+			prev = id
+		}
+		if p.sink.IsValid() {
+			d.edge(prev, d.node("", depth), p.elems, len(p.elems)-1, depth)
+		}
+	default:
+		if label == "" {
+			label = "process"
+		}
+		d.node(label, depth)
+	}
+}
+
+// edge writes the edge from the node from to the node to for the
+// channel the element elems[i] of a pipeline sends to, or for its
+// source if i is negative.
+func (d *dot) edge(from, to string, elems []pipelineElem, i, depth int) {
+	if i < 0 {
+		// the source channel has the element type of the first stage.
+		e := elems[0]
+		d.printf(depth, "%s -> %s [label=%s];", from, to, strconv.Quote(e.fn.Type().In(e.arg(false)).Elem().String()))
+		return
+	}
+	e := elems[i]
+	label := e.outType().String()
+	if e.buffer > 0 {
+		label += fmt.Sprintf(", buffer %d", e.buffer)
+	}
+	d.printf(depth, "%s -> %s [label=%s];", from, to, strconv.Quote(label))
+}
@@ -0,0 +1,50 @@
+package csp_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/changkun/gobase/csp"
+)
+
+func TestDot(t *testing.T) {
+	cardfile, lineprinter := make(chan []rune), make(chan string)
+	reformat := csp.Pipeline().Source(cardfile).
+		Stage(csp.S33_DISASSEMBLE).Buffer(80).Stage(csp.S31_COPY).Stage(csp.S34_ASSEMBLE).
+		Sink(lineprinter)
+	fac, _ := csp.Array(2, func(i int, left, right chan int) csp.Process {
+		return csp.Named("fac", csp.SKIP)
+	})
+
+	var b strings.Builder
+	err := csp.Dot(&b, csp.Par(csp.Named("reformat", reformat), fac, csp.Named("user", csp.SKIP)))
+	if err != nil {
+		t.Fatalf("%v: unexpected error: %v", t.Name(), err)
+	}
+	want := `digraph {
+    subgraph cluster_1 {
+        label="reformat";
+        p2 [shape=point];
+        p3 [label="S33_DISASSEMBLE"];
+        p2 -> p3 [label="[]int32"];
+        p4 [label="S31_COPY"];
+        p3 -> p4 [label="int32, buffer 80"];
+        p5 [label="S34_ASSEMBLE"];
+        p4 -> p5 [label="int32"];
+        p6 [shape=point];
+        p5 -> p6 [label="string"];
+    }
+    p7 [shape=point];
+    p8 [label="fac(1)"];
+    p7 -> p8 [label="int", dir=both];
+    p9 [label="fac(2)"];
+    p8 -> p9 [label="int", dir=both];
+    p10 [shape=point];
+    p9 -> p10 [label="int", dir=both];
+    p11 [label="user"];
+}
+`
+	if got := b.String(); got != want {
+		t.Fatalf("%v: expected:\n%v\ngot:\n%v", t.Name(), want, got)
+	}
+}
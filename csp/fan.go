@@ -0,0 +1,161 @@
+package csp
+
+import (
+	"runtime"
+
+	"github.com/changkun/gobase/csp/alt"
+)
+
+// Merge multiplexes ins into out: it repeatedly receives from whichever
+// input is ready, chosen fairly among the ready ones, and sends the
+// value to out:
+//
+//   *[(i:1..n) in(i)?v -> out!v]
+//
+// out is closed once all ins are closed.
+func Merge[T any](out chan<- T, ins ...<-chan T) {
+	x := alt.New()
+	for _, in := range ins {
+		x.Add(alt.Recv(in, func(v T) { out <- v }))
+	}
+	alt.Repeat(x)
+	close(out)
+}
+
+// DeliveryMode is the order in which Delta delivers a value to its
+// outputs.
+type DeliveryMode int
+
+const (
+	// Sequential delivers a value to one output after another, in
+	// order of the outputs:
+	//
+	//   *[in?v -> out(1)!v; out(2)!v; ...; out(n)!v]
+	Sequential DeliveryMode = iota
+	// Parallel delivers a value to all outputs in parallel, in
+	// whichever order the receivers become ready:
+	//
+	//   *[in?v -> [out(1)!v||out(2)!v||...||out(n)!v]]
+	Parallel
+)
+
+// Delta broadcasts every value received from in to all outs, as the
+// delta process of occam. A value is delivered to every output before
+// the next one is received, according to mode. All outs are closed
+// once in is closed.
+func Delta[T any](in <-chan T, mode DeliveryMode, outs ...chan<- T) {
+	var v T
+	sent := make([]bool, len(outs))
+	x := alt.New()
+	for i, out := range outs {
+		i := i
+		x.Add(alt.Send(out, func() T { return v }, func() { sent[i] = true }).
+			When(func() bool { return !sent[i] }))
+	}
+
+	for v = range in {
+		switch mode {
+		case Parallel:
+			for i := range sent {
+				sent[i] = false
+			}
+			alt.Repeat(x)
+		default:
+			for _, out := range outs {
+				out <- v
+			}
+		}
+	}
+	for _, out := range outs {
+		close(out)
+	}
+}
+
+// Farm is a farm of workers: Workers copies of the process
+//
+//   worker(i:1..n):: *[in?x -> out!Work(x)]
+//
+// share one input channel, hence a value is taken by whichever worker
+// is ready first, and their results are merged into one output
+// channel. A farm scales a costly stage of a pipeline across cores.
+type Farm[I, O any] struct {
+	// Workers is the number of workers, if not positive it defaults to
+	// runtime.GOMAXPROCS(0).
+	Workers int
+	// Work computes the result of a value.
+	Work func(I) O
+	// Ordered reports whether results are output in the order of their
+	// values. Otherwise results are output as soon as they are
+	// computed.
+	Ordered bool
+}
+
+// Run runs the farm on the values received from in and sends their
+// results to out. out is closed once in is closed and all results
+// have been sent. Run has the signature of a pipeline stage.
+func (f Farm[I, O]) Run(in <-chan I, out chan<- O) {
+	n := f.Workers
+	if n <= 0 {
+		n = runtime.GOMAXPROCS(0)
+	}
+	if !f.Ordered {
+		results := make([]<-chan O, n)
+		for i := range results {
+			res := make(chan O)
+			results[i] = res
+			go func() {
+				for x := range in {
+					res <- f.Work(x)
+				}
+				close(res)
+			}()
+		}
+		Merge(out, results...)
+		return
+	}
+
+	tagIn := make(chan tagged[I])
+	go func() {
+		i := 0
+		for x := range in {
+			tagIn <- tagged[I]{i, x}
+			i++
+		}
+		close(tagIn)
+	}()
+	results := make([]<-chan tagged[O], n)
+	for i := range results {
+		res := make(chan tagged[O])
+		results[i] = res
+		go func() {
+			for x := range tagIn {
+				res <- tagged[O]{x.i, f.Work(x.v)}
+			}
+			close(res)
+		}()
+	}
+	merged := make(chan tagged[O])
+	go Merge(merged, results...)
+
+	next := 0
+	pending := map[int]O{}
+	for r := range merged {
+		pending[r.i] = r.v
+		for {
+			v, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			out <- v
+			next++
+		}
+	}
+	close(out)
+}
+
+// tagged is a value of a Farm tagged with its position in the input.
+type tagged[T any] struct {
+	i int
+	v T
+}
@@ -0,0 +1,160 @@
+package csp_test
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+	"unicode"
+
+	"github.com/changkun/gobase/csp"
+)
+
+func TestMerge(t *testing.T) {
+	n := 5
+	ins := make([]<-chan int, n)
+	for i := 0; i < n; i++ {
+		in := make(chan int)
+		ins[i] = in
+		go func(i int, in chan<- int) {
+			for k := 0; k < 10; k++ {
+				in <- i*10 + k
+			}
+			close(in)
+		}(i, in)
+	}
+
+	out := make(chan int)
+	go csp.Merge(out, ins...)
+	got := []int{}
+	for v := range out {
+		got = append(got, v)
+	}
+	sort.Ints(got)
+	want := make([]int, n*10)
+	for i := range want {
+		want[i] = i
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Fatalf("%v: expected: %v, got: %v", t.Name(), want, got)
+	}
+}
+
+func TestMergeNone(t *testing.T) {
+	out := make(chan string)
+	go csp.Merge(out)
+	if _, ok := <-out; ok {
+		t.Fatalf("%v: expected closed output", t.Name())
+	}
+}
+
+func TestDelta(t *testing.T) {
+	for _, mode := range []csp.DeliveryMode{csp.Sequential, csp.Parallel} {
+		in := make(chan int)
+		a, b := make(chan int), make(chan int)
+		go csp.Delta(in, mode, a, b)
+		go func() {
+			for i := 0; i < 10; i++ {
+				in <- i
+			}
+			close(in)
+		}()
+
+		// a is received from concurrently, as sequential delivery
+		// blocks on a before it sends to b.
+		gotA, gotB := []int{}, []int{}
+		done := make(chan struct{})
+		go func() {
+			for v := range a {
+				gotA = append(gotA, v)
+			}
+			close(done)
+		}()
+		for v := range b {
+			gotB = append(gotB, v)
+		}
+		<-done
+
+		want := []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}
+		if !reflect.DeepEqual(want, gotA) || !reflect.DeepEqual(want, gotB) {
+			t.Fatalf("%v: mode %v, expected: %v, got: %v and %v", t.Name(), mode, want, gotA, gotB)
+		}
+	}
+}
+
+func TestDeltaParallel(t *testing.T) {
+	// with parallel delivery, b receives the value although a only
+	// receives after b.
+	in := make(chan int)
+	a, b := make(chan int), make(chan int)
+	go csp.Delta(in, csp.Parallel, a, b)
+	go func() {
+		in <- 42
+		close(in)
+	}()
+	if v := <-b; v != 42 {
+		t.Fatalf("%v: expected: %v, got: %v", t.Name(), 42, v)
+	}
+	if v := <-a; v != 42 {
+		t.Fatalf("%v: expected: %v, got: %v", t.Name(), 42, v)
+	}
+	for range a {
+	}
+	for range b {
+	}
+}
+
+func TestFarm(t *testing.T) {
+	square := func(x int) int {
+		time.Sleep(time.Duration(x%3) * time.Millisecond)
+		return x * x
+	}
+
+	for _, ordered := range []bool{false, true} {
+		in, out := make(chan int), make(chan int)
+		go csp.Farm[int, int]{Workers: 4, Work: square, Ordered: ordered}.Run(in, out)
+		go func() {
+			for i := 0; i < 100; i++ {
+				in <- i
+			}
+			close(in)
+		}()
+
+		got := []int{}
+		for v := range out {
+			got = append(got, v)
+		}
+		if !ordered {
+			sort.Ints(got)
+		}
+		want := make([]int, 100)
+		for i := range want {
+			want[i] = i * i
+		}
+		if !reflect.DeepEqual(want, got) {
+			t.Fatalf("%v: ordered %v, expected: %v, got: %v", t.Name(), ordered, want, got)
+		}
+	}
+}
+
+func TestFarmPipeline(t *testing.T) {
+	// a farm replaces COPY in [west::DISASSEMBLE||X::COPY||east::ASSEMBLE].
+	upper := csp.Farm[rune, rune]{Work: unicode.ToUpper, Ordered: true}
+	cardfile, lineprinter := make(chan []rune), make(chan string)
+	go func() {
+		cardfile <- []rune("Hello,CSP")
+		close(cardfile)
+	}()
+	go csp.Pipeline().Source(cardfile).
+		Stage(csp.S33_DISASSEMBLE).Stage(upper.Run).Stage(csp.S34_ASSEMBLE).
+		Sink(lineprinter).Run(context.Background())
+
+	got := <-lineprinter
+	if want := "HELLO,CSP "; !strings.HasPrefix(got, want) || len(got) != 125 {
+		t.Fatalf("%v: expected: %q, got: %q", t.Name(), want, got)
+	}
+	for range lineprinter {
+	}
+}
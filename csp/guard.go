@@ -0,0 +1,130 @@
+package csp
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/changkun/gobase/csp/alt"
+)
+
+// Condition is a <boolean expr> of a guard list. Expr is the source
+// text of the expression, Eval evaluates it.
+type Condition struct {
+	Expr string
+	Eval func() bool
+}
+
+// InputCmd is the <input cmd> source?target ending a guard. Chan is the
+// channel of the source process, it must be a channel that can be
+// received from; the received value is passed to the command list of
+// the guarded command.
+type InputCmd struct {
+	Source string
+	Target string
+	Chan   interface{}
+}
+
+// Guard is the guard of a guarded command:
+//
+//   <guard>             ::= <guard list> | <guard list>;<input cmd> | <input cmd>
+//   <guard list>        ::= <guard elem> {; <guard elem>}
+//
+// A guard fails if any of its conditions is false, or if the source
+// process of its input command has terminated. A guard without input
+// command is ready as soon as all its conditions hold.
+type Guard struct {
+	List  []Condition
+	Input *InputCmd
+}
+
+// GuardedCommand is a guarded command:
+//
+//   <guarded cmd>       ::= <guard> → <cmd list>
+//
+// Cmd is the source text of the command list, Exec executes it with
+// the value received by the input command of the guard, or nil if the
+// guard has none.
+type GuardedCommand struct {
+	Guard Guard
+	Cmd   string
+	Exec  func(v interface{})
+}
+
+// Alternative is an alternative command:
+//
+//   <alternative cmd>   ::= [<guarded cmd> { □ <guarded cmd> }]
+//
+// It is a plain description of the command, which can be inspected and
+// printed as well as executed.
+type Alternative []GuardedCommand
+
+// Select executes the alternative command: it executes one of the
+// guarded commands whose guard is ready, chosen at random, and returns
+// its index. Select returns false if all guards fail.
+func (a Alternative) Select() (int, bool) {
+	return a.alt().Select()
+}
+
+// Repeat executes the repetitive command *a until all guards fail, and
+// returns the number of iterations.
+func (a Alternative) Repeat() int {
+	return alt.Repeat(a.alt())
+}
+
+// String formats the alternative command in the notation of the paper.
+func (a Alternative) String() string {
+	cmds := make([]string, len(a))
+	for i, gc := range a {
+		cmds[i] = gc.String()
+	}
+	return "[" + strings.Join(cmds, " □ ") + "]"
+}
+
+// String formats the guarded command in the notation of the paper.
+func (gc GuardedCommand) String() string {
+	return fmt.Sprintf("%v → %v", gc.Guard, gc.Cmd)
+}
+
+// String formats the guard in the notation of the paper.
+func (g Guard) String() string {
+	elems := make([]string, 0, len(g.List)+1)
+	for _, c := range g.List {
+		elems = append(elems, c.Expr)
+	}
+	if g.Input != nil {
+		elems = append(elems, g.Input.Source+"?"+g.Input.Target)
+	}
+	return strings.Join(elems, "; ")
+}
+
+// enabled reports whether all conditions of the guard list hold.
+func (g Guard) enabled() bool {
+	for _, c := range g.List {
+		if !c.Eval() {
+			return false
+		}
+	}
+	return true
+}
+
+// alt returns the executable alternative command of a.
+func (a Alternative) alt() *alt.Alt {
+	x := alt.New()
+	for _, gc := range a {
+		gc := gc
+		exec := func(v interface{}) {
+			if gc.Exec != nil {
+				gc.Exec(v)
+			}
+		}
+
+		var g *alt.Guard
+		if gc.Guard.Input == nil {
+			g = alt.Ready(func() { exec(nil) })
+		} else {
+			g = alt.RecvAny(gc.Guard.Input.Chan, exec)
+		}
+		x.Add(g.When(gc.Guard.enabled))
+	}
+	return x
+}
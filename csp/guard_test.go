@@ -0,0 +1,87 @@
+package csp_test
+
+import (
+	"testing"
+
+	"github.com/changkun/gobase/csp"
+)
+
+func TestAlternativeString(t *testing.T) {
+	west := make(chan rune)
+	a := csp.Alternative{
+		{
+			Guard: csp.Guard{
+				List:  []csp.Condition{{Expr: "i < 10"}},
+				Input: &csp.InputCmd{Source: "west", Target: "c", Chan: west},
+			},
+			Cmd: "east!c",
+		},
+		{
+			Guard: csp.Guard{List: []csp.Condition{{Expr: "i = 10"}}},
+			Cmd:   "skip",
+		},
+	}
+	if want := "[i < 10; west?c → east!c □ i = 10 → skip]"; a.String() != want {
+		t.Fatalf("%v: expected: %v, got: %v", t.Name(), want, a.String())
+	}
+}
+
+func TestAlternativeRepeat(t *testing.T) {
+	// *[i < 3; west?c -> sum := sum+c; i := i+1 □ i = 3 -> i := 4]
+	west := make(chan int)
+	go func() {
+		for i := 1; i <= 5; i++ {
+			west <- i
+		}
+		close(west)
+	}()
+
+	i, sum := 0, 0
+	a := csp.Alternative{
+		{
+			Guard: csp.Guard{
+				List:  []csp.Condition{{Expr: "i < 3", Eval: func() bool { return i < 3 }}},
+				Input: &csp.InputCmd{Source: "west", Target: "c", Chan: west},
+			},
+			Exec: func(v interface{}) {
+				sum += v.(int)
+				i++
+			},
+		},
+		{
+			Guard: csp.Guard{List: []csp.Condition{{Expr: "i = 3", Eval: func() bool { return i == 3 }}}},
+			Exec:  func(interface{}) { i = 4 },
+		},
+	}
+	if n := a.Repeat(); n != 4 {
+		t.Fatalf("%v: expected: %v iterations, got: %v", t.Name(), 4, n)
+	}
+	if sum != 6 {
+		t.Fatalf("%v: expected: %v, got: %v", t.Name(), 6, sum)
+	}
+	for range west {
+	}
+}
+
+func TestAlternativeTerminated(t *testing.T) {
+	west := make(chan rune)
+	close(west)
+	a := csp.Alternative{
+		{Guard: csp.Guard{Input: &csp.InputCmd{Source: "west", Target: "c", Chan: west}}},
+	}
+	if i, ok := a.Select(); ok {
+		t.Fatalf("%v: expected alternative to fail, got guard %v", t.Name(), i)
+	}
+}
+
+func TestAlternativeInvalidInput(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil {
+			t.Fatalf("%v: expected panic on send-only channel", t.Name())
+		}
+	}()
+	var east chan<- rune = make(chan rune)
+	csp.Alternative{
+		{Guard: csp.Guard{Input: &csp.InputCmd{Source: "east", Target: "c", Chan: east}}},
+	}.Select()
+}
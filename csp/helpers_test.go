@@ -0,0 +1,59 @@
+package csp_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// BlockingSink returns a channel that simulates a stalled downstream
+// consumer: sends on ch block until release is called, afterwards all
+// runes are consumed until ch is closed. release may be called any
+// number of times, including before any send.
+func BlockingSink() (ch chan rune, release func()) {
+	ch = make(chan rune)
+	released := make(chan struct{})
+	once := sync.Once{}
+	go func() {
+		<-released
+		for range ch {
+		}
+	}()
+	return ch, func() { once.Do(func() { close(released) }) }
+}
+
+func TestBlockingSink(t *testing.T) {
+	sink, release := BlockingSink()
+
+	sent := make(chan struct{})
+	go func() {
+		for _, c := range "Hello, CSP." {
+			sink <- c
+		}
+		close(sink)
+		close(sent)
+	}()
+
+	select {
+	case <-sent:
+		t.Fatalf("%v: producer proceeded before release", t.Name())
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	release()
+	release()
+	select {
+	case <-sent:
+	case <-time.After(time.Second):
+		t.Fatalf("%v: producer still blocked after release", t.Name())
+	}
+
+	sink, release = BlockingSink()
+	release()
+	select {
+	case sink <- 'x':
+		close(sink)
+	case <-time.After(time.Second):
+		t.Fatalf("%v: producer blocked although released before send", t.Name())
+	}
+}
@@ -0,0 +1,453 @@
+package lang
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/changkun/gobase/csp/lts"
+)
+
+// DefaultMaxStates is the number of states an Analyzer explores at
+// most, unless its MaxStates is set.
+const DefaultMaxStates = 100000
+
+// Analyzer explores the states a program may reach, as run by an
+// Interpreter with a Schedule, without running it: from the start of
+// the program, the states every step a Schedule may choose takes it
+// to, breadth first. The steps it reports leading to a state are as few
+// as possible, and of those the first in the order of the steps, as
+// formatted, such that they are the same whatever the order of the
+// processes.
+//
+// The analysis reports the deadlocks among these states, and the
+// divergences: cycles of steps the program may take forever without
+// communicating with an external name, whether its processes
+// communicate with each other or select guards without input. A
+// program without external names diverges whenever it runs forever.
+//
+// The analysis is bounded: it explores at most MaxStates states, and
+// reports whether it explored all reachable states. The processes of
+// the program communicate with external names as with an Interpreter,
+// except that an external source inputs the values of Inputs, and an
+// external destination accepts any output.
+//
+// The analysis abstracts from time: a wait command is as skip, and a
+// timeout as a guard without input, which the program may select at
+// any time. It abstracts from probabilities as well: a probabilistic
+// choice is as a choice among guards without input.
+type Analyzer struct {
+	Program *Program
+
+	// Inputs are the values the external sources of the program
+	// input, in order. An exhausted source, or one which is not in
+	// Inputs, behaves as a terminated process.
+	Inputs map[string][]Value
+
+	// MaxStates is the number of states to explore at most,
+	// DefaultMaxStates if zero.
+	MaxStates int
+
+	hidden map[string]bool // the external names hidden by a definition
+}
+
+// Analysis is the result of an Analyzer.
+type Analysis struct {
+	// States is the number of states explored.
+	States int
+	// Complete reports whether all states the program may reach were
+	// explored.
+	Complete bool
+	// Deadlocks are the deadlocks the program may reach, in which
+	// processes wait for communications no other process offers, in
+	// the order they were found.
+	Deadlocks []Deadlock
+	// Divergences are the divergences the program may reach, one for
+	// every set of states the program may cycle through without
+	// communicating with an external name, in the order they were
+	// found.
+	Divergences []Divergence
+}
+
+// Deadlock is a deadlock a program may reach.
+type Deadlock struct {
+	// Trace is the steps leading from the start of the program to the
+	// deadlock.
+	Trace []Step
+	// Procs are the states of the processes in the deadlock, in the
+	// order they started, the first is the program itself. The Cmd of
+	// a process is the command it waits at, the parallel command of
+	// one waiting for its processes.
+	Procs []ProcState
+}
+
+// String formats d, such as
+//
+//   deadlock after X→Y: 1
+//   	Y at Z!n: n = 1
+//   	Z at Y!0
+func (d Deadlock) String() string {
+	lines := []string{"deadlock at start"}
+	if len(d.Trace) > 0 {
+		lines[0] = "deadlock after " + joinSteps(d.Trace)
+	}
+	for _, s := range d.Procs {
+		if !s.Done {
+			lines = append(lines, "\t"+s.String())
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Schedule returns a Schedule which takes the steps of d.Trace in turn,
+// such that the program run by an Interpreter with the same inputs
+// runs into the deadlock. It fails the run if the next step is none of
+// those the program may take.
+func (d Deadlock) Schedule() func([]Step) int {
+	steps := d.Trace
+	return func(next []Step) int {
+		if len(steps) == 0 {
+			return -1
+		}
+		want := steps[0].String()
+		steps = steps[1:]
+		for i, s := range next {
+			if s.String() == want {
+				return i
+			}
+		}
+		return -1
+	}
+}
+
+// Divergence is a divergence a program may reach, a livelock.
+type Divergence struct {
+	// Trace is the steps leading from the start of the program to the
+	// cycle.
+	Trace []Step
+	// Cycle is the steps the program may repeat forever, none of which
+	// communicates with an external name.
+	Cycle []Step
+}
+
+// String formats d, such as
+//
+//   divergence after west→X repeating X→Y: 1, Y: n > 0
+func (d Divergence) String() string {
+	s := "divergence at start"
+	if len(d.Trace) > 0 {
+		s = "divergence after " + joinSteps(d.Trace)
+	}
+	return s + " repeating " + joinSteps(d.Cycle)
+}
+
+// joinSteps formats the steps of a trace.
+func joinSteps(trace []Step) string {
+	steps := make([]string, len(trace))
+	for i, s := range trace {
+		steps[i] = s.String()
+	}
+	return strings.Join(steps, ", ")
+}
+
+// Analyze explores the states of the program until it explored them
+// all, MaxStates of them, or ctx is done.
+func (a *Analyzer) Analyze(ctx context.Context) (*Analysis, error) {
+	nodes, complete, err := a.explore(ctx)
+	if err != nil {
+		return nil, err
+	}
+	an := &Analysis{States: len(nodes), Complete: complete}
+	paths := newPaths(nodes)
+	for i, n := range nodes {
+		if n.deadlock != nil {
+			an.Deadlocks = append(an.Deadlocks, Deadlock{Trace: paths.to(i), Procs: n.deadlock})
+		}
+	}
+	for _, l := range loops(nodes) {
+		an.Divergences = append(an.Divergences, Divergence{Trace: paths.to(l.at), Cycle: l.steps})
+	}
+	return an, nil
+}
+
+// LTS returns the labelled transition system of the program, whose
+// states are those Analyze explores, the initial state the start of
+// the program. Its events are the communications with external names,
+// such as west.'a' for the input of 'a' from west, or east.'a' for its
+// output to east, its other steps are internal. Once the program
+// terminated, it engages in lts.Tick to a state of its own, whereas a
+// program that failed, as one that deadlocked, has no transitions. LTS
+// fails if the program has more than MaxStates states.
+func (a *Analyzer) LTS(ctx context.Context) (*lts.LTS, error) {
+	nodes, complete, err := a.explore(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !complete {
+		return nil, fmt.Errorf("lang: program has more than %d states", a.maxStates())
+	}
+	return transitions(nodes), nil
+}
+
+// transitions returns the transition system of the explored nodes.
+func transitions(nodes []xnode) *lts.LTS {
+	l := &lts.LTS{Trans: make([][]lts.Transition, len(nodes))}
+	end := -1
+	for i, n := range nodes {
+		seen := map[lts.Transition]bool{}
+		for _, e := range n.edges {
+			t := lts.Transition{Event: e.step.event(), To: e.to}
+			if !seen[t] {
+				seen[t] = true
+				l.Trans[i] = append(l.Trans[i], t)
+			}
+		}
+		if n.done {
+			if end < 0 {
+				end = len(l.Trans)
+				l.Trans = append(l.Trans, nil)
+			}
+			l.Trans[i] = append(l.Trans[i], lts.Transition{Event: lts.Tick, To: end})
+		}
+	}
+	return l
+}
+
+func (a *Analyzer) maxStates() int {
+	if a.MaxStates <= 0 {
+		return DefaultMaxStates
+	}
+	return a.MaxStates
+}
+
+// explore explores the states of the program breadth first, at most
+// MaxStates of them, and reports whether it explored all of them.
+func (a *Analyzer) explore(ctx context.Context) ([]xnode, bool, error) {
+	e, err := newExplorer(a.Program, a.Inputs)
+	if err != nil {
+		return nil, false, err
+	}
+	st := e.initial()
+	nodes := []xnode{{st: st}}
+	seen := map[string]int{e.key(st): 0}
+	complete := true
+	for i := 0; i < len(nodes); i++ {
+		if err := ctx.Err(); err != nil {
+			return nil, false, err
+		}
+		st := nodes[i].st
+		nodes[i].st = nil
+		steps := e.steps(st)
+		switch {
+		case st.procs[0].status == xdone:
+			nodes[i].done = true
+		case len(steps) == 0 && !st.failed():
+			nodes[i].deadlock = st.states()
+		}
+		for _, s := range steps {
+			s.hidden = a.hides(s)
+			next := e.take(st, s)
+			k := e.key(next)
+			j, ok := seen[k]
+			if !ok {
+				if len(nodes) == a.maxStates() {
+					complete = false
+					continue
+				}
+				j = len(nodes)
+				seen[k] = j
+				nodes = append(nodes, xnode{st: next})
+			}
+			nodes[i].edges = append(nodes[i].edges, xedge{to: j, step: s})
+		}
+	}
+	return nodes, complete, nil
+}
+
+// hides reports whether s communicates with an external name hidden
+// by a, whatever its subscripts.
+func (a *Analyzer) hides(s xstep) bool {
+	if s.r >= 0 || s.step.Guard != "" {
+		return false
+	}
+	name := s.step.Comm.Dst
+	if s.step.Comm.Value == nil {
+		name = s.step.Comm.Src
+	}
+	name, _, _ = strings.Cut(name, "(")
+	return a.hidden[name]
+}
+
+// xnode is a state explored by an Analyzer, with the steps leading from
+// it to other states.
+type xnode struct {
+	st       *xstate
+	edges    []xedge
+	done     bool        // the program terminated
+	deadlock []ProcState // the states of the processes of a deadlock
+}
+
+// xedge is a step to the node to.
+type xedge struct {
+	to   int
+	step xstep
+}
+
+// event returns the event of s in a transition system.
+func (s xstep) event() string {
+	c := s.step.Comm
+	switch {
+	case !s.external():
+		return lts.Tau
+	case c.Value == nil:
+		return c.Src + "." + Format(s.v)
+	}
+	return c.Dst + "." + Format(c.Value)
+}
+
+// paths finds the smallest paths to the explored nodes, as a shrinking
+// pass over the paths the exploration found.
+type paths struct {
+	nodes []xnode
+	into  [][]int // the nodes with edges into every node
+}
+
+func newPaths(nodes []xnode) *paths {
+	p := &paths{nodes: nodes, into: make([][]int, len(nodes))}
+	for i, n := range nodes {
+		for _, e := range n.edges {
+			p.into[e.to] = append(p.into[e.to], i)
+		}
+	}
+	return p
+}
+
+// to returns the steps leading from the start to the node i: as few as
+// possible, and of those the first in the order of the steps.
+func (p *paths) to(i int) []Step {
+	// the number of steps from every node to i, breadth first
+	// backwards from i, up to the start.
+	dist := map[int]int{i: 0}
+	for queue := []int{i}; len(queue) > 0; {
+		j := queue[0]
+		queue = queue[1:]
+		if j == 0 {
+			break
+		}
+		for _, k := range p.into[j] {
+			if _, ok := dist[k]; !ok {
+				dist[k] = dist[j] + 1
+				queue = append(queue, k)
+			}
+		}
+	}
+
+	// the smallest step closer to i, from the start on.
+	var steps []Step
+	for j := 0; j != i; {
+		var next *xedge
+		for k, e := range p.nodes[j].edges {
+			if d, ok := dist[e.to]; ok && d == dist[j]-1 && (next == nil || e.step.step.String() < next.step.step.String()) {
+				next = &p.nodes[j].edges[k]
+			}
+		}
+		steps = append(steps, next.step.step)
+		j = next.to
+	}
+	return steps
+}
+
+// loop is a cycle of internal steps from the node at to itself.
+type loop struct {
+	at    int
+	steps []Step
+}
+
+// loops returns a cycle of internal steps of every set of nodes
+// strongly connected by them, the shortest from the first node of the
+// set, in the order of their first nodes.
+func loops(nodes []xnode) []loop {
+	// Tarjan's algorithm.
+	index := make([]int, len(nodes))
+	low := make([]int, len(nodes))
+	on := make([]bool, len(nodes))
+	var stack []int
+	var loops []loop
+	n := 0
+	var visit func(i int)
+	visit = func(i int) {
+		n++
+		index[i], low[i] = n, n
+		stack = append(stack, i)
+		on[i] = true
+		for _, e := range nodes[i].edges {
+			if e.step.external() {
+				continue
+			}
+			switch {
+			case index[e.to] == 0:
+				visit(e.to)
+				low[i] = min(low[i], low[e.to])
+			case on[e.to]:
+				low[i] = min(low[i], index[e.to])
+			}
+		}
+		if low[i] != index[i] {
+			return
+		}
+		in := map[int]bool{}
+		first := i
+		for j := -1; j != i; {
+			j = stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			on[j] = false
+			in[j] = true
+			first = min(first, j)
+		}
+		if steps := cycle(nodes, first, in); steps != nil {
+			loops = append(loops, loop{at: first, steps: steps})
+		}
+	}
+	for i := range nodes {
+		if index[i] == 0 {
+			visit(i)
+		}
+	}
+	sort.Slice(loops, func(i, j int) bool { return loops[i].at < loops[j].at })
+	return loops
+}
+
+// cycle returns the steps of the shortest cycle of internal steps from
+// the node first to itself through the nodes in, nil if there is none.
+func cycle(nodes []xnode, first int, in map[int]bool) []Step {
+	// breadth first, from the edge each node was reached by, from the
+	// node to.
+	from := map[int]xedge{}
+	queue := []int{first}
+	for len(queue) > 0 {
+		i := queue[0]
+		queue = queue[1:]
+		for _, e := range nodes[i].edges {
+			if _, ok := from[e.to]; ok || !in[e.to] || e.step.external() {
+				continue
+			}
+			from[e.to] = xedge{to: i, step: e.step}
+			queue = append(queue, e.to)
+		}
+		if _, ok := from[first]; ok {
+			break
+		}
+	}
+	if _, ok := from[first]; !ok {
+		return nil
+	}
+	var steps []Step
+	for j := first; ; {
+		e := from[j]
+		steps = append([]Step{e.step.step}, steps...)
+		if j = e.to; j == first {
+			return steps
+		}
+	}
+}
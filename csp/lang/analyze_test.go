@@ -0,0 +1,333 @@
+package lang_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/changkun/gobase/csp/lang"
+	"github.com/changkun/gobase/csp/lts"
+)
+
+// philosophers is Section 5.3, the dining philosophers, n of them,
+// whose room admits at most seats of them.
+func philosophers(n, seats int) string {
+	return strings.NewReplacer("{n}", fmt.Sprint(n), "{m}", fmt.Sprint(n-1), "{seats}", fmt.Sprint(seats)).Replace(`PHIL = (*[true →
+	  room!enter();
+	  fork(i)!pickup(); fork((i+1) mod {n})!pickup();
+	  fork(i)!putdown(); fork((i+1) mod {n})!putdown();
+	  room!exit()])
+	FORK = (*[phil(i)?pickup() → phil(i)?putdown()
+	  □ phil((i+{m}) mod {n})?pickup() → phil((i+{m}) mod {n})?putdown()])
+	ROOM = (occupancy:integer; occupancy := 0;
+	  *[(i:0..{m})occupancy < {seats}; phil(i)?enter() → occupancy := occupancy+1
+	  □ (i:0..{m})phil(i)?exit() → occupancy := occupancy-1])
+	[room::ROOM||fork(i:0..{m})::FORK||phil(i:0..{m})::PHIL]`)
+}
+
+func TestAnalyzerDeadlock(t *testing.T) {
+	prog, err := lang.Parse(philosophers(3, 3))
+	if err != nil {
+		t.Fatalf("%v: %v", t.Name(), err)
+	}
+	an, err := (&lang.Analyzer{Program: prog}).Analyze(context.Background())
+	if err != nil {
+		t.Fatalf("%v: unexpected error: %v", t.Name(), err)
+	}
+	if !an.Complete || len(an.Deadlocks) != 1 {
+		t.Fatalf("%v: expected a single deadlock of a complete analysis, got: %v of %d states, complete: %v",
+			t.Name(), an.Deadlocks, an.States, an.Complete)
+	}
+
+	// all philosophers entered the room and picked up their left
+	// fork, in as few steps as possible.
+	d := an.Deadlocks[0]
+	if len(d.Trace) != 9 {
+		t.Fatalf("%v: expected: %v steps, got: %v", t.Name(), 9, d.Trace)
+	}
+	states := map[string]string{}
+	for _, s := range d.Procs {
+		states[s.Label] = s.String()
+	}
+	want := map[string]string{
+		"room":    "room at *[(i:0..2) occupancy < 3; phil(i)?enter() → occupancy := occupancy + 1 □ (i:0..2) phil(i)?exit() → occupancy := occupancy - 1]: occupancy = 3",
+		"phil(0)": "phil(0) at fork((i + 1) mod 3)!pickup(): i = 0",
+		"fork(1)": "fork(1) at phil(i)?putdown(): i = 1",
+	}
+	for label, want := range want {
+		if got := states[label]; got != want {
+			t.Fatalf("%v: %v: expected: %v, got: %v", t.Name(), label, want, got)
+		}
+	}
+
+	// the interpreter runs into the deadlock by the steps of the
+	// trace, which fails the first deadlocked process to notice, and
+	// cancels the others.
+	_, err = runScheduled(context.Background(), d.Schedule(), philosophers(3, 3))
+	if err == nil || !strings.Contains(err.Error(), ": deadlock") {
+		t.Fatalf("%v: expected: deadlock, got: %v", t.Name(), err)
+	}
+}
+
+func TestAnalyzerNoDeadlock(t *testing.T) {
+	tests := []struct {
+		src string
+		in  []lang.Value
+	}{
+		{src: philosophers(3, 2)},
+		{src: solutions(t, "S31_COPY")["S31_COPY"], in: chars("CSP")},
+		{src: "[X::Y!1 || Y::n:integer; Y?n]"},
+		{src: "[X::Y!1 || Y::Z!2 || Z::skip]"},
+	}
+	for _, tt := range tests {
+		prog, err := lang.Parse(tt.src)
+		if err != nil {
+			t.Fatalf("%v: %v", t.Name(), err)
+		}
+		an, err := (&lang.Analyzer{Program: prog, Inputs: map[string][]lang.Value{"west": tt.in}}).Analyze(context.Background())
+		if err != nil {
+			t.Fatalf("%v: %q: unexpected error: %v", t.Name(), tt.src, err)
+		}
+		if !an.Complete || len(an.Deadlocks) != 0 {
+			t.Fatalf("%v: %q: expected no deadlock, got: %v, complete: %v", t.Name(), tt.src, an.Deadlocks, an.Complete)
+		}
+	}
+}
+
+func TestAnalyzerTrace(t *testing.T) {
+	prog, err := lang.Parse(`[X::Y!1; Y!2; Z!3
+	||Y::n:integer; *[X?n → east!n]
+	||Z::n:integer; X?n; W?n
+	||W::n:integer; Z?n]`)
+	if err != nil {
+		t.Fatalf("%v: %v", t.Name(), err)
+	}
+	an, err := (&lang.Analyzer{Program: prog}).Analyze(context.Background())
+	if err != nil {
+		t.Fatalf("%v: unexpected error: %v", t.Name(), err)
+	}
+	if len(an.Deadlocks) != 1 {
+		t.Fatalf("%v: expected a deadlock, got: %v", t.Name(), an.Deadlocks)
+	}
+	// of the shortest traces, the first in the order of the steps, as
+	// X→Z precedes Y→east.
+	want := "deadlock after X→Y: 1, Y→east: 1, X→Y: 2, X→Z: 3, Y→east: 2\n" +
+		"\tprogram at [X::Y!1; Y!2; Z!3 || Y::n:integer; *[X?n → east!n] || Z::n:integer; X?n; W?n || W::n:integer; Z?n]\n" +
+		"\tZ at W?n: n = 3\n" +
+		"\tW at Z?n: n = undefined"
+	if got := an.Deadlocks[0].String(); got != want {
+		t.Fatalf("%v: expected:\n%v\ngot:\n%v", t.Name(), want, got)
+	}
+}
+
+func TestAnalyzerMaxStates(t *testing.T) {
+	prog, err := lang.Parse(philosophers(3, 3))
+	if err != nil {
+		t.Fatalf("%v: %v", t.Name(), err)
+	}
+	an, err := (&lang.Analyzer{Program: prog, MaxStates: 100}).Analyze(context.Background())
+	if err != nil {
+		t.Fatalf("%v: unexpected error: %v", t.Name(), err)
+	}
+	if an.Complete || an.States != 100 || len(an.Deadlocks) != 0 {
+		t.Fatalf("%v: expected an incomplete analysis of %d states, got: %d states, complete: %v, deadlocks: %v",
+			t.Name(), 100, an.States, an.Complete, an.Deadlocks)
+	}
+}
+
+func TestAnalyzerErrors(t *testing.T) {
+	prog, err := lang.Parse("P = (skip) P = (skip) P")
+	if err != nil {
+		t.Fatalf("%v: %v", t.Name(), err)
+	}
+	_, err = (&lang.Analyzer{Program: prog}).Analyze(context.Background())
+	if want := "1:12: process P redefined"; err == nil || err.Error() != want {
+		t.Fatalf("%v: expected: %v, got: %v", t.Name(), want, err)
+	}
+}
+
+func TestAnalyzerDivergence(t *testing.T) {
+	tests := []struct {
+		src  string
+		in   []lang.Value
+		want []string
+	}{
+		{
+			src: `[X::c:character; west?c; *[c ≠ 'z' → Y!c]
+			||Y::c:character; *[X?c → skip]]`,
+			in: chars("a"),
+			// Y input 'a' before the cycle.
+			want: []string{"divergence after west→X, X: c ≠ 'z', X→Y: 'a' repeating X: c ≠ 'z', X→Y: 'a'"},
+		},
+		{
+			src:  "*[true → skip]",
+			want: []string{"divergence at start repeating program: true"},
+		},
+		{
+			src: "*[true → east!1]",
+		},
+		{
+			src: solutions(t, "S31_COPY")["S31_COPY"],
+			in:  chars("CSP"),
+		},
+	}
+	for _, tt := range tests {
+		prog, err := lang.Parse(tt.src)
+		if err != nil {
+			t.Fatalf("%v: %v", t.Name(), err)
+		}
+		an, err := (&lang.Analyzer{Program: prog, Inputs: map[string][]lang.Value{"west": tt.in}}).Analyze(context.Background())
+		if err != nil {
+			t.Fatalf("%v: %q: unexpected error: %v", t.Name(), tt.src, err)
+		}
+		var got []string
+		for _, d := range an.Divergences {
+			got = append(got, d.String())
+		}
+		if !an.Complete || strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+			t.Fatalf("%v: %q: expected: %v, got: %v, complete: %v", t.Name(), tt.src, tt.want, got, an.Complete)
+		}
+	}
+}
+
+func TestAnalyzerLTS(t *testing.T) {
+	prog, err := lang.Parse(solutions(t, "S31_COPY")["S31_COPY"])
+	if err != nil {
+		t.Fatalf("%v: %v", t.Name(), err)
+	}
+	a := &lang.Analyzer{Program: prog, Inputs: map[string][]lang.Value{"west": chars("ab")}}
+	l, err := a.LTS(context.Background())
+	if err != nil {
+		t.Fatalf("%v: unexpected error: %v", t.Name(), err)
+	}
+	var b strings.Builder
+	if err := lts.Dot(&b, l); err != nil {
+		t.Fatalf("%v: unexpected error: %v", t.Name(), err)
+	}
+	want := `digraph {
+    init [shape=point];
+    init -> 0;
+    0 -> 1 [label="west.'a'"];
+    1 -> 2 [label="east.'a'"];
+    2 -> 3 [label="west.'b'"];
+    3 -> 4 [label="east.'b'"];
+    4 -> 5 [label="✓"];
+}
+`
+	if got := b.String(); got != want {
+		t.Fatalf("%v: expected:\n%v\ngot:\n%v", t.Name(), want, got)
+	}
+
+	// internal steps are τ transitions.
+	prog, err = lang.Parse("[X::Y!1; east!2 || Y::n:integer; X?n]")
+	if err != nil {
+		t.Fatalf("%v: %v", t.Name(), err)
+	}
+	l, err = (&lang.Analyzer{Program: prog}).LTS(context.Background())
+	if err != nil {
+		t.Fatalf("%v: unexpected error: %v", t.Name(), err)
+	}
+	want = "[[{τ 1}] [{east.2 2}] [{✓ 3}] []]"
+	if got := fmt.Sprint(l.Trans); got != want {
+		t.Fatalf("%v: expected: %v, got: %v", t.Name(), want, got)
+	}
+
+	a.MaxStates = 3
+	if _, err := a.LTS(context.Background()); err == nil || err.Error() != "lang: program has more than 3 states" {
+		t.Fatalf("%v: expected: more than 3 states, got: %v", t.Name(), err)
+	}
+}
+
+func TestAnalyzerRefines(t *testing.T) {
+	// SPEC inputs any characters and outputs the squashed ones, in any
+	// order.
+	spec := func(out string) string {
+		n := len([]rune(out))
+		return fmt.Sprintf(`[W::*[c:character; west?c → skip]
+		||E::out:(1..%d)character; out := %q;
+		   i:integer; i := 1; *[i ≤ %d → east!out(i); i := i+1]]`, n, out, n)
+	}
+	ltsOf := func(src string) *lts.LTS {
+		prog, err := lang.Parse(src)
+		if err != nil {
+			t.Fatalf("%v: %v", t.Name(), err)
+		}
+		l, err := (&lang.Analyzer{Program: prog, Inputs: map[string][]lang.Value{"west": chars("a**b*")}}).LTS(context.Background())
+		if err != nil {
+			t.Fatalf("%v: unexpected error: %v", t.Name(), err)
+		}
+		return l
+	}
+	squash := ltsOf(solutions(t, "S32_SQUASH_EX")["S32_SQUASH_EX"])
+	if c := lts.Refines(ltsOf(spec("a↑b*")), squash); c != nil {
+		t.Fatalf("%v: unexpected counterexample: %v", t.Name(), c)
+	}
+	c := lts.Refines(ltsOf(spec("a**b*")), squash)
+	if want := "after west.'a', east.'a', west.'*', west.'*': east.'↑'"; c == nil || c.String() != want {
+		t.Fatalf("%v: expected: %v, got: %v", t.Name(), want, c)
+	}
+}
+
+func TestAnalyzerDeadlockFree(t *testing.T) {
+	tests := []struct {
+		src        string
+		fails, fds string
+	}{
+		// a deadlock refuses even to terminate.
+		{src: philosophers(3, 3), fails: "after ⟨⟩: refuses {✓}", fds: "after ⟨⟩: diverges"},
+		// the philosophers dine forever, without external events.
+		{src: philosophers(3, 2), fds: "after ⟨⟩: diverges"},
+		{src: "[X::Y!1; east!2 || Y::n:integer; X?n]"},
+		{src: "[X::Y!1; east!2 || Y::n:integer; X?n; X?n]", fails: "after east.2: refuses {east.2, ✓}", fds: "after east.2: refuses {east.2, ✓}"},
+	}
+	for _, tt := range tests {
+		prog, err := lang.Parse(tt.src)
+		if err != nil {
+			t.Fatalf("%v: %v", t.Name(), err)
+		}
+		l, err := (&lang.Analyzer{Program: prog}).LTS(context.Background())
+		if err != nil {
+			t.Fatalf("%v: unexpected error: %v", t.Name(), err)
+		}
+		df := lts.DF(l.Alphabet())
+		for _, c := range []struct {
+			got  *lts.Counterexample
+			want string
+		}{{lts.RefinesFailures(df, l), tt.fails}, {lts.RefinesFD(df, l), tt.fds}} {
+			got := ""
+			if c.got != nil {
+				got = c.got.String()
+			}
+			if got != c.want {
+				t.Fatalf("%v: %q: expected: %q, got: %q", t.Name(), tt.src, c.want, got)
+			}
+		}
+	}
+}
+
+func TestAnalyzerBisimilar(t *testing.T) {
+	ltsOf := func(src string) *lts.LTS {
+		prog, err := lang.Parse(src)
+		if err != nil {
+			t.Fatalf("%v: %v", t.Name(), err)
+		}
+		l, err := (&lang.Analyzer{Program: prog, Inputs: map[string][]lang.Value{"west": chars("ab")}}).LTS(context.Background())
+		if err != nil {
+			t.Fatalf("%v: unexpected error: %v", t.Name(), err)
+		}
+		return l
+	}
+	// relay copies by a process of its own, waiting for it to output
+	// every character.
+	copy := ltsOf(solutions(t, "S31_COPY")["S31_COPY"])
+	relay := ltsOf(`[X::*[c:character; west?c → Y!c; Y?done()]
+	||Y::*[c:character; X?c → east!c; X!done()]]`)
+	if d := lts.WeaklyBisimilar(copy, relay); d != nil {
+		t.Fatalf("%v: unexpected difference: %v", t.Name(), d)
+	}
+	want := "⟨west.'a'⟩⟨east.'a'⟩true"
+	if d := lts.Bisimilar(copy, relay); d == nil || d.String() != want {
+		t.Fatalf("%v: expected: %v, got: %v", t.Name(), want, d)
+	}
+}
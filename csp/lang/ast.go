@@ -0,0 +1,512 @@
+package lang
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Node is a node of the syntax tree of a program.
+type Node interface {
+	// Pos returns the position of the first token of the node.
+	Pos() Pos
+}
+
+// Stmt is an element of a command list, a command or a declaration.
+type Stmt interface {
+	Node
+	stmt()
+}
+
+// Cmd is a command.
+type Cmd interface {
+	Stmt
+	cmd()
+}
+
+// Expr is an expression.
+type Expr interface {
+	Node
+	expr()
+}
+
+// Type is the type of a declaration.
+type Type interface {
+	Node
+	typ()
+}
+
+// Program is a program: a command list, preceded by the definitions of
+// the processes it refers to by name, and the assertions about them.
+//
+//   <program>           ::= {<definition> | <assertion>} <cmd list>
+type Program struct {
+	Defs    []*Definition
+	Asserts []*Assertion
+	Body    *CmdList
+}
+
+// Definition defines a named process, which a command list refers to
+// by its name, as in [west::DISASSEMBLE||X::COPY||east::ASSEMBLE]:
+//
+//   <definition>        ::= <identifier> = ( <cmd list> ) [<hiding>]
+//   <hiding>            ::= \ ( <identifier> {, <identifier>} )
+//
+// The hiding of a definition conceals the communications of the
+// process with the named external names, as with log in
+//
+//   COPY = (*[c:character; west?c → log!c; east!c]) \ (log)
+//
+// They are internal steps of the process as a program on its own, as
+// its assertions verify it. A process referring to the
+// definition by its name executes its body only.
+type Definition struct {
+	NamePos Pos
+	Name    string
+	Body    *CmdList
+	Hidden  []*Ident // the hidden names, none without hiding
+}
+
+// Assertion asserts a property of a defined process, or that the
+// process Impl refines the process Proc in a model of CSP, as in FDR,
+// which an Analyzer verifies:
+//
+//   <assertion>         ::= assert <identifier> :[ <property> ]
+//                         | assert <identifier> [ <model> = <identifier> ]
+//   <property>          ::= deadlock free | divergence free | deterministic
+//   <model>             ::= T | F | FD
+type Assertion struct {
+	Assert   Pos
+	ProcPos  Pos
+	Proc     string
+	Property string // empty for a refinement
+	Model    string // T, F or FD, of a refinement
+	ImplPos  Pos
+	Impl     string
+}
+
+// CmdList is a command list:
+//
+//   <cmd list>          ::= {<declaration>; | <cmd>; } <cmd>
+type CmdList struct {
+	Start Pos
+	Stmts []Stmt
+}
+
+// Declaration declares variables of a type:
+//
+//   <declaration>       ::= <identifier> {, <identifier>} : <type>
+type Declaration struct {
+	Names []*Ident
+	Type  Type
+}
+
+// NamedType is a simple type such as integer, character or boolean.
+type NamedType struct {
+	NamePos Pos
+	Name    string
+}
+
+// ArrayType is an array type such as (1..80)character.
+type ArrayType struct {
+	Lparen Pos
+	Lo, Hi Expr
+	Elem   Type
+}
+
+// SkipCmd is the command skip, which has no effect.
+type SkipCmd struct {
+	Skip Pos
+}
+
+// WaitCmd is a wait command, which delays the process by Duration units
+// of time:
+//
+//   <wait cmd>          ::= wait <expr>
+type WaitCmd struct {
+	Wait     Pos
+	Duration Expr
+}
+
+// AssignmentCmd is an assignment command:
+//
+//   <assignment cmd>    ::= <target var> := <expr>
+type AssignmentCmd struct {
+	Target Expr
+	Value  Expr
+}
+
+// InputCmd is an input command:
+//
+//   <input cmd>         ::= <source> ? <target var>
+type InputCmd struct {
+	Source *ProcName
+	Target Expr
+}
+
+// OutputCmd is an output command:
+//
+//   <output cmd>        ::= <destination> ! <expr>
+type OutputCmd struct {
+	Dest  *ProcName
+	Value Expr
+}
+
+// ProcName names the source or destination of a communication:
+//
+//   <proc name>         ::= <identifier> | <identifier> ( <subscripts> )
+type ProcName struct {
+	NamePos    Pos
+	Name       string
+	Subscripts []Expr
+}
+
+// ParallelCmd is a parallel command:
+//
+//   <parallel cmd>      ::= [<proc>{||<proc>}] | [<proc>{|||<proc>}]
+//
+// The processes of an interleaving, separated by |||, run in parallel
+// without naming each other: a process name of one of them names a
+// process outside the command, with which it communicates as the
+// process executing the command, or an external name, as both copies
+// of
+//
+//   [X::COPY ||| Y::COPY]
+//
+// input from west and output to east, in any order.
+//
+// Implicit is set for a program consisting of labelled processes
+// without the enclosing brackets, such as X::*[...].
+type ParallelCmd struct {
+	Lbrack     Pos
+	Procs      []*Proc
+	Implicit   bool
+	Interleave bool // the processes are separated by |||
+}
+
+// Proc is a process of a parallel command:
+//
+//   <proc>              ::= <proc label> <cmd list>
+type Proc struct {
+	Label *ProcLabel // nil for an empty label
+	Body  *CmdList
+}
+
+// ProcLabel is the label of a process, each subscript is either an
+// expression or a Range:
+//
+//   <proc label>        ::= <empty> | <identifier> :: | <identifier>(<label subscript>{,<label subscript>}) ::
+type ProcLabel struct {
+	NamePos    Pos
+	Name       string
+	Subscripts []Expr
+}
+
+// Range is the range of a bound variable:
+//
+//   <range>             ::= <bound variable>:<lower bound>..<upper bound>
+type Range struct {
+	VarPos Pos
+	Var    string
+	Lo, Hi Expr
+}
+
+// AlternativeCmd is an alternative command:
+//
+//   <alternative cmd>   ::= [<guarded cmd> { □ <guarded cmd> }]
+type AlternativeCmd struct {
+	Lbrack Pos
+	Cmds   []*GuardedCmd
+}
+
+// RepetitiveCmd is a repetitive command:
+//
+//   <repetitive cmd>    ::= * <alternative cmd>
+type RepetitiveCmd struct {
+	Star Pos
+	Alt  *AlternativeCmd
+}
+
+// GuardedCmd is a guarded command:
+//
+//   <guarded cmd>       ::= <guard> → <cmd list> | ( <range> {, <range> }) <guard> → <cmd list>
+type GuardedCmd struct {
+	Ranges []*Range
+	Guard  *Guard
+	Body   *CmdList
+}
+
+// Guard is a guard, each element of its list is either a boolean
+// expression or a *Declaration:
+//
+//   <guard>             ::= <guard list> | <guard list>;<input cmd> | <input cmd>
+//                         | <guard list>;<timeout> | <timeout>
+//                         | <guard list>;<prob> | <prob>
+//   <guard list>        ::= <guard elem> {; <guard elem>}
+type Guard struct {
+	Start   Pos
+	List    []Node
+	Input   *InputCmd
+	Timeout *Timeout
+	Prob    *Prob
+}
+
+// Timeout is the timeout of a guard, which is ready once Duration units
+// of time passed since its alternative command started, unless another
+// guard was selected first. The alternative command
+//
+//   [west?c → east!c □ timeout 5 → east!'?']
+//
+// is the timeout operator P [5> Q of Timed CSP, of the input of P and
+// the output of Q:
+//
+//   <timeout>           ::= timeout <expr>
+type Timeout struct {
+	Timeout  Pos
+	Duration Expr
+}
+
+// Prob is the weight of a guard of a probabilistic choice, an
+// alternative command whose guards all have weights. The process
+// selects one of its guards whose boolean guards are true and whose
+// weight is positive, at random, as likely as its weight, such as the
+// output of 1 three times out of four in
+//
+//   [prob 3 → east!1 □ prob 1 → east!0]
+//
+// as of the operator P [3 ⊕ 1] Q:
+//
+//   <prob>              ::= prob <expr>
+type Prob struct {
+	Prob   Pos
+	Weight Expr
+}
+
+// ProcRef is a command referring to a defined process by its name,
+// such as COPY in [west::DISASSEMBLE||X::COPY||east::ASSEMBLE]:
+//
+//   <proc ref>          ::= <identifier> | <identifier> [[ <renaming> {, <renaming>} ]]
+//
+// A reference with renamings executes the body of the definition as if
+// the names it communicates with were renamed, such as west to in and
+// east to out in X::COPY[[west <- in, east <- out]]. The names of the
+// processes of the parallel commands of the body are not renamed.
+type ProcRef struct {
+	NamePos Pos
+	Name    string
+	Renames []*Renaming
+}
+
+// Renaming renames the process or external name From to To:
+//
+//   <renaming>          ::= <identifier> <- <identifier>
+type Renaming struct {
+	From, To *Ident
+}
+
+// Ident is an identifier.
+type Ident struct {
+	NamePos Pos
+	Name    string
+}
+
+// IntLit is an integer literal.
+type IntLit struct {
+	ValuePos Pos
+	Value    int
+}
+
+// CharLit is a character literal such as 'a'.
+type CharLit struct {
+	ValuePos Pos
+	Value    rune
+}
+
+// StringLit is a string literal such as "Hello, CSP", a shorthand for
+// an array of characters.
+type StringLit struct {
+	ValuePos Pos
+	Value    string
+}
+
+// ParenExpr is a parenthesized expression.
+type ParenExpr struct {
+	Lparen Pos
+	X      Expr
+}
+
+// UnaryExpr is a unary expression such as -x or ¬b.
+type UnaryExpr struct {
+	OpPos Pos
+	Op    Token
+	X     Expr
+}
+
+// BinaryExpr is a binary expression such as x+y.
+type BinaryExpr struct {
+	X     Expr
+	OpPos Pos
+	Op    Token
+	Y     Expr
+}
+
+// StructuredExpr is a structured expression or target, or a subscripted
+// array element, which the notation does not distinguish:
+//
+//   <structured expr>   ::= <constructor> ( <expr list> )
+//   <constructor>       ::= <identifier> | <empty>
+type StructuredExpr struct {
+	Start       Pos
+	Constructor string
+	Args        []Expr
+}
+
+func (n *Program) Pos() Pos {
+	switch {
+	case len(n.Defs) > 0 && (len(n.Asserts) == 0 || n.Defs[0].Pos().Offset < n.Asserts[0].Pos().Offset):
+		return n.Defs[0].Pos()
+	case len(n.Asserts) > 0:
+		return n.Asserts[0].Pos()
+	}
+	return n.Body.Pos()
+}
+func (n *Definition) Pos() Pos { return n.NamePos }
+func (n *Assertion) Pos() Pos  { return n.Assert }
+func (n *CmdList) Pos() Pos    { return n.Start }
+func (n *Declaration) Pos() Pos {
+	return n.Names[0].Pos()
+}
+func (n *NamedType) Pos() Pos     { return n.NamePos }
+func (n *ArrayType) Pos() Pos     { return n.Lparen }
+func (n *SkipCmd) Pos() Pos       { return n.Skip }
+func (n *WaitCmd) Pos() Pos       { return n.Wait }
+func (n *AssignmentCmd) Pos() Pos { return n.Target.Pos() }
+func (n *InputCmd) Pos() Pos      { return n.Source.Pos() }
+func (n *OutputCmd) Pos() Pos     { return n.Dest.Pos() }
+func (n *ProcName) Pos() Pos      { return n.NamePos }
+func (n *ParallelCmd) Pos() Pos   { return n.Lbrack }
+func (n *Proc) Pos() Pos {
+	if n.Label != nil {
+		return n.Label.Pos()
+	}
+	return n.Body.Pos()
+}
+func (n *ProcLabel) Pos() Pos      { return n.NamePos }
+func (n *Range) Pos() Pos          { return n.VarPos }
+func (n *AlternativeCmd) Pos() Pos { return n.Lbrack }
+func (n *RepetitiveCmd) Pos() Pos  { return n.Star }
+func (n *GuardedCmd) Pos() Pos {
+	if len(n.Ranges) > 0 {
+		return n.Ranges[0].Pos()
+	}
+	return n.Guard.Pos()
+}
+func (n *Guard) Pos() Pos          { return n.Start }
+func (n *Timeout) Pos() Pos        { return n.Timeout }
+func (n *Prob) Pos() Pos           { return n.Prob }
+func (n *ProcRef) Pos() Pos        { return n.NamePos }
+func (n *Renaming) Pos() Pos       { return n.From.Pos() }
+func (n *Ident) Pos() Pos          { return n.NamePos }
+func (n *IntLit) Pos() Pos         { return n.ValuePos }
+func (n *CharLit) Pos() Pos        { return n.ValuePos }
+func (n *StringLit) Pos() Pos      { return n.ValuePos }
+func (n *ParenExpr) Pos() Pos      { return n.Lparen }
+func (n *UnaryExpr) Pos() Pos      { return n.OpPos }
+func (n *BinaryExpr) Pos() Pos     { return n.X.Pos() }
+func (n *StructuredExpr) Pos() Pos { return n.Start }
+
+func (*Declaration) stmt()    {}
+func (*SkipCmd) stmt()        {}
+func (*WaitCmd) stmt()        {}
+func (*AssignmentCmd) stmt()  {}
+func (*InputCmd) stmt()       {}
+func (*OutputCmd) stmt()      {}
+func (*ParallelCmd) stmt()    {}
+func (*AlternativeCmd) stmt() {}
+func (*RepetitiveCmd) stmt()  {}
+func (*ProcRef) stmt()        {}
+
+func (*SkipCmd) cmd()        {}
+func (*WaitCmd) cmd()        {}
+func (*AssignmentCmd) cmd()  {}
+func (*InputCmd) cmd()       {}
+func (*OutputCmd) cmd()      {}
+func (*ParallelCmd) cmd()    {}
+func (*AlternativeCmd) cmd() {}
+func (*RepetitiveCmd) cmd()  {}
+func (*ProcRef) cmd()        {}
+
+func (*Range) expr()          {}
+func (*Ident) expr()          {}
+func (*IntLit) expr()         {}
+func (*CharLit) expr()        {}
+func (*StringLit) expr()      {}
+func (*ParenExpr) expr()      {}
+func (*UnaryExpr) expr()      {}
+func (*BinaryExpr) expr()     {}
+func (*StructuredExpr) expr() {}
+
+func (*NamedType) typ() {}
+func (*ArrayType) typ() {}
+
+// Fprint prints the syntax tree of n to w, one field per line and
+// without positions. It is meant for debugging and testing.
+func Fprint(w io.Writer, n Node) error {
+	p := &printer{w: w}
+	p.print(reflect.ValueOf(n), 0)
+	p.printf("\n")
+	return p.err
+}
+
+type printer struct {
+	w   io.Writer
+	err error
+}
+
+func (p *printer) printf(format string, args ...interface{}) {
+	if p.err == nil {
+		_, p.err = fmt.Fprintf(p.w, format, args...)
+	}
+}
+
+var posType = reflect.TypeOf(Pos{})
+
+func (p *printer) print(v reflect.Value, depth int) {
+	indent := strings.Repeat("  ", depth+1)
+	switch v.Kind() {
+	case reflect.Interface, reflect.Ptr:
+		if v.IsNil() {
+			p.printf("nil")
+			return
+		}
+		p.print(v.Elem(), depth)
+	case reflect.Struct:
+		p.printf("%s", v.Type().Name())
+		for i := 0; i < v.NumField(); i++ {
+			f := v.Field(i)
+			if f.Type() == posType || f.IsZero() {
+				continue
+			}
+			p.printf("\n%s%s: ", indent, v.Type().Field(i).Name)
+			p.print(f, depth+1)
+		}
+	case reflect.Slice:
+		p.printf("[%d]", v.Len())
+		for i := 0; i < v.Len(); i++ {
+			p.printf("\n%s- ", indent)
+			p.print(v.Index(i), depth+1)
+		}
+	case reflect.String:
+		p.printf("%s", strconv.Quote(v.String()))
+	case reflect.Int32:
+		p.printf("%s", strconv.QuoteRune(rune(v.Int())))
+	default:
+		if t, ok := v.Interface().(Token); ok {
+			p.printf("%v", t)
+			return
+		}
+		p.printf("%v", v.Interface())
+	}
+}
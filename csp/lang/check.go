@@ -0,0 +1,457 @@
+package lang
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Check verifies the static rules of the paper for prog, which the
+// Interpreter otherwise reports only once it executes the offending
+// command:
+//
+//   - the labels of the processes of a parallel command are unique,
+//   - a process names another process by its label, with as many
+//     subscripts, and never names itself,
+//   - variables, including the bound variables of ranges, are used
+//     within their scope only,
+//   - every output to a process has the type of one of the inputs of
+//     that process from its source.
+//   - the durations of wait commands and timeouts, and the weights of
+//     guards, are integers.
+//   - the guards of an alternative command all have weights, or none.
+//   - assertions refer to defined processes.
+//   - a definition hides only names in the alphabet of its process, as
+//     by AlphabetOf.
+//
+// A name that is not the label of any enclosing process is external,
+// as for Externals. A definition is checked where a process refers to
+// it, as if its body was written in place of the reference. Check
+// returns the errors of prog as an ErrorList sorted by position, or
+// nil.
+func Check(prog *Program) error {
+	c := &checker{defs: map[string]*Definition{}, expanding: map[string]bool{}, seen: map[string]bool{}}
+	for _, d := range prog.Defs {
+		if _, ok := c.defs[d.Name]; ok {
+			c.errorf(d, "process %s redefined", d.Name)
+			continue
+		}
+		c.defs[d.Name] = d
+	}
+	for _, d := range prog.Defs {
+		if len(d.Hidden) == 0 {
+			continue
+		}
+		names := alphabet(d.Body, c.defs, map[string]bool{d.Name: true})
+		for _, id := range d.Hidden {
+			if !contains(names, id.Name) {
+				c.errorf(id, "process %s hides %s, which it does not communicate with", d.Name, id.Name)
+			}
+		}
+	}
+	for _, a := range prog.Asserts {
+		names := []*Ident{{NamePos: a.ProcPos, Name: a.Proc}}
+		if a.Impl != "" {
+			names = append(names, &Ident{NamePos: a.ImplPos, Name: a.Impl})
+		}
+		for _, id := range names {
+			if c.defs[id.Name] == nil {
+				c.errorf(id, "undefined process %s", id.Name)
+			}
+		}
+	}
+	c.list(prog.Body, newScope(nil), nil)
+	return sortErrors(c.errs)
+}
+
+// checker is the state of Check.
+type checker struct {
+	defs      map[string]*Definition
+	expanding map[string]bool // definitions being checked in place
+	errs      []*Error
+	seen      map[string]bool // errors already reported
+}
+
+// level is a process of a parallel command and the processes it may
+// name: those of its own command and of the enclosing levels, or the
+// body of a definition referred to with renamings, which rename the
+// names of the levels it encloses.
+type level struct {
+	outer  *level
+	self   *ProcLabel // nil for a process without a label
+	labels map[string]*ProcLabel
+	links  map[[2]string]*comms
+	ref    *ProcRef // of the body of a definition
+}
+
+// comms are the inputs and outputs over the link from one process of a
+// parallel command to another.
+type comms struct {
+	outputs, inputs []typed
+}
+
+// typed is a communication and the type of its value, empty if not
+// known statically.
+type typed struct {
+	n   Node
+	typ string
+}
+
+// errorf reports an error at n, once per position.
+func (c *checker) errorf(n Node, format string, args ...interface{}) {
+	e := &Error{Pos: n.Pos(), Msg: fmt.Sprintf(format, args...)}
+	if key := e.Error(); !c.seen[key] {
+		c.seen[key] = true
+		c.errs = append(c.errs, e)
+	}
+}
+
+func (c *checker) list(l *CmdList, s *scope, lv *level) {
+	for _, st := range l.Stmts {
+		c.stmt(st, s, lv)
+	}
+}
+
+func (c *checker) stmt(st Stmt, s *scope, lv *level) {
+	switch st := st.(type) {
+	case *Declaration:
+		c.declare(st, s)
+	case *AssignmentCmd:
+		c.target(st.Target, s)
+		c.expr(st.Value, s)
+	case *InputCmd:
+		c.target(st.Target, s)
+		c.comm(st.Source, s, lv, false, c.typeOf(st.Target, s))
+	case *OutputCmd:
+		c.expr(st.Value, s)
+		c.comm(st.Dest, s, lv, true, c.typeOf(st.Value, s))
+	case *WaitCmd:
+		c.integer(st.Duration, s, "duration")
+	case *ParallelCmd:
+		c.parallel(st, s, lv)
+	case *AlternativeCmd:
+		c.alternative(st, s, lv)
+	case *RepetitiveCmd:
+		c.alternative(st.Alt, s, lv)
+	case *ProcRef:
+		d, ok := c.defs[st.Name]
+		if !ok {
+			c.errorf(st, "undefined process %s", st.Name)
+			return
+		}
+		if c.expanding[d.Name] {
+			return
+		}
+		c.expanding[d.Name] = true
+		if len(st.Renames) > 0 {
+			lv = &level{outer: lv, ref: st}
+		}
+		c.list(d.Body, s, lv)
+		c.expanding[d.Name] = false
+	}
+}
+
+// parallel checks the processes of cmd, each in a scope of its own,
+// and then the types of their communications.
+func (c *checker) parallel(cmd *ParallelCmd, s *scope, lv *level) {
+	labels, links := map[string]*ProcLabel{}, map[[2]string]*comms{}
+	keys := map[string]bool{}
+	for _, proc := range cmd.Procs {
+		if proc.Label == nil {
+			continue
+		}
+		if _, ok := labels[proc.Label.Name]; !ok {
+			labels[proc.Label.Name] = proc.Label
+		}
+		key, ok := labelKey(proc.Label)
+		if ok && keys[key] {
+			c.errorf(proc.Label, "duplicate process label %s", key)
+		}
+		keys[key] = true
+	}
+	if cmd.Interleave {
+		// the processes name none of each other.
+		labels = nil
+	}
+	for _, proc := range cmd.Procs {
+		ps := newScope(s)
+		if proc.Label != nil {
+			for _, e := range proc.Label.Subscripts {
+				if r, ok := e.(*Range); ok {
+					c.bind(r, s, ps)
+				} else {
+					c.expr(e, s)
+				}
+			}
+		}
+		c.list(proc.Body, ps, &level{outer: lv, self: proc.Label, labels: labels, links: links})
+	}
+	for key, l := range links {
+		c.match(key, l)
+	}
+}
+
+// labelKey returns the name of a process labelled l, such as X(1), and
+// whether its subscripts are constant.
+func labelKey(l *ProcLabel) (string, bool) {
+	if len(l.Subscripts) == 0 {
+		return l.Name, true
+	}
+	subs := make([]string, len(l.Subscripts))
+	for i, e := range l.Subscripts {
+		if _, ok := e.(*Range); ok {
+			return "", false
+		}
+		v, err := Eval(e)
+		if err != nil {
+			return "", false
+		}
+		subs[i] = Format(v)
+	}
+	return l.Name + "(" + strings.Join(subs, ",") + ")", true
+}
+
+// comm checks the process name n of an input or output, and records the
+// communication with the type typ of its value on the link to or from
+// the process n names.
+func (c *checker) comm(n *ProcName, s *scope, lv *level, output bool, typ string) {
+	for _, e := range n.Subscripts {
+		c.expr(e, s)
+	}
+	for ; lv != nil; lv = lv.outer {
+		if lv.ref != nil {
+			if name := renamed([]*ProcRef{lv.ref}, n.Name); name != n.Name {
+				n = &ProcName{NamePos: n.NamePos, Name: name, Subscripts: n.Subscripts}
+			}
+			continue
+		}
+		l, ok := lv.labels[n.Name]
+		if !ok {
+			continue
+		}
+		if len(n.Subscripts) != len(l.Subscripts) {
+			c.errorf(n, "process %s takes %d subscripts, found %d", n.Name, len(l.Subscripts), len(n.Subscripts))
+			return
+		}
+		self := ""
+		if lv.self != nil {
+			self = lv.self.Name
+			if names(lv.self, n) {
+				c.errorf(n, "process %s names itself", Source(n))
+				return
+			}
+		}
+		key := [2]string{n.Name, self}
+		if output {
+			key = [2]string{self, n.Name}
+		}
+		link, ok := lv.links[key]
+		if !ok {
+			link = &comms{}
+			lv.links[key] = link
+		}
+		if output {
+			link.outputs = append(link.outputs, typed{n, typ})
+		} else {
+			link.inputs = append(link.inputs, typed{n, typ})
+		}
+		return
+	}
+}
+
+// names reports whether n names the process labelled l: the same name
+// with the same subscripts, where a range is its bound variable.
+func names(l *ProcLabel, n *ProcName) bool {
+	if l.Name != n.Name || len(l.Subscripts) != len(n.Subscripts) {
+		return false
+	}
+	for i, e := range l.Subscripts {
+		if r, ok := e.(*Range); ok {
+			e = &Ident{Name: r.Var}
+		}
+		if Source(e) != Source(n.Subscripts[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// match reports the outputs over the link key whose types match none
+// of the known types of its inputs.
+func (c *checker) match(key [2]string, l *comms) {
+	var ins []string
+	for _, in := range l.inputs {
+		if in.typ != "" && !contains(ins, in.typ) {
+			ins = append(ins, in.typ)
+		}
+	}
+	if len(ins) == 0 {
+		return
+	}
+	for _, out := range l.outputs {
+		if out.typ != "" && !contains(ins, out.typ) {
+			c.errorf(out.n, "%s outputs %s to %s, which inputs %s", key[0], out.typ, key[1], strings.Join(ins, " or "))
+		}
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, x := range list {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
+
+// alternative checks the guarded commands of alt, whose guards all have
+// weights or none.
+func (c *checker) alternative(alt *AlternativeCmd, s *scope, lv *level) {
+	weighted := 0
+	for _, gc := range alt.Cmds {
+		if gc.Guard.Prob != nil {
+			weighted++
+		}
+		c.guarded(gc, s, lv)
+	}
+	if weighted > 0 && weighted < len(alt.Cmds) {
+		c.errorf(alt, "guards with and without weights")
+	}
+}
+
+// guarded checks gc in a scope of its own, where its ranges bind their
+// variables.
+func (c *checker) guarded(gc *GuardedCmd, s *scope, lv *level) {
+	gs := newScope(s)
+	for _, r := range gc.Ranges {
+		c.bind(r, s, gs)
+	}
+	for _, n := range gc.Guard.List {
+		switch n := n.(type) {
+		case *Declaration:
+			c.declare(n, gs)
+		case Expr:
+			c.expr(n, gs)
+		}
+	}
+	if in := gc.Guard.Input; in != nil {
+		c.stmt(in, gs, lv)
+	}
+	if t := gc.Guard.Timeout; t != nil {
+		c.integer(t.Duration, gs, "duration")
+	}
+	if p := gc.Guard.Prob; p != nil {
+		c.integer(p.Weight, gs, "weight")
+	}
+	c.list(gc.Body, gs, lv)
+}
+
+// integer checks e, the duration of a wait command or timeout, or the
+// weight of a guard, as what.
+func (c *checker) integer(e Expr, s *scope, what string) {
+	c.expr(e, s)
+	if typ := c.typeOf(e, s); typ != "" && typ != "integer" {
+		c.errorf(e, "%s %s of type %s is not an integer", what, Source(e), typ)
+	}
+}
+
+// bind checks the bounds of r in s, and declares its bound variable, an
+// integer, in inner.
+func (c *checker) bind(r *Range, s, inner *scope) {
+	c.expr(r.Lo, s)
+	c.expr(r.Hi, s)
+	if _, ok := inner.vars[r.Var]; ok {
+		c.errorf(r, "%s redeclared", r.Var)
+	}
+	inner.vars[r.Var] = &variable{typ: &NamedType{NamePos: r.VarPos, Name: "integer"}, bound: true}
+}
+
+func (c *checker) declare(d *Declaration, s *scope) {
+	switch t := d.Type.(type) {
+	case *NamedType:
+		if _, ok := types[t.Name]; !ok {
+			c.errorf(t, "undefined type %s", t.Name)
+		}
+	case *ArrayType:
+		c.expr(t.Lo, s)
+		c.expr(t.Hi, s)
+		if elem, ok := t.Elem.(*NamedType); !ok {
+			c.errorf(t.Elem, "arrays of arrays are not supported")
+		} else if _, ok := types[elem.Name]; !ok {
+			c.errorf(elem, "undefined type %s", elem.Name)
+		}
+	}
+	for _, id := range d.Names {
+		if _, ok := s.vars[id.Name]; ok {
+			c.errorf(id, "%s redeclared", id.Name)
+		}
+		s.vars[id.Name] = &variable{typ: d.Type}
+	}
+}
+
+// expr checks that the variables of e are in scope. The constructor of
+// a structured expression which is not an array is that of a structured
+// value.
+func (c *checker) expr(e Expr, s *scope) {
+	Inspect(e, func(n Node) bool {
+		if id, ok := n.(*Ident); ok && s.lookup(id.Name) == nil {
+			if _, ok := constants[id.Name]; !ok {
+				c.errorf(id, "undefined variable %s", id.Name)
+			}
+		}
+		return true
+	})
+}
+
+// target checks the target of an assignment or input, which must not
+// be a bound variable.
+func (c *checker) target(e Expr, s *scope) {
+	c.expr(e, s)
+	if id, ok := e.(*Ident); ok {
+		if x := s.lookup(id.Name); x != nil && x.bound {
+			c.errorf(id, "cannot assign to bound variable %s", id.Name)
+		}
+	}
+}
+
+// typeOf returns the name of the type of e, as by typeName, or the
+// empty string if it is not known statically.
+func (c *checker) typeOf(e Expr, s *scope) string {
+	switch e := e.(type) {
+	case *IntLit:
+		return "integer"
+	case *CharLit:
+		return "character"
+	case *StringLit:
+		return "array of character"
+	case *Ident:
+		if x := s.lookup(e.Name); x != nil {
+			return typeName(x.typ)
+		}
+		switch constants[e.Name].(type) {
+		case bool:
+			return "boolean"
+		case rune:
+			return "character"
+		}
+	case *ParenExpr:
+		return c.typeOf(e.X, s)
+	case *StructuredExpr:
+		if x := s.lookup(e.Constructor); x != nil {
+			if t, ok := x.typ.(*ArrayType); ok {
+				return typeName(t.Elem)
+			}
+		}
+	case *UnaryExpr:
+		if e.Op == NOT {
+			return "boolean"
+		}
+		return "integer"
+	case *BinaryExpr:
+		switch e.Op {
+		case ADD, SUB, MUL, DIV, MOD:
+			return "integer"
+		}
+		return "boolean"
+	}
+	return ""
+}
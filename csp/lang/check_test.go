@@ -0,0 +1,156 @@
+package lang_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/changkun/gobase/csp/lang"
+)
+
+func TestCheck(t *testing.T) {
+	progs := solutions(t, "S31_COPY", "S32_SQUASH", "S32_SQUASH_EX",
+		"S33_DISASSEMBLE", "S34_ASSEMBLE", "S35_Reformat", "S36_ConwayProblem")
+	copyBody := strings.SplitN(progs["S31_COPY"], "::", 2)[1]
+	defs := "DISASSEMBLE = (" + progs["S33_DISASSEMBLE"] + ")\n" +
+		"COPY = (" + copyBody + ")\n" +
+		"SQUASH = (" + strings.SplitN(progs["S32_SQUASH"], "::", 2)[1] + ")\n" +
+		"ASSEMBLE = (" + progs["S34_ASSEMBLE"] + ")\n"
+	progs["S35_Reformat"] = defs + progs["S35_Reformat"]
+	progs["S36_ConwayProblem"] = defs + progs["S36_ConwayProblem"]
+	progs["fac"] = `USER = (fac(1)!3; r:integer; fac(1)?r)
+	limit:integer; limit := 3;
+	[fac(i:1..limit)::
+	 *[n:integer;fac(i-1)?n ->
+	   [n=0 -> fac(i-1)!1
+	   □ n>0 -> fac(i+1)!n-1;
+	     r:integer; fac(i+1)?r; fac(i-1)!(n*r)
+	 ]] || fac(0)::USER ]`
+
+	for name, src := range progs {
+		prog, err := lang.Parse(src)
+		if err != nil {
+			t.Fatalf("%v: %v: %v", t.Name(), name, err)
+		}
+		if err := lang.Check(prog); err != nil {
+			t.Fatalf("%v: %v: unexpected error: %v", t.Name(), name, err)
+		}
+	}
+}
+
+func TestCheckErrors(t *testing.T) {
+	tests := []struct {
+		src  string
+		want []string
+	}{
+		{
+			src:  "[X::skip || Y::skip || X::skip]",
+			want: []string{"1:24: duplicate process label X"},
+		},
+		{
+			src:  "[X(1)::skip || X(2)::skip || X(1+1)::skip || X(i:1..2)::skip]",
+			want: []string{"1:30: duplicate process label X(2)"},
+		},
+		{
+			src:  "[X::Y!1 || Y::x:integer; X?x]; Y!2",
+			want: nil,
+		},
+		{
+			src: "P = (log!1; [X::Y!1 || Y::x:integer; X?x]) \\ (log, X, out)\nQ = (P) \\ (log)\nskip",
+			want: []string{
+				"1:52: process P hides X, which it does not communicate with",
+				"1:55: process P hides out, which it does not communicate with",
+			},
+		},
+		{
+			src: "[X::Y!1 || Y(i:1..2)::x:integer; X?x; Y(1,2)?x]",
+			want: []string{
+				"1:5: process Y takes 1 subscripts, found 0",
+				"1:39: process Y takes 1 subscripts, found 2",
+			},
+		},
+		{
+			src: "[X::X!1 || Y(i:0..1)::x:integer; Y(i)?x; Y(1-i)?x]",
+			want: []string{
+				"1:5: process X names itself",
+				"1:34: process Y(i) names itself",
+			},
+		},
+		{
+			src: "[X::Y!'a'; Y!1; Y!(1,2); Y!\"ab\" || Y::x:integer; s:(1..2)character; X?x; X?s || Z::X!true]",
+			want: []string{
+				"1:5: X outputs character to Y, which inputs integer or array of character",
+			},
+		},
+		{
+			src: "[X::[Z::Y!true || W::skip] || Y::c:character; X?c]",
+			want: []string{
+				"1:9: X outputs boolean to Y, which inputs character",
+			},
+		},
+		{
+			// the processes of an interleaving name Y outside it.
+			src: "[X::[Z::Y!true ||| Y::c:character; Z?c] || Y::c:character; X?c]",
+			want: []string{
+				"1:9: X outputs boolean to Y, which inputs character",
+			},
+		},
+		{
+			src: "x := y; [X(i:1..i)::x:integer; x := i + j]; i := 1; *[(j:1..2) j > 0 → skip]; j := 1",
+			want: []string{
+				"1:1: undefined variable x",
+				"1:6: undefined variable y",
+				"1:17: undefined variable i",
+				"1:41: undefined variable j",
+				"1:45: undefined variable i",
+				"1:79: undefined variable j",
+			},
+		},
+		{
+			src:  "wait 'a'; [c:character; west?c → skip □ timeout 1 = 1 → skip]",
+			want: []string{"1:6: duration 'a' of type character is not an integer", "1:49: duration 1 = 1 of type boolean is not an integer"},
+		},
+		{
+			src:  "[prob 1 → skip □ true → skip]; *[prob true → skip]",
+			want: []string{"1:1: guards with and without weights", "1:39: weight true of type boolean is not an integer"},
+		},
+		{
+			src:  "[X(i:1..2)::i := 1; west?i]",
+			want: []string{"1:13: cannot assign to bound variable i", "1:26: cannot assign to bound variable i"},
+		},
+		{
+			src: "P = (x:integer; x := 1)\nP = (skip)\nx:integer; y:real; P; P; R",
+			want: []string{
+				"1:6: x redeclared",
+				"2:1: process P redefined",
+				"3:14: undefined type real",
+				"3:26: undefined process R",
+			},
+		},
+		{
+			// the renamed output of X is to Y, the input of Z from
+			// its own process Y is not renamed.
+			src: "COPY = (*[c:character; west?c → east!c])\nSINK = ([Y::n:integer; Z?n || Z::Y!1])\n" +
+				"[X::COPY[[east <- Y]] || Y::n:integer; X?n || west::X!'a' || Z::SINK[[Y <- X]]]",
+			want: []string{"1:33: X outputs character to Y, which inputs integer"},
+		},
+		{
+			src:  "P = (skip)\nassert P :[deadlock free]\nassert Q [T= P]\nassert P [FD= R]",
+			want: []string{"3:8: undefined process Q", "4:15: undefined process R"},
+		},
+	}
+	for _, tt := range tests {
+		prog, err := lang.Parse(tt.src)
+		if err != nil {
+			t.Fatalf("%v: %q: %v", t.Name(), tt.src, err)
+		}
+		var got []string
+		if err := lang.Check(prog); err != nil {
+			for _, e := range err.(lang.ErrorList) {
+				got = append(got, e.Error())
+			}
+		}
+		if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+			t.Fatalf("%v: %q: expected:\n%v\ngot:\n%v", t.Name(), tt.src, strings.Join(tt.want, "\n"), strings.Join(got, "\n"))
+		}
+	}
+}
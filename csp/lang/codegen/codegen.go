@@ -0,0 +1,851 @@
+// Package codegen generates Go source code from programs of package
+// lang, in the style of the solutions of package csp: every process is
+// a function of the channels it communicates on, an alternative or
+// repetitive command with input guards selects by package alt, and a
+// parallel command starts its processes as goroutines connected by
+// unbuffered channels.
+//
+// The process COPY
+//
+//   X :: *[c:character; west?c -> east!c]
+//
+// becomes
+//
+//   func X(west <-chan rune, east chan<- rune) {
+//   	defer close(east)
+//   	alt.Repeat(alt.New(
+//   		alt.Recv(west, func(c rune) {
+//   			east <- c
+//   		}),
+//   	))
+//   }
+//
+// Every process closes the channels it outputs to once it terminates,
+// hence an input guard of a terminated source is false, as in the
+// paper. A process fails with a panic, such as when all guards of an
+// alternative command are false. Unlike the paper, an output to a
+// terminated process blocks forever. Arrays of processes, bound
+// variables, structured values, interleaving, renaming, wait commands,
+// timeouts and probabilistic choices are not supported.
+package codegen
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/changkun/gobase/csp/lang"
+)
+
+// Config configures the generated code.
+type Config struct {
+	// Package is the name of the package of the generated code, the
+	// default is main.
+	Package string
+	// Name is the name of the function of the program. The default is
+	// the label of a program consisting of a single labelled process,
+	// and Main otherwise.
+	Name string
+}
+
+// Generate returns the formatted Go source code of prog.
+func Generate(prog *lang.Program, cfg Config) (src []byte, err error) {
+	if cfg.Package == "" {
+		cfg.Package = "main"
+	}
+	g := &generator{
+		cfg:     cfg,
+		defs:    map[string]*lang.Definition{},
+		funcs:   map[string]*function{},
+		imports: map[string]bool{},
+		used:    used(prog),
+	}
+	for _, d := range prog.Defs {
+		g.defs[d.Name] = d
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			e, ok := r.(*lang.Error)
+			if !ok {
+				panic(r)
+			}
+			src, err = nil, e
+		}
+	}()
+	g.program(prog)
+	return g.source()
+}
+
+// generator generates the functions of a program.
+type generator struct {
+	cfg     Config
+	defs    map[string]*lang.Definition
+	funcs   map[string]*function
+	order   []*function
+	imports map[string]bool
+	used    map[string]bool // variables read by an expression
+}
+
+// function is a generated function of a process.
+type function struct {
+	name    string
+	params  []*param
+	body    bytes.Buffer
+	scope   *scope
+	doc     string
+	compose bool // runs the processes of a parallel command
+}
+
+// param is the channel of a function to communicate with the process
+// named name.
+type param struct {
+	name  string
+	input bool
+	typ   string // element type
+}
+
+// ident returns the Go name of p, names used in both directions are
+// suffixed by In and Out.
+func (p *param) ident(f *function) string {
+	for _, q := range f.params {
+		if q != p && q.name == p.name {
+			if p.input {
+				return p.name + "In"
+			}
+			return p.name + "Out"
+		}
+	}
+	return p.name
+}
+
+func (p *param) chanType() string {
+	if p.input {
+		return "<-chan " + p.typ
+	}
+	return "chan<- " + p.typ
+}
+
+// scope is a scope of variables of a function.
+type scope struct {
+	outer *scope
+	vars  map[string]*variable
+}
+
+// variable is a declared variable, lo is the lower bound of an array.
+type variable struct {
+	typ string
+	lo  int
+}
+
+func (s *scope) lookup(name string) *variable {
+	for ; s != nil; s = s.outer {
+		if v, ok := s.vars[name]; ok {
+			return v
+		}
+	}
+	return nil
+}
+
+func errorf(n lang.Node, format string, args ...interface{}) {
+	panic(&lang.Error{Pos: n.Pos(), Msg: fmt.Sprintf(format, args...)})
+}
+
+// used returns the names of all variables read by an expression of
+// prog.
+func used(prog *lang.Program) map[string]bool {
+	written := map[*lang.Ident]bool{}
+	lang.Inspect(prog, func(n lang.Node) bool {
+		switch n := n.(type) {
+		case *lang.Declaration:
+			for _, id := range n.Names {
+				written[id] = true
+			}
+		case *lang.AssignmentCmd:
+			if id, ok := n.Target.(*lang.Ident); ok {
+				written[id] = true
+			}
+		case *lang.InputCmd:
+			if id, ok := n.Target.(*lang.Ident); ok {
+				written[id] = true
+			}
+		}
+		return true
+	})
+	names := map[string]bool{}
+	lang.Inspect(prog, func(n lang.Node) bool {
+		if id, ok := n.(*lang.Ident); ok && !written[id] {
+			names[id.Name] = true
+		}
+		if s, ok := n.(*lang.StructuredExpr); ok {
+			names[s.Constructor] = true
+		}
+		return true
+	})
+	return names
+}
+
+func (g *generator) program(prog *lang.Program) {
+	body := prog.Body
+	if len(body.Stmts) == 0 {
+		for _, d := range prog.Defs {
+			g.definition(d)
+		}
+		return
+	}
+	par, ok := body.Stmts[0].(*lang.ParallelCmd)
+	if !ok || len(body.Stmts) > 1 {
+		g.process(g.name("Main"), "of the program", body)
+		return
+	}
+	if len(par.Procs) == 1 && par.Procs[0].Label != nil && g.call(par.Procs[0].Body) == nil {
+		label := par.Procs[0].Label.Name
+		g.process(g.name(label), label, par.Procs[0].Body)
+		return
+	}
+	g.parallel(g.name("Main"), par)
+}
+
+// name returns the configured name of the function of the program.
+func (g *generator) name(def string) string {
+	if g.cfg.Name != "" {
+		return g.cfg.Name
+	}
+	return def
+}
+
+// call returns the definition a process body consists of, if so.
+func (g *generator) call(body *lang.CmdList) *lang.Definition {
+	if len(body.Stmts) != 1 {
+		return nil
+	}
+	ref, ok := body.Stmts[0].(*lang.ProcRef)
+	if !ok {
+		return nil
+	}
+	d, ok := g.defs[ref.Name]
+	if !ok {
+		errorf(ref, "undefined process %s", ref.Name)
+	}
+	if len(ref.Renames) > 0 {
+		errorf(ref.Renames[0], "renaming is not supported")
+	}
+	return d
+}
+
+// definition returns the function of d.
+func (g *generator) definition(d *lang.Definition) *function {
+	if f, ok := g.funcs[d.Name]; ok {
+		return f
+	}
+	return g.process(d.Name, d.Name, d.Body)
+}
+
+// process generates the function name executing the body of the
+// process label.
+func (g *generator) process(name, label string, body *lang.CmdList) *function {
+	if _, ok := g.funcs[name]; ok {
+		errorf(body, "function %s generated twice", name)
+	}
+	f := &function{
+		name:  name,
+		scope: &scope{vars: map[string]*variable{}},
+		doc:   fmt.Sprintf("%s runs the process %s.", name, label),
+	}
+	g.funcs[name] = f
+	g.order = append(g.order, f)
+	g.stmts(f, body)
+	return f
+}
+
+// parallel generates the function name executing par.
+func (g *generator) parallel(name string, par *lang.ParallelCmd) {
+	if par.Interleave {
+		errorf(par, "interleaving is not supported")
+	}
+	labels := map[string]bool{}
+	for _, p := range par.Procs {
+		if p.Label == nil {
+			errorf(p, "processes without labels are not supported")
+		}
+		if len(p.Label.Subscripts) > 0 {
+			errorf(p.Label, "arrays of processes are not supported")
+		}
+		if labels[p.Label.Name] {
+			errorf(p.Label, "duplicate process label %s", p.Label.Name)
+		}
+		labels[p.Label.Name] = true
+	}
+
+	main := &function{
+		name:    name,
+		doc:     fmt.Sprintf("%s runs the processes of the parallel command.", name),
+		compose: true,
+	}
+	g.funcs[name] = main
+	links := map[[2]string]string{} // element types of the links
+	dests := map[string]string{}    // the processes outputting to external destinations
+	calls := []string{}
+	for _, p := range par.Procs {
+		label := p.Label.Name
+		var f *function
+		if d := g.call(p.Body); d != nil {
+			f = g.definition(d)
+		} else {
+			f = g.process(label, label, p.Body)
+		}
+		args := []string{}
+		for _, prm := range f.params {
+			if !labels[prm.name] {
+				if !prm.input {
+					if other, ok := dests[prm.name]; ok {
+						errorf(p, "%s and %s both output to %s", other, label, prm.name)
+					}
+					dests[prm.name] = label
+				}
+				ext := main.param(p, prm.name, prm.input, prm.typ)
+				args = append(args, ext.ident(main))
+				continue
+			}
+			key := [2]string{label, prm.name}
+			if prm.input {
+				key = [2]string{prm.name, label}
+			}
+			if typ, ok := links[key]; ok && typ != prm.typ {
+				errorf(p, "%s communicates %s, %s expects %s", key[0], typ, key[1], prm.typ)
+			}
+			links[key] = prm.typ
+			args = append(args, key[0]+"_"+key[1])
+		}
+		calls = append(calls, fmt.Sprintf("%s(%s)", f.name, strings.Join(args, ", ")))
+	}
+	keys := make([][2]string, 0, len(links))
+	for key := range links {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i][0] < keys[j][0] || keys[i][0] == keys[j][0] && keys[i][1] < keys[j][1]
+	})
+	for _, key := range keys {
+		fmt.Fprintf(&main.body, "%s_%s := make(chan %s)\n", key[0], key[1], links[key])
+	}
+	g.imports["sync"] = true
+	fmt.Fprintf(&main.body, "wg := sync.WaitGroup{}\nwg.Add(%d)\n", len(calls))
+	for _, call := range calls {
+		fmt.Fprintf(&main.body, "go func() {\ndefer wg.Done()\n%s\n}()\n", call)
+	}
+	fmt.Fprintf(&main.body, "wg.Wait()\n")
+	g.order = append(g.order, main)
+}
+
+// param returns the parameter of f to communicate with name in the
+// given direction, it is added if needed.
+func (f *function) param(n lang.Node, name string, input bool, typ string) *param {
+	for _, p := range f.params {
+		if p.name == name && p.input == input {
+			if typ != "" && p.typ != typ {
+				errorf(n, "%s communicates both %s and %s", name, p.typ, typ)
+			}
+			return p
+		}
+	}
+	p := &param{name: name, input: input, typ: typ}
+	f.params = append(f.params, p)
+	return p
+}
+
+// port returns the channel of f to communicate with n.
+func (g *generator) port(f *function, n *lang.ProcName, input bool, typ string) string {
+	if len(n.Subscripts) > 0 {
+		errorf(n, "arrays of processes are not supported")
+	}
+	return f.param(n, n.Name, input, typ).ident(f)
+}
+
+// temp returns a name for a temporary variable, which does not
+// collide with the variables of the program.
+func (g *generator) temp(f *function, name string) string {
+	tmp := name
+	for i := 2; g.used[tmp] || f.scope.lookup(tmp) != nil; i++ {
+		tmp = name + strconv.Itoa(i)
+	}
+	return tmp
+}
+
+func (g *generator) printf(f *function, format string, args ...interface{}) {
+	fmt.Fprintf(&f.body, format, args...)
+}
+
+// capture returns the code generated by gen.
+func (g *generator) capture(f *function, gen func()) string {
+	body := f.body
+	f.body = bytes.Buffer{}
+	gen()
+	code := f.body.String()
+	f.body = body
+	return code
+}
+
+func (g *generator) stmts(f *function, l *lang.CmdList) {
+	for _, s := range l.Stmts {
+		g.stmt(f, s)
+	}
+}
+
+// block generates l in a new scope.
+func (g *generator) block(f *function, l *lang.CmdList, vars map[string]*variable) {
+	if vars == nil {
+		vars = map[string]*variable{}
+	}
+	f.scope = &scope{outer: f.scope, vars: vars}
+	g.stmts(f, l)
+	f.scope = f.scope.outer
+}
+
+func (g *generator) stmt(f *function, s lang.Stmt) {
+	switch s := s.(type) {
+	case *lang.Declaration:
+		g.declare(f, s)
+	case *lang.SkipCmd:
+	case *lang.AssignmentCmd:
+		g.assign(f, s.Target, g.expr(f, s.Value), g.typeOf(f, s.Value))
+	case *lang.InputCmd:
+		typ := g.typeOf(f, s.Target)
+		ch := g.port(f, s.Source, true, typ)
+		v, ok := g.temp(f, "v"), g.temp(f, "ok")
+		g.printf(f, "if %s, %s := <-%s; %s {\n", v, ok, ch, ok)
+		g.assign(f, s.Target, v, typ)
+		g.printf(f, "} else {\npanic(%q)\n}\n", "csp: input from terminated process "+s.Source.Name)
+	case *lang.OutputCmd:
+		typ := g.typeOf(f, s.Value)
+		ch := g.port(f, s.Dest, false, typ)
+		g.printf(f, "%s <- %s\n", ch, g.value(f, s.Value))
+	case *lang.AlternativeCmd:
+		g.alternative(f, s, false)
+	case *lang.RepetitiveCmd:
+		g.alternative(f, s.Alt, true)
+	case *lang.ProcRef:
+		d, ok := g.defs[s.Name]
+		if !ok {
+			errorf(s, "undefined process %s", s.Name)
+		}
+		if len(s.Renames) > 0 {
+			errorf(s.Renames[0], "renaming is not supported")
+		}
+		g.stmts(f, d.Body)
+	case *lang.WaitCmd:
+		errorf(s, "wait commands are not supported")
+	case *lang.ParallelCmd:
+		errorf(s, "nested parallel commands are not supported")
+	default:
+		errorf(s, "unexpected %T", s)
+	}
+}
+
+var types = map[string]string{
+	"integer":   "int",
+	"character": "rune",
+	"boolean":   "bool",
+}
+
+func (g *generator) declare(f *function, d *lang.Declaration) {
+	for _, id := range d.Names {
+		if _, ok := f.scope.vars[id.Name]; ok {
+			errorf(id, "%s redeclared", id.Name)
+		}
+		switch t := d.Type.(type) {
+		case *lang.NamedType:
+			typ, ok := types[t.Name]
+			if !ok {
+				errorf(t, "undefined type %s", t.Name)
+			}
+			f.scope.vars[id.Name] = &variable{typ: typ}
+			g.printf(f, "var %s %s\n", id.Name, typ)
+		case *lang.ArrayType:
+			elem, ok := t.Elem.(*lang.NamedType)
+			if !ok || types[elem.Name] == "" {
+				errorf(t.Elem, "unsupported element type")
+			}
+			lo, hi := constant(t.Lo), constant(t.Hi)
+			f.scope.vars[id.Name] = &variable{typ: "[]" + types[elem.Name], lo: lo}
+			g.printf(f, "%s := make([]%s, %d)\n", id.Name, types[elem.Name], hi-lo+1)
+		}
+		if !g.used[id.Name] {
+			g.printf(f, "_ = %s\n", id.Name)
+		}
+	}
+}
+
+// constant returns the value of a constant integer expression.
+func constant(e lang.Expr) int {
+	v, err := lang.Eval(e)
+	if err != nil {
+		errorf(e, "%v", err)
+	}
+	i, ok := v.(int)
+	if !ok {
+		errorf(e, "expected integer constant")
+	}
+	return i
+}
+
+// assign generates the assignment of the Go expression v of type typ to
+// target.
+func (g *generator) assign(f *function, target lang.Expr, v, typ string) {
+	switch t := target.(type) {
+	case *lang.Ident:
+		x := f.scope.lookup(t.Name)
+		if x == nil {
+			errorf(t, "undefined variable %s", t.Name)
+		}
+		if x.typ != typ {
+			errorf(t, "cannot assign %s to %s of type %s", typ, t.Name, x.typ)
+		}
+		if strings.HasPrefix(x.typ, "[]") {
+			g.printf(f, "copy(%s, %s)\n", t.Name, v)
+			return
+		}
+		g.printf(f, "%s = %s\n", t.Name, v)
+	case *lang.StructuredExpr:
+		if elem := g.typeOf(f, t); elem != typ {
+			errorf(t, "cannot assign %s to element of %s", typ, t.Constructor)
+		}
+		g.printf(f, "%s = %s\n", g.expr(f, t), v)
+	case *lang.ParenExpr:
+		g.assign(f, t.X, v, typ)
+	default:
+		errorf(target, "cannot assign to expression")
+	}
+}
+
+// alternative generates an alternative command, or a repetitive one if
+// repeat is set.
+func (g *generator) alternative(f *function, a *lang.AlternativeCmd, repeat bool) {
+	inputs := false
+	for _, gc := range a.Cmds {
+		if len(gc.Ranges) > 0 {
+			errorf(gc.Ranges[0], "bound variables are not supported")
+		}
+		if gc.Guard.Timeout != nil {
+			errorf(gc.Guard.Timeout, "timeouts are not supported")
+		}
+		if gc.Guard.Prob != nil {
+			errorf(gc.Guard.Prob, "probabilistic choices are not supported")
+		}
+		if gc.Guard.Input != nil {
+			inputs = true
+		}
+	}
+	if inputs {
+		g.selection(f, a, repeat)
+		return
+	}
+
+	if repeat && len(a.Cmds) == 1 {
+		gc := a.Cmds[0]
+		g.printf(f, "for %s {\n", g.condition(f, gc.Guard))
+		g.block(f, gc.Body, nil)
+		g.printf(f, "}\n")
+		return
+	}
+	if repeat {
+		g.printf(f, "for {\n")
+	}
+	for i, gc := range a.Cmds {
+		if i > 0 {
+			g.printf(f, "} else ")
+		}
+		g.printf(f, "if %s {\n", g.condition(f, gc.Guard))
+		g.block(f, gc.Body, nil)
+	}
+	if repeat {
+		g.printf(f, "} else {\nbreak\n}\n}\n")
+		return
+	}
+	g.printf(f, "} else {\npanic(%q)\n}\n", "csp: all guards fail")
+}
+
+// condition returns the Go expression of the boolean guard list of g.
+func (g *generator) condition(f *function, gd *lang.Guard) string {
+	conds := []string{}
+	for _, n := range gd.List {
+		e, ok := n.(lang.Expr)
+		if !ok {
+			errorf(n, "declarations in guards without input are not supported")
+		}
+		if typ := g.typeOf(f, e); typ != "bool" {
+			errorf(e, "expected boolean, found %s", typ)
+		}
+		conds = append(conds, g.expr(f, e))
+	}
+	if len(conds) == 0 {
+		return "true"
+	}
+	if len(conds) == 1 {
+		return conds[0]
+	}
+	for i := range conds {
+		conds[i] = "(" + conds[i] + ")"
+	}
+	return strings.Join(conds, " && ")
+}
+
+// selection generates an alternative or repetitive command with input
+// guards by package alt.
+func (g *generator) selection(f *function, a *lang.AlternativeCmd, repeat bool) {
+	g.imports["github.com/changkun/gobase/csp/alt"] = true
+	if repeat {
+		g.printf(f, "alt.Repeat(alt.New(\n")
+	} else {
+		g.printf(f, "if _, ok := alt.New(\n")
+	}
+	for _, gc := range a.Cmds {
+		decls, conds := []*lang.Declaration{}, &lang.Guard{}
+		for _, n := range gc.Guard.List {
+			if d, ok := n.(*lang.Declaration); ok {
+				decls = append(decls, d)
+				continue
+			}
+			conds.List = append(conds.List, n)
+		}
+		// the condition is evaluated before the body, outside the
+		// scope of the declarations of the guard.
+		cond := ""
+		if len(conds.List) > 0 {
+			cond = g.condition(f, conds)
+		}
+
+		f.scope = &scope{outer: f.scope, vars: map[string]*variable{}}
+		in := gc.Guard.Input
+		if in == nil {
+			g.printf(f, "alt.Ready(func() {\n")
+			for _, d := range decls {
+				g.declare(f, d)
+			}
+		} else {
+			// a variable declared by the guard to input to is the
+			// parameter of the body, as in c:character; west?c.
+			param := ""
+			if id, ok := in.Target.(*lang.Ident); ok && len(decls) > 0 {
+				last := decls[len(decls)-1]
+				if t, ok := last.Type.(*lang.NamedType); ok && types[t.Name] != "" &&
+					len(last.Names) == 1 && last.Names[0].Name == id.Name {
+					param = id.Name
+					decls = decls[:len(decls)-1]
+					f.scope.vars[id.Name] = &variable{typ: types[t.Name]}
+				}
+			}
+			// the declarations determine the type of the target, but
+			// follow the parameter.
+			decl := g.capture(f, func() {
+				for _, d := range decls {
+					g.declare(f, d)
+				}
+			})
+			typ := g.typeOf(f, in.Target)
+			ch := g.port(f, in.Source, true, typ)
+			if param == "" {
+				param = g.temp(f, "v")
+				decl += g.capture(f, func() { g.assign(f, in.Target, param, typ) })
+			}
+			g.printf(f, "alt.Recv(%s, func(%s %s) {\n%s", ch, param, typ, decl)
+		}
+		g.stmts(f, gc.Body)
+		f.scope = f.scope.outer
+
+		if cond != "" {
+			g.printf(f, "}).When(func() bool { return %s }),\n", cond)
+		} else {
+			g.printf(f, "}),\n")
+		}
+	}
+	if repeat {
+		g.printf(f, "))\n")
+		return
+	}
+	g.printf(f, ").Select(); !ok {\npanic(%q)\n}\n", "csp: all guards fail")
+}
+
+// typeOf returns the Go type of e.
+func (g *generator) typeOf(f *function, e lang.Expr) string {
+	switch e := e.(type) {
+	case *lang.IntLit:
+		return "int"
+	case *lang.CharLit:
+		return "rune"
+	case *lang.StringLit:
+		return "[]rune"
+	case *lang.Ident:
+		if x := f.scope.lookup(e.Name); x != nil {
+			return x.typ
+		}
+		switch e.Name {
+		case "true", "false":
+			return "bool"
+		case "space", "asterisk", "upward arrow":
+			return "rune"
+		}
+		errorf(e, "undefined variable %s", e.Name)
+	case *lang.ParenExpr:
+		return g.typeOf(f, e.X)
+	case *lang.UnaryExpr:
+		if e.Op == lang.NOT {
+			return "bool"
+		}
+		return "int"
+	case *lang.BinaryExpr:
+		switch e.Op {
+		case lang.ADD, lang.SUB, lang.MUL, lang.DIV, lang.MOD:
+			return "int"
+		}
+		return "bool"
+	case *lang.StructuredExpr:
+		x := f.scope.lookup(e.Constructor)
+		if x == nil || !strings.HasPrefix(x.typ, "[]") || len(e.Args) != 1 {
+			errorf(e, "structured values are not supported")
+		}
+		return strings.TrimPrefix(x.typ, "[]")
+	}
+	errorf(e, "unexpected %T", e)
+	return ""
+}
+
+// value returns the Go expression of e as a value to output, which for
+// arrays is a copy.
+func (g *generator) value(f *function, e lang.Expr) string {
+	v := g.expr(f, e)
+	if typ := g.typeOf(f, e); strings.HasPrefix(typ, "[]") {
+		if _, ok := e.(*lang.StringLit); !ok {
+			return fmt.Sprintf("append(%s(nil), %s...)", typ, v)
+		}
+	}
+	return v
+}
+
+var operators = map[lang.Token]string{
+	lang.OR: "||", lang.AND: "&&",
+	lang.EQ: "==", lang.NEQ: "!=", lang.LT: "<", lang.LEQ: "<=", lang.GT: ">", lang.GEQ: ">=",
+	lang.ADD: "+", lang.SUB: "-",
+	lang.MUL: "*", lang.DIV: "/", lang.MOD: "%",
+}
+
+var precedence = map[lang.Token]int{
+	lang.OR: 1, lang.AND: 2,
+	lang.EQ: 3, lang.NEQ: 3, lang.LT: 3, lang.LEQ: 3, lang.GT: 3, lang.GEQ: 3,
+	lang.ADD: 4, lang.SUB: 4,
+	lang.MUL: 5, lang.DIV: 5, lang.MOD: 5,
+}
+
+// expr returns the Go expression of e.
+func (g *generator) expr(f *function, e lang.Expr) string {
+	switch e := e.(type) {
+	case *lang.IntLit:
+		return strconv.Itoa(e.Value)
+	case *lang.CharLit:
+		return strconv.QuoteRune(e.Value)
+	case *lang.StringLit:
+		return fmt.Sprintf("[]rune(%s)", strconv.Quote(e.Value))
+	case *lang.Ident:
+		if f.scope.lookup(e.Name) != nil {
+			return e.Name
+		}
+		switch e.Name {
+		case "true", "false":
+			return e.Name
+		case "space":
+			return "' '"
+		case "asterisk":
+			return "'*'"
+		case "upward arrow":
+			return "'↑'"
+		}
+		errorf(e, "undefined variable %s", e.Name)
+	case *lang.ParenExpr:
+		return "(" + g.expr(f, e.X) + ")"
+	case *lang.UnaryExpr:
+		if e.Op == lang.NOT {
+			return "!" + g.operand(f, e.X, 6)
+		}
+		return operators[e.Op] + g.operand(f, e.X, 6)
+	case *lang.BinaryExpr:
+		prec := precedence[e.Op]
+		return g.operand(f, e.X, prec) + " " + operators[e.Op] + " " + g.operand(f, e.Y, prec+1)
+	case *lang.StructuredExpr:
+		x := f.scope.lookup(e.Constructor)
+		if x == nil || !strings.HasPrefix(x.typ, "[]") || len(e.Args) != 1 {
+			errorf(e, "structured values are not supported")
+		}
+		i := g.operand(f, e.Args[0], 4)
+		if x.lo != 0 {
+			i = fmt.Sprintf("%s-%d", i, x.lo)
+		}
+		return fmt.Sprintf("%s[%s]", e.Constructor, i)
+	}
+	errorf(e, "unexpected %T", e)
+	return ""
+}
+
+// operand returns the Go expression of e as an operand of an operator
+// of precedence prec.
+func (g *generator) operand(f *function, e lang.Expr, prec int) string {
+	if b, ok := e.(*lang.BinaryExpr); ok && precedence[b.Op] < prec {
+		return "(" + g.expr(f, e) + ")"
+	}
+	return g.expr(f, e)
+}
+
+// source returns the formatted source of all functions.
+func (g *generator) source() ([]byte, error) {
+	buf := bytes.Buffer{}
+	fmt.Fprintf(&buf, "// Code generated by codegen. DO NOT EDIT.\n\npackage %s\n\n", g.cfg.Package)
+	// standard imports precede the others.
+	imports := [2][]string{}
+	for path := range g.imports {
+		i := 0
+		if strings.Contains(path, ".") {
+			i = 1
+		}
+		imports[i] = append(imports[i], path)
+	}
+	if len(g.imports) > 0 {
+		fmt.Fprintf(&buf, "import (\n")
+		for _, paths := range imports {
+			sort.Strings(paths)
+			for _, path := range paths {
+				fmt.Fprintf(&buf, "%q\n", path)
+			}
+			fmt.Fprintf(&buf, "\n")
+		}
+		fmt.Fprintf(&buf, ")\n\n")
+	}
+	for _, f := range g.order {
+		params := []string{}
+		closes := []string{}
+		for _, p := range f.params {
+			if p.typ == "" {
+				return nil, fmt.Errorf("codegen: cannot infer the type of %s", p.name)
+			}
+			params = append(params, p.ident(f)+" "+p.chanType())
+			if !p.input {
+				closes = append(closes, p.ident(f))
+			}
+		}
+		fmt.Fprintf(&buf, "// %s\n", f.doc)
+		fmt.Fprintf(&buf, "func %s(%s) {\n", f.name, strings.Join(params, ", "))
+		// the processes of a composition close its outputs.
+		if !f.compose {
+			for _, name := range closes {
+				fmt.Fprintf(&buf, "defer close(%s)\n", name)
+			}
+		}
+		buf.Write(f.body.Bytes())
+		fmt.Fprintf(&buf, "}\n\n")
+	}
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("codegen: %v", err)
+	}
+	return src, nil
+}
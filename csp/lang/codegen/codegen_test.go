@@ -0,0 +1,184 @@
+package codegen_test
+
+import (
+	"flag"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/changkun/gobase/csp/lang"
+	"github.com/changkun/gobase/csp/lang/codegen"
+)
+
+var update = flag.Bool("update", false, "update the generated code of package paper")
+
+// solutions returns the programs quoted after "Solution:" in the doc
+// comments of the given functions of package csp.
+func solutions(t *testing.T, funcs ...string) map[string]string {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "../../csp.go", nil, parser.ParseComments)
+	if err != nil {
+		t.Fatalf("%v: cannot parse csp.go: %v", t.Name(), err)
+	}
+
+	progs := map[string]string{}
+	for _, decl := range f.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Doc == nil {
+			continue
+		}
+		lines := strings.Split(fn.Doc.Text(), "\n")
+		prog := []string{}
+		for i, l := range lines {
+			if l != "Solution:" {
+				continue
+			}
+			for _, l := range lines[i+2:] {
+				if !strings.HasPrefix(l, "  ") {
+					break
+				}
+				prog = append(prog, strings.TrimPrefix(l, "  "))
+			}
+		}
+		progs[fn.Name.Name] = strings.Join(prog, "\n")
+	}
+
+	sols := map[string]string{}
+	for _, name := range funcs {
+		if progs[name] == "" {
+			t.Fatalf("%v: no solution quoted by %v", t.Name(), name)
+		}
+		sols[name] = progs[name]
+	}
+	return sols
+}
+
+// TestGenerate compares the code generated from the solutions of the
+// paper with package paper, whose behaviour is compared with the
+// hand-written solutions of package csp.
+func TestGenerate(t *testing.T) {
+	progs := solutions(t, "S31_COPY", "S32_SQUASH")
+	tests := []struct {
+		solution, name, file string
+	}{
+		{solution: "S31_COPY", name: "COPY", file: "copy.go"},
+		{solution: "S32_SQUASH", name: "SQUASH", file: "squash.go"},
+	}
+	for _, tt := range tests {
+		prog, err := lang.Parse(progs[tt.solution])
+		if err != nil {
+			t.Fatalf("%v: %v: %v", t.Name(), tt.solution, err)
+		}
+		src, err := codegen.Generate(prog, codegen.Config{Package: "paper", Name: tt.name})
+		if err != nil {
+			t.Fatalf("%v: %v: %v", t.Name(), tt.solution, err)
+		}
+
+		golden := filepath.Join("internal", "paper", tt.file)
+		if *update {
+			if err := os.WriteFile(golden, src, 0644); err != nil {
+				t.Fatalf("%v: %v", t.Name(), err)
+			}
+			continue
+		}
+		want, err := os.ReadFile(golden)
+		if err != nil {
+			t.Fatalf("%v: %v", t.Name(), err)
+		}
+		if string(src) != string(want) {
+			t.Fatalf("%v: %v: expected:\n%s\ngot:\n%s", t.Name(), tt.solution, want, src)
+		}
+	}
+}
+
+func TestGenerateCode(t *testing.T) {
+	tests := []struct {
+		src  string
+		want []string
+	}{
+		{
+			src:  "X::*[n:integer; in?n -> out!n*n]",
+			want: []string{"func X(in <-chan int, out chan<- int) {", "defer close(out)", "out <- n * n"},
+		},
+		{
+			src:  "X::n:integer; n := 0; *[n < 10 -> out!n; n := n+1]",
+			want: []string{"func X(out chan<- int) {", "for n < 10 {"},
+		},
+		{
+			src:  "X::b:boolean; in?b; [b ∧ ¬b -> out!1 □ b ∨ (1+2)*3 = 9 -> out!2]",
+			want: []string{"if b && !b {", "} else if b || (1+2)*3 == 9 {", `panic("csp: all guards fail")`},
+		},
+		{
+			src:  "X::a:(0..2)integer; a(1) := 3; out!a",
+			want: []string{"a := make([]int, 3)", "a[1] = 3", "out <- append([]int(nil), a...)"},
+		},
+		{
+			src:  "X::*[v:integer; in?v -> ok:boolean; ok := v > 0; out!ok]",
+			want: []string{"alt.Recv(in, func(v int) {", "var ok bool"},
+		},
+		{
+			src:  "X::v:integer; in?v; out!v",
+			want: []string{"if v2, ok := <-in; ok {", "v = v2"},
+		},
+		{
+			src:  "X::n:integer; n := 0; *[n < 3; in?n -> skip □ n >= 3 -> out!n; n := 0]",
+			want: []string{"}).When(func() bool { return n < 3 }),", "alt.Ready(func() {"},
+		},
+		{
+			src: "[X::*[c:character; in?c -> Y!c] || Y::*[c:character; X?c -> out!c]]",
+			want: []string{
+				"func Main(in <-chan rune, out chan<- rune) {",
+				"X_Y := make(chan rune)",
+				"X(in, X_Y)",
+				"Y(X_Y, out)",
+			},
+		},
+	}
+	for _, tt := range tests {
+		prog, err := lang.Parse(tt.src)
+		if err != nil {
+			t.Fatalf("%v: %v: %v", t.Name(), tt.src, err)
+		}
+		src, err := codegen.Generate(prog, codegen.Config{})
+		if err != nil {
+			t.Fatalf("%v: %v: unexpected error: %v", t.Name(), tt.src, err)
+		}
+		for _, want := range tt.want {
+			if !strings.Contains(string(src), want) {
+				t.Fatalf("%v: %v: expected: %v, got:\n%s", t.Name(), tt.src, want, src)
+			}
+		}
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+	tests := []struct {
+		src, want string
+	}{
+		{src: "X::[Y::skip || Z::skip]", want: "1:4: nested parallel commands are not supported"},
+		{src: "X::*[(i:1..3) c:character; west(i)?c -> east!c]", want: "1:7: bound variables are not supported"},
+		{src: "X::west(1)!1", want: "1:4: arrays of processes are not supported"},
+		{src: "P = (east!1)\nX::P[[east <- log]]", want: "2:7: renaming is not supported"},
+		{src: "P = (east!1)\nX::skip; P[[east <- log]]", want: "2:13: renaming is not supported"},
+		{src: "[X::east!1 ||| Y::east!2]", want: "1:1: interleaving is not supported"},
+		{src: "X::c:character; c := 1", want: "1:17: cannot assign int to c of type rune"},
+		{src: "X::east!x", want: "1:9: undefined variable x"},
+		{src: "X::east!P(1, 2)", want: "1:9: structured values are not supported"},
+		{src: "[X::out!1 || Y::out!2]", want: "1:14: X and Y both output to out"},
+		{src: "X::COPY", want: "1:4: undefined process COPY"},
+	}
+	for _, tt := range tests {
+		prog, err := lang.Parse(tt.src)
+		if err != nil {
+			t.Fatalf("%v: %v: %v", t.Name(), tt.src, err)
+		}
+		_, err = codegen.Generate(prog, codegen.Config{})
+		if err == nil || err.Error() != tt.want {
+			t.Fatalf("%v: %v: expected: %v, got: %v", t.Name(), tt.src, tt.want, err)
+		}
+	}
+}
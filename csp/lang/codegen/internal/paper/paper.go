@@ -0,0 +1,8 @@
+// Package paper holds the code generated from the solutions of the
+// paper quoted by package csp, whose behaviour is compared with their
+// hand-written implementations.
+//
+// The code is updated by
+//
+//   go test github.com/changkun/gobase/csp/lang/codegen -update
+package paper
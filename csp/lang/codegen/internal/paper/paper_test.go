@@ -0,0 +1,56 @@
+package paper_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/changkun/gobase/csp"
+	"github.com/changkun/gobase/csp/lang/codegen/internal/paper"
+)
+
+// run returns the output of a process of characters given their input.
+func run(proc func(west <-chan rune, east chan<- rune), input string) []rune {
+	west, east := make(chan rune), make(chan rune)
+	go func() {
+		for _, c := range input {
+			west <- c
+		}
+		close(west)
+	}()
+	go proc(west, east)
+	out := []rune{}
+	for c := range east {
+		out = append(out, c)
+	}
+	return out
+}
+
+func TestGenerated(t *testing.T) {
+	tests := []struct {
+		name      string
+		generated func(west <-chan rune, east chan<- rune)
+		written   func(west <-chan rune, east chan<- rune)
+		inputs    []string
+	}{
+		{
+			name:      "COPY",
+			generated: paper.COPY,
+			written:   csp.S31_COPY,
+			inputs:    []string{"", "a", "Hello, CSP"},
+		},
+		{
+			name:      "SQUASH",
+			generated: paper.SQUASH,
+			written:   csp.S32_SQUASH,
+			inputs:    []string{"", "a", "a*b", "a**b", "***a", "****", "*a**b***c"},
+		},
+	}
+	for _, tt := range tests {
+		for _, in := range tt.inputs {
+			want, got := run(tt.written, in), run(tt.generated, in)
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("%v: %v(%q): expected: %q, got: %q", t.Name(), tt.name, in, string(want), string(got))
+			}
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package cspm
+
+import (
+	"github.com/changkun/gobase/csp/algebra"
+	"github.com/changkun/gobase/csp/lang"
+)
+
+// Algebra imports the CSPm script src as a term of package algebra,
+// whose transition system package lts checks. The process named main,
+// or the last process defined if main is empty, is the term. The
+// operators of the script are those of the algebra: the alphabetised
+// parallel [A || B] is algebra.ParAlpha, and hiding is algebra.Hide. A
+// channel of data carries the values of a set such as {0..9}, or of
+// Bool, its events are those of algebra.NewEvent, such as in.0.
+func Algebra(src, main string) (algebra.Process, error) {
+	s, err := parse(src)
+	if err != nil {
+		return nil, err
+	}
+	d, err := s.main(main)
+	if err != nil {
+		return nil, err
+	}
+	t := &algebraic{script: s, open: map[string]bool{}}
+	return t.term(d)
+}
+
+// algebraic translates a script to a term of the algebra.
+type algebraic struct {
+	*script
+	open map[string]bool // the processes being defined, whether they recur
+}
+
+func (t *algebraic) term(d *definition) (p algebra.Process, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			f, ok := r.(failure)
+			if !ok {
+				panic(r)
+			}
+			p, err = nil, f.err
+		}
+	}()
+	return t.process(&ref{pos: d.pos, name: d.name}, nil), nil
+}
+
+// process returns the term of p, whose variables have the values of
+// vars.
+func (t *algebraic) process(p proc, vars map[string]lang.Expr) algebra.Process {
+	x := t.operator(p, vars)
+	if hidden, ok := t.hidden[p]; ok {
+		x = algebra.Hide(x, t.events(p.position(), hidden))
+	}
+	return x
+}
+
+func (t *algebraic) operator(p proc, vars map[string]lang.Expr) algebra.Process {
+	switch x := p.(type) {
+	case *stop:
+		if x.skip {
+			return algebra.Skip()
+		}
+		return algebra.Stop()
+	case *ref:
+		if _, ok := t.open[x.name]; ok {
+			t.open[x.name] = true
+			return algebra.Var(x.name)
+		}
+		d, ok := t.defs[x.name]
+		if !ok {
+			errorf(x.pos, "undefined process %s", x.name)
+		}
+		// definitions have no parameters, the variables of p are not
+		// in scope of d.
+		t.open[x.name] = false
+		defer delete(t.open, x.name)
+		body := t.process(d.body, nil)
+		if t.open[x.name] {
+			return algebra.Rec(x.name, body)
+		}
+		return body
+	case *prefix:
+		switch x.dir {
+		case "":
+			return algebra.Prefix(algebra.Event(x.ch), t.process(x.then, vars))
+		case "!", ".":
+			v := t.eval(x.value, vars)
+			return algebra.Prefix(algebra.NewEvent(x.ch, lang.Format(v)), t.process(x.then, vars))
+		}
+		values, ok := t.values[x.ch]
+		if !ok {
+			errorf(x.pos, "channel %s carries infinitely many values", x.ch)
+		}
+		var choice algebra.Process
+		for _, v := range values {
+			bound := map[string]lang.Expr{name(x.bind): literal(v)}
+			for n, e := range vars {
+				if _, ok := bound[n]; !ok {
+					bound[n] = e
+				}
+			}
+			q := algebra.Prefix(algebra.NewEvent(x.ch, lang.Format(v)), t.process(x.then, bound))
+			if choice == nil {
+				choice = q
+			} else {
+				choice = algebra.ExtChoice(choice, q)
+			}
+		}
+		return choice
+	case *guard:
+		if t.eval(x.cond, vars) == true {
+			return t.process(x.then, vars)
+		}
+		return algebra.Stop()
+	case *cond:
+		if t.eval(x.cond, vars) == true {
+			return t.process(x.then, vars)
+		}
+		return t.process(x.els, vars)
+	case *choice:
+		if x.internal {
+			return algebra.IntChoice(t.process(x.l, vars), t.process(x.r, vars))
+		}
+		return algebra.ExtChoice(t.process(x.l, vars), t.process(x.r, vars))
+	case *seq:
+		return algebra.Seq(t.process(x.l, vars), t.process(x.r, vars))
+	case *par:
+		l, r := t.process(x.l, vars), t.process(x.r, vars)
+		if x.alpha != nil {
+			return algebra.ParAlpha(l, t.events(x.pos, x.alpha[0]), r, t.events(x.pos, x.alpha[1]))
+		}
+		return algebra.Par(l, t.events(x.pos, x.sync), r)
+	}
+	panic("unreachable")
+}
+
+// events returns the events of the channels chans.
+func (t *algebraic) events(pos lang.Pos, chans []string) []algebra.Event {
+	events := []algebra.Event{}
+	for _, c := range chans {
+		if t.chans[c] == "" {
+			events = append(events, algebra.Event(c))
+			continue
+		}
+		values, ok := t.values[c]
+		if !ok {
+			errorf(pos, "channel %s carries infinitely many values", c)
+		}
+		for _, v := range values {
+			events = append(events, algebra.NewEvent(c, lang.Format(v)))
+		}
+	}
+	return events
+}
+
+// eval returns the value of e, whose variables have the values of vars.
+func (t *algebraic) eval(e lang.Expr, vars map[string]lang.Expr) lang.Value {
+	v, err := lang.Eval(bind(e, vars))
+	if err != nil {
+		if err, ok := err.(*lang.Error); ok {
+			panic(failure{err})
+		}
+		errorf(e.Pos(), "%v", err)
+	}
+	return v
+}
+
+// bind returns e, whose variables are replaced by the expressions of
+// vars.
+func bind(e lang.Expr, vars map[string]lang.Expr) lang.Expr {
+	switch x := e.(type) {
+	case *lang.Ident:
+		if v, ok := vars[x.Name]; ok {
+			return v
+		}
+	case *lang.ParenExpr:
+		return &lang.ParenExpr{Lparen: x.Lparen, X: bind(x.X, vars)}
+	case *lang.UnaryExpr:
+		return &lang.UnaryExpr{OpPos: x.OpPos, Op: x.Op, X: bind(x.X, vars)}
+	case *lang.BinaryExpr:
+		return &lang.BinaryExpr{X: bind(x.X, vars), OpPos: x.OpPos, Op: x.Op, Y: bind(x.Y, vars)}
+	}
+	return e
+}
+
+// literal returns the expression of the value v of a channel.
+func literal(v lang.Value) lang.Expr {
+	switch v := v.(type) {
+	case bool:
+		if v {
+			return &lang.Ident{Name: "true"}
+		}
+		return &lang.Ident{Name: "false"}
+	case rune:
+		return &lang.CharLit{Value: v}
+	}
+	return &lang.IntLit{Value: v.(int)}
+}
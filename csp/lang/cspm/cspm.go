@@ -0,0 +1,684 @@
+// Package cspm imports scripts of machine-readable CSP (CSPm), the
+// dialect of the refinement checker FDR, as programs of package lang,
+// such that models written for FDR are executed by its interpreter, and
+// exports programs as scripts, such that FDR checks them.
+//
+// The script
+//
+//   channel in, mid, out : {0..9}
+//   LEFT = in?x -> mid!x -> LEFT
+//   RIGHT = mid?x -> out!x -> RIGHT
+//   SYSTEM = LEFT [| {| mid |} |] RIGHT
+//
+// is imported as the program
+//
+//   LEFT = (*[x:integer; in?x → RIGHT!x])
+//   RIGHT = (*[x:integer; LEFT?x → out!x])
+//   [LEFT::LEFT || RIGHT::RIGHT]
+//
+// A parallel composition becomes a parallel command of its processes,
+// and a channel synchronising two of them names the other process. A
+// channel no pair of processes synchronises on is external, it is
+// named by itself. A channel carries its values as they are, unless two
+// processes communicate on several channels, whose values are then
+// structured values named by the channels, such as mid(x). The events
+// of channels without data are structured values without components,
+// such as mid(), the process on the left of the parallel composition
+// outputs them.
+//
+// An external choice becomes an alternative command guarded by the
+// inputs of the first events of its processes. The notation has no
+// output guards, a choice of outputs therefore commits to one of them
+// in advance, as does an internal choice. A process recursing to
+// itself at its end becomes a repetitive command, which, as in the
+// paper, terminates once the processes it inputs from have terminated.
+//
+// The supported subset of CSPm is channel declarations of sets of
+// integers such as {0..9}, Int, Bool or Char; process definitions
+// without parameters; STOP, SKIP; prefix c -> P, c!e -> P, c.e -> P and
+// c?x -> P of a single field; external choice []; internal choice |~|;
+// sequential composition ;; boolean guards b & P; if b then P else Q;
+// generalised parallel [| A |], alphabetised parallel [A || B] and
+// interleaving |||, on sets of channels such as {| c, d |}; and
+// hiding \ A, which has no effect as channels connecting processes
+// are internal anyway. Assertions are ignored. STOP terminates like
+// SKIP, as the notation has no command which neither terminates nor
+// fails. A channel synchronising more than two processes is not
+// supported.
+//
+// Algebra imports a script as a term of package algebra instead, by the
+// operators of the algebra, such as algebra.ParAlpha of an alphabetised
+// parallel. A program cannot block the events outside the alphabets of
+// an alphabetised parallel, Parse therefore fails if its processes
+// communicate outside them.
+//
+// Export translates a program to a script the other way round, every
+// process of a parallel command is a process of the script, its
+// variables are parameters of the processes of its command lists.
+package cspm
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/changkun/gobase/csp/lang"
+)
+
+// Parse imports the CSPm script src. The process named main, or the
+// last process defined if main is empty, is the body of the program.
+func Parse(src, main string) (*lang.Program, error) {
+	s, err := parse(src)
+	if err != nil {
+		return nil, err
+	}
+	d, err := s.main(main)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &translator{script: s, variants: map[string]*lang.Definition{}, names: map[string]bool{}}
+	return t.program(d)
+}
+
+// main returns the definition of the process named name, or of the last
+// process defined if name is empty.
+func (s *script) main(name string) (*definition, error) {
+	if name == "" {
+		if len(s.order) == 0 {
+			return nil, &lang.Error{Pos: lang.Pos{Line: 1, Column: 1}, Msg: "no process defined"}
+		}
+		name = s.order[len(s.order)-1].name
+	}
+	d, ok := s.defs[name]
+	if !ok {
+		return nil, fmt.Errorf("undefined process %s", name)
+	}
+	return d, nil
+}
+
+// translator translates a script to a program.
+type translator struct {
+	*script
+	variants map[string]*lang.Definition // definitions by process and environment
+	names    map[string]bool             // names of the definitions
+	defs     []*lang.Definition
+	again    string // the variable repeating a recursive process
+}
+
+// env maps the channels connecting a process to others to their ends.
+type env map[string]end
+
+// end is the end of a channel connecting two processes.
+type end struct {
+	peer   string // the label of the other process
+	out    bool   // whether the process outputs the events without data
+	tagged bool   // whether values are structured values named by the channel
+}
+
+// key returns the canonical form of e.
+func (e env) key() string {
+	keys := []string{}
+	for c, end := range e {
+		keys = append(keys, fmt.Sprintf("%s=%s/%t/%t", c, end.peer, end.out, end.tagged))
+	}
+	sort.Strings(keys)
+	return strings.Join(keys, ",")
+}
+
+// failure aborts the translation with an error.
+type failure struct{ err *lang.Error }
+
+func errorf(pos lang.Pos, format string, args ...interface{}) {
+	panic(failure{&lang.Error{Pos: pos, Msg: fmt.Sprintf(format, args...)}})
+}
+
+func (t *translator) program(main *definition) (prog *lang.Program, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			f, ok := r.(failure)
+			if !ok {
+				panic(r)
+			}
+			prog, err = nil, f.err
+		}
+	}()
+
+	// the variable repeating a recursive process must not hide one of
+	// the script.
+	idents := map[string]bool{}
+	for _, d := range t.order {
+		walk(d.body, func(p proc) {
+			switch x := p.(type) {
+			case *prefix:
+				idents[name(x.bind)] = true
+				inspect(x.value, idents)
+			case *guard:
+				inspect(x.cond, idents)
+			case *cond:
+				inspect(x.cond, idents)
+			}
+		})
+	}
+	t.again = "again"
+	for n := 2; idents[t.again]; n++ {
+		t.again = "again" + strconv.Itoa(n)
+	}
+
+	body := t.parallel(&ref{pos: main.pos, name: main.name}, env{})
+	if len(body.Procs) == 1 {
+		body.Implicit = true
+	}
+	return &lang.Program{Defs: t.defs, Body: &lang.CmdList{Stmts: []lang.Stmt{body}}}, nil
+}
+
+// walk calls fn for p and the processes it consists of, but not the
+// processes it refers to.
+func walk(p proc, fn func(proc)) {
+	fn(p)
+	switch x := p.(type) {
+	case *prefix:
+		walk(x.then, fn)
+	case *guard:
+		walk(x.then, fn)
+	case *cond:
+		walk(x.then, fn)
+		walk(x.els, fn)
+	case *choice:
+		walk(x.l, fn)
+		walk(x.r, fn)
+	case *seq:
+		walk(x.l, fn)
+		walk(x.r, fn)
+	case *par:
+		walk(x.l, fn)
+		walk(x.r, fn)
+	}
+}
+
+// inspect adds the identifiers of e to idents.
+func inspect(e lang.Expr, idents map[string]bool) {
+	if e == nil {
+		return
+	}
+	lang.Inspect(e, func(n lang.Node) bool {
+		if id, ok := n.(*lang.Ident); ok {
+			idents[id.Name] = true
+		}
+		return true
+	})
+}
+
+// uses reports whether the process p, or a process it refers to,
+// communicates on the channel c.
+func (t *translator) uses(p proc, c string, visited map[string]bool) bool {
+	found := false
+	walk(p, func(p proc) {
+		switch x := p.(type) {
+		case *prefix:
+			found = found || x.ch == c
+		case *ref:
+			if d, ok := t.script.defs[x.name]; ok && !visited[x.name] {
+				visited[x.name] = true
+				found = found || t.uses(d.body, c, visited)
+			}
+		}
+	})
+	return found
+}
+
+// leaf is a process of a parallel composition.
+type leaf struct {
+	p     proc
+	label string
+	links map[string]link
+}
+
+// link links a leaf to another one by a channel.
+type link struct {
+	peer *leaf
+	out  bool
+}
+
+// parallel returns the parallel command of the processes composed by
+// p, which are connected to processes outside the composition by
+// outer.
+func (t *translator) parallel(p proc, outer env) *lang.ParallelCmd {
+	leaves := []*leaf{}
+	var flatten func(p proc, expanding map[string]bool) []*leaf
+	flatten = func(p proc, expanding map[string]bool) []*leaf {
+		switch x := p.(type) {
+		case *par:
+			if x.alpha != nil {
+				t.confine(x)
+			}
+			l, r := flatten(x.l, expanding), flatten(x.r, expanding)
+			for _, c := range x.sync {
+				t.link(x, c, l, r)
+			}
+			return append(l, r...)
+		case *ref:
+			d := t.definition(x)
+			if _, ok := d.body.(*par); ok && !expanding[x.name] {
+				expanding[x.name] = true
+				defer delete(expanding, x.name)
+				return flatten(d.body, expanding)
+			}
+		}
+		l := &leaf{p: p, links: map[string]link{}}
+		leaves = append(leaves, l)
+		return []*leaf{l}
+	}
+	flatten(p, map[string]bool{})
+
+	// processes are labelled by the processes they refer to, the
+	// labels must not be taken for external channels.
+	used := map[string]bool{}
+	for c := range t.chans {
+		if _, ok := outer[c]; !ok {
+			used[name(c)] = true
+		}
+	}
+	for _, end := range outer {
+		used[end.peer] = true
+	}
+	for i, l := range leaves {
+		base := "P" + strconv.Itoa(i+1)
+		if r, ok := l.p.(*ref); ok {
+			base = name(r.name)
+		}
+		label := base
+		for n := 2; used[label]; n++ {
+			label = base + "_" + strconv.Itoa(n)
+		}
+		used[label] = true
+		l.label = label
+	}
+
+	cmd := &lang.ParallelCmd{}
+	for _, l := range leaves {
+		e := env{}
+		for c, end := range outer {
+			e[c] = end
+		}
+		peers := map[*leaf]int{}
+		for _, k := range l.links {
+			peers[k.peer]++
+		}
+		for c, k := range l.links {
+			e[c] = end{peer: k.peer.label, out: k.out, tagged: peers[k.peer] > 1}
+		}
+		cmd.Procs = append(cmd.Procs, &lang.Proc{
+			Label: &lang.ProcLabel{Name: l.label},
+			Body:  &lang.CmdList{Stmts: t.stmts(l.p, &frame{env: e}, block{}, false)},
+		})
+	}
+	return cmd
+}
+
+// confine fails unless the processes of the alphabetised parallel x
+// communicate on the channels of their alphabets only: the events
+// outside them are blocked, as by algebra.ParAlpha, which a program
+// cannot express.
+func (t *translator) confine(x *par) {
+	chans := []string{}
+	for c := range t.chans {
+		chans = append(chans, c)
+	}
+	sort.Strings(chans)
+	for i, p := range []proc{x.l, x.r} {
+		in := map[string]bool{}
+		for _, c := range x.alpha[i] {
+			in[c] = true
+		}
+		for _, c := range chans {
+			if !in[c] && t.uses(p, c, map[string]bool{}) {
+				errorf(x.pos, "channel %s is outside the alphabet of its process", c)
+			}
+		}
+	}
+}
+
+// link links the only processes of l and r which communicate on the
+// channel c, which the parallel composition x synchronises.
+func (t *translator) link(x *par, c string, l, r []*leaf) {
+	users := func(leaves []*leaf) []*leaf {
+		out := []*leaf{}
+		for _, lf := range leaves {
+			if t.uses(lf.p, c, map[string]bool{}) {
+				out = append(out, lf)
+			}
+		}
+		return out
+	}
+	lu, ru := users(l), users(r)
+	if len(lu) != 1 || len(ru) != 1 {
+		errorf(x.pos, "channel %s must connect a single process on either side", c)
+	}
+	lu[0].links[c] = link{peer: ru[0], out: true}
+	ru[0].links[c] = link{peer: lu[0]}
+}
+
+// definition returns the definition of the process x refers to.
+func (t *translator) definition(x *ref) *definition {
+	d, ok := t.script.defs[x.name]
+	if !ok {
+		errorf(x.pos, "undefined process %s", x.name)
+	}
+	return d
+}
+
+// variant returns the name of the definition of the process x refers
+// to in the environment e, which it defines first if needed.
+func (t *translator) variant(x *ref, e env) string {
+	d := t.definition(x)
+	key := x.name + "|" + e.key()
+	if v, ok := t.variants[key]; ok {
+		return v.Name
+	}
+	v := &lang.Definition{Name: name(x.name)}
+	for n := 2; t.names[v.Name]; n++ {
+		v.Name = name(x.name) + "_" + strconv.Itoa(n)
+	}
+	t.names[v.Name] = true
+	t.variants[key] = v
+	t.defs = append(t.defs, v)
+
+	if p, ok := d.body.(*par); ok {
+		v.Body = &lang.CmdList{Stmts: []lang.Stmt{t.parallel(p, e)}}
+		return v.Name
+	}
+	f := &frame{env: e, self: x.name, tails: map[lang.Stmt]bool{}}
+	brs := t.branches(d.body, f, true)
+	if len(f.tails) > 0 {
+		v.Body = &lang.CmdList{Stmts: t.loop(brs, f)}
+		return v.Name
+	}
+	stmts := t.alternative(brs)
+	if declares(stmts) {
+		// a definition is written in place of the references to it,
+		// its variables must not clash with those of the process.
+		stmts = []lang.Stmt{alternative(guarded(nil, stmts))}
+	}
+	v.Body = &lang.CmdList{Stmts: stmts}
+	return v.Name
+}
+
+// loop returns the repetitive command of the branches of a recursive
+// process.
+func (t *translator) loop(brs []*branch, f *frame) []lang.Stmt {
+	again := &lang.Ident{Name: t.again}
+	repeated := true
+	for _, b := range brs {
+		repeated = repeated && len(b.body) > 0 && f.tails[b.body[len(b.body)-1]]
+	}
+	if repeated {
+		cmds := []*lang.GuardedCmd{}
+		for _, b := range brs {
+			b.body = b.body[:len(b.body)-1]
+			cmds = append(cmds, b.guarded())
+		}
+		return []lang.Stmt{&lang.RepetitiveCmd{Alt: &lang.AlternativeCmd{Cmds: cmds}}}
+	}
+	body := append([]lang.Stmt{
+		&lang.AssignmentCmd{Target: again, Value: &lang.Ident{Name: "false"}},
+	}, t.alternative(brs)...)
+	return []lang.Stmt{alternative(guarded(nil, []lang.Stmt{
+		&lang.Declaration{Names: []*lang.Ident{again}, Type: &lang.NamedType{Name: "boolean"}},
+		&lang.AssignmentCmd{Target: again, Value: &lang.Ident{Name: "true"}},
+		&lang.RepetitiveCmd{Alt: alternative(guarded(again, body))},
+	}))}
+}
+
+// frame is the context of the translation of a process.
+type frame struct {
+	env   env
+	self  string             // the process repeated by tail recursion
+	tails map[lang.Stmt]bool // the commands repeating it
+}
+
+// block records the variables declared by a command list.
+type block map[string]string
+
+// branch is a guarded command in translation.
+type branch struct {
+	conds []lang.Expr
+	decl  *lang.Declaration
+	input *lang.InputCmd
+	body  []lang.Stmt
+}
+
+func (b *branch) guarded() *lang.GuardedCmd {
+	list := []lang.Node{}
+	for _, c := range b.conds {
+		list = append(list, c)
+	}
+	if b.decl != nil {
+		list = append(list, b.decl)
+	}
+	if len(list) == 0 && b.input == nil {
+		list = append(list, &lang.Ident{Name: "true"})
+	}
+	return &lang.GuardedCmd{Guard: &lang.Guard{List: list, Input: b.input}, Body: cmdList(b.body)}
+}
+
+// branches returns the branches of the alternative command choosing
+// among the first events of p.
+func (t *translator) branches(p proc, f *frame, tail bool) []*branch {
+	switch x := p.(type) {
+	case *choice:
+		if x.internal {
+			return []*branch{
+				{body: t.stmts(x.l, f, block{}, tail)},
+				{body: t.stmts(x.r, f, block{}, tail)},
+			}
+		}
+		return append(t.branches(x.l, f, tail), t.branches(x.r, f, tail)...)
+	case *prefix:
+		if decl, in := t.input(x, f.env); in != nil {
+			blk := block{}
+			if decl != nil {
+				blk[x.bind] = t.chans[x.ch]
+			}
+			return []*branch{{decl: decl, input: in, body: t.stmts(x.then, f, blk, tail)}}
+		}
+	case *guard:
+		brs := t.branches(x.then, f, tail)
+		for _, b := range brs {
+			b.conds = append([]lang.Expr{x.cond}, b.conds...)
+		}
+		return brs
+	case *cond:
+		then, els := t.branches(x.then, f, tail), t.branches(x.els, f, tail)
+		for _, b := range then {
+			b.conds = append([]lang.Expr{x.cond}, b.conds...)
+		}
+		for _, b := range els {
+			b.conds = append([]lang.Expr{not(x.cond)}, b.conds...)
+		}
+		return append(then, els...)
+	case *stop:
+		if !x.skip {
+			return nil
+		}
+	}
+	return []*branch{{body: t.stmts(p, f, block{}, tail)}}
+}
+
+// alternative returns the commands choosing among brs.
+func (t *translator) alternative(brs []*branch) []lang.Stmt {
+	switch {
+	case len(brs) == 0:
+		return []lang.Stmt{&lang.SkipCmd{}}
+	case len(brs) == 1 && len(brs[0].conds) == 0 && brs[0].input == nil:
+		return brs[0].body
+	}
+	alt := &lang.AlternativeCmd{}
+	for _, b := range brs {
+		alt.Cmds = append(alt.Cmds, b.guarded())
+	}
+	return []lang.Stmt{alt}
+}
+
+// stmts returns the commands of p, whose variables are declared in
+// blk. A reference to the process repeated in f is a tail call if
+// tail is set.
+func (t *translator) stmts(p proc, f *frame, blk block, tail bool) []lang.Stmt {
+	switch x := p.(type) {
+	case *stop:
+		return []lang.Stmt{&lang.SkipCmd{}}
+	case *ref:
+		if tail && x.name == f.self {
+			s := &lang.AssignmentCmd{Target: &lang.Ident{Name: t.again}, Value: &lang.Ident{Name: "true"}}
+			f.tails[s] = true
+			return []lang.Stmt{s}
+		}
+		return []lang.Stmt{&lang.ProcRef{NamePos: x.pos, Name: t.variant(x, f.env)}}
+	case *prefix:
+		out := []lang.Stmt{}
+		if decl, in := t.input(x, f.env); in != nil {
+			if decl != nil {
+				typ, ok := blk[x.bind]
+				if ok && typ != t.chans[x.ch] {
+					// the variable is redeclared of another type.
+					return t.alternative(t.branches(p, f, tail))
+				}
+				if !ok {
+					blk[x.bind] = t.chans[x.ch]
+					out = append(out, decl)
+				}
+			}
+			out = append(out, in)
+		} else {
+			out = append(out, t.output(x, f.env))
+		}
+		return then(out, t.stmts(x.then, f, blk, tail))
+	case *guard:
+		return []lang.Stmt{alternative(
+			guarded(x.cond, t.stmts(x.then, f, block{}, tail)),
+			guarded(not(x.cond), []lang.Stmt{&lang.SkipCmd{}}),
+		)}
+	case *cond:
+		return []lang.Stmt{alternative(
+			guarded(x.cond, t.stmts(x.then, f, block{}, tail)),
+			guarded(not(x.cond), t.stmts(x.els, f, block{}, tail)),
+		)}
+	case *choice:
+		return t.alternative(t.branches(p, f, tail))
+	case *seq:
+		l := t.stmts(x.l, f, block{}, false)
+		if declares(l) {
+			l = []lang.Stmt{alternative(guarded(nil, l))}
+		}
+		return then(l, t.stmts(x.r, f, blk, tail))
+	case *par:
+		return []lang.Stmt{t.parallel(x, f.env)}
+	}
+	panic(fmt.Sprintf("unexpected %T", p))
+}
+
+// input returns the declaration of the variable and the input command
+// of the event x, or a nil input command if x is an output.
+func (t *translator) input(x *prefix, e env) (*lang.Declaration, *lang.InputCmd) {
+	end, internal := e[x.ch]
+	src := &lang.ProcName{NamePos: x.pos, Name: name(x.ch)}
+	if internal {
+		src.Name = end.peer
+	}
+	switch {
+	case x.dir == "?":
+		v := &lang.Ident{NamePos: x.pos, Name: name(x.bind)}
+		decl := &lang.Declaration{Names: []*lang.Ident{v}, Type: &lang.NamedType{Name: t.chans[x.ch]}}
+		var target lang.Expr = v
+		if end.tagged {
+			target = &lang.StructuredExpr{Start: x.pos, Constructor: name(x.ch), Args: []lang.Expr{v}}
+		}
+		return decl, &lang.InputCmd{Source: src, Target: target}
+	case x.dir == "" && !(internal && end.out):
+		return nil, &lang.InputCmd{Source: src, Target: &lang.StructuredExpr{Start: x.pos, Constructor: name(x.ch)}}
+	}
+	return nil, nil
+}
+
+// output returns the output command of the event x.
+func (t *translator) output(x *prefix, e env) *lang.OutputCmd {
+	end, internal := e[x.ch]
+	dst := &lang.ProcName{NamePos: x.pos, Name: name(x.ch)}
+	if internal {
+		dst.Name = end.peer
+	}
+	switch {
+	case x.dir == "":
+		return &lang.OutputCmd{Dest: dst, Value: &lang.StructuredExpr{Start: x.pos, Constructor: name(x.ch)}}
+	case end.tagged:
+		return &lang.OutputCmd{Dest: dst, Value: &lang.StructuredExpr{Start: x.pos, Constructor: name(x.ch), Args: []lang.Expr{x.value}}}
+	}
+	return &lang.OutputCmd{Dest: dst, Value: x.value}
+}
+
+// then returns the commands of a followed by those of b, omitting
+// skip.
+func then(a, b []lang.Stmt) []lang.Stmt {
+	if _, ok := a[len(a)-1].(*lang.SkipCmd); ok {
+		a = a[:len(a)-1]
+	}
+	if _, ok := b[0].(*lang.SkipCmd); ok && len(b) == 1 && len(a) > 0 {
+		return a
+	}
+	return append(a, b...)
+}
+
+// declares reports whether stmts declare variables.
+func declares(stmts []lang.Stmt) bool {
+	for _, s := range stmts {
+		if _, ok := s.(*lang.Declaration); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func cmdList(stmts []lang.Stmt) *lang.CmdList {
+	if len(stmts) == 0 {
+		stmts = []lang.Stmt{&lang.SkipCmd{}}
+	}
+	return &lang.CmdList{Stmts: stmts}
+}
+
+func alternative(cmds ...*lang.GuardedCmd) *lang.AlternativeCmd {
+	return &lang.AlternativeCmd{Cmds: cmds}
+}
+
+// guarded returns the guarded command of the condition c, which is
+// true if c is nil, and the body stmts.
+func guarded(c lang.Expr, stmts []lang.Stmt) *lang.GuardedCmd {
+	if c == nil {
+		c = &lang.Ident{Name: "true"}
+	}
+	return &lang.GuardedCmd{Guard: &lang.Guard{List: []lang.Node{c}}, Body: cmdList(stmts)}
+}
+
+// negations are the negated comparisons.
+var negations = map[lang.Token]lang.Token{
+	lang.EQ: lang.NEQ, lang.NEQ: lang.EQ,
+	lang.LT: lang.GEQ, lang.GEQ: lang.LT,
+	lang.GT: lang.LEQ, lang.LEQ: lang.GT,
+}
+
+// not returns the negation of c.
+func not(c lang.Expr) lang.Expr {
+	if p, ok := c.(*lang.ParenExpr); ok {
+		c = p.X
+	}
+	if u, ok := c.(*lang.UnaryExpr); ok && u.Op == lang.NOT {
+		return u.X
+	}
+	if b, ok := c.(*lang.BinaryExpr); ok {
+		if op, ok := negations[b.Op]; ok {
+			return &lang.BinaryExpr{X: b.X, Op: op, Y: b.Y}
+		}
+		c = &lang.ParenExpr{X: c}
+	}
+	return &lang.UnaryExpr{Op: lang.NOT, X: c}
+}
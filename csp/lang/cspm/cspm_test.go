@@ -0,0 +1,228 @@
+package cspm_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/changkun/gobase/csp/algebra"
+	"github.com/changkun/gobase/csp/lang"
+	"github.com/changkun/gobase/csp/lang/cspm"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		src, main string
+		want      string
+	}{
+		{
+			src: `-- a buffer of two places
+channel in, mid, out : {0..9}
+LEFT = in?x -> mid!x -> LEFT
+RIGHT = mid?x -> out!x -> RIGHT
+SYSTEM = LEFT [| {| mid |} |] RIGHT
+assert SYSTEM :[deadlock free]
+`,
+			want: `LEFT = (*[x:integer; in?x → RIGHT!x])
+RIGHT = (*[x:integer; LEFT?x → out!x])
+[LEFT::LEFT || RIGHT::RIGHT]
+`,
+		},
+		{
+			src: `channel up, down
+channel n : Int
+COUNT = up -> n!1 -> COUNT [] down -> (n!0 -> SKIP ; COUNT)
+CHOOSE = up -> STOP |~| down -> STOP
+USER = up -> down -> USER
+SYSTEM = USER [| {| up, down |} |] COUNT
+`,
+			want: `USER = (*[true → COUNT!up(); COUNT!down()])
+COUNT = (*[USER?up() → n!1 □ USER?down() → n!0])
+[USER::USER || COUNT::COUNT]
+`,
+		},
+		{
+			src: `channel a, b : {0..9}
+P = a?x -> (x > 4 & b!x -> P [] x <= 4 & b!0 -> P)
+Q = a?x -> if x == 0 then SKIP else b!(x - 1) -> Q
+R = a?x -> b!x -> a?x -> SKIP ; a?x -> STOP
+`,
+			main: "Q",
+			want: `Q = (
+    [true →
+        again:boolean;
+        again := true;
+        *[again →
+            again := false;
+            [x:integer; a?x →
+                [x = 0 → skip □ x ≠ 0 → b!(x - 1); again := true]
+            ]
+        ]
+    ]
+)
+Q::Q
+`,
+		},
+		{
+			src: `channel a, b : {0..9}
+R = a?x -> b!x -> a?x -> SKIP ; a?x -> STOP
+`,
+			want: `R = ([true → [true → x:integer; a?x; b!x; a?x]; x:integer; a?x])
+R::R
+`,
+		},
+		{
+			src: `channel a, b : {0..9}
+channel done
+P = a!1 -> b!2 -> done -> SKIP
+Q = a?x -> b?y -> done -> SKIP
+SYSTEM = P [{| a, b, done |} || {| a, b, done |}] Q
+`,
+			want: `P = (Q!a(1); Q!b(2); Q!done())
+Q = ([x:integer; P?a(x) → y:integer; P?b(y); P?done()])
+[P::P || Q::Q]
+`,
+		},
+		{
+			src: `channel in, out : Int
+ECHO = in?x -> out!x -> ECHO
+SYSTEM = ECHO ||| (ECHO ||| in?x -> SKIP) \ {| in |}
+`,
+			want: `ECHO = (*[x:integer; in?x → out!x])
+[ECHO::ECHO || ECHO_2::ECHO || P3::x:integer; in?x]
+`,
+		},
+	}
+	for _, tt := range tests {
+		prog, err := cspm.Parse(tt.src, tt.main)
+		if err != nil {
+			t.Fatalf("%v: unexpected error: %v", t.Name(), err)
+		}
+		if got := lang.Source(prog); got != tt.want {
+			t.Fatalf("%v: expected:\n%v\ngot:\n%v", t.Name(), tt.want, got)
+		}
+	}
+}
+
+func TestParseRun(t *testing.T) {
+	scripts := []string{
+		`channel in, mid, out : {0..9}
+LEFT = in?x -> mid!x -> LEFT
+RIGHT = mid?x -> out!(x * x) -> RIGHT
+SYSTEM = LEFT [| {| mid |} |] RIGHT
+`,
+		// LEFT and RIGHT communicate structured values mid(x) and
+		// ack().
+		`channel in, mid, out : {0..9}
+channel ack
+LEFT = in?x -> mid!x -> ack -> LEFT
+RIGHT = mid?x -> ack -> out!(x * x) -> RIGHT
+SYSTEM = LEFT [| {| mid, ack |} |] RIGHT
+`,
+	}
+	for _, src := range scripts {
+		prog, err := cspm.Parse(src, "")
+		if err != nil {
+			t.Fatalf("%v: unexpected error: %v", t.Name(), err)
+		}
+		in, out := make(chan lang.Value), make(chan lang.Value)
+		interp := &lang.Interpreter{
+			Program: prog,
+			Inputs:  map[string]<-chan lang.Value{"in": in},
+			Outputs: map[string]chan<- lang.Value{"out": out},
+		}
+		errc := make(chan error, 1)
+		go func() { errc <- interp.Run(context.Background()) }()
+		go func() {
+			for i := 1; i <= 3; i++ {
+				in <- i
+			}
+			close(in)
+		}()
+		got := []int{}
+		for v := range out {
+			got = append(got, v.(int))
+		}
+		if err := <-errc; err != nil {
+			t.Fatalf("%v: unexpected error: %v\n%v", t.Name(), err, lang.Source(prog))
+		}
+		if len(got) != 3 || got[0] != 1 || got[1] != 4 || got[2] != 9 {
+			t.Fatalf("%v: expected: [1 4 9], got: %v", t.Name(), got)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		src, main string
+		err       string
+	}{
+		{src: "", err: "1:1: no process defined"},
+		{src: "channel c\nP = c -> P", main: "MAIN", err: "undefined process MAIN"},
+		{src: "channel c\nP = c -> Q", err: "2:10: undefined process Q"},
+		{src: "channel a : {0..9}\nP = a -> P", err: "2:5: channel a carries data"},
+		{src: "channel c\nP = c!1 -> P", err: "2:5: channel c carries no data"},
+		{src: "channel c : {0..9}\nP = c.1.2 -> P", err: "2:8: events of several fields are not supported"},
+		{src: "channel a, b\nP = a -> P\nQ = P [[ a <- b ]]", err: "3:7: renaming is not supported"},
+		{src: "P(n) = STOP", err: "1:2: process parameters are not supported"},
+		{src: "datatype T = A | B", err: "1:1: datatype declarations are not supported"},
+		{src: "channel c\nP = c -> P\nS = P [| {| c |} |] P [| {| c |} |] P", err: "3:23: channel c must connect a single process on either side"},
+		{src: "channel c\nP = c -> P [| {| c.1 |} |] P", err: "2:19: sets of events are not supported, use {| c |}"},
+		{src: "P = STOP {- comment", err: "1:10: unterminated comment"},
+		{src: "channel a, b\nP = a -> b -> P\nS = P [{| a |} || {| a |}] P", err: "3:7: channel b is outside the alphabet of its process"},
+	}
+	for _, tt := range tests {
+		_, err := cspm.Parse(tt.src, tt.main)
+		if err == nil || err.Error() != tt.err {
+			t.Fatalf("%v: %q: expected: %v, got: %v", t.Name(), tt.src, tt.err, err)
+		}
+	}
+}
+
+func TestAlgebra(t *testing.T) {
+	a, b, c, d := algebra.Event("a"), algebra.Event("b"), algebra.Event("c"), algebra.Event("d")
+	tests := []struct {
+		src  string
+		want algebra.Process
+	}{
+		{
+			src: `channel a, b, c
+P = a -> b -> STOP
+Q = b -> c -> STOP
+SYSTEM = P [{| a, b |} || {| b, c |}] Q
+`,
+			want: algebra.ParAlpha(
+				algebra.Prefix(a, algebra.Prefix(b, algebra.Stop())), []algebra.Event{a, b},
+				algebra.Prefix(b, algebra.Prefix(c, algebra.Stop())), []algebra.Event{b, c}),
+		},
+		{
+			src: `channel in, out : {0..1}
+COPY = in?x -> out!x -> COPY
+`,
+			want: algebra.Rec("COPY", algebra.ExtChoice(
+				algebra.Prefix("in.0", algebra.Prefix("out.0", algebra.Var("COPY"))),
+				algebra.Prefix("in.1", algebra.Prefix("out.1", algebra.Var("COPY"))))),
+		},
+		{
+			src: `channel c : Bool
+channel d
+P = c?b -> (b & d -> SKIP) \ {| d |}
+`,
+			want: algebra.Hide(algebra.ExtChoice(
+				algebra.Prefix("c.false", algebra.Stop()),
+				algebra.Prefix("c.true", algebra.Prefix(d, algebra.Skip()))), []algebra.Event{d}),
+		},
+	}
+	for _, tt := range tests {
+		got, err := cspm.Algebra(tt.src, "")
+		if err != nil {
+			t.Fatalf("%v: unexpected error: %v", t.Name(), err)
+		}
+		if got.String() != tt.want.String() {
+			t.Fatalf("%v: expected: %v, got: %v", t.Name(), tt.want, got)
+		}
+	}
+
+	if _, err := cspm.Algebra("channel c : Int\nP = c?x -> P", ""); err == nil || err.Error() != "2:5: channel c carries infinitely many values" {
+		t.Fatalf("%v: expected an error of an infinite channel, got: %v", t.Name(), err)
+	}
+}
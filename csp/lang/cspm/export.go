@@ -0,0 +1,834 @@
+package cspm
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/changkun/gobase/csp/lang"
+)
+
+// Config configures an exported script.
+type Config struct {
+	// Main is the name of the process composing the processes of a
+	// parallel command, and of a program which is not one. The
+	// default is SYSTEM.
+	Main string
+	// Integers is the set of the integers communicated on channels,
+	// the default is {0..9}. FDR enumerates the values of a channel,
+	// which must therefore be finite.
+	Integers string
+}
+
+// Export returns the CSPm script of prog, which FDR checks. Every
+// process of its parallel command is a process of the script, and the
+// parallel command is their generalised parallel composition, or for
+// an interleaving their interleaving, named by cfg.Main. A program without a body, such as the descriptions of
+// package extract, is exported as a process for each of its
+// definitions, which hides the channels of the names the definition
+// hides.
+//
+// Processes communicate on channels named by the communicating
+// processes, such as X_Y for the values X outputs to Y, and by the
+// constructors of structured values, such as X_Y_has for has(n) whose
+// components are the fields of the events. Variables are parameters of
+// the processes a command list is split into, an assignment binds a new
+// name such as n'.
+//
+// CSPm has no failure and no termination of processes for each other:
+// an alternative command whose guards are false deadlocks, and a
+// repetitive command terminates once all of its boolean guards are
+// false only. Guards without input are chosen among by the environment
+// of the process, like inputs. Arrays, bound variables, nested parallel
+// commands, renaming, wait commands, timeouts and probabilistic choices
+// are not supported.
+func Export(prog *lang.Program, cfg Config) (src []byte, err error) {
+	if cfg.Main == "" {
+		cfg.Main = "SYSTEM"
+	}
+	if cfg.Integers == "" {
+		cfg.Integers = "{0..9}"
+	}
+	x := &exporter{
+		cfg:    cfg,
+		defs:   map[string]*lang.Definition{},
+		names:  map[string]bool{},
+		chans:  map[string]*channel{},
+		parens: map[string]bool{},
+	}
+	for _, d := range prog.Defs {
+		x.defs[d.Name] = d
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			f, ok := r.(failure)
+			if !ok {
+				panic(r)
+			}
+			src, err = nil, f.err
+		}
+	}()
+	x.recursion(prog)
+	x.program(prog)
+	return x.source(), nil
+}
+
+// exporter exports a program.
+type exporter struct {
+	cfg   Config
+	defs  map[string]*lang.Definition
+	names map[string]bool // names of the channels and processes
+	chans map[string]*channel
+	order []*channel
+	eqs   []*equation
+	// parens are the parenthesized processes, whose parentheses are
+	// omitted where a process is not an operand.
+	parens map[string]bool
+}
+
+// channel is a channel of the script.
+type channel struct {
+	name     string
+	src, dst string   // the labels of the communicating processes, empty if external
+	ext      string   // the external name, empty between processes
+	fields   []string // the types of the fields
+}
+
+// equation defines a process of the script.
+type equation struct {
+	name   string
+	params []string
+	body   string
+}
+
+// process is a process in translation.
+type process struct {
+	label   string
+	name    string          // the name of its equation
+	peers   map[string]bool // the labels of the processes of the parallel command
+	eq      *equation
+	scope   *scope
+	bases   map[string]bool // the names of the variables
+	fresh   bool            // whether no command was translated yet
+	helpers int
+}
+
+// scope is a scope of variables.
+type scope struct {
+	outer *scope
+	vars  map[string]*variable
+	order []*variable
+}
+
+// variable is a variable, which is bound to a new name by every
+// assignment.
+type variable struct {
+	typ     string
+	base    string
+	version int // the number of assignments, -1 if undefined
+}
+
+// name returns the current name of v, such as n''.
+func (v *variable) name() string {
+	return v.base + strings.Repeat("'", v.version)
+}
+
+// value returns the current value of v, the zero value of its type if
+// v is undefined.
+func (v *variable) value() string {
+	if v.version >= 0 {
+		return v.name()
+	}
+	switch v.typ {
+	case "boolean":
+		return "false"
+	case "character":
+		return "' '"
+	}
+	return "0"
+}
+
+func (p *process) lookup(name string) *variable {
+	for s := p.scope; s != nil; s = s.outer {
+		if v, ok := s.vars[name]; ok {
+			return v
+		}
+	}
+	return nil
+}
+
+// vars returns the variables of all scopes of p, the outermost first.
+func (p *process) vars() []*variable {
+	vars := []*variable{}
+	for s := p.scope; s != nil; s = s.outer {
+		vars = append(append([]*variable{}, s.order...), vars...)
+	}
+	return vars
+}
+
+func (p *process) declare(id *lang.Ident, t lang.Type) {
+	nt, ok := t.(*lang.NamedType)
+	if !ok {
+		errorf(t.Pos(), "arrays are not supported")
+	}
+	if _, ok := p.scope.vars[id.Name]; ok {
+		errorf(id.Pos(), "%s redeclared", id.Name)
+	}
+	base := ident(id.Name)
+	for n := 2; p.bases[base]; n++ {
+		base = ident(id.Name) + "_" + strconv.Itoa(n)
+	}
+	p.bases[base] = true
+	v := &variable{typ: nt.Name, base: base, version: -1}
+	p.scope.vars[id.Name] = v
+	p.scope.order = append(p.scope.order, v)
+}
+
+// reserved are the keywords and builtins of CSPm which are valid
+// identifiers of the notation.
+var reserved = map[string]bool{
+	"Int": true, "Bool": true, "Char": true, "Events": true, "Proc": true,
+	"card": true, "diff": true, "head": true, "tail": true, "length": true,
+	"member": true, "union": true, "inter": true, "null": true, "seq": true,
+	"set": true, "elem": true, "concat": true, "empty": true, "error": true,
+	"show": true, "CHAOS": true, "RUN": true, "DIV": true,
+}
+
+// ident returns the CSPm identifier of the identifier s.
+func ident(s string) string {
+	s = strings.ReplaceAll(s, " ", "_")
+	if keywords[s] || reserved[s] {
+		return s + "_"
+	}
+	return s
+}
+
+// unique returns a name of the script based on s.
+func (x *exporter) unique(s string) string {
+	name := ident(s)
+	for n := 2; x.names[name]; n++ {
+		name = ident(s) + "_" + strconv.Itoa(n)
+	}
+	x.names[name] = true
+	return name
+}
+
+// recursion rejects definitions referring to themselves, which are
+// expanded in place.
+func (x *exporter) recursion(prog *lang.Program) {
+	state := map[string]int{} // 1 while expanding, 2 once done
+	var visit func(d *lang.Definition)
+	visit = func(d *lang.Definition) {
+		state[d.Name] = 1
+		lang.Inspect(d.Body, func(n lang.Node) bool {
+			if r, ok := n.(*lang.ProcRef); ok {
+				switch ref := x.defs[r.Name]; {
+				case ref == nil:
+				case state[r.Name] == 1:
+					errorf(r.Pos(), "recursive process %s is not supported", r.Name)
+				case state[r.Name] == 0:
+					visit(ref)
+				}
+			}
+			return true
+		})
+		state[d.Name] = 2
+	}
+	for _, d := range prog.Defs {
+		if state[d.Name] == 0 {
+			visit(d)
+		}
+	}
+}
+
+func (x *exporter) program(prog *lang.Program) {
+	body := prog.Body
+	if len(body.Stmts) == 0 {
+		for _, d := range prog.Defs {
+			x.definition(d)
+		}
+		return
+	}
+	par, ok := body.Stmts[0].(*lang.ParallelCmd)
+	if !ok || len(body.Stmts) > 1 {
+		x.process(x.cfg.Main, nil, body)
+		return
+	}
+
+	labels := map[string]bool{}
+	for _, p := range par.Procs {
+		if p.Label == nil {
+			errorf(p.Pos(), "processes without labels are not supported")
+		}
+		if len(p.Label.Subscripts) > 0 {
+			errorf(p.Label.Pos(), "arrays of processes are not supported")
+		}
+		if labels[p.Label.Name] {
+			errorf(p.Label.Pos(), "duplicate process label %s", p.Label.Name)
+		}
+		labels[p.Label.Name] = true
+	}
+	peers := labels
+	if par.Interleave {
+		peers = nil
+	}
+	procs := []*process{}
+	for _, p := range par.Procs {
+		procs = append(procs, x.process(p.Label.Name, peers, p.Body))
+	}
+	if len(procs) == 1 {
+		return
+	}
+
+	// the processes are composed one by one, synchronising on the
+	// channels connecting them to those composed before.
+	composed := map[string]bool{procs[0].label: true}
+	system := procs[0].name
+	for i, p := range procs[1:] {
+		sync := []string{}
+		for _, c := range x.order {
+			if composed[c.src] && c.dst == p.label || composed[c.dst] && c.src == p.label {
+				sync = append(sync, c.name)
+			}
+		}
+		if i > 0 {
+			system = "(" + system + ")"
+		}
+		if len(sync) == 0 {
+			system += " ||| " + p.name
+		} else {
+			system += " [| {| " + strings.Join(sync, ", ") + " |} |] " + p.name
+		}
+		composed[p.label] = true
+	}
+	x.eqs = append(x.eqs, &equation{name: x.unique(x.cfg.Main), body: system})
+}
+
+// definition translates the definition d as a process of its own, its
+// communications with the names it hides hidden by the process of its
+// name.
+func (x *exporter) definition(d *lang.Definition) {
+	if len(d.Hidden) == 0 {
+		x.process(d.Name, nil, d.Body)
+		return
+	}
+	eq := &equation{name: x.unique(d.Name)}
+	x.eqs = append(x.eqs, eq)
+	p := x.process(d.Name, nil, d.Body)
+	hidden := map[string]bool{}
+	for _, id := range d.Hidden {
+		hidden[id.Name] = true
+	}
+	chans := []string{}
+	for _, c := range x.order {
+		if hidden[c.ext] {
+			chans = append(chans, c.name)
+		}
+	}
+	eq.body = p.name
+	if len(chans) > 0 {
+		eq.body += " \\ {| " + strings.Join(chans, ", ") + " |}"
+	}
+}
+
+// process translates the process label, which communicates with the
+// processes labelled by peers.
+func (x *exporter) process(label string, peers map[string]bool, body *lang.CmdList) *process {
+	p := &process{
+		label: label,
+		name:  x.unique(label),
+		peers: peers,
+		scope: &scope{vars: map[string]*variable{}},
+		bases: map[string]bool{},
+		fresh: true,
+	}
+	p.eq = &equation{name: p.name}
+	x.eqs = append(x.eqs, p.eq)
+	text := x.list(p, body.Stmts, nil)
+	if p.eq.body == "" {
+		p.eq.body = x.unparen(text)
+	}
+	return p
+}
+
+// cont is the continuation of a command list, a nil continuation
+// terminates the process.
+type cont struct {
+	text func() string
+	call bool // whether text calls a process
+}
+
+// resume returns the process continuing with k.
+func resume(k *cont) string {
+	if k == nil {
+		return "SKIP"
+	}
+	return k.text()
+}
+
+// call returns the call of the process name with the current values of
+// vars.
+func call(name string, vars []*variable) string {
+	if len(vars) == 0 {
+		return name
+	}
+	args := make([]string, len(vars))
+	for i, v := range vars {
+		args[i] = v.value()
+	}
+	return name + "(" + strings.Join(args, ", ") + ")"
+}
+
+// equation defines the process name, whose parameters are vars, by
+// the process body returns.
+func (x *exporter) equation(p *process, name string, vars []*variable, body func() string) {
+	eq := p.eq
+	if name != p.name {
+		eq = &equation{name: name}
+		x.eqs = append(x.eqs, eq)
+	}
+	versions := make([]int, len(vars))
+	for i, v := range vars {
+		versions[i] = v.version
+		v.version = 0
+		eq.params = append(eq.params, v.name())
+	}
+	eq.body = x.unparen(body())
+	for i, v := range vars {
+		v.version = versions[i]
+	}
+}
+
+// helper returns the name of a new process of p.
+func (x *exporter) helper(p *process) string {
+	p.helpers++
+	return x.unique(p.name + "_" + strconv.Itoa(p.helpers))
+}
+
+// share returns a continuation calling a process continuing with k,
+// which can be used more than once.
+func (x *exporter) share(p *process, k *cont) *cont {
+	if k == nil || k.call {
+		return k
+	}
+	name, vars := x.helper(p), p.vars()
+	x.equation(p, name, vars, k.text)
+	return &cont{text: func() string { return call(name, vars) }, call: true}
+}
+
+// list returns the process executing stmts and continuing with k.
+func (x *exporter) list(p *process, stmts []lang.Stmt, k *cont) string {
+	if len(stmts) == 0 {
+		return resume(k)
+	}
+	rest := k
+	if len(stmts) > 1 {
+		rest = &cont{text: func() string { return x.list(p, stmts[1:], k) }}
+	}
+	return x.stmt(p, stmts[0], rest)
+}
+
+func (x *exporter) stmt(p *process, s lang.Stmt, rest *cont) string {
+	fresh := p.fresh
+	switch s := s.(type) {
+	case *lang.Declaration:
+		for _, id := range s.Names {
+			p.declare(id, s.Type)
+		}
+		return resume(rest)
+	case *lang.SkipCmd:
+		return resume(rest)
+	case *lang.ProcRef:
+		d, ok := x.defs[s.Name]
+		if !ok {
+			errorf(s.Pos(), "undefined process %s", s.Name)
+		}
+		if len(s.Renames) > 0 {
+			errorf(s.Renames[0].Pos(), "renaming is not supported")
+		}
+		return x.list(p, d.Body.Stmts, rest)
+	}
+
+	p.fresh = false
+	switch s := s.(type) {
+	case *lang.AssignmentCmd:
+		id, ok := s.Target.(*lang.Ident)
+		if !ok {
+			errorf(s.Target.Pos(), "structured values are not supported")
+		}
+		v := p.lookup(id.Name)
+		if v == nil {
+			errorf(id.Pos(), "undefined variable %s", id.Name)
+		}
+		if typ := x.typeOf(p, s.Value); typ != v.typ {
+			errorf(s.Value.Pos(), "cannot assign %s to %s of type %s", typ, id.Name, v.typ)
+		}
+		value := x.expr(p, s.Value)
+		v.version++
+		return x.paren([]string{"let " + v.name() + " = " + value + " within " + resume(rest)})
+	case *lang.InputCmd:
+		return x.input(p, s, rest)
+	case *lang.OutputCmd:
+		c, args := x.port(p, s.Dest, s.Value, false)
+		event := c.name
+		for _, a := range args {
+			event += "!" + x.field(p, a)
+		}
+		return event + " -> " + resume(rest)
+	case *lang.AlternativeCmd:
+		return x.paren(x.choice(p, s, x.share(p, rest)))
+	case *lang.RepetitiveCmd:
+		// a repetitive command at the start of a process without
+		// variables is the process itself.
+		vars := p.vars()
+		name := p.name
+		if !fresh || len(vars) > 0 {
+			name = x.helper(p)
+		}
+		loop := &cont{text: func() string { return call(name, vars) }, call: true}
+		text := loop.text()
+		x.equation(p, name, vars, func() string {
+			brs := x.choice(p, s.Alt, loop)
+			if exit := x.exit(p, s.Alt); exit != "" {
+				brs = append(brs, exit+" & "+resume(rest))
+			}
+			return x.paren(brs)
+		})
+		return text
+	case *lang.WaitCmd:
+		errorf(s.Pos(), "wait commands are not supported")
+	case *lang.ParallelCmd:
+		errorf(s.Pos(), "nested parallel commands are not supported")
+	}
+	errorf(s.Pos(), "unexpected %T", s)
+	return ""
+}
+
+// choice returns the branches of the external choice among the guarded
+// commands of alt, which continue with k.
+func (x *exporter) choice(p *process, alt *lang.AlternativeCmd, k *cont) []string {
+	vars := p.vars()
+	versions := make([]int, len(vars))
+	for i, v := range vars {
+		versions[i] = v.version
+	}
+	brs := []string{}
+	for _, gc := range alt.Cmds {
+		if len(gc.Ranges) > 0 {
+			errorf(gc.Ranges[0].Pos(), "bound variables are not supported")
+		}
+		if gc.Guard.Timeout != nil {
+			errorf(gc.Guard.Timeout.Pos(), "timeouts are not supported")
+		}
+		if gc.Guard.Prob != nil {
+			errorf(gc.Guard.Prob.Pos(), "probabilistic choices are not supported")
+		}
+		// every branch starts from the same values.
+		for i, v := range vars {
+			v.version = versions[i]
+		}
+		p.scope = &scope{outer: p.scope, vars: map[string]*variable{}}
+		text := ""
+		if cond := x.cond(p, gc.Guard); cond != nil {
+			text = x.expr(p, cond) + " & "
+		}
+		body := &cont{text: func() string { return x.list(p, gc.Body.Stmts, k) }}
+		if gc.Guard.Input != nil {
+			text += x.input(p, gc.Guard.Input, body)
+		} else {
+			text += body.text()
+		}
+		brs = append(brs, text)
+		p.scope = p.scope.outer
+	}
+	for i, v := range vars {
+		v.version = versions[i]
+	}
+	return brs
+}
+
+// cond returns the conjunction of the boolean guards of g, nil if
+// they are true, and declares the variables of g.
+func (x *exporter) cond(p *process, g *lang.Guard) lang.Expr {
+	var cond lang.Expr
+	for _, n := range g.List {
+		switch n := n.(type) {
+		case *lang.Declaration:
+			for _, id := range n.Names {
+				p.declare(id, n.Type)
+			}
+		case lang.Expr:
+			if typ := x.typeOf(p, n); typ != "boolean" {
+				errorf(n.Pos(), "guard of type %s", typ)
+			}
+			if id, ok := n.(*lang.Ident); ok && id.Name == "true" {
+				continue
+			}
+			if cond == nil {
+				cond = n
+			} else {
+				cond = &lang.BinaryExpr{X: cond, Op: lang.AND, Y: n}
+			}
+		}
+	}
+	return cond
+}
+
+// exit returns the condition terminating the repetitive command of
+// alt, empty if it never terminates.
+func (x *exporter) exit(p *process, alt *lang.AlternativeCmd) string {
+	var some lang.Expr
+	for _, gc := range alt.Cmds {
+		p.scope = &scope{outer: p.scope, vars: map[string]*variable{}}
+		cond := x.cond(p, gc.Guard)
+		p.scope = p.scope.outer
+		if cond == nil {
+			return ""
+		}
+		if some == nil {
+			some = cond
+		} else {
+			some = &lang.BinaryExpr{X: some, Op: lang.OR, Y: cond}
+		}
+	}
+	return x.expr(p, &lang.UnaryExpr{Op: lang.NOT, X: &lang.ParenExpr{X: some}})
+}
+
+// input returns the process inputting s and continuing with k.
+func (x *exporter) input(p *process, s *lang.InputCmd, k *cont) string {
+	c, args := x.port(p, s.Source, s.Target, true)
+	event := c.name
+	for _, a := range args {
+		v := p.lookup(a.(*lang.Ident).Name)
+		v.version++
+		event += "?" + v.name()
+	}
+	return event + " -> " + resume(k)
+}
+
+// port returns the channel of the communication of the value v with
+// the process n, and the fields of its events.
+func (x *exporter) port(p *process, n *lang.ProcName, v lang.Expr, input bool) (*channel, []lang.Expr) {
+	if len(n.Subscripts) > 0 {
+		errorf(n.Pos(), "arrays of processes are not supported")
+	}
+	if n.Name == p.label {
+		errorf(n.Pos(), "process %s communicates with itself", n.Name)
+	}
+	cons, args := "", []lang.Expr{v}
+	if s, ok := v.(*lang.StructuredExpr); ok {
+		if p.lookup(s.Constructor) != nil {
+			errorf(s.Pos(), "arrays are not supported")
+		}
+		cons, args = s.Constructor, s.Args
+	}
+	fields := make([]string, len(args))
+	for i, a := range args {
+		if !input {
+			fields[i] = x.typeOf(p, a)
+			continue
+		}
+		id, ok := a.(*lang.Ident)
+		if !ok {
+			errorf(a.Pos(), "structured values are not supported")
+		}
+		v := p.lookup(id.Name)
+		if v == nil {
+			errorf(id.Pos(), "undefined variable %s", id.Name)
+		}
+		fields[i] = v.typ
+	}
+
+	src, dst, name := "", "", n.Name
+	if p.peers[n.Name] {
+		src, dst = p.label, n.Name
+		if input {
+			src, dst = n.Name, p.label
+		}
+		name = src + "_" + dst
+	}
+	if cons != "" {
+		name += "_" + cons
+	}
+	key := "|" + n.Name + "|" + cons
+	if src != "" {
+		key = src + "|" + dst + "|" + cons
+	}
+	c, ok := x.chans[key]
+	if !ok {
+		c = &channel{name: x.unique(name), src: src, dst: dst, fields: fields}
+		if src == "" {
+			c.ext = n.Name
+		}
+		x.chans[key] = c
+		x.order = append(x.order, c)
+	}
+	if strings.Join(c.fields, ".") != strings.Join(fields, ".") {
+		errorf(v.Pos(), "channel %s carries %s and %s", c.name, x.types(c.fields), x.types(fields))
+	}
+	return c, args
+}
+
+// types returns the CSPm type of events of the given fields.
+func (x *exporter) types(fields []string) string {
+	types := make([]string, len(fields))
+	for i, f := range fields {
+		switch f {
+		case "integer":
+			types[i] = x.cfg.Integers
+		case "boolean":
+			types[i] = "Bool"
+		case "character":
+			types[i] = "Char"
+		}
+	}
+	return strings.Join(types, ".")
+}
+
+// typeOf returns the type of e.
+func (x *exporter) typeOf(p *process, e lang.Expr) string {
+	switch e := e.(type) {
+	case *lang.IntLit:
+		return "integer"
+	case *lang.CharLit:
+		return "character"
+	case *lang.Ident:
+		if v := p.lookup(e.Name); v != nil {
+			return v.typ
+		}
+		switch e.Name {
+		case "true", "false":
+			return "boolean"
+		case "space", "asterisk", "upward arrow":
+			return "character"
+		}
+		errorf(e.Pos(), "undefined variable %s", e.Name)
+	case *lang.ParenExpr:
+		return x.typeOf(p, e.X)
+	case *lang.UnaryExpr:
+		if e.Op == lang.NOT {
+			return "boolean"
+		}
+		return "integer"
+	case *lang.BinaryExpr:
+		if _, ok := operators[e.Op]; ok && precedence[e.Op] >= precedence[lang.ADD] {
+			return "integer"
+		}
+		return "boolean"
+	case *lang.StringLit:
+		errorf(e.Pos(), "arrays are not supported")
+	}
+	errorf(e.Pos(), "structured values are not supported")
+	return ""
+}
+
+// operators are the CSPm operators of the notation.
+var operators = map[lang.Token]string{
+	lang.OR: "or", lang.AND: "and",
+	lang.EQ: "==", lang.NEQ: "!=", lang.LT: "<", lang.LEQ: "<=", lang.GT: ">", lang.GEQ: ">=",
+	lang.ADD: "+", lang.SUB: "-",
+	lang.MUL: "*", lang.DIV: "/", lang.MOD: "%",
+}
+
+// precedence are the precedences of the binary operators of CSPm.
+var precedence = map[lang.Token]int{
+	lang.OR: 1, lang.AND: 2,
+	lang.EQ: 4, lang.NEQ: 4, lang.LT: 4, lang.LEQ: 4, lang.GT: 4, lang.GEQ: 4,
+	lang.ADD: 5, lang.SUB: 5,
+	lang.MUL: 6, lang.DIV: 6, lang.MOD: 6,
+}
+
+// expr returns the CSPm expression of e.
+func (x *exporter) expr(p *process, e lang.Expr) string {
+	switch e := e.(type) {
+	case *lang.IntLit:
+		return strconv.Itoa(e.Value)
+	case *lang.CharLit:
+		return strconv.QuoteRune(e.Value)
+	case *lang.Ident:
+		if v := p.lookup(e.Name); v != nil {
+			return v.value()
+		}
+		switch e.Name {
+		case "space":
+			return "' '"
+		case "asterisk":
+			return "'*'"
+		case "upward arrow":
+			return "'↑'"
+		}
+		return e.Name
+	case *lang.ParenExpr:
+		return "(" + x.expr(p, e.X) + ")"
+	case *lang.UnaryExpr:
+		if e.Op == lang.NOT {
+			return "not " + x.operand(p, e.X, precedence[lang.EQ])
+		}
+		return "-" + x.operand(p, e.X, precedence[lang.MUL]+1)
+	case *lang.BinaryExpr:
+		prec := precedence[e.Op]
+		return x.operand(p, e.X, prec) + " " + operators[e.Op] + " " + x.operand(p, e.Y, prec+1)
+	}
+	x.typeOf(p, e)
+	return ""
+}
+
+// operand returns the CSPm expression of e as an operand of an operator
+// of precedence prec.
+func (x *exporter) operand(p *process, e lang.Expr, prec int) string {
+	if b, ok := e.(*lang.BinaryExpr); ok && precedence[b.Op] < prec {
+		return "(" + x.expr(p, e) + ")"
+	}
+	return x.expr(p, e)
+}
+
+// field returns the CSPm expression of e as a field of an event.
+func (x *exporter) field(p *process, e lang.Expr) string {
+	switch e.(type) {
+	case *lang.BinaryExpr, *lang.UnaryExpr:
+		return "(" + x.expr(p, e) + ")"
+	}
+	return x.expr(p, e)
+}
+
+// paren returns the choice among brs as an operand.
+func (x *exporter) paren(brs []string) string {
+	if len(brs) == 0 {
+		return "STOP"
+	}
+	s := "(" + strings.Join(brs, " [] ") + ")"
+	x.parens[s] = true
+	return s
+}
+
+// unparen returns the process s, which is not an operand.
+func (x *exporter) unparen(s string) string {
+	if x.parens[s] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+// source returns the script.
+func (x *exporter) source() []byte {
+	buf := bytes.Buffer{}
+	for _, c := range x.order {
+		fmt.Fprintf(&buf, "channel %s", c.name)
+		if len(c.fields) > 0 {
+			fmt.Fprintf(&buf, " : %s", x.types(c.fields))
+		}
+		fmt.Fprintf(&buf, "\n")
+	}
+	if len(x.order) > 0 {
+		fmt.Fprintf(&buf, "\n")
+	}
+	for _, eq := range x.eqs {
+		fmt.Fprintf(&buf, "%s", eq.name)
+		if len(eq.params) > 0 {
+			fmt.Fprintf(&buf, "(%s)", strings.Join(eq.params, ", "))
+		}
+		fmt.Fprintf(&buf, " = %s\n", eq.body)
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,144 @@
+package cspm_test
+
+import (
+	"testing"
+
+	"github.com/changkun/gobase/csp/lang"
+	"github.com/changkun/gobase/csp/lang/cspm"
+)
+
+func TestExport(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{
+			src: "X::*[c:character; west?c → east!c]",
+			want: `channel west : Char
+channel east : Char
+
+X = west?c -> east!c -> X
+`,
+		},
+		{
+			src: `X::*[c:character; west?c →
+    [c ≠ asterisk → east!c
+    □ c = asterisk → west?c;
+        [c ≠ asterisk → east!asterisk; east!c □ c = asterisk → east!upward arrow]
+    ]
+]`,
+			want: `channel west : Char
+channel east : Char
+
+X = west?c -> (c != '*' & east!c -> X [] c == '*' & west?c' -> (c' != '*' & east!'*' -> east!c' -> X [] c' == '*' & east!'↑' -> X))
+`,
+		},
+		{
+			src: "X::n:integer; n := 0; *[n < 3 → out!n; n := n + 1]; done!n * 2",
+			want: `channel out : {0..9}
+channel done : {0..9}
+
+X = let n = 0 within X_1(n)
+X_1(n) = n < 3 & out!n -> (let n' = n + 1 within X_1(n')) [] not (n < 3) & done!(n * 2) -> SKIP
+`,
+		},
+		{
+			src: "S::n, m:integer; n := 0; *[n < 10; X?has(m) → n := n + m □ X?V() → n := n - 1]",
+			want: `channel X_has : {0..9}
+channel X_V
+
+S = let n = 0 within S_1(n, 0)
+S_1(n, m) = n < 10 & X_has?m' -> (let n' = n + m' within S_1(n', m')) [] X_V -> (let n' = n - 1 within S_1(n', m))
+`,
+		},
+		{
+			src: `COPY = (*[c:character; west?c → east!c])
+[west::*[c:character; in?c → X!c] || X::*[c:character; west?c → east!c; east!P()] || east::*[c:character; X?c → out!c □ X?P() → skip]]`,
+			want: `channel in : Char
+channel west_X : Char
+channel X_east : Char
+channel X_east_P
+channel out : Char
+
+west = in?c -> west_X!c -> west
+X = west_X?c -> X_east!c -> X_east_P -> X
+east = X_east?c -> out!c -> east [] X_east_P -> east
+SYSTEM = (west [| {| west_X |} |] X) [| {| X_east, X_east_P |} |] east
+`,
+		},
+		{
+			src: "COPY = (*[c:character; west?c → east!c])\nRUN = (x:integer; x := 1; [x > 0 → COPY □ x ≤ 0 → skip]; out!x)",
+			want: `channel west : Char
+channel east : Char
+channel out : {0..9}
+
+COPY = west?c -> east!c -> COPY
+RUN_ = let x = 1 within (x > 0 & RUN__2(x) [] x <= 0 & RUN__1(x))
+RUN__1(x) = out!x -> SKIP
+RUN__2(x) = west?c -> east!c -> RUN__2(x)
+`,
+		},
+		{
+			src: "LOG = (*[c:character; west?c → log!c; east!c]) \\ (log)",
+			want: `channel west : Char
+channel log : Char
+channel east : Char
+
+LOG = LOG_2 \ {| log |}
+LOG_2 = west?c -> log!c -> east!c -> LOG_2
+`,
+		},
+		{
+			// X outputs to the external name Y, not to its process Y.
+			src: "[X::Y!1 ||| Y::out!2]",
+			want: `channel Y : {0..9}
+channel out : {0..9}
+
+X = Y!1 -> SKIP
+Y_2 = out!2 -> SKIP
+SYSTEM = X ||| Y_2
+`,
+		},
+	}
+	for _, tt := range tests {
+		prog, err := lang.Parse(tt.src)
+		if err != nil {
+			t.Fatalf("%v: %v", t.Name(), err)
+		}
+		got, err := cspm.Export(prog, cspm.Config{})
+		if err != nil {
+			t.Fatalf("%v: unexpected error: %v", t.Name(), err)
+		}
+		if string(got) != tt.want {
+			t.Fatalf("%v: expected:\n%v\ngot:\n%v", t.Name(), tt.want, string(got))
+		}
+	}
+}
+
+func TestExportErrors(t *testing.T) {
+	tests := []struct {
+		src string
+		err string
+	}{
+		{src: "X::a:(1..3)integer; a(1) := 0", err: "1:6: arrays are not supported"},
+		{src: "X::*[(i:1..3) Y(i)?c() → skip]", err: "1:7: bound variables are not supported"},
+		{src: "X::[Y::skip || Z::skip]", err: "1:4: nested parallel commands are not supported"},
+		{src: "P = (out!1; P)\nX::P", err: "1:13: recursive process P is not supported"},
+		{src: "P = (out!1)\nX::P[[out <- log]]", err: "2:7: renaming is not supported"},
+		{src: "X::out!n", err: "1:8: undefined variable n"},
+		{src: "X::c:character; c := 1", err: "1:22: cannot assign integer to c of type character"},
+		{src: "X::out!1; out!'a'", err: "1:15: channel out carries {0..9} and Char"},
+		{src: "[X::Y!1 || X::skip]", err: "1:12: duplicate process label X"},
+		{src: "X::X!1", err: "1:4: process X communicates with itself"},
+	}
+	for _, tt := range tests {
+		prog, err := lang.Parse(tt.src)
+		if err != nil {
+			t.Fatalf("%v: %v", t.Name(), err)
+		}
+		_, err = cspm.Export(prog, cspm.Config{})
+		if err == nil || err.Error() != tt.err {
+			t.Fatalf("%v: %q: expected: %v, got: %v", t.Name(), tt.src, tt.err, err)
+		}
+	}
+}
@@ -0,0 +1,683 @@
+package cspm
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/changkun/gobase/csp/lang"
+)
+
+// token is a token of CSPm: an identifier, a number, or a symbol.
+type token struct {
+	pos  lang.Pos
+	kind byte // 'i' identifier, 'n' number, 's' symbol, 0 end of file
+	lit  string
+}
+
+func (t token) String() string {
+	if t.kind == 0 {
+		return "EOF"
+	}
+	return t.lit
+}
+
+// symbols are the symbols of CSPm, longer ones first.
+var symbols = []string{
+	"|||", "|~|", "[|", "|]", "{|", "|}", "->", "[]", "||", "..", "==", "!=", "<=", ">=", "[>", "/\\", "[[", "]]", "<-",
+	"[", "]", "(", ")", "{", "}", ",", ";", "&", "|", "?", "!", ".", ":", "=", "<", ">", "+", "-", "*", "/", "%", "\\", "@",
+}
+
+// tokenize returns the tokens of src, terminated by one of kind 0.
+func tokenize(src string) ([]token, error) {
+	toks := []token{}
+	line, col := 1, 1
+	advance := func(n int) {
+		for _, r := range src[:n] {
+			if r == '\n' {
+				line, col = line+1, 1
+			} else {
+				col++
+			}
+		}
+		src = src[n:]
+	}
+	for {
+		// white space and comments
+		for len(src) > 0 {
+			r, size := utf8.DecodeRuneInString(src)
+			switch {
+			case unicode.IsSpace(r):
+				advance(size)
+				continue
+			case strings.HasPrefix(src, "--"):
+				end := strings.IndexByte(src, '\n')
+				if end < 0 {
+					end = len(src)
+				}
+				advance(end)
+				continue
+			case strings.HasPrefix(src, "{-"):
+				end := strings.Index(src, "-}")
+				if end < 0 {
+					return nil, &lang.Error{Pos: lang.Pos{Line: line, Column: col}, Msg: "unterminated comment"}
+				}
+				advance(end + 2)
+				continue
+			}
+			break
+		}
+		pos := lang.Pos{Line: line, Column: col}
+		if len(src) == 0 {
+			return append(toks, token{pos: pos}), nil
+		}
+
+		r, _ := utf8.DecodeRuneInString(src)
+		n, kind := 0, byte('s')
+		switch {
+		case unicode.IsLetter(r) || r == '_':
+			kind = 'i'
+			for n < len(src) {
+				r, size := utf8.DecodeRuneInString(src[n:])
+				if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' && r != '\'' {
+					break
+				}
+				n += size
+			}
+		case unicode.IsDigit(r):
+			kind = 'n'
+			for n < len(src) && '0' <= src[n] && src[n] <= '9' {
+				n++
+			}
+		default:
+			for _, s := range symbols {
+				if strings.HasPrefix(src, s) {
+					n = len(s)
+					break
+				}
+			}
+			if n == 0 {
+				return nil, &lang.Error{Pos: pos, Msg: fmt.Sprintf("unexpected %q", r)}
+			}
+		}
+		toks = append(toks, token{pos: pos, kind: kind, lit: src[:n]})
+		advance(n)
+	}
+}
+
+// The syntax tree of the processes of CSPm.
+type (
+	proc interface{ position() lang.Pos }
+
+	// stop is STOP, or SKIP if skip is set.
+	stop struct {
+		pos  lang.Pos
+		skip bool
+	}
+	// ref refers to a defined process.
+	ref struct {
+		pos  lang.Pos
+		name string
+	}
+	// prefix is an event followed by a process: c -> P, c!e -> P,
+	// c.e -> P or c?x -> P.
+	prefix struct {
+		pos   lang.Pos
+		ch    string
+		dir   string // "", "!", "." or "?"
+		value lang.Expr
+		bind  string
+		then  proc
+	}
+	// guard is b & P.
+	guard struct {
+		pos  lang.Pos
+		cond lang.Expr
+		then proc
+	}
+	// cond is if b then P else Q.
+	cond struct {
+		pos       lang.Pos
+		cond      lang.Expr
+		then, els proc
+	}
+	// choice is P [] Q, or P |~| Q if internal is set.
+	choice struct {
+		pos      lang.Pos
+		internal bool
+		l, r     proc
+	}
+	// seq is P ; Q.
+	seq struct {
+		pos  lang.Pos
+		l, r proc
+	}
+	// par is P [| A |] Q, P [A || B] Q or P ||| Q, which synchronise
+	// on the channels of sync. The alphabets A and B of P [A || B] Q
+	// are those of alpha, whose intersection is sync.
+	par struct {
+		pos   lang.Pos
+		sync  []string
+		alpha *[2][]string
+		l, r  proc
+	}
+)
+
+func (p *stop) position() lang.Pos   { return p.pos }
+func (p *ref) position() lang.Pos    { return p.pos }
+func (p *prefix) position() lang.Pos { return p.pos }
+func (p *guard) position() lang.Pos  { return p.pos }
+func (p *cond) position() lang.Pos   { return p.pos }
+func (p *choice) position() lang.Pos { return p.pos }
+func (p *seq) position() lang.Pos    { return p.pos }
+func (p *par) position() lang.Pos    { return p.pos }
+
+// definition defines a process.
+type definition struct {
+	pos  lang.Pos
+	name string
+	body proc
+}
+
+// script is a parsed CSPm script.
+type script struct {
+	chans  map[string]string       // the types of the channels, "" without data
+	values map[string][]lang.Value // the values of the channels of sets
+	hidden map[proc][]string       // the channels hidden from the processes
+	defs   map[string]*definition
+	order  []*definition
+}
+
+// parser parses a CSPm script.
+type parser struct {
+	toks []token
+	p    int
+	s    *script
+}
+
+// bailout aborts parsing with an error.
+type bailout struct{ err *lang.Error }
+
+func (p *parser) tok() token { return p.toks[p.p] }
+
+func (p *parser) next() token {
+	t := p.toks[p.p]
+	if p.p < len(p.toks)-1 {
+		p.p++
+	}
+	return t
+}
+
+// is reports whether the current token is the symbol or keyword lit.
+func (p *parser) is(lit string) bool {
+	t := p.tok()
+	return t.kind != 0 && t.kind != 'n' && t.lit == lit
+}
+
+func (p *parser) expect(lit string) token {
+	if !p.is(lit) {
+		p.errorf(p.tok().pos, "expected %s, found %v", lit, p.tok())
+	}
+	return p.next()
+}
+
+func (p *parser) ident() token {
+	if p.tok().kind != 'i' || keywords[p.tok().lit] {
+		p.errorf(p.tok().pos, "expected identifier, found %v", p.tok())
+	}
+	return p.next()
+}
+
+func (p *parser) errorf(pos lang.Pos, format string, args ...interface{}) {
+	panic(bailout{&lang.Error{Pos: pos, Msg: fmt.Sprintf(format, args...)}})
+}
+
+// keywords are the reserved words of CSPm.
+var keywords = map[string]bool{
+	"channel": true, "datatype": true, "nametype": true, "subtype": true, "assert": true,
+	"include": true, "transparent": true, "external": true, "let": true, "within": true,
+	"if": true, "then": true, "else": true, "and": true, "or": true, "not": true,
+	"true": true, "false": true, "STOP": true, "SKIP": true,
+}
+
+// parse parses the declarations of a script.
+func parse(src string) (s *script, err error) {
+	toks, err := tokenize(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{toks: toks, s: &script{chans: map[string]string{}, values: map[string][]lang.Value{},
+		hidden: map[proc][]string{}, defs: map[string]*definition{}}}
+	defer func() {
+		if r := recover(); r != nil {
+			b, ok := r.(bailout)
+			if !ok {
+				panic(r)
+			}
+			s, err = nil, b.err
+		}
+	}()
+	for p.tok().kind != 0 {
+		p.declaration()
+	}
+	return p.s, nil
+}
+
+func (p *parser) declaration() {
+	t := p.tok()
+	switch {
+	case p.is("channel"):
+		p.next()
+		names := []token{p.ident()}
+		for p.is(",") {
+			p.next()
+			names = append(names, p.ident())
+		}
+		typ, values := "", []lang.Value(nil)
+		if p.is(":") {
+			p.next()
+			typ, values = p.channelType()
+		}
+		for _, n := range names {
+			if _, ok := p.s.chans[n.lit]; ok {
+				p.errorf(n.pos, "channel %s redeclared", n.lit)
+			}
+			p.s.chans[n.lit] = typ
+			if values != nil {
+				p.s.values[n.lit] = values
+			}
+		}
+	case p.is("assert"):
+		// assertions are checked by other tools, they end with their
+		// line.
+		line := t.pos.Line
+		for p.tok().kind != 0 && p.tok().pos.Line == line {
+			p.next()
+		}
+	case t.kind == 'i' && keywords[t.lit]:
+		p.errorf(t.pos, "%s declarations are not supported", t.lit)
+	default:
+		name := p.ident()
+		if p.is("(") {
+			p.errorf(p.tok().pos, "process parameters are not supported")
+		}
+		p.expect("=")
+		if _, ok := p.s.defs[name.lit]; ok {
+			p.errorf(name.pos, "process %s redefined", name.lit)
+		}
+		d := &definition{pos: name.pos, name: name.lit, body: p.process()}
+		p.s.defs[d.name] = d
+		p.s.order = append(p.s.order, d)
+	}
+}
+
+// channelType parses the type of a channel, which is a set of integers
+// such as {0..9}, or Int, Bool or Char, and returns the values of a set
+// or of Bool.
+func (p *parser) channelType() (string, []lang.Value) {
+	t := p.tok()
+	switch {
+	case p.is("Int"):
+		p.next()
+		return "integer", nil
+	case p.is("Bool"):
+		p.next()
+		return "boolean", []lang.Value{false, true}
+	case p.is("Char"):
+		p.next()
+		return "character", nil
+	case p.is("{"):
+		p.next()
+		typ, values := "", []lang.Value{}
+		for !p.is("}") {
+			x := p.expr()
+			xt := "integer"
+			if id, ok := x.(*lang.Ident); ok && (id.Name == "true" || id.Name == "false") {
+				xt = "boolean"
+			}
+			if typ != "" && typ != xt {
+				p.errorf(x.Pos(), "set of mixed types")
+			}
+			typ = xt
+			v := p.constant(x)
+			if p.is("..") {
+				p.next()
+				y := p.expr()
+				lo, ok1 := v.(int)
+				hi, ok2 := p.constant(y).(int)
+				if !ok1 || !ok2 {
+					p.errorf(x.Pos(), "range of non-integers")
+				}
+				for i := lo; i <= hi; i++ {
+					values = append(values, i)
+				}
+			} else {
+				values = append(values, v)
+			}
+			if !p.is(",") {
+				break
+			}
+			p.next()
+		}
+		p.expect("}")
+		if typ == "" {
+			p.errorf(t.pos, "empty channel type")
+		}
+		return typ, values
+	}
+	p.errorf(t.pos, "unsupported channel type %v", t)
+	return "", nil
+}
+
+// constant returns the value of the constant expression x.
+func (p *parser) constant(x lang.Expr) lang.Value {
+	v, err := lang.Eval(x)
+	if err != nil {
+		p.errorf(x.Pos(), "%v", err)
+	}
+	return v
+}
+
+// process parses a process, the operators by increasing precedence:
+//
+//   P \ A                       hiding
+//   P [| A |] Q, P [A || B] Q, P ||| Q
+//   P |~| Q
+//   P [] Q
+//   P ; Q
+//   c -> P, b & P, if b then P else Q
+func (p *parser) process() proc {
+	x := p.parallel()
+	for p.is("\\") {
+		p.next()
+		// hiding is implicit for programs: channels connecting
+		// processes are internal.
+		p.s.hidden[x] = append(p.s.hidden[x], p.channelSet()...)
+	}
+	return x
+}
+
+func (p *parser) parallel() proc {
+	x := p.internal()
+	for {
+		pos := p.tok().pos
+		switch {
+		case p.is("|||"):
+			p.next()
+			x = &par{pos: pos, l: x, r: p.internal()}
+		case p.is("[|"):
+			p.next()
+			sync := p.channelSet()
+			p.expect("|]")
+			x = &par{pos: pos, sync: sync, l: x, r: p.internal()}
+		case p.is("[") && p.alphabetised():
+			p.next()
+			a := p.channelSet()
+			p.expect("||")
+			b := p.channelSet()
+			p.expect("]")
+			x = &par{pos: pos, sync: intersect(a, b), alpha: &[2][]string{a, b}, l: x, r: p.internal()}
+		default:
+			return x
+		}
+	}
+}
+
+// alphabetised reports whether the current bracket opens the alphabets
+// of an alphabetised parallel.
+func (p *parser) alphabetised() bool {
+	depth := 0
+	for i := p.p; i < len(p.toks) && p.toks[i].kind != 0; i++ {
+		switch p.toks[i].lit {
+		case "[", "{", "{|", "(":
+			depth++
+		case "]", "}", "|}", ")":
+			depth--
+			if depth == 0 {
+				return false
+			}
+		case "||":
+			if depth == 1 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func intersect(a, b []string) []string {
+	in := map[string]bool{}
+	for _, c := range b {
+		in[c] = true
+	}
+	out := []string{}
+	for _, c := range a {
+		if in[c] {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// channelSet parses a set of channels, {| c, d |} or {c, d}.
+func (p *parser) channelSet() []string {
+	closing := "}"
+	if p.is("{|") {
+		closing = "|}"
+		p.next()
+	} else {
+		p.expect("{")
+	}
+	set := []string{}
+	for !p.is(closing) {
+		c := p.ident()
+		if _, ok := p.s.chans[c.lit]; !ok {
+			p.errorf(c.pos, "undeclared channel %s", c.lit)
+		}
+		if p.is(".") {
+			p.errorf(p.tok().pos, "sets of events are not supported, use {| %s |}", c.lit)
+		}
+		set = append(set, c.lit)
+		if !p.is(",") {
+			break
+		}
+		p.next()
+	}
+	p.expect(closing)
+	return set
+}
+
+func (p *parser) internal() proc {
+	x := p.external()
+	for p.is("|~|") {
+		pos := p.next().pos
+		x = &choice{pos: pos, internal: true, l: x, r: p.external()}
+	}
+	return x
+}
+
+func (p *parser) external() proc {
+	x := p.sequential()
+	for p.is("[]") {
+		pos := p.next().pos
+		x = &choice{pos: pos, l: x, r: p.sequential()}
+	}
+	return x
+}
+
+func (p *parser) sequential() proc {
+	x := p.prefix()
+	for p.is(";") {
+		pos := p.next().pos
+		x = &seq{pos: pos, l: x, r: p.prefix()}
+	}
+	return x
+}
+
+func (p *parser) prefix() proc {
+	t := p.tok()
+	if p.is("if") {
+		p.next()
+		c := p.expr()
+		p.expect("then")
+		then := p.process()
+		p.expect("else")
+		return &cond{pos: t.pos, cond: c, then: then, els: p.process()}
+	}
+	if _, ok := p.s.chans[t.lit]; ok && t.kind == 'i' {
+		return p.event()
+	}
+	switch {
+	case p.is("STOP"), p.is("SKIP"):
+		p.next()
+		return &stop{pos: t.pos, skip: t.lit == "SKIP"}
+	case p.is("["), p.is("[|"), p.is("|||"), p.is("[]"), p.is("|~|"), p.is("->"):
+		p.errorf(t.pos, "expected process, found %v", t)
+	case t.kind == 'i' && !keywords[t.lit] && !p.guarded():
+		p.next()
+		if p.is("[[") {
+			p.errorf(p.tok().pos, "renaming is not supported")
+		}
+		return &ref{pos: t.pos, name: t.lit}
+	case p.is("(") && !p.guarded():
+		p.next()
+		x := p.process()
+		p.expect(")")
+		return x
+	}
+	c := p.expr()
+	p.expect("&")
+	return &guard{pos: t.pos, cond: c, then: p.prefix()}
+}
+
+// guarded reports whether a boolean guard b & P starts at the current
+// token.
+func (p *parser) guarded() bool {
+	depth := 0
+	for i := p.p; i < len(p.toks) && p.toks[i].kind != 0; i++ {
+		switch p.toks[i].lit {
+		case "(":
+			depth++
+		case ")":
+			depth--
+			if depth < 0 {
+				return false
+			}
+		case "&":
+			return depth == 0
+		case "->", "[]", "|~|", "|||", "[|", ";", "=", "[", "\\":
+			if depth == 0 {
+				return false
+			}
+		}
+	}
+	return false
+}
+
+// event parses an event prefixing a process.
+func (p *parser) event() proc {
+	c := p.next()
+	x := &prefix{pos: c.pos, ch: c.lit}
+	if p.is("!") || p.is(".") || p.is("?") {
+		x.dir = p.next().lit
+		if x.dir == "?" {
+			x.bind = p.ident().lit
+			if p.is(":") {
+				// the set an input is restricted to.
+				p.next()
+				p.expr()
+			}
+		} else {
+			x.value = p.expr()
+		}
+		if p.is("!") || p.is(".") || p.is("?") {
+			p.errorf(p.tok().pos, "events of several fields are not supported")
+		}
+	}
+	if x.dir == "" && p.s.chans[c.lit] != "" {
+		p.errorf(c.pos, "channel %s carries data", c.lit)
+	}
+	if x.dir != "" && p.s.chans[c.lit] == "" {
+		p.errorf(c.pos, "channel %s carries no data", c.lit)
+	}
+	p.expect("->")
+	x.then = p.prefix()
+	return x
+}
+
+// binary operators of CSPm by precedence, from lowest to highest.
+var binary = map[string]struct {
+	prec int
+	op   lang.Token
+}{
+	"or":  {1, lang.OR},
+	"and": {2, lang.AND},
+	"==":  {3, lang.EQ}, "!=": {3, lang.NEQ}, "<": {3, lang.LT}, "<=": {3, lang.LEQ}, ">": {3, lang.GT}, ">=": {3, lang.GEQ},
+	"+": {4, lang.ADD}, "-": {4, lang.SUB},
+	"*": {5, lang.MUL}, "/": {5, lang.DIV}, "%": {5, lang.MOD},
+}
+
+func (p *parser) expr() lang.Expr {
+	return p.binaryExpr(1)
+}
+
+func (p *parser) binaryExpr(prec int) lang.Expr {
+	x := p.unary()
+	for {
+		t := p.tok()
+		b, ok := binary[t.lit]
+		if !ok || t.kind == 'n' || b.prec < prec {
+			return x
+		}
+		p.next()
+		x = &lang.BinaryExpr{X: x, OpPos: t.pos, Op: b.op, Y: p.binaryExpr(b.prec + 1)}
+	}
+}
+
+func (p *parser) unary() lang.Expr {
+	t := p.tok()
+	switch {
+	case p.is("not"):
+		p.next()
+		return &lang.UnaryExpr{OpPos: t.pos, Op: lang.NOT, X: p.binaryExpr(binary["=="].prec)}
+	case p.is("-"):
+		p.next()
+		return &lang.UnaryExpr{OpPos: t.pos, Op: lang.SUB, X: p.unary()}
+	}
+	return p.operand()
+}
+
+func (p *parser) operand() lang.Expr {
+	t := p.next()
+	switch {
+	case t.kind == 'n':
+		v, err := strconv.Atoi(t.lit)
+		if err != nil {
+			p.errorf(t.pos, "invalid integer %s", t.lit)
+		}
+		return &lang.IntLit{ValuePos: t.pos, Value: v}
+	case t.kind == 'i' && (t.lit == "true" || t.lit == "false"):
+		return &lang.Ident{NamePos: t.pos, Name: t.lit}
+	case t.kind == 'i' && !keywords[t.lit]:
+		return &lang.Ident{NamePos: t.pos, Name: name(t.lit)}
+	case t.kind == 's' && t.lit == "(":
+		x := p.expr()
+		p.expect(")")
+		return &lang.ParenExpr{Lparen: t.pos, X: x}
+	}
+	p.errorf(t.pos, "expected expression, found %v", t)
+	return nil
+}
+
+// name returns the identifier of the notation naming the CSPm
+// identifier s.
+func name(s string) string {
+	s = strings.ReplaceAll(s, "'", "_")
+	switch s {
+	case "skip", "mod", "comment":
+		return s + "_"
+	}
+	return s
+}
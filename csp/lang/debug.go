@@ -0,0 +1,121 @@
+package lang
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Stop is a command a process is about to execute when it calls the
+// Debug function of an Interpreter.
+type Stop struct {
+	// Process is the label of the process.
+	Process string
+	// Cmd is the command, an *InputCmd, *OutputCmd, *AlternativeCmd or
+	// *RepetitiveCmd.
+	Cmd Stmt
+	// Guards are the guards of an alternative or repetitive command
+	// which are not false, in the notation of the paper, preceded by
+	// the values of their bound variables, such as i = 2: X(i)?V().
+	Guards []string
+	// Procs are the states of the processes of the program in the
+	// order they started, the first is the program itself.
+	Procs []ProcState
+}
+
+// ProcState is the state of a process as of its last stop.
+type ProcState struct {
+	Label string
+	// Cmd is the command the process stopped at last, nil if it has
+	// not stopped yet. Unless the process stopped at it now, the
+	// process executes or waits for the command, or has moved on.
+	Cmd Stmt
+	// Vars are the values of the variables in scope of the process.
+	Vars map[string]Value
+	// Done reports whether the process terminated.
+	Done bool
+}
+
+// String formats s on a line, such as X at west?c: c = 'a'.
+func (s ProcState) String() string {
+	label := s.Label
+	if label == "" {
+		label = "program"
+	}
+	switch {
+	case s.Done:
+		return label + " terminated"
+	case s.Cmd == nil:
+		return label + " running"
+	}
+	names := make([]string, 0, len(s.Vars))
+	for name := range s.Vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	vars := make([]string, len(names))
+	for i, name := range names {
+		vars[i] = name + " = " + Format(s.Vars[name])
+	}
+	line := label + " at " + compact(s.Cmd)
+	if len(vars) > 0 {
+		line += ": " + strings.Join(vars, ", ")
+	}
+	return line
+}
+
+// start records the start of p, if the program is debugged.
+func (p *process) start() {
+	if p.Debug == nil {
+		return
+	}
+	p.dmu.Lock()
+	defer p.dmu.Unlock()
+	p.state = &ProcState{Label: p.label}
+	p.states = append(p.states, p.state)
+}
+
+// done records the termination of p.
+func (p *process) done() {
+	if p.Debug == nil {
+		return
+	}
+	p.dmu.Lock()
+	defer p.dmu.Unlock()
+	p.state.Done = true
+}
+
+// stop calls Debug before p executes cmd, with the descriptions of the
+// guards of cmd, if any, and returns its result.
+func (p *process) stop(cmd Stmt, guards []string) int {
+	vars := map[string]Value{}
+	for s := p.vars; s != nil; s = s.outer {
+		for name, x := range s.vars {
+			if _, ok := vars[name]; !ok {
+				vars[name] = copyValue(x.val)
+			}
+		}
+	}
+	p.dmu.Lock()
+	defer p.dmu.Unlock()
+	p.state.Cmd, p.state.Vars = cmd, vars
+	procs := make([]ProcState, len(p.states))
+	for i, s := range p.states {
+		procs[i] = *s
+	}
+	return p.Debug(Stop{Process: p.label, Cmd: cmd, Guards: guards, Procs: procs})
+}
+
+// describe returns the description of the guard of gc in the scope s
+// for Stop.Guards.
+func describe(s *scope, gc *GuardedCmd) string {
+	g := compact(gc.Guard)
+	if len(gc.Ranges) == 0 {
+		return g
+	}
+	bound := make([]string, len(gc.Ranges))
+	for i, r := range gc.Ranges {
+		bound[i] = fmt.Sprintf("%s = %s", r.Var, Format(s.vars[r.Var].val))
+	}
+	return strings.Join(bound, ", ") + ": " + g
+}
@@ -0,0 +1,219 @@
+package lang
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Dot writes the topology of prog in the DOT language of Graphviz. The
+// processes of its parallel commands are nodes, labelled by their label
+// and the definition they run, and the links over which a process
+// outputs to another are directed edges. For [west::DISASSEMBLE||
+// X::SQUASH||east::ASSEMBLE], where the definitions are in scope:
+//
+//   digraph {
+//       p1 [label="west::DISASSEMBLE"];
+//       p2 [label="X::SQUASH"];
+//       p3 [label="east::ASSEMBLE"];
+//       p4 [label="cardfile", shape=none];
+//       p5 [label="lineprinter", shape=none];
+//       p4 -> p1;
+//       p1 -> p2;
+//       p2 -> p3;
+//       p3 -> p5;
+//   }
+//
+// An array of processes such as fac(i:1..limit) is a single node, the
+// links between its processes a loop. The processes of a parallel
+// command within a process are a cluster labelled by that process.
+// External names are nodes without a shape. The communications of the
+// program outside any parallel command are those of a node program.
+func Dot(w io.Writer, prog *Program) error {
+	g := &grapher{w: bufio.NewWriter(w), defs: map[string]*Definition{}, expanding: map[string]bool{},
+		externals: map[string]string{}, edges: map[[2]string]bool{}}
+	for _, d := range prog.Defs {
+		if _, ok := g.defs[d.Name]; !ok {
+			g.defs[d.Name] = d
+		}
+	}
+	fmt.Fprintln(g.w, "digraph {")
+	g.list(prog.Body, &node{name: "program"}, 1)
+	for _, line := range g.late {
+		g.printf(1, "%s", line)
+	}
+	for _, e := range g.order {
+		g.printf(1, "%s -> %s;", e[0], e[1])
+	}
+	fmt.Fprintln(g.w, "}")
+	return g.w.Flush()
+}
+
+// grapher is the state of Dot.
+type grapher struct {
+	w         *bufio.Writer
+	defs      map[string]*Definition
+	expanding map[string]bool // definitions being walked in place
+	ids       int
+	externals map[string]string // the node ids of external names
+	late      []string          // nodes written after the processes
+	edges     map[[2]string]bool
+	order     [][2]string // of the edges
+}
+
+// node is the node of a process, and the processes it may name: those
+// of its own parallel command and of the enclosing ones.
+type node struct {
+	outer  *node
+	id     string // empty until the node is written
+	name   string
+	labels map[string][]*node // the processes of the labels by name
+	label  *ProcLabel
+	refs   []*ProcRef // the references with renamings walked in place
+}
+
+func (g *grapher) printf(depth int, format string, args ...interface{}) {
+	fmt.Fprintf(g.w, strings.Repeat("    ", depth)+format+"\n", args...)
+}
+
+// node writes a node labelled label with the given attributes, or
+// keeps it for the end of the graph if depth is 0, and returns its id.
+func (g *grapher) node(label, attrs string, depth int) string {
+	g.ids++
+	id := fmt.Sprintf("p%d", g.ids)
+	line := fmt.Sprintf("%s [label=%s%s];", id, strconv.Quote(label), attrs)
+	if depth == 0 {
+		g.late = append(g.late, line)
+	} else {
+		g.printf(depth, "%s", line)
+	}
+	return id
+}
+
+func (g *grapher) list(l *CmdList, n *node, depth int) {
+	for _, st := range l.Stmts {
+		g.stmt(st, n, depth)
+	}
+}
+
+func (g *grapher) stmt(st Stmt, n *node, depth int) {
+	switch st := st.(type) {
+	case *InputCmd:
+		g.comm(st.Source, n, false)
+	case *OutputCmd:
+		g.comm(st.Dest, n, true)
+	case *ParallelCmd:
+		if n.outer == nil && n.labels == nil {
+			// a parallel command of the program.
+			g.parallel(st, n, depth)
+			return
+		}
+		g.ids++
+		g.printf(depth, "subgraph cluster_%d {", g.ids)
+		g.printf(depth+1, "label=%s;", strconv.Quote(n.name))
+		g.parallel(st, n, depth+1)
+		g.printf(depth, "}")
+	case *AlternativeCmd:
+		g.alternative(st, n, depth)
+	case *RepetitiveCmd:
+		g.alternative(st.Alt, n, depth)
+	case *ProcRef:
+		d, ok := g.defs[st.Name]
+		if !ok || g.expanding[d.Name] {
+			return
+		}
+		g.expanding[d.Name] = true
+		if len(st.Renames) > 0 {
+			n.refs = append(n.refs, st)
+		}
+		g.list(d.Body, n, depth)
+		if len(st.Renames) > 0 {
+			n.refs = n.refs[:len(n.refs)-1]
+		}
+		g.expanding[d.Name] = false
+	}
+}
+
+func (g *grapher) alternative(alt *AlternativeCmd, n *node, depth int) {
+	for _, gc := range alt.Cmds {
+		if gc.Guard.Input != nil {
+			g.stmt(gc.Guard.Input, n, depth)
+		}
+		g.list(gc.Body, n, depth)
+	}
+}
+
+// parallel writes the nodes of the processes of cmd, which is run by
+// the process n, and walks their bodies.
+func (g *grapher) parallel(cmd *ParallelCmd, n *node, depth int) {
+	// the nodes come first, as the processes name each other in any
+	// order.
+	labels := map[string][]*node{}
+	procs := make([]*node, len(cmd.Procs))
+	for i, proc := range cmd.Procs {
+		name := "(unlabelled)"
+		if proc.Label != nil {
+			name = Source(proc.Label)
+		}
+		label := name
+		if len(proc.Body.Stmts) == 1 {
+			if ref, ok := proc.Body.Stmts[0].(*ProcRef); ok {
+				label += "::" + Source(ref)
+			}
+		}
+		procs[i] = &node{outer: n, id: g.node(label, "", depth), name: name, labels: labels, label: proc.Label}
+		if proc.Label != nil && !cmd.Interleave {
+			labels[proc.Label.Name] = append(labels[proc.Label.Name], procs[i])
+		}
+	}
+	for i, proc := range cmd.Procs {
+		g.list(proc.Body, procs[i], depth)
+	}
+}
+
+// comm records the link of an input or output of the process n from or
+// to the process, or external name, pn names.
+func (g *grapher) comm(pn *ProcName, n *node, output bool) {
+	peer := ""
+	for l := n; l != nil && peer == ""; l = l.outer {
+		if name := renamed(l.refs, pn.Name); name != pn.Name {
+			pn = &ProcName{NamePos: pn.NamePos, Name: name, Subscripts: pn.Subscripts}
+		}
+		peer = resolve(l.labels[pn.Name], pn)
+	}
+	if peer == "" {
+		if peer = g.externals[pn.Name]; peer == "" {
+			peer = g.node(pn.Name, ", shape=none", 0)
+			g.externals[pn.Name] = peer
+		}
+	}
+	if n.id == "" {
+		// the program communicates outside of any parallel command.
+		n.id = g.node(n.name, "", 0)
+	}
+	e := [2]string{peer, n.id}
+	if output {
+		e = [2]string{n.id, peer}
+	}
+	if !g.edges[e] {
+		g.edges[e] = true
+		g.order = append(g.order, e)
+	}
+}
+
+// resolve returns the id of the process of procs pn names: the one
+// whose label has the same subscripts, or else the first one, which
+// pn names for some values of its subscripts.
+func resolve(procs []*node, pn *ProcName) string {
+	for _, p := range procs {
+		if names(p.label, pn) {
+			return p.id
+		}
+	}
+	if len(procs) == 0 {
+		return ""
+	}
+	return procs[0].id
+}
@@ -0,0 +1,97 @@
+package lang_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/changkun/gobase/csp/lang"
+)
+
+func TestDot(t *testing.T) {
+	progs := solutions(t, "S32_SQUASH", "S33_DISASSEMBLE", "S34_ASSEMBLE", "S36_ConwayProblem")
+	conway := "DISASSEMBLE = (" + progs["S33_DISASSEMBLE"] + ")\n" +
+		"SQUASH = (" + strings.SplitN(progs["S32_SQUASH"], "::", 2)[1] + ")\n" +
+		"ASSEMBLE = (" + progs["S34_ASSEMBLE"] + ")\n" +
+		progs["S36_ConwayProblem"]
+
+	tests := []struct {
+		src, want string
+	}{
+		{conway, `digraph {
+    p1 [label="west::DISASSEMBLE"];
+    p2 [label="X::SQUASH"];
+    p3 [label="east::ASSEMBLE"];
+    p4 [label="cardfile", shape=none];
+    p5 [label="lineprinter", shape=none];
+    p4 -> p1;
+    p1 -> p2;
+    p2 -> p3;
+    p3 -> p5;
+}
+`},
+		{`USER = (fac(1)!3; r:integer; fac(1)?r)
+		[fac(i:1..3)::*[n:integer; fac(i-1)?n -> fac(i+1)!n-1; r:integer; fac(i+1)?r; fac(i-1)!(n*r)]
+		|| fac(0)::USER]`, `digraph {
+    p1 [label="fac(i:1..3)"];
+    p2 [label="fac(0)::USER"];
+    p1 -> p1;
+    p2 -> p1;
+    p1 -> p2;
+}
+`},
+		{`in?x; [X::[Y::west?c || west::Y!c] || Z::X!1]; out!x`, `digraph {
+    p3 [label="X"];
+    p4 [label="Z"];
+    subgraph cluster_5 {
+        label="X";
+        p6 [label="Y"];
+        p7 [label="west"];
+    }
+    p1 [label="in", shape=none];
+    p2 [label="program"];
+    p8 [label="out", shape=none];
+    p1 -> p2;
+    p7 -> p6;
+    p4 -> p3;
+    p2 -> p8;
+}
+`},
+		{`COPY = (*[c:character; west?c -> east!c])
+		[X::COPY[[east <- Y]] || Y::COPY[[west <- X]]]`, `digraph {
+    p1 [label="X::COPY[[east <- Y]]"];
+    p2 [label="Y::COPY[[west <- X]]"];
+    p3 [label="west", shape=none];
+    p4 [label="east", shape=none];
+    p3 -> p1;
+    p1 -> p2;
+    p2 -> p4;
+}
+`},
+		{`[X::*[c:character; west?c → Y!c] ||| Y::*[c:character; X?c → east!c]]`, `digraph {
+    p1 [label="X"];
+    p2 [label="Y"];
+    p3 [label="west", shape=none];
+    p4 [label="Y", shape=none];
+    p5 [label="X", shape=none];
+    p6 [label="east", shape=none];
+    p3 -> p1;
+    p1 -> p4;
+    p5 -> p2;
+    p2 -> p6;
+}
+`},
+	}
+	for _, tt := range tests {
+		prog, err := lang.Parse(tt.src)
+		if err != nil {
+			t.Fatalf("%v: %v", t.Name(), err)
+		}
+		var b strings.Builder
+		if err := lang.Dot(&b, prog); err != nil {
+			t.Fatalf("%v: unexpected error: %v", t.Name(), err)
+		}
+		if got := b.String(); got != tt.want {
+			t.Fatalf("%v: expected:\n%v\ngot:\n%v", t.Name(), tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package lang
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// ErrorList is a list of errors sorted by position, as returned by
+// Parse for a source with several syntax errors.
+type ErrorList []*Error
+
+func (l ErrorList) Len() int           { return len(l) }
+func (l ErrorList) Swap(i, j int)      { l[i], l[j] = l[j], l[i] }
+func (l ErrorList) Less(i, j int) bool { return l[i].Pos.Offset < l[j].Pos.Offset }
+
+// Error returns the message of the first error and the number of the
+// others.
+func (l ErrorList) Error() string {
+	switch len(l) {
+	case 0:
+		return "no errors"
+	case 1:
+		return l[0].Error()
+	}
+	return fmt.Sprintf("%v (and %d more errors)", l[0], len(l)-1)
+}
+
+// Err returns l as an error, or nil if l is empty.
+func (l ErrorList) Err() error {
+	if len(l) == 0 {
+		return nil
+	}
+	return l
+}
+
+// PrintError prints the errors of err, one per line, each followed by
+// the line of src it refers to and a caret under its column:
+//
+//   prog.csp:1:25: expected →, found EOF
+//   	X::*[c:character; west?c
+//   	                        ^
+//
+// The name, if any, prefixes every error. Errors other than an
+// ErrorList or an *Error are printed as they are.
+func PrintError(w io.Writer, name, src string, err error) {
+	if name != "" {
+		name += ":"
+	}
+	var list ErrorList
+	var e *Error
+	switch {
+	case errors.As(err, &list):
+	case errors.As(err, &e):
+		list = ErrorList{e}
+	default:
+		fmt.Fprintf(w, "%s%v\n", name, err)
+		return
+	}
+	for _, e := range list {
+		fmt.Fprintf(w, "%s%v\n%s", name, e, Snippet(src, e.Pos))
+	}
+}
+
+// Snippet returns the line of src at pos, indented by a tab, and a
+// line with a caret under the column of pos.
+func Snippet(src string, pos Pos) string {
+	off := pos.Offset
+	if off > len(src) {
+		off = len(src)
+	}
+	start := strings.LastIndexByte(src[:off], '\n') + 1
+	end := strings.IndexByte(src[off:], '\n')
+	if end < 0 {
+		end = len(src)
+	} else {
+		end += off
+	}
+	// keep the tabs before the column so that the caret aligns.
+	caret := strings.Map(func(r rune) rune {
+		if r == '\t' {
+			return r
+		}
+		return ' '
+	}, src[start:off])
+	return fmt.Sprintf("\t%s\n\t%s^\n", src[start:end], caret)
+}
+
+// sortErrors sorts errs by position and returns them as an error, or
+// nil if there are none.
+func sortErrors(errs []*Error) error {
+	list := ErrorList(errs)
+	sort.Stable(list)
+	return list.Err()
+}
@@ -0,0 +1,67 @@
+package lang
+
+import (
+	"context"
+	"math/rand"
+	"strings"
+)
+
+// Estimate estimates the probability of every outcome of prog by runs
+// of it, the fraction of the runs of the outcome, a Monte-Carlo
+// simulation. Run i, from 0, runs with the Schedule Random(i) and a
+// Rand seeded with i, such that the estimate is reproducible, and in
+// virtual time.
+//
+// The external sources of a run are terminated, and its external
+// destinations accept any output. The outcome of a run is that outcome
+// returns of its timed trace, the communications it traced in turn,
+// and of the error it failed with, if any. If outcome is nil, the
+// outcome is the trace, such as X→Y: 1, Y→east: 1, followed by the
+// error, if any. Estimate fails once ctx is done.
+func Estimate(ctx context.Context, prog *Program, runs int, outcome func(trace []Comm, err error) string) (map[string]float64, error) {
+	if outcome == nil {
+		outcome = func(trace []Comm, err error) string {
+			comms := make([]string, len(trace))
+			for i, c := range trace {
+				comms[i] = c.String()
+			}
+			s := strings.Join(comms, ", ")
+			if err != nil {
+				return s + ": " + err.Error()
+			}
+			return s
+		}
+	}
+	inputs, outputs := Externals(prog)
+	probs := map[string]float64{}
+	for i := 0; i < runs; i++ {
+		in := &Interpreter{
+			Program:  prog,
+			Inputs:   map[string]<-chan Value{},
+			Outputs:  map[string]chan<- Value{},
+			Schedule: Random(int64(i)),
+			Rand:     rand.New(rand.NewSource(int64(i))),
+		}
+		for _, name := range inputs {
+			ch := make(chan Value)
+			close(ch)
+			in.Inputs[name] = ch
+		}
+		for _, name := range outputs {
+			ch := make(chan Value)
+			go func() {
+				for range ch {
+				}
+			}()
+			in.Outputs[name] = ch
+		}
+		var trace []Comm
+		in.Trace = func(c Comm) { trace = append(trace, c) }
+		err := in.Run(ctx)
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		probs[outcome(trace, err)] += 1 / float64(runs)
+	}
+	return probs, nil
+}
@@ -0,0 +1,715 @@
+package lang
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+)
+
+// explorer executes a program state by state, rather than on
+// goroutines, for an Analyzer. A state is a snapshot of all processes,
+// which the explorer copies before taking one of its steps, the steps
+// a Schedule would choose among. Processes run one at a time as they
+// do for a Schedule: each until it stops at a communication or an
+// alternative or repetitive command, waits for the processes of a
+// parallel command, or terminates.
+type explorer struct {
+	*process // evaluates expressions in its scope vars
+	inputs   map[string][]Value
+	nodes    map[Node]int // ids of the nodes of states, for their keys
+}
+
+func newExplorer(prog *Program, inputs map[string][]Value) (*explorer, error) {
+	m := &machine{Interpreter: &Interpreter{Program: prog}, defs: map[string]*Definition{}}
+	for _, d := range prog.Defs {
+		if _, ok := m.defs[d.Name]; ok {
+			return nil, &Error{Pos: d.Pos(), Msg: fmt.Sprintf("process %s redefined", d.Name)}
+		}
+		m.defs[d.Name] = d
+	}
+	return &explorer{process: &process{machine: m, ctx: context.Background()}, inputs: inputs, nodes: map[Node]int{}}, nil
+}
+
+// xstatus is the status of a process of a state.
+type xstatus int
+
+const (
+	xrunning xstatus = iota // runs until it stops
+	xwaiting                // for one of its choices, or its processes
+	xdone
+	xfailed
+)
+
+// xstate is a state of a program: its processes in the order they
+// started, the first is the program itself, and the number of values
+// input from every external source.
+type xstate struct {
+	procs  []*xproc
+	inputs map[string]int
+}
+
+// xproc is a process of a state.
+type xproc struct {
+	label   string
+	outer   int  // index of the process executing its parallel command, -1 for the program
+	inter   bool // of an interleaving, not named by the processes of its command
+	frames  []xframe
+	status  xstatus
+	choices []xchoice // of a waiting process, none while it waits for its processes
+}
+
+// xframe is a command list a process executes, or a repetitive
+// command it repeats.
+type xframe struct {
+	list *CmdList
+	pc   int
+	rep  *RepetitiveCmd
+	vars *scope
+	ref  *ProcRef // with renamings, of the body of a definition
+}
+
+// refs returns the references with renamings x executes, the innermost
+// last.
+func (x *xproc) refs() []*ProcRef {
+	var refs []*ProcRef
+	for _, f := range x.frames {
+		if f.ref != nil {
+			refs = append(refs, f.ref)
+		}
+	}
+	return refs
+}
+
+// xchoice is a guard without input, an input or an output a waiting
+// process offers to take, as a choice offered to a scheduler.
+type xchoice struct {
+	kind   choiceKind
+	guard  string      // description of a guard
+	gc     *GuardedCmd // of a guard
+	vars   *scope      // of a guard
+	peer   int         // index of the process communicated with, -1 for external names
+	comm   Comm        // of an input or output, Value set for an output
+	target Expr        // of an input
+}
+
+// xstep is a step of a state: the choice j of the process i, and for a
+// communication between processes the choice k of the process r
+// outputting the value v.
+type xstep struct {
+	step Step
+	i, j int
+	r, k int
+	v    Value
+
+	hidden bool // communicates with a hidden external name
+}
+
+// external reports whether s communicates with an external name which
+// is not hidden.
+func (s xstep) external() bool {
+	return s.r < 0 && s.step.Guard == "" && !s.hidden
+}
+
+// try calls f and returns the error of the process failure it panics
+// with, if any.
+func try(f func()) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			fl, ok := v.(failure)
+			if !ok {
+				panic(v)
+			}
+			err = fl.err
+		}
+	}()
+	f()
+	return nil
+}
+
+// initial returns the state of the program once it started.
+func (e *explorer) initial() *xstate {
+	st := &xstate{inputs: map[string]int{}}
+	st.procs = []*xproc{{outer: -1, frames: []xframe{{list: e.Program.Body, vars: newScope(nil)}}}}
+	e.settle(st)
+	return st
+}
+
+// settle runs the processes of st until they all wait, resolving the
+// choices of those whose choices all fail, unless a process failed.
+func (e *explorer) settle(st *xstate) {
+	for e.settleOnce(st) {
+	}
+}
+
+// settleOnce runs or resumes a process of st, it reports whether it
+// did.
+func (e *explorer) settleOnce(st *xstate) bool {
+	if st.failed() {
+		return false
+	}
+	for i, x := range st.procs {
+		switch {
+		case x.status == xrunning:
+			if err := try(func() { e.exec(st, i) }); err != nil {
+				x.status = xfailed
+			}
+			return true
+		case x.status != xwaiting:
+		case len(x.choices) == 0:
+			if e.joined(st, i) {
+				return true
+			}
+		case e.dead(st, x):
+			e.resolve(st, i, -1, nil)
+			return true
+		}
+	}
+	return false
+}
+
+// failed reports whether a process of st failed.
+func (st *xstate) failed() bool {
+	for _, x := range st.procs {
+		if x.status == xfailed {
+			return true
+		}
+	}
+	return false
+}
+
+// exec executes the process i of st until it stops or terminates.
+func (e *explorer) exec(st *xstate, i int) {
+	x := st.procs[i]
+	for len(x.frames) > 0 {
+		f := &x.frames[len(x.frames)-1]
+		e.vars = f.vars
+		if f.rep != nil {
+			if x.choices = e.alternative(st, i, f.rep.Alt); len(x.choices) > 0 {
+				x.status = xwaiting
+				return
+			}
+			x.frames = x.frames[:len(x.frames)-1]
+			continue
+		}
+		if f.pc == len(f.list.Stmts) {
+			x.frames = x.frames[:len(x.frames)-1]
+			continue
+		}
+		switch s := f.list.Stmts[f.pc].(type) {
+		case *Declaration:
+			e.declare(s)
+		case *SkipCmd, *WaitCmd:
+		case *AssignmentCmd:
+			e.assign(s.Target, e.eval(s.Value))
+		case *InputCmd:
+			c := e.input(st, i, s.Source)
+			c.target = s.Target
+			x.choices, x.status = []xchoice{c}, xwaiting
+			return
+		case *OutputCmd:
+			v := copyValue(e.eval(s.Value))
+			c := e.output(st, i, s.Dest)
+			c.comm.Value = v
+			x.choices, x.status = []xchoice{c}, xwaiting
+			return
+		case *ParallelCmd:
+			if e.parallel(st, i, s) {
+				x.status = xwaiting
+				return
+			}
+		case *AlternativeCmd:
+			if x.choices = e.alternative(st, i, s); len(x.choices) == 0 {
+				e.errorf(s, "all guards fail")
+			}
+			x.status = xwaiting
+			return
+		case *RepetitiveCmd:
+			f.pc++
+			x.frames = append(x.frames, xframe{rep: s, vars: f.vars})
+			continue
+		case *ProcRef:
+			d, ok := e.defs[s.Name]
+			if !ok {
+				e.errorf(s, "undefined process %s", s.Name)
+			}
+			f.pc++
+			ref := s
+			if len(s.Renames) == 0 {
+				ref = nil
+			}
+			x.frames = append(x.frames, xframe{list: d.Body, vars: f.vars, ref: ref})
+			continue
+		default:
+			e.errorf(s, "unexpected %T", s)
+		}
+		f.pc++
+	}
+	x.status = xdone
+}
+
+// parallel starts the processes of cmd executed by the process i of
+// st, it reports whether there are any.
+func (e *explorer) parallel(st *xstate, i int, cmd *ParallelCmd) bool {
+	var insts []instance
+	for _, proc := range cmd.Procs {
+		insts = append(insts, e.instances(proc)...)
+	}
+	labels := map[string]bool{}
+	for _, inst := range insts {
+		if inst.label == "" {
+			continue
+		}
+		if labels[inst.label] {
+			e.errorf(inst.proc.Label, "duplicate process label %s", inst.label)
+		}
+		labels[inst.label] = true
+	}
+	for _, inst := range insts {
+		st.procs = append(st.procs, &xproc{label: inst.label, outer: i, inter: cmd.Interleave, frames: []xframe{{list: inst.proc.Body, vars: inst.vars}}})
+	}
+	return len(insts) > 0
+}
+
+// joined resumes the process i of st if its processes all terminated,
+// which it removes from st, it reports whether it did.
+func (e *explorer) joined(st *xstate, i int) bool {
+	for _, x := range st.procs {
+		if x.outer == i && x.status != xdone {
+			return false
+		}
+	}
+	index := make([]int, len(st.procs))
+	procs := st.procs[:0]
+	for j, x := range st.procs {
+		index[j] = -1
+		if x.outer != i {
+			index[j] = len(procs)
+			procs = append(procs, x)
+		}
+	}
+	for j := len(procs); j < len(st.procs); j++ {
+		st.procs[j] = nil
+	}
+	st.procs = procs
+	for _, x := range procs {
+		if x.outer >= 0 {
+			x.outer = index[x.outer]
+		}
+		for j := range x.choices {
+			if c := &x.choices[j]; c.peer >= 0 {
+				c.peer = index[c.peer]
+			}
+		}
+	}
+	x := st.procs[index[i]]
+	x.frames[len(x.frames)-1].pc++
+	x.status = xrunning
+	return true
+}
+
+// alternative returns the choices of the guards of cmd which are not
+// false for the process i of st.
+func (e *explorer) alternative(st *xstate, i int, cmd *AlternativeCmd) []xchoice {
+	var choices []xchoice
+	for _, gc := range cmd.Cmds {
+		for _, s := range e.replicas(gc) {
+			if !e.guard(s, gc.Guard) {
+				continue
+			}
+			if pr := gc.Guard.Prob; pr != nil {
+				var w int
+				e.within(s, func() { w = e.int(pr.Weight) })
+				if w < 0 {
+					e.errorf(pr.Weight, "negative weight %d", w)
+				}
+				if w == 0 {
+					continue
+				}
+			}
+			c := xchoice{kind: guardChoice, peer: -1}
+			if input := gc.Guard.Input; input != nil {
+				e.within(s, func() { c = e.input(st, i, input.Source) })
+				c.target = input.Target
+			}
+			c.guard, c.gc, c.vars = describe(s, gc), gc, s
+			choices = append(choices, c)
+		}
+	}
+	return choices
+}
+
+// port returns the index of the process named n communicating with the
+// process i of st, as for peer, and the label of the process of its
+// parallel command communicating on behalf of i, or -1 and the name of
+// n for an external name.
+func (e *explorer) port(st *xstate, i int, n *ProcName) (peer int, self, name string) {
+	name = e.name(n.Name, n.Subscripts)
+	for q := i; q >= 0; q = st.procs[q].outer {
+		name = renamed(st.procs[q].refs(), name)
+		g := st.procs[q].outer
+		if g < 0 || st.procs[q].inter {
+			continue
+		}
+		for j, x := range st.procs {
+			if x.outer != g || x.label != name {
+				continue
+			}
+			if j == q {
+				e.errorf(n, "process %s names itself", name)
+			}
+			return j, st.procs[q].label, name
+		}
+	}
+	return -1, st.self(i), name
+}
+
+// input returns the choice of the process i of st to input from n.
+func (e *explorer) input(st *xstate, i int, n *ProcName) xchoice {
+	peer, self, name := e.port(st, i, n)
+	return xchoice{kind: inputChoice, peer: peer, comm: Comm{Src: name, Dst: self}}
+}
+
+// output returns the choice of the process i of st to output to n.
+func (e *explorer) output(st *xstate, i int, n *ProcName) xchoice {
+	peer, self, name := e.port(st, i, n)
+	return xchoice{kind: outputChoice, peer: peer, comm: Comm{Src: self, Dst: name}}
+}
+
+// self returns the label of the innermost labelled process executing
+// the process i of st.
+func (st *xstate) self(i int) string {
+	for ; i >= 0; i = st.procs[i].outer {
+		if st.procs[i].label != "" {
+			return st.procs[i].label
+		}
+	}
+	return ""
+}
+
+// dead reports whether the choices of x all fail: as for a Schedule,
+// those communicating with terminated processes, and inputs from
+// exhausted external sources.
+func (e *explorer) dead(st *xstate, x *xproc) bool {
+	for _, c := range x.choices {
+		switch {
+		case c.kind == guardChoice:
+			return false
+		case c.peer >= 0:
+			if st.procs[c.peer].status != xdone {
+				return false
+			}
+		case c.kind == outputChoice || st.inputs[c.comm.Src] < len(e.inputs[c.comm.Src]):
+			return false
+		}
+	}
+	return true
+}
+
+// resolve takes the choice j of the process i of st, which inputs v,
+// or fails all of its choices if j is -1.
+func (e *explorer) resolve(st *xstate, i, j int, v Value) {
+	x := st.procs[i]
+	var c xchoice
+	if j >= 0 {
+		c = x.choices[j]
+	}
+	src, dst := x.choices[0].comm.Src, x.choices[0].comm.Dst
+	x.choices, x.status = nil, xrunning
+	err := try(func() {
+		f := &x.frames[len(x.frames)-1]
+		e.vars = f.vars
+		if f.rep != nil {
+			if j < 0 {
+				x.frames = x.frames[:len(x.frames)-1]
+				return
+			}
+			e.guarded(x, c, v)
+			return
+		}
+		switch s := f.list.Stmts[f.pc].(type) {
+		case *InputCmd:
+			if j < 0 {
+				e.errorf(s.Source, "input from terminated process %s", src)
+			}
+			f.pc++
+			e.assign(s.Target, v)
+		case *OutputCmd:
+			if j < 0 {
+				e.errorf(s.Dest, "output to terminated process %s", dst)
+			}
+			f.pc++
+		case *AlternativeCmd:
+			if j < 0 {
+				e.errorf(s, "all guards fail")
+			}
+			f.pc++
+			e.guarded(x, c, v)
+		}
+	})
+	if err != nil {
+		x.status = xfailed
+	}
+}
+
+// guarded executes the guarded command of the choice c of x, which
+// inputs v.
+func (e *explorer) guarded(x *xproc, c xchoice, v Value) {
+	x.frames = append(x.frames, xframe{list: c.gc.Body, vars: c.vars})
+	if c.kind == inputChoice {
+		e.vars = c.vars
+		e.assign(c.target, v)
+	}
+}
+
+// steps returns the steps of st, in the order a Schedule is offered
+// them, none if a process failed.
+func (e *explorer) steps(st *xstate) []xstep {
+	if st.failed() {
+		return nil
+	}
+	var steps []xstep
+	for i, x := range st.procs {
+		if x.status != xwaiting {
+			continue
+		}
+		for j, c := range x.choices {
+			switch {
+			case c.kind == guardChoice:
+				steps = append(steps, xstep{step: Step{Process: st.self(i), Guard: c.guard}, i: i, j: j, r: -1})
+			case c.peer < 0 && c.kind == outputChoice:
+				steps = append(steps, xstep{step: Step{Comm: c.comm}, i: i, j: j, r: -1})
+			case c.peer < 0:
+				vs := e.inputs[c.comm.Src]
+				n := st.inputs[c.comm.Src]
+				if n == len(vs) || c.gc != nil && !e.accepts(c, vs[n]) {
+					continue
+				}
+				steps = append(steps, xstep{step: Step{Comm: c.comm}, i: i, j: j, r: -1, v: vs[n]})
+			case c.kind == inputChoice:
+				for r, y := range st.procs {
+					if y.status != xwaiting {
+						continue
+					}
+					for k, out := range y.choices {
+						if out.kind != outputChoice || out.peer < 0 || out.comm.Src != c.comm.Src || out.comm.Dst != c.comm.Dst ||
+							st.procs[out.peer].outer != st.procs[c.peer].outer || c.gc != nil && !e.accepts(c, out.comm.Value) {
+							continue
+						}
+						steps = append(steps, xstep{step: Step{Comm: out.comm}, i: i, j: j, r: r, k: k, v: out.comm.Value})
+					}
+				}
+			}
+		}
+	}
+	return steps
+}
+
+// accepts reports whether v matches the target of the input guard c.
+func (e *explorer) accepts(c xchoice, v Value) bool {
+	e.vars = c.vars
+	ok := false
+	if err := try(func() { ok = e.process.matches(c.target, v) }); err != nil {
+		return false
+	}
+	return ok
+}
+
+// take returns the state st takes s to.
+func (e *explorer) take(st *xstate, s xstep) *xstate {
+	st = st.clone()
+	if s.r >= 0 {
+		e.resolve(st, s.r, s.k, nil)
+	}
+	if c := st.procs[s.i].choices[s.j]; c.kind == inputChoice && c.peer < 0 {
+		st.inputs[c.comm.Src]++
+	}
+	e.resolve(st, s.i, s.j, copyValue(s.v))
+	e.settle(st)
+	return st
+}
+
+// states returns the states of the processes of st, for a Deadlock.
+func (st *xstate) states() []ProcState {
+	states := make([]ProcState, len(st.procs))
+	for i, x := range st.procs {
+		states[i] = ProcState{Label: x.label, Done: x.status == xdone}
+		if len(x.frames) == 0 {
+			continue
+		}
+		f := x.frames[len(x.frames)-1]
+		if f.rep != nil {
+			states[i].Cmd = f.rep
+		} else {
+			states[i].Cmd = f.list.Stmts[f.pc]
+		}
+		states[i].Vars = map[string]Value{}
+		for s := f.vars; s != nil; s = s.outer {
+			for name, x := range s.vars {
+				if _, ok := states[i].Vars[name]; !ok {
+					states[i].Vars[name] = copyValue(x.val)
+				}
+			}
+		}
+	}
+	return states
+}
+
+// clone returns a copy of st which shares no scopes, variables or
+// values with st.
+func (st *xstate) clone() *xstate {
+	c := &cloner{scopes: map[*scope]*scope{}, vars: map[*variable]*variable{}}
+	n := &xstate{procs: make([]*xproc, len(st.procs)), inputs: make(map[string]int, len(st.inputs))}
+	for name, i := range st.inputs {
+		n.inputs[name] = i
+	}
+	for i, x := range st.procs {
+		y := &xproc{label: x.label, outer: x.outer, inter: x.inter, status: x.status, frames: make([]xframe, len(x.frames))}
+		for j, f := range x.frames {
+			f.vars = c.scope(f.vars)
+			y.frames[j] = f
+		}
+		for _, ch := range x.choices {
+			ch.vars = c.scope(ch.vars)
+			ch.comm.Value = copyValue(ch.comm.Value)
+			y.choices = append(y.choices, ch)
+		}
+		n.procs[i] = y
+	}
+	return n
+}
+
+// cloner copies scopes, preserving the scopes and variables they
+// share.
+type cloner struct {
+	scopes map[*scope]*scope
+	vars   map[*variable]*variable
+}
+
+func (c *cloner) scope(s *scope) *scope {
+	if s == nil {
+		return nil
+	}
+	if n, ok := c.scopes[s]; ok {
+		return n
+	}
+	n := newScope(c.scope(s.outer))
+	c.scopes[s] = n
+	for name, x := range s.vars {
+		y, ok := c.vars[x]
+		if !ok {
+			y = &variable{typ: x.typ, lo: x.lo, val: copyValue(x.val), bound: x.bound}
+			c.vars[x] = y
+		}
+		n.vars[name] = y
+	}
+	return n
+}
+
+// key returns a description of st, equal for equal states.
+func (e *explorer) key(st *xstate) string {
+	k := &keyer{nodes: e.nodes, ids: map[interface{}]int{}}
+	for _, x := range st.procs {
+		k.str(x.label)
+		k.int(x.outer)
+		k.int(int(x.status))
+		for _, f := range x.frames {
+			k.node(f.list)
+			k.int(f.pc)
+			k.node(f.rep)
+			k.scope(f.vars)
+			k.node(f.ref)
+		}
+		k.b = append(k.b, '|')
+		for _, c := range x.choices {
+			k.int(int(c.kind))
+			k.str(c.guard)
+			k.node(c.gc)
+			k.int(c.peer)
+			k.str(c.comm.Src)
+			k.str(c.comm.Dst)
+			k.str(Format(c.comm.Value))
+			k.scope(c.vars)
+		}
+		k.b = append(k.b, '\n')
+	}
+	names := make([]string, 0, len(st.inputs))
+	for name := range st.inputs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		k.str(name)
+		k.int(st.inputs[name])
+	}
+	return string(k.b)
+}
+
+// keyer describes the nodes of the syntax tree by the order they were
+// first described in by an explorer, and scopes and variables by the
+// order they are first described in by a key, such that equal states
+// have equal keys.
+type keyer struct {
+	b     []byte
+	nodes map[Node]int
+	ids   map[interface{}]int
+}
+
+func (k *keyer) int(i int) {
+	k.b = strconv.AppendInt(k.b, int64(i), 10)
+	k.b = append(k.b, ' ')
+}
+
+func (k *keyer) str(s string) {
+	k.b = strconv.AppendInt(k.b, int64(len(s)), 10)
+	k.b = append(k.b, ':')
+	k.b = append(k.b, s...)
+}
+
+func (k *keyer) node(n Node) {
+	if reflect.ValueOf(n).IsNil() {
+		k.int(-1)
+		return
+	}
+	id, ok := k.nodes[n]
+	if !ok {
+		id = len(k.nodes)
+		k.nodes[n] = id
+	}
+	k.int(id)
+}
+
+func (k *keyer) scope(s *scope) {
+	if s == nil {
+		k.int(-1)
+		return
+	}
+	if id, ok := k.ids[s]; ok {
+		k.int(id)
+		return
+	}
+	id := len(k.ids)
+	k.ids[s] = id
+	k.int(id)
+	k.b = append(k.b, '{')
+	k.scope(s.outer)
+	names := make([]string, 0, len(s.vars))
+	for name := range s.vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		x := s.vars[name]
+		k.str(name)
+		if id, ok := k.ids[x]; ok {
+			k.int(id)
+			continue
+		}
+		id := len(k.ids)
+		k.ids[x] = id
+		k.int(id)
+		k.str(Format(x.val))
+	}
+	k.b = append(k.b, '}')
+}
@@ -0,0 +1,823 @@
+// Package extract extracts approximate descriptions in the notation of
+// package lang from Go functions communicating on channels, such that
+// existing goroutine code can be viewed as processes of the paper and
+// processed by the tools of package lang.
+//
+// The function
+//
+//   func Copy(west <-chan rune, east chan<- rune) {
+//   	for c := range west {
+//   		east <- c
+//   	}
+//   	close(east)
+//   }
+//
+// is described as
+//
+//   Copy::*[c:character; west?c → east!c]
+//
+// A channel is named like the process it connects to: a send is an
+// output command, a receive an input command, and a select statement
+// an alternative command whose guards are its cases. A loop becomes a
+// repetitive command, for ever being a repetitive command guarded by
+// true, and a loop consisting of a select statement the repetitive
+// command of its cases, which terminates once the sources of all of
+// them are closed. Goroutines started by a function run in parallel
+// with its remaining statements.
+//
+// The description is approximate. Only the syntax of the function is
+// inspected, hence only variables of basic types whose type is evident
+// are declared, expressions which have no counterpart in the notation
+// are the empty structured value (), conditions which cannot be
+// expressed are replaced by true, and statements without communication
+// such as return, break and defer are omitted.
+package extract
+
+import (
+	"go/ast"
+	"go/token"
+	"strconv"
+
+	"github.com/changkun/gobase/csp/lang"
+)
+
+// Func returns the description of fn as a program of a single process
+// labelled by the name of fn.
+func Func(fn *ast.FuncDecl) *lang.Program {
+	e := newExtractor(nil)
+	body := e.function(fn)
+	return &lang.Program{Body: &lang.CmdList{Stmts: []lang.Stmt{
+		&lang.ParallelCmd{Implicit: true, Procs: []*lang.Proc{
+			{Label: &lang.ProcLabel{Name: name(fn.Name.Name)}, Body: body},
+		}},
+	}}}
+}
+
+// File returns the descriptions of the functions of f which communicate
+// on channels, as definitions named by the functions. A call of such a
+// function, or a goroutine running it, refers to its definition.
+func File(f *ast.File) *lang.Program {
+	fns := []*ast.FuncDecl{}
+	for _, decl := range f.Decls {
+		if fn, ok := decl.(*ast.FuncDecl); ok && fn.Body != nil && fn.Recv == nil && communicates(fn.Body) {
+			fns = append(fns, fn)
+		}
+	}
+	funcs := map[string]bool{}
+	for _, fn := range fns {
+		funcs[fn.Name.Name] = true
+	}
+
+	prog := &lang.Program{Body: &lang.CmdList{}}
+	for _, fn := range fns {
+		e := newExtractor(funcs)
+		prog.Defs = append(prog.Defs, &lang.Definition{Name: name(fn.Name.Name), Body: e.function(fn)})
+	}
+	return prog
+}
+
+// communicates reports whether n sends, receives, selects, or starts a
+// goroutine.
+func communicates(n ast.Node) bool {
+	found := false
+	ast.Inspect(n, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.SendStmt, *ast.SelectStmt, *ast.GoStmt:
+			found = true
+		case *ast.UnaryExpr:
+			found = found || n.Op == token.ARROW
+		}
+		return !found
+	})
+	return found
+}
+
+// keywords are the words of the notation which are no identifiers.
+var keywords = map[string]bool{
+	"skip": true, "mod": true, "and": true, "or": true, "not": true, "comment": true,
+}
+
+// name returns the identifier of the notation naming the Go identifier
+// s.
+func name(s string) string {
+	if keywords[s] {
+		return s + "_"
+	}
+	return s
+}
+
+// extractor extracts the description of a function.
+type extractor struct {
+	funcs map[string]bool   // functions referred to by name
+	types map[string]string // types of variables, by the names of the notation
+	chans map[string]string // channels and the types of their elements
+	gos   int               // number of goroutines
+	label string            // label of the function
+}
+
+func newExtractor(funcs map[string]bool) *extractor {
+	return &extractor{
+		funcs: funcs,
+		types: map[string]string{},
+		chans: map[string]string{},
+	}
+}
+
+// basic are the names of the notation of the basic types of Go.
+var basic = map[string]string{
+	"int": "integer", "int8": "integer", "int16": "integer", "int32": "integer", "int64": "integer",
+	"uint": "integer", "uint8": "integer", "uint16": "integer", "uint32": "integer", "uint64": "integer",
+	"uintptr": "integer",
+	"rune":    "character",
+	"byte":    "character",
+	"bool":    "boolean",
+}
+
+// typeName returns the name of the notation of the Go type t, or "" if
+// it has none.
+func typeName(t ast.Expr) string {
+	if id, ok := t.(*ast.Ident); ok {
+		return basic[id.Name]
+	}
+	return ""
+}
+
+func (e *extractor) function(fn *ast.FuncDecl) *lang.CmdList {
+	e.label = name(fn.Name.Name)
+	for _, field := range fn.Type.Params.List {
+		if ch, ok := field.Type.(*ast.ChanType); ok {
+			for _, id := range field.Names {
+				e.chans[id.Name] = typeName(ch.Value)
+			}
+			continue
+		}
+		for _, id := range field.Names {
+			e.types[id.Name] = typeName(field.Type)
+		}
+	}
+	// channels are also evident from their use.
+	if fn.Body != nil {
+		ast.Inspect(fn.Body, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.SendStmt:
+				e.channel(n.Chan)
+			case *ast.UnaryExpr:
+				if n.Op == token.ARROW {
+					e.channel(n.X)
+				}
+			}
+			return true
+		})
+	}
+	if fn.Body == nil {
+		return list(nil)
+	}
+	return list(e.block(fn.Body.List))
+}
+
+// channel records x as a channel, if it is a variable.
+func (e *extractor) channel(x ast.Expr) {
+	if id, ok := x.(*ast.Ident); ok {
+		if _, ok := e.chans[id.Name]; !ok {
+			e.chans[id.Name] = ""
+		}
+	}
+}
+
+// list returns a command list of stmts, which is skip if there are
+// none.
+func list(stmts []lang.Stmt) *lang.CmdList {
+	if len(stmts) == 0 {
+		stmts = []lang.Stmt{&lang.SkipCmd{}}
+	}
+	return &lang.CmdList{Stmts: stmts}
+}
+
+// block returns the commands of stmts. Goroutines run in parallel with
+// the statements following them.
+func (e *extractor) block(stmts []ast.Stmt) []lang.Stmt {
+	out := []lang.Stmt{}
+	for i, s := range stmts {
+		if _, ok := s.(*ast.GoStmt); !ok {
+			out = append(out, e.stmt(s)...)
+			continue
+		}
+		par := &lang.ParallelCmd{}
+		rest := []ast.Stmt{}
+		for _, s := range stmts[i:] {
+			if g, ok := s.(*ast.GoStmt); ok {
+				par.Procs = append(par.Procs, e.goroutine(g))
+				continue
+			}
+			rest = append(rest, s)
+		}
+		if body := e.block(rest); len(body) > 0 {
+			par.Procs = append(par.Procs, &lang.Proc{Label: &lang.ProcLabel{Name: e.label}, Body: list(body)})
+		}
+		return append(out, par)
+	}
+	return out
+}
+
+// goroutine returns the process started by g.
+func (e *extractor) goroutine(g *ast.GoStmt) *lang.Proc {
+	e.gos++
+	label := "g" + strconv.Itoa(e.gos)
+	var body []lang.Stmt
+	switch fun := g.Call.Fun.(type) {
+	case *ast.FuncLit:
+		body = e.block(fun.Body.List)
+	case *ast.Ident:
+		if e.funcs[fun.Name] {
+			body = []lang.Stmt{&lang.ProcRef{Name: name(fun.Name)}}
+		}
+	}
+	return &lang.Proc{Label: &lang.ProcLabel{Name: label}, Body: list(body)}
+}
+
+// stmt returns the commands of s.
+func (e *extractor) stmt(s ast.Stmt) []lang.Stmt {
+	switch s := s.(type) {
+	case *ast.BlockStmt:
+		return e.block(s.List)
+	case *ast.LabeledStmt:
+		return e.stmt(s.Stmt)
+	case *ast.DeclStmt:
+		return e.decl(s)
+	case *ast.AssignStmt:
+		return e.assign(s)
+	case *ast.IncDecStmt:
+		op := lang.ADD
+		if s.Tok == token.DEC {
+			op = lang.SUB
+		}
+		if target := e.target(s.X); target != nil {
+			return []lang.Stmt{&lang.AssignmentCmd{
+				Target: target,
+				Value:  &lang.BinaryExpr{X: e.expr(s.X), Op: op, Y: &lang.IntLit{Value: 1}},
+			}}
+		}
+	case *ast.SendStmt:
+		return []lang.Stmt{e.output(s)}
+	case *ast.ExprStmt:
+		if u, ok := s.X.(*ast.UnaryExpr); ok && u.Op == token.ARROW {
+			return []lang.Stmt{&lang.InputCmd{Source: e.procName(u.X), Target: &lang.StructuredExpr{}}}
+		}
+		if call, ok := s.X.(*ast.CallExpr); ok {
+			if id, ok := call.Fun.(*ast.Ident); ok && e.funcs[id.Name] {
+				return []lang.Stmt{&lang.ProcRef{Name: name(id.Name)}}
+			}
+		}
+	case *ast.IfStmt:
+		return e.ifStmt(s)
+	case *ast.ForStmt:
+		return e.forStmt(s)
+	case *ast.RangeStmt:
+		return e.rangeStmt(s)
+	case *ast.SelectStmt:
+		if alt := e.selectStmt(s); alt != nil {
+			return []lang.Stmt{alt}
+		}
+	case *ast.SwitchStmt:
+		return e.switchStmt(s)
+	}
+	return nil
+}
+
+// decl returns the declarations and assignments of the variables
+// declared by s.
+func (e *extractor) decl(s *ast.DeclStmt) []lang.Stmt {
+	gen, ok := s.Decl.(*ast.GenDecl)
+	if !ok || gen.Tok != token.VAR {
+		return nil
+	}
+	out := []lang.Stmt{}
+	for _, spec := range gen.Specs {
+		v := spec.(*ast.ValueSpec)
+		if ch, ok := v.Type.(*ast.ChanType); ok {
+			for _, id := range v.Names {
+				e.chans[id.Name] = typeName(ch.Value)
+			}
+			continue
+		}
+		for i, id := range v.Names {
+			typ := typeName(v.Type)
+			var value ast.Expr
+			if i < len(v.Values) && len(v.Values) == len(v.Names) {
+				value = v.Values[i]
+				if typ == "" {
+					typ = e.typeOf(value)
+				}
+			}
+			out = append(out, e.define(id, typ, value)...)
+		}
+	}
+	return out
+}
+
+// define returns the declaration of the variable id of type typ and
+// its assignment of value, if any.
+func (e *extractor) define(id *ast.Ident, typ string, value ast.Expr) []lang.Stmt {
+	if id.Name == "_" {
+		return nil
+	}
+	if make, ok := value.(*ast.CallExpr); ok && isMake(make) {
+		if ch, ok := make.Args[0].(*ast.ChanType); ok {
+			e.chans[id.Name] = typeName(ch.Value)
+			return nil
+		}
+	}
+	out := []lang.Stmt{}
+	if typ != "" {
+		e.types[id.Name] = typ
+		out = append(out, &lang.Declaration{
+			Names: []*lang.Ident{{Name: name(id.Name)}},
+			Type:  &lang.NamedType{Name: typ},
+		})
+	}
+	if value != nil {
+		out = append(out, &lang.AssignmentCmd{Target: &lang.Ident{Name: name(id.Name)}, Value: e.expr(value)})
+	}
+	return out
+}
+
+func isMake(call *ast.CallExpr) bool {
+	id, ok := call.Fun.(*ast.Ident)
+	return ok && id.Name == "make" && len(call.Args) > 0
+}
+
+// assignments of an operator, such as x += 1, by their operator.
+var assignOps = map[token.Token]token.Token{
+	token.ADD_ASSIGN: token.ADD,
+	token.SUB_ASSIGN: token.SUB,
+	token.MUL_ASSIGN: token.MUL,
+	token.QUO_ASSIGN: token.QUO,
+	token.REM_ASSIGN: token.REM,
+}
+
+func (e *extractor) assign(s *ast.AssignStmt) []lang.Stmt {
+	// a receive is an input command.
+	if len(s.Rhs) == 1 {
+		if u, ok := s.Rhs[0].(*ast.UnaryExpr); ok && u.Op == token.ARROW {
+			return e.input(s.Lhs[0], u.X, s.Tok == token.DEFINE)
+		}
+	}
+	if len(s.Lhs) != len(s.Rhs) {
+		// x, y := f() assigns the components of a structured value.
+		target := &lang.StructuredExpr{}
+		for _, x := range s.Lhs {
+			t := e.target(x)
+			if t == nil {
+				t = &lang.StructuredExpr{}
+			}
+			target.Args = append(target.Args, t)
+		}
+		return []lang.Stmt{&lang.AssignmentCmd{Target: target, Value: e.expr(s.Rhs[0])}}
+	}
+
+	out := []lang.Stmt{}
+	for i, x := range s.Lhs {
+		value := s.Rhs[i]
+		if op, ok := assignOps[s.Tok]; ok {
+			value = &ast.BinaryExpr{X: x, Op: op, Y: value}
+		}
+		if id, ok := x.(*ast.Ident); ok && s.Tok == token.DEFINE {
+			out = append(out, e.define(id, e.typeOf(value), value)...)
+			continue
+		}
+		if target := e.target(x); target != nil {
+			out = append(out, &lang.AssignmentCmd{Target: target, Value: e.expr(value)})
+		}
+	}
+	return out
+}
+
+// input returns the input command receiving from the channel ch to the
+// target x, which is preceded by its declaration if define is set.
+func (e *extractor) input(x, ch ast.Expr, define bool) []lang.Stmt {
+	cmd := &lang.InputCmd{Source: e.procName(ch), Target: e.target(x)}
+	if cmd.Target == nil {
+		cmd.Target = &lang.StructuredExpr{}
+		return []lang.Stmt{cmd}
+	}
+	id, ok := x.(*ast.Ident)
+	if !define || !ok {
+		return []lang.Stmt{cmd}
+	}
+	decl := e.define(id, e.elem(ch), nil)
+	return append(decl, cmd)
+}
+
+// isChan reports whether x is a channel.
+func (e *extractor) isChan(x ast.Expr) bool {
+	id, ok := x.(*ast.Ident)
+	if !ok {
+		return false
+	}
+	_, ok = e.chans[id.Name]
+	return ok
+}
+
+// elem returns the type of the elements of the channel ch.
+func (e *extractor) elem(ch ast.Expr) string {
+	if id, ok := ch.(*ast.Ident); ok {
+		return e.chans[id.Name]
+	}
+	return ""
+}
+
+func (e *extractor) output(s *ast.SendStmt) *lang.OutputCmd {
+	return &lang.OutputCmd{Dest: e.procName(s.Chan), Value: e.expr(s.Value)}
+}
+
+// procName returns the name of the process the channel ch connects to.
+func (e *extractor) procName(ch ast.Expr) *lang.ProcName {
+	switch ch := ch.(type) {
+	case *ast.Ident:
+		return &lang.ProcName{Name: name(ch.Name)}
+	case *ast.SelectorExpr:
+		return &lang.ProcName{Name: name(ch.Sel.Name)}
+	case *ast.ParenExpr:
+		return e.procName(ch.X)
+	case *ast.IndexExpr:
+		n := e.procName(ch.X)
+		n.Subscripts = append(n.Subscripts, e.expr(ch.Index))
+		return n
+	case *ast.CallExpr:
+		// <-ctx.Done() inputs from Done.
+		return e.procName(ch.Fun)
+	}
+	return &lang.ProcName{Name: "chan"}
+}
+
+// target returns the target variable of x, or nil for the blank
+// identifier.
+func (e *extractor) target(x ast.Expr) lang.Expr {
+	switch x := x.(type) {
+	case *ast.Ident:
+		if x.Name == "_" {
+			return nil
+		}
+		return &lang.Ident{Name: name(x.Name)}
+	case *ast.StarExpr:
+		return e.target(x.X)
+	case *ast.ParenExpr:
+		return e.target(x.X)
+	}
+	return e.expr(x)
+}
+
+// cond returns the condition x, or nil if it cannot be expressed.
+func (e *extractor) cond(x ast.Expr) lang.Expr {
+	c := e.expr(x)
+	unknown := false
+	lang.Inspect(c, func(n lang.Node) bool {
+		if s, ok := n.(*lang.StructuredExpr); ok && s.Constructor == "" && len(s.Args) == 0 {
+			unknown = true
+		}
+		return !unknown
+	})
+	if unknown {
+		return nil
+	}
+	return c
+}
+
+// negations are the negated comparisons.
+var negations = map[lang.Token]lang.Token{
+	lang.EQ: lang.NEQ, lang.NEQ: lang.EQ,
+	lang.LT: lang.GEQ, lang.GEQ: lang.LT,
+	lang.GT: lang.LEQ, lang.LEQ: lang.GT,
+}
+
+// not returns the negation of c.
+func not(c lang.Expr) lang.Expr {
+	if u, ok := c.(*lang.UnaryExpr); ok && u.Op == lang.NOT {
+		return u.X
+	}
+	if b, ok := c.(*lang.BinaryExpr); ok {
+		if op, ok := negations[b.Op]; ok {
+			return &lang.BinaryExpr{X: b.X, Op: op, Y: b.Y}
+		}
+		c = &lang.ParenExpr{X: c}
+	}
+	return &lang.UnaryExpr{Op: lang.NOT, X: c}
+}
+
+// guarded returns the guarded command of the condition c, which is
+// true if c is nil, and the body stmts.
+func guarded(c lang.Expr, stmts []lang.Stmt) *lang.GuardedCmd {
+	if c == nil {
+		c = &lang.Ident{Name: "true"}
+	}
+	return &lang.GuardedCmd{Guard: &lang.Guard{List: []lang.Node{c}}, Body: list(stmts)}
+}
+
+// ifStmt returns the alternative command of s: a condition which cannot
+// be expressed chooses either branch.
+func (e *extractor) ifStmt(s *ast.IfStmt) []lang.Stmt {
+	out := []lang.Stmt{}
+	if s.Init != nil {
+		out = append(out, e.stmt(s.Init)...)
+	}
+	c := e.cond(s.Cond)
+	var otherwise lang.Expr
+	if c != nil {
+		otherwise = not(c)
+	}
+	alt := &lang.AlternativeCmd{Cmds: []*lang.GuardedCmd{guarded(c, e.block(s.Body.List))}}
+	var els []lang.Stmt
+	if s.Else != nil {
+		els = e.stmt(s.Else)
+	}
+	alt.Cmds = append(alt.Cmds, guarded(otherwise, els))
+	return append(out, alt)
+}
+
+func (e *extractor) forStmt(s *ast.ForStmt) []lang.Stmt {
+	out := []lang.Stmt{}
+	if s.Init != nil {
+		out = append(out, e.stmt(s.Init)...)
+	}
+	// for { select { ... } } repeats the cases of the select statement.
+	if s.Cond == nil && s.Post == nil && len(s.Body.List) == 1 {
+		if sel, ok := s.Body.List[0].(*ast.SelectStmt); ok {
+			if alt := e.selectStmt(sel); alt != nil {
+				return append(out, &lang.RepetitiveCmd{Alt: alt})
+			}
+		}
+	}
+	var c lang.Expr
+	if s.Cond != nil {
+		c = e.cond(s.Cond)
+	}
+	body := e.block(s.Body.List)
+	if s.Post != nil {
+		body = append(body, e.stmt(s.Post)...)
+	}
+	rep := &lang.RepetitiveCmd{Alt: &lang.AlternativeCmd{Cmds: []*lang.GuardedCmd{guarded(c, body)}}}
+	return append(out, rep)
+}
+
+// rangeStmt returns the repetitive command of s, which inputs from a
+// channel until it is closed, or repeats while true otherwise.
+func (e *extractor) rangeStmt(s *ast.RangeStmt) []lang.Stmt {
+	if !e.isChan(s.X) {
+		return []lang.Stmt{&lang.RepetitiveCmd{Alt: &lang.AlternativeCmd{
+			Cmds: []*lang.GuardedCmd{guarded(nil, e.block(s.Body.List))},
+		}}}
+	}
+	var key ast.Expr = ast.NewIdent("_")
+	if s.Key != nil {
+		key = s.Key
+	}
+	guard := e.inputGuard(key, s.X, s.Tok == token.DEFINE)
+	return []lang.Stmt{&lang.RepetitiveCmd{Alt: &lang.AlternativeCmd{
+		Cmds: []*lang.GuardedCmd{{Guard: guard, Body: list(e.block(s.Body.List))}},
+	}}}
+}
+
+// inputGuard returns the guard receiving from ch to x, preceded by the
+// declaration of x if define is set.
+func (e *extractor) inputGuard(x, ch ast.Expr, define bool) *lang.Guard {
+	g := &lang.Guard{}
+	for _, s := range e.input(x, ch, define) {
+		switch s := s.(type) {
+		case *lang.Declaration:
+			g.List = append(g.List, s)
+		case *lang.InputCmd:
+			g.Input = s
+		}
+	}
+	return g
+}
+
+// selectStmt returns the alternative command of the cases of s, a send
+// or default case being guarded by true, or nil if s has no cases.
+func (e *extractor) selectStmt(s *ast.SelectStmt) *lang.AlternativeCmd {
+	alt := &lang.AlternativeCmd{}
+	for _, stmt := range s.Body.List {
+		cc := stmt.(*ast.CommClause)
+		body := e.block(cc.Body)
+		switch comm := cc.Comm.(type) {
+		case nil:
+			alt.Cmds = append(alt.Cmds, guarded(nil, body))
+		case *ast.SendStmt:
+			body = append([]lang.Stmt{e.output(comm)}, body...)
+			alt.Cmds = append(alt.Cmds, guarded(nil, body))
+		case *ast.ExprStmt:
+			u := comm.X.(*ast.UnaryExpr)
+			g := e.inputGuard(ast.NewIdent("_"), u.X, false)
+			alt.Cmds = append(alt.Cmds, &lang.GuardedCmd{Guard: g, Body: list(body)})
+		case *ast.AssignStmt:
+			u := comm.Rhs[0].(*ast.UnaryExpr)
+			g := e.inputGuard(comm.Lhs[0], u.X, comm.Tok == token.DEFINE)
+			alt.Cmds = append(alt.Cmds, &lang.GuardedCmd{Guard: g, Body: list(body)})
+		}
+	}
+	if len(alt.Cmds) == 0 {
+		return nil
+	}
+	return alt
+}
+
+// switchStmt returns the alternative command of s, whose default case
+// is guarded by the negation of all other cases.
+func (e *extractor) switchStmt(s *ast.SwitchStmt) []lang.Stmt {
+	out := []lang.Stmt{}
+	if s.Init != nil {
+		out = append(out, e.stmt(s.Init)...)
+	}
+	alt := &lang.AlternativeCmd{}
+	var def *ast.CaseClause
+	conds := []lang.Expr{}
+	known := true
+	for _, stmt := range s.Body.List {
+		cc := stmt.(*ast.CaseClause)
+		if cc.List == nil {
+			def = cc
+			continue
+		}
+		var c lang.Expr
+		for _, x := range cc.List {
+			if s.Tag != nil {
+				x = &ast.BinaryExpr{X: s.Tag, Op: token.EQL, Y: x}
+			}
+			xc := e.cond(x)
+			if xc == nil {
+				c = nil
+				break
+			}
+			if c == nil {
+				c = xc
+			} else {
+				c = &lang.BinaryExpr{X: c, Op: lang.OR, Y: xc}
+			}
+		}
+		if c == nil {
+			known = false
+		} else {
+			conds = append(conds, c)
+		}
+		alt.Cmds = append(alt.Cmds, guarded(c, e.block(cc.Body)))
+	}
+	// a switch without default does nothing if no case holds.
+	var c lang.Expr
+	if known {
+		for _, x := range conds {
+			if c == nil {
+				c = not(x)
+			} else {
+				c = &lang.BinaryExpr{X: c, Op: lang.AND, Y: not(x)}
+			}
+		}
+	}
+	var body []lang.Stmt
+	if def != nil {
+		body = e.block(def.Body)
+	}
+	alt.Cmds = append(alt.Cmds, guarded(c, body))
+	return append(out, alt)
+}
+
+// typeOf returns the name of the type of x in the notation, or "" if it
+// is not evident.
+func (e *extractor) typeOf(x ast.Expr) string {
+	switch x := x.(type) {
+	case *ast.BasicLit:
+		switch x.Kind {
+		case token.INT:
+			return "integer"
+		case token.CHAR:
+			return "character"
+		}
+	case *ast.Ident:
+		if x.Name == "true" || x.Name == "false" {
+			return "boolean"
+		}
+		return e.types[x.Name]
+	case *ast.ParenExpr:
+		return e.typeOf(x.X)
+	case *ast.UnaryExpr:
+		if x.Op == token.NOT {
+			return "boolean"
+		}
+		if x.Op == token.ARROW {
+			return e.elem(x.X)
+		}
+		return e.typeOf(x.X)
+	case *ast.BinaryExpr:
+		switch x.Op {
+		case token.EQL, token.NEQ, token.LSS, token.LEQ, token.GTR, token.GEQ, token.LAND, token.LOR:
+			return "boolean"
+		}
+		if t := e.typeOf(x.X); t != "" {
+			return t
+		}
+		return e.typeOf(x.Y)
+	case *ast.CallExpr:
+		if id, ok := x.Fun.(*ast.Ident); ok && len(x.Args) == 1 {
+			if id.Name == "len" || id.Name == "cap" {
+				return "integer"
+			}
+			// a conversion such as rune(x).
+			return basic[id.Name]
+		}
+	}
+	return ""
+}
+
+// operators of the notation by the operators of Go.
+var operators = map[token.Token]lang.Token{
+	token.ADD: lang.ADD, token.SUB: lang.SUB, token.MUL: lang.MUL, token.QUO: lang.DIV, token.REM: lang.MOD,
+	token.EQL: lang.EQ, token.NEQ: lang.NEQ,
+	token.LSS: lang.LT, token.LEQ: lang.LEQ, token.GTR: lang.GT, token.GEQ: lang.GEQ,
+	token.LAND: lang.AND, token.LOR: lang.OR,
+}
+
+// expr returns the expression of x, which is the empty structured
+// value () if it cannot be expressed.
+func (e *extractor) expr(x ast.Expr) lang.Expr {
+	switch x := x.(type) {
+	case *ast.BasicLit:
+		switch x.Kind {
+		case token.INT:
+			if v, err := strconv.ParseInt(x.Value, 0, 0); err == nil {
+				return &lang.IntLit{Value: int(v)}
+			}
+		case token.CHAR:
+			if s, err := strconv.Unquote(x.Value); err == nil {
+				return &lang.CharLit{Value: []rune(s)[0]}
+			}
+		case token.STRING:
+			if s, err := strconv.Unquote(x.Value); err == nil {
+				return &lang.StringLit{Value: s}
+			}
+		}
+	case *ast.Ident:
+		if x.Name != "nil" && x.Name != "_" {
+			return &lang.Ident{Name: name(x.Name)}
+		}
+	case *ast.ParenExpr:
+		return &lang.ParenExpr{X: e.expr(x.X)}
+	case *ast.UnaryExpr:
+		switch x.Op {
+		case token.NOT:
+			return &lang.UnaryExpr{Op: lang.NOT, X: e.expr(x.X)}
+		case token.SUB:
+			return &lang.UnaryExpr{Op: lang.SUB, X: e.expr(x.X)}
+		case token.ADD:
+			return e.expr(x.X)
+		}
+	case *ast.BinaryExpr:
+		if op, ok := operators[x.Op]; ok {
+			return &lang.BinaryExpr{X: e.expr(x.X), Op: op, Y: e.expr(x.Y)}
+		}
+	case *ast.SelectorExpr:
+		return &lang.Ident{Name: name(x.Sel.Name)}
+	case *ast.StarExpr:
+		return e.expr(x.X)
+	case *ast.IndexExpr:
+		if id, ok := x.X.(*ast.Ident); ok {
+			return &lang.StructuredExpr{Constructor: name(id.Name), Args: []lang.Expr{e.expr(x.Index)}}
+		}
+	case *ast.CallExpr:
+		var fun string
+		switch f := x.Fun.(type) {
+		case *ast.Ident:
+			fun = f.Name
+		case *ast.SelectorExpr:
+			fun = f.Sel.Name
+		}
+		if fun == "" {
+			break
+		}
+		// a conversion such as rune(c) is its operand.
+		if basic[fun] != "" && len(x.Args) == 1 {
+			return e.expr(x.Args[0])
+		}
+		s := &lang.StructuredExpr{Constructor: name(fun)}
+		for _, arg := range x.Args {
+			s.Args = append(s.Args, e.expr(arg))
+		}
+		return s
+	case *ast.CompositeLit:
+		s := &lang.StructuredExpr{}
+		switch t := x.Type.(type) {
+		case *ast.Ident:
+			s.Constructor = name(t.Name)
+		case *ast.SelectorExpr:
+			s.Constructor = name(t.Sel.Name)
+		}
+		for _, elt := range x.Elts {
+			if kv, ok := elt.(*ast.KeyValueExpr); ok {
+				elt = kv.Value
+			}
+			s.Args = append(s.Args, e.expr(elt))
+		}
+		if s.Constructor != "" || len(s.Args) > 1 {
+			return s
+		}
+	}
+	return &lang.StructuredExpr{}
+}
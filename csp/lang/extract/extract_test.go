@@ -0,0 +1,188 @@
+package extract_test
+
+import (
+	"context"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"testing"
+
+	"github.com/changkun/gobase/csp/lang"
+	"github.com/changkun/gobase/csp/lang/extract"
+)
+
+const src = `package p
+
+func Copy(west <-chan rune, east chan<- rune) {
+	for c := range west {
+		east <- c
+	}
+	close(east)
+}
+
+func Squash(west <-chan rune, east chan<- rune) {
+	defer close(east)
+	for {
+		select {
+		case c := <-west:
+			if c != '*' {
+				east <- c
+			} else if c2 := <-west; c2 == '*' {
+				east <- '↑'
+			} else {
+				east <- '*'
+				east <- c2
+			}
+		case <-done:
+			return
+		}
+	}
+}
+
+func Count(out chan<- int, n int) {
+	i := 0
+	for i < n {
+		out <- i * i
+		i++
+	}
+	switch {
+	case i > 10:
+		out <- 1
+	case len(log) == 0:
+		out <- 2
+	}
+}
+
+func Start(in <-chan rune, out chan<- rune) {
+	mid := make(chan rune)
+	go Copy(in, mid)
+	go func() {
+		Copy(mid, out)
+	}()
+	var skip bool = true
+	mid <- 'x'
+	var v struct{}
+	v, ok := <-in
+	out <- f(v, ok)
+}
+
+func helper(x int) int { return x + 1 }
+`
+
+func parse(t *testing.T) *ast.File {
+	f, err := parser.ParseFile(token.NewFileSet(), "p.go", src, 0)
+	if err != nil {
+		t.Fatalf("%v: %v", t.Name(), err)
+	}
+	return f
+}
+
+func TestFunc(t *testing.T) {
+	f := parse(t)
+	tests := map[string]string{
+		"Copy": "Copy::*[c:character; west?c → east!c]\n",
+		"Squash": `Squash::*[c:character; west?c →
+    [c ≠ '*' → east!c
+    □ c = '*' →
+        c2:character;
+        west?c2;
+        [c2 = '*' → east!'↑' □ c2 ≠ '*' → east!'*'; east!c2]
+    ]
+□ done?() → skip
+]
+`,
+		"Count": `Count::
+    i:integer;
+    i := 0;
+    *[i < n → out!i * i; i := i + 1];
+    [i > 10 → out!1
+    □ len(log) = 0 → out!2
+    □ i ≤ 10 ∧ len(log) ≠ 0 → skip
+    ]
+`,
+	}
+	for _, decl := range f.Decls {
+		fn := decl.(*ast.FuncDecl)
+		want, ok := tests[fn.Name.Name]
+		if !ok {
+			continue
+		}
+		if got := lang.Source(extract.Func(fn)); got != want {
+			t.Fatalf("%v: %v: expected:\n%v\ngot:\n%v", t.Name(), fn.Name.Name, want, got)
+		}
+	}
+}
+
+func TestFile(t *testing.T) {
+	want := `Copy = (*[c:character; west?c → east!c])
+Squash = (
+    *[c:character; west?c →
+        [c ≠ '*' → east!c
+        □ c = '*' →
+            c2:character;
+            west?c2;
+            [c2 = '*' → east!'↑' □ c2 ≠ '*' → east!'*'; east!c2]
+        ]
+    □ done?() → skip
+    ]
+)
+Count = (
+    i:integer;
+    i := 0;
+    *[i < n → out!i * i; i := i + 1];
+    [i > 10 → out!1
+    □ len(log) = 0 → out!2
+    □ i ≤ 10 ∧ len(log) ≠ 0 → skip
+    ]
+)
+Start = (
+    [g1::Copy
+    || g2::Copy
+    || Start::
+        skip_:boolean;
+        skip_ := true;
+        mid!'x';
+        v:character;
+        in?v;
+        out!f(v, ok)
+    ]
+)
+`
+	prog := extract.File(parse(t))
+	got := lang.Source(prog)
+	if got != want {
+		t.Fatalf("%v: expected:\n%v\ngot:\n%v", t.Name(), want, got)
+	}
+	if _, err := lang.Parse(got); err != nil {
+		t.Fatalf("%v: cannot parse description: %v", t.Name(), err)
+	}
+}
+
+// TestFuncRun runs the description of a function by the interpreter.
+func TestFuncRun(t *testing.T) {
+	fn := parse(t).Decls[0].(*ast.FuncDecl)
+	west, east := make(chan lang.Value), make(chan lang.Value)
+	in := &lang.Interpreter{
+		Program: extract.Func(fn),
+		Inputs:  map[string]<-chan lang.Value{"west": west},
+		Outputs: map[string]chan<- lang.Value{"east": east},
+	}
+	errc := make(chan error, 1)
+	go func() { errc <- in.Run(context.Background()) }()
+	go func() {
+		for _, c := range "CSP" {
+			west <- c
+		}
+		close(west)
+	}()
+	got := []rune{}
+	for v := range east {
+		got = append(got, v.(rune))
+	}
+	if err := <-errc; err != nil {
+		t.Fatalf("%v: unexpected error: %v", t.Name(), err)
+	}
+	if string(got) != "CSP" {
+		t.Fatalf("%v: expected: %v, got: %v", t.Name(), "CSP", string(got))
+	}
+}
@@ -0,0 +1,1336 @@
+package lang
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/changkun/gobase/csp"
+	"github.com/changkun/gobase/csp/alt"
+	"github.com/changkun/gobase/csp/trace"
+)
+
+// Interpreter runs a program. Every process of a parallel command runs
+// on its own goroutine, input and output commands are communications
+// over unbuffered channels, and alternative commands select among their
+// guards by package alt. A process refers to a defined process by its
+// name as if the definition was written in its place.
+//
+// Processes name each other by their labels. A label with ranges, such
+// as fac(i:1..limit), declares an array of processes, one for every
+// value of its bound variables, such as fac(1) to fac(limit), and so
+// does a guarded command with ranges for guarded commands. A name
+// that is not the label of a process, such as cardfile or lineprinter,
+// is external and refers to Inputs or Outputs.
+//
+// As in the paper, an input from a terminated process fails. An input
+// guard naming a terminated source is false, hence a repetitive command
+// terminates once all sources of its input guards have terminated, and
+// the termination of a process propagates through the network.
+//
+// Time passes by wait commands, which delay a process, and timeouts,
+// which end the wait of an alternative command, as in Timed CSP. Both
+// count units of time: of Unit, on the wall clock, or of virtual time
+// under a Schedule.
+type Interpreter struct {
+	Program *Program
+
+	// Inputs are the channels of the external sources of the program.
+	// A closed channel behaves as a terminated process.
+	Inputs map[string]<-chan Value
+	// Outputs are the channels of the external destinations of the
+	// program, they are closed once the program terminates.
+	Outputs map[string]chan<- Value
+
+	// Trace, if not nil, is called with every communication of the
+	// program, one call at a time and in an order consistent with
+	// the order of the communications of every process. Under the
+	// context of a recorder of package trace, the communications are
+	// recorded as a send and a receive on the channel Src→Dst, and
+	// the guards selected by alternative and repetitive commands as
+	// described in Stop.Guards, other than those of probabilistic
+	// choices.
+	Trace func(Comm)
+
+	// Debug, if not nil, is called before every input, output,
+	// alternative and repetitive command of a process, one call at a
+	// time, and the process waits for it to return. For an
+	// alternative or repetitive command, Debug returns the index of
+	// the guard among Stop.Guards the process selects, waiting for it
+	// if need be, or -1 to select any ready guard. The result is
+	// ignored otherwise.
+	Debug func(Stop) int
+
+	// Schedule, if not nil, runs the program deterministically: its
+	// processes run one at a time, each until it communicates or
+	// terminates, and Schedule resolves every nondeterministic choice.
+	// Whenever the program takes a step, it is called with the steps
+	// the program may take, in an order given by the program alone,
+	// and returns the index of the step to take, or -1 to fail the
+	// run. Random, Script and Replay return such functions, which
+	// choose only among several steps. A communication with an external
+	// name, once taken, waits for its source or destination. The run
+	// fails with a deadlock once no step is possible.
+	Schedule func([]Step) int
+
+	// Unit is the duration of a unit of time, a millisecond if zero.
+	// Under a Schedule, time is virtual instead: it passes only once
+	// no other step is possible, up to the earliest end of a wait
+	// command or timeout, whose end is then a step of its own.
+	Unit time.Duration
+
+	// Rand, if not nil, draws the guards probabilistic choices
+	// select, otherwise a generator seeded at random does. A draw is
+	// not a step of a Schedule: runs with the same Schedule, such as
+	// by Replay, take the same steps only with a Rand of the same
+	// seed.
+	Rand *rand.Rand
+}
+
+// Run runs the program until it terminates, fails or ctx is done.
+func (in *Interpreter) Run(ctx context.Context) error {
+	defer func() {
+		for _, ch := range in.Outputs {
+			close(ch)
+		}
+	}()
+
+	m := &machine{Interpreter: in, defs: map[string]*Definition{}, rec: trace.FromContext(ctx), started: time.Now()}
+	for _, d := range in.Program.Defs {
+		if _, ok := m.defs[d.Name]; ok {
+			return &Error{Pos: d.Pos(), Msg: fmt.Sprintf("process %s redefined", d.Name)}
+		}
+		m.defs[d.Name] = d
+	}
+	p := &process{machine: m, ctx: ctx, vars: newScope(nil)}
+	if in.Schedule != nil {
+		m.sched = &scheduler{schedule: in.Schedule}
+		m.sched.spawn(p)
+	}
+	p.start()
+	defer p.done()
+	return p.run(in.Program.Body)
+}
+
+// Externals returns the external names a program inputs from and
+// outputs to, that is the names of sources and destinations which are
+// not the label of any of its processes.
+func Externals(prog *Program) (inputs, outputs []string) {
+	labels := map[string]bool{}
+	Inspect(prog, func(n Node) bool {
+		if l, ok := n.(*ProcLabel); ok {
+			labels[l.Name] = true
+		}
+		return true
+	})
+	add := func(names []string, n *ProcName) []string {
+		if labels[n.Name] {
+			return names
+		}
+		for _, name := range names {
+			if name == n.Name {
+				return names
+			}
+		}
+		return append(names, n.Name)
+	}
+	Inspect(prog, func(n Node) bool {
+		switch n := n.(type) {
+		case *InputCmd:
+			inputs = add(inputs, n.Source)
+		case *OutputCmd:
+			outputs = add(outputs, n.Dest)
+		}
+		return true
+	})
+	return inputs, outputs
+}
+
+// AlphabetOf returns the alphabet of the process prog defines as name,
+// nil if none: the external names its body inputs from and outputs to,
+// in order, other than those its definition hides. The body of a
+// definition it refers to contributes its names, renamed as by the
+// reference, such that the alphabet of COPY2 in
+//
+//   COPY = *[c:character; west?c → east!c]
+//   COPY2 = COPY[[west <- in, east <- out]]
+//
+// is in and out.
+func AlphabetOf(prog *Program, name string) []string {
+	defs := map[string]*Definition{}
+	for _, d := range prog.Defs {
+		if _, ok := defs[d.Name]; !ok {
+			defs[d.Name] = d
+		}
+	}
+	d := defs[name]
+	if d == nil {
+		return nil
+	}
+	var names []string
+	for _, n := range alphabet(d.Body, defs, map[string]bool{d.Name: true}) {
+		hidden := false
+		for _, id := range d.Hidden {
+			hidden = hidden || id.Name == n
+		}
+		if !hidden {
+			names = append(names, n)
+		}
+	}
+	return names
+}
+
+// alphabet returns the external names body communicates with, in
+// order, following the references to the definitions defs which are
+// not being expanded.
+func alphabet(body *CmdList, defs map[string]*Definition, expanding map[string]bool) []string {
+	labels := map[string]bool{}
+	var names []string
+	add := func(name string) {
+		if !contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	Inspect(body, func(n Node) bool {
+		switch n := n.(type) {
+		case *ProcLabel:
+			labels[n.Name] = true
+		case *InputCmd:
+			add(n.Source.Name)
+		case *OutputCmd:
+			add(n.Dest.Name)
+		case *ProcRef:
+			d := defs[n.Name]
+			if d == nil || expanding[d.Name] {
+				break
+			}
+			expanding[d.Name] = true
+			for _, name := range alphabet(d.Body, defs, expanding) {
+				add(renamed([]*ProcRef{n}, name))
+			}
+			expanding[d.Name] = false
+		}
+		return true
+	})
+	external := names[:0]
+	for _, name := range names {
+		if !labels[name] {
+			external = append(external, name)
+		}
+	}
+	return external
+}
+
+// Eval evaluates a constant expression, such as 'a', "Hello, CSP" or
+// (1+2)*3.
+func Eval(e Expr) (v Value, err error) {
+	p := &process{machine: &machine{Interpreter: &Interpreter{}}, ctx: context.Background(), vars: newScope(nil)}
+	defer func() {
+		if r := recover(); r != nil {
+			f, ok := r.(failure)
+			if !ok {
+				panic(r)
+			}
+			err = f.err
+		}
+	}()
+	return p.eval(e), nil
+}
+
+// machine is the state shared by all processes of a run.
+type machine struct {
+	*Interpreter
+	defs map[string]*Definition
+	mu   sync.Mutex // serializes calls of Trace
+	rec  *trace.Recorder
+
+	dmu    sync.Mutex // serializes calls of Debug, guards states
+	states []*ProcState
+
+	rmu sync.Mutex // serializes draws from Rand
+
+	sched   *scheduler // for a Schedule
+	started time.Time  // of the run, for the time of Unit
+}
+
+// process is a running sequential process.
+type process struct {
+	*machine
+	ctx   context.Context
+	label string
+	group *group   // processes of the parallel command, nil for the program
+	outer *process // process executing the parallel command
+	vars  *scope
+	refs  []*ProcRef             // the references with renamings executing
+	held  map[<-chan Value]Value // values received but not yet input
+	state *ProcState             // if debugged
+
+	// for a Schedule
+	seq     int
+	wake    chan struct{}
+	pending int                   // processes of a parallel command running
+	closed  map[<-chan Value]bool // external sources found closed
+}
+
+// failure aborts the execution of a process.
+type failure struct {
+	err error
+}
+
+// run executes l, once it runs for a Schedule, and reports why the
+// process failed, if so.
+func (p *process) run(l *CmdList) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			f, ok := v.(failure)
+			if !ok {
+				panic(v)
+			}
+			err = f.err
+		}
+	}()
+	if p.sched != nil {
+		p.sched.wait(p)
+	}
+	p.execList(l)
+	return nil
+}
+
+func (p *process) fail(err error) {
+	panic(failure{err})
+}
+
+func (p *process) errorf(n Node, format string, args ...interface{}) {
+	p.fail(&Error{Pos: n.Pos(), Msg: fmt.Sprintf(format, args...)})
+}
+
+// group is the network of the processes of a parallel command. Two
+// processes communicate over the link of their ordered pair of labels,
+// which is closed once its source terminates.
+type group struct {
+	mu         sync.Mutex
+	done       map[string]chan struct{}
+	links      map[[2]string]*link
+	interleave bool // the processes do not name each other
+}
+
+// link is the channel from one process to another. The destination
+// acknowledges every communication once its target matched the value
+// and it has been traced, such that an output completes only once the
+// value is input, and the trace respects causality.
+type link struct {
+	ch  chan Value
+	ack chan struct{}
+}
+
+func newGroup() *group {
+	return &group{done: map[string]chan struct{}{}, links: map[[2]string]*link{}}
+}
+
+// link returns the link from process src to process dst.
+func (g *group) link(src, dst string) *link {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	key := [2]string{src, dst}
+	l, ok := g.links[key]
+	if !ok {
+		l = &link{ch: make(chan Value), ack: make(chan struct{})}
+		g.links[key] = l
+		select {
+		case <-g.done[src]:
+			close(l.ch)
+		default:
+		}
+	}
+	return l
+}
+
+// terminate records the termination of a process.
+func (g *group) terminate(label string) {
+	if label == "" {
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	close(g.done[label])
+	for key, l := range g.links {
+		if key[0] == label {
+			close(l.ch)
+		}
+	}
+}
+
+// Comm is a communication of a program, from the process or external
+// source Src to the process or external destination Dst. The Time of a
+// traced communication is the number of units of time passed since the
+// run started, such that the traced communications are the timed trace
+// of the run.
+type Comm struct {
+	Src, Dst string
+	Value    Value
+	Time     int
+}
+
+func (c Comm) String() string {
+	return fmt.Sprintf("%s→%s: %s", c.Src, c.Dst, Format(c.Value))
+}
+
+// port is the end of a communication of a process.
+type port struct {
+	comm Comm
+	in   <-chan Value
+	out  chan<- Value
+	link *link           // nil for external names
+	ack  chan struct{}   // nil for external names
+	done <-chan struct{} // closed once the peer terminated
+}
+
+// peer finds the process named name among the processes of the
+// enclosing parallel commands, it returns the process of the innermost
+// one communicating on behalf of p, and name as renamed by the process
+// references executing up to it, or nil and the name as renamed by all
+// of them for an external name.
+func (p *process) peer(n *ProcName, name string) (*process, string) {
+	for q := p; q != nil; q = q.outer {
+		name = renamed(q.refs, name)
+		if q.group == nil || q.group.interleave {
+			continue
+		}
+		if _, ok := q.group.done[name]; ok {
+			if name == q.label {
+				p.errorf(n, "process %s names itself", name)
+			}
+			return q, name
+		}
+	}
+	return nil, name
+}
+
+// self returns the label of the innermost labelled process executing
+// p.
+func (p *process) self() string {
+	for q := p; q != nil; q = q.outer {
+		if q.label != "" {
+			return q.label
+		}
+	}
+	return ""
+}
+
+// input returns the port to input from the source n.
+func (p *process) input(n *ProcName) port {
+	q, name := p.peer(n, p.name(n.Name, n.Subscripts))
+	if q != nil {
+		l := q.group.link(name, q.label)
+		return port{comm: Comm{Src: name, Dst: q.label}, in: l.ch, link: l, ack: l.ack, done: q.group.done[name]}
+	}
+	if ch, ok := p.Inputs[name]; ok {
+		return port{comm: Comm{Src: name, Dst: p.self()}, in: ch}
+	}
+	p.errorf(n, "undefined source %s", name)
+	return port{}
+}
+
+// output returns the port to output to the destination n.
+func (p *process) output(n *ProcName) port {
+	q, name := p.peer(n, p.name(n.Name, n.Subscripts))
+	if q != nil {
+		l := q.group.link(q.label, name)
+		return port{comm: Comm{Src: q.label, Dst: name}, out: l.ch, link: l, ack: l.ack, done: q.group.done[name]}
+	}
+	if ch, ok := p.Outputs[name]; ok {
+		return port{comm: Comm{Src: p.self(), Dst: name}, out: ch}
+	}
+	p.errorf(n, "undefined destination %s", name)
+	return port{}
+}
+
+// renamed returns the name of a process, such as X(1), as renamed by
+// the renamings of refs, the innermost last.
+func renamed(refs []*ProcRef, name string) string {
+	if len(refs) == 0 {
+		return name
+	}
+	base, subs, ok := strings.Cut(name, "(")
+	for i := len(refs) - 1; i >= 0; i-- {
+		for _, r := range refs[i].Renames {
+			if r.From.Name == base {
+				base = r.To.Name
+				break
+			}
+		}
+	}
+	if ok {
+		return base + "(" + subs
+	}
+	return base
+}
+
+// name returns the name of a process with subscripts, such as X(1).
+func (p *process) name(name string, subscripts []Expr) string {
+	if len(subscripts) == 0 {
+		return name
+	}
+	subs := make([]string, len(subscripts))
+	for i, e := range subscripts {
+		if r, ok := e.(*Range); ok {
+			p.errorf(r, "arrays of processes are not supported")
+		}
+		subs[i] = Format(p.eval(e))
+	}
+	return name + "(" + strings.Join(subs, ",") + ")"
+}
+
+func (p *process) recv(n *ProcName) Value {
+	if p.sched != nil {
+		return p.scheduledRecv(n)
+	}
+	pt := p.input(n)
+	if v, ok := p.held[pt.in]; ok {
+		delete(p.held, pt.in)
+		p.received(pt, v)
+		return v
+	}
+	select {
+	case v, ok := <-pt.in:
+		if !ok {
+			p.errorf(n, "input from terminated process %s", pt.comm.Src)
+		}
+		p.received(pt, v)
+		return v
+	case <-p.ctx.Done():
+		p.fail(p.ctx.Err())
+	}
+	return nil
+}
+
+// received traces the input of v and acknowledges it to its source.
+func (p *process) received(pt port, v Value) {
+	if p.traced() {
+		pt.comm.Value = v
+		p.trace(pt.comm)
+	}
+	if pt.ack == nil {
+		return
+	}
+	select {
+	case pt.ack <- struct{}{}:
+	case <-p.ctx.Done():
+		p.fail(p.ctx.Err())
+	}
+}
+
+func (p *process) send(n *ProcName, v Value) {
+	if p.sched != nil {
+		p.scheduledSend(n, v)
+		return
+	}
+	pt := p.output(n)
+	select {
+	case pt.out <- copyValue(v):
+	case <-pt.done:
+		p.errorf(n, "output to terminated process %s", pt.comm.Dst)
+	case <-p.ctx.Done():
+		p.fail(p.ctx.Err())
+	}
+	if pt.ack == nil {
+		if p.traced() {
+			pt.comm.Value = v
+			p.trace(pt.comm)
+		}
+		return
+	}
+	select {
+	case <-pt.ack:
+	case <-pt.done:
+		p.errorf(n, "output to terminated process %s", pt.comm.Dst)
+	case <-p.ctx.Done():
+		p.fail(p.ctx.Err())
+	}
+}
+
+// traced reports whether the communications of p are traced.
+func (p *process) traced() bool {
+	return p.Trace != nil || p.rec != nil
+}
+
+// trace calls Trace with c, one call at a time, and records it.
+func (p *process) trace(c Comm) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	c.Time = p.now()
+	if p.rec != nil {
+		ch := c.Src + "→" + c.Dst
+		p.rec.Record(trace.Event{Kind: trace.Send, Process: c.Src, Chan: ch, Value: c.Value})
+		p.rec.Record(trace.Event{Kind: trace.Receive, Process: c.Dst, Chan: ch, Value: c.Value})
+	}
+	if p.Trace != nil {
+		p.Trace(c)
+	}
+}
+
+// selected records the selection of guard by p.
+func (p *process) selected(guard string) {
+	p.rec.Record(trace.Event{Kind: trace.Select, Process: p.self(), Guard: guard})
+}
+
+func (p *process) execList(l *CmdList) {
+	for _, s := range l.Stmts {
+		p.exec(s)
+	}
+}
+
+// within calls f with s as the scope of p.
+func (p *process) within(s *scope, f func()) {
+	vars := p.vars
+	p.vars = s
+	defer func() { p.vars = vars }()
+	f()
+}
+
+func (p *process) exec(s Stmt) {
+	if err := p.ctx.Err(); err != nil {
+		p.fail(err)
+	}
+	switch s := s.(type) {
+	case *Declaration:
+		p.declare(s)
+	case *SkipCmd:
+	case *WaitCmd:
+		p.wait(s)
+	case *AssignmentCmd:
+		p.assign(s.Target, p.eval(s.Value))
+	case *InputCmd:
+		if p.Debug != nil {
+			p.stop(s, nil)
+		}
+		p.assign(s.Target, p.recv(s.Source))
+	case *OutputCmd:
+		if p.Debug != nil {
+			p.stop(s, nil)
+		}
+		p.send(s.Dest, p.eval(s.Value))
+	case *ParallelCmd:
+		p.parallel(s)
+	case *AlternativeCmd:
+		if !p.alternative(s, s) {
+			p.errorf(s, "all guards fail")
+		}
+	case *RepetitiveCmd:
+		for p.alternative(s.Alt, s) {
+		}
+	case *ProcRef:
+		d, ok := p.defs[s.Name]
+		if !ok {
+			p.errorf(s, "undefined process %s", s.Name)
+		}
+		if len(s.Renames) == 0 {
+			p.execList(d.Body)
+			break
+		}
+		p.refs = append(p.refs, s)
+		p.execList(d.Body)
+		p.refs = p.refs[:len(p.refs)-1]
+	default:
+		p.errorf(s, "unexpected %T", s)
+	}
+}
+
+// wait executes a wait command.
+func (p *process) wait(s *WaitCmd) {
+	d := p.duration(s.Duration)
+	if d == 0 {
+		return
+	}
+	if p.sched != nil {
+		p.scheduledWait(s, d)
+		return
+	}
+	t := time.NewTimer(time.Duration(d) * p.unit())
+	defer t.Stop()
+	select {
+	case <-t.C:
+	case <-p.ctx.Done():
+		p.fail(p.ctx.Err())
+	}
+}
+
+// duration evaluates the duration of a wait command or timeout.
+func (p *process) duration(e Expr) int {
+	d := p.int(e)
+	if d < 0 {
+		p.errorf(e, "negative duration %d", d)
+	}
+	return d
+}
+
+// unit returns the duration of a unit of time.
+func (p *process) unit() time.Duration {
+	if p.Unit > 0 {
+		return p.Unit
+	}
+	return time.Millisecond
+}
+
+// now returns the number of units of time passed since the run
+// started.
+func (p *process) now() int {
+	if p.sched != nil {
+		return int(p.sched.now.Load())
+	}
+	return int(time.Since(p.started) / p.unit())
+}
+
+// parallel executes the processes of cmd concurrently and waits for all
+// of them to terminate.
+func (p *process) parallel(cmd *ParallelCmd) {
+	g := newGroup()
+	g.interleave = cmd.Interleave
+	var insts []instance
+	for _, proc := range cmd.Procs {
+		insts = append(insts, p.instances(proc)...)
+	}
+	for _, inst := range insts {
+		if inst.label == "" {
+			continue
+		}
+		if _, ok := g.done[inst.label]; ok {
+			p.errorf(inst.proc.Label, "duplicate process label %s", inst.label)
+		}
+		g.done[inst.label] = make(chan struct{})
+	}
+
+	procs := make([]csp.Process, len(insts))
+	for i, inst := range insts {
+		q := &process{machine: p.machine, label: inst.label, group: g, outer: p, vars: inst.vars}
+		q.start()
+		if p.sched != nil {
+			p.sched.spawn(q)
+		}
+		body := inst.proc.Body
+		procs[i] = csp.Named(q.label, csp.ProcessFunc(func(ctx context.Context) error {
+			if q.sched != nil {
+				defer q.sched.exit(q)
+			}
+			defer g.terminate(q.label)
+			defer q.done()
+			q.ctx = ctx
+			return q.run(body)
+		}))
+	}
+	if p.sched != nil && len(procs) > 0 {
+		p.sched.release(p, len(procs))
+	}
+	err := csp.Par(procs...).Run(p.ctx)
+	if p.sched != nil && len(procs) > 0 {
+		p.sched.wait(p)
+	}
+	if err != nil {
+		p.fail(err)
+	}
+}
+
+// instance is a process of a parallel command, one of an array of
+// processes for a label with ranges.
+type instance struct {
+	proc  *Proc
+	label string
+	vars  *scope // with the bound variables of the ranges
+}
+
+// instances returns the processes of proc. As in the paper, a label
+// with ranges, such as fac(i:1..limit), stands for the processes of
+// all combinations of the values of its ranges, whose bounds are
+// evaluated at start-up: fac(1), ..., fac(limit). The bound variables
+// of an instance are constants of its values.
+func (p *process) instances(proc *Proc) []instance {
+	insts := []instance{{proc: proc, vars: newScope(p.vars)}}
+	if proc.Label == nil {
+		return insts
+	}
+	insts[0].label = proc.Label.Name
+	for i, e := range proc.Label.Subscripts {
+		sep := ","
+		if i == 0 {
+			sep = "("
+		}
+		r, ok := e.(*Range)
+		if !ok {
+			v := Format(p.eval(e))
+			for j := range insts {
+				insts[j].label += sep + v
+			}
+			continue
+		}
+		lo, hi := p.int(r.Lo), p.int(r.Hi)
+		var next []instance
+		for _, inst := range insts {
+			for v := lo; v <= hi; v++ {
+				vars := inst.vars.bind(r, v)
+				next = append(next, instance{proc: proc, label: inst.label + sep + Format(v), vars: vars})
+			}
+		}
+		insts = next
+	}
+	if len(proc.Label.Subscripts) > 0 {
+		for j := range insts {
+			insts[j].label += ")"
+		}
+	}
+	return insts
+}
+
+// alternative executes an alternative command, it returns false if all
+// guards fail.
+//
+// An input guard is selected only if its target matches the value its
+// source outputs: a value which does not match the target of the
+// selected guard is held back, unacknowledged, and the guards are
+// selected again, such that the value is input by a guard whose target
+// matches it, possibly a later one once its boolean guard is true.
+//
+// The command stmt, cmd or the repetitive command of cmd, is the stop
+// of Debug.
+func (p *process) alternative(cmd *AlternativeCmd, stmt Stmt) bool {
+	for _, gc := range cmd.Cmds {
+		if gc.Guard.Prob != nil {
+			return p.probabilistic(cmd, stmt)
+		}
+	}
+	if p.sched != nil {
+		return p.scheduledAlternative(cmd, stmt)
+	}
+	for {
+		var guards []*alt.Guard
+		var descs []string
+		held := false
+		for _, gc := range cmd.Cmds {
+			for _, s := range p.replicas(gc) {
+				if g := p.guarded(s, gc, &held); g != nil {
+					guards = append(guards, g)
+					descs = append(descs, describe(s, gc))
+				}
+			}
+		}
+		if p.Debug != nil && len(guards) > 0 {
+			if i := p.stop(stmt, descs); i >= 0 && i < len(guards) {
+				guards = guards[i : i+1]
+			}
+		}
+		a := alt.New(guards...)
+		if _, ok := a.SelectContext(p.ctx); !ok {
+			if err := p.ctx.Err(); err != nil {
+				p.fail(err)
+			}
+			return false
+		}
+		if !held {
+			return true
+		}
+	}
+}
+
+// probabilistic executes an alternative command whose guards have
+// weights, a probabilistic choice, as alternative does. Guards of
+// weight zero fail.
+func (p *process) probabilistic(cmd *AlternativeCmd, stmt Stmt) bool {
+	var gcs []*GuardedCmd
+	var scopes []*scope
+	var weights []int
+	var descs []string
+	total := 0
+	for _, gc := range cmd.Cmds {
+		if gc.Guard.Prob == nil {
+			p.errorf(gc, "guard without weight in a probabilistic choice")
+		}
+		for _, s := range p.replicas(gc) {
+			if !p.guard(s, gc.Guard) {
+				continue
+			}
+			var w int
+			p.within(s, func() { w = p.int(gc.Guard.Prob.Weight) })
+			if w < 0 {
+				p.errorf(gc.Guard.Prob.Weight, "negative weight %d", w)
+			}
+			if w == 0 {
+				continue
+			}
+			gcs, scopes, weights = append(gcs, gc), append(scopes, s), append(weights, w)
+			descs = append(descs, describe(s, gc))
+			total += w
+		}
+	}
+	if len(gcs) == 0 {
+		return false
+	}
+	i := -1
+	if p.Debug != nil {
+		i = p.stop(stmt, descs)
+	}
+	if i < 0 || i >= len(gcs) {
+		i = p.draw(weights, total)
+	}
+	p.within(scopes[i], func() { p.execList(gcs[i].Body) })
+	return true
+}
+
+// draw returns the index of one of weights at random, as likely as its
+// weight, of their total.
+func (p *process) draw(weights []int, total int) int {
+	p.rmu.Lock()
+	defer p.rmu.Unlock()
+	var n int
+	if p.Rand != nil {
+		n = p.Rand.Intn(total)
+	} else {
+		n = rand.Intn(total)
+	}
+	for i, w := range weights {
+		if n < w {
+			return i
+		}
+		n -= w
+	}
+	return len(weights) - 1
+}
+
+// replicas returns the scopes of the guarded commands gc stands for:
+// as in the paper, a guarded command with ranges, such as
+// (i:1..100) X(i)?V() → ..., stands for one guarded command for every
+// combination of the values of its bound variables.
+func (p *process) replicas(gc *GuardedCmd) []*scope {
+	scopes := []*scope{newScope(p.vars)}
+	for _, r := range gc.Ranges {
+		lo, hi := p.int(r.Lo), p.int(r.Hi)
+		var next []*scope
+		for _, s := range scopes {
+			for v := lo; v <= hi; v++ {
+				next = append(next, s.bind(r, v))
+			}
+		}
+		scopes = next
+	}
+	return scopes
+}
+
+// guarded returns the guard of the guarded command gc in s, or nil if
+// its boolean guard is false. If its input receives a value its target
+// does not match, it holds the value and sets held.
+func (p *process) guarded(s *scope, gc *GuardedCmd, held *bool) *alt.Guard {
+	if !p.guard(s, gc.Guard) {
+		return nil
+	}
+	body := gc.Body
+	input := gc.Guard.Input
+	selected := func() {
+		if p.rec != nil {
+			p.selected(describe(s, gc))
+		}
+	}
+	if t := gc.Guard.Timeout; t != nil {
+		var d int
+		p.within(s, func() { d = p.duration(t.Duration) })
+		return alt.After(time.Duration(d)*p.unit(), func() {
+			selected()
+			p.within(s, func() { p.execList(body) })
+		})
+	}
+	if input == nil {
+		return alt.Ready(func() {
+			selected()
+			p.within(s, func() { p.execList(body) })
+		})
+	}
+	var pt port
+	p.within(s, func() { pt = p.input(input.Source) })
+	exec := func(v Value) {
+		p.received(pt, v)
+		selected()
+		p.within(s, func() {
+			p.assign(input.Target, v)
+			p.execList(body)
+		})
+	}
+	matches := func(v Value) (ok bool) {
+		p.within(s, func() { ok = p.matches(input.Target, v) })
+		return ok
+	}
+	if v, ok := p.held[pt.in]; ok {
+		if !matches(v) {
+			// the guard waits, as its source does not terminate
+			// before the value is input.
+			return alt.Recv((<-chan Value)(nil), nil)
+		}
+		return alt.Ready(func() {
+			delete(p.held, pt.in)
+			exec(v)
+		})
+	}
+	return alt.Recv(pt.in, func(v Value) {
+		if !matches(v) {
+			if p.held == nil {
+				p.held = map[<-chan Value]Value{}
+			}
+			p.held[pt.in], *held = v, true
+			return
+		}
+		exec(v)
+	})
+}
+
+// guard evaluates the declarations and boolean expressions of g in s,
+// it reports whether they are all true.
+func (p *process) guard(s *scope, g *Guard) bool {
+	ok := true
+	p.within(s, func() {
+		for _, n := range g.List {
+			switch n := n.(type) {
+			case *Declaration:
+				p.declare(n)
+			case Expr:
+				if !p.bool(n) {
+					ok = false
+					return
+				}
+			}
+		}
+	})
+	return ok
+}
+
+// scope is a scope of variables, each command list executed by a
+// process opens one.
+type scope struct {
+	outer *scope
+	vars  map[string]*variable
+}
+
+func newScope(outer *scope) *scope {
+	return &scope{outer: outer, vars: map[string]*variable{}}
+}
+
+// bind returns a copy of s in which the bound variable of r is the
+// constant v.
+func (s *scope) bind(r *Range, v int) *scope {
+	b := newScope(s.outer)
+	for name, x := range s.vars {
+		b.vars[name] = x
+	}
+	b.vars[r.Var] = &variable{typ: &NamedType{NamePos: r.VarPos, Name: "integer"}, val: v, bound: true}
+	return b
+}
+
+func (s *scope) lookup(name string) *variable {
+	for ; s != nil; s = s.outer {
+		if v, ok := s.vars[name]; ok {
+			return v
+		}
+	}
+	return nil
+}
+
+// variable is a declared variable, lo is the lower bound of an array.
+// A bound variable of a range is a constant.
+type variable struct {
+	typ   Type
+	lo    int
+	val   Value
+	bound bool
+}
+
+func (p *process) declare(d *Declaration) {
+	for _, id := range d.Names {
+		if _, ok := p.vars.vars[id.Name]; ok {
+			p.errorf(id, "%s redeclared", id.Name)
+		}
+		v := &variable{typ: d.Type}
+		switch t := d.Type.(type) {
+		case *NamedType:
+			if _, ok := types[t.Name]; !ok {
+				p.errorf(t, "undefined type %s", t.Name)
+			}
+		case *ArrayType:
+			elem, ok := t.Elem.(*NamedType)
+			if !ok {
+				p.errorf(t.Elem, "arrays of arrays are not supported")
+			}
+			if _, ok := types[elem.Name]; !ok {
+				p.errorf(elem, "undefined type %s", elem.Name)
+			}
+			lo, hi := p.int(t.Lo), p.int(t.Hi)
+			if hi < lo-1 {
+				p.errorf(t, "invalid bounds %d..%d", lo, hi)
+			}
+			v.lo, v.val = lo, make(Array, hi-lo+1)
+		}
+		p.vars.vars[id.Name] = v
+	}
+}
+
+// types are the types of the paper.
+var types = map[string]reflect.Type{
+	"integer":   reflect.TypeOf(0),
+	"character": reflect.TypeOf(' '),
+	"boolean":   reflect.TypeOf(false),
+}
+
+// typeName returns the name of t for error messages.
+func typeName(t Type) string {
+	if a, ok := t.(*ArrayType); ok {
+		return "array of " + typeName(a.Elem)
+	}
+	return t.(*NamedType).Name
+}
+
+// assignable reports whether v is a value of the type t of x, which
+// for arrays requires the same length.
+func assignable(x *variable, t Type, v Value) bool {
+	switch t := t.(type) {
+	case *NamedType:
+		return reflect.TypeOf(v) == types[t.Name]
+	case *ArrayType:
+		a, ok := v.(Array)
+		if !ok || len(a) != len(x.val.(Array)) {
+			return false
+		}
+		for _, e := range a {
+			if e != nil && !assignable(x, t.Elem, e) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+func (p *process) assign(target Expr, v Value) {
+	switch t := target.(type) {
+	case *Ident:
+		x := p.variable(t)
+		if x.bound {
+			p.errorf(t, "cannot assign to bound variable %s", t.Name)
+		}
+		if !assignable(x, x.typ, v) {
+			p.errorf(t, "cannot assign %s to %s of type %s", Format(v), t.Name, typeName(x.typ))
+		}
+		x.val = copyValue(v)
+	case *StructuredExpr:
+		if p.structured(t) {
+			if !p.matches(t, v) {
+				p.errorf(t, "%s does not match %s", Format(v), Source(t))
+			}
+			for i, arg := range t.Args {
+				p.assign(arg, v.(Structured).Components[i])
+			}
+			return
+		}
+		x, i := p.element(t)
+		elem := x.typ.(*ArrayType).Elem
+		if !assignable(x, elem, v) {
+			p.errorf(t, "cannot assign %s to element of %s of type %s", Format(v), t.Constructor, typeName(x.typ))
+		}
+		x.val.(Array)[i] = copyValue(v)
+	case *ParenExpr:
+		p.assign(t.X, v)
+	default:
+		p.errorf(target, "cannot assign to expression")
+	}
+}
+
+func (p *process) variable(id *Ident) *variable {
+	x := p.vars.lookup(id.Name)
+	if x == nil {
+		p.errorf(id, "undefined variable %s", id.Name)
+	}
+	return x
+}
+
+// structured reports whether e is a structured expression, rather than
+// an element of an array: whether its constructor is empty or not the
+// name of a variable.
+func (p *process) structured(e *StructuredExpr) bool {
+	return e.Constructor == "" || p.vars.lookup(e.Constructor) == nil
+}
+
+// matches reports whether the value v matches the target e: as in the
+// paper, a structured target matches only a structured value of the
+// same constructor and number of components, whose components match
+// its own.
+func (p *process) matches(e Expr, v Value) bool {
+	switch e := e.(type) {
+	case *ParenExpr:
+		return p.matches(e.X, v)
+	case *StructuredExpr:
+		if !p.structured(e) {
+			return true
+		}
+		s, ok := v.(Structured)
+		if !ok || s.Constructor != e.Constructor || len(s.Components) != len(e.Args) {
+			return false
+		}
+		for i, arg := range e.Args {
+			if !p.matches(arg, s.Components[i]) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// element returns the array and the index of the subscripted element e.
+func (p *process) element(e *StructuredExpr) (*variable, int) {
+	x := p.vars.lookup(e.Constructor)
+	a, ok := x.val.(Array)
+	if _, isArray := x.typ.(*ArrayType); !isArray || !ok {
+		p.errorf(e, "%s is not an array", e.Constructor)
+	}
+	if len(e.Args) != 1 {
+		p.errorf(e, "array %s takes a single subscript", e.Constructor)
+	}
+	i := p.int(e.Args[0])
+	if i < x.lo || i >= x.lo+len(a) {
+		p.errorf(e.Args[0], "subscript %d out of range %d..%d", i, x.lo, x.lo+len(a)-1)
+	}
+	return x, i - x.lo
+}
+
+func (p *process) int(e Expr) int {
+	x := p.eval(e)
+	v, ok := x.(int)
+	if !ok {
+		p.errorf(e, "expected integer, found %s", Format(x))
+	}
+	return v
+}
+
+func (p *process) bool(e Expr) bool {
+	x := p.eval(e)
+	v, ok := x.(bool)
+	if !ok {
+		p.errorf(e, "expected boolean, found %s", Format(x))
+	}
+	return v
+}
+
+func (p *process) eval(e Expr) Value {
+	switch e := e.(type) {
+	case *IntLit:
+		return e.Value
+	case *CharLit:
+		return e.Value
+	case *StringLit:
+		return Chars(e.Value)
+	case *Ident:
+		if x := p.vars.lookup(e.Name); x != nil {
+			if x.val == nil {
+				p.errorf(e, "%s is undefined", e.Name)
+			}
+			return x.val
+		}
+		if v, ok := constants[e.Name]; ok {
+			return v
+		}
+		p.errorf(e, "undefined variable %s", e.Name)
+	case *ParenExpr:
+		return p.eval(e.X)
+	case *StructuredExpr:
+		if p.structured(e) {
+			v := Structured{Constructor: e.Constructor, Components: make([]Value, len(e.Args))}
+			for i, arg := range e.Args {
+				v.Components[i] = p.eval(arg)
+			}
+			return v
+		}
+		x, i := p.element(e)
+		v := x.val.(Array)[i]
+		if v == nil {
+			p.errorf(e, "%s(%d) is undefined", e.Constructor, i+x.lo)
+		}
+		return v
+	case *UnaryExpr:
+		switch e.Op {
+		case SUB:
+			return -p.int(e.X)
+		case ADD:
+			return p.int(e.X)
+		case NOT:
+			return !p.bool(e.X)
+		}
+	case *BinaryExpr:
+		return p.binary(e)
+	}
+	p.errorf(e, "unexpected %T", e)
+	return nil
+}
+
+func (p *process) binary(e *BinaryExpr) Value {
+	switch e.Op {
+	case AND:
+		return p.bool(e.X) && p.bool(e.Y)
+	case OR:
+		return p.bool(e.X) || p.bool(e.Y)
+	case ADD:
+		return p.int(e.X) + p.int(e.Y)
+	case SUB:
+		return p.int(e.X) - p.int(e.Y)
+	case MUL:
+		return p.int(e.X) * p.int(e.Y)
+	case DIV, MOD:
+		x, y := p.int(e.X), p.int(e.Y)
+		if y == 0 {
+			p.errorf(e.Y, "division by zero")
+		}
+		if e.Op == DIV {
+			return x / y
+		}
+		return x % y
+	}
+
+	x, y := p.eval(e.X), p.eval(e.Y)
+	if reflect.TypeOf(x) != reflect.TypeOf(y) {
+		p.errorf(e, "mismatched types of %s and %s", Format(x), Format(y))
+	}
+	switch e.Op {
+	case EQ:
+		return reflect.DeepEqual(x, y)
+	case NEQ:
+		return !reflect.DeepEqual(x, y)
+	}
+	var c int
+	switch x := x.(type) {
+	case int:
+		c = compare(x, y.(int))
+	case rune:
+		c = compare(x, y.(rune))
+	default:
+		p.errorf(e, "%v is not defined on %s", e.Op, Format(x))
+	}
+	switch e.Op {
+	case LT:
+		return c < 0
+	case LEQ:
+		return c <= 0
+	case GT:
+		return c > 0
+	case GEQ:
+		return c >= 0
+	}
+	p.errorf(e, "unexpected %v", e.Op)
+	return nil
+}
+
+func compare[T int | rune](x, y T) int {
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	}
+	return 0
+}
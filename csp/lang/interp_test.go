@@ -0,0 +1,843 @@
+package lang_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/changkun/gobase/csp"
+	"github.com/changkun/gobase/csp/lang"
+	"github.com/changkun/gobase/csp/trace"
+	"github.com/changkun/gobase/leaktest"
+)
+
+// run runs src with the external source west fed with the values of in
+// and returns the values output to east.
+func run(ctx context.Context, src string, in ...lang.Value) ([]lang.Value, error) {
+	return runScheduled(ctx, nil, src, in...)
+}
+
+// runScheduled runs src as run does, with the Schedule schedule.
+func runScheduled(ctx context.Context, schedule func([]lang.Step) int, src string, in ...lang.Value) ([]lang.Value, error) {
+	prog, err := lang.Parse(src)
+	if err != nil {
+		return nil, err
+	}
+	west, east := make(chan lang.Value), make(chan lang.Value)
+	go func() {
+		defer close(west)
+		for _, v := range in {
+			select {
+			case west <- v:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	r := csp.Go(ctx, &lang.Interpreter{
+		Program:  prog,
+		Inputs:   map[string]<-chan lang.Value{"west": west},
+		Outputs:  map[string]chan<- lang.Value{"east": east},
+		Schedule: schedule,
+	})
+	out := []lang.Value{}
+	for v := range east {
+		out = append(out, v)
+	}
+	return out, r.Wait()
+}
+
+// chars returns the values of the characters of s.
+func chars(s string) []lang.Value {
+	vs := []lang.Value{}
+	for _, c := range s {
+		vs = append(vs, c)
+	}
+	return vs
+}
+
+func TestInterpreterCOPY(t *testing.T) {
+	src := solutions(t, "S31_COPY")["S31_COPY"]
+	out, err := run(context.Background(), src, chars("Hello, CSP.")...)
+	if err != nil {
+		t.Fatalf("%v: unexpected error: %v", t.Name(), err)
+	}
+	if got := lang.Array(out).String(); got != "Hello, CSP." {
+		t.Fatalf("%v: expected: %v, got: %v", t.Name(), "Hello, CSP.", got)
+	}
+}
+
+func TestInterpreterSQUASH(t *testing.T) {
+	progs := solutions(t, "S32_SQUASH", "S32_SQUASH_EX")
+	tests := []struct {
+		prog, in, want string
+	}{
+		{prog: "S32_SQUASH", in: "a**b*c***d", want: "a↑b*c↑*d"},
+		{prog: "S32_SQUASH_EX", in: "a**b*c***d", want: "a↑b*c↑*d"},
+		{prog: "S32_SQUASH_EX", in: "a***", want: "a↑*"},
+	}
+	for _, tt := range tests {
+		out, err := run(context.Background(), progs[tt.prog], chars(tt.in)...)
+		if err != nil {
+			t.Fatalf("%v: %v: unexpected error: %v", t.Name(), tt.prog, err)
+		}
+		if got := lang.Array(out).String(); got != tt.want {
+			t.Fatalf("%v: %v: expected: %v, got: %v", t.Name(), tt.prog, tt.want, got)
+		}
+	}
+}
+
+func TestInterpreterReformat(t *testing.T) {
+	progs := solutions(t, "S31_COPY", "S33_DISASSEMBLE", "S34_ASSEMBLE", "S35_Reformat")
+	copyBody := strings.SplitN(progs["S31_COPY"], "::", 2)[1]
+	src := "DISASSEMBLE = (" + progs["S33_DISASSEMBLE"] + ")\n" +
+		"COPY = (" + copyBody + ")\n" +
+		"ASSEMBLE = (" + progs["S34_ASSEMBLE"] + ")\n" +
+		progs["S35_Reformat"]
+	prog, err := lang.Parse(src)
+	if err != nil {
+		t.Fatalf("%v: %v", t.Name(), err)
+	}
+
+	cards := []string{}
+	for i := 0; i < 5; i++ {
+		cards = append(cards, strings.Repeat(string(rune('a'+i)), 80))
+	}
+	cardfile, lineprinter := make(chan lang.Value), make(chan lang.Value)
+	go func() {
+		for _, card := range cards {
+			cardfile <- lang.Chars(card)
+		}
+		close(cardfile)
+	}()
+	r := csp.Go(context.Background(), &lang.Interpreter{
+		Program: prog,
+		Inputs:  map[string]<-chan lang.Value{"cardfile": cardfile},
+		Outputs: map[string]chan<- lang.Value{"lineprinter": lineprinter},
+	})
+	got := []string{}
+	for line := range lineprinter {
+		got = append(got, line.(lang.Array).String())
+	}
+	if err := r.Wait(); err != nil {
+		t.Fatalf("%v: unexpected error: %v", t.Name(), err)
+	}
+
+	// compare with the Go implementation.
+	cardfileGo, lineprinterGo := make(chan []rune), make(chan string)
+	go func() {
+		for _, card := range cards {
+			cardfileGo <- []rune(card)
+		}
+		close(cardfileGo)
+	}()
+	go csp.S35_Reformat(cardfileGo, lineprinterGo)
+	want := []string{}
+	for line := range lineprinterGo {
+		want = append(want, line)
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("%v: expected:\n%v\ngot:\n%v", t.Name(), strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+}
+
+func TestInterpreterTermination(t *testing.T) {
+	// Y terminates once X terminated, and so does Z once Y terminated.
+	src := `[X::i:integer; i:=0; *[i<3 -> Y!i; i:=i+1]
+	||Y::*[n:integer; X?n -> Z!n*n]
+	||Z::*[n:integer; Y?n -> east!n]]`
+	out, err := run(context.Background(), src)
+	if err != nil {
+		t.Fatalf("%v: unexpected error: %v", t.Name(), err)
+	}
+	if got := lang.Array(out).String(); got != "(0,1,4)" {
+		t.Fatalf("%v: expected: %v, got: %v", t.Name(), "(0,1,4)", got)
+	}
+}
+
+func TestInterpreterFactorial(t *testing.T) {
+	// Section 4.2, with a user inputting from west and outputting to
+	// east.
+	src := `USER = (*[n:integer; west?n -> fac(1)!n; r:integer; fac(1)?r; east!r])
+	limit:integer; limit := 6;
+	[fac(i:1..limit)::
+	 *[n:integer;fac(i-1)?n ->
+	   [n=0 -> fac(i-1)!1
+	   □ n>0 -> fac(i+1)!n-1;
+	     r:integer; fac(i+1)?r; fac(i-1)!(n*r)
+	 ]] || fac(0)::USER ]`
+	out, err := run(context.Background(), src, 0, 1, 2, 3, 4, 5)
+	if err != nil {
+		t.Fatalf("%v: unexpected error: %v", t.Name(), err)
+	}
+	if got := lang.Array(out).String(); got != "(1,1,2,6,24,120)" {
+		t.Fatalf("%v: expected: %v, got: %v", t.Name(), "(1,1,2,6,24,120)", got)
+	}
+}
+
+func TestInterpreterBoundVariables(t *testing.T) {
+	// S terminates once all processes of the array X terminated.
+	src := `[X(i:1..3)::S!i*i
+	||S::sum:integer; sum := 0;
+	   *[(i:1..3) n:integer; X(i)?n → sum := sum + n];
+	   east!sum;
+	   [(i:1..2, j:3..4) i+j = 6 → east!10*i+j]]`
+	out, err := run(context.Background(), src)
+	if err != nil {
+		t.Fatalf("%v: unexpected error: %v", t.Name(), err)
+	}
+	if got := lang.Array(out).String(); got != "(14,24)" {
+		t.Fatalf("%v: expected: %v, got: %v", t.Name(), "(14,24)", got)
+	}
+}
+
+func TestInterpreterStructuredValues(t *testing.T) {
+	tests := []struct {
+		src, want string
+	}{
+		{
+			src:  "x,y:integer; (x, y) := (1, 2); (x, y) := (y, x); east!(x, y); east!cons('a', (x,)); east!P()",
+			want: "((2,1),cons('a',(2,)),P())",
+		},
+		{
+			// X's P() is input once Z's V() is, whichever of
+			// Y's guards receives it.
+			src: `[X::Y!P() || Z::Y!V()
+			||Y::n:integer; n := 0;
+			   *[n > 0; X?P() → n := n-1; east!'p'
+			   □ X?V() → n := n+1; east!'x'
+			   □ Z?V() → n := n+1; east!'z']]`,
+			want: "zp",
+		},
+		{
+			src:  "[X::Y!has(3) || Y::n:integer; X?has(n); east!n*n]",
+			want: "(9)",
+		},
+	}
+	for _, tt := range tests {
+		out, err := run(context.Background(), tt.src)
+		if err != nil {
+			t.Fatalf("%v: %q: unexpected error: %v", t.Name(), tt.src, err)
+		}
+		if got := lang.Array(out).String(); got != tt.want {
+			t.Fatalf("%v: %q: expected: %v, got: %v", t.Name(), tt.src, tt.want, got)
+		}
+	}
+}
+
+func TestInterpreterSemaphore(t *testing.T) {
+	// Section 5.2, with X(1) releasing the semaphore first.
+	src := `[S::val:integer; val:=0;
+	 *[(i:1..3)X(i)?V()->val:=val+1
+	 □ (i:1..3)val>0;X(i)?P()->val:=val-1]
+	||X(i:1..3)::[i = 1 → skip □ i > 1 → S!P(); east!i]; S!V()]`
+	out, err := run(context.Background(), src)
+	if err != nil {
+		t.Fatalf("%v: unexpected error: %v", t.Name(), err)
+	}
+	got := lang.Array(out).String()
+	if got != "(2,3)" && got != "(3,2)" {
+		t.Fatalf("%v: expected: %v, got: %v", t.Name(), "(2,3)", got)
+	}
+}
+
+func TestInterpreterRenaming(t *testing.T) {
+	// a pipeline of two buffers of one character each, and of a
+	// buffer of two, whose names of its own processes are not renamed.
+	src := `COPY = (*[c:character; in?c → out!c])
+	BUF2 = ([X::COPY[[out <- Y]] || Y::COPY[[in <- X]]])
+	[X::COPY[[in <- west, out <- Y]] || Y::COPY[[in <- X, out <- Z]] || Z::BUF2[[in <- Y, out <- east]]]`
+	for _, schedule := range []func([]lang.Step) int{nil, lang.Random(1)} {
+		out, err := runScheduled(context.Background(), schedule, src, chars("Hello, CSP.")...)
+		if err != nil {
+			t.Fatalf("%v: unexpected error: %v", t.Name(), err)
+		}
+		if got := lang.Array(out).String(); got != "Hello, CSP." {
+			t.Fatalf("%v: expected: %v, got: %v", t.Name(), "Hello, CSP.", got)
+		}
+	}
+}
+
+func TestInterpreterInterleaving(t *testing.T) {
+	// the inner X outputs to the outer Y, as the outer X, rather than
+	// to the terminated Y of its interleaving.
+	src := `[X::[X::*[c:character; west?c → Y!c] ||| Y::skip] || Y::*[c:character; X?c → east!c]]`
+	for _, schedule := range []func([]lang.Step) int{nil, lang.Random(1)} {
+		out, err := runScheduled(context.Background(), schedule, src, chars("Hello, CSP.")...)
+		if err != nil {
+			t.Fatalf("%v: unexpected error: %v", t.Name(), err)
+		}
+		if got := lang.Array(out).String(); got != "Hello, CSP." {
+			t.Fatalf("%v: expected: %v, got: %v", t.Name(), "Hello, CSP.", got)
+		}
+	}
+}
+
+func TestInterpreterTrace(t *testing.T) {
+	prog, err := lang.Parse("[X::*[c:character; west?c -> Y!c] || Y::*[c:character; X?c -> east!c]]")
+	if err != nil {
+		t.Fatalf("%v: %v", t.Name(), err)
+	}
+	west, east := make(chan lang.Value), make(chan lang.Value)
+	trace := []string{}
+	r := csp.Go(context.Background(), &lang.Interpreter{
+		Program: prog,
+		Inputs:  map[string]<-chan lang.Value{"west": west},
+		Outputs: map[string]chan<- lang.Value{"east": east},
+		Trace:   func(c lang.Comm) { trace = append(trace, c.String()) },
+	})
+	for _, c := range "ab" {
+		west <- c
+		<-east
+	}
+	close(west)
+	if err := r.Wait(); err != nil {
+		t.Fatalf("%v: unexpected error: %v", t.Name(), err)
+	}
+
+	// the communications of every process are traced in order.
+	want := map[string]string{
+		"X": "west→X: 'a' X→Y: 'a' west→X: 'b' X→Y: 'b'",
+		"Y": "X→Y: 'a' Y→east: 'a' X→Y: 'b' Y→east: 'b'",
+	}
+	for label, want := range want {
+		got := []string{}
+		for _, c := range trace {
+			if strings.Contains(c, label+"→") || strings.Contains(c, "→"+label) {
+				got = append(got, c)
+			}
+		}
+		if strings.Join(got, " ") != want {
+			t.Fatalf("%v: %v: expected: %v, got: %v", t.Name(), label, want, strings.Join(got, " "))
+		}
+	}
+}
+
+func TestInterpreterDebug(t *testing.T) {
+	prog, err := lang.Parse(`[X::x:integer; x := 0; *[x < 2 → x := x + 1; Y!x □ x < 2 → x := x + 2; Y!x]
+	||Y::n:integer; *[X?n → east!n]]`)
+	if err != nil {
+		t.Fatalf("%v: %v", t.Name(), err)
+	}
+	stops := map[string][]string{}
+	east := make(chan lang.Value, 1)
+	err = (&lang.Interpreter{
+		Program: prog,
+		Outputs: map[string]chan<- lang.Value{"east": east},
+		Debug: func(s lang.Stop) int {
+			stop := lang.Source(s.Cmd)
+			if len(s.Guards) > 0 {
+				stop += " " + strings.Join(s.Guards, ", ")
+			}
+			for _, p := range s.Procs {
+				if p.Label == s.Process {
+					stop += " | " + p.String()
+				}
+			}
+			stops[s.Process] = append(stops[s.Process], stop)
+			if len(s.Guards) == 2 {
+				return 1
+			}
+			return -1
+		},
+	}).Run(context.Background())
+	if err != nil {
+		t.Fatalf("%v: unexpected error: %v", t.Name(), err)
+	}
+	if v := <-east; v != 2 {
+		t.Fatalf("%v: expected: 2, got: %v", t.Name(), v)
+	}
+	want := map[string][]string{
+		"X": {
+			"*[x < 2 → x := x + 1; Y!x □ x < 2 → x := x + 2; Y!x] x < 2, x < 2 | X at *[x < 2 → x := x + 1; Y!x □ x < 2 → x := x + 2; Y!x]: x = 0",
+			"Y!x | X at Y!x: x = 2",
+		},
+		"Y": {
+			"*[X?n → east!n] X?n | Y at *[X?n → east!n]: n = undefined",
+			"east!n | Y at east!n: n = 2",
+			"*[X?n → east!n] X?n | Y at *[X?n → east!n]: n = 2",
+		},
+	}
+	for label, want := range want {
+		if got := strings.Join(stops[label], "\n"); got != strings.Join(want, "\n") {
+			t.Fatalf("%v: %v: expected:\n%v\ngot:\n%v", t.Name(), label, strings.Join(want, "\n"), got)
+		}
+	}
+}
+
+func TestInterpreterErrors(t *testing.T) {
+	tests := []struct {
+		src, want string
+	}{
+		{src: "x:integer; x := 'a'", want: "1:12: cannot assign 'a' to x of type integer"},
+		{src: "x:integer; east!x", want: "1:17: x is undefined"},
+		{src: "[x > 0 -> skip]", want: "1:2: undefined variable x"},
+		{src: "[1 > 2 -> skip]", want: "1:1: all guards fail"},
+		{src: "a:(1..3)integer; a(4) := 1", want: "1:20: subscript 4 out of range 1..3"},
+		{src: "[X::Y!1 || Y::skip]", want: "X: 1:5: output to terminated process Y"},
+		{src: "[X::Y?x || Y::skip]", want: "X: 1:5: input from terminated process Y"},
+		{src: "[X::X!1]", want: "X: 1:5: process X names itself"},
+		{src: "USER", want: "1:1: undefined process USER"},
+		{src: "[X::Y!P() || Y::X?V()]", want: "Y: 1:19: P() does not match V()"},
+		{src: "x:integer; (x, x) := (1,)", want: "1:12: (1,) does not match (x, x)"},
+		{src: "[X(i:1..2)::skip || X(2)::skip]", want: "1:21: duplicate process label X(2)"},
+		{src: "[X(i:1..1, j:2..2)::j := i]", want: "X(1,2): 1:21: cannot assign to bound variable j"},
+		{src: "wait 1-2", want: "1:6: negative duration -1"},
+		{src: "[prob 1-2 → skip]", want: "1:7: negative weight -1"},
+		{src: "[prob 0 → skip]", want: "1:1: all guards fail"},
+	}
+	for _, tt := range tests {
+		_, err := run(context.Background(), tt.src)
+		if err == nil || err.Error() != tt.want {
+			t.Fatalf("%v: %q: expected: %v, got: %v", t.Name(), tt.src, tt.want, err)
+		}
+		_, err = runScheduled(context.Background(), lang.Random(1), tt.src)
+		if err == nil || err.Error() != tt.want {
+			t.Fatalf("%v: %q: scheduled: expected: %v, got: %v", t.Name(), tt.src, tt.want, err)
+		}
+	}
+}
+
+func TestInterpreterSchedule(t *testing.T) {
+	tests := []struct {
+		src  string
+		in   []lang.Value
+		want string
+	}{
+		{
+			src:  solutions(t, "S31_COPY")["S31_COPY"],
+			in:   chars("CSP"),
+			want: "CSP",
+		},
+		{
+			src: `[X::i:integer; i:=0; *[i<3 -> Y!i; i:=i+1]
+			||Y::*[n:integer; X?n -> Z!n*n]
+			||Z::*[n:integer; Y?n -> east!n]]`,
+			want: "(0,1,4)",
+		},
+		{
+			src: `limit:integer; limit := 3;
+			[fac(i:1..limit)::
+			 *[n:integer;fac(i-1)?n ->
+			   [n=0 -> fac(i-1)!1
+			   □ n>0 -> fac(i+1)!n-1;
+			     r:integer; fac(i+1)?r; fac(i-1)!(n*r)
+			 ]] || fac(0)::*[n:integer; west?n -> fac(1)!n; r:integer; fac(1)?r; east!r]]`,
+			in:   []lang.Value{0, 1, 2},
+			want: "(1,1,2)",
+		},
+		{
+			src: `[X(i:1..3)::S!i*i
+			||S::sum:integer; sum := 0;
+			   *[(i:1..3) n:integer; X(i)?n → sum := sum + n];
+			   east!sum]`,
+			want: "(14)",
+		},
+		{
+			src: `[X::Y!P() || Z::Y!V()
+			||Y::n:integer; n := 0;
+			   *[n > 0; X?P() → n := n-1; east!'p'
+			   □ X?V() → n := n+1; east!'x'
+			   □ Z?V() → n := n+1; east!'z']]`,
+			want: "zp",
+		},
+	}
+	for _, tt := range tests {
+		for seed := int64(0); seed < 10; seed++ {
+			out, err := runScheduled(context.Background(), lang.Random(seed), tt.src, tt.in...)
+			if err != nil {
+				t.Fatalf("%v: %q: seed %d: unexpected error: %v", t.Name(), tt.src, seed, err)
+			}
+			if got := lang.Array(out).String(); got != tt.want {
+				t.Fatalf("%v: %q: seed %d: expected: %v, got: %v", t.Name(), tt.src, seed, tt.want, got)
+			}
+		}
+	}
+}
+
+func TestInterpreterScheduleTrace(t *testing.T) {
+	// Section 5.2, the processes of X pass the semaphore in the order
+	// of the schedule.
+	prog, err := lang.Parse(`[S::val:integer; val:=1;
+	 *[(i:1..3)X(i)?V()->val:=val+1
+	 □ (i:1..3)val>0;X(i)?P()->val:=val-1]
+	||X(i:1..3)::S!P(); east!i; S!V()]`)
+	if err != nil {
+		t.Fatalf("%v: %v", t.Name(), err)
+	}
+	trace := func(schedule func([]lang.Step) int) (string, error) {
+		east := make(chan lang.Value, 3)
+		var comms []string
+		err := (&lang.Interpreter{
+			Program:  prog,
+			Outputs:  map[string]chan<- lang.Value{"east": east},
+			Trace:    func(c lang.Comm) { comms = append(comms, c.String()) },
+			Schedule: schedule,
+		}).Run(context.Background())
+		return strings.Join(comms, ", "), err
+	}
+
+	traces := map[string]bool{}
+	for seed := int64(0); seed < 10; seed++ {
+		want, err := trace(lang.Random(seed))
+		if err != nil {
+			t.Fatalf("%v: seed %d: unexpected error: %v", t.Name(), seed, err)
+		}
+		for i := 0; i < 5; i++ {
+			if got, _ := trace(lang.Random(seed)); got != want {
+				t.Fatalf("%v: seed %d: expected: %v, got: %v", t.Name(), seed, want, got)
+			}
+		}
+		traces[want] = true
+	}
+	if len(traces) < 2 {
+		t.Fatalf("%v: expected several traces, got: %v", t.Name(), traces)
+	}
+
+	got, err := trace(lang.Script("X(3)", "X(1)"))
+	if err != nil {
+		t.Fatalf("%v: unexpected error: %v", t.Name(), err)
+	}
+	want := "X(3)→S: P(), X(3)→east: 3, X(3)→S: V(), X(1)→S: P(), X(1)→east: 1, X(1)→S: V(), " +
+		"X(2)→S: P(), X(2)→east: 2, X(2)→S: V()"
+	if got != want {
+		t.Fatalf("%v: expected: %v, got: %v", t.Name(), want, got)
+	}
+}
+
+func TestInterpreterRecord(t *testing.T) {
+	prog, err := lang.Parse("[X::Y!1; Y!2 || Y::n:integer; *[X?n → east!n]]")
+	if err != nil {
+		t.Fatalf("%v: %v", t.Name(), err)
+	}
+	east := make(chan lang.Value, 2)
+	r := trace.NewRecorder()
+	err = (&lang.Interpreter{
+		Program:  prog,
+		Outputs:  map[string]chan<- lang.Value{"east": east},
+		Schedule: lang.Random(1),
+	}).Run(trace.NewContext(context.Background(), r))
+	if err != nil {
+		t.Fatalf("%v: unexpected error: %v", t.Name(), err)
+	}
+
+	// processes start and terminate concurrently with the
+	// communications of the others.
+	got := []string{}
+	for _, e := range r.Events() {
+		if e.Kind != trace.Spawn && e.Kind != trace.Terminate {
+			got = append(got, strings.SplitN(e.String(), " ", 2)[1])
+		}
+	}
+	want := []string{
+		"X send X→Y 1", "Y receive X→Y 1", "Y select X?n",
+		"Y send Y→east 1", "east receive Y→east 1",
+		"X send X→Y 2", "Y receive X→Y 2", "Y select X?n",
+		"Y send Y→east 2", "east receive Y→east 2",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("%v: expected:\n%v\ngot:\n%v", t.Name(), strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+}
+
+func TestInterpreterReplay(t *testing.T) {
+	// Section 5.2, the processes of X pass the semaphore in some
+	// order, and again in the same order once replayed.
+	prog, err := lang.Parse(`[S::val:integer; val:=1;
+	 *[(i:1..3)X(i)?V()->val:=val+1
+	 □ (i:1..3)val>0;X(i)?P()->val:=val-1]
+	||X(i:1..3)::S!P(); east!i; S!V()]`)
+	if err != nil {
+		t.Fatalf("%v: %v", t.Name(), err)
+	}
+	record := func(schedule func([]lang.Step) int) ([]trace.Event, string, error) {
+		east := make(chan lang.Value, 3)
+		r := trace.NewRecorder()
+		err := (&lang.Interpreter{
+			Program:  prog,
+			Outputs:  map[string]chan<- lang.Value{"east": east},
+			Schedule: schedule,
+		}).Run(trace.NewContext(context.Background(), r))
+		var comms []string
+		for _, e := range r.Events() {
+			if e.Kind != trace.Spawn && e.Kind != trace.Terminate {
+				comms = append(comms, strings.SplitN(e.String(), " ", 2)[1])
+			}
+		}
+		return r.Events(), strings.Join(comms, ", "), err
+	}
+
+	for seed := int64(0); seed < 5; seed++ {
+		events, want, err := record(lang.Random(seed))
+		if err != nil {
+			t.Fatalf("%v: seed %d: unexpected error: %v", t.Name(), seed, err)
+		}
+		_, got, err := record(lang.Replay(events))
+		if err != nil {
+			t.Fatalf("%v: seed %d: unexpected error: %v", t.Name(), seed, err)
+		}
+		if got != want {
+			t.Fatalf("%v: seed %d: expected: %v, got: %v", t.Name(), seed, want, got)
+		}
+	}
+
+	// the replay of another run diverges.
+	_, _, err = record(lang.Replay([]trace.Event{{Kind: trace.Send, Process: "X(4)", Chan: "X(4)→S", Value: lang.Structured{Constructor: "P"}}}))
+	if err == nil || !strings.Contains(err.Error(), "no step scheduled") {
+		t.Fatalf("%v: expected: no step scheduled, got: %v", t.Name(), err)
+	}
+}
+
+func TestInterpreterScheduleErrors(t *testing.T) {
+	tests := []struct {
+		src      string
+		schedule func([]lang.Step) int
+		want     string
+	}{
+		{
+			src:      "[X::Y!1 || Y::Z!2 || Z::X!3]",
+			schedule: lang.Random(1),
+			want:     "X: 1:5: deadlock; Y: 1:15: deadlock; Z: 1:25: deadlock",
+		},
+		{
+			src:      "[X::Y!1 || Z::Y!2 || Y::n:integer; *[X?n → skip □ Z?n → skip]]",
+			schedule: lang.Script("Y→X"),
+			want:     "X: 1:5: no step scheduled; Z: 1:15: no step scheduled; Y: 1:36: no step scheduled",
+		},
+	}
+	for _, tt := range tests {
+		_, err := runScheduled(context.Background(), tt.schedule, tt.src)
+		if err == nil || err.Error() != tt.want {
+			t.Fatalf("%v: %q: expected: %v, got: %v", t.Name(), tt.src, tt.want, err)
+		}
+	}
+}
+
+func TestInterpreterTimed(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{
+			// X beats every 2 units of time.
+			src:  "[X::i:integer; i := 0; *[i < 3 → Y!i; wait 2; i := i+1] || Y::*[n:integer; X?n → east!n]]",
+			want: "0 X→Y: 0, 0 Y→east: 0, 2 X→Y: 1, 2 Y→east: 1, 4 X→Y: 2, 4 Y→east: 2",
+		},
+		{
+			// Y times out before X outputs, and then waits for it.
+			src:  "[X::wait 5; Y!1 || Y::n:integer; [X?n → east!n □ timeout 3 → east!0; X?n]]",
+			want: "3 Y→east: 0, 5 X→Y: 1",
+		},
+		{
+			src:  "[X::wait 1; Y!1 || Y::n:integer; [X?n → east!n □ timeout 3 → east!0; X?n]]",
+			want: "1 X→Y: 1, 1 Y→east: 1",
+		},
+		{
+			// time passes for all processes at once, the earliest
+			// timeout ends first.
+			src:  "[X::[timeout 4 → east!4] || Y::wait 1; [timeout 2 → east!3] || Z::wait 2; east!2]",
+			want: "2 Z→east: 2, 3 Y→east: 3, 4 X→east: 4",
+		},
+	}
+	for _, tt := range tests {
+		prog, err := lang.Parse(tt.src)
+		if err != nil {
+			t.Fatalf("%v: %v", t.Name(), err)
+		}
+		var comms []string
+		err = (&lang.Interpreter{
+			Program:  prog,
+			Outputs:  map[string]chan<- lang.Value{"east": make(chan lang.Value, 10)},
+			Trace:    func(c lang.Comm) { comms = append(comms, fmt.Sprintf("%d %v", c.Time, c)) },
+			Schedule: lang.Random(1),
+		}).Run(context.Background())
+		if err != nil {
+			t.Fatalf("%v: %q: unexpected error: %v", t.Name(), tt.src, err)
+		}
+		if got := strings.Join(comms, ", "); got != tt.want {
+			t.Fatalf("%v: %q: expected: %v, got: %v", t.Name(), tt.src, tt.want, got)
+		}
+	}
+
+	// without a Schedule, time passes on the wall clock.
+	start := time.Now()
+	out, err := run(context.Background(), "[X::wait 50; Y!1 || Y::n:integer; [X?n → east!n □ timeout 1 → east!0; X?n; east!n]]")
+	if err != nil {
+		t.Fatalf("%v: unexpected error: %v", t.Name(), err)
+	}
+	if got := fmt.Sprint(out); got != "[0 1]" {
+		t.Fatalf("%v: expected: %v, got: %v", t.Name(), "[0 1]", got)
+	}
+	if d := time.Since(start); d < 50*time.Millisecond {
+		t.Fatalf("%v: expected a wait of %v, got: %v", t.Name(), 50*time.Millisecond, d)
+	}
+
+	// the end of a wait command is a step of a Schedule.
+	_, err = runScheduled(context.Background(), lang.Script("X: wait 2"), "[X::wait 2; east!1 || Y::wait 2; east!2]")
+	if err != nil {
+		t.Fatalf("%v: unexpected error: %v", t.Name(), err)
+	}
+}
+
+func TestInterpreterProbabilistic(t *testing.T) {
+	prog, err := lang.Parse(`n:integer; n := 0;
+	*[n < 1000; prob 3 → east!1; n := n+1 □ n < 1000; prob 1 → east!0; n := n+1 □ n < 1000; prob 0 → east!2]`)
+	if err != nil {
+		t.Fatalf("%v: %v", t.Name(), err)
+	}
+	draw := func(seed int64) []lang.Value {
+		east := make(chan lang.Value, 1000)
+		err := (&lang.Interpreter{
+			Program: prog,
+			Outputs: map[string]chan<- lang.Value{"east": east},
+			Rand:    rand.New(rand.NewSource(seed)),
+		}).Run(context.Background())
+		if err != nil {
+			t.Fatalf("%v: unexpected error: %v", t.Name(), err)
+		}
+		var out []lang.Value
+		for v := range east {
+			out = append(out, v)
+		}
+		return out
+	}
+
+	// the guards are selected as likely as their weights.
+	out := draw(1)
+	counts := map[lang.Value]int{}
+	for _, v := range out {
+		counts[v]++
+	}
+	if counts[2] != 0 || counts[1] < 700 || counts[1] > 800 {
+		t.Fatalf("%v: expected about 750 of 1 and no 2, got: %v", t.Name(), counts)
+	}
+	// the draws of a seed are the same.
+	if got := draw(1); fmt.Sprint(got) != fmt.Sprint(out) {
+		t.Fatalf("%v: expected the draws of the same seed", t.Name())
+	}
+}
+
+func TestEstimate(t *testing.T) {
+	prog, err := lang.Parse("[X::[prob 1 → Y!1 □ prob 3 → Y!0] || Y::n:integer; [X?n → east!n □ timeout 2 → skip]]")
+	if err != nil {
+		t.Fatalf("%v: %v", t.Name(), err)
+	}
+	probs, err := lang.Estimate(context.Background(), prog, 1000, nil)
+	if err != nil {
+		t.Fatalf("%v: unexpected error: %v", t.Name(), err)
+	}
+	if len(probs) != 2 {
+		t.Fatalf("%v: expected two outcomes, got: %v", t.Name(), probs)
+	}
+	if p := probs["X→Y: 1, Y→east: 1"]; p < 0.2 || p > 0.3 {
+		t.Fatalf("%v: expected a probability of about 0.25, got: %v", t.Name(), probs)
+	}
+	again, err := lang.Estimate(context.Background(), prog, 1000, nil)
+	if err != nil || fmt.Sprint(again) != fmt.Sprint(probs) {
+		t.Fatalf("%v: expected: %v, got: %v, %v", t.Name(), probs, again, err)
+	}
+
+	// the outcome of a timed trace, the time X outputs by.
+	prog, err = lang.Parse("[X::[prob 1 → wait 1 □ prob 1 → wait 3]; Y!1 || Y::n:integer; [X?n → skip □ timeout 2 → skip]]")
+	if err != nil {
+		t.Fatalf("%v: %v", t.Name(), err)
+	}
+	probs, err = lang.Estimate(context.Background(), prog, 1000, func(trace []lang.Comm, err error) string {
+		if len(trace) == 0 {
+			return "timeout"
+		}
+		return fmt.Sprint(trace[0].Time)
+	})
+	if err != nil {
+		t.Fatalf("%v: unexpected error: %v", t.Name(), err)
+	}
+	if p := probs["1"]; len(probs) != 2 || p < 0.45 || p > 0.55 {
+		t.Fatalf("%v: expected the outcomes 1 and timeout about as likely, got: %v", t.Name(), probs)
+	}
+}
+
+func TestInterpreterCancel(t *testing.T) {
+	leakctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	defer leaktest.CheckContext(leakctx, t)()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	// X and Y deadlock waiting for each other.
+	_, err := run(ctx, "[X::x:integer; Y?x || Y::*[y:integer; X?y -> skip]]")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("%v: expected: %v, got: %v", t.Name(), context.DeadlineExceeded, err)
+	}
+}
+
+func TestExternals(t *testing.T) {
+	prog, err := lang.Parse(`COPY = (*[c:character; west?c -> east!c])
+	[west::*[c:character; cardfile?c -> X!c] || X::COPY || east::*[c:character; X?c -> lineprinter!c; log!c]]`)
+	if err != nil {
+		t.Fatalf("%v: %v", t.Name(), err)
+	}
+	inputs, outputs := lang.Externals(prog)
+	if got := strings.Join(inputs, ","); got != "cardfile" {
+		t.Fatalf("%v: expected inputs: %v, got: %v", t.Name(), "cardfile", got)
+	}
+	if got := strings.Join(outputs, ","); got != "lineprinter,log" {
+		t.Fatalf("%v: expected outputs: %v, got: %v", t.Name(), "lineprinter,log", got)
+	}
+}
+
+func TestAlphabetOf(t *testing.T) {
+	prog, err := lang.Parse(`COPY = (*[c:character; west?c -> east!c])
+	COPY2 = (COPY[[west <- in, east <- out]])
+	LOG = (*[c:character; west?c -> log!c; east!c]) \ (log)
+	PIPE = ([X::COPY[[east <- Y]] || Y::COPY[[west <- X]]])
+	skip`)
+	if err != nil {
+		t.Fatalf("%v: %v", t.Name(), err)
+	}
+	tests := []struct {
+		name, want string
+	}{
+		{"COPY", "west,east"},
+		{"COPY2", "in,out"},
+		{"LOG", "west,east"},
+		{"PIPE", "west,east"},
+		{"NONE", ""},
+	}
+	for _, tt := range tests {
+		if got := strings.Join(lang.AlphabetOf(prog, tt.name), ","); got != tt.want {
+			t.Fatalf("%v: %v: expected: %v, got: %v", t.Name(), tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestEval(t *testing.T) {
+	tests := []struct {
+		src, want string
+	}{
+		{src: "(1+2)*3 mod 4", want: "1"},
+		{src: "'a' < 'b' ∧ ¬false", want: "true"},
+		{src: `"Hello, CSP"`, want: `"Hello, CSP"`},
+		{src: "upward arrow", want: "'↑'"},
+		{src: "x+1", want: "1:1: undefined variable x"},
+	}
+	for _, tt := range tests {
+		x, err := lang.ParseExpr(tt.src)
+		if err != nil {
+			t.Fatalf("%v: %v", t.Name(), err)
+		}
+		got := ""
+		if v, err := lang.Eval(x); err != nil {
+			got = err.Error()
+		} else {
+			got = lang.Format(v)
+		}
+		if got != tt.want {
+			t.Fatalf("%v: %q: expected: %v, got: %v", t.Name(), tt.src, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,381 @@
+// Package lang implements the notation of communicating sequential
+// processes as described in the documentation of package csp, such
+// that the programs of the paper can be processed by tools.
+//
+// The lexer accepts both the symbols of the paper and an ASCII spelling
+// for them:
+//
+//   □   []      →   ->      ≠   != <>
+//   ≤   <=      ≥   >=      ×   *
+//   ∧   and     ∨   or      ¬   not
+//
+// A comment either starts with -- and extends to the end of the line,
+// or is the paper's "comment ...;" which extends to the next
+// semicolon. The named characters of the paper, space, asterisk and
+// upward arrow, are identifiers.
+package lang
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Error is an error at a position of the source.
+type Error struct {
+	Pos Pos
+	Msg string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%v: %s", e.Pos, e.Msg)
+}
+
+// Lexer tokenizes the source of a program.
+type Lexer struct {
+	src    string
+	offset int // offset of the next rune
+	line   int
+	column int
+
+	// Errors are the errors encountered so far.
+	Errors []*Error
+
+	comments []comment
+}
+
+// comment is a comment of the source.
+type comment struct {
+	pos  Pos
+	text string
+}
+
+// NewLexer returns a lexer of src.
+func NewLexer(src string) *Lexer {
+	return &Lexer{src: src, line: 1, column: 1}
+}
+
+// Item is a token at a position, with its literal text for
+// identifiers, numbers, characters and strings.
+type Item struct {
+	Pos Pos
+	Tok Token
+	Lit string
+}
+
+func (it Item) String() string {
+	switch it.Tok {
+	case IDENT, INT:
+		return it.Lit
+	case CHAR:
+		return strconv.QuoteRune([]rune(it.Lit)[0])
+	case STRING:
+		return strconv.Quote(it.Lit)
+	}
+	return it.Tok.String()
+}
+
+// Tokenize returns all tokens of src, terminated by EOF, and the first
+// error encountered, if any.
+func Tokenize(src string) ([]Item, error) {
+	l := NewLexer(src)
+	items := []Item{}
+	for {
+		it := l.Next()
+		items = append(items, it)
+		if it.Tok == EOF {
+			break
+		}
+	}
+	if len(l.Errors) > 0 {
+		return items, l.Errors[0]
+	}
+	return items, nil
+}
+
+// Next returns the next token. At the end of the source it returns EOF
+// repeatedly. The literal of a CHAR is the character, the literal of a
+// STRING is the unquoted string.
+func (l *Lexer) Next() Item {
+	l.skip()
+	pos := l.pos()
+	if l.offset >= len(l.src) {
+		return Item{Pos: pos, Tok: EOF}
+	}
+
+	r := l.peek(0)
+	switch {
+	case isLetter(r):
+		return l.ident(pos)
+	case unicode.IsDigit(r):
+		start := l.offset
+		for unicode.IsDigit(l.peek(0)) {
+			l.read()
+		}
+		return Item{Pos: pos, Tok: INT, Lit: l.src[start:l.offset]}
+	case r == '\'':
+		return l.char(pos)
+	case r == '"':
+		return l.string(pos)
+	}
+
+	l.read()
+	tok := ILLEGAL
+	switch r {
+	case ':':
+		tok = COLON
+		if l.match(':') {
+			tok = LABEL
+		} else if l.match('=') {
+			tok = ASSIGN
+		}
+	case '|':
+		if l.match('|') {
+			tok = PAR
+			if l.match('|') {
+				tok = INTER
+			}
+		}
+	case '?':
+		tok = INPUT
+	case '!':
+		tok = OUTPUT
+		if l.match('=') {
+			tok = NEQ
+		}
+	case '□':
+		tok = BOX
+	case '[':
+		tok = LBRACK
+		if l.match(']') {
+			tok = BOX
+		}
+	case ']':
+		tok = RBRACK
+	case '→':
+		tok = ARROW
+	case '-':
+		tok = SUB
+		if l.match('>') {
+			tok = ARROW
+		}
+	case '*':
+		tok = MUL
+		if l.match('[') {
+			tok = REP
+		}
+	case '×':
+		tok = MUL
+	case '(':
+		tok = LPAREN
+	case ')':
+		tok = RPAREN
+	case ',':
+		tok = COMMA
+	case ';':
+		tok = SEMI
+	case '.':
+		if l.match('.') {
+			tok = RANGE
+		}
+	case '=':
+		tok = EQ
+	case '≠':
+		tok = NEQ
+	case '<':
+		tok = LT
+		if l.match('=') {
+			tok = LEQ
+		} else if l.match('>') {
+			tok = NEQ
+		}
+	case '≤':
+		tok = LEQ
+	case '>':
+		tok = GT
+		if l.match('=') {
+			tok = GEQ
+		}
+	case '≥':
+		tok = GEQ
+	case '+':
+		tok = ADD
+	case '/':
+		tok = DIV
+	case '\\':
+		tok = MOD
+	case '∧':
+		tok = AND
+	case '∨':
+		tok = OR
+	case '¬':
+		tok = NOT
+	}
+	if tok == ILLEGAL {
+		l.errorf(pos, "unexpected %q", l.src[pos.Offset:l.offset])
+		return Item{Pos: pos, Tok: ILLEGAL, Lit: l.src[pos.Offset:l.offset]}
+	}
+	return Item{Pos: pos, Tok: tok}
+}
+
+// ident scans an identifier or keyword.
+func (l *Lexer) ident(pos Pos) Item {
+	start := l.offset
+	for isLetter(l.peek(0)) || unicode.IsDigit(l.peek(0)) {
+		l.read()
+	}
+	lit := l.src[start:l.offset]
+	if tok, ok := keywords[lit]; ok {
+		return Item{Pos: pos, Tok: tok}
+	}
+	// upward arrow is the only name of the paper spelled with a space.
+	if lit == "upward" {
+		i := l.offset
+		for i < len(l.src) && (l.src[i] == ' ' || l.src[i] == '\t') {
+			i++
+		}
+		if i > l.offset && strings.HasPrefix(l.src[i:], "arrow") && !isIdentRune(l.src[i+len("arrow"):]) {
+			l.advance(i + len("arrow"))
+			lit = "upward arrow"
+		}
+	}
+	return Item{Pos: pos, Tok: IDENT, Lit: lit}
+}
+
+// isIdentRune reports whether s starts with a rune of an identifier.
+func isIdentRune(s string) bool {
+	r, _ := utf8.DecodeRuneInString(s)
+	return s != "" && (isLetter(r) || unicode.IsDigit(r))
+}
+
+// char scans a character literal such as 'a' or '\n'.
+func (l *Lexer) char(pos Pos) Item {
+	end := l.literalEnd('\'')
+	lit := l.src[pos.Offset:end]
+	l.advance(end)
+	s, err := strconv.Unquote(lit)
+	if err != nil || utf8.RuneCountInString(s) != 1 {
+		l.errorf(pos, "invalid character literal %s", lit)
+		return Item{Pos: pos, Tok: ILLEGAL, Lit: lit}
+	}
+	return Item{Pos: pos, Tok: CHAR, Lit: s}
+}
+
+// string scans a string literal such as "Hello, CSP".
+func (l *Lexer) string(pos Pos) Item {
+	end := l.literalEnd('"')
+	lit := l.src[pos.Offset:end]
+	l.advance(end)
+	s, err := strconv.Unquote(lit)
+	if err != nil {
+		l.errorf(pos, "invalid string literal %s", lit)
+		return Item{Pos: pos, Tok: ILLEGAL, Lit: lit}
+	}
+	return Item{Pos: pos, Tok: STRING, Lit: s}
+}
+
+// literalEnd returns the offset following the quoted literal starting
+// at the current offset, or the end of the line if it is unterminated.
+func (l *Lexer) literalEnd(quote byte) int {
+	for i := l.offset + 1; i < len(l.src); i++ {
+		switch l.src[i] {
+		case '\\':
+			i++
+		case quote:
+			return i + 1
+		case '\n':
+			return i
+		}
+	}
+	return len(l.src)
+}
+
+// skip skips white space and comments.
+func (l *Lexer) skip() {
+	for l.offset < len(l.src) {
+		r := l.peek(0)
+		switch {
+		case unicode.IsSpace(r):
+			l.read()
+		case r == '-' && l.peek(1) == '-':
+			pos := l.pos()
+			for l.offset < len(l.src) && l.peek(0) != '\n' {
+				l.read()
+			}
+			l.comments = append(l.comments, comment{pos, strings.TrimRightFunc(l.src[pos.Offset:l.offset], unicode.IsSpace)})
+		case strings.HasPrefix(l.src[l.offset:], "comment") && !isIdentRune(l.src[l.offset+len("comment"):]):
+			pos := l.pos()
+			for l.offset < len(l.src) && l.peek(0) != ';' {
+				l.read()
+			}
+			if l.offset >= len(l.src) {
+				l.errorf(pos, "comment not terminated by ;")
+				return
+			}
+			l.read()
+			l.comments = append(l.comments, comment{pos, l.src[pos.Offset:l.offset]})
+		default:
+			return
+		}
+	}
+}
+
+// pos returns the position of the next rune.
+func (l *Lexer) pos() Pos {
+	return Pos{Offset: l.offset, Line: l.line, Column: l.column}
+}
+
+// peek returns the i-th rune following the current offset without
+// consuming it, or -1 at the end of the source.
+func (l *Lexer) peek(i int) rune {
+	off := l.offset
+	for ; i > 0 && off < len(l.src); i-- {
+		_, n := utf8.DecodeRuneInString(l.src[off:])
+		off += n
+	}
+	if off >= len(l.src) {
+		return -1
+	}
+	r, _ := utf8.DecodeRuneInString(l.src[off:])
+	return r
+}
+
+// read consumes the next rune.
+func (l *Lexer) read() rune {
+	r, n := utf8.DecodeRuneInString(l.src[l.offset:])
+	l.offset += n
+	if r == '\n' {
+		l.line++
+		l.column = 1
+	} else {
+		l.column++
+	}
+	return r
+}
+
+// match consumes the next rune if it is r.
+func (l *Lexer) match(r rune) bool {
+	if l.peek(0) != r {
+		return false
+	}
+	l.read()
+	return true
+}
+
+// advance consumes all runes up to offset end.
+func (l *Lexer) advance(end int) {
+	for l.offset < end {
+		l.read()
+	}
+}
+
+func (l *Lexer) errorf(pos Pos, format string, args ...interface{}) {
+	l.Errors = append(l.Errors, &Error{Pos: pos, Msg: fmt.Sprintf(format, args...)})
+}
+
+// isLetter reports whether r may start an identifier.
+func isLetter(r rune) bool {
+	return r == '_' || unicode.IsLetter(r)
+}
@@ -0,0 +1,92 @@
+package lang_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/changkun/gobase/csp/lang"
+)
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{
+			src:  "X :: *[c:character; west?c -> east!c]",
+			want: "X :: *[ c : character ; west ? c → east ! c ] EOF",
+		},
+		{
+			src:  "X::*[c:character; west?c → east!c]",
+			want: "X :: *[ c : character ; west ? c → east ! c ] EOF",
+		},
+		{
+			src:  "[c != asterisk -> east!c [] c = asterisk -> east!upward arrow]",
+			want: "[ c ≠ asterisk → east ! c □ c = asterisk → east ! upward arrow ] EOF",
+		},
+		{
+			src:  "[west::DISASSEMBLE||X::COPY||east::ASSEMBLE]",
+			want: "[ west :: DISASSEMBLE || X :: COPY || east :: ASSEMBLE ] EOF",
+		},
+		{
+			src:  "[X::COPY|||Y::COPY]",
+			want: "[ X :: COPY ||| Y :: COPY ] EOF",
+		},
+		{
+			src:  "fac(i:1..limit)::n:=n*r; i <= 124 ≤ ≥ >= <> ¬ not and or mod",
+			want: "fac ( i : 1 .. limit ) :: n := n * r ; i ≤ 124 ≤ ≥ ≥ ≠ ¬ ¬ ∧ ∨ mod EOF",
+		},
+		{
+			src:  "mp:=p; comment mp is a multiple of p; *[m>mp->skip] -- a comment\nx",
+			want: "mp := p ; *[ m > mp → skip ] x EOF",
+		},
+		{
+			src:  `print!'↑'; print!"Hello, CSP"; upward; arrow`,
+			want: `print ! '↑' ; print ! "Hello, CSP" ; upward ; arrow EOF`,
+		},
+	}
+
+	for _, tt := range tests {
+		items, err := lang.Tokenize(tt.src)
+		if err != nil {
+			t.Fatalf("%v: unexpected error: %v", t.Name(), err)
+		}
+		got := make([]string, len(items))
+		for i, it := range items {
+			got[i] = it.String()
+		}
+		if strings.Join(got, " ") != tt.want {
+			t.Fatalf("%v: expected: %v, got: %v", t.Name(), tt.want, strings.Join(got, " "))
+		}
+	}
+}
+
+func TestTokenizePositions(t *testing.T) {
+	items, err := lang.Tokenize("X ::\n  west?c →\teast!c")
+	if err != nil {
+		t.Fatalf("%v: unexpected error: %v", t.Name(), err)
+	}
+	want := []string{"1:1", "1:3", "2:3", "2:7", "2:8", "2:10", "2:12", "2:16", "2:17", "2:18"}
+	for i, it := range items {
+		if got := it.Pos.String(); got != want[i] {
+			t.Fatalf("%v: token %v, expected: %v, got: %v", t.Name(), it, want[i], got)
+		}
+	}
+}
+
+func TestTokenizeErrors(t *testing.T) {
+	tests := []struct {
+		src, want string
+	}{
+		{src: "x | y", want: `1:3: unexpected "|"`},
+		{src: "x := 'ab'", want: "1:6: invalid character literal 'ab'"},
+		{src: "x := 1\n  comment unterminated", want: "2:3: comment not terminated by ;"},
+		{src: "x.y", want: `1:2: unexpected "."`},
+	}
+	for _, tt := range tests {
+		_, err := lang.Tokenize(tt.src)
+		if err == nil || err.Error() != tt.want {
+			t.Fatalf("%v: expected: %v, got: %v", t.Name(), tt.want, err)
+		}
+	}
+}
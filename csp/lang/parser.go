@@ -0,0 +1,744 @@
+package lang
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Parse parses the source of a program:
+//
+//   <program>           ::= {<definition> | <assertion>} <cmd list>
+//                         | {<definition> | <assertion>} <proc> {||<proc>}
+//
+// A program of labelled processes without enclosing brackets, such as
+// X::*[...], is parsed as a parallel command with Implicit set. The
+// body of a program consisting of definitions and assertions only is
+// empty.
+//
+// After a syntax error, Parse resumes at the next command or guarded
+// command, so that it returns up to ten errors of the source as an
+// ErrorList.
+func Parse(src string) (*Program, error) {
+	p := newParser(src)
+	prog := p.parse()
+	if err := sortErrors(p.errs); err != nil {
+		return nil, err
+	}
+	return prog, nil
+}
+
+// ParseExpr parses the source of an expression.
+func ParseExpr(src string) (Expr, error) {
+	p := newParser(src)
+	x, _ := p.parseOnly(func() Node { return p.parseExpr() }).(Expr)
+	if err := sortErrors(p.errs); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// parser is a recursive descent parser of the CSP notation.
+type parser struct {
+	items    []Item
+	p        int
+	errs     []*Error
+	comments []comment
+}
+
+// bailout aborts parsing at an error, up to the next command the
+// parser resumes at.
+type bailout struct{}
+
+// maxErrors is the number of errors after which the parser gives up.
+const maxErrors = 10
+
+func newParser(src string) *parser {
+	l := NewLexer(src)
+	p := &parser{}
+	for {
+		it := l.Next()
+		if it.Tok == ILLEGAL {
+			continue
+		}
+		p.items = append(p.items, it)
+		if it.Tok == EOF {
+			break
+		}
+	}
+	p.errs = append(p.errs, l.Errors...)
+	p.comments = l.comments
+	return p
+}
+
+func (p *parser) parse() *Program {
+	prog, _ := p.parseOnly(func() Node { return p.parseProgram() }).(*Program)
+	return prog
+}
+
+func (p *parser) parseProgram() *Program {
+	prog := &Program{}
+	for {
+		if p.tok() == IDENT && p.peek(1) == EQ {
+			prog.Defs = append(prog.Defs, p.parseDefinition())
+		} else if p.isAssertion() {
+			prog.Asserts = append(prog.Asserts, p.parseAssertion())
+		} else {
+			break
+		}
+	}
+	if p.tok() == EOF && len(prog.Defs)+len(prog.Asserts) > 0 {
+		prog.Body = &CmdList{Start: p.pos()}
+		return prog
+	}
+	if p.isLabel() {
+		par := &ParallelCmd{Lbrack: p.pos(), Implicit: true}
+		p.parseProcs(par)
+		prog.Body = &CmdList{Start: par.Lbrack, Stmts: []Stmt{par}}
+	} else {
+		prog.Body = p.parseCmdList()
+	}
+	return prog
+}
+
+// parseOnly parses the whole source by f.
+func (p *parser) parseOnly(f func() Node) (n Node) {
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(bailout); !ok {
+				panic(r)
+			}
+		}
+	}()
+	n = f()
+	p.expect(EOF)
+	return n
+}
+
+// current token handling
+
+func (p *parser) tok() Token       { return p.items[p.p].Tok }
+func (p *parser) pos() Pos         { return p.items[p.p].Pos }
+func (p *parser) peek(i int) Token { return p.item(i).Tok }
+func (p *parser) item(i int) Item {
+	if p.p+i >= len(p.items) {
+		return p.items[len(p.items)-1]
+	}
+	return p.items[p.p+i]
+}
+
+func (p *parser) next() Item {
+	it := p.items[p.p]
+	if it.Tok != EOF {
+		p.p++
+	}
+	return it
+}
+
+func (p *parser) expect(tok Token) Item {
+	if p.tok() != tok {
+		p.errorExpected(tok.String())
+	}
+	return p.next()
+}
+
+func (p *parser) errorExpected(what string) {
+	p.errorf(p.pos(), "expected %s, found %v", what, p.items[p.p])
+}
+
+// errorf records an error at pos, unless the line of pos has one
+// already, as errors following another on the same line mostly are
+// its consequences, and bails out.
+func (p *parser) errorf(pos Pos, format string, args ...interface{}) {
+	for _, e := range p.errs {
+		if e.Pos.Line == pos.Line {
+			panic(bailout{})
+		}
+	}
+	p.errs = append(p.errs, &Error{Pos: pos, Msg: fmt.Sprintf(format, args...)})
+	panic(bailout{})
+}
+
+// resume resumes parsing after the panic r, if any, of an error in
+// the command starting at the token at index start: it skips the
+// tokens up to the first of stops outside the brackets and
+// parentheses of the command. It reports whether there was an error.
+func (p *parser) resume(r interface{}, start int, stops ...Token) bool {
+	if r == nil {
+		return false
+	}
+	if _, ok := r.(bailout); !ok || len(p.errs) >= maxErrors {
+		panic(r)
+	}
+	depth := 0
+	for i := start; i < p.p; i++ {
+		switch p.items[i].Tok {
+		case LBRACK, REP, LPAREN:
+			depth++
+		case RBRACK, RPAREN:
+			depth--
+		}
+	}
+	for ; p.tok() != EOF; p.next() {
+		if depth <= 0 {
+			for _, tok := range stops {
+				if p.tok() == tok {
+					return true
+				}
+			}
+		}
+		switch p.tok() {
+		case LBRACK, REP, LPAREN:
+			depth++
+		case RBRACK, RPAREN:
+			depth--
+		}
+	}
+	return true
+}
+
+// closing returns the index of the token closing the bracket or
+// parenthesis at index i, or -1.
+func (p *parser) closing(i int) int {
+	depth := 0
+	for ; i < len(p.items); i++ {
+		switch p.items[i].Tok {
+		case LBRACK, REP, LPAREN:
+			depth++
+		case RBRACK, RPAREN:
+			depth--
+			if depth == 0 {
+				return i
+			}
+		case EOF:
+			return -1
+		}
+	}
+	return -1
+}
+
+// isLabel reports whether a process label starts at the current token.
+func (p *parser) isLabel() bool {
+	if p.tok() != IDENT {
+		return false
+	}
+	switch p.peek(1) {
+	case LABEL:
+		return true
+	case LPAREN:
+		end := p.closing(p.p + 1)
+		return end > 0 && end+1 < len(p.items) && p.items[end+1].Tok == LABEL
+	}
+	return false
+}
+
+// definitions and processes
+
+func (p *parser) parseDefinition() *Definition {
+	name := p.expect(IDENT)
+	p.expect(EQ)
+	p.expect(LPAREN)
+	body := p.parseCmdList()
+	p.expect(RPAREN)
+	d := &Definition{NamePos: name.Pos, Name: name.Lit, Body: body}
+	if p.tok() == MOD {
+		p.next()
+		p.expect(LPAREN)
+		for {
+			id := p.expect(IDENT)
+			d.Hidden = append(d.Hidden, &Ident{NamePos: id.Pos, Name: id.Lit})
+			if p.tok() != COMMA {
+				break
+			}
+			p.next()
+		}
+		p.expect(RPAREN)
+	}
+	return d
+}
+
+// properties are the properties of an assertion, models the models of
+// a refinement.
+var (
+	properties = map[string]bool{"deadlock free": true, "divergence free": true, "deterministic": true}
+	models     = map[string]bool{"T": true, "F": true, "FD": true}
+)
+
+// isAssertion reports whether an assertion starts at the current token.
+// As no command starts with two identifiers, assert is not reserved.
+func (p *parser) isAssertion() bool {
+	return p.tok() == IDENT && p.items[p.p].Lit == "assert" && p.peek(1) == IDENT
+}
+
+func (p *parser) parseAssertion() *Assertion {
+	a := &Assertion{Assert: p.next().Pos}
+	proc := p.expect(IDENT)
+	a.ProcPos, a.Proc = proc.Pos, proc.Lit
+	if p.tok() == COLON {
+		p.next()
+		p.expect(LBRACK)
+		pos, words := p.pos(), []string{p.expect(IDENT).Lit}
+		for p.tok() == IDENT {
+			words = append(words, p.next().Lit)
+		}
+		if a.Property = strings.Join(words, " "); !properties[a.Property] {
+			p.errorf(pos, "unknown property %s", a.Property)
+		}
+		p.expect(RBRACK)
+		return a
+	}
+	p.expect(LBRACK)
+	model := p.expect(IDENT)
+	if a.Model = model.Lit; !models[a.Model] {
+		p.errorf(model.Pos, "unknown model %s, expected T, F or FD", a.Model)
+	}
+	p.expect(EQ)
+	impl := p.expect(IDENT)
+	a.ImplPos, a.Impl = impl.Pos, impl.Lit
+	p.expect(RBRACK)
+	return a
+}
+
+func (p *parser) parseProc() *Proc {
+	proc := &Proc{}
+	if p.isLabel() {
+		name := p.next()
+		proc.Label = &ProcLabel{NamePos: name.Pos, Name: name.Lit}
+		if p.tok() == LPAREN {
+			p.next()
+			for {
+				if p.tok() == IDENT && p.peek(1) == COLON {
+					proc.Label.Subscripts = append(proc.Label.Subscripts, p.parseRange())
+				} else {
+					proc.Label.Subscripts = append(proc.Label.Subscripts, p.parseExpr())
+				}
+				if p.tok() != COMMA {
+					break
+				}
+				p.next()
+			}
+			p.expect(RPAREN)
+		}
+		p.expect(LABEL)
+	}
+	proc.Body = p.parseCmdList()
+	return proc
+}
+
+func (p *parser) parseRange() *Range {
+	v := p.expect(IDENT)
+	p.expect(COLON)
+	lo := p.parseExpr()
+	p.expect(RANGE)
+	hi := p.parseExpr()
+	return &Range{VarPos: v.Pos, Var: v.Lit, Lo: lo, Hi: hi}
+}
+
+// commands
+
+// endOfList reports whether the current token ends a command list.
+func (p *parser) endOfList() bool {
+	switch p.tok() {
+	case RBRACK, BOX, PAR, INTER, RPAREN, EOF:
+		return true
+	}
+	return false
+}
+
+func (p *parser) parseCmdList() *CmdList {
+	list := &CmdList{Start: p.pos()}
+	for {
+		if s := p.parseListedStmt(); s != nil {
+			list.Stmts = append(list.Stmts, s)
+		}
+		if p.tok() != SEMI {
+			return list
+		}
+		p.next()
+		// a command list may end with a semicolon.
+		if p.endOfList() {
+			return list
+		}
+	}
+}
+
+// parseListedStmt parses a command of a command list. After an error,
+// it skips to the end of the command and returns nil.
+func (p *parser) parseListedStmt() (s Stmt) {
+	defer func(start int) {
+		if p.resume(recover(), start, SEMI, RBRACK, BOX, PAR, INTER, RPAREN) {
+			s = nil
+		}
+	}(p.p)
+	if p.endOfList() {
+		p.errorExpected("command")
+	}
+	return p.parseStmt()
+}
+
+// isDeclaration reports whether a declaration starts at the current
+// token: x:integer, x,y:integer or content(0..n-1)integer.
+func (p *parser) isDeclaration() bool {
+	if p.tok() != IDENT {
+		return false
+	}
+	switch p.peek(1) {
+	case COLON, COMMA:
+		return true
+	case LPAREN:
+		end := p.closing(p.p + 1)
+		if end < 0 || end+1 >= len(p.items) || p.items[end+1].Tok != IDENT {
+			return false
+		}
+		for i := p.p + 2; i < end; i++ {
+			if p.items[i].Tok == RANGE {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (p *parser) parseStmt() Stmt {
+	switch p.tok() {
+	case SKIP:
+		return &SkipCmd{Skip: p.next().Pos}
+	case WAIT:
+		return &WaitCmd{Wait: p.next().Pos, Duration: p.parseExpr()}
+	case REP:
+		star := p.next().Pos
+		return &RepetitiveCmd{Star: star, Alt: p.parseAlternative(star)}
+	case LBRACK:
+		if p.isAlternative() {
+			return p.parseAlternative(p.next().Pos)
+		}
+		return p.parseParallel()
+	}
+	if p.isDeclaration() {
+		return p.parseDeclaration()
+	}
+	return p.parseSimpleCmd()
+}
+
+// isAlternative reports whether the bracket at the current token opens
+// an alternative command rather than a parallel command, i.e. whether
+// an arrow precedes the first || or ||| or the closing bracket.
+func (p *parser) isAlternative() bool {
+	depth := 0
+	for i := p.p + 1; i < len(p.items); i++ {
+		switch p.items[i].Tok {
+		case LBRACK, REP, LPAREN:
+			depth++
+		case RBRACK, RPAREN:
+			if depth == 0 {
+				return false
+			}
+			depth--
+		case PAR, INTER:
+			if depth == 0 {
+				return false
+			}
+		case ARROW:
+			if depth == 0 {
+				return true
+			}
+		case EOF:
+			return false
+		}
+	}
+	return false
+}
+
+func (p *parser) parseDeclaration() *Declaration {
+	d := &Declaration{}
+	for {
+		name := p.expect(IDENT)
+		d.Names = append(d.Names, &Ident{NamePos: name.Pos, Name: name.Lit})
+		if p.tok() != COMMA {
+			break
+		}
+		p.next()
+	}
+	// content(0..n-1)integer omits the colon.
+	if p.tok() == COLON {
+		p.next()
+	} else if p.tok() != LPAREN {
+		p.errorExpected(":")
+	}
+	d.Type = p.parseType()
+	return d
+}
+
+func (p *parser) parseType() Type {
+	if p.tok() == LPAREN {
+		lparen := p.next().Pos
+		lo := p.parseExpr()
+		p.expect(RANGE)
+		hi := p.parseExpr()
+		p.expect(RPAREN)
+		return &ArrayType{Lparen: lparen, Lo: lo, Hi: hi, Elem: p.parseType()}
+	}
+	name := p.expect(IDENT)
+	return &NamedType{NamePos: name.Pos, Name: name.Lit}
+}
+
+// parseSimpleCmd parses an assignment, input or output command, or a
+// process reference.
+func (p *parser) parseSimpleCmd() Cmd {
+	start := p.pos()
+	x := p.parseOperand()
+	switch p.tok() {
+	case ASSIGN:
+		p.next()
+		return &AssignmentCmd{Target: x, Value: p.parseExpr()}
+	case INPUT:
+		p.next()
+		return &InputCmd{Source: p.procName(x), Target: p.parseOperand()}
+	case OUTPUT:
+		p.next()
+		return &OutputCmd{Dest: p.procName(x), Value: p.parseExpr()}
+	}
+	if id, ok := x.(*Ident); ok {
+		ref := &ProcRef{NamePos: id.NamePos, Name: id.Name}
+		if p.tok() == LBRACK && p.peek(1) == LBRACK {
+			ref.Renames = p.parseRenames()
+		}
+		return ref
+	}
+	p.errorf(start, "expected command, found %v", p.items[p.p])
+	return nil
+}
+
+// parseRenames parses the renamings of a process reference, such as
+// [[west <- in, east <- out]].
+func (p *parser) parseRenames() []*Renaming {
+	p.expect(LBRACK)
+	p.expect(LBRACK)
+	var renames []*Renaming
+	renamed := map[string]bool{}
+	for {
+		from := p.expect(IDENT)
+		p.expect(LT)
+		p.expect(SUB)
+		to := p.expect(IDENT)
+		if renamed[from.Lit] {
+			p.errorf(from.Pos, "%s renamed more than once", from.Lit)
+		}
+		renamed[from.Lit] = true
+		renames = append(renames, &Renaming{From: &Ident{NamePos: from.Pos, Name: from.Lit}, To: &Ident{NamePos: to.Pos, Name: to.Lit}})
+		if p.tok() != COMMA {
+			break
+		}
+		p.next()
+	}
+	p.expect(RBRACK)
+	p.expect(RBRACK)
+	return renames
+}
+
+// procName converts the operand preceding ? or ! to a process name.
+func (p *parser) procName(x Expr) *ProcName {
+	switch x := x.(type) {
+	case *Ident:
+		return &ProcName{NamePos: x.NamePos, Name: x.Name}
+	case *StructuredExpr:
+		if x.Constructor != "" {
+			return &ProcName{NamePos: x.Start, Name: x.Constructor, Subscripts: x.Args}
+		}
+	}
+	p.errorf(x.Pos(), "expected process name")
+	return nil
+}
+
+func (p *parser) parseParallel() *ParallelCmd {
+	par := &ParallelCmd{Lbrack: p.expect(LBRACK).Pos}
+	p.parseProcs(par)
+	p.expect(RBRACK)
+	return par
+}
+
+// parseProcs parses the processes of par, separated by || or, for an
+// interleaving, by |||.
+func (p *parser) parseProcs(par *ParallelCmd) {
+	par.Procs = append(par.Procs, p.parseProc())
+	for p.tok() == PAR || p.tok() == INTER {
+		inter := p.tok() == INTER
+		if len(par.Procs) == 1 {
+			par.Interleave = inter
+		} else if inter != par.Interleave {
+			p.errorf(p.pos(), "|| and ||| mixed in a parallel command")
+		}
+		p.next()
+		par.Procs = append(par.Procs, p.parseProc())
+	}
+}
+
+// parseAlternative parses the guarded commands of an alternative
+// command following its opening bracket at lbrack, and its closing
+// bracket.
+func (p *parser) parseAlternative(lbrack Pos) *AlternativeCmd {
+	alt := &AlternativeCmd{Lbrack: lbrack}
+	for {
+		if gc := p.parseListedGuardedCmd(); gc != nil {
+			alt.Cmds = append(alt.Cmds, gc)
+		}
+		if p.tok() != BOX {
+			break
+		}
+		p.next()
+	}
+	p.expect(RBRACK)
+	return alt
+}
+
+// parseListedGuardedCmd parses a guarded command of an alternative
+// command. After an error, it skips to the end of the guarded command
+// and returns nil.
+func (p *parser) parseListedGuardedCmd() (gc *GuardedCmd) {
+	defer func(start int) {
+		if p.resume(recover(), start, RBRACK, BOX, PAR, INTER, RPAREN) {
+			gc = nil
+		}
+	}(p.p)
+	return p.parseGuardedCmd()
+}
+
+func (p *parser) parseGuardedCmd() *GuardedCmd {
+	gc := &GuardedCmd{}
+	if p.tok() == LPAREN && p.peek(1) == IDENT && p.peek(2) == COLON {
+		p.next()
+		for {
+			gc.Ranges = append(gc.Ranges, p.parseRange())
+			if p.tok() != COMMA {
+				break
+			}
+			p.next()
+		}
+		p.expect(RPAREN)
+	}
+	gc.Guard = p.parseGuard()
+	p.expect(ARROW)
+	gc.Body = p.parseCmdList()
+	return gc
+}
+
+func (p *parser) parseGuard() *Guard {
+	g := &Guard{Start: p.pos()}
+	for {
+		switch p.tok() {
+		case TIMEOUT:
+			g.Timeout = &Timeout{Timeout: p.next().Pos, Duration: p.parseExpr()}
+			return g
+		case PROB:
+			g.Prob = &Prob{Prob: p.next().Pos, Weight: p.parseExpr()}
+			return g
+		}
+		if p.isDeclaration() {
+			g.List = append(g.List, p.parseDeclaration())
+		} else {
+			x := p.parseExpr()
+			if p.tok() == INPUT {
+				p.next()
+				g.Input = &InputCmd{Source: p.procName(x), Target: p.parseOperand()}
+				return g
+			}
+			g.List = append(g.List, x)
+		}
+		if p.tok() != SEMI {
+			return g
+		}
+		p.next()
+	}
+}
+
+// expressions
+
+// binary operators by precedence, from lowest to highest.
+var precedence = map[Token]int{
+	OR:  1,
+	AND: 2,
+	EQ:  4, NEQ: 4, LT: 4, LEQ: 4, GT: 4, GEQ: 4,
+	ADD: 5, SUB: 5,
+	MUL: 6, DIV: 6, MOD: 6,
+}
+
+func (p *parser) parseExpr() Expr {
+	return p.parseBinary(1)
+}
+
+func (p *parser) parseBinary(prec int) Expr {
+	x := p.parseUnary()
+	for {
+		op := p.tok()
+		oprec, ok := precedence[op]
+		if !ok || oprec < prec {
+			return x
+		}
+		opPos := p.next().Pos
+		y := p.parseBinary(oprec + 1)
+		x = &BinaryExpr{X: x, OpPos: opPos, Op: op, Y: y}
+	}
+}
+
+func (p *parser) parseUnary() Expr {
+	switch p.tok() {
+	case NOT:
+		pos := p.next().Pos
+		// ¬ binds weaker than comparisons: ¬ x = y is ¬(x = y).
+		return &UnaryExpr{OpPos: pos, Op: NOT, X: p.parseBinary(precedence[EQ])}
+	case SUB:
+		pos := p.next().Pos
+		return &UnaryExpr{OpPos: pos, Op: SUB, X: p.parseUnary()}
+	}
+	return p.parseOperand()
+}
+
+// parseOperand parses a literal, a variable, or a structured
+// expression.
+func (p *parser) parseOperand() Expr {
+	it := p.item(0)
+	switch it.Tok {
+	case INT:
+		p.next()
+		v, err := strconv.Atoi(it.Lit)
+		if err != nil {
+			p.errorf(it.Pos, "invalid integer %s", it.Lit)
+		}
+		return &IntLit{ValuePos: it.Pos, Value: v}
+	case CHAR:
+		p.next()
+		return &CharLit{ValuePos: it.Pos, Value: []rune(it.Lit)[0]}
+	case STRING:
+		p.next()
+		return &StringLit{ValuePos: it.Pos, Value: it.Lit}
+	case IDENT:
+		p.next()
+		if p.tok() != LPAREN {
+			return &Ident{NamePos: it.Pos, Name: it.Lit}
+		}
+		return &StructuredExpr{Start: it.Pos, Constructor: it.Lit, Args: p.parseArgs()}
+	case LPAREN:
+		args := p.parseArgs()
+		if len(args) == 1 && p.items[p.p-2].Tok != COMMA {
+			return &ParenExpr{Lparen: it.Pos, X: args[0]}
+		}
+		return &StructuredExpr{Start: it.Pos, Args: args}
+	}
+	p.errorExpected("expression")
+	return nil
+}
+
+// parseArgs parses a parenthesized expression list.
+func (p *parser) parseArgs() []Expr {
+	p.expect(LPAREN)
+	args := []Expr{}
+	for p.tok() != RPAREN {
+		args = append(args, p.parseExpr())
+		if p.tok() != COMMA {
+			break
+		}
+		p.next()
+	}
+	p.expect(RPAREN)
+	return args
+}
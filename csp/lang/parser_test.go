@@ -0,0 +1,279 @@
+package lang_test
+
+import (
+	"bytes"
+	"flag"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/changkun/gobase/csp/lang"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+// quoted returns the programs quoted after "Solution:" in the doc
+// comments of the functions of package csp, by function.
+func quoted(t *testing.T) map[string]string {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "../csp.go", nil, parser.ParseComments)
+	if err != nil {
+		t.Fatalf("%v: cannot parse csp.go: %v", t.Name(), err)
+	}
+
+	progs := map[string]string{}
+	for _, decl := range f.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Doc == nil {
+			continue
+		}
+		lines := strings.Split(fn.Doc.Text(), "\n")
+		prog := []string{}
+		for i, l := range lines {
+			if l != "Solution:" {
+				continue
+			}
+			for _, l := range lines[i+2:] {
+				if !strings.HasPrefix(l, "  ") {
+					break
+				}
+				prog = append(prog, strings.TrimPrefix(l, "  "))
+			}
+		}
+		progs[fn.Name.Name] = strings.Join(prog, "\n")
+	}
+	return progs
+}
+
+// solutions returns the programs quoted by the given functions of
+// package csp. The notation does not accept some of the programs as
+// quoted from the paper, such as [west::DISASSEMBLE||X:COPY||east::ASSEMBLE]
+// of S35_Reformat, for which testdata holds the program corrected, as
+// name.csp, instead.
+func solutions(t *testing.T, funcs ...string) map[string]string {
+	progs := quoted(t)
+	sols := map[string]string{}
+	for _, name := range funcs {
+		if progs[name] == "" {
+			t.Fatalf("%v: no solution quoted by %v", t.Name(), name)
+		}
+		sols[name] = progs[name]
+		src, err := os.ReadFile(filepath.Join("testdata", name+".csp"))
+		if err == nil {
+			sols[name] = strings.TrimSuffix(string(src), "\n")
+		} else if !os.IsNotExist(err) {
+			t.Fatalf("%v: %v", t.Name(), err)
+		}
+	}
+	return sols
+}
+
+func TestParseSection3(t *testing.T) {
+	progs := solutions(t, "S31_COPY", "S32_SQUASH", "S32_SQUASH_EX",
+		"S33_DISASSEMBLE", "S34_ASSEMBLE", "S35_Reformat", "S36_ConwayProblem")
+
+	for name, src := range progs {
+		prog, err := lang.Parse(src)
+		if err != nil {
+			t.Fatalf("%v: %v: %v", t.Name(), name, err)
+		}
+		buf := bytes.Buffer{}
+		if err := lang.Fprint(&buf, prog); err != nil {
+			t.Fatalf("%v: %v: %v", t.Name(), name, err)
+		}
+
+		golden := filepath.Join("testdata", name+".golden")
+		if *update {
+			if err := os.WriteFile(golden, buf.Bytes(), 0644); err != nil {
+				t.Fatalf("%v: %v", t.Name(), err)
+			}
+			continue
+		}
+		want, err := os.ReadFile(golden)
+		if err != nil {
+			t.Fatalf("%v: %v", t.Name(), err)
+		}
+		if buf.String() != string(want) {
+			t.Fatalf("%v: %v: expected:\n%s\ngot:\n%s", t.Name(), name, want, buf.String())
+		}
+	}
+}
+
+func TestParseQuoted(t *testing.T) {
+	// the programs of the paper corrected by testdata, which the
+	// notation does not accept as quoted: X:COPY declares a variable.
+	tests := map[string]string{
+		"S32_SQUASH_EX":   "6:18: expected →, found !",
+		"S33_DISASSEMBLE": "4:5: expected ], found X",
+		"S34_ASSEMBLE":    "2:10: expected EOF, found ,",
+		"S35_Reformat":    "1:23: undefined type COPY",
+	}
+	progs := quoted(t)
+	for name, want := range tests {
+		if _, err := os.Stat(filepath.Join("testdata", name+".csp")); err != nil {
+			t.Fatalf("%v: %v", t.Name(), err)
+		}
+		prog, err := lang.Parse(progs[name])
+		if err == nil {
+			err = lang.Check(prog)
+		}
+		var got []string
+		if err != nil {
+			for _, e := range err.(lang.ErrorList) {
+				got = append(got, e.Error())
+			}
+		}
+		if !slices.Contains(got, want) {
+			t.Fatalf("%v: %v: expected: %v, got: %v", t.Name(), name, want, got)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []string{
+		// Section 4.1
+		`[DIV::*[x,y:integer; X?(x,y)->
+		     quot,rem:integer; quot := 0; rem := x;
+		     *[rem >= y -> rem := rem - y; quot := quot + 1;];
+		     X!(quot,rem)
+		   ]
+		||X::USER]`,
+		// Section 4.2
+		`[fac(i:1..limit)::
+		 *[n:integer;fac(i-1)?n ->
+		   [n=0 -> fac(i-1)!1
+		   □ n>0 -> fac(i+1)!n-1;
+		     r:integer; fac(i+1)?r; fac(i-1)!(n*r)
+		 ]] || fac(0)::USER ]`,
+		// Section 4.3
+		`S::
+		content(0..n-1)integer; size:integer; size := 0;
+		*[n:integer; X?has(n) -> SEARCH; X!(i<size)
+		□ n:integer; X?insert(n) -> SEARCH;
+		      [i<size -> skip
+		      □i = size; size<n ->
+		         content(size) := n; size := size+1
+		]]`,
+		// Section 5.2
+		`S::val:integer; val:=0;
+		*[(i:1..100)X(i)?V()->val:=val+1
+		□ (i:1..100)val>0;X(i)?P()->val:=val-1]`,
+		// definitions
+		`COPY = (*[c:character; west?c -> east!c])
+		[X::COPY || Y::(x, y) := (y, x); print!"Hello, CSP"; print!-x mod 3 ≠ 1 ∧ ¬true]`,
+		// renaming
+		`COPY = (*[c:character; west?c -> east!c])
+		[X::COPY[[east <- Y]] || Y::COPY[[west<-X, east<-out]]]`,
+		// interleaving
+		`COPY = (*[c:character; west?c -> east!c])
+		X::COPY ||| Y::[Z::COPY ||| W::COPY]`,
+	}
+	for _, src := range tests {
+		if _, err := lang.Parse(src); err != nil {
+			t.Fatalf("%v: %v\n%v", t.Name(), err, src)
+		}
+	}
+}
+
+func TestParseStructure(t *testing.T) {
+	prog, err := lang.Parse("[X::*[c:character; west?c -> east!c] || Y(i:1..3)::skip]")
+	if err != nil {
+		t.Fatalf("%v: unexpected error: %v", t.Name(), err)
+	}
+	par, ok := prog.Body.Stmts[0].(*lang.ParallelCmd)
+	if !ok || len(par.Procs) != 2 {
+		t.Fatalf("%v: expected parallel command of two processes, got: %#v", t.Name(), prog.Body.Stmts[0])
+	}
+	rep, ok := par.Procs[0].Body.Stmts[0].(*lang.RepetitiveCmd)
+	if !ok {
+		t.Fatalf("%v: expected repetitive command, got: %#v", t.Name(), par.Procs[0].Body.Stmts[0])
+	}
+	g := rep.Alt.Cmds[0].Guard
+	if len(g.List) != 1 || g.Input == nil || g.Input.Source.Name != "west" {
+		t.Fatalf("%v: expected guard c:character; west?c, got: %#v", t.Name(), g)
+	}
+	if got := g.Input.Pos().String(); got != "1:20" {
+		t.Fatalf("%v: expected position 1:20, got: %v", t.Name(), got)
+	}
+	r, ok := par.Procs[1].Label.Subscripts[0].(*lang.Range)
+	if !ok || r.Var != "i" {
+		t.Fatalf("%v: expected range subscript, got: %#v", t.Name(), par.Procs[1].Label.Subscripts[0])
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		src, want string
+	}{
+		{src: "X::*[c:character; west?c -> east!c", want: "1:35: expected ], found EOF"},
+		{src: "x := ", want: "1:6: expected expression, found EOF"},
+		{src: "[x > 1 -> skip □ y]", want: "1:19: expected →, found ]"},
+		{src: "[X:: skip || ]", want: "1:14: expected command, found ]"},
+		{src: "x := 1 | 2", want: `1:8: unexpected "|"`},
+		{src: "x := ;\ny := 1;\n[y > 0 → skip □ y]", want: "1:6: expected expression, found ; (and 1 more errors)"},
+		{src: "X = (x := )\nY = (skip)\nZ = (y !)", want: "1:11: expected expression, found ) (and 1 more errors)"},
+		{src: "P = (skip)\nassert P :[livelock free]", want: "2:12: unknown property livelock free"},
+		{src: "P = (skip)\nassert P [R= P]", want: "2:11: unknown model R, expected T, F or FD"},
+		{src: "P = (skip) \\ (log,)", want: "1:19: expected IDENT, found )"},
+		{src: "P = (skip)\nP[[a <- b, a <- c]]", want: "2:12: a renamed more than once"},
+		{src: "P = (skip)\nP[[a <= b]]", want: "2:6: expected <, found ≤"},
+		{src: "[X::skip || Y::skip ||| Z::skip]", want: "1:21: || and ||| mixed in a parallel command"},
+	}
+	for _, tt := range tests {
+		_, err := lang.Parse(tt.src)
+		if err == nil || err.Error() != tt.want {
+			t.Fatalf("%v: %q: expected: %v, got: %v", t.Name(), tt.src, tt.want, err)
+		}
+	}
+}
+
+func TestParseRecovery(t *testing.T) {
+	src := `X::*[c:character; west?c → east!]
+|| Y::*[
+  c:character; east?c → skip
+□ c > → skip
+]; z := (1 + )
+|| Z::[x := 1 → skip]`
+	_, err := lang.Parse(src)
+	list, ok := err.(lang.ErrorList)
+	if !ok {
+		t.Fatalf("%v: expected an error list, got: %v", t.Name(), err)
+	}
+	want := []string{
+		"1:33: expected expression, found ]",
+		"4:7: expected expression, found →",
+		"5:14: expected expression, found )",
+		"6:10: expected →, found :=",
+	}
+	var got []string
+	for _, e := range list {
+		got = append(got, e.Error())
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("%v: expected:\n%v\ngot:\n%v", t.Name(), strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+}
+
+func TestPrintError(t *testing.T) {
+	src := "X::*[c:character;\n\twest?c\n]"
+	_, err := lang.Parse(src)
+	buf := bytes.Buffer{}
+	lang.PrintError(&buf, "prog.csp", src, err)
+	want := "prog.csp:3:1: expected →, found ]\n\t]\n\t^\n"
+	if buf.String() != want {
+		t.Fatalf("%v: expected: %q, got: %q", t.Name(), want, buf.String())
+	}
+	src = "x := 1 +\n\ty := 2"
+	_, err = lang.Parse(src)
+	buf.Reset()
+	lang.PrintError(&buf, "", src, err)
+	want = "2:4: expected EOF, found :=\n\t\ty := 2\n\t\t  ^\n"
+	if buf.String() != want {
+		t.Fatalf("%v: expected: %q, got: %q", t.Name(), want, buf.String())
+	}
+}
@@ -0,0 +1,700 @@
+// Package pat exports programs of package lang as models in CSP#, the
+// language of the model checker PAT, such that PAT verifies them.
+//
+// The process COPY
+//
+//   X :: *[c:character; west?c -> east!c]
+//
+// becomes
+//
+//   channel west 0;
+//   channel east 0;
+//   var c = 0;
+//
+//   X() = X_1();
+//   X_1() = west?c_in -> tau{c = c_in} -> east!c -> X_1();
+//   SYSTEM() = X();
+//
+// CSP# is close to the notation: processes are defined by events and
+// choices among them, communicate on synchronous channels, and update
+// variables by the programs of events. Every definition and every
+// process of the parallel command of a program is a process of the
+// model, and the program a process of its own, named by cfg.Main, the
+// parallel composition of the processes of its parallel command, or
+// for an interleaving their interleaving. The
+// assertions of the program are the assertions of the model.
+//
+// Processes communicate on channels named by the communicating
+// processes, such as X_Y for the values X outputs to Y, and by the
+// constructors of structured values, such as X_Y_has for has(n) whose
+// components are the compound values of the channel. A communication
+// without values is an event the processes synchronize on. An external
+// name is a channel named by itself, whose other end is left to an
+// environment the model is completed with. Characters are integers,
+// the code points of the characters, and variables are variables of
+// the model, renamed if declared more than once. An input binds the
+// input values to names of their own, such as c_in, which the variables
+// are assigned by an internal event.
+//
+// An alternative command becomes a choice among its guarded commands,
+// of which those without input are chosen internally, and a repetitive
+// command a recursive process, which terminates once all of its
+// boolean guards are false, unless it has guards without boolean
+// conditions. CSP# has no failure of processes and no termination of
+// processes for each other: an alternative command whose guards are
+// false deadlocks, and an input guard waits for its source forever.
+// Arrays, bound variables, nested parallel commands, recursive
+// definitions, hiding, renaming, wait commands, timeouts and
+// probabilistic choices are not supported.
+package pat
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/changkun/gobase/csp/lang"
+)
+
+// Config configures an exported model.
+type Config struct {
+	// Main is the name of the process of the program. The default is
+	// SYSTEM.
+	Main string
+}
+
+// Export returns the CSP# model of prog. A program without a body, such
+// as the descriptions of package extract, is exported as a process for
+// each of its definitions.
+func Export(prog *lang.Program, cfg Config) (src []byte, err error) {
+	if cfg.Main == "" {
+		cfg.Main = "SYSTEM"
+	}
+	x := &exporter{
+		cfg:   cfg,
+		defs:  map[string]*lang.Definition{},
+		names: map[string]bool{},
+		procs: map[string]string{},
+		chans: map[string]*channel{},
+	}
+	for _, d := range prog.Defs {
+		x.defs[d.Name] = d
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			f, ok := r.(failure)
+			if !ok {
+				panic(r)
+			}
+			src, err = nil, f.err
+		}
+	}()
+	x.program(prog)
+	return x.source(), nil
+}
+
+// failure is the error of an export, raised by errorf.
+type failure struct{ err *lang.Error }
+
+func errorf(pos lang.Pos, format string, args ...interface{}) {
+	panic(failure{&lang.Error{Pos: pos, Msg: fmt.Sprintf(format, args...)}})
+}
+
+// exporter exports a program.
+type exporter struct {
+	cfg     Config
+	defs    map[string]*lang.Definition
+	names   map[string]bool   // names of the channels, variables and processes
+	procs   map[string]string // the names of the processes of the definitions
+	chans   map[string]*channel
+	order   []*channel
+	vars    []string
+	bodies  []*definition
+	asserts []string
+}
+
+// channel is a channel of the model.
+type channel struct {
+	name   string
+	fields []string // the types of the fields, none of an event
+}
+
+// definition is the definition of a process of the model.
+type definition struct {
+	name string
+	expr string
+}
+
+// process is a process in translation.
+type process struct {
+	label     string
+	name      string
+	peers     map[string]bool // the labels of the processes of the parallel command
+	scope     *scope
+	helpers   int             // the number of helper processes of the process
+	expanding map[string]bool // the definitions expanded in place
+}
+
+// scope is a scope of variables.
+type scope struct {
+	outer *scope
+	vars  map[string]*variable
+}
+
+// variable is a variable of the model.
+type variable struct {
+	name string
+	in   string // the name input values are bound to
+	typ  string
+}
+
+func (p *process) lookup(name string) *variable {
+	for s := p.scope; s != nil; s = s.outer {
+		if v, ok := s.vars[name]; ok {
+			return v
+		}
+	}
+	return nil
+}
+
+func (x *exporter) declare(p *process, id *lang.Ident, t lang.Type) {
+	nt, ok := t.(*lang.NamedType)
+	if !ok {
+		errorf(t.Pos(), "arrays are not supported")
+	}
+	if _, ok := p.scope.vars[id.Name]; ok {
+		errorf(id.Pos(), "%s redeclared", id.Name)
+	}
+	init, ok := zeros[nt.Name]
+	if !ok {
+		errorf(nt.Pos(), "undefined type %s", nt.Name)
+	}
+	v := &variable{name: x.unique(id.Name), typ: nt.Name}
+	v.in = x.unique(v.name + "_in")
+	p.scope.vars[id.Name] = v
+	x.vars = append(x.vars, "var "+v.name+" = "+init+";")
+}
+
+// zeros are the initial values of the variables of the types of the
+// notation.
+var zeros = map[string]string{
+	"integer":   "0",
+	"boolean":   "false",
+	"character": "0",
+}
+
+// keywords are the keywords and predefined names of CSP# which are
+// valid identifiers of the notation.
+var keywords = map[string]bool{
+	"Skip": true, "Stop": true, "atomic": true, "call": true, "case": true,
+	"channel": true, "default": true, "define": true, "else": true,
+	"false": true, "hvar": true, "if": true, "ifa": true, "ifb": true,
+	"new": true, "tau": true, "true": true, "var": true, "while": true,
+}
+
+// ident returns the CSP# identifier of the identifier s.
+func ident(s string) string {
+	s = strings.ReplaceAll(s, " ", "_")
+	if keywords[s] {
+		return s + "_"
+	}
+	return s
+}
+
+// unique returns a name of the model based on s.
+func (x *exporter) unique(s string) string {
+	name := ident(s)
+	for n := 2; x.names[name]; n++ {
+		name = ident(s) + "_" + strconv.Itoa(n)
+	}
+	x.names[name] = true
+	return name
+}
+
+func (x *exporter) program(prog *lang.Program) {
+	x.names[x.cfg.Main] = true
+	for _, d := range prog.Defs {
+		x.procs[d.Name] = x.unique(d.Name)
+	}
+	body := prog.Body
+	var par *lang.ParallelCmd
+	if len(body.Stmts) == 1 {
+		par, _ = body.Stmts[0].(*lang.ParallelCmd)
+	}
+	labels := map[string]string{}
+	if par != nil {
+		for _, p := range par.Procs {
+			if p.Label == nil {
+				errorf(p.Pos(), "processes without labels are not supported")
+			}
+			if len(p.Label.Subscripts) > 0 {
+				errorf(p.Label.Pos(), "arrays of processes are not supported")
+			}
+			if _, ok := labels[p.Label.Name]; ok {
+				errorf(p.Label.Pos(), "duplicate process label %s", p.Label.Name)
+			}
+			labels[p.Label.Name] = x.unique(p.Label.Name)
+		}
+	}
+
+	for _, d := range prog.Defs {
+		if len(d.Hidden) > 0 {
+			errorf(d.Hidden[0].Pos(), "hiding is not supported")
+		}
+		x.process(x.procs[d.Name], d.Name, nil, d.Body)
+	}
+	for _, a := range prog.Asserts {
+		x.assertion(a)
+	}
+	switch {
+	case len(body.Stmts) == 0:
+	case par == nil:
+		x.process(x.cfg.Main, x.cfg.Main, nil, body)
+	default:
+		peers := map[string]bool{}
+		for label := range labels {
+			if !par.Interleave {
+				peers[label] = true
+			}
+		}
+		var calls []string
+		for _, p := range par.Procs {
+			name := labels[p.Label.Name]
+			x.process(name, p.Label.Name, peers, p.Body)
+			calls = append(calls, name+"()")
+		}
+		sep := " || "
+		if par.Interleave {
+			sep = " ||| "
+		}
+		x.bodies = append(x.bodies, &definition{name: x.cfg.Main, expr: strings.Join(calls, sep)})
+	}
+}
+
+// process translates the process label named name, which communicates
+// with the processes labelled by peers.
+func (x *exporter) process(name, label string, peers map[string]bool, cmds *lang.CmdList) {
+	p := &process{
+		label:     label,
+		name:      name,
+		peers:     peers,
+		scope:     &scope{vars: map[string]*variable{}},
+		expanding: map[string]bool{},
+	}
+	b := &definition{name: name}
+	x.bodies = append(x.bodies, b)
+	b.expr = x.list(p, cmds.Stmts, func() string { return "Skip" })
+}
+
+// properties are the CSP# assertions of the properties of the
+// notation, models those of its refinements.
+var (
+	properties = map[string]string{"deadlock free": "deadlockfree", "divergence free": "divergencefree", "deterministic": "deterministic"}
+	models     = map[string]string{"T": "refines", "F": "refines<F>", "FD": "refines<FD>"}
+)
+
+func (x *exporter) assertion(a *lang.Assertion) {
+	proc, ok := x.procs[a.Proc]
+	if !ok {
+		errorf(a.ProcPos, "undefined process %s", a.Proc)
+	}
+	if a.Property != "" {
+		x.asserts = append(x.asserts, fmt.Sprintf("#assert %s() %s;", proc, properties[a.Property]))
+		return
+	}
+	impl, ok := x.procs[a.Impl]
+	if !ok {
+		errorf(a.ImplPos, "undefined process %s", a.Impl)
+	}
+	x.asserts = append(x.asserts, fmt.Sprintf("#assert %s() %s %s();", impl, models[a.Model], proc))
+}
+
+// list returns the process of stmts followed by the process k
+// returns, which it calls once the processes of stmts are translated,
+// such that helper processes are numbered in order.
+func (x *exporter) list(p *process, stmts []lang.Stmt, k func() string) string {
+	if len(stmts) == 0 {
+		return k()
+	}
+	return x.stmt(p, stmts[0], func() string { return x.list(p, stmts[1:], k) })
+}
+
+// helper defines a helper process of p, the process expr returns, and
+// returns its call.
+func (x *exporter) helper(p *process, expr func(call string) string) string {
+	p.helpers++
+	b := &definition{name: x.unique(p.name + "_" + strconv.Itoa(p.helpers))}
+	x.bodies = append(x.bodies, b)
+	call := b.name + "()"
+	b.expr = expr(call)
+	return call
+}
+
+// calls matches the calls of processes.
+var calls = regexp.MustCompile(`^\w+\(\)$`)
+
+// continuation returns a call of the process k returns, defined by a
+// helper process unless it is a call already.
+func (x *exporter) continuation(p *process, k func() string) string {
+	next := k()
+	if next == "Skip" || calls.MatchString(next) {
+		return next
+	}
+	return x.helper(p, func(string) string { return next })
+}
+
+// stmt returns the process of s followed by the process k returns.
+func (x *exporter) stmt(p *process, s lang.Stmt, k func() string) string {
+	switch s := s.(type) {
+	case *lang.Declaration:
+		for _, id := range s.Names {
+			x.declare(p, id, s.Type)
+		}
+		return k()
+	case *lang.SkipCmd:
+		return k()
+	case *lang.ProcRef:
+		d, ok := x.defs[s.Name]
+		if !ok {
+			errorf(s.Pos(), "undefined process %s", s.Name)
+		}
+		if len(s.Renames) > 0 {
+			errorf(s.Renames[0].Pos(), "renaming is not supported")
+		}
+		if p.expanding[s.Name] {
+			errorf(s.Pos(), "recursive process %s is not supported", s.Name)
+		}
+		// the process of the definition ends in its scope, unless it
+		// never terminates.
+		outer := p.scope
+		done := func() {
+			delete(p.expanding, s.Name)
+			p.scope = outer
+		}
+		p.expanding[s.Name] = true
+		p.scope = &scope{outer: outer, vars: map[string]*variable{}}
+		defer done()
+		return x.list(p, d.Body.Stmts, func() string {
+			done()
+			return k()
+		})
+	case *lang.AssignmentCmd:
+		id, ok := s.Target.(*lang.Ident)
+		if !ok {
+			errorf(s.Target.Pos(), "structured values are not supported")
+		}
+		v := p.lookup(id.Name)
+		if v == nil {
+			errorf(id.Pos(), "undefined variable %s", id.Name)
+		}
+		if typ := x.typeOf(p, s.Value); typ != v.typ {
+			errorf(s.Value.Pos(), "cannot assign %s to %s of type %s", typ, id.Name, v.typ)
+		}
+		return "tau{" + v.name + " = " + x.expr(p, s.Value) + "} -> " + k()
+	case *lang.InputCmd:
+		return x.input(p, s) + " -> " + k()
+	case *lang.OutputCmd:
+		c, args := x.port(p, s.Dest, s.Value, false)
+		if len(args) == 0 {
+			return c.name + " -> " + k()
+		}
+		values := make([]string, len(args))
+		for i, a := range args {
+			values[i] = x.operand(p, a, len(precedence))
+		}
+		return c.name + "!" + strings.Join(values, ".") + " -> " + k()
+	case *lang.AlternativeCmd:
+		next := x.continuation(p, k)
+		choice, _ := x.choice(p, s, next)
+		return choice
+	case *lang.RepetitiveCmd:
+		return x.helper(p, func(call string) string {
+			choice, cond := x.choice(p, s.Alt, call)
+			if cond == "" {
+				return choice
+			}
+			return "if (" + cond + ") { " + choice + " } else { " + k() + " }"
+		})
+	case *lang.WaitCmd:
+		errorf(s.Pos(), "wait commands are not supported")
+	case *lang.ParallelCmd:
+		errorf(s.Pos(), "nested parallel commands are not supported")
+	}
+	errorf(s.Pos(), "unexpected %T", s)
+	return ""
+}
+
+// choice returns the choice among the guarded commands of alt, each
+// followed by the process next, and the condition of alt as a
+// repetitive command, empty if it never terminates.
+func (x *exporter) choice(p *process, alt *lang.AlternativeCmd, next string) (choice, cond string) {
+	var branches, conds []string
+	for _, gc := range alt.Cmds {
+		if len(gc.Ranges) > 0 {
+			errorf(gc.Ranges[0].Pos(), "bound variables are not supported")
+		}
+		if gc.Guard.Timeout != nil {
+			errorf(gc.Guard.Timeout.Pos(), "timeouts are not supported")
+		}
+		if gc.Guard.Prob != nil {
+			errorf(gc.Guard.Prob.Pos(), "probabilistic choices are not supported")
+		}
+		p.scope = &scope{outer: p.scope, vars: map[string]*variable{}}
+		c := x.cond(p, gc.Guard)
+		var prefix string
+		switch {
+		case gc.Guard.Input != nil:
+			prefix = x.input(p, gc.Guard.Input) + " -> "
+		case len(alt.Cmds) > 1:
+			prefix = "tau -> "
+		}
+		branch := prefix + x.list(p, gc.Body.Stmts, func() string { return next })
+		if c != nil {
+			conds = append(conds, x.operand(p, c, precedence[lang.OR]))
+			branch = "[" + x.expr(p, c) + "](" + branch + ")"
+		}
+		branches = append(branches, branch)
+		p.scope = p.scope.outer
+	}
+	choice = strings.Join(branches, " [] ")
+	if len(branches) > 1 {
+		choice = "(" + choice + ")"
+	}
+	if len(conds) < len(alt.Cmds) {
+		return choice, ""
+	}
+	return choice, strings.Join(conds, " || ")
+}
+
+// cond returns the conjunction of the boolean guards of g, nil if
+// they are true, and declares the variables of g.
+func (x *exporter) cond(p *process, g *lang.Guard) lang.Expr {
+	var cond lang.Expr
+	for _, n := range g.List {
+		switch n := n.(type) {
+		case *lang.Declaration:
+			for _, id := range n.Names {
+				x.declare(p, id, n.Type)
+			}
+		case lang.Expr:
+			if typ := x.typeOf(p, n); typ != "boolean" {
+				errorf(n.Pos(), "guard of type %s", typ)
+			}
+			if id, ok := n.(*lang.Ident); ok && id.Name == "true" {
+				continue
+			}
+			if cond == nil {
+				cond = n
+			} else {
+				cond = &lang.BinaryExpr{X: cond, Op: lang.AND, Y: n}
+			}
+		}
+	}
+	return cond
+}
+
+// input returns the input s, which binds its values and assigns them
+// to their variables.
+func (x *exporter) input(p *process, s *lang.InputCmd) string {
+	c, args := x.port(p, s.Source, s.Target, true)
+	if len(args) == 0 {
+		return c.name
+	}
+	ins := make([]string, len(args))
+	assigns := make([]string, len(args))
+	for i, a := range args {
+		v := p.lookup(a.(*lang.Ident).Name)
+		ins[i], assigns[i] = v.in, v.name+" = "+v.in
+	}
+	return c.name + "?" + strings.Join(ins, ".") + " -> tau{" + strings.Join(assigns, "; ") + "}"
+}
+
+// port returns the channel of the communication of the value v with
+// the process n, and the fields of its messages.
+func (x *exporter) port(p *process, n *lang.ProcName, v lang.Expr, input bool) (*channel, []lang.Expr) {
+	if len(n.Subscripts) > 0 {
+		errorf(n.Pos(), "arrays of processes are not supported")
+	}
+	if n.Name == p.label {
+		errorf(n.Pos(), "process %s communicates with itself", n.Name)
+	}
+	cons, args := "", []lang.Expr{v}
+	if s, ok := v.(*lang.StructuredExpr); ok {
+		if p.lookup(s.Constructor) != nil {
+			errorf(s.Pos(), "arrays are not supported")
+		}
+		cons, args = s.Constructor, s.Args
+	}
+	fields := make([]string, len(args))
+	for i, a := range args {
+		if !input {
+			fields[i] = x.typeOf(p, a)
+			continue
+		}
+		id, ok := a.(*lang.Ident)
+		if !ok {
+			errorf(a.Pos(), "structured values are not supported")
+		}
+		v := p.lookup(id.Name)
+		if v == nil {
+			errorf(id.Pos(), "undefined variable %s", id.Name)
+		}
+		fields[i] = v.typ
+	}
+
+	name, key := n.Name, "|"+n.Name+"|"+cons
+	if p.peers[n.Name] {
+		src, dst := p.label, n.Name
+		if input {
+			src, dst = n.Name, p.label
+		}
+		name, key = src+"_"+dst, src+"|"+dst+"|"+cons
+	}
+	if cons != "" {
+		name += "_" + cons
+	}
+	c, ok := x.chans[key]
+	if !ok {
+		c = &channel{name: x.unique(name), fields: fields}
+		x.chans[key] = c
+		x.order = append(x.order, c)
+	}
+	if strings.Join(c.fields, ".") != strings.Join(fields, ".") {
+		errorf(v.Pos(), "channel %s carries %s and %s", c.name, x.values(c.fields), x.values(fields))
+	}
+	return c, args
+}
+
+// values returns the types of the values of a channel.
+func (x *exporter) values(fields []string) string {
+	if len(fields) == 0 {
+		return "no values"
+	}
+	return strings.Join(fields, ".")
+}
+
+// typeOf returns the type of e.
+func (x *exporter) typeOf(p *process, e lang.Expr) string {
+	switch e := e.(type) {
+	case *lang.IntLit:
+		return "integer"
+	case *lang.CharLit:
+		return "character"
+	case *lang.Ident:
+		if v := p.lookup(e.Name); v != nil {
+			return v.typ
+		}
+		switch e.Name {
+		case "true", "false":
+			return "boolean"
+		case "space", "asterisk", "upward arrow":
+			return "character"
+		}
+		errorf(e.Pos(), "undefined variable %s", e.Name)
+	case *lang.ParenExpr:
+		return x.typeOf(p, e.X)
+	case *lang.UnaryExpr:
+		if e.Op == lang.NOT {
+			return "boolean"
+		}
+		return "integer"
+	case *lang.BinaryExpr:
+		if precedence[e.Op] >= precedence[lang.ADD] {
+			return "integer"
+		}
+		return "boolean"
+	case *lang.StringLit:
+		errorf(e.Pos(), "arrays are not supported")
+	}
+	errorf(e.Pos(), "structured values are not supported")
+	return ""
+}
+
+// operators are the CSP# operators of the notation.
+var operators = map[lang.Token]string{
+	lang.OR: "||", lang.AND: "&&",
+	lang.EQ: "==", lang.NEQ: "!=", lang.LT: "<", lang.LEQ: "<=", lang.GT: ">", lang.GEQ: ">=",
+	lang.ADD: "+", lang.SUB: "-",
+	lang.MUL: "*", lang.DIV: "/", lang.MOD: "%",
+}
+
+// precedence are the precedences of the binary operators of CSP#.
+var precedence = map[lang.Token]int{
+	lang.OR: 1, lang.AND: 2,
+	lang.EQ: 3, lang.NEQ: 3,
+	lang.LT: 4, lang.LEQ: 4, lang.GT: 4, lang.GEQ: 4,
+	lang.ADD: 5, lang.SUB: 5,
+	lang.MUL: 6, lang.DIV: 6, lang.MOD: 6,
+}
+
+// expr returns the CSP# expression of e.
+func (x *exporter) expr(p *process, e lang.Expr) string {
+	switch e := e.(type) {
+	case *lang.IntLit:
+		return strconv.Itoa(e.Value)
+	case *lang.CharLit:
+		return strconv.Itoa(int(e.Value))
+	case *lang.Ident:
+		if v := p.lookup(e.Name); v != nil {
+			return v.name
+		}
+		switch e.Name {
+		case "space":
+			return strconv.Itoa(' ')
+		case "asterisk":
+			return strconv.Itoa('*')
+		case "upward arrow":
+			return strconv.Itoa('↑')
+		}
+		return e.Name
+	case *lang.ParenExpr:
+		return "(" + x.expr(p, e.X) + ")"
+	case *lang.UnaryExpr:
+		if e.Op == lang.NOT {
+			return "!" + x.operand(p, e.X, len(precedence))
+		}
+		return "-" + x.operand(p, e.X, len(precedence))
+	case *lang.BinaryExpr:
+		prec := precedence[e.Op]
+		return x.operand(p, e.X, prec) + " " + operators[e.Op] + " " + x.operand(p, e.Y, prec+1)
+	}
+	x.typeOf(p, e)
+	return ""
+}
+
+// operand returns the CSP# expression of e as an operand of an
+// operator of precedence prec.
+func (x *exporter) operand(p *process, e lang.Expr, prec int) string {
+	if b, ok := e.(*lang.BinaryExpr); ok && precedence[b.Op] < prec {
+		return "(" + x.expr(p, e) + ")"
+	}
+	return x.expr(p, e)
+}
+
+// source returns the model.
+func (x *exporter) source() []byte {
+	buf := bytes.Buffer{}
+	for _, c := range x.order {
+		if len(c.fields) > 0 {
+			fmt.Fprintf(&buf, "channel %s 0;\n", c.name)
+		}
+	}
+	for _, v := range x.vars {
+		fmt.Fprintf(&buf, "%s\n", v)
+	}
+	if buf.Len() > 0 {
+		fmt.Fprintf(&buf, "\n")
+	}
+	for _, b := range x.bodies {
+		fmt.Fprintf(&buf, "%s() = %s;\n", b.name, b.expr)
+	}
+	if len(x.asserts) > 0 {
+		fmt.Fprintf(&buf, "\n")
+	}
+	for _, a := range x.asserts {
+		fmt.Fprintf(&buf, "%s\n", a)
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,163 @@
+package pat_test
+
+import (
+	"testing"
+
+	"github.com/changkun/gobase/csp/lang"
+	"github.com/changkun/gobase/csp/lang/pat"
+)
+
+func TestExport(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{
+			src: "X::*[c:character; west?c → east!c]",
+			want: `channel west 0;
+channel east 0;
+var c = 0;
+
+X() = X_1();
+X_1() = west?c_in -> tau{c = c_in} -> east!c -> X_1();
+SYSTEM() = X();
+`,
+		},
+		{
+			src: `X::*[c:character; west?c →
+    [c ≠ asterisk → east!c
+    □ c = asterisk → west?c;
+        [c ≠ asterisk → east!asterisk; east!c □ c = asterisk → east!upward arrow]
+    ]
+]`,
+			want: `channel west 0;
+channel east 0;
+var c = 0;
+
+X() = X_1();
+X_1() = west?c_in -> tau{c = c_in} -> ([c != 42](tau -> east!c -> X_1()) [] [c == 42](tau -> west?c_in -> tau{c = c_in} -> ([c != 42](tau -> east!42 -> east!c -> X_1()) [] [c == 42](tau -> east!8593 -> X_1()))));
+SYSTEM() = X();
+`,
+		},
+		{
+			src: "X::n:integer; b:boolean; n := 0; *[n < 3 ∧ n ≥ 0 ∨ b → out!n; n := (n + 1) * 2; b := ¬(n = 2)]; done!-n mod 3 * 2",
+			want: `channel out 0;
+channel done 0;
+var n = 0;
+var b = false;
+
+X() = tau{n = 0} -> X_1();
+X_1() = if (n < 3 && n >= 0 || b) { [n < 3 && n >= 0 || b](out!n -> tau{n = (n + 1) * 2} -> tau{b = !(n == 2)} -> X_1()) } else { done!(-n % 3 * 2) -> Skip };
+SYSTEM() = X();
+`,
+		},
+		{
+			src: "S::n, m:integer; n := 0; *[n < 10; X?has(m, n) → n := n + m □ n < 10; X?V() → n := n - 1]",
+			want: `channel X_has 0;
+var n = 0;
+var m = 0;
+
+S() = tau{n = 0} -> S_1();
+S_1() = if (n < 10 || n < 10) { ([n < 10](X_has?m_in.n_in -> tau{m = m_in; n = n_in} -> tau{n = n + m} -> S_1()) [] [n < 10](X_V -> tau{n = n - 1} -> S_1())) } else { Skip };
+SYSTEM() = S();
+`,
+		},
+		{
+			src: `COPY = (*[c:character; west?c → east!c])
+[west::*[c:character; in?c → X!c] || X::*[c:character; west?c → east!c; east!P()] || east::*[c:character; X?c → out!c □ X?P() → skip]]`,
+			want: `channel west_2 0;
+channel east_2 0;
+channel in 0;
+channel west_X 0;
+channel X_east 0;
+channel out 0;
+var c = 0;
+var c_2 = 0;
+var c_3 = 0;
+var c_4 = 0;
+
+COPY() = COPY_1();
+COPY_1() = west_2?c_in -> tau{c = c_in} -> east_2!c -> COPY_1();
+west() = west_1();
+west_1() = in?c_2_in -> tau{c_2 = c_2_in} -> west_X!c_2 -> west_1();
+X() = X_1();
+X_1() = west_X?c_3_in -> tau{c_3 = c_3_in} -> X_east!c_3 -> X_east_P -> X_1();
+east() = east_1();
+east_1() = (X_east?c_4_in -> tau{c_4 = c_4_in} -> out!c_4 -> east_1() [] X_east_P -> east_1());
+SYSTEM() = west() || X() || east();
+`,
+		},
+		{
+			src: "COPY = (c:character; west?c; east!c)\nRUN = (x:integer; x := 1; [x > 0 → COPY; COPY □ x ≤ 0 → skip]; out!x)\nassert RUN :[deadlock free]\nassert COPY [FD= RUN]\nassert COPY [T= COPY]",
+			want: `channel west 0;
+channel east 0;
+channel out 0;
+var c = 0;
+var x = 0;
+var c_2 = 0;
+var c_3 = 0;
+
+COPY() = west?c_in -> tau{c = c_in} -> east!c -> Skip;
+RUN() = tau{x = 1} -> ([x > 0](tau -> west?c_2_in -> tau{c_2 = c_2_in} -> east!c_2 -> west?c_3_in -> tau{c_3 = c_3_in} -> east!c_3 -> RUN_1()) [] [x <= 0](tau -> RUN_1()));
+RUN_1() = out!x -> Skip;
+
+#assert RUN() deadlockfree;
+#assert RUN() refines<FD> COPY();
+#assert COPY() refines COPY();
+`,
+		},
+		{
+			src: "[X::Y!1 ||| Y::out!2]",
+			want: `channel Y_2 0;
+channel out 0;
+
+X() = Y_2!1 -> Skip;
+Y() = out!2 -> Skip;
+SYSTEM() = X() ||| Y();
+`,
+		},
+	}
+	for _, tt := range tests {
+		prog, err := lang.Parse(tt.src)
+		if err != nil {
+			t.Fatalf("%v: %v", t.Name(), err)
+		}
+		got, err := pat.Export(prog, pat.Config{})
+		if err != nil {
+			t.Fatalf("%v: unexpected error: %v", t.Name(), err)
+		}
+		if string(got) != tt.want {
+			t.Fatalf("%v: expected:\n%v\ngot:\n%v", t.Name(), tt.want, string(got))
+		}
+	}
+}
+
+func TestExportErrors(t *testing.T) {
+	tests := []struct {
+		src string
+		err string
+	}{
+		{src: "X::a:(1..3)integer; a(1) := 0", err: "1:6: arrays are not supported"},
+		{src: "X::*[(i:1..3) Y(i)?c() → skip]", err: "1:7: bound variables are not supported"},
+		{src: "X::[Y::skip || Z::skip]", err: "1:4: nested parallel commands are not supported"},
+		{src: "P = (out!1; P)\nX::P", err: "1:13: recursive process P is not supported"},
+		{src: "P = (out!1)\nX::P[[out <- log]]", err: "2:7: renaming is not supported"},
+		{src: "P = (log!1; out!1) \\ (log)", err: "1:23: hiding is not supported"},
+		{src: "X::out!n", err: "1:8: undefined variable n"},
+		{src: "X::c:character; c := true", err: "1:22: cannot assign boolean to c of type character"},
+		{src: "X::out!1; out!true", err: "1:15: channel out carries integer and boolean"},
+		{src: "X::out!P(); out!P(1)", err: "1:17: channel out_P carries no values and integer"},
+		{src: "[X::Y!1 || X::skip]", err: "1:12: duplicate process label X"},
+		{src: "X::X!1", err: "1:4: process X communicates with itself"},
+	}
+	for _, tt := range tests {
+		prog, err := lang.Parse(tt.src)
+		if err != nil {
+			t.Fatalf("%v: %v", t.Name(), err)
+		}
+		_, err = pat.Export(prog, pat.Config{})
+		if err == nil || err.Error() != tt.err {
+			t.Fatalf("%v: %q: expected: %v, got: %v", t.Name(), tt.src, tt.err, err)
+		}
+	}
+}
@@ -0,0 +1,707 @@
+// Package pluscal exports programs of package lang as TLA+ modules of
+// PlusCal algorithms, which the PlusCal translator translates into TLA+
+// specifications such that TLC checks them.
+//
+// The process COPY
+//
+//   X :: *[c:character; west?c -> east!c]
+//
+// becomes
+//
+//   ---- MODULE CSP ----
+//   EXTENDS Integers, Sequences
+//
+//   (* --algorithm CSP {
+//   variables
+//     west = <<>>,
+//     east = <<>>;
+//
+//   process (X = "X")
+//   variables c = 0;
+//   {
+//     X_1:
+//       while (TRUE) {
+//         await west # <<>>;
+//         c := Head(west)[1];
+//         west := <<>>;
+//         X_2:
+//           await east = <<>>;
+//           east := << <<c>> >>;
+//         X_3:
+//           await east = <<>>;
+//       }
+//   }
+//   } *)
+//   \* BEGIN TRANSLATION
+//   \* END TRANSLATION
+//   ====
+//
+// Every process of the parallel command of a program is a process of
+// the algorithm, and a program which is not one a process of its own.
+// Processes communicate on channels named by the communicating
+// processes, such as X_Y for the values X outputs to Y, and by the
+// constructors of structured values, such as X_Y_has for has(n) whose
+// components are the fields of the messages. A channel is a variable
+// holding a sequence of at most one message, a tuple of its fields: an
+// output puts its message into the empty channel and waits for an input
+// to take it, such that processes synchronize on their communications
+// as in the notation. An external name is a channel named by itself,
+// whose other end is left to an environment the module is completed
+// with. Characters are integers, the code points of the characters,
+// and variables are variables of their process, renamed if declared
+// more than once in the algorithm.
+//
+// Every command is a step of its own, labelled by its process, except
+// the guards of an alternative command, which are a step with the
+// choice among them. An alternative command becomes an either
+// statement, and a repetitive command a while statement, which
+// terminates once all of its boolean guards are false, unless it has
+// guards without boolean conditions. PlusCal has no failure of
+// processes and no termination of processes for each other: an
+// alternative command whose guards are false blocks, and an input guard
+// waits for its source forever. Arrays, bound variables, nested
+// parallel commands, interleaving, renaming, wait commands, timeouts and
+// probabilistic choices are not supported.
+package pluscal
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/changkun/gobase/csp/lang"
+)
+
+// Config configures an exported module.
+type Config struct {
+	// Module is the name of the module and of its algorithm, which
+	// is the name of the file of the module without .tla. The
+	// default is CSP.
+	Module string
+	// Main is the name of the process of a program which is not a
+	// parallel command. The default is main.
+	Main string
+}
+
+// Export returns the TLA+ module of the PlusCal algorithm of prog. A
+// program without a body, such as the descriptions of package extract,
+// is exported as a process for each of its definitions.
+func Export(prog *lang.Program, cfg Config) (src []byte, err error) {
+	if cfg.Module == "" {
+		cfg.Module = "CSP"
+	}
+	if cfg.Main == "" {
+		cfg.Main = "main"
+	}
+	x := &exporter{
+		cfg:   cfg,
+		defs:  map[string]*lang.Definition{},
+		names: map[string]bool{cfg.Module: true},
+		chans: map[string]*channel{},
+	}
+	for _, d := range prog.Defs {
+		x.defs[d.Name] = d
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			f, ok := r.(failure)
+			if !ok {
+				panic(r)
+			}
+			src, err = nil, f.err
+		}
+	}()
+	x.program(prog)
+	return x.source(), nil
+}
+
+// failure is the error of an export, raised by errorf.
+type failure struct{ err *lang.Error }
+
+func errorf(pos lang.Pos, format string, args ...interface{}) {
+	panic(failure{&lang.Error{Pos: pos, Msg: fmt.Sprintf(format, args...)}})
+}
+
+// exporter exports a program.
+type exporter struct {
+	cfg   Config
+	defs  map[string]*lang.Definition
+	names map[string]bool // names of the channels, processes, variables and labels
+	chans map[string]*channel
+	order []*channel
+	procs []*process
+}
+
+// channel is a channel of the algorithm.
+type channel struct {
+	name   string
+	fields []string // the types of the fields
+}
+
+// process is a process in translation.
+type process struct {
+	label     string
+	name      string
+	peers     map[string]bool // the labels of the processes of the parallel command
+	scope     *scope
+	labels    int // the number of labels of the process
+	decls     []string
+	body      []string
+	expanding map[string]bool // the definitions expanded in place
+}
+
+// scope is a scope of variables.
+type scope struct {
+	outer *scope
+	vars  map[string]*variable
+}
+
+// variable is a variable of a process.
+type variable struct {
+	name string
+	typ  string
+}
+
+func (p *process) lookup(name string) *variable {
+	for s := p.scope; s != nil; s = s.outer {
+		if v, ok := s.vars[name]; ok {
+			return v
+		}
+	}
+	return nil
+}
+
+func (x *exporter) declare(p *process, id *lang.Ident, t lang.Type) {
+	nt, ok := t.(*lang.NamedType)
+	if !ok {
+		errorf(t.Pos(), "arrays are not supported")
+	}
+	if _, ok := p.scope.vars[id.Name]; ok {
+		errorf(id.Pos(), "%s redeclared", id.Name)
+	}
+	init, ok := zeros[nt.Name]
+	if !ok {
+		errorf(nt.Pos(), "undefined type %s", nt.Name)
+	}
+	name := x.unique(id.Name)
+	p.scope.vars[id.Name] = &variable{name: name, typ: nt.Name}
+	p.decls = append(p.decls, name+" = "+init)
+}
+
+// zeros are the initial values of the variables of the types of the
+// notation.
+var zeros = map[string]string{
+	"integer":   "0",
+	"boolean":   "FALSE",
+	"character": "0",
+}
+
+// keywords are the keywords and predefined names of TLA+, PlusCal and
+// the modules the algorithm extends, and the names of its translation,
+// which are valid identifiers of the notation.
+var keywords = map[string]bool{
+	"ASSUME": true, "ASSUMPTION": true, "AXIOM": true, "CASE": true,
+	"CHOOSE": true, "CONSTANT": true, "CONSTANTS": true, "DOMAIN": true,
+	"ELSE": true, "ENABLED": true, "EXCEPT": true, "EXTENDS": true,
+	"FALSE": true, "IF": true, "IN": true, "INSTANCE": true, "LAMBDA": true,
+	"LET": true, "LOCAL": true, "MODULE": true, "OTHER": true, "STRING": true,
+	"SUBSET": true, "THEN": true, "THEOREM": true, "TRUE": true,
+	"UNCHANGED": true, "UNION": true, "VARIABLE": true, "VARIABLES": true,
+	"WITH":   true,
+	"Append": true, "BOOLEAN": true, "Head": true, "Init": true, "Int": true,
+	"Len": true, "Nat": true, "Next": true, "ProcSet": true, "Seq": true,
+	"Spec": true, "SubSeq": true, "Tail": true, "Terminating": true,
+	"Termination": true, "assert": true, "await": true, "begin": true,
+	"call": true, "define": true, "do": true, "either": true, "else": true,
+	"elsif": true, "end": true, "fair": true, "goto": true, "if": true,
+	"macro": true, "or": true, "pc": true, "print": true, "procedure": true,
+	"process": true, "return": true, "self": true, "skip": true, "stack": true,
+	"then": true, "variable": true, "variables": true, "vars": true,
+	"when": true, "while": true, "with": true,
+}
+
+// ident returns the TLA+ identifier of the identifier s.
+func ident(s string) string {
+	s = strings.ReplaceAll(s, " ", "_")
+	if keywords[s] {
+		return s + "_"
+	}
+	return s
+}
+
+// unique returns a name of the algorithm based on s.
+func (x *exporter) unique(s string) string {
+	name := ident(s)
+	for n := 2; x.names[name]; n++ {
+		name = ident(s) + "_" + strconv.Itoa(n)
+	}
+	x.names[name] = true
+	return name
+}
+
+func (x *exporter) program(prog *lang.Program) {
+	body := prog.Body
+	if len(body.Stmts) == 0 {
+		for _, d := range prog.Defs {
+			x.process(d.Name, nil, d.Body)
+		}
+		return
+	}
+	par, ok := body.Stmts[0].(*lang.ParallelCmd)
+	if !ok || len(body.Stmts) > 1 {
+		x.process(x.cfg.Main, nil, body)
+		return
+	}
+
+	if par.Interleave {
+		errorf(par.Pos(), "interleaving is not supported")
+	}
+	labels := map[string]bool{}
+	for _, p := range par.Procs {
+		if p.Label == nil {
+			errorf(p.Pos(), "processes without labels are not supported")
+		}
+		if len(p.Label.Subscripts) > 0 {
+			errorf(p.Label.Pos(), "arrays of processes are not supported")
+		}
+		if labels[p.Label.Name] {
+			errorf(p.Label.Pos(), "duplicate process label %s", p.Label.Name)
+		}
+		labels[p.Label.Name] = true
+	}
+	for _, p := range par.Procs {
+		x.process(p.Label.Name, labels, p.Body)
+	}
+}
+
+// process translates the process label, which communicates with the
+// processes labelled by peers.
+func (x *exporter) process(label string, peers map[string]bool, body *lang.CmdList) {
+	p := &process{
+		label:     label,
+		name:      x.unique(label),
+		peers:     peers,
+		scope:     &scope{vars: map[string]*variable{}},
+		expanding: map[string]bool{},
+	}
+	x.procs = append(x.procs, p)
+	p.body = x.list(p, body.Stmts)
+}
+
+// list returns the lines of the steps of stmts, a skip if there are
+// none.
+func (x *exporter) list(p *process, stmts []lang.Stmt) []string {
+	var lines []string
+	for _, s := range stmts {
+		lines = append(lines, x.stmt(p, s)...)
+	}
+	if len(lines) == 0 {
+		return x.step(p, "skip;")
+	}
+	return lines
+}
+
+// step returns the lines of a step of the statements stmts, labelled
+// by a new label of the process p.
+func (x *exporter) step(p *process, stmts ...string) []string {
+	return append([]string{x.label(p) + ":"}, indent(stmts)...)
+}
+
+// label returns a new label of the process p.
+func (x *exporter) label(p *process) string {
+	p.labels++
+	return x.unique(p.name + "_" + strconv.Itoa(p.labels))
+}
+
+// indent indents lines by a level.
+func indent(lines []string) []string {
+	indented := make([]string, len(lines))
+	for i, l := range lines {
+		indented[i] = "  " + l
+	}
+	return indented
+}
+
+// stmt returns the lines of the steps of s.
+func (x *exporter) stmt(p *process, s lang.Stmt) []string {
+	switch s := s.(type) {
+	case *lang.Declaration:
+		for _, id := range s.Names {
+			x.declare(p, id, s.Type)
+		}
+		return nil
+	case *lang.SkipCmd:
+		return x.step(p, "skip;")
+	case *lang.ProcRef:
+		d, ok := x.defs[s.Name]
+		if !ok {
+			errorf(s.Pos(), "undefined process %s", s.Name)
+		}
+		if len(s.Renames) > 0 {
+			errorf(s.Renames[0].Pos(), "renaming is not supported")
+		}
+		if p.expanding[s.Name] {
+			errorf(s.Pos(), "recursive process %s is not supported", s.Name)
+		}
+		p.expanding[s.Name] = true
+		defer delete(p.expanding, s.Name)
+		p.scope = &scope{outer: p.scope, vars: map[string]*variable{}}
+		defer func() { p.scope = p.scope.outer }()
+		return x.list(p, d.Body.Stmts)
+	case *lang.AssignmentCmd:
+		id, ok := s.Target.(*lang.Ident)
+		if !ok {
+			errorf(s.Target.Pos(), "structured values are not supported")
+		}
+		v := p.lookup(id.Name)
+		if v == nil {
+			errorf(id.Pos(), "undefined variable %s", id.Name)
+		}
+		if typ := x.typeOf(p, s.Value); typ != v.typ {
+			errorf(s.Value.Pos(), "cannot assign %s to %s of type %s", typ, id.Name, v.typ)
+		}
+		return x.step(p, v.name+" := "+x.expr(p, s.Value)+";")
+	case *lang.InputCmd:
+		return x.step(p, x.input(p, s)...)
+	case *lang.OutputCmd:
+		c, args := x.port(p, s.Dest, s.Value, false)
+		fields := make([]string, len(args))
+		for i, a := range args {
+			fields[i] = x.expr(p, a)
+		}
+		// the output waits for an input to take its message.
+		lines := x.step(p, "await "+c.name+" = <<>>;", c.name+" := << <<"+strings.Join(fields, ", ")+">> >>;")
+		return append(lines, x.step(p, "await "+c.name+" = <<>>;")...)
+	case *lang.AlternativeCmd:
+		label := x.label(p)
+		lines, _ := x.alternative(p, s)
+		return append([]string{label + ":"}, indent(lines)...)
+	case *lang.RepetitiveCmd:
+		label := x.label(p)
+		lines, cond := x.alternative(p, s.Alt)
+		if cond == "" {
+			cond = "TRUE"
+		}
+		lines = append([]string{"while (" + cond + ") {"}, indent(lines)...)
+		return append([]string{label + ":"}, indent(append(lines, "}"))...)
+	case *lang.WaitCmd:
+		errorf(s.Pos(), "wait commands are not supported")
+	case *lang.ParallelCmd:
+		errorf(s.Pos(), "nested parallel commands are not supported")
+	}
+	errorf(s.Pos(), "unexpected %T", s)
+	return nil
+}
+
+// alternative returns the lines of alt, an either statement of its
+// guarded commands unless it has only one, and the condition of alt
+// as a repetitive command, empty if it never terminates.
+func (x *exporter) alternative(p *process, alt *lang.AlternativeCmd) (lines []string, cond string) {
+	var conds []string
+	for i, gc := range alt.Cmds {
+		if len(gc.Ranges) > 0 {
+			errorf(gc.Ranges[0].Pos(), "bound variables are not supported")
+		}
+		if gc.Guard.Timeout != nil {
+			errorf(gc.Guard.Timeout.Pos(), "timeouts are not supported")
+		}
+		if gc.Guard.Prob != nil {
+			errorf(gc.Guard.Prob.Pos(), "probabilistic choices are not supported")
+		}
+		p.scope = &scope{outer: p.scope, vars: map[string]*variable{}}
+		var branch []string
+		if c := x.cond(p, gc.Guard); c != nil {
+			branch = append(branch, "await "+x.expr(p, c)+";")
+			conds = append(conds, x.operand(p, c, lang.OR, true))
+		}
+		if gc.Guard.Input != nil {
+			branch = append(branch, x.input(p, gc.Guard.Input)...)
+		}
+		if len(branch) == 0 {
+			branch = []string{"skip;"}
+		}
+		branch = append(branch, x.list(p, gc.Body.Stmts)...)
+		p.scope = p.scope.outer
+
+		switch {
+		case len(alt.Cmds) == 1:
+			lines = branch
+		case i == 0:
+			lines = append(append(lines, "either {"), indent(branch)...)
+		default:
+			lines = append(append(lines, "} or {"), indent(branch)...)
+		}
+	}
+	if len(alt.Cmds) > 1 {
+		lines = append(lines, "}")
+	}
+	if len(conds) < len(alt.Cmds) {
+		return lines, ""
+	}
+	return lines, strings.Join(conds, " \\/ ")
+}
+
+// cond returns the conjunction of the boolean guards of g, nil if
+// they are true, and declares the variables of g.
+func (x *exporter) cond(p *process, g *lang.Guard) lang.Expr {
+	var cond lang.Expr
+	for _, n := range g.List {
+		switch n := n.(type) {
+		case *lang.Declaration:
+			for _, id := range n.Names {
+				x.declare(p, id, n.Type)
+			}
+		case lang.Expr:
+			if typ := x.typeOf(p, n); typ != "boolean" {
+				errorf(n.Pos(), "guard of type %s", typ)
+			}
+			if id, ok := n.(*lang.Ident); ok && id.Name == "true" {
+				continue
+			}
+			if cond == nil {
+				cond = n
+			} else {
+				cond = &lang.BinaryExpr{X: cond, Op: lang.AND, Y: n}
+			}
+		}
+	}
+	return cond
+}
+
+// input returns the statements of the input s, which wait for a
+// message, assign its fields, and take it from its channel.
+func (x *exporter) input(p *process, s *lang.InputCmd) []string {
+	c, args := x.port(p, s.Source, s.Target, true)
+	lines := []string{"await " + c.name + " # <<>>;"}
+	assigned := map[string]bool{}
+	for i, a := range args {
+		v := p.lookup(a.(*lang.Ident).Name)
+		if assigned[v.name] {
+			errorf(a.Pos(), "variable %s input more than once", a.(*lang.Ident).Name)
+		}
+		assigned[v.name] = true
+		lines = append(lines, fmt.Sprintf("%s := Head(%s)[%d];", v.name, c.name, i+1))
+	}
+	return append(lines, c.name+" := <<>>;")
+}
+
+// port returns the channel of the communication of the value v with
+// the process n, and the fields of its messages.
+func (x *exporter) port(p *process, n *lang.ProcName, v lang.Expr, input bool) (*channel, []lang.Expr) {
+	if len(n.Subscripts) > 0 {
+		errorf(n.Pos(), "arrays of processes are not supported")
+	}
+	if n.Name == p.label {
+		errorf(n.Pos(), "process %s communicates with itself", n.Name)
+	}
+	cons, args := "", []lang.Expr{v}
+	if s, ok := v.(*lang.StructuredExpr); ok {
+		if p.lookup(s.Constructor) != nil {
+			errorf(s.Pos(), "arrays are not supported")
+		}
+		cons, args = s.Constructor, s.Args
+	}
+	fields := make([]string, len(args))
+	for i, a := range args {
+		if !input {
+			fields[i] = x.typeOf(p, a)
+			continue
+		}
+		id, ok := a.(*lang.Ident)
+		if !ok {
+			errorf(a.Pos(), "structured values are not supported")
+		}
+		v := p.lookup(id.Name)
+		if v == nil {
+			errorf(id.Pos(), "undefined variable %s", id.Name)
+		}
+		fields[i] = v.typ
+	}
+
+	name, key := n.Name, "|"+n.Name+"|"+cons
+	if p.peers[n.Name] {
+		src, dst := p.label, n.Name
+		if input {
+			src, dst = n.Name, p.label
+		}
+		name, key = src+"_"+dst, src+"|"+dst+"|"+cons
+	}
+	if cons != "" {
+		name += "_" + cons
+	}
+	c, ok := x.chans[key]
+	if !ok {
+		c = &channel{name: x.unique(name), fields: fields}
+		x.chans[key] = c
+		x.order = append(x.order, c)
+	}
+	if strings.Join(c.fields, ", ") != strings.Join(fields, ", ") {
+		errorf(v.Pos(), "channel %s carries (%s) and (%s)", c.name, strings.Join(c.fields, ", "), strings.Join(fields, ", "))
+	}
+	return c, args
+}
+
+// typeOf returns the type of e.
+func (x *exporter) typeOf(p *process, e lang.Expr) string {
+	switch e := e.(type) {
+	case *lang.IntLit:
+		return "integer"
+	case *lang.CharLit:
+		return "character"
+	case *lang.Ident:
+		if v := p.lookup(e.Name); v != nil {
+			return v.typ
+		}
+		switch e.Name {
+		case "true", "false":
+			return "boolean"
+		case "space", "asterisk", "upward arrow":
+			return "character"
+		}
+		errorf(e.Pos(), "undefined variable %s", e.Name)
+	case *lang.ParenExpr:
+		return x.typeOf(p, e.X)
+	case *lang.UnaryExpr:
+		if e.Op == lang.NOT {
+			return "boolean"
+		}
+		return "integer"
+	case *lang.BinaryExpr:
+		if precedence[e.Op] >= precedence[lang.ADD] {
+			return "integer"
+		}
+		return "boolean"
+	case *lang.StringLit:
+		errorf(e.Pos(), "arrays are not supported")
+	}
+	errorf(e.Pos(), "structured values are not supported")
+	return ""
+}
+
+// operators are the TLA+ operators of the notation.
+var operators = map[lang.Token]string{
+	lang.OR: "\\/", lang.AND: "/\\",
+	lang.EQ: "=", lang.NEQ: "#", lang.LT: "<", lang.LEQ: "<=", lang.GT: ">", lang.GEQ: ">=",
+	lang.ADD: "+", lang.SUB: "-", lang.MOD: "%",
+	lang.MUL: "*", lang.DIV: "\\div",
+}
+
+// precedence are the levels of the binary operators of TLA+, of which
+// operators of the same level are associative only if they are the
+// same, or both additive. TLA+ requires parentheses around mixed
+// conjunctions and disjunctions, and % binds as weakly as +.
+var precedence = map[lang.Token]int{
+	lang.OR: 1, lang.AND: 1,
+	lang.EQ: 2, lang.NEQ: 2, lang.LT: 2, lang.LEQ: 2, lang.GT: 2, lang.GEQ: 2,
+	lang.ADD: 3, lang.SUB: 3, lang.MOD: 3,
+	lang.MUL: 4, lang.DIV: 4,
+}
+
+// associative reports whether the operator x associates to the left
+// with the operator y.
+func associative(x, y lang.Token) bool {
+	switch x {
+	case lang.OR, lang.AND, lang.MUL:
+		return x == y
+	case lang.ADD, lang.SUB:
+		return y == lang.ADD || y == lang.SUB
+	}
+	return false
+}
+
+// expr returns the TLA+ expression of e.
+func (x *exporter) expr(p *process, e lang.Expr) string {
+	switch e := e.(type) {
+	case *lang.IntLit:
+		return strconv.Itoa(e.Value)
+	case *lang.CharLit:
+		return strconv.Itoa(int(e.Value))
+	case *lang.Ident:
+		if v := p.lookup(e.Name); v != nil {
+			return v.name
+		}
+		switch e.Name {
+		case "true":
+			return "TRUE"
+		case "false":
+			return "FALSE"
+		case "space":
+			return strconv.Itoa(' ')
+		case "asterisk":
+			return strconv.Itoa('*')
+		case "upward arrow":
+			return strconv.Itoa('↑')
+		}
+		return e.Name
+	case *lang.ParenExpr:
+		return "(" + x.expr(p, e.X) + ")"
+	case *lang.UnaryExpr:
+		op := "-"
+		if e.Op == lang.NOT {
+			op = "~"
+		}
+		switch e.X.(type) {
+		case *lang.BinaryExpr, *lang.UnaryExpr:
+			return op + "(" + x.expr(p, e.X) + ")"
+		}
+		return op + x.expr(p, e.X)
+	case *lang.BinaryExpr:
+		return x.operand(p, e.X, e.Op, true) + " " + operators[e.Op] + " " + x.operand(p, e.Y, e.Op, false)
+	}
+	x.typeOf(p, e)
+	return ""
+}
+
+// operand returns the TLA+ expression of e as the left or right
+// operand of the operator op.
+func (x *exporter) operand(p *process, e lang.Expr, op lang.Token, left bool) string {
+	switch o := e.(type) {
+	case *lang.BinaryExpr:
+		if precedence[o.Op] > precedence[op] || left && precedence[o.Op] == precedence[op] && associative(o.Op, op) {
+			return x.expr(p, e)
+		}
+		return "(" + x.expr(p, e) + ")"
+	case *lang.UnaryExpr:
+		// ~ binds more weakly than relations, and - than
+		// multiplications.
+		if o.Op == lang.NOT && precedence[op] > precedence[lang.OR] || o.Op == lang.SUB && precedence[op] > precedence[lang.ADD] {
+			return "(" + x.expr(p, e) + ")"
+		}
+	}
+	return x.expr(p, e)
+}
+
+// source returns the module.
+func (x *exporter) source() []byte {
+	buf := bytes.Buffer{}
+	fmt.Fprintf(&buf, "---- MODULE %s ----\n", x.cfg.Module)
+	fmt.Fprintf(&buf, "EXTENDS Integers, Sequences\n\n")
+	fmt.Fprintf(&buf, "(* --algorithm %s {\n", x.cfg.Module)
+	if len(x.order) > 0 {
+		fmt.Fprintf(&buf, "variables\n")
+		for i, c := range x.order {
+			end := ","
+			if i == len(x.order)-1 {
+				end = ";"
+			}
+			fmt.Fprintf(&buf, "  %s = <<>>%s\n", c.name, end)
+		}
+	}
+	for _, p := range x.procs {
+		fmt.Fprintf(&buf, "\nprocess (%s = %q)\n", p.name, p.label)
+		if len(p.decls) > 0 {
+			fmt.Fprintf(&buf, "variables %s;\n", strings.Join(p.decls, ", "))
+		}
+		fmt.Fprintf(&buf, "{\n")
+		for _, l := range p.body {
+			fmt.Fprintf(&buf, "  %s\n", l)
+		}
+		fmt.Fprintf(&buf, "}\n")
+	}
+	fmt.Fprintf(&buf, "} *)\n")
+	fmt.Fprintf(&buf, "\\* BEGIN TRANSLATION\n")
+	fmt.Fprintf(&buf, "\\* END TRANSLATION\n")
+	fmt.Fprintf(&buf, "====\n")
+	return buf.Bytes()
+}
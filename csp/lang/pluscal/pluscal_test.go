@@ -0,0 +1,384 @@
+package pluscal_test
+
+import (
+	"testing"
+
+	"github.com/changkun/gobase/csp/lang"
+	"github.com/changkun/gobase/csp/lang/pluscal"
+)
+
+func TestExport(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{
+			src: "X::*[c:character; west?c → east!c]",
+			want: `---- MODULE CSP ----
+EXTENDS Integers, Sequences
+
+(* --algorithm CSP {
+variables
+  west = <<>>,
+  east = <<>>;
+
+process (X = "X")
+variables c = 0;
+{
+  X_1:
+    while (TRUE) {
+      await west # <<>>;
+      c := Head(west)[1];
+      west := <<>>;
+      X_2:
+        await east = <<>>;
+        east := << <<c>> >>;
+      X_3:
+        await east = <<>>;
+    }
+}
+} *)
+\* BEGIN TRANSLATION
+\* END TRANSLATION
+====
+`,
+		},
+		{
+			src: `X::*[c:character; west?c →
+    [c ≠ asterisk → east!c
+    □ c = asterisk → west?c;
+        [c ≠ asterisk → east!asterisk; east!c □ c = asterisk → east!upward arrow]
+    ]
+]`,
+			want: `---- MODULE CSP ----
+EXTENDS Integers, Sequences
+
+(* --algorithm CSP {
+variables
+  west = <<>>,
+  east = <<>>;
+
+process (X = "X")
+variables c = 0;
+{
+  X_1:
+    while (TRUE) {
+      await west # <<>>;
+      c := Head(west)[1];
+      west := <<>>;
+      X_2:
+        either {
+          await c # 42;
+          X_3:
+            await east = <<>>;
+            east := << <<c>> >>;
+          X_4:
+            await east = <<>>;
+        } or {
+          await c = 42;
+          X_5:
+            await west # <<>>;
+            c := Head(west)[1];
+            west := <<>>;
+          X_6:
+            either {
+              await c # 42;
+              X_7:
+                await east = <<>>;
+                east := << <<42>> >>;
+              X_8:
+                await east = <<>>;
+              X_9:
+                await east = <<>>;
+                east := << <<c>> >>;
+              X_10:
+                await east = <<>>;
+            } or {
+              await c = 42;
+              X_11:
+                await east = <<>>;
+                east := << <<8593>> >>;
+              X_12:
+                await east = <<>>;
+            }
+        }
+    }
+}
+} *)
+\* BEGIN TRANSLATION
+\* END TRANSLATION
+====
+`,
+		},
+		{
+			src: "X::n:integer; b:boolean; n := 0; *[n < 3 ∧ n ≥ 0 ∨ b → out!n; n := (n + 1) * 2; b := ¬(n = 2)]; done!-n mod 3 * 2",
+			want: `---- MODULE CSP ----
+EXTENDS Integers, Sequences
+
+(* --algorithm CSP {
+variables
+  out = <<>>,
+  done = <<>>;
+
+process (X = "X")
+variables n = 0, b = FALSE;
+{
+  X_1:
+    n := 0;
+  X_2:
+    while ((n < 3 /\ n >= 0) \/ b) {
+      await (n < 3 /\ n >= 0) \/ b;
+      X_3:
+        await out = <<>>;
+        out := << <<n>> >>;
+      X_4:
+        await out = <<>>;
+      X_5:
+        n := (n + 1) * 2;
+      X_6:
+        b := ~(n = 2);
+    }
+  X_7:
+    await done = <<>>;
+    done := << <<(-n % 3) * 2>> >>;
+  X_8:
+    await done = <<>>;
+}
+} *)
+\* BEGIN TRANSLATION
+\* END TRANSLATION
+====
+`,
+		},
+		{
+			src: "S::n, m:integer; n := 0; *[n < 10; X?has(m, n) → n := n + m □ n < 10; X?V() → n := n - 1]",
+			want: `---- MODULE CSP ----
+EXTENDS Integers, Sequences
+
+(* --algorithm CSP {
+variables
+  X_has = <<>>,
+  X_V = <<>>;
+
+process (S = "S")
+variables n = 0, m = 0;
+{
+  S_1:
+    n := 0;
+  S_2:
+    while (n < 10 \/ n < 10) {
+      either {
+        await n < 10;
+        await X_has # <<>>;
+        m := Head(X_has)[1];
+        n := Head(X_has)[2];
+        X_has := <<>>;
+        S_3:
+          n := n + m;
+      } or {
+        await n < 10;
+        await X_V # <<>>;
+        X_V := <<>>;
+        S_4:
+          n := n - 1;
+      }
+    }
+}
+} *)
+\* BEGIN TRANSLATION
+\* END TRANSLATION
+====
+`,
+		},
+		{
+			src: `COPY = (*[c:character; west?c → east!c])
+[west::*[c:character; in?c → X!c] || X::*[c:character; west?c → east!c; east!P()] || east::*[c:character; X?c → out!c □ X?P() → skip]]`,
+			want: `---- MODULE CSP ----
+EXTENDS Integers, Sequences
+
+(* --algorithm CSP {
+variables
+  in = <<>>,
+  west_X = <<>>,
+  X_east = <<>>,
+  X_east_P = <<>>,
+  out = <<>>;
+
+process (west = "west")
+variables c = 0;
+{
+  west_1:
+    while (TRUE) {
+      await in # <<>>;
+      c := Head(in)[1];
+      in := <<>>;
+      west_2:
+        await west_X = <<>>;
+        west_X := << <<c>> >>;
+      west_3:
+        await west_X = <<>>;
+    }
+}
+
+process (X = "X")
+variables c_2 = 0;
+{
+  X_1:
+    while (TRUE) {
+      await west_X # <<>>;
+      c_2 := Head(west_X)[1];
+      west_X := <<>>;
+      X_2:
+        await X_east = <<>>;
+        X_east := << <<c_2>> >>;
+      X_3:
+        await X_east = <<>>;
+      X_4:
+        await X_east_P = <<>>;
+        X_east_P := << <<>> >>;
+      X_5:
+        await X_east_P = <<>>;
+    }
+}
+
+process (east = "east")
+variables c_3 = 0;
+{
+  east_1:
+    while (TRUE) {
+      either {
+        await X_east # <<>>;
+        c_3 := Head(X_east)[1];
+        X_east := <<>>;
+        east_2:
+          await out = <<>>;
+          out := << <<c_3>> >>;
+        east_3:
+          await out = <<>>;
+      } or {
+        await X_east_P # <<>>;
+        X_east_P := <<>>;
+        east_4:
+          skip;
+      }
+    }
+}
+} *)
+\* BEGIN TRANSLATION
+\* END TRANSLATION
+====
+`,
+		},
+		{
+			src: "COPY = (c:character; west?c; east!c)\nRUN = (x:integer; x := 1; [x > 0 → COPY; COPY □ x ≤ 0 → skip]; out!x)",
+			want: `---- MODULE CSP ----
+EXTENDS Integers, Sequences
+
+(* --algorithm CSP {
+variables
+  west = <<>>,
+  east = <<>>,
+  out = <<>>;
+
+process (COPY = "COPY")
+variables c = 0;
+{
+  COPY_1:
+    await west # <<>>;
+    c := Head(west)[1];
+    west := <<>>;
+  COPY_2:
+    await east = <<>>;
+    east := << <<c>> >>;
+  COPY_3:
+    await east = <<>>;
+}
+
+process (RUN = "RUN")
+variables x = 0, c_2 = 0, c_3 = 0;
+{
+  RUN_1:
+    x := 1;
+  RUN_2:
+    either {
+      await x > 0;
+      RUN_3:
+        await west # <<>>;
+        c_2 := Head(west)[1];
+        west := <<>>;
+      RUN_4:
+        await east = <<>>;
+        east := << <<c_2>> >>;
+      RUN_5:
+        await east = <<>>;
+      RUN_6:
+        await west # <<>>;
+        c_3 := Head(west)[1];
+        west := <<>>;
+      RUN_7:
+        await east = <<>>;
+        east := << <<c_3>> >>;
+      RUN_8:
+        await east = <<>>;
+    } or {
+      await x <= 0;
+      RUN_9:
+        skip;
+    }
+  RUN_10:
+    await out = <<>>;
+    out := << <<x>> >>;
+  RUN_11:
+    await out = <<>>;
+}
+} *)
+\* BEGIN TRANSLATION
+\* END TRANSLATION
+====
+`,
+		},
+	}
+	for _, tt := range tests {
+		prog, err := lang.Parse(tt.src)
+		if err != nil {
+			t.Fatalf("%v: %v", t.Name(), err)
+		}
+		got, err := pluscal.Export(prog, pluscal.Config{})
+		if err != nil {
+			t.Fatalf("%v: unexpected error: %v", t.Name(), err)
+		}
+		if string(got) != tt.want {
+			t.Fatalf("%v: expected:\n%v\ngot:\n%v", t.Name(), tt.want, string(got))
+		}
+	}
+}
+
+func TestExportErrors(t *testing.T) {
+	tests := []struct {
+		src string
+		err string
+	}{
+		{src: "X::a:(1..3)integer; a(1) := 0", err: "1:6: arrays are not supported"},
+		{src: "X::*[(i:1..3) Y(i)?c() → skip]", err: "1:7: bound variables are not supported"},
+		{src: "X::[Y::skip || Z::skip]", err: "1:4: nested parallel commands are not supported"},
+		{src: "P = (out!1; P)\nX::P", err: "1:13: recursive process P is not supported"},
+		{src: "P = (out!1)\nX::P[[out <- log]]", err: "2:7: renaming is not supported"},
+		{src: "[X::out!1 ||| Y::out!2]", err: "1:1: interleaving is not supported"},
+		{src: "X::out!n", err: "1:8: undefined variable n"},
+		{src: "X::c:character; c := true", err: "1:22: cannot assign boolean to c of type character"},
+		{src: "X::out!1; out!true", err: "1:15: channel out carries (integer) and (boolean)"},
+		{src: "X::n:integer; Y?p(n, n)", err: "1:22: variable n input more than once"},
+		{src: "[X::Y!1 || X::skip]", err: "1:12: duplicate process label X"},
+		{src: "X::X!1", err: "1:4: process X communicates with itself"},
+	}
+	for _, tt := range tests {
+		prog, err := lang.Parse(tt.src)
+		if err != nil {
+			t.Fatalf("%v: %v", t.Name(), err)
+		}
+		_, err = pluscal.Export(prog, pluscal.Config{})
+		if err == nil || err.Error() != tt.err {
+			t.Fatalf("%v: %q: expected: %v, got: %v", t.Name(), tt.src, tt.err, err)
+		}
+	}
+}
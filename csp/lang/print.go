@@ -0,0 +1,371 @@
+package lang
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// width is the width of a line of the source printed by Source.
+const width = 72
+
+// indent is the indentation of nested command lists.
+const indent = "    "
+
+// Source returns the source of n in the notation of the paper. A
+// structured command which does not fit in a line is laid out over
+// several lines, with one guarded command or process per line:
+//
+//   X::*[c:character; west?c →
+//       [c ≠ asterisk → east!c
+//       □ c = asterisk →
+//           west?c;
+//           [c ≠ asterisk → east!asterisk; east!c
+//           □ c = asterisk → east!upward arrow
+//           ]
+//       ]
+//   ]
+func Source(n Node) string {
+	switch n := n.(type) {
+	case *Program:
+		return program(n, nil)
+	case Stmt:
+		return stmt(n, "")
+	case *CmdList:
+		return cmdList(n, "")
+	case *Proc:
+		return proc(n, "")
+	case *GuardedCmd:
+		return guardedCmd(n, "")
+	}
+	return compact(n)
+}
+
+// FormatSource returns the canonical source of the program src, as
+// printed by Source, such as for cspi fmt. Formatting a formatted
+// source returns it unchanged. A comment is kept on a line of its own,
+// preceding the definition or the command list of the program it
+// appears in.
+func FormatSource(src []byte) ([]byte, error) {
+	p := newParser(string(src))
+	prog := p.parse()
+	if err := sortErrors(p.errs); err != nil {
+		return nil, err
+	}
+
+	// a comment belongs to the definition or the body of the token
+	// preceding it on its line, or of the token following it.
+	nodes, starts := []Node{}, []int{}
+	for _, n := range heading(prog) {
+		nodes, starts = append(nodes, n), append(starts, n.Pos().Offset)
+	}
+	nodes, starts = append(nodes, prog.Body), append(starts, prog.Body.Pos().Offset)
+	comments := map[Node][]string{}
+	next := 0
+	for _, c := range p.comments {
+		for next < len(p.items)-1 && p.items[next].Pos.Offset < c.pos.Offset {
+			next++
+		}
+		tok := p.items[next].Pos
+		if next > 0 && p.items[next-1].Pos.Line == c.pos.Line {
+			tok = p.items[next-1].Pos
+		}
+		i := sort.SearchInts(starts, tok.Offset+1) - 1
+		if i < 0 {
+			i = 0
+		}
+		comments[nodes[i]] = append(comments[nodes[i]], c.text)
+	}
+	return []byte(program(prog, comments)), nil
+}
+
+// hiding returns the hiding of d, such as \ (log), empty if none.
+func hiding(d *Definition) string {
+	if len(d.Hidden) == 0 {
+		return ""
+	}
+	return " \\ (" + join(d.Hidden, ", ") + ")"
+}
+
+// parallel returns the separator of the processes of cmd, || or |||.
+func parallel(cmd *ParallelCmd) string {
+	if cmd.Interleave {
+		return "|||"
+	}
+	return "||"
+}
+
+// fits reports whether s fits in a line following prefix.
+func fits(prefix, s string) bool {
+	return !strings.Contains(s, "\n") && utf8.RuneCountInString(prefix+s) <= width
+}
+
+// heading returns the definitions and assertions of prog, in the order
+// of the source.
+func heading(prog *Program) []Node {
+	nodes := []Node{}
+	for _, d := range prog.Defs {
+		nodes = append(nodes, d)
+	}
+	for _, a := range prog.Asserts {
+		nodes = append(nodes, a)
+	}
+	sort.SliceStable(nodes, func(i, j int) bool { return nodes[i].Pos().Offset < nodes[j].Pos().Offset })
+	return nodes
+}
+
+// program returns the source of prog, the comments of a definition, an
+// assertion or the body precede it.
+func program(prog *Program, comments map[Node][]string) string {
+	b := strings.Builder{}
+	for _, n := range heading(prog) {
+		for _, c := range comments[n] {
+			b.WriteString(c + "\n")
+		}
+		d, ok := n.(*Definition)
+		if !ok {
+			b.WriteString(compact(n) + "\n")
+			continue
+		}
+		if line := d.Name + " = (" + compact(d.Body) + ")" + hiding(d); fits("", line) {
+			b.WriteString(line + "\n")
+			continue
+		}
+		b.WriteString(d.Name + " = (\n" + indent + cmdList(d.Body, indent) + "\n)" + hiding(d) + "\n")
+	}
+	if prog.Body == nil {
+		return b.String()
+	}
+	for _, c := range comments[prog.Body] {
+		b.WriteString(c + "\n")
+	}
+	if len(prog.Body.Stmts) > 0 {
+		b.WriteString(cmdList(prog.Body, "") + "\n")
+	}
+	return b.String()
+}
+
+// cmdList returns the commands of l at the indentation ind, on one
+// line if they fit.
+func cmdList(l *CmdList, ind string) string {
+	if line := compact(l); fits(ind, line) {
+		return line
+	}
+	stmts := make([]string, len(l.Stmts))
+	for i, s := range l.Stmts {
+		stmts[i] = stmt(s, ind)
+	}
+	return strings.Join(stmts, ";\n"+ind)
+}
+
+// stmt returns s starting at the indentation ind.
+func stmt(s Stmt, ind string) string {
+	if line := compact(s); fits(ind, line) {
+		return line
+	}
+	switch s := s.(type) {
+	case *AlternativeCmd:
+		return alternative(s, ind)
+	case *RepetitiveCmd:
+		return "*" + alternative(s.Alt, ind)
+	case *ParallelCmd:
+		procs := make([]string, len(s.Procs))
+		for i, p := range s.Procs {
+			procs[i] = proc(p, ind)
+		}
+		sep := "\n" + ind + parallel(s) + " "
+		if s.Implicit {
+			return strings.Join(procs, sep)
+		}
+		return "[" + strings.Join(procs, sep) + "\n" + ind + "]"
+	}
+	return compact(s)
+}
+
+func alternative(a *AlternativeCmd, ind string) string {
+	cmds := make([]string, len(a.Cmds))
+	for i, gc := range a.Cmds {
+		cmds[i] = guardedCmd(gc, ind)
+	}
+	return "[" + strings.Join(cmds, "\n"+ind+"□ ") + "\n" + ind + "]"
+}
+
+func guardedCmd(gc *GuardedCmd, ind string) string {
+	head := compactGuard(gc) + " →"
+	if body := compact(gc.Body); fits(ind+"□ "+head+" ", body) {
+		return head + " " + body
+	}
+	return head + "\n" + ind + indent + cmdList(gc.Body, ind+indent)
+}
+
+func proc(p *Proc, ind string) string {
+	label := ""
+	if p.Label != nil {
+		label = compact(p.Label) + "::"
+	}
+	if body := compact(p.Body); fits(ind+"|| "+label, body) {
+		return label + body
+	}
+	// a single command follows the label, as in X::*[...].
+	if len(p.Body.Stmts) == 1 {
+		return label + stmt(p.Body.Stmts[0], ind)
+	}
+	return label + "\n" + ind + indent + cmdList(p.Body, ind+indent)
+}
+
+// compact returns n on a single line.
+func compact(n Node) string {
+	switch n := n.(type) {
+	case *Program:
+		return strings.TrimSuffix(program(n, nil), "\n")
+	case *Definition:
+		return n.Name + " = (" + compact(n.Body) + ")" + hiding(n)
+	case *Assertion:
+		if n.Property != "" {
+			return "assert " + n.Proc + " :[" + n.Property + "]"
+		}
+		return "assert " + n.Proc + " [" + n.Model + "= " + n.Impl + "]"
+	case *CmdList:
+		return join(n.Stmts, "; ")
+	case *Declaration:
+		return join(n.Names, ", ") + ":" + compact(n.Type)
+	case *NamedType:
+		return n.Name
+	case *ArrayType:
+		return "(" + compact(n.Lo) + ".." + compact(n.Hi) + ")" + compact(n.Elem)
+	case *SkipCmd:
+		return "skip"
+	case *WaitCmd:
+		return "wait " + compact(n.Duration)
+	case *AssignmentCmd:
+		return compact(n.Target) + " := " + compact(n.Value)
+	case *InputCmd:
+		return compact(n.Source) + "?" + compact(n.Target)
+	case *OutputCmd:
+		return compact(n.Dest) + "!" + compact(n.Value)
+	case *ProcName:
+		return subscripted(n.Name, n.Subscripts)
+	case *ParallelCmd:
+		procs := join(n.Procs, " "+parallel(n)+" ")
+		if n.Implicit {
+			return procs
+		}
+		return "[" + procs + "]"
+	case *Proc:
+		if n.Label == nil {
+			return compact(n.Body)
+		}
+		return compact(n.Label) + "::" + compact(n.Body)
+	case *ProcLabel:
+		return subscripted(n.Name, n.Subscripts)
+	case *Range:
+		return n.Var + ":" + compact(n.Lo) + ".." + compact(n.Hi)
+	case *AlternativeCmd:
+		return "[" + join(n.Cmds, " □ ") + "]"
+	case *RepetitiveCmd:
+		return "*" + compact(n.Alt)
+	case *GuardedCmd:
+		return compactGuard(n) + " → " + compact(n.Body)
+	case *Guard:
+		elems := make([]string, 0, len(n.List)+1)
+		for _, e := range n.List {
+			elems = append(elems, compact(e))
+		}
+		if n.Input != nil {
+			elems = append(elems, compact(n.Input))
+		}
+		if n.Timeout != nil {
+			elems = append(elems, compact(n.Timeout))
+		}
+		if n.Prob != nil {
+			elems = append(elems, compact(n.Prob))
+		}
+		return strings.Join(elems, "; ")
+	case *Timeout:
+		return "timeout " + compact(n.Duration)
+	case *Prob:
+		return "prob " + compact(n.Weight)
+	case *ProcRef:
+		if len(n.Renames) == 0 {
+			return n.Name
+		}
+		return n.Name + "[[" + join(n.Renames, ", ") + "]]"
+	case *Renaming:
+		return n.From.Name + " <- " + n.To.Name
+	case Expr:
+		return expr(n, 0)
+	}
+	return ""
+}
+
+func compactGuard(gc *GuardedCmd) string {
+	if len(gc.Ranges) == 0 {
+		return compact(gc.Guard)
+	}
+	return "(" + join(gc.Ranges, ", ") + ") " + compact(gc.Guard)
+}
+
+// join returns the nodes of the slice nodes separated by sep.
+func join[T Node](nodes []T, sep string) string {
+	s := make([]string, len(nodes))
+	for i, n := range nodes {
+		s[i] = compact(n)
+	}
+	return strings.Join(s, sep)
+}
+
+func subscripted(name string, subscripts []Expr) string {
+	if len(subscripts) == 0 {
+		return name
+	}
+	return name + "(" + join(subscripts, ", ") + ")"
+}
+
+// expr returns e as an operand of an operator of precedence prec.
+func expr(e Expr, prec int) string {
+	switch e := e.(type) {
+	case *Ident:
+		return e.Name
+	case *IntLit:
+		return strconv.Itoa(e.Value)
+	case *CharLit:
+		return strconv.QuoteRune(e.Value)
+	case *StringLit:
+		return strconv.Quote(e.Value)
+	case *ParenExpr:
+		return "(" + expr(e.X, 0) + ")"
+	case *UnaryExpr:
+		if e.Op == NOT {
+			// ¬ binds weaker than comparisons.
+			s := "¬" + expr(e.X, precedence[EQ])
+			if prec >= precedence[EQ] {
+				return "(" + s + ")"
+			}
+			return s
+		}
+		// -(-x) is not a comment.
+		if _, ok := e.X.(*UnaryExpr); ok {
+			return e.Op.String() + "(" + expr(e.X, 0) + ")"
+		}
+		return e.Op.String() + expr(e.X, precedence[MUL]+1)
+	case *BinaryExpr:
+		p := precedence[e.Op]
+		s := expr(e.X, p) + " " + e.Op.String() + " " + expr(e.Y, p+1)
+		if p < prec {
+			return "(" + s + ")"
+		}
+		return s
+	case *StructuredExpr:
+		args := join(e.Args, ", ")
+		// (x,) distinguishes a structured value of one component
+		// from a parenthesized expression.
+		if e.Constructor == "" && len(e.Args) == 1 {
+			args += ","
+		}
+		return e.Constructor + "(" + args + ")"
+	case *Range:
+		return compact(e)
+	}
+	return ""
+}
@@ -0,0 +1,162 @@
+package lang_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/changkun/gobase/csp/lang"
+)
+
+// tree returns the syntax tree of prog without positions.
+func tree(t *testing.T, prog *lang.Program) string {
+	buf := bytes.Buffer{}
+	if err := lang.Fprint(&buf, prog); err != nil {
+		t.Fatalf("%v: %v", t.Name(), err)
+	}
+	return buf.String()
+}
+
+func TestSource(t *testing.T) {
+	progs := solutions(t, "S31_COPY", "S32_SQUASH", "S32_SQUASH_EX",
+		"S33_DISASSEMBLE", "S34_ASSEMBLE", "S35_Reformat", "S36_ConwayProblem")
+	progs["definitions"] = `COPY = (*[c:character; west?c -> east!c])
+[X::COPY || Y::(x, y) := (y, x); print!"Hello, CSP"; print!-x mod 3 ≠ 1 ∧ ¬true]`
+	progs["set"] = `S::
+content(0..n-1)integer; size:integer; size := 0;
+*[n:integer; X?has(n) -> SEARCH; X!(i<size)
+□ n:integer; X?insert(n) -> SEARCH;
+      [i<size -> skip
+      □i = size; size<n ->
+         content(size) := n; size := size+1
+]]`
+	progs["semaphore"] = `S::val:integer; val:=0;
+*[(i:1..100)X(i)?V()->val:=val+1
+□ (i:1..100)val>0;X(i)?P()->val:=val-1]`
+	progs["assertions"] = `P = (skip)
+assert P :[deadlock free]
+SPEC = (east!1)
+assert SPEC [FD= P]
+[X::P || Y::SPEC]`
+	progs["renaming"] = `COPY = (*[c:character; west?c -> east!c])
+[X::COPY[[east <- Y]] || Y::COPY[[west<-X, east<-out]]]`
+	progs["interleaving"] = `COPY = (*[c:character; west?c -> east!c])
+X::COPY ||| Y::[Z::COPY ||| W::COPY]`
+	progs["hiding"] = `LOG = (*[c:character; west?c -> log!c; east!c]) \ (log, debug)
+assert LOG :[divergence free]`
+	progs["timed"] = `[X::*[c:character; west?c -> wait 2; Y!c □ timeout 5 -> Y!'?'] || Y::n:integer; n := 1;
+*[c:character; X?c -> east!c □ n > 0; timeout n*2 -> n := n-1]]`
+	progs["probabilistic"] = `n:integer; n := 0; *[n < 10; prob 3 -> n := n+1 □ n < 10; prob n+1 -> east!n]`
+	progs["operators"] = "X::x := (-(-1) + 2) * 3; b := ¬(x = 1) = (¬b ∨ x < 2); y := (1,); z := x - (y - 1)"
+
+	for name, src := range progs {
+		prog, err := lang.Parse(src)
+		if err != nil {
+			t.Fatalf("%v: %v: %v", t.Name(), name, err)
+		}
+		out := lang.Source(prog)
+		again, err := lang.Parse(out)
+		if err != nil {
+			t.Fatalf("%v: %v: cannot parse printed source: %v\n%s", t.Name(), name, err, out)
+		}
+		if want, got := tree(t, prog), tree(t, again); want != got {
+			t.Fatalf("%v: %v: printed source differs:\n%s", t.Name(), name, out)
+		}
+		if printed := lang.Source(again); printed != out {
+			t.Fatalf("%v: %v: expected: %v, got: %v", t.Name(), name, out, printed)
+		}
+	}
+}
+
+func TestSourceLayout(t *testing.T) {
+	tests := []struct {
+		src, want string
+	}{
+		{
+			src:  "X::*[c:character; west?c -> east!c]",
+			want: "X::*[c:character; west?c → east!c]\n",
+		},
+		{
+			src: `X :: *[c:character; west?c ->
+  [ c != asterisk -> east!c
+   □ c = asterisk -> west?c;
+         [ c != asterisk -> east!asterisk; east!c
+          □ c = asterisk -> east!upward arrow
+  ] ]    ]`,
+			want: `X::*[c:character; west?c →
+    [c ≠ asterisk → east!c
+    □ c = asterisk →
+        west?c;
+        [c ≠ asterisk → east!asterisk; east!c
+        □ c = asterisk → east!upward arrow
+        ]
+    ]
+]
+`,
+		},
+		{
+			src: "COPY = (*[c:character; west?c -> east!c]) [west::DISASSEMBLE||X::COPY||east::ASSEMBLE]",
+			want: `COPY = (*[c:character; west?c → east!c])
+[west::DISASSEMBLE || X::COPY || east::ASSEMBLE]
+`,
+		},
+	}
+	for _, tt := range tests {
+		prog, err := lang.Parse(tt.src)
+		if err != nil {
+			t.Fatalf("%v: %v", t.Name(), err)
+		}
+		if got := lang.Source(prog); got != tt.want {
+			t.Fatalf("%v: expected:\n%v\ngot:\n%v", t.Name(), tt.want, got)
+		}
+	}
+}
+
+func TestFormatSource(t *testing.T) {
+	tests := []struct {
+		src, want string
+	}{
+		{
+			src: `-- copies west to east
+COPY = (*[c:character; west?c -> east!c])  -- forever
+comment the network of
+    the paper;
+[west::DISASSEMBLE||X::COPY -- the copy
+||east::ASSEMBLE]
+-- the end
+`,
+			want: `-- copies west to east
+-- forever
+COPY = (*[c:character; west?c → east!c])
+comment the network of
+    the paper;
+-- the copy
+-- the end
+[west::DISASSEMBLE || X::COPY || east::ASSEMBLE]
+`,
+		},
+		{
+			src:  "X::n:integer; n := 0; *[n<10 -> out!n ; n:=n+1]\n",
+			want: "X::n:integer; n := 0; *[n < 10 → out!n; n := n + 1]\n",
+		},
+		{
+			src:  "COPY = (*[c:character; west?c -> east!c])\n-- unused\n",
+			want: "COPY = (*[c:character; west?c → east!c])\n-- unused\n",
+		},
+	}
+	for _, tt := range tests {
+		got, err := lang.FormatSource([]byte(tt.src))
+		if err != nil {
+			t.Fatalf("%v: unexpected error: %v", t.Name(), err)
+		}
+		if string(got) != tt.want {
+			t.Fatalf("%v: expected:\n%v\ngot:\n%v", t.Name(), tt.want, string(got))
+		}
+		again, err := lang.FormatSource(got)
+		if err != nil || string(again) != string(got) {
+			t.Fatalf("%v: formatting is not idempotent:\n%v\ngot:\n%v", t.Name(), string(got), string(again))
+		}
+	}
+	if _, err := lang.FormatSource([]byte("X::*[")); err == nil {
+		t.Fatalf("%v: expected an error", t.Name())
+	}
+}
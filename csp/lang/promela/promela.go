@@ -0,0 +1,641 @@
+// Package promela exports programs of package lang as models in
+// Promela, the language of the model checker SPIN, such that SPIN
+// verifies them.
+//
+// The process COPY
+//
+//   X :: *[c:character; west?c -> east!c]
+//
+// becomes
+//
+//   chan west = [0] of { int };
+//   chan east = [0] of { int };
+//
+//   active proctype X() {
+//   	int c;
+//   	do
+//   	:: west?c ->
+//   		east!c
+//   	od
+//   }
+//
+// Every process of the parallel command of a program is a proctype, of
+// which SPIN starts an instance, and every other program a proctype of
+// its own. Processes communicate on rendezvous channels named by the
+// communicating processes, such as X_Y for the values X outputs to Y,
+// and by the constructors of structured values, such as X_Y_has for
+// has(n) whose components are the fields of the messages. An external
+// name is a channel named by itself, whose other end is left to an
+// environment the model is completed with. Characters are integers, the
+// code points of the characters, and variables are declared at the
+// start of their proctype, renamed if declared more than once.
+//
+// An alternative command becomes an if statement and a repetitive
+// command a do statement, whose options are its guarded commands. A
+// repetitive command terminates once all of its boolean guards are
+// false, by an option breaking out of its loop, unless it has guards
+// without boolean conditions. Promela has no failure of processes and
+// no termination of processes for each other: an alternative command
+// whose guards are false blocks, and an input guard waits for its
+// source forever. A guard of Promela is the first statement of an
+// option only, hence a guard of both a boolean condition and an input
+// commits to its input once the condition holds. Arrays, bound
+// variables, nested parallel commands, interleaving, renaming, wait
+// commands, timeouts and probabilistic choices are not supported.
+package promela
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/changkun/gobase/csp/lang"
+)
+
+// Config configures an exported model.
+type Config struct {
+	// Main is the name of the proctype of a program which is not a
+	// parallel command. The default is main.
+	Main string
+}
+
+// Export returns the Promela model of prog. A program without a body,
+// such as the descriptions of package extract, is exported as a
+// proctype for each of its definitions, which SPIN does not start.
+func Export(prog *lang.Program, cfg Config) (src []byte, err error) {
+	if cfg.Main == "" {
+		cfg.Main = "main"
+	}
+	x := &exporter{
+		cfg:    cfg,
+		defs:   map[string]*lang.Definition{},
+		names:  map[string]bool{},
+		locals: map[string]bool{},
+		chans:  map[string]*channel{},
+	}
+	for _, d := range prog.Defs {
+		x.defs[d.Name] = d
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			f, ok := r.(failure)
+			if !ok {
+				panic(r)
+			}
+			src, err = nil, f.err
+		}
+	}()
+	x.program(prog)
+	return x.source(), nil
+}
+
+// failure is the error of an export, raised by errorf.
+type failure struct{ err *lang.Error }
+
+func errorf(pos lang.Pos, format string, args ...interface{}) {
+	panic(failure{&lang.Error{Pos: pos, Msg: fmt.Sprintf(format, args...)}})
+}
+
+// exporter exports a program.
+type exporter struct {
+	cfg    Config
+	defs   map[string]*lang.Definition
+	names  map[string]bool // names of the channels and proctypes
+	locals map[string]bool // names of the variables of all proctypes
+	chans  map[string]*channel
+	order  []*channel
+	procs  []*proctype
+}
+
+// channel is a channel of the model.
+type channel struct {
+	name   string
+	fields []string // the types of the fields
+}
+
+// proctype is a proctype in translation.
+type proctype struct {
+	label     string
+	name      string
+	active    bool
+	peers     map[string]bool // the labels of the processes of the parallel command
+	scope     *scope
+	bases     map[string]bool // the names of the variables
+	decls     []string
+	body      []string
+	expanding map[string]bool // the definitions expanded in place
+}
+
+// scope is a scope of variables.
+type scope struct {
+	outer *scope
+	vars  map[string]*variable
+}
+
+// variable is a variable of a proctype.
+type variable struct {
+	name string
+	typ  string
+}
+
+func (p *proctype) lookup(name string) *variable {
+	for s := p.scope; s != nil; s = s.outer {
+		if v, ok := s.vars[name]; ok {
+			return v
+		}
+	}
+	return nil
+}
+
+func (x *exporter) declare(p *proctype, id *lang.Ident, t lang.Type) {
+	nt, ok := t.(*lang.NamedType)
+	if !ok {
+		errorf(t.Pos(), "arrays are not supported")
+	}
+	if _, ok := p.scope.vars[id.Name]; ok {
+		errorf(id.Pos(), "%s redeclared", id.Name)
+	}
+	typ, ok := types[nt.Name]
+	if !ok {
+		errorf(nt.Pos(), "undefined type %s", nt.Name)
+	}
+	base := ident(id.Name)
+	name := base
+	for n := 2; p.bases[name] || x.names[name]; n++ {
+		name = base + "_" + strconv.Itoa(n)
+	}
+	p.bases[name] = true
+	x.locals[name] = true
+	p.scope.vars[id.Name] = &variable{name: name, typ: nt.Name}
+	p.decls = append(p.decls, typ+" "+name+";")
+}
+
+// types are the Promela types of the types of the notation.
+var types = map[string]string{
+	"integer":   "int",
+	"boolean":   "bool",
+	"character": "int",
+}
+
+// keywords are the keywords and predefined names of Promela which are
+// valid identifiers of the notation.
+var keywords = map[string]bool{
+	"active": true, "assert": true, "atomic": true, "bit": true, "bool": true,
+	"break": true, "byte": true, "chan": true, "d_step": true, "do": true,
+	"else": true, "empty": true, "enabled": true, "eval": true, "fi": true,
+	"for": true, "full": true, "goto": true, "hidden": true, "if": true,
+	"in": true, "init": true, "inline": true, "int": true, "len": true,
+	"local": true, "mtype": true, "nempty": true, "never": true, "nfull": true,
+	"od": true, "of": true, "pid": true, "printf": true, "priority": true,
+	"proctype": true, "provided": true, "run": true, "select": true,
+	"short": true, "show": true, "timeout": true, "typedef": true,
+	"unless": true, "unsigned": true, "xr": true, "xs": true,
+}
+
+// ident returns the Promela identifier of the identifier s.
+func ident(s string) string {
+	s = strings.ReplaceAll(s, " ", "_")
+	if keywords[s] {
+		return s + "_"
+	}
+	return s
+}
+
+// unique returns a global name of the model based on s.
+func (x *exporter) unique(s string) string {
+	name := ident(s)
+	for n := 2; x.names[name] || x.locals[name]; n++ {
+		name = ident(s) + "_" + strconv.Itoa(n)
+	}
+	x.names[name] = true
+	return name
+}
+
+func (x *exporter) program(prog *lang.Program) {
+	body := prog.Body
+	if len(body.Stmts) == 0 {
+		for _, d := range prog.Defs {
+			x.proctype(d.Name, false, nil, d.Body)
+		}
+		return
+	}
+	par, ok := body.Stmts[0].(*lang.ParallelCmd)
+	if !ok || len(body.Stmts) > 1 {
+		x.proctype(x.cfg.Main, true, nil, body)
+		return
+	}
+
+	if par.Interleave {
+		errorf(par.Pos(), "interleaving is not supported")
+	}
+	labels := map[string]bool{}
+	for _, p := range par.Procs {
+		if p.Label == nil {
+			errorf(p.Pos(), "processes without labels are not supported")
+		}
+		if len(p.Label.Subscripts) > 0 {
+			errorf(p.Label.Pos(), "arrays of processes are not supported")
+		}
+		if labels[p.Label.Name] {
+			errorf(p.Label.Pos(), "duplicate process label %s", p.Label.Name)
+		}
+		labels[p.Label.Name] = true
+	}
+	for _, p := range par.Procs {
+		x.proctype(p.Label.Name, true, labels, p.Body)
+	}
+}
+
+// proctype translates the process label, which communicates with the
+// processes labelled by peers.
+func (x *exporter) proctype(label string, active bool, peers map[string]bool, body *lang.CmdList) {
+	p := &proctype{
+		label:     label,
+		name:      x.unique(label),
+		active:    active,
+		peers:     peers,
+		scope:     &scope{vars: map[string]*variable{}},
+		bases:     map[string]bool{},
+		expanding: map[string]bool{},
+	}
+	x.procs = append(x.procs, p)
+	p.body = x.list(p, body.Stmts)
+}
+
+// list returns the lines of the statements of stmts, separated by
+// semicolons, skip if there are none.
+func (x *exporter) list(p *proctype, stmts []lang.Stmt) []string {
+	var lines []string
+	for _, s := range stmts {
+		block := x.stmt(p, s)
+		if len(block) == 0 {
+			continue
+		}
+		if len(lines) > 0 {
+			lines[len(lines)-1] += ";"
+		}
+		lines = append(lines, block...)
+	}
+	if len(lines) == 0 {
+		return []string{"skip"}
+	}
+	return lines
+}
+
+func (x *exporter) stmt(p *proctype, s lang.Stmt) []string {
+	switch s := s.(type) {
+	case *lang.Declaration:
+		for _, id := range s.Names {
+			x.declare(p, id, s.Type)
+		}
+		return nil
+	case *lang.SkipCmd:
+		return []string{"skip"}
+	case *lang.ProcRef:
+		d, ok := x.defs[s.Name]
+		if !ok {
+			errorf(s.Pos(), "undefined process %s", s.Name)
+		}
+		if len(s.Renames) > 0 {
+			errorf(s.Renames[0].Pos(), "renaming is not supported")
+		}
+		if p.expanding[s.Name] {
+			errorf(s.Pos(), "recursive process %s is not supported", s.Name)
+		}
+		p.expanding[s.Name] = true
+		defer delete(p.expanding, s.Name)
+		p.scope = &scope{outer: p.scope, vars: map[string]*variable{}}
+		defer func() { p.scope = p.scope.outer }()
+		return x.list(p, d.Body.Stmts)
+	case *lang.AssignmentCmd:
+		id, ok := s.Target.(*lang.Ident)
+		if !ok {
+			errorf(s.Target.Pos(), "structured values are not supported")
+		}
+		v := p.lookup(id.Name)
+		if v == nil {
+			errorf(id.Pos(), "undefined variable %s", id.Name)
+		}
+		if typ := x.typeOf(p, s.Value); typ != v.typ {
+			errorf(s.Value.Pos(), "cannot assign %s to %s of type %s", typ, id.Name, v.typ)
+		}
+		return []string{v.name + " = " + x.expr(p, s.Value)}
+	case *lang.InputCmd:
+		return []string{x.input(p, s)}
+	case *lang.OutputCmd:
+		c, args := x.port(p, s.Dest, s.Value, false)
+		fields := make([]string, len(args))
+		for i, a := range args {
+			fields[i] = x.expr(p, a)
+		}
+		if len(fields) == 0 {
+			fields = []string{"0"}
+		}
+		return []string{c.name + "!" + strings.Join(fields, ",")}
+	case *lang.AlternativeCmd:
+		lines, _ := x.options(p, s)
+		return append(append([]string{"if"}, lines...), "fi")
+	case *lang.RepetitiveCmd:
+		lines, exit := x.options(p, s.Alt)
+		lines = append([]string{"do"}, lines...)
+		if exit != "" {
+			lines = append(lines, ":: "+exit+" -> break")
+		}
+		return append(lines, "od")
+	case *lang.WaitCmd:
+		errorf(s.Pos(), "wait commands are not supported")
+	case *lang.ParallelCmd:
+		errorf(s.Pos(), "nested parallel commands are not supported")
+	}
+	errorf(s.Pos(), "unexpected %T", s)
+	return nil
+}
+
+// options returns the lines of the options of the guarded commands of
+// alt, and the condition terminating alt as a repetitive command, empty
+// if it never terminates.
+func (x *exporter) options(p *proctype, alt *lang.AlternativeCmd) (lines []string, exit string) {
+	var conds []string
+	for _, gc := range alt.Cmds {
+		if len(gc.Ranges) > 0 {
+			errorf(gc.Ranges[0].Pos(), "bound variables are not supported")
+		}
+		if gc.Guard.Timeout != nil {
+			errorf(gc.Guard.Timeout.Pos(), "timeouts are not supported")
+		}
+		if gc.Guard.Prob != nil {
+			errorf(gc.Guard.Prob.Pos(), "probabilistic choices are not supported")
+		}
+		p.scope = &scope{outer: p.scope, vars: map[string]*variable{}}
+		var guard []string
+		if cond := x.cond(p, gc.Guard); cond != nil {
+			guard = append(guard, x.expr(p, cond))
+			conds = append(conds, x.operand(p, cond, precedence[lang.OR]))
+		}
+		if gc.Guard.Input != nil {
+			guard = append(guard, x.input(p, gc.Guard.Input))
+		}
+		if len(guard) == 0 {
+			guard = []string{"true"}
+		}
+		lines = append(lines, ":: "+strings.Join(guard, " -> ")+" ->")
+		for _, l := range x.list(p, gc.Body.Stmts) {
+			lines = append(lines, "\t"+l)
+		}
+		p.scope = p.scope.outer
+	}
+	if len(conds) < len(alt.Cmds) {
+		return lines, ""
+	}
+	return lines, "!(" + strings.Join(conds, " || ") + ")"
+}
+
+// cond returns the conjunction of the boolean guards of g, nil if
+// they are true, and declares the variables of g.
+func (x *exporter) cond(p *proctype, g *lang.Guard) lang.Expr {
+	var cond lang.Expr
+	for _, n := range g.List {
+		switch n := n.(type) {
+		case *lang.Declaration:
+			for _, id := range n.Names {
+				x.declare(p, id, n.Type)
+			}
+		case lang.Expr:
+			if typ := x.typeOf(p, n); typ != "boolean" {
+				errorf(n.Pos(), "guard of type %s", typ)
+			}
+			if id, ok := n.(*lang.Ident); ok && id.Name == "true" {
+				continue
+			}
+			if cond == nil {
+				cond = n
+			} else {
+				cond = &lang.BinaryExpr{X: cond, Op: lang.AND, Y: n}
+			}
+		}
+	}
+	return cond
+}
+
+// input returns the statement of the input s.
+func (x *exporter) input(p *proctype, s *lang.InputCmd) string {
+	c, args := x.port(p, s.Source, s.Target, true)
+	fields := make([]string, len(args))
+	for i, a := range args {
+		fields[i] = p.lookup(a.(*lang.Ident).Name).name
+	}
+	if len(fields) == 0 {
+		fields = []string{"_"}
+	}
+	return c.name + "?" + strings.Join(fields, ",")
+}
+
+// port returns the channel of the communication of the value v with
+// the process n, and the fields of its messages.
+func (x *exporter) port(p *proctype, n *lang.ProcName, v lang.Expr, input bool) (*channel, []lang.Expr) {
+	if len(n.Subscripts) > 0 {
+		errorf(n.Pos(), "arrays of processes are not supported")
+	}
+	if n.Name == p.label {
+		errorf(n.Pos(), "process %s communicates with itself", n.Name)
+	}
+	cons, args := "", []lang.Expr{v}
+	if s, ok := v.(*lang.StructuredExpr); ok {
+		if p.lookup(s.Constructor) != nil {
+			errorf(s.Pos(), "arrays are not supported")
+		}
+		cons, args = s.Constructor, s.Args
+	}
+	fields := make([]string, len(args))
+	for i, a := range args {
+		if !input {
+			fields[i] = x.typeOf(p, a)
+			continue
+		}
+		id, ok := a.(*lang.Ident)
+		if !ok {
+			errorf(a.Pos(), "structured values are not supported")
+		}
+		v := p.lookup(id.Name)
+		if v == nil {
+			errorf(id.Pos(), "undefined variable %s", id.Name)
+		}
+		fields[i] = v.typ
+	}
+
+	name, key := n.Name, "|"+n.Name+"|"+cons
+	if p.peers[n.Name] {
+		src, dst := p.label, n.Name
+		if input {
+			src, dst = n.Name, p.label
+		}
+		name, key = src+"_"+dst, src+"|"+dst+"|"+cons
+	}
+	if cons != "" {
+		name += "_" + cons
+	}
+	c, ok := x.chans[key]
+	if !ok {
+		c = &channel{name: x.unique(name), fields: fields}
+		x.chans[key] = c
+		x.order = append(x.order, c)
+	}
+	if x.fields(c.fields) != x.fields(fields) {
+		errorf(v.Pos(), "channel %s carries %s and %s", c.name, x.fields(c.fields), x.fields(fields))
+	}
+	return c, args
+}
+
+// fields returns the Promela fields of the messages of a channel.
+func (x *exporter) fields(fields []string) string {
+	if len(fields) == 0 {
+		return "{ bit }"
+	}
+	types := make([]string, len(fields))
+	for i, f := range fields {
+		types[i] = typeNames[f]
+	}
+	return "{ " + strings.Join(types, ", ") + " }"
+}
+
+// typeNames are the Promela types of the values of the notation, as
+// fields of messages.
+var typeNames = map[string]string{
+	"integer":   "int",
+	"boolean":   "bool",
+	"character": "int",
+}
+
+// typeOf returns the type of e.
+func (x *exporter) typeOf(p *proctype, e lang.Expr) string {
+	switch e := e.(type) {
+	case *lang.IntLit:
+		return "integer"
+	case *lang.CharLit:
+		return "character"
+	case *lang.Ident:
+		if v := p.lookup(e.Name); v != nil {
+			return v.typ
+		}
+		switch e.Name {
+		case "true", "false":
+			return "boolean"
+		case "space", "asterisk", "upward arrow":
+			return "character"
+		}
+		errorf(e.Pos(), "undefined variable %s", e.Name)
+	case *lang.ParenExpr:
+		return x.typeOf(p, e.X)
+	case *lang.UnaryExpr:
+		if e.Op == lang.NOT {
+			return "boolean"
+		}
+		return "integer"
+	case *lang.BinaryExpr:
+		if precedence[e.Op] >= precedence[lang.ADD] {
+			return "integer"
+		}
+		return "boolean"
+	case *lang.StringLit:
+		errorf(e.Pos(), "arrays are not supported")
+	}
+	errorf(e.Pos(), "structured values are not supported")
+	return ""
+}
+
+// operators are the Promela operators of the notation.
+var operators = map[lang.Token]string{
+	lang.OR: "||", lang.AND: "&&",
+	lang.EQ: "==", lang.NEQ: "!=", lang.LT: "<", lang.LEQ: "<=", lang.GT: ">", lang.GEQ: ">=",
+	lang.ADD: "+", lang.SUB: "-",
+	lang.MUL: "*", lang.DIV: "/", lang.MOD: "%",
+}
+
+// precedence are the precedences of the binary operators of Promela.
+var precedence = map[lang.Token]int{
+	lang.OR: 1, lang.AND: 2,
+	lang.EQ: 3, lang.NEQ: 3,
+	lang.LT: 4, lang.LEQ: 4, lang.GT: 4, lang.GEQ: 4,
+	lang.ADD: 5, lang.SUB: 5,
+	lang.MUL: 6, lang.DIV: 6, lang.MOD: 6,
+}
+
+// expr returns the Promela expression of e.
+func (x *exporter) expr(p *proctype, e lang.Expr) string {
+	switch e := e.(type) {
+	case *lang.IntLit:
+		return strconv.Itoa(e.Value)
+	case *lang.CharLit:
+		return char(e.Value)
+	case *lang.Ident:
+		if v := p.lookup(e.Name); v != nil {
+			return v.name
+		}
+		switch e.Name {
+		case "space":
+			return char(' ')
+		case "asterisk":
+			return char('*')
+		case "upward arrow":
+			return char('↑')
+		}
+		return e.Name
+	case *lang.ParenExpr:
+		return "(" + x.expr(p, e.X) + ")"
+	case *lang.UnaryExpr:
+		if e.Op == lang.NOT {
+			return "!" + x.operand(p, e.X, len(precedence))
+		}
+		return "-" + x.operand(p, e.X, len(precedence))
+	case *lang.BinaryExpr:
+		prec := precedence[e.Op]
+		return x.operand(p, e.X, prec) + " " + operators[e.Op] + " " + x.operand(p, e.Y, prec+1)
+	}
+	x.typeOf(p, e)
+	return ""
+}
+
+// operand returns the Promela expression of e as an operand of an
+// operator of precedence prec.
+func (x *exporter) operand(p *proctype, e lang.Expr, prec int) string {
+	if b, ok := e.(*lang.BinaryExpr); ok && precedence[b.Op] < prec {
+		return "(" + x.expr(p, e) + ")"
+	}
+	return x.expr(p, e)
+}
+
+// char returns the Promela constant of the character c, a character
+// literal if Promela has one, or else its code point.
+func char(c rune) string {
+	if c < unicode.MaxASCII && unicode.IsPrint(c) && c != '\'' && c != '\\' {
+		return "'" + string(c) + "'"
+	}
+	return strconv.Itoa(int(c))
+}
+
+// source returns the model.
+func (x *exporter) source() []byte {
+	buf := bytes.Buffer{}
+	for _, c := range x.order {
+		fmt.Fprintf(&buf, "chan %s = [0] of %s;\n", c.name, x.fields(c.fields))
+	}
+	for _, p := range x.procs {
+		if buf.Len() > 0 {
+			fmt.Fprintf(&buf, "\n")
+		}
+		if p.active {
+			fmt.Fprintf(&buf, "active ")
+		}
+		fmt.Fprintf(&buf, "proctype %s() {\n", p.name)
+		for _, d := range p.decls {
+			fmt.Fprintf(&buf, "\t%s\n", d)
+		}
+		for _, l := range p.body {
+			fmt.Fprintf(&buf, "\t%s\n", l)
+		}
+		fmt.Fprintf(&buf, "}\n")
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,207 @@
+package promela_test
+
+import (
+	"testing"
+
+	"github.com/changkun/gobase/csp/lang"
+	"github.com/changkun/gobase/csp/lang/promela"
+)
+
+func TestExport(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{
+			src: "X::*[c:character; west?c → east!c]",
+			want: `chan west = [0] of { int };
+chan east = [0] of { int };
+
+active proctype X() {
+	int c;
+	do
+	:: west?c ->
+		east!c
+	od
+}
+`,
+		},
+		{
+			src: `X::*[c:character; west?c →
+    [c ≠ asterisk → east!c
+    □ c = asterisk → west?c;
+        [c ≠ asterisk → east!asterisk; east!c □ c = asterisk → east!upward arrow]
+    ]
+]`,
+			want: `chan west = [0] of { int };
+chan east = [0] of { int };
+
+active proctype X() {
+	int c;
+	do
+	:: west?c ->
+		if
+		:: c != '*' ->
+			east!c
+		:: c == '*' ->
+			west?c;
+			if
+			:: c != '*' ->
+				east!'*';
+				east!c
+			:: c == '*' ->
+				east!8593
+			fi
+		fi
+	od
+}
+`,
+		},
+		{
+			src: "X::n:integer; n := 0; *[n < 3 ∧ n ≥ 0 → out!n; n := (n + 1) * 2]; done!-n mod 3",
+			want: `chan out = [0] of { int };
+chan done = [0] of { int };
+
+active proctype X() {
+	int n;
+	n = 0;
+	do
+	:: n < 3 && n >= 0 ->
+		out!n;
+		n = (n + 1) * 2
+	:: !(n < 3 && n >= 0) -> break
+	od;
+	done!-n % 3
+}
+`,
+		},
+		{
+			src: "S::n, m:integer; n := 0; *[n < 10; X?has(m) → n := n + m □ n < 10; X?V() → n := n - 1]",
+			want: `chan X_has = [0] of { int };
+chan X_V = [0] of { bit };
+
+active proctype S() {
+	int n;
+	int m;
+	n = 0;
+	do
+	:: n < 10 -> X_has?m ->
+		n = n + m
+	:: n < 10 -> X_V?_ ->
+		n = n - 1
+	:: !(n < 10 || n < 10) -> break
+	od
+}
+`,
+		},
+		{
+			src: `COPY = (*[c:character; west?c → east!c])
+[west::*[c:character; in?c → X!c] || X::*[c:character; west?c → east!c; east!P()] || east::*[c:character; X?c → out!c □ X?P() → skip]]`,
+			want: `chan in_ = [0] of { int };
+chan west_X = [0] of { int };
+chan X_east = [0] of { int };
+chan X_east_P = [0] of { bit };
+chan out = [0] of { int };
+
+active proctype west() {
+	int c;
+	do
+	:: in_?c ->
+		west_X!c
+	od
+}
+
+active proctype X() {
+	int c;
+	do
+	:: west_X?c ->
+		X_east!c;
+		X_east_P!0
+	od
+}
+
+active proctype east() {
+	int c;
+	do
+	:: X_east?c ->
+		out!c
+	:: X_east_P?_ ->
+		skip
+	od
+}
+`,
+		},
+		{
+			src: "COPY = (c:character; west?c; east!c)\nRUN = (x:integer; x := 1; [x > 0 → COPY; COPY □ x ≤ 0 → skip]; out!x)",
+			want: `chan west = [0] of { int };
+chan east = [0] of { int };
+chan out = [0] of { int };
+
+proctype COPY() {
+	int c;
+	west?c;
+	east!c
+}
+
+proctype RUN() {
+	int x;
+	int c;
+	int c_2;
+	x = 1;
+	if
+	:: x > 0 ->
+		west?c;
+		east!c;
+		west?c_2;
+		east!c_2
+	:: x <= 0 ->
+		skip
+	fi;
+	out!x
+}
+`,
+		},
+	}
+	for _, tt := range tests {
+		prog, err := lang.Parse(tt.src)
+		if err != nil {
+			t.Fatalf("%v: %v", t.Name(), err)
+		}
+		got, err := promela.Export(prog, promela.Config{})
+		if err != nil {
+			t.Fatalf("%v: unexpected error: %v", t.Name(), err)
+		}
+		if string(got) != tt.want {
+			t.Fatalf("%v: expected:\n%v\ngot:\n%v", t.Name(), tt.want, string(got))
+		}
+	}
+}
+
+func TestExportErrors(t *testing.T) {
+	tests := []struct {
+		src string
+		err string
+	}{
+		{src: "X::a:(1..3)integer; a(1) := 0", err: "1:6: arrays are not supported"},
+		{src: "X::*[(i:1..3) Y(i)?c() → skip]", err: "1:7: bound variables are not supported"},
+		{src: "X::[Y::skip || Z::skip]", err: "1:4: nested parallel commands are not supported"},
+		{src: "P = (out!1; P)\nX::P", err: "1:13: recursive process P is not supported"},
+		{src: "P = (out!1)\nX::P[[out <- log]]", err: "2:7: renaming is not supported"},
+		{src: "[X::out!1 ||| Y::out!2]", err: "1:1: interleaving is not supported"},
+		{src: "X::out!n", err: "1:8: undefined variable n"},
+		{src: "X::c:character; c := true", err: "1:22: cannot assign boolean to c of type character"},
+		{src: "X::out!1; out!true", err: "1:15: channel out carries { int } and { bool }"},
+		{src: "[X::Y!1 || X::skip]", err: "1:12: duplicate process label X"},
+		{src: "X::X!1", err: "1:4: process X communicates with itself"},
+	}
+	for _, tt := range tests {
+		prog, err := lang.Parse(tt.src)
+		if err != nil {
+			t.Fatalf("%v: %v", t.Name(), err)
+		}
+		_, err = promela.Export(prog, promela.Config{})
+		if err == nil || err.Error() != tt.err {
+			t.Fatalf("%v: %q: expected: %v, got: %v", t.Name(), tt.src, tt.err, err)
+		}
+	}
+}
@@ -0,0 +1,466 @@
+// Package repl implements an interactive read-eval-print loop of the
+// notation of package lang: definitions are remembered, a program runs
+// in the background while the user feeds values to its external
+// sources and watches what it outputs to its external destinations.
+//
+// A line starting with a colon is a command of the loop:
+//
+//   :send west 'a', 'b'   output values to the external source west
+//   :chars west Hello     output the characters of a text to west
+//   :close west           terminate the external source west
+//   :wait                 wait for the running program to terminate
+//   :stop                 stop the running program
+//   :debug                step through the programs started next
+//   :step                 let the stopped process continue
+//   :guard 2              let the stopped process select its guard 2
+//   :continue             continue without stopping
+//   :defs                 list the definitions
+//   :help                 list the commands
+//   :quit                 leave the loop
+//
+// Any other input is the source of definitions or of a program, which
+// may span several lines until its brackets are balanced:
+//
+//   csp> COPY = (*[c:character; west?c -> east!c])
+//   defined COPY
+//   csp> X::COPY
+//   csp> :chars west Hi
+//   east!'H'
+//   east!'i'
+//   csp> :close west
+//   csp> :wait
+//   terminated
+//
+// While debugging, a process stops before every input, output,
+// alternative and repetitive command, and the loop prints the guards
+// it may select and the state of every process:
+//
+//   csp> :debug
+//   debugging on
+//   csp> [X::Y!1 || Y::n:integer; X?n]
+//   X stops at Y!1
+//     program running
+//     X at Y!1
+//     Y running
+//   csp> :step
+//   Y stops at X?n
+//     program running
+//     X at Y!1
+//     Y at X?n: n = undefined
+//
+// The loop keeps reading commands, such as :send for a program waiting
+// for input. A process stopped at an alternative command selects any
+// ready guard after :step, or waits for the guard chosen by :guard.
+// :step and :guard wait for the next stop of a running program.
+package repl
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/changkun/gobase/csp"
+	"github.com/changkun/gobase/csp/lang"
+)
+
+// REPL is a read-eval-print loop.
+type REPL struct {
+	// Prompt is printed before reading a line, an empty prompt
+	// suits non-interactive input.
+	Prompt string
+
+	in  *bufio.Scanner
+	out io.Writer
+	mu  sync.Mutex // guards out
+
+	defs    map[string]*lang.Definition
+	running *program
+	debug   bool
+}
+
+// program is a running program.
+type program struct {
+	r      *csp.Running
+	inputs map[string]chan lang.Value
+	done   chan struct{} // closed once all outputs and the result are printed
+
+	steps    chan int      // guards selected by :step and :guard
+	resume   chan struct{} // closed by :continue
+	stopping chan struct{} // closed by :stop
+}
+
+// New returns a loop reading from in and printing to out.
+func New(in io.Reader, out io.Writer) *REPL {
+	return &REPL{
+		Prompt: "csp> ",
+		in:     bufio.NewScanner(in),
+		out:    out,
+		defs:   map[string]*lang.Definition{},
+	}
+}
+
+// Run runs the loop until its input ends, :quit is entered, or ctx is
+// done. A program still running is stopped.
+func (r *REPL) Run(ctx context.Context) error {
+	defer r.stop()
+	for {
+		src, ok := r.read()
+		if !ok {
+			return r.in.Err()
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if strings.HasPrefix(src, ":") {
+			if quit := r.command(ctx, src); quit {
+				return nil
+			}
+			continue
+		}
+		if strings.TrimSpace(src) != "" {
+			r.eval(ctx, src)
+		}
+	}
+}
+
+func (r *REPL) printf(format string, args ...interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	fmt.Fprintf(r.out, format, args...)
+}
+
+// read reads a line, or several lines until the brackets and
+// parentheses of the source are balanced.
+func (r *REPL) read() (string, bool) {
+	prompt := r.Prompt
+	lines := []string{}
+	for {
+		if prompt != "" {
+			r.printf("%s", prompt)
+		}
+		if !r.in.Scan() {
+			return strings.Join(lines, "\n"), len(lines) > 0
+		}
+		lines = append(lines, r.in.Text())
+		src := strings.Join(lines, "\n")
+		if strings.HasPrefix(src, ":") || depth(src) <= 0 {
+			return src, true
+		}
+		if prompt != "" {
+			prompt = strings.Repeat(".", len(strings.TrimSpace(r.Prompt))) + " "
+		}
+	}
+}
+
+// depth returns the number of brackets and parentheses src leaves open.
+func depth(src string) int {
+	d := 0
+	l := lang.NewLexer(src)
+	for it := l.Next(); it.Tok != lang.EOF; it = l.Next() {
+		switch it.Tok {
+		case lang.LBRACK, lang.REP, lang.LPAREN:
+			d++
+		case lang.RBRACK, lang.RPAREN:
+			d--
+		}
+	}
+	return d
+}
+
+// eval remembers the definitions of src and starts its program, if any.
+// It prints the syntax errors of src with the lines they refer to.
+func (r *REPL) eval(ctx context.Context, src string) {
+	prog, err := lang.Parse(src)
+	if err != nil {
+		for _, e := range err.(lang.ErrorList) {
+			r.printf("error: %v\n%s", e, lang.Snippet(src, e.Pos))
+		}
+		return
+	}
+	if len(prog.Body.Stmts) > 0 && r.running != nil {
+		r.printf("error: a program is running, :wait or :stop it first\n")
+		return
+	}
+	for _, d := range prog.Defs {
+		r.defs[d.Name] = d
+		r.printf("defined %s\n", d.Name)
+	}
+	if len(prog.Body.Stmts) > 0 {
+		r.start(ctx, prog.Body)
+	}
+}
+
+// start runs body with all definitions in the background.
+func (r *REPL) start(ctx context.Context, body *lang.CmdList) {
+	prog := &lang.Program{Body: body}
+	for _, name := range r.names() {
+		prog.Defs = append(prog.Defs, r.defs[name])
+	}
+	in := &lang.Interpreter{
+		Program: prog,
+		Inputs:  map[string]<-chan lang.Value{},
+		Outputs: map[string]chan<- lang.Value{},
+	}
+	p := &program{
+		inputs:   map[string]chan lang.Value{},
+		done:     make(chan struct{}),
+		steps:    make(chan int),
+		resume:   make(chan struct{}),
+		stopping: make(chan struct{}),
+	}
+	if r.debug {
+		in.Debug = func(s lang.Stop) int { return r.stopped(p, s) }
+	}
+	inputs, outputs := lang.Externals(prog)
+	for _, name := range inputs {
+		ch := make(chan lang.Value)
+		p.inputs[name], in.Inputs[name] = ch, ch
+	}
+	wg := sync.WaitGroup{}
+	for _, name := range outputs {
+		ch := make(chan lang.Value)
+		in.Outputs[name] = ch
+		wg.Add(1)
+		go func(name string, ch <-chan lang.Value) {
+			defer wg.Done()
+			for v := range ch {
+				r.printf("%s!%s\n", name, lang.Format(v))
+			}
+		}(name, ch)
+	}
+
+	p.r = csp.Go(ctx, in)
+	go func() {
+		defer close(p.done)
+		err := p.r.Wait()
+		wg.Wait()
+		if err != nil {
+			r.printf("error: %v\n", err)
+			return
+		}
+		r.printf("terminated\n")
+	}()
+	r.running = p
+}
+
+// stopped prints the stop s of the program p and waits for :step or
+// :guard, it returns the selected guard.
+func (r *REPL) stopped(p *program, s lang.Stop) int {
+	select {
+	case <-p.resume:
+		return -1
+	case <-p.stopping:
+		return -1
+	default:
+	}
+	label := s.Process
+	if label == "" {
+		label = "program"
+	}
+	b := strings.Builder{}
+	fmt.Fprintf(&b, "%s stops at %s\n", label, lang.Source(s.Cmd))
+	for i, g := range s.Guards {
+		fmt.Fprintf(&b, "  guard %d: %s\n", i+1, g)
+	}
+	for _, ps := range s.Procs {
+		fmt.Fprintf(&b, "  %v\n", ps)
+	}
+	r.printf("%s", b.String())
+	select {
+	case i := <-p.steps:
+		return i
+	case <-p.resume:
+	case <-p.stopping:
+	}
+	return -1
+}
+
+// step lets the stopped process of the running program select guard
+// i, or any guard if i is -1, once it stops.
+func (r *REPL) step(i int) {
+	if r.running == nil {
+		r.printf("error: no program is running\n")
+		return
+	}
+	select {
+	case r.running.steps <- i:
+	case <-r.running.resume:
+		r.printf("error: the program continues without stopping\n")
+	case <-r.running.done:
+		r.wait()
+		r.printf("error: no program is running\n")
+	}
+}
+
+// stop stops the running program, if any.
+func (r *REPL) stop() {
+	if r.running == nil {
+		return
+	}
+	close(r.running.stopping)
+	r.running.r.Stop()
+	r.wait()
+}
+
+// wait waits for the running program, if any.
+func (r *REPL) wait() {
+	if r.running == nil {
+		return
+	}
+	<-r.running.done
+	r.running = nil
+}
+
+// names returns the names of the definitions in order.
+func (r *REPL) names() []string {
+	names := make([]string, 0, len(r.defs))
+	for name := range r.defs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+const help = `:send NAME EXPR, ...  output values to the external source NAME
+:chars NAME TEXT      output the characters of TEXT to NAME
+:close NAME           terminate the external source NAME
+:wait                 wait for the running program to terminate
+:stop                 stop the running program
+:debug                step through the programs started next
+:step                 let the stopped process continue
+:guard N              let the stopped process select its guard N
+:continue             continue without stopping
+:defs                 list the definitions
+:help                 list the commands
+:quit                 leave the loop
+`
+
+// command executes a command of the loop, it reports whether to quit.
+func (r *REPL) command(ctx context.Context, line string) bool {
+	fields := strings.Fields(line)
+	args := strings.TrimSpace(strings.TrimPrefix(line, fields[0]))
+	switch fields[0] {
+	case ":quit":
+		return true
+	case ":help":
+		r.printf("%s", help)
+	case ":defs":
+		for _, name := range r.names() {
+			r.printf("%s\n", name)
+		}
+	case ":wait":
+		r.wait()
+	case ":stop":
+		r.stop()
+	case ":debug":
+		r.debug = !r.debug
+		if r.debug {
+			r.printf("debugging on\n")
+		} else {
+			r.printf("debugging off\n")
+		}
+	case ":step":
+		r.step(-1)
+	case ":guard":
+		i, err := strconv.Atoi(args)
+		if err != nil || i < 1 {
+			r.printf("error: :guard requires the number of a guard\n")
+			return false
+		}
+		r.step(i - 1)
+	case ":continue":
+		if r.running == nil {
+			r.printf("error: no program is running\n")
+			return false
+		}
+		select {
+		case <-r.running.resume:
+		default:
+			close(r.running.resume)
+		}
+	case ":send", ":chars", ":close":
+		name, ch, ok := r.input(fields)
+		if !ok {
+			return false
+		}
+		text := strings.TrimSpace(strings.TrimPrefix(args, name))
+		switch fields[0] {
+		case ":send":
+			r.send(ctx, name, ch, text)
+		case ":chars":
+			for _, c := range text {
+				if !r.output(ctx, name, ch, c) {
+					break
+				}
+			}
+		case ":close":
+			close(ch)
+			delete(r.running.inputs, name)
+		}
+	default:
+		r.printf("error: unknown command %s, see :help\n", fields[0])
+	}
+	return false
+}
+
+// input returns the external source named by the command.
+func (r *REPL) input(fields []string) (string, chan lang.Value, bool) {
+	if len(fields) < 2 {
+		r.printf("error: %s requires the name of a source\n", fields[0])
+		return "", nil, false
+	}
+	if r.running == nil {
+		r.printf("error: no program is running\n")
+		return "", nil, false
+	}
+	ch, ok := r.running.inputs[fields[1]]
+	if !ok {
+		r.printf("error: %s is not a source of the program\n", fields[1])
+		return "", nil, false
+	}
+	return fields[1], ch, true
+}
+
+// send outputs the values of the comma separated expressions of text.
+func (r *REPL) send(ctx context.Context, name string, ch chan lang.Value, text string) {
+	x, err := lang.ParseExpr("(" + text + ")")
+	if err != nil {
+		r.printf("error: %v\n", err)
+		return
+	}
+	exprs := []lang.Expr{x}
+	if s, ok := x.(*lang.StructuredExpr); ok {
+		exprs = s.Args
+	} else if p, ok := x.(*lang.ParenExpr); ok {
+		exprs = []lang.Expr{p.X}
+	}
+	for _, e := range exprs {
+		v, err := lang.Eval(e)
+		if err != nil {
+			r.printf("error: %v\n", err)
+			return
+		}
+		if !r.output(ctx, name, ch, v) {
+			return
+		}
+	}
+}
+
+// output outputs v to the external source name, it reports whether the
+// program input v before it terminated.
+func (r *REPL) output(ctx context.Context, name string, ch chan lang.Value, v lang.Value) bool {
+	select {
+	case ch <- v:
+		return true
+	case <-r.running.done:
+		r.running = nil
+	case <-ctx.Done():
+	}
+	r.printf("error: %s!%s is not input\n", name, lang.Format(v))
+	return false
+}
@@ -0,0 +1,117 @@
+package repl_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/changkun/gobase/csp/lang/repl"
+)
+
+func TestREPL(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{
+			in: `COPY = (*[c:character; west?c -> east!c])
+X::COPY
+:chars west Hi
+:send west '!', 'x'
+:close west
+:wait`,
+			want: `defined COPY
+east!'H'
+east!'i'
+east!'!'
+east!'x'
+terminated
+`,
+		},
+		{
+			in: `SQUARE = (
+  *[n:integer; west?n ->
+    east!n*n
+  ]
+)
+:defs
+[X::SQUARE]
+:send west 1+1, 3
+:close west
+:wait`,
+			want: `defined SQUARE
+SQUARE
+east!4
+east!9
+terminated
+`,
+		},
+		{
+			in: `X::*[c:character; west?c -> east!c+1]
+:send west 'a'
+:wait
+:send west 1
+:foo`,
+			want: `error: X: 1:34: expected integer, found 'a'
+error: no program is running
+error: unknown command :foo, see :help
+`,
+		},
+		{
+			in: `:debug
+x:integer; [true → x := 1 □ true → x := 2]; [x = 2 → east!x]
+:guard 2
+:step
+:step
+:wait
+:guard 1`,
+			want: `debugging on
+program stops at [true → x := 1 □ true → x := 2]
+  guard 1: true
+  guard 2: true
+  program at [true → x := 1 □ true → x := 2]: x = undefined
+program stops at [x = 2 → east!x]
+  guard 1: x = 2
+  program at [x = 2 → east!x]: x = 2
+program stops at east!x
+  program at east!x: x = 2
+east!2
+terminated
+error: no program is running
+`,
+		},
+		{
+			in: `BAD = (
+  [x > 0 -> skip □ y];
+  y := ;
+  z := 1
+)`,
+			want: "error: 2:21: expected →, found ]\n\t  [x > 0 -> skip □ y];\n\t                    ^\n" +
+				"error: 3:8: expected expression, found ;\n\t  y := ;\n\t       ^\n",
+		},
+	}
+	for _, tt := range tests {
+		out := bytes.Buffer{}
+		r := repl.New(strings.NewReader(tt.in), &out)
+		r.Prompt = ""
+		if err := r.Run(context.Background()); err != nil {
+			t.Fatalf("%v: unexpected error: %v", t.Name(), err)
+		}
+		if out.String() != tt.want {
+			t.Fatalf("%v: expected:\n%v\ngot:\n%v", t.Name(), tt.want, out.String())
+		}
+	}
+}
+
+func TestREPLStop(t *testing.T) {
+	out := bytes.Buffer{}
+	r := repl.New(strings.NewReader("X::*[c:character; west?c -> east!c]\n:chars west a\n:stop\n:quit\n:help"), &out)
+	r.Prompt = ""
+	if err := r.Run(context.Background()); err != nil {
+		t.Fatalf("%v: unexpected error: %v", t.Name(), err)
+	}
+	// X is stopped either before or after it output 'a'.
+	if want := "error: X: context canceled\n"; !strings.HasSuffix(out.String(), want) {
+		t.Fatalf("%v: expected: %v, got: %v", t.Name(), want, out.String())
+	}
+}
@@ -0,0 +1,578 @@
+package lang
+
+import (
+	"math/rand"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/changkun/gobase/csp/trace"
+)
+
+// Step is a step a program run by a Schedule may take next: a
+// communication, the selection of a guard without input, or the end of
+// a wait command or timeout.
+type Step struct {
+	// Comm is the communication of the step, its Value is nil for an
+	// input from an external source.
+	Comm Comm
+	// Process and Guard are the label of the process and the guard it
+	// selects, as in Stop.Guards, for a step without communication,
+	// such as timeout 5, or the wait command it ends, such as wait 2.
+	Process, Guard string
+}
+
+// String formats s, such as X→Y: 1, west→X, X: n > 0 or X: wait 2.
+func (s Step) String() string {
+	switch {
+	case s.Guard != "":
+		label := s.Process
+		if label == "" {
+			label = "program"
+		}
+		return label + ": " + s.Guard
+	case s.Comm.Value == nil:
+		return s.Comm.Src + "→" + s.Comm.Dst
+	}
+	return s.Comm.String()
+}
+
+// Random returns a Schedule which takes steps at random, as drawn from
+// a pseudo-random generator seeded with seed: runs of a program with
+// the same seed take the same steps.
+func Random(seed int64) func([]Step) int {
+	r := rand.New(rand.NewSource(seed))
+	return func(steps []Step) int {
+		if len(steps) == 1 {
+			return 0
+		}
+		return r.Intn(len(steps))
+	}
+}
+
+// Script returns a Schedule which takes the steps described by steps
+// in turn: at every choice, the first step whose description, as by
+// Step.String, starts with the next of steps, such as X→Y. It fails the
+// run if there is no such step, and takes the first step once steps
+// are exhausted.
+func Script(steps ...string) func([]Step) int {
+	return func(next []Step) int {
+		if len(next) == 1 || len(steps) == 0 {
+			return 0
+		}
+		want := steps[0]
+		steps = steps[1:]
+		for i, s := range next {
+			if strings.HasPrefix(s.String(), want) {
+				return i
+			}
+		}
+		return -1
+	}
+}
+
+// Replay returns a Schedule which takes the steps of a run recorded by
+// package trace in the same order, such that a run of the program
+// with the same inputs replays the recorded run. The events are those
+// of a run with a Schedule: once the run diverges from them, as its
+// next step is none of those the program may take, Replay fails the
+// run. It takes the first step once the events are exhausted.
+func Replay(events []trace.Event) func([]Step) int {
+	var steps []trace.Event
+	var last *trace.Event
+	for _, e := range events {
+		switch e.Kind {
+		case trace.Send:
+			steps = append(steps, e)
+			last = &steps[len(steps)-1]
+		case trace.Select:
+			// the selection of an input guard follows its
+			// input, it is not a step of its own.
+			if last != nil && strings.HasSuffix(last.Chan, "→"+e.Process) {
+				last = nil
+				continue
+			}
+			steps = append(steps, e)
+			last = nil
+		}
+	}
+	return func(next []Step) int {
+		if len(steps) == 0 {
+			return 0
+		}
+		want := steps[0]
+		steps = steps[1:]
+		for i, s := range next {
+			if replays(s, want) {
+				return i
+			}
+		}
+		return -1
+	}
+}
+
+// replays reports whether the step s is the step of the event e, a
+// Send or a Select.
+func replays(s Step, e trace.Event) bool {
+	if e.Kind == trace.Select {
+		return s.Guard != "" && s.Process == e.Process && s.Guard == e.Guard
+	}
+	if s.Guard != "" || s.Comm.Src+"→"+s.Comm.Dst != e.Chan {
+		return false
+	}
+	// the value of an input from an external source is not known
+	// before it is taken.
+	return s.Comm.Value == nil || Format(s.Comm.Value) == Format(e.Value)
+}
+
+// scheduler runs the processes of a program one at a time for a
+// Schedule. The running process runs until it offers choices of
+// communications and waits for them, or terminates; the scheduler then
+// runs the next process of its queue, or takes a step among the offers
+// and queues the processes whose choices it took.
+type scheduler struct {
+	schedule func([]Step) int
+	now      atomic.Int64 // virtual time
+
+	mu      sync.Mutex
+	seq     int
+	running *process
+	queue   []*process
+	offers  []*offer // in the order the processes started
+}
+
+// offer is the choices of a process waiting for the scheduler.
+type offer struct {
+	p       *process
+	choices []choice
+	taken   int    // index of the choice taken, -1 if all fail
+	value   Value  // input by the choice taken
+	fail    string // why the run failed instead, if so
+}
+
+type choiceKind int
+
+const (
+	guardChoice choiceKind = iota
+	inputChoice
+	outputChoice
+	timeChoice
+)
+
+// choice is a guard without input, an input or an output a process
+// offers to take, or the end of a wait command or timeout, which it
+// takes at the time end.
+type choice struct {
+	kind  choiceKind
+	guard string           // description of a guard or wait command
+	pt    port             // of an input or output
+	value Value            // of an output, or held for a guard
+	match func(Value) bool // of an input, nil matches any value
+	end   int              // of a wait command or timeout
+}
+
+// spawn queues the new process p.
+func (s *scheduler) spawn(p *process) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p.seq, s.seq = s.seq, s.seq+1
+	p.wake = make(chan struct{}, 1)
+	s.queue = append(s.queue, p)
+	s.next()
+}
+
+// wait waits until p runs.
+func (s *scheduler) wait(p *process) {
+	select {
+	case <-p.wake:
+	case <-p.ctx.Done():
+		p.fail(p.ctx.Err())
+	}
+}
+
+// await offers the choices of p and waits until the scheduler takes
+// one of them. It fails at n if the run fails.
+func (s *scheduler) await(p *process, n Node, choices []choice) *offer {
+	o := &offer{p: p, choices: choices}
+	s.mu.Lock()
+	i := len(s.offers)
+	for i > 0 && s.offers[i-1].p.seq > p.seq {
+		i--
+	}
+	s.offers = append(s.offers[:i], append([]*offer{o}, s.offers[i:]...)...)
+	s.running = nil
+	s.next()
+	s.mu.Unlock()
+
+	select {
+	case <-p.wake:
+	case <-p.ctx.Done():
+		// a run which failed, such as by a deadlock of all processes,
+		// fails p by its failure rather than by the cancellation of the
+		// first process to fail.
+		s.mu.Lock()
+		aborted := o.fail != ""
+		s.mu.Unlock()
+		if !aborted {
+			p.fail(p.ctx.Err())
+		}
+	}
+	if o.fail != "" {
+		p.errorf(n, "%s", o.fail)
+	}
+	return o
+}
+
+// release lets the process p wait for its n processes of a parallel
+// command, the last of them to terminate queues p.
+func (s *scheduler) release(p *process, n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p.pending = n
+	s.running = nil
+	s.next()
+}
+
+// exit records the termination of p.
+func (s *scheduler) exit(p *process) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, o := range s.offers {
+		if o.p == p {
+			s.withdraw(o)
+			break
+		}
+	}
+	for i, q := range s.queue {
+		if q == p {
+			s.queue = append(s.queue[:i], s.queue[i+1:]...)
+			break
+		}
+	}
+	if s.running == p {
+		s.running = nil
+	}
+	if q := p.outer; q != nil {
+		if q.pending--; q.pending == 0 {
+			s.queue = append(s.queue, q)
+		}
+	}
+	s.next()
+}
+
+// withdraw removes o from the offers.
+func (s *scheduler) withdraw(o *offer) {
+	for i, x := range s.offers {
+		if x == o {
+			s.offers = append(s.offers[:i], s.offers[i+1:]...)
+			return
+		}
+	}
+}
+
+// next runs the next process, unless a process runs: the first of the
+// queue, or the first queued by a step.
+func (s *scheduler) next() {
+	for s.running == nil {
+		if len(s.queue) > 0 {
+			s.running = s.queue[0]
+			s.queue = s.queue[1:]
+			s.running.wake <- struct{}{}
+			return
+		}
+		if len(s.offers) == 0 {
+			return
+		}
+		s.step()
+	}
+}
+
+// step resolves the offers whose choices all fail, if any, or else
+// takes one of the possible steps. If there is none, time passes up to
+// the earliest end of a wait command or timeout, whose ends are then
+// the possible steps. If there is none either, the program deadlocked.
+func (s *scheduler) step() {
+	var failed []*offer
+	for _, o := range s.offers {
+		live := false
+		for _, c := range o.choices {
+			if c.kind == guardChoice || c.kind == timeChoice || c.pt.link == nil || !terminated(c.pt.done) {
+				live = true
+			}
+		}
+		if !live {
+			failed = append(failed, o)
+		}
+	}
+	if len(failed) > 0 {
+		for _, o := range failed {
+			s.resolve(o, -1, nil)
+		}
+		return
+	}
+
+	var steps []Step
+	var takes []func()
+	for _, o := range s.offers {
+		for i, c := range o.choices {
+			o, i, c := o, i, c
+			switch {
+			case c.kind == timeChoice:
+			case c.kind == guardChoice:
+				steps = append(steps, Step{Process: o.p.self(), Guard: c.guard})
+				takes = append(takes, func() { s.resolve(o, i, c.value) })
+			case c.pt.link == nil:
+				steps = append(steps, Step{Comm: c.pt.comm})
+				if c.kind == outputChoice {
+					steps[len(steps)-1].Comm.Value = c.value
+				}
+				takes = append(takes, func() { s.resolve(o, i, nil) })
+			case c.kind == inputChoice:
+				for _, src := range s.offers {
+					for j, out := range src.choices {
+						if out.kind != outputChoice || out.pt.link != c.pt.link || c.match != nil && !c.match(out.value) {
+							continue
+						}
+						src, j, v := src, j, out.value
+						comm := c.pt.comm
+						comm.Value = v
+						steps = append(steps, Step{Comm: comm})
+						takes = append(takes, func() {
+							if o.p.traced() {
+								o.p.trace(comm)
+							}
+							s.resolve(src, j, nil)
+							s.resolve(o, i, v)
+						})
+					}
+				}
+			}
+		}
+	}
+
+	if len(steps) == 0 {
+		steps, takes = s.timeout()
+	}
+	if len(steps) == 0 {
+		s.abort("deadlock")
+		return
+	}
+	i := s.schedule(steps)
+	if i < 0 || i >= len(steps) {
+		s.abort("no step scheduled")
+		return
+	}
+	takes[i]()
+}
+
+// timeout lets time pass up to the earliest end of the wait commands
+// and timeouts offered, and returns the steps ending them.
+func (s *scheduler) timeout() (steps []Step, takes []func()) {
+	end := -1
+	for _, o := range s.offers {
+		for _, c := range o.choices {
+			if c.kind == timeChoice && (end < 0 || c.end < end) {
+				end = c.end
+			}
+		}
+	}
+	if end < 0 {
+		return nil, nil
+	}
+	s.now.Store(int64(end))
+	for _, o := range s.offers {
+		for i, c := range o.choices {
+			if c.kind == timeChoice && c.end == end {
+				o, i := o, i
+				steps = append(steps, Step{Process: o.p.self(), Guard: c.guard})
+				takes = append(takes, func() { s.resolve(o, i, nil) })
+			}
+		}
+	}
+	return steps, takes
+}
+
+// resolve takes the choice i of o and queues its process.
+func (s *scheduler) resolve(o *offer, i int, v Value) {
+	o.taken, o.value = i, v
+	s.withdraw(o)
+	s.queue = append(s.queue, o.p)
+}
+
+// abort fails all offers with msg.
+func (s *scheduler) abort(msg string) {
+	for len(s.offers) > 0 {
+		o := s.offers[0]
+		o.fail = msg
+		s.resolve(o, -1, nil)
+	}
+}
+
+// terminated reports whether done is closed.
+func terminated(done <-chan struct{}) bool {
+	select {
+	case <-done:
+		return true
+	default:
+		return false
+	}
+}
+
+// scheduledRecv inputs from the source n for a Schedule.
+func (p *process) scheduledRecv(n *ProcName) Value {
+	pt := p.input(n)
+	if v, ok := p.held[pt.in]; ok {
+		delete(p.held, pt.in)
+		p.received(pt, v)
+		return v
+	}
+	o := p.sched.await(p, n, []choice{{kind: inputChoice, pt: pt}})
+	if o.taken < 0 {
+		p.errorf(n, "input from terminated process %s", pt.comm.Src)
+	}
+	if pt.link != nil {
+		return o.value
+	}
+	v, ok := p.external(pt)
+	if !ok {
+		p.errorf(n, "input from terminated process %s", pt.comm.Src)
+	}
+	p.received(pt, v)
+	return v
+}
+
+// external inputs from an external source for a Schedule, it reports
+// whether the source is not closed.
+func (p *process) external(pt port) (Value, bool) {
+	select {
+	case v, ok := <-pt.in:
+		return v, ok
+	case <-p.ctx.Done():
+		p.fail(p.ctx.Err())
+	}
+	return nil, false
+}
+
+// scheduledWait waits for d units of virtual time for a Schedule.
+func (p *process) scheduledWait(s *WaitCmd, d int) {
+	guard := Source(s)
+	p.sched.await(p, s, []choice{{kind: timeChoice, guard: guard, end: p.now() + d}})
+	if p.rec != nil {
+		p.selected(guard)
+	}
+}
+
+// scheduledSend outputs v to the destination n for a Schedule.
+func (p *process) scheduledSend(n *ProcName, v Value) {
+	pt := p.output(n)
+	o := p.sched.await(p, n, []choice{{kind: outputChoice, pt: pt, value: copyValue(v)}})
+	if o.taken < 0 {
+		p.errorf(n, "output to terminated process %s", pt.comm.Dst)
+	}
+	if pt.link != nil {
+		return
+	}
+	select {
+	case pt.out <- copyValue(v):
+	case <-p.ctx.Done():
+		p.fail(p.ctx.Err())
+	}
+	if p.traced() {
+		pt.comm.Value = v
+		p.trace(pt.comm)
+	}
+}
+
+// scheduledAlternative executes an alternative command for a Schedule,
+// as alternative does. The scheduler selects an input guard only for
+// an output its target matches, an input from an external source is
+// held back if it does not.
+func (p *process) scheduledAlternative(cmd *AlternativeCmd, stmt Stmt) bool {
+	for {
+		var choices []choice
+		var descs []string
+		var execs []func(Value)
+		for _, gc := range cmd.Cmds {
+			for _, s := range p.replicas(gc) {
+				if !p.guard(s, gc.Guard) {
+					continue
+				}
+				s, body, input := s, gc.Body, gc.Guard.Input
+				c := choice{kind: guardChoice, guard: describe(s, gc)}
+				exec := func(Value) {
+					p.within(s, func() { p.execList(body) })
+				}
+				if t := gc.Guard.Timeout; t != nil {
+					p.within(s, func() { c.end = p.now() + p.duration(t.Duration) })
+					c.kind = timeChoice
+				}
+				if input != nil {
+					p.within(s, func() { c.pt = p.input(input.Source) })
+					matches := func(v Value) (ok bool) {
+						p.within(s, func() { ok = p.matches(input.Target, v) })
+						return ok
+					}
+					if v, ok := p.held[c.pt.in]; ok {
+						if !matches(v) {
+							continue
+						}
+						c.value = v
+					} else if p.closed[c.pt.in] {
+						continue
+					} else {
+						c.kind, c.match = inputChoice, matches
+					}
+					pt := c.pt
+					exec = func(v Value) {
+						if pt.link == nil {
+							delete(p.held, pt.in)
+							p.received(pt, v)
+						}
+						p.within(s, func() {
+							p.assign(input.Target, v)
+							p.execList(body)
+						})
+					}
+				}
+				choices = append(choices, c)
+				descs = append(descs, c.guard)
+				execs = append(execs, exec)
+			}
+		}
+		if len(choices) == 0 {
+			return false
+		}
+		if p.Debug != nil {
+			if i := p.stop(stmt, descs); i >= 0 && i < len(choices) {
+				choices, execs = choices[i:i+1], execs[i:i+1]
+			}
+		}
+		o := p.sched.await(p, stmt, choices)
+		if o.taken < 0 {
+			return false
+		}
+		c, v := choices[o.taken], o.value
+		if c.kind == inputChoice && c.pt.link == nil {
+			var ok bool
+			if v, ok = p.external(c.pt); !ok {
+				if p.closed == nil {
+					p.closed = map[<-chan Value]bool{}
+				}
+				p.closed[c.pt.in] = true
+				continue
+			}
+			if !c.match(v) {
+				if p.held == nil {
+					p.held = map[<-chan Value]Value{}
+				}
+				p.held[c.pt.in] = v
+				continue
+			}
+		}
+		if p.rec != nil {
+			p.selected(descs[o.taken])
+		}
+		execs[o.taken](v)
+		return true
+	}
+}
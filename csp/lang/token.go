@@ -0,0 +1,148 @@
+package lang
+
+import "fmt"
+
+// Token is a lexical token of the CSP notation.
+type Token int
+
+// The tokens of the CSP notation. Tokens with several spellings accept
+// both the notation of the paper and an ASCII spelling.
+const (
+	ILLEGAL Token = iota
+	EOF
+
+	IDENT  // west
+	INT    // 125
+	CHAR   // 'a'
+	STRING // "Hello, CSP"
+
+	LABEL  // ::
+	PAR    // ||
+	INTER  // |||
+	INPUT  // ?
+	OUTPUT // !
+	BOX    // □ or []
+	ARROW  // → or ->
+	REP    // *[, the opening bracket of a repetitive command
+
+	LBRACK // [
+	RBRACK // ]
+	LPAREN // (
+	RPAREN // )
+	COMMA  // ,
+	SEMI   // ;
+	COLON  // :
+	ASSIGN // :=
+	RANGE  // ..
+
+	EQ  // =
+	NEQ // ≠ or != or <>
+	LT  // <
+	LEQ // ≤ or <=
+	GT  // >
+	GEQ // ≥ or >=
+
+	ADD // +
+	SUB // -
+	MUL // * or ×
+	DIV // /
+	MOD // mod or \
+	AND // ∧ or and
+	OR  // ∨ or or
+	NOT // ¬ or not
+
+	SKIP    // skip
+	WAIT    // wait
+	TIMEOUT // timeout
+	PROB    // prob
+)
+
+var tokens = [...]string{
+	ILLEGAL: "ILLEGAL",
+	EOF:     "EOF",
+
+	IDENT:  "IDENT",
+	INT:    "INT",
+	CHAR:   "CHAR",
+	STRING: "STRING",
+
+	LABEL:  "::",
+	PAR:    "||",
+	INTER:  "|||",
+	INPUT:  "?",
+	OUTPUT: "!",
+	BOX:    "□",
+	ARROW:  "→",
+	REP:    "*[",
+
+	LBRACK: "[",
+	RBRACK: "]",
+	LPAREN: "(",
+	RPAREN: ")",
+	COMMA:  ",",
+	SEMI:   ";",
+	COLON:  ":",
+	ASSIGN: ":=",
+	RANGE:  "..",
+
+	EQ:  "=",
+	NEQ: "≠",
+	LT:  "<",
+	LEQ: "≤",
+	GT:  ">",
+	GEQ: "≥",
+
+	ADD: "+",
+	SUB: "-",
+	MUL: "*",
+	DIV: "/",
+	MOD: "mod",
+	AND: "∧",
+	OR:  "∨",
+	NOT: "¬",
+
+	SKIP:    "skip",
+	WAIT:    "wait",
+	TIMEOUT: "timeout",
+	PROB:    "prob",
+}
+
+// String returns the canonical spelling of tok, or its name for
+// tokens without a fixed spelling.
+func (tok Token) String() string {
+	if tok >= 0 && int(tok) < len(tokens) {
+		return tokens[tok]
+	}
+	return fmt.Sprintf("token(%d)", int(tok))
+}
+
+// keywords are the identifiers spelling a token.
+var keywords = map[string]Token{
+	"skip":    SKIP,
+	"wait":    WAIT,
+	"timeout": TIMEOUT,
+	"prob":    PROB,
+	"mod":     MOD,
+	"and":     AND,
+	"or":      OR,
+	"not":     NOT,
+}
+
+// Pos is a position in the source of a program.
+type Pos struct {
+	Offset int // byte offset, starting at 0
+	Line   int // line number, starting at 1
+	Column int // column number in runes, starting at 1
+}
+
+// IsValid reports whether the position is valid.
+func (p Pos) IsValid() bool {
+	return p.Line > 0
+}
+
+func (p Pos) String() string {
+	if !p.IsValid() {
+		return "-"
+	}
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
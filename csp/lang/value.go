@@ -0,0 +1,111 @@
+package lang
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Value is a value of a program: an int for integer, a rune for
+// character, a bool for boolean, an Array, or a Structured value.
+type Value = interface{}
+
+// Structured is a structured value, such as cons(1,'a'), P() or
+// (x,y) without constructor.
+type Structured struct {
+	Constructor string
+	Components  []Value
+}
+
+// String formats s in the notation of the paper. A structured value of
+// one component without constructor is formatted as (v,), which
+// distinguishes it from a parenthesized expression.
+func (s Structured) String() string {
+	comps := make([]string, len(s.Components))
+	for i, v := range s.Components {
+		comps[i] = Format(v)
+	}
+	if s.Constructor == "" && len(comps) == 1 {
+		return "(" + comps[0] + ",)"
+	}
+	return s.Constructor + "(" + strings.Join(comps, ",") + ")"
+}
+
+// Array is the value of an array, such as a card image of type
+// (1..80)character.
+type Array []Value
+
+// Chars returns the array of the characters of s.
+func Chars(s string) Array {
+	a := Array{}
+	for _, c := range s {
+		a = append(a, c)
+	}
+	return a
+}
+
+// String returns the characters of an array of characters as a
+// string, any other array is formatted as (v1,v2,...).
+func (a Array) String() string {
+	chars := strings.Builder{}
+	for _, v := range a {
+		c, ok := v.(rune)
+		if !ok {
+			elems := make([]string, len(a))
+			for i, v := range a {
+				elems[i] = Format(v)
+			}
+			return "(" + strings.Join(elems, ",") + ")"
+		}
+		chars.WriteRune(c)
+	}
+	return chars.String()
+}
+
+// Format formats v in the notation of the paper: characters are
+// quoted, arrays of characters are strings.
+func Format(v Value) string {
+	switch v := v.(type) {
+	case nil:
+		return "undefined"
+	case rune:
+		return fmt.Sprintf("%q", v)
+	case Array:
+		if len(v) == 0 {
+			return "()"
+		}
+		if _, ok := v[0].(rune); ok {
+			return fmt.Sprintf("%q", v.String())
+		}
+		return v.String()
+	}
+	return fmt.Sprint(v)
+}
+
+// copyValue returns a copy of v that shares no arrays or components
+// with v.
+func copyValue(v Value) Value {
+	switch v := v.(type) {
+	case Array:
+		c := make(Array, len(v))
+		for i := range v {
+			c[i] = copyValue(v[i])
+		}
+		return c
+	case Structured:
+		c := Structured{Constructor: v.Constructor, Components: make([]Value, len(v.Components))}
+		for i := range v.Components {
+			c.Components[i] = copyValue(v.Components[i])
+		}
+		return c
+	}
+	return v
+}
+
+// constants are the named constants of the paper.
+var constants = map[string]Value{
+	"true":         true,
+	"false":        false,
+	"space":        ' ',
+	"asterisk":     '*',
+	"upward arrow": '↑',
+}
@@ -0,0 +1,140 @@
+package lang
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/changkun/gobase/csp/lts"
+)
+
+// Verdict is the verdict of an Analyzer on an assertion of its program.
+type Verdict struct {
+	Assertion *Assertion
+	// States is the number of states explored, of the process, or of
+	// both processes of a refinement.
+	States int
+	// Complete reports whether all states were explored. Otherwise an
+	// assertion without a Counterexample is not known to hold.
+	Complete bool
+	// Counterexample is the violation of the assertion, empty if none
+	// was found.
+	Counterexample string
+}
+
+// Holds reports whether the assertion of v is known to hold.
+func (v Verdict) Holds() bool {
+	return v.Complete && v.Counterexample == ""
+}
+
+// String formats v, such as
+//
+//   assert P :[deadlock free]: holds
+//   assert SPEC [T= P]: fails: after east.1: east.2
+//   assert P :[divergence free]: unknown after 100000 states
+func (v Verdict) String() string {
+	s := compact(v.Assertion) + ": "
+	switch {
+	case v.Counterexample != "":
+		return s + "fails: " + v.Counterexample
+	case !v.Complete:
+		return s + fmt.Sprintf("unknown after %d states", v.States)
+	}
+	return s + "holds"
+}
+
+// Verify verifies the assertions of the program, in order. The process
+// an assertion names runs as a program on its own, with the Inputs of a,
+// its communications with the names its definition hides being internal
+// steps, and the analysis of every process is bounded by MaxStates:
+//
+//   - deadlock free holds if the process reaches no deadlock, the first
+//     it reaches being the counterexample, as found by Analyze,
+//   - divergence free holds if the process reaches no divergence, the
+//     first it reaches being the counterexample,
+//   - deterministic holds if the transition system of the process, as
+//     generated by LTS, is deterministic by lts.Deterministic, the
+//     counterexample being its nondeterminism and the steps leading to
+//     the state it arises in,
+//   - a refinement in the model T, F or FD holds if the transition
+//     system of the implementation refines that of the specification,
+//     as generated by LTS, checked by lts.Refines, lts.RefinesFailures
+//     or lts.RefinesFD.
+//
+// Traces refinement is checked of the states of the implementation
+// explored, if not all, as their traces are its traces; determinism
+// and other refinements are checked only if all states were explored.
+func (a *Analyzer) Verify(ctx context.Context) ([]Verdict, error) {
+	defs := map[string]*Definition{}
+	for _, d := range a.Program.Defs {
+		defs[d.Name] = d
+	}
+	explore := func(pos Pos, name string) ([]xnode, bool, error) {
+		d := defs[name]
+		if d == nil {
+			return nil, false, &Error{Pos: pos, Msg: "undefined process " + name}
+		}
+		p := &Analyzer{Program: &Program{Defs: a.Program.Defs, Body: d.Body}, Inputs: a.Inputs, MaxStates: a.MaxStates}
+		if len(d.Hidden) > 0 {
+			p.hidden = map[string]bool{}
+			for _, id := range d.Hidden {
+				p.hidden[id.Name] = true
+			}
+		}
+		return p.explore(ctx)
+	}
+
+	var verdicts []Verdict
+	for _, as := range a.Program.Asserts {
+		v := Verdict{Assertion: as}
+		nodes, complete, err := explore(as.ProcPos, as.Proc)
+		if err != nil {
+			return nil, err
+		}
+		v.States, v.Complete = len(nodes), complete
+		paths := newPaths(nodes)
+		switch as.Property {
+		case "deadlock free":
+			for i, n := range nodes {
+				if n.deadlock != nil {
+					v.Counterexample = Deadlock{Trace: paths.to(i), Procs: n.deadlock}.String()
+					break
+				}
+			}
+		case "divergence free":
+			if l := loops(nodes); len(l) > 0 {
+				v.Counterexample = Divergence{Trace: paths.to(l[0].at), Cycle: l[0].steps}.String()
+			}
+		case "deterministic":
+			if !complete {
+				break
+			}
+			if d := lts.Deterministic(transitions(nodes)); d != nil {
+				v.Counterexample = d.String()
+				if d.State < len(nodes) && d.State > 0 {
+					v.Counterexample += "\n\treached by " + joinSteps(paths.to(d.State))
+				}
+			}
+		default:
+			impl, implComplete, err := explore(as.ImplPos, as.Impl)
+			if err != nil {
+				return nil, err
+			}
+			v.States += len(impl)
+			v.Complete = v.Complete && implComplete
+			if !complete || (!implComplete && as.Model != "T") {
+				break
+			}
+			refines := map[string]func(spec, impl *lts.LTS) *lts.Counterexample{
+				"T": lts.Refines, "F": lts.RefinesFailures, "FD": lts.RefinesFD,
+			}[as.Model]
+			if refines == nil {
+				return nil, &Error{Pos: as.Pos(), Msg: "unknown model " + as.Model}
+			}
+			if c := refines(transitions(nodes), transitions(impl)); c != nil {
+				v.Counterexample = c.String()
+			}
+		}
+		verdicts = append(verdicts, v)
+	}
+	return verdicts, nil
+}
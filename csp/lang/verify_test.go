@@ -0,0 +1,92 @@
+package lang_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/changkun/gobase/csp/lang"
+)
+
+func TestVerify(t *testing.T) {
+	src := `COPY = (*[c:character; west?c → east!c])
+SPEC = (*[c:character; west?c → east!c □ c:character; west?c → skip])
+SWAP = ([X::Y!1; Z!2 || Y::n:integer; Z?n; X?n || Z::n:integer; X?n; Y!n])
+SPIN = (*[true → skip])
+LOG = (*[c:character; west?c → log!c; east!c]) \ (log)
+LOUD = (*[c:character; west?c → log!c; east!c])
+CHATTY = (*[true → log!1]) \ (log)
+PIPE = ([X::COPY[[east <- Y]] || Y::COPY[[west <- X]]])
+MERGE = ([X::COPY ||| Y::COPY])
+assert COPY :[deadlock free]
+assert SWAP :[deadlock free]
+assert COPY :[divergence free]
+assert SPIN :[divergence free]
+assert SPEC [T= COPY]
+assert COPY [T= SPEC]
+assert SPEC [F= COPY]
+assert COPY [FD= SPIN]
+assert COPY :[deterministic]
+assert SPEC :[deterministic]
+assert COPY [FD= LOG]
+assert COPY [T= LOUD]
+assert CHATTY :[divergence free]
+assert PIPE :[deadlock free]
+assert COPY [T= PIPE]
+assert MERGE [T= COPY]
+assert COPY [T= MERGE]`
+	prog, err := lang.Parse(src)
+	if err != nil {
+		t.Fatalf("%v: %v", t.Name(), err)
+	}
+	a := &lang.Analyzer{Program: prog, Inputs: map[string][]lang.Value{"west": chars("ab")}}
+	verdicts, err := a.Verify(context.Background())
+	if err != nil {
+		t.Fatalf("%v: unexpected error: %v", t.Name(), err)
+	}
+	want := []string{
+		"assert COPY :[deadlock free]: holds",
+		"assert SWAP :[deadlock free]: fails: deadlock at start\n" +
+			"\tprogram at [X::Y!1; Z!2 || Y::n:integer; Z?n; X?n || Z::n:integer; X?n; Y!n]\n" +
+			"\tX at Y!1\n" +
+			"\tY at Z?n: n = undefined\n" +
+			"\tZ at X?n: n = undefined",
+		"assert COPY :[divergence free]: holds",
+		"assert SPIN :[divergence free]: fails: divergence at start repeating program: true",
+		"assert SPEC [T= COPY]: holds",
+		"assert COPY [T= SPEC]: fails: after west.'a': west.'b'",
+		"assert SPEC [F= COPY]: holds",
+		"assert COPY [FD= SPIN]: fails: after ⟨⟩: diverges",
+		"assert COPY :[deterministic]: holds",
+		"assert SPEC :[deterministic]: fails: after west.'a': may engage in east.'a' or refuse it in state 2\n" +
+			"\treached by west→",
+		"assert COPY [FD= LOG]: holds",
+		"assert COPY [T= LOUD]: fails: after west.'a': log.'a'",
+		"assert CHATTY :[divergence free]: fails: divergence at start repeating program: true, →log: 1",
+		"assert PIPE :[deadlock free]: holds",
+		"assert COPY [T= PIPE]: fails: after west.'a': west.'b'",
+		"assert MERGE [T= COPY]: holds",
+		"assert COPY [T= MERGE]: fails: after west.'a': west.'b'",
+	}
+	var got []string
+	for _, v := range verdicts {
+		got = append(got, v.String())
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("%v: expected:\n%v\ngot:\n%v", t.Name(), strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+
+	// a bounded analysis does not know whether an assertion holds
+	// without a counterexample among the states explored.
+	a.MaxStates = 2
+	verdicts, err = a.Verify(context.Background())
+	if err != nil {
+		t.Fatalf("%v: unexpected error: %v", t.Name(), err)
+	}
+	if v := verdicts[0]; v.Holds() || v.String() != "assert COPY :[deadlock free]: unknown after 2 states" {
+		t.Fatalf("%v: expected an unknown verdict, got: %v", t.Name(), v)
+	}
+	if v := verdicts[5]; v.Holds() || v.String() != "assert COPY [T= SPEC]: unknown after 4 states" {
+		t.Fatalf("%v: expected an unknown verdict, got: %v", t.Name(), v)
+	}
+}
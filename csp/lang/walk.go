@@ -0,0 +1,107 @@
+package lang
+
+// Inspect traverses the syntax tree of n in depth-first order: it calls
+// f(n), and if f returns true, Inspect visits the children of n, each
+// followed by a call of f(nil).
+func Inspect(n Node, f func(Node) bool) {
+	if n == nil || !f(n) {
+		return
+	}
+	switch n := n.(type) {
+	case *Program:
+		for _, d := range n.Defs {
+			Inspect(d, f)
+		}
+		for _, a := range n.Asserts {
+			Inspect(a, f)
+		}
+		Inspect(n.Body, f)
+	case *Definition:
+		Inspect(n.Body, f)
+	case *CmdList:
+		for _, s := range n.Stmts {
+			Inspect(s, f)
+		}
+	case *Declaration:
+		for _, id := range n.Names {
+			Inspect(id, f)
+		}
+		Inspect(n.Type, f)
+	case *ArrayType:
+		Inspect(n.Lo, f)
+		Inspect(n.Hi, f)
+		Inspect(n.Elem, f)
+	case *AssignmentCmd:
+		Inspect(n.Target, f)
+		Inspect(n.Value, f)
+	case *InputCmd:
+		Inspect(n.Source, f)
+		Inspect(n.Target, f)
+	case *OutputCmd:
+		Inspect(n.Dest, f)
+		Inspect(n.Value, f)
+	case *WaitCmd:
+		Inspect(n.Duration, f)
+	case *ProcName:
+		for _, e := range n.Subscripts {
+			Inspect(e, f)
+		}
+	case *ParallelCmd:
+		for _, p := range n.Procs {
+			Inspect(p, f)
+		}
+	case *Proc:
+		if n.Label != nil {
+			Inspect(n.Label, f)
+		}
+		Inspect(n.Body, f)
+	case *ProcLabel:
+		for _, e := range n.Subscripts {
+			Inspect(e, f)
+		}
+	case *Range:
+		Inspect(n.Lo, f)
+		Inspect(n.Hi, f)
+	case *AlternativeCmd:
+		for _, gc := range n.Cmds {
+			Inspect(gc, f)
+		}
+	case *RepetitiveCmd:
+		Inspect(n.Alt, f)
+	case *GuardedCmd:
+		for _, r := range n.Ranges {
+			Inspect(r, f)
+		}
+		Inspect(n.Guard, f)
+		Inspect(n.Body, f)
+	case *Guard:
+		for _, e := range n.List {
+			Inspect(e, f)
+		}
+		if n.Input != nil {
+			Inspect(n.Input, f)
+		}
+		if n.Timeout != nil {
+			Inspect(n.Timeout, f)
+		}
+		if n.Prob != nil {
+			Inspect(n.Prob, f)
+		}
+	case *Timeout:
+		Inspect(n.Duration, f)
+	case *Prob:
+		Inspect(n.Weight, f)
+	case *ParenExpr:
+		Inspect(n.X, f)
+	case *UnaryExpr:
+		Inspect(n.X, f)
+	case *BinaryExpr:
+		Inspect(n.X, f)
+		Inspect(n.Y, f)
+	case *StructuredExpr:
+		for _, e := range n.Args {
+			Inspect(e, f)
+		}
+	}
+	f(nil)
+}
@@ -0,0 +1,426 @@
+// Package laws implements the algebraic laws of CSP as executable
+// properties of the processes of package algebra: equations of
+// processes, such as
+//
+//   P □ Q = Q □ P
+//
+// which hold for any processes P and Q. A law is checked for processes
+// generated at random, by comparing the transition systems of its
+// sides, both a regression test of the operators of the algebra and a
+// demonstration of their semantics:
+//
+//   g := laws.NewGenerator(1)
+//   for _, l := range laws.Laws {
+//       if err := l.Check(g, 100); err != nil {
+//           log.Fatal(err)
+//       }
+//   }
+//
+// Most laws hold up to bisimulation, a law which holds in the
+// failures-divergences model only, such as P □ P = P, is checked by
+// refinement.
+package laws
+
+import (
+	"fmt"
+	"math/rand"
+
+	"github.com/changkun/gobase/csp/algebra"
+	"github.com/changkun/gobase/csp/lts"
+)
+
+// Equivalence is an equivalence of processes.
+type Equivalence int
+
+const (
+	// Bisimilar processes are strongly bisimilar.
+	Bisimilar Equivalence = iota
+	// WeaklyBisimilar processes are weakly bisimilar.
+	WeaklyBisimilar
+	// FD processes refine each other in the failures-divergences
+	// model.
+	FD
+)
+
+func (e Equivalence) String() string {
+	switch e {
+	case Bisimilar:
+		return "strong bisimulation"
+	case WeaklyBisimilar:
+		return "weak bisimulation"
+	case FD:
+		return "failures-divergences equivalence"
+	}
+	return fmt.Sprintf("Equivalence(%d)", int(e))
+}
+
+// differ returns why the processes l and r are not equivalent, empty
+// if they are.
+func (e Equivalence) differ(l, r *lts.LTS) string {
+	switch e {
+	case Bisimilar, WeaklyBisimilar:
+		d := lts.Bisimilar(l, r)
+		if e == WeaklyBisimilar {
+			d = lts.WeaklyBisimilar(l, r)
+		}
+		if d != nil {
+			return "the left side satisfies " + d.String() + ", the right side does not"
+		}
+	case FD:
+		if c := lts.RefinesFD(l, r); c != nil {
+			return "the right side does not refine the left side: " + c.String()
+		}
+		if c := lts.RefinesFD(r, l); c != nil {
+			return "the left side does not refine the right side: " + c.String()
+		}
+	}
+	return ""
+}
+
+// Law is an algebraic law, an equation of processes.
+type Law struct {
+	// Name is the equation, such as P □ Q = Q □ P.
+	Name string
+	// Equivalence is the equivalence of the sides of the equation.
+	Equivalence Equivalence
+	// Sides returns the sides of an instance of the equation, whose
+	// processes and events are generated by g.
+	Sides func(g *Generator) (lhs, rhs algebra.Process)
+}
+
+// Violation is an instance of a law whose sides are not equivalent.
+type Violation struct {
+	Law      *Law
+	LHS, RHS algebra.Process
+	// Reason tells why the sides are not equivalent.
+	Reason string
+}
+
+func (v *Violation) Error() string {
+	return fmt.Sprintf("laws: %s does not hold up to %v for %v = %v: %s", v.Law.Name, v.Law.Equivalence, v.LHS, v.RHS, v.Reason)
+}
+
+// Check checks n instances of the law, generated by g, and returns a
+// Violation of the first instance which violates it, nil if none does.
+func (l *Law) Check(g *Generator, n int) error {
+	for i := 0; i < n; i++ {
+		lhs, rhs := l.Sides(g)
+		ll, err := algebra.LTS(lhs)
+		if err != nil {
+			return err
+		}
+		rl, err := algebra.LTS(rhs)
+		if err != nil {
+			return err
+		}
+		if reason := l.Equivalence.differ(ll, rl); reason != "" {
+			return &Violation{Law: l, LHS: lhs, RHS: rhs, Reason: reason}
+		}
+	}
+	return nil
+}
+
+// Laws are the laws of the operators of package algebra, as in Roscoe,
+// A. W. (2010). Understanding Concurrent Systems. Springer. Laws such
+// as P ; SKIP = P, which hold only if termination is a signal rather
+// than an event, are left out, since package lts treats lts.Tick as an
+// event like any other.
+var Laws = []*Law{
+	// external choice
+	{"P □ Q = Q □ P", Bisimilar, func(g *Generator) (algebra.Process, algebra.Process) {
+		p, q := g.Process(), g.Process()
+		return algebra.ExtChoice(p, q), algebra.ExtChoice(q, p)
+	}},
+	{"(P □ Q) □ R = P □ (Q □ R)", Bisimilar, func(g *Generator) (algebra.Process, algebra.Process) {
+		p, q, r := g.Process(), g.Process(), g.Process()
+		return algebra.ExtChoice(algebra.ExtChoice(p, q), r), algebra.ExtChoice(p, algebra.ExtChoice(q, r))
+	}},
+	{"P □ STOP = P", Bisimilar, func(g *Generator) (algebra.Process, algebra.Process) {
+		p := g.Process()
+		return algebra.ExtChoice(p, algebra.Stop()), p
+	}},
+	{"P □ P = P", FD, func(g *Generator) (algebra.Process, algebra.Process) {
+		p := g.Process()
+		return algebra.ExtChoice(p, p), p
+	}},
+
+	// internal choice
+	{"P ⊓ Q = Q ⊓ P", Bisimilar, func(g *Generator) (algebra.Process, algebra.Process) {
+		p, q := g.Process(), g.Process()
+		return algebra.IntChoice(p, q), algebra.IntChoice(q, p)
+	}},
+	{"(P ⊓ Q) ⊓ R = P ⊓ (Q ⊓ R)", FD, func(g *Generator) (algebra.Process, algebra.Process) {
+		p, q, r := g.Process(), g.Process(), g.Process()
+		return algebra.IntChoice(algebra.IntChoice(p, q), r), algebra.IntChoice(p, algebra.IntChoice(q, r))
+	}},
+	{"P ⊓ P = P", WeaklyBisimilar, func(g *Generator) (algebra.Process, algebra.Process) {
+		p := g.Process()
+		return algebra.IntChoice(p, p), p
+	}},
+	{"P □ (Q ⊓ R) = (P □ Q) ⊓ (P □ R)", FD, func(g *Generator) (algebra.Process, algebra.Process) {
+		p, q, r := g.Process(), g.Process(), g.Process()
+		return algebra.ExtChoice(p, algebra.IntChoice(q, r)), algebra.IntChoice(algebra.ExtChoice(p, q), algebra.ExtChoice(p, r))
+	}},
+	{"P ⊓ (Q □ R) = (P ⊓ Q) □ (P ⊓ R)", FD, func(g *Generator) (algebra.Process, algebra.Process) {
+		p, q, r := g.Process(), g.Process(), g.Process()
+		return algebra.IntChoice(p, algebra.ExtChoice(q, r)), algebra.ExtChoice(algebra.IntChoice(p, q), algebra.IntChoice(p, r))
+	}},
+
+	// probabilistic choice, abstracted from its weights
+	{"P [w ⊕ v] Q = P ⊓ Q", Bisimilar, func(g *Generator) (algebra.Process, algebra.Process) {
+		p, q := g.Process(), g.Process()
+		w, v := float64(1+g.rand.Intn(3)), float64(1+g.rand.Intn(3))
+		return algebra.ProbChoice(p, w, q, v), algebra.IntChoice(p, q)
+	}},
+
+	// prefix
+	{"a → P □ a → Q = a → (P ⊓ Q)", FD, func(g *Generator) (algebra.Process, algebra.Process) {
+		a, p, q := g.Event(), g.Process(), g.Process()
+		return algebra.ExtChoice(algebra.Prefix(a, p), algebra.Prefix(a, q)), algebra.Prefix(a, algebra.IntChoice(p, q))
+	}},
+	{"a → (P ⊓ Q) = a → P ⊓ a → Q", FD, func(g *Generator) (algebra.Process, algebra.Process) {
+		a, p, q := g.Event(), g.Process(), g.Process()
+		return algebra.Prefix(a, algebra.IntChoice(p, q)), algebra.IntChoice(algebra.Prefix(a, p), algebra.Prefix(a, q))
+	}},
+
+	// parallel composition
+	{"P [|A|] Q = Q [|A|] P", Bisimilar, func(g *Generator) (algebra.Process, algebra.Process) {
+		p, a, q := g.Process(), g.Events(), g.Process()
+		return algebra.Par(p, a, q), algebra.Par(q, a, p)
+	}},
+	{"(P [|A|] Q) [|A|] R = P [|A|] (Q [|A|] R)", WeaklyBisimilar, func(g *Generator) (algebra.Process, algebra.Process) {
+		p, a, q, r := g.Process(), g.Events(), g.Process(), g.Process()
+		return algebra.Par(algebra.Par(p, a, q), a, r), algebra.Par(p, a, algebra.Par(q, a, r))
+	}},
+	{"P [|A|] (Q ⊓ R) = (P [|A|] Q) ⊓ (P [|A|] R)", FD, func(g *Generator) (algebra.Process, algebra.Process) {
+		p, a, q, r := g.Process(), g.Events(), g.Process(), g.Process()
+		return algebra.Par(p, a, algebra.IntChoice(q, r)), algebra.IntChoice(algebra.Par(p, a, q), algebra.Par(p, a, r))
+	}},
+	{"P [A || B] Q = Q [B || A] P", Bisimilar, func(g *Generator) (algebra.Process, algebra.Process) {
+		p, a, q, b := g.Process(), g.Events(), g.Process(), g.Events()
+		return algebra.ParAlpha(p, a, q, b), algebra.ParAlpha(q, b, p, a)
+	}},
+	{"P [Σ || Σ] Q = P [|Σ|] Q", Bisimilar, func(g *Generator) (algebra.Process, algebra.Process) {
+		p, q := g.Process(), g.Process()
+		return algebra.ParAlpha(p, g.Alphabet, q, g.Alphabet), algebra.Par(p, g.Alphabet, q)
+	}},
+	{"P ||| STOP = P ; STOP", Bisimilar, func(g *Generator) (algebra.Process, algebra.Process) {
+		p := g.Process()
+		return algebra.Interleave(p, algebra.Stop()), algebra.Seq(p, algebra.Stop())
+	}},
+	{"P ||| Q = Q ||| P", Bisimilar, func(g *Generator) (algebra.Process, algebra.Process) {
+		p, q := g.Process(), g.Process()
+		return algebra.Interleave(p, q), algebra.Interleave(q, p)
+	}},
+	{"a → P ||| b → Q = a → (P ||| b → Q) □ b → (a → P ||| Q)", Bisimilar, func(g *Generator) (algebra.Process, algebra.Process) {
+		a, p, b, q := g.Event(), g.Process(), g.Event(), g.Process()
+		ap, bq := algebra.Prefix(a, p), algebra.Prefix(b, q)
+		return algebra.Interleave(ap, bq), algebra.ExtChoice(algebra.Prefix(a, algebra.Interleave(p, bq)), algebra.Prefix(b, algebra.Interleave(ap, q)))
+	}},
+
+	// sequential composition
+	{"SKIP ; P = P", WeaklyBisimilar, func(g *Generator) (algebra.Process, algebra.Process) {
+		p := g.Process()
+		return algebra.Seq(algebra.Skip(), p), p
+	}},
+	{"STOP ; P = STOP", Bisimilar, func(g *Generator) (algebra.Process, algebra.Process) {
+		return algebra.Seq(algebra.Stop(), g.Process()), algebra.Stop()
+	}},
+	{"(P ; Q) ; R = P ; (Q ; R)", Bisimilar, func(g *Generator) (algebra.Process, algebra.Process) {
+		p, q, r := g.Process(), g.Process(), g.Process()
+		return algebra.Seq(algebra.Seq(p, q), r), algebra.Seq(p, algebra.Seq(q, r))
+	}},
+	{"(P ⊓ Q) ; R = (P ; R) ⊓ (Q ; R)", Bisimilar, func(g *Generator) (algebra.Process, algebra.Process) {
+		p, q, r := g.Process(), g.Process(), g.Process()
+		return algebra.Seq(algebra.IntChoice(p, q), r), algebra.IntChoice(algebra.Seq(p, r), algebra.Seq(q, r))
+	}},
+
+	// interrupt
+	{"P △ STOP = P", Bisimilar, func(g *Generator) (algebra.Process, algebra.Process) {
+		p := g.Process()
+		return algebra.Interrupt(p, algebra.Stop()), p
+	}},
+	{"STOP △ Q = Q", Bisimilar, func(g *Generator) (algebra.Process, algebra.Process) {
+		q := g.Process()
+		return algebra.Interrupt(algebra.Stop(), q), q
+	}},
+	{"(P △ Q) △ R = P △ (Q △ R)", Bisimilar, func(g *Generator) (algebra.Process, algebra.Process) {
+		p, q, r := g.Process(), g.Process(), g.Process()
+		return algebra.Interrupt(algebra.Interrupt(p, q), r), algebra.Interrupt(p, algebra.Interrupt(q, r))
+	}},
+	{"(a → P) △ Q = Q □ a → (P △ Q)", FD, func(g *Generator) (algebra.Process, algebra.Process) {
+		a, p, q := g.Event(), g.Process(), g.Process()
+		return algebra.Interrupt(algebra.Prefix(a, p), q), algebra.ExtChoice(q, algebra.Prefix(a, algebra.Interrupt(p, q)))
+	}},
+
+	// time
+	{"WAIT m ; WAIT n = WAIT (m + n)", WeaklyBisimilar, func(g *Generator) (algebra.Process, algebra.Process) {
+		m, n := g.rand.Intn(3), g.rand.Intn(3)
+		return algebra.Seq(algebra.Wait(m), algebra.Wait(n)), algebra.Wait(m + n)
+	}},
+	{"STOP [d> Q = WAIT d ; Q", Bisimilar, func(g *Generator) (algebra.Process, algebra.Process) {
+		d, q := g.rand.Intn(3), g.Process()
+		return algebra.Timeout(algebra.Stop(), d, q), algebra.Seq(algebra.Wait(d), q)
+	}},
+
+	// priority
+	{"prioritise(P, ⟨⟩) = P", Bisimilar, func(g *Generator) (algebra.Process, algebra.Process) {
+		p := g.Process()
+		return algebra.Prioritise(p), p
+	}},
+	{"prioritise(P ⊓ Q, ⟨A, B⟩) = prioritise(P, ⟨A, B⟩) ⊓ prioritise(Q, ⟨A, B⟩)", Bisimilar, func(g *Generator) (algebra.Process, algebra.Process) {
+		p, q, a, b := g.Process(), g.Process(), g.Events(), g.Events()
+		return algebra.Prioritise(algebra.IntChoice(p, q), a, b), algebra.IntChoice(algebra.Prioritise(p, a, b), algebra.Prioritise(q, a, b))
+	}},
+
+	// hiding
+	{`(P \ A) \ B = P \ (A ∪ B)`, Bisimilar, func(g *Generator) (algebra.Process, algebra.Process) {
+		p, a, b := g.Process(), g.Events(), g.Events()
+		return algebra.Hide(algebra.Hide(p, a), b), algebra.Hide(p, append(append([]algebra.Event(nil), a...), b...))
+	}},
+	{`(a → P) \ {a} = P \ {a}`, WeaklyBisimilar, func(g *Generator) (algebra.Process, algebra.Process) {
+		a, p := g.Event(), g.Process()
+		return algebra.Hide(algebra.Prefix(a, p), []algebra.Event{a}), algebra.Hide(p, []algebra.Event{a})
+	}},
+	{`(P ⊓ Q) \ A = (P \ A) ⊓ (Q \ A)`, Bisimilar, func(g *Generator) (algebra.Process, algebra.Process) {
+		p, q, a := g.Process(), g.Process(), g.Events()
+		return algebra.Hide(algebra.IntChoice(p, q), a), algebra.IntChoice(algebra.Hide(p, a), algebra.Hide(q, a))
+	}},
+
+	// renaming
+	{"(a → P)[[R]] = R(a) → P[[R]]", Bisimilar, func(g *Generator) (algebra.Process, algebra.Process) {
+		a, p, r := g.Event(), g.Process(), g.Renaming()
+		b := a
+		if to, ok := r[a]; ok {
+			b = to
+		}
+		return algebra.Rename(algebra.Prefix(a, p), r), algebra.Prefix(b, algebra.Rename(p, r))
+	}},
+	{"(P □ Q)[[R]] = P[[R]] □ Q[[R]]", Bisimilar, func(g *Generator) (algebra.Process, algebra.Process) {
+		p, q, r := g.Process(), g.Process(), g.Renaming()
+		return algebra.Rename(algebra.ExtChoice(p, q), r), algebra.ExtChoice(algebra.Rename(p, r), algebra.Rename(q, r))
+	}},
+	{"(P ⊓ Q)[[R]] = P[[R]] ⊓ Q[[R]]", Bisimilar, func(g *Generator) (algebra.Process, algebra.Process) {
+		p, q, r := g.Process(), g.Process(), g.Renaming()
+		return algebra.Rename(algebra.IntChoice(p, q), r), algebra.IntChoice(algebra.Rename(p, r), algebra.Rename(q, r))
+	}},
+	{"(P ; Q)[[R]] = P[[R]] ; Q[[R]]", Bisimilar, func(g *Generator) (algebra.Process, algebra.Process) {
+		p, q, r := g.Process(), g.Process(), g.Renaming()
+		return algebra.Rename(algebra.Seq(p, q), r), algebra.Seq(algebra.Rename(p, r), algebra.Rename(q, r))
+	}},
+}
+
+// Generator generates small processes at random.
+type Generator struct {
+	rand *rand.Rand
+
+	// Alphabet are the events of the processes, a and b by default.
+	Alphabet []algebra.Event
+	// Depth is the depth of the terms of the processes at most, 3 by
+	// default.
+	Depth int
+}
+
+// NewGenerator returns a generator whose processes are determined by
+// the seed.
+func NewGenerator(seed int64) *Generator {
+	return &Generator{
+		rand:     rand.New(rand.NewSource(seed)),
+		Alphabet: []algebra.Event{"a", "b"},
+		Depth:    3,
+	}
+}
+
+// Event returns an event of the alphabet.
+func (g *Generator) Event() algebra.Event {
+	return g.Alphabet[g.rand.Intn(len(g.Alphabet))]
+}
+
+// Events returns a set of events of the alphabet.
+func (g *Generator) Events() []algebra.Event {
+	var events []algebra.Event
+	for _, e := range g.Alphabet {
+		if g.rand.Intn(2) == 0 {
+			events = append(events, e)
+		}
+	}
+	return events
+}
+
+// Renaming returns a renaming of events of the alphabet to events of
+// the alphabet.
+func (g *Generator) Renaming() map[algebra.Event]algebra.Event {
+	r := map[algebra.Event]algebra.Event{}
+	for _, e := range g.Events() {
+		r[e] = g.Event()
+	}
+	return r
+}
+
+// Process returns a process. Its recursions are guarded, and tail
+// recursive but for choices, such that it has finitely many states.
+func (g *Generator) Process() algebra.Process {
+	return g.process(g.Depth, nil, nil)
+}
+
+// process returns a process of at most the depth, in which the
+// variables vars may recur, and those of unguarded may recur once
+// guarded by a prefix.
+func (g *Generator) process(depth int, vars, unguarded []string) algebra.Process {
+	const (
+		stop = iota
+		skip
+		variable
+		prefix
+		extChoice
+		intChoice
+		par
+		parAlpha
+		seq
+		interrupt
+		hide
+		rename
+		rec
+		ops
+	)
+	op := g.rand.Intn(variable + 1)
+	if depth > 0 {
+		op = g.rand.Intn(ops)
+	}
+	switch {
+	case op == variable && len(vars) == 0:
+		op = stop
+	case op == rec && len(vars)+len(unguarded) == 3:
+		op = prefix
+	}
+	switch op {
+	case stop:
+		return algebra.Stop()
+	case skip:
+		return algebra.Skip()
+	case variable:
+		return algebra.Var(vars[g.rand.Intn(len(vars))])
+	case prefix:
+		vars = append(append([]string(nil), vars...), unguarded...)
+		return algebra.Prefix(g.Event(), g.process(depth-1, vars, nil))
+	case extChoice:
+		return algebra.ExtChoice(g.process(depth-1, vars, unguarded), g.process(depth-1, vars, unguarded))
+	case intChoice:
+		return algebra.IntChoice(g.process(depth-1, vars, unguarded), g.process(depth-1, vars, unguarded))
+	case par:
+		return algebra.Par(g.process(depth-1, nil, nil), g.Events(), g.process(depth-1, nil, nil))
+	case parAlpha:
+		return algebra.ParAlpha(g.process(depth-1, nil, nil), g.Events(), g.process(depth-1, nil, nil), g.Events())
+	case seq:
+		return algebra.Seq(g.process(depth-1, nil, nil), g.process(depth-1, nil, nil))
+	case interrupt:
+		return algebra.Interrupt(g.process(depth-1, nil, nil), g.process(depth-1, nil, nil))
+	case hide:
+		return algebra.Hide(g.process(depth-1, nil, nil), g.Events())
+	case rename:
+		return algebra.Rename(g.process(depth-1, nil, nil), g.Renaming())
+	}
+	name := string(rune('X' + len(vars) + len(unguarded)))
+	unguarded = append(append([]string(nil), unguarded...), name)
+	return algebra.Rec(name, g.process(depth-1, vars, unguarded))
+}
@@ -0,0 +1,63 @@
+package laws_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/changkun/gobase/csp/algebra"
+	"github.com/changkun/gobase/csp/laws"
+)
+
+func TestLaws(t *testing.T) {
+	g := laws.NewGenerator(1)
+	for _, l := range laws.Laws {
+		if err := l.Check(g, 200); err != nil {
+			t.Fatalf("%v: %v", t.Name(), err)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		law  *laws.Law
+		want string
+	}{
+		{
+			law: &laws.Law{Name: "P □ Q = P", Equivalence: laws.Bisimilar, Sides: func(g *laws.Generator) (algebra.Process, algebra.Process) {
+				p, q := g.Process(), g.Process()
+				return algebra.ExtChoice(p, q), p
+			}},
+			want: "laws: P □ Q = P does not hold up to strong bisimulation for ",
+		},
+		{
+			law: &laws.Law{Name: "P ⊓ Q = P □ Q", Equivalence: laws.FD, Sides: func(g *laws.Generator) (algebra.Process, algebra.Process) {
+				p, q := algebra.Prefix("a", algebra.Stop()), algebra.Prefix("b", algebra.Stop())
+				return algebra.IntChoice(p, q), algebra.ExtChoice(p, q)
+			}},
+			want: "laws: P ⊓ Q = P □ Q does not hold up to failures-divergences equivalence for (a → STOP ⊓ b → STOP) = (a → STOP □ b → STOP): the left side does not refine the right side: after ⟨⟩: refuses {a}",
+		},
+	}
+	for _, tt := range tests {
+		err := tt.law.Check(laws.NewGenerator(1), 100)
+		var v *laws.Violation
+		if !errors.As(err, &v) || !strings.HasPrefix(err.Error(), tt.want) {
+			t.Fatalf("%v: expected: %v, got: %v", t.Name(), tt.want, err)
+		}
+	}
+}
+
+func TestGenerator(t *testing.T) {
+	// the processes are the same for the same seed, and have finitely
+	// many states.
+	a, b := laws.NewGenerator(2), laws.NewGenerator(2)
+	for i := 0; i < 100; i++ {
+		p, q := a.Process(), b.Process()
+		if p.String() != q.String() {
+			t.Fatalf("%v: expected: %v, got: %v", t.Name(), p, q)
+		}
+		if _, err := algebra.LTS(p); err != nil {
+			t.Fatalf("%v: %v: unexpected error: %v", t.Name(), p, err)
+		}
+	}
+}
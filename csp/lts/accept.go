@@ -0,0 +1,117 @@
+package lts
+
+import (
+	"fmt"
+	"sort"
+)
+
+// After returns the states l may be in after the trace, sorted: those
+// it reaches by the events of the trace and Tau transitions, from its
+// initial state. It returns nil if l cannot engage in the trace.
+func (l *LTS) After(trace []string) []int {
+	if l.States() == 0 {
+		return nil
+	}
+	states := l.closure([]int{0})
+	for _, e := range trace {
+		var next []int
+		for _, s := range states {
+			for _, t := range l.Trans[s] {
+				if t.Event == e {
+					next = append(next, t.To)
+				}
+			}
+		}
+		if next == nil {
+			return nil
+		}
+		states = l.closure(next)
+	}
+	return states
+}
+
+// Acceptances returns the minimal acceptances of the state s of l, as
+// of the stable failures model: the events offered by every stable
+// state s reaches by Tau transitions, including s itself, none of
+// which offers a subset of the events of another. Every set is sorted,
+// and the sets are in order. A state which reaches no stable state,
+// such as a divergent one, has no acceptances.
+func (l *LTS) Acceptances(s int) [][]string {
+	var accs [][]string
+	for _, u := range l.closure([]int{s}) {
+		if !l.stable(u) {
+			continue
+		}
+		acc := l.initials(u)
+		minimal := true
+		for _, a := range accs {
+			minimal = minimal && !subset(a, acc)
+		}
+		if !minimal {
+			continue
+		}
+		kept := accs[:0]
+		for _, a := range accs {
+			if !subset(acc, a) {
+				kept = append(kept, a)
+			}
+		}
+		accs = append(kept, acc)
+	}
+	sort.Slice(accs, func(i, j int) bool { return fmt.Sprint(accs[i]) < fmt.Sprint(accs[j]) })
+	return accs
+}
+
+// Refusals returns the maximal refusals of the state s of l over the
+// alphabet of l, the complements of its acceptances: the sets of events
+// s may refuse all of, once stable. That a buffer never refuses the
+// input in.0 after a trace is checked of the states after it by
+//
+//   for _, s := range l.After(trace) {
+//       for _, r := range l.Refusals(s) {
+//           if slices.Contains(r, "in.0") {
+//               log.Fatalf("the buffer may refuse in.0 after %v", trace)
+//           }
+//       }
+//   }
+func (l *LTS) Refusals(s int) [][]string {
+	alphabet := l.Alphabet()
+	var refs [][]string
+	for _, acc := range l.Acceptances(s) {
+		refs = append(refs, minus(alphabet, acc))
+	}
+	return refs
+}
+
+// closure returns the states l reaches from the states by Tau
+// transitions, including them, sorted.
+func (l *LTS) closure(states []int) []int {
+	in := map[int]bool{}
+	var set []int
+	for len(states) > 0 {
+		s := states[len(states)-1]
+		states = states[:len(states)-1]
+		if in[s] {
+			continue
+		}
+		in[s] = true
+		set = append(set, s)
+		for _, t := range l.Trans[s] {
+			if t.Event == Tau {
+				states = append(states, t.To)
+			}
+		}
+	}
+	sort.Ints(set)
+	return set
+}
+
+// subset reports whether the events of a are all in b.
+func subset(a, b []string) bool {
+	for _, e := range a {
+		if !contains(b, e) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,59 @@
+package lts_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/changkun/gobase/csp/lts"
+)
+
+func TestAfter(t *testing.T) {
+	tests := []struct {
+		trace []string
+		want  string
+	}{
+		{nil, "[0 3]"},
+		{[]string{"in.0"}, "[1]"},
+		{[]string{"in.1", "out.1", lts.Tick}, "[4]"},
+		{[]string{"out.0"}, "[]"},
+	}
+	for _, tt := range tests {
+		if got := fmt.Sprint(buffer.After(tt.trace)); got != tt.want {
+			t.Fatalf("%v: %v: expected: %v, got: %v", t.Name(), tt.trace, tt.want, got)
+		}
+	}
+	if got := (&lts.LTS{}).After(nil); got != nil {
+		t.Fatalf("%v: expected no states, got: %v", t.Name(), got)
+	}
+}
+
+func TestAcceptances(t *testing.T) {
+	// internal offers a, or both a and b.
+	internal := &lts.LTS{Trans: [][]lts.Transition{
+		{{Event: lts.Tau, To: 1}, {Event: lts.Tau, To: 2}},
+		{{Event: "a", To: 3}},
+		{{Event: "a", To: 3}, {Event: "b", To: 3}},
+		nil,
+	}}
+	divergent := &lts.LTS{Trans: [][]lts.Transition{{{Event: lts.Tau, To: 0}}}}
+	tests := []struct {
+		l                *lts.LTS
+		s                int
+		accepts, refuses string
+	}{
+		{buffer, 0, "[[✓]]", "[[in.0 in.1 out.0 out.1]]"},
+		{buffer, 1, "[[out.0]]", "[[in.0 in.1 out.1 ✓]]"},
+		{buffer, 4, "[[]]", "[[in.0 in.1 out.0 out.1 ✓]]"},
+		{internal, 0, "[[a]]", "[[b]]"},
+		{internal, 2, "[[a b]]", "[[]]"},
+		{divergent, 0, "[]", "[]"},
+	}
+	for _, tt := range tests {
+		if got := fmt.Sprint(tt.l.Acceptances(tt.s)); got != tt.accepts {
+			t.Fatalf("%v: %d: expected acceptances: %v, got: %v", t.Name(), tt.s, tt.accepts, got)
+		}
+		if got := fmt.Sprint(tt.l.Refusals(tt.s)); got != tt.refuses {
+			t.Fatalf("%v: %d: expected refusals: %v, got: %v", t.Name(), tt.s, tt.refuses, got)
+		}
+	}
+}
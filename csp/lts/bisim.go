@@ -0,0 +1,242 @@
+package lts
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Difference tells two processes apart which are not bisimilar: a
+// formula of Hennessy-Milner logic the first satisfies, and the second
+// does not.
+//
+// A formula is true, the negation ¬f of a formula f, the conjunction
+// (f ∧ g) of formulas, or ⟨e⟩f, which a process satisfies if it may
+// engage in the event e, including Tau, to a state satisfying f. In a
+// difference of weak bisimulation, the process may also take any Tau
+// transitions before and after e, and ⟨τ⟩f is satisfied by a process
+// that satisfies f after Tau transitions only, possibly none.
+type Difference struct {
+	Formula string
+}
+
+// String returns the formula of d, such as
+//
+//   ⟨in.0⟩¬⟨out.0⟩true
+func (d *Difference) String() string {
+	return d.Formula
+}
+
+// Bisimilar reports whether a and b are strongly bisimilar: whether
+// their states are related, the initial states of a and b with each
+// other, such that whenever a state engages in an event, including Tau,
+// the states related to it engage in the same event to states related
+// to the state it engages in the event to. It returns nil if so, or else
+// a difference.
+//
+// Bisimilar processes are equivalent in every model of CSP, but
+// processes may be equivalent without being bisimilar.
+func Bisimilar(a, b *LTS) *Difference {
+	return bisimilar(a, b, false)
+}
+
+// WeaklyBisimilar reports whether a and b are weakly bisimilar: whether
+// they are bisimilar but for Tau transitions, as a process engaging in
+// an event to a state may be matched by one taking Tau transitions
+// before and after the event, and a Tau transition by Tau transitions,
+// possibly none. It returns nil if so, or else a difference.
+//
+// Weak bisimulation proves alternative implementations equivalent
+// whose internal steps differ, such as a process and another which
+// delegates the same work to a process of its own.
+func WeaklyBisimilar(a, b *LTS) *Difference {
+	return bisimilar(a, b, true)
+}
+
+func bisimilar(a, b *LTS, weak bool) *Difference {
+	// the disjoint union of a and b, an LTS without states being a
+	// process which engages in no events.
+	u := &LTS{}
+	offset := func(l *LTS) int {
+		n := len(u.Trans)
+		if l.States() == 0 {
+			u.Trans = append(u.Trans, nil)
+			return n
+		}
+		for _, ts := range l.Trans {
+			shifted := make([]Transition, len(ts))
+			for i, t := range ts {
+				shifted[i] = Transition{Event: t.Event, To: t.To + n}
+			}
+			u.Trans = append(u.Trans, shifted)
+		}
+		return n
+	}
+	p, q := offset(a), offset(b)
+	if weak {
+		u = saturate(u)
+	}
+	rounds := partition(u)
+	if last := rounds[len(rounds)-1]; last[p] == last[q] {
+		return nil
+	}
+	return &Difference{Formula: distinguish(u, rounds, p, q)}
+}
+
+// saturate returns the LTS whose transitions are the weak transitions
+// of l: a Tau transition from every state to every state it reaches by
+// Tau transitions, including itself, and a transition of every other
+// event to every state it reaches by Tau transitions, the event and
+// again Tau transitions.
+func saturate(l *LTS) *LTS {
+	closure := func(s int) []int {
+		seen := map[int]bool{s: true}
+		states := []int{s}
+		for i := 0; i < len(states); i++ {
+			for _, t := range l.Trans[states[i]] {
+				if t.Event == Tau && !seen[t.To] {
+					seen[t.To] = true
+					states = append(states, t.To)
+				}
+			}
+		}
+		return states
+	}
+	taus := make([][]int, l.States())
+	for s := range taus {
+		taus[s] = closure(s)
+	}
+	sat := &LTS{Trans: make([][]Transition, l.States())}
+	for s := range sat.Trans {
+		seen := map[Transition]bool{}
+		add := func(t Transition) {
+			if !seen[t] {
+				seen[t] = true
+				sat.Trans[s] = append(sat.Trans[s], t)
+			}
+		}
+		for _, u := range taus[s] {
+			add(Transition{Event: Tau, To: u})
+			for _, t := range l.Trans[u] {
+				if t.Event == Tau {
+					continue
+				}
+				for _, v := range taus[t.To] {
+					add(Transition{Event: t.Event, To: v})
+				}
+			}
+		}
+	}
+	return sat
+}
+
+// partition returns the blocks of the states of l in every round of
+// partition refinement, until bisimilar states share a block: all
+// states share the first block, and two states share a block in the
+// next round if they shared one, and engage in the same events to the
+// same blocks.
+func partition(l *LTS) [][]int {
+	rounds := [][]int{make([]int, l.States())}
+	for {
+		blocks := rounds[len(rounds)-1]
+		ids := map[string]int{}
+		next := make([]int, l.States())
+		for s, ts := range l.Trans {
+			sig := signature(ts, blocks)
+			key := strconv.Itoa(blocks[s]) + "|" + strings.Join(sig, "|")
+			id, ok := ids[key]
+			if !ok {
+				id = len(ids)
+				ids[key] = id
+			}
+			next[s] = id
+		}
+		if len(ids) == count(blocks) {
+			return rounds
+		}
+		rounds = append(rounds, next)
+	}
+}
+
+// signature returns the events of the transitions ts and the blocks
+// they lead to, sorted and without duplicates.
+func signature(ts []Transition, blocks []int) []string {
+	var sig []string
+	for _, t := range ts {
+		sig = append(sig, t.Event+" "+strconv.Itoa(blocks[t.To]))
+	}
+	sort.Strings(sig)
+	n := 0
+	for i, s := range sig {
+		if i == 0 || s != sig[i-1] {
+			sig[n] = s
+			n++
+		}
+	}
+	return sig[:n]
+}
+
+// count returns the number of distinct blocks.
+func count(blocks []int) int {
+	seen := map[int]bool{}
+	for _, b := range blocks {
+		seen[b] = true
+	}
+	return len(seen)
+}
+
+// distinguish returns a formula the state p of l satisfies, and the
+// state q does not, which belong to different blocks of the last round.
+func distinguish(l *LTS, rounds [][]int, p, q int) string {
+	// p and q are apart from the round k on, as one of them engages in
+	// an event to a block of the round before the other does not.
+	k := 1
+	for rounds[k][p] == rounds[k][q] {
+		k++
+	}
+	before := rounds[k-1]
+	if t, ok := unmatched(l, before, p, q); ok {
+		var fs []string
+		for _, u := range l.Trans[q] {
+			if u.Event == t.Event {
+				fs = append(fs, distinguish(l, rounds[:k], t.To, u.To))
+			}
+		}
+		return "⟨" + t.Event + "⟩" + conjunction(fs)
+	}
+	return "¬" + distinguish(l, rounds, q, p)
+}
+
+// unmatched returns a transition of the state p of l to a block which
+// the state q has no transition of the same event to.
+func unmatched(l *LTS, blocks []int, p, q int) (Transition, bool) {
+	for _, t := range l.Trans[p] {
+		matched := false
+		for _, u := range l.Trans[q] {
+			matched = matched || u.Event == t.Event && blocks[u.To] == blocks[t.To]
+		}
+		if !matched {
+			return t, true
+		}
+	}
+	return Transition{}, false
+}
+
+// conjunction returns the conjunction of the formulas fs, without
+// duplicates, true if there are none.
+func conjunction(fs []string) string {
+	sort.Strings(fs)
+	var uniq []string
+	for i, f := range fs {
+		if i == 0 || f != fs[i-1] {
+			uniq = append(uniq, f)
+		}
+	}
+	switch len(uniq) {
+	case 0:
+		return "true"
+	case 1:
+		return uniq[0]
+	}
+	return "(" + strings.Join(uniq, " ∧ ") + ")"
+}
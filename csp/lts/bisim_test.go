@@ -0,0 +1,68 @@
+package lts_test
+
+import (
+	"testing"
+
+	"github.com/changkun/gobase/csp/lts"
+)
+
+func TestBisimilar(t *testing.T) {
+	// copy outputs every value it inputs.
+	copy := &lts.LTS{Trans: [][]lts.Transition{
+		{{Event: "in.0", To: 1}},
+		{{Event: "out.0", To: 0}},
+	}}
+	// unrolled is copy unrolled once.
+	unrolled := &lts.LTS{Trans: [][]lts.Transition{
+		{{Event: "in.0", To: 1}},
+		{{Event: "out.0", To: 2}},
+		{{Event: "in.0", To: 3}},
+		{{Event: "out.0", To: 0}},
+	}}
+	// relay passes every value on by a step of its own.
+	relay := &lts.LTS{Trans: [][]lts.Transition{
+		{{Event: "in.0", To: 1}},
+		{{Event: lts.Tau, To: 2}},
+		{{Event: "out.0", To: 0}},
+	}}
+	// lossy may lose a value, or output it.
+	lossy := &lts.LTS{Trans: [][]lts.Transition{
+		{{Event: "in.0", To: 1}, {Event: "in.0", To: 2}},
+		{{Event: "out.0", To: 0}},
+		nil,
+	}}
+	// once outputs a single value.
+	once := &lts.LTS{Trans: [][]lts.Transition{
+		{{Event: "in.0", To: 1}},
+		{{Event: "out.0", To: 2}},
+		nil,
+	}}
+
+	tests := []struct {
+		a, b         *lts.LTS
+		strong, weak string
+	}{
+		{a: copy, b: copy},
+		{a: copy, b: unrolled},
+		{a: copy, b: relay, strong: "⟨in.0⟩⟨out.0⟩true"},
+		{a: relay, b: copy, strong: "⟨in.0⟩⟨τ⟩true"},
+		{a: copy, b: lossy, strong: "¬⟨in.0⟩¬⟨out.0⟩true", weak: "¬⟨in.0⟩¬⟨out.0⟩true"},
+		{a: copy, b: once, strong: "⟨in.0⟩⟨out.0⟩⟨in.0⟩true", weak: "⟨in.0⟩⟨out.0⟩⟨in.0⟩true"},
+		{a: &lts.LTS{}, b: &lts.LTS{Trans: [][]lts.Transition{nil}}},
+		{a: copy, b: &lts.LTS{}, strong: "⟨in.0⟩true", weak: "⟨in.0⟩true"},
+	}
+	for i, tt := range tests {
+		for _, c := range []struct {
+			got  *lts.Difference
+			want string
+		}{{lts.Bisimilar(tt.a, tt.b), tt.strong}, {lts.WeaklyBisimilar(tt.a, tt.b), tt.weak}} {
+			got := ""
+			if c.got != nil {
+				got = c.got.String()
+			}
+			if got != c.want {
+				t.Fatalf("%v: %d: expected: %q, got: %q", t.Name(), i, c.want, got)
+			}
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package lts
+
+import "fmt"
+
+// Nondeterminism is the nondeterminism of a process: after a trace, it
+// may both engage in an event and refuse it, or it diverges.
+type Nondeterminism struct {
+	// Trace is the events the process engages in first, without Tau.
+	Trace []string
+	// Event is the event the process may both engage in and refuse
+	// after Trace, empty for a divergence.
+	Event string
+	// State is the stable state refusing Event after Trace, or the
+	// state diverging.
+	State int
+	// Divergence reports whether the process diverges after Trace.
+	Divergence bool
+}
+
+// String formats d, such as
+//
+//   after in.0: may engage in out.0 or refuse it in state 3
+//   after ⟨⟩: diverges in state 2
+func (d *Nondeterminism) String() string {
+	if d.Divergence {
+		return fmt.Sprintf("after %s: diverges in state %d", trace(d.Trace), d.State)
+	}
+	return fmt.Sprintf("after %s: may engage in %s or refuse it in state %d", trace(d.Trace), d.Event, d.State)
+}
+
+// Deterministic reports whether l is deterministic in the
+// failures-divergences model of CSP, as in FDR: whether it never
+// diverges, and after every trace, whenever it may engage in an event,
+// it cannot refuse it. A deterministic process is a function of its
+// environment, such as a pipeline stage whose outputs are a function of
+// its inputs, however its steps are scheduled. Deterministic returns
+// nil if so, or else the smallest nondeterminism: after as few events
+// as possible, and of those the first in the order of events, as the
+// normal form of l is explored breadth first by the events in order.
+func Deterministic(l *LTS) *Nondeterminism {
+	if l.States() == 0 {
+		return nil
+	}
+	n := newNormal(l)
+	divergent := l.divergent()
+
+	// the states of the normal form, each with the state and the event
+	// it was reached by.
+	type node struct {
+		parent int
+		event  string
+	}
+	nodes := map[int]node{n.initial(): {parent: -1}}
+	queue := []int{n.initial()}
+	nondeterminism := func(s int) *Nondeterminism {
+		d := &Nondeterminism{}
+		for ; nodes[s].parent >= 0; s = nodes[s].parent {
+			d.Trace = append([]string{nodes[s].event}, d.Trace...)
+		}
+		return d
+	}
+	for len(queue) > 0 {
+		s := queue[0]
+		queue = queue[1:]
+		var events []string
+		for _, u := range n.sets[s] {
+			events = union(events, l.initials(u))
+		}
+		var d *Nondeterminism
+		for _, u := range n.sets[s] {
+			if divergent[u] {
+				d = nondeterminism(s)
+				d.Divergence, d.State = true, u
+				return d
+			}
+			if !l.stable(u) {
+				continue
+			}
+			if refused := minus(events, l.initials(u)); len(refused) > 0 && (d == nil || refused[0] < d.Event) {
+				d = nondeterminism(s)
+				d.Event, d.State = refused[0], u
+			}
+		}
+		if d != nil {
+			return d
+		}
+		for _, e := range events {
+			next := n.after(s, e)
+			if _, ok := nodes[next]; !ok {
+				nodes[next] = node{parent: s, event: e}
+				queue = append(queue, next)
+			}
+		}
+	}
+	return nil
+}
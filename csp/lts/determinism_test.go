@@ -0,0 +1,56 @@
+package lts_test
+
+import (
+	"testing"
+
+	"github.com/changkun/gobase/csp/lts"
+)
+
+func TestDeterministic(t *testing.T) {
+	// copy outputs every value it inputs, after a step of its own.
+	copy := &lts.LTS{Trans: [][]lts.Transition{
+		{{Event: "in.0", To: 1}, {Event: "in.1", To: 2}},
+		{{Event: lts.Tau, To: 3}},
+		{{Event: "out.1", To: 0}},
+		{{Event: "out.0", To: 0}},
+	}}
+	// lossy may drop a value instead of outputting it.
+	lossy := &lts.LTS{Trans: [][]lts.Transition{
+		{{Event: "in.0", To: 1}},
+		{{Event: "out.0", To: 0}, {Event: lts.Tau, To: 0}},
+	}}
+	// guess decides which value to input.
+	guess := &lts.LTS{Trans: [][]lts.Transition{
+		{{Event: lts.Tau, To: 1}, {Event: lts.Tau, To: 2}},
+		{{Event: "in.0", To: 0}},
+		{{Event: "in.1", To: 0}},
+	}}
+	// spin may diverge after a value.
+	spin := &lts.LTS{Trans: [][]lts.Transition{
+		{{Event: "in.0", To: 1}},
+		{{Event: lts.Tau, To: 1}, {Event: "out.0", To: 0}},
+	}}
+
+	tests := []struct {
+		l    *lts.LTS
+		want string
+	}{
+		{l: copy},
+		{l: lts.DF(nil)},
+		{l: &lts.LTS{}},
+		{l: lossy, want: "after in.0: may engage in out.0 or refuse it in state 0"},
+		{l: guess, want: "after ⟨⟩: may engage in in.0 or refuse it in state 2"},
+		{l: spin, want: "after in.0: diverges in state 1"},
+		// buffer may either terminate or input a value.
+		{l: buffer, want: "after ⟨⟩: may engage in in.0 or refuse it in state 3"},
+	}
+	for i, tt := range tests {
+		got := ""
+		if d := lts.Deterministic(tt.l); d != nil {
+			got = d.String()
+		}
+		if got != tt.want {
+			t.Fatalf("%v: %d: expected: %q, got: %q", t.Name(), i, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+// Package lts implements labelled transition systems, the operational
+// semantics of processes: a state for every state a process may be in,
+// and a transition from one state to another for every event the
+// process may engage in there, labelled by the event, or by Tau for a
+// step of its own the environment does not take part in.
+//
+// Package lang generates the transition system of a program, whose
+// events are its communications with external names:
+//
+//   l, err := (&lang.Analyzer{Program: prog, Inputs: inputs}).LTS(ctx)
+//   lts.Dot(os.Stdout, l)
+//
+// Refines checks that an implementation refines its specification in
+// the traces model, RefinesFailures in the stable failures model and
+// RefinesFD in the failures-divergences model, reporting a
+// counterexample otherwise:
+//
+//   if c := lts.Refines(spec, impl); c != nil {
+//       log.Fatalf("impl does not refine spec: %v", c)
+//   }
+//   if c := lts.RefinesFailures(lts.DF(impl.Alphabet()), impl); c != nil {
+//       log.Fatalf("impl may deadlock: %v", c)
+//   }
+//
+// Deterministic checks that a process is deterministic, reporting where
+// it is not.
+//
+// After returns the states a process may be in after a trace, and
+// Acceptances and Refusals the events such a state offers and refuses
+// once stable, for checks of their own without a specification.
+//
+// Bisimilar and WeaklyBisimilar check that two processes are
+// equivalent, reporting a formula telling them apart otherwise.
+//
+// Transition systems are explicit, every state is a number, hence they
+// are meant for small models only.
+package lts
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+)
+
+const (
+	// Tau labels the internal transitions.
+	Tau = "τ"
+	// Tick labels the successful termination of a process.
+	Tick = "✓"
+)
+
+// Transition is a transition to the state To.
+type Transition struct {
+	Event string
+	To    int
+}
+
+// LTS is a labelled transition system. Its states are numbered from 0,
+// the initial state, to States()-1.
+type LTS struct {
+	// Trans are the transitions from every state.
+	Trans [][]Transition
+}
+
+// States returns the number of states of l.
+func (l *LTS) States() int {
+	return len(l.Trans)
+}
+
+// Alphabet returns the events of the transitions of l other than Tau,
+// sorted.
+func (l *LTS) Alphabet() []string {
+	seen := map[string]bool{}
+	var events []string
+	for _, ts := range l.Trans {
+		for _, t := range ts {
+			if t.Event != Tau && !seen[t.Event] {
+				seen[t.Event] = true
+				events = append(events, t.Event)
+			}
+		}
+	}
+	sort.Strings(events)
+	return events
+}
+
+// Dot writes l in the DOT language of Graphviz. States are nodes,
+// numbered as in l, transitions edges labelled by their events, and an
+// edge from a point marks the initial state:
+//
+//   digraph {
+//       init [shape=point];
+//       init -> 0;
+//       0 -> 1 [label="west.'a'"];
+//       1 -> 0 [label="east.'a'"];
+//   }
+func Dot(w io.Writer, l *LTS) error {
+	b := bufio.NewWriter(w)
+	fmt.Fprintln(b, "digraph {")
+	fmt.Fprintln(b, "    init [shape=point];")
+	if l.States() > 0 {
+		fmt.Fprintln(b, "    init -> 0;")
+	}
+	for s, ts := range l.Trans {
+		for _, t := range ts {
+			fmt.Fprintf(b, "    %d -> %d [label=%s];\n", s, t.To, strconv.Quote(t.Event))
+		}
+	}
+	fmt.Fprintln(b, "}")
+	return b.Flush()
+}
@@ -0,0 +1,49 @@
+package lts_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/changkun/gobase/csp/lts"
+)
+
+// buffer is a buffer of one value, either 0 or 1, which may also decide
+// to terminate once empty.
+var buffer = &lts.LTS{Trans: [][]lts.Transition{
+	{{Event: "in.0", To: 1}, {Event: "in.1", To: 2}, {Event: lts.Tau, To: 3}},
+	{{Event: "out.0", To: 0}},
+	{{Event: "out.1", To: 0}},
+	{{Event: lts.Tick, To: 4}},
+	nil,
+}}
+
+func TestAlphabet(t *testing.T) {
+	want := "in.0 in.1 out.0 out.1 ✓"
+	if got := strings.Join(buffer.Alphabet(), " "); got != want {
+		t.Fatalf("%v: expected: %v, got: %v", t.Name(), want, got)
+	}
+	if got := buffer.States(); got != 5 {
+		t.Fatalf("%v: expected: %v states, got: %v", t.Name(), 5, got)
+	}
+}
+
+func TestDot(t *testing.T) {
+	var b strings.Builder
+	if err := lts.Dot(&b, buffer); err != nil {
+		t.Fatalf("%v: unexpected error: %v", t.Name(), err)
+	}
+	want := `digraph {
+    init [shape=point];
+    init -> 0;
+    0 -> 1 [label="in.0"];
+    0 -> 2 [label="in.1"];
+    0 -> 3 [label="τ"];
+    1 -> 0 [label="out.0"];
+    2 -> 0 [label="out.1"];
+    3 -> 4 [label="✓"];
+}
+`
+	if got := b.String(); got != want {
+		t.Fatalf("%v: expected:\n%v\ngot:\n%v", t.Name(), want, got)
+	}
+}
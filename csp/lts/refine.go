@@ -0,0 +1,451 @@
+package lts
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Counterexample is a behaviour of an implementation its specification
+// does not allow: after a trace the specification allows, the
+// implementation engages in an event, refuses events, or diverges,
+// which the specification does not.
+type Counterexample struct {
+	// Trace is the events the implementation engages in first, which
+	// the specification allows, without Tau.
+	Trace []string
+	// Event is the event the implementation engages in next, which the
+	// specification does not allow, if any.
+	Event string
+	// Refusal is, for a failure, the events of either alphabet the
+	// implementation refuses in a stable state after Trace, all of
+	// which no stable state of the specification refuses.
+	Refusal []string
+	// Divergence reports whether the implementation diverges after
+	// Trace.
+	Divergence bool
+}
+
+// String formats c, such as
+//
+//   after west.'a', east.'a': east.'b'
+//   after west.'a': refuses {east.'a', ✓}
+//   after ⟨⟩: diverges
+func (c *Counterexample) String() string {
+	switch {
+	case c.Divergence:
+		return fmt.Sprintf("after %s: diverges", trace(c.Trace))
+	case c.Event == "":
+		return fmt.Sprintf("after %s: refuses {%s}", trace(c.Trace), strings.Join(c.Refusal, ", "))
+	}
+	return fmt.Sprintf("after %s: %s", trace(c.Trace), c.Event)
+}
+
+// trace formats the events of a trace, such as west.'a', east.'a', or
+// ⟨⟩ for the empty trace.
+func trace(events []string) string {
+	if len(events) == 0 {
+		return "⟨⟩"
+	}
+	return strings.Join(events, ", ")
+}
+
+// model is a semantic model of CSP.
+type model int
+
+const (
+	traces model = iota
+	failures
+	failuresDivergences
+)
+
+// Refines reports whether impl refines spec in the traces model of CSP,
+// spec ⊑T impl: whether every trace of impl, its events without Tau, is
+// a trace of spec. It returns nil if so, or else a counterexample.
+//
+// As in FDR, spec is normalized, such that its states after a trace
+// are a single state, and the pairs of the states of spec and impl
+// after the same traces are explored breadth first. Once a
+// counterexample is found, it is shrunk to the smallest: of as few
+// events as possible, and of those the first in the order of events,
+// however long the paths of impl to it are.
+func Refines(spec, impl *LTS) *Counterexample {
+	return refines(spec, impl, traces)
+}
+
+// RefinesFailures reports whether impl refines spec in the stable
+// failures model of CSP, spec ⊑F impl: whether impl refines spec in the
+// traces model, and whenever impl is in a stable state after a trace,
+// which has no Tau transitions, spec may be in a stable state after the
+// same trace which refuses all the events impl refuses. It returns nil
+// if so, or else a counterexample. Tick is an event as any other.
+//
+// As the specification DF is free of deadlocks, impl is free of
+// deadlocks if DF(impl.Alphabet()) ⊑F impl. As for Refines, the
+// counterexample is the smallest.
+func RefinesFailures(spec, impl *LTS) *Counterexample {
+	return refines(spec, impl, failures)
+}
+
+// RefinesFD reports whether impl refines spec in the failures-
+// divergences model of CSP, spec ⊑FD impl: whether impl refines spec in
+// the stable failures model, and diverges after a trace, by an infinite
+// sequence of Tau transitions, only if spec diverges after the same
+// trace, after which spec allows any behaviour. It returns nil if so,
+// or else the smallest counterexample, as for Refines.
+func RefinesFD(spec, impl *LTS) *Counterexample {
+	return refines(spec, impl, failuresDivergences)
+}
+
+func refines(spec, impl *LTS, m model) *Counterexample {
+	if impl.States() == 0 {
+		return nil
+	}
+	r := newRefinement(spec, impl, m)
+
+	// the pairs of a normal state of spec and a state of impl, as
+	// seen.
+	start := [2]int{r.spec.initial(), 0}
+	pairs := [][2]int{start}
+	seen := map[[2]int]bool{start: true}
+	for i := 0; i < len(pairs); i++ {
+		s, p := pairs[i][0], pairs[i][1]
+		if m == failuresDivergences && r.spec.divergent(s) {
+			continue
+		}
+		if r.violation(s, []int{p}) != nil {
+			return r.smallest()
+		}
+		for _, t := range impl.Trans[p] {
+			next := [2]int{s, t.To}
+			if t.Event != Tau {
+				next[0] = r.spec.after(s, t.Event)
+			}
+			if !seen[next] {
+				seen[next] = true
+				pairs = append(pairs, next)
+			}
+		}
+	}
+	return nil
+}
+
+// refinement is a check of the refinement of spec by impl in a model.
+type refinement struct {
+	spec, impl *normal
+	m          model
+	divergent  []bool   // of the states of impl
+	sigma      []string // the events of spec and impl
+}
+
+func newRefinement(spec, impl *LTS, m model) *refinement {
+	return &refinement{
+		spec:      newNormal(spec),
+		impl:      newNormal(impl),
+		m:         m,
+		divergent: impl.divergent(),
+		sigma:     union(spec.Alphabet(), impl.Alphabet()),
+	}
+}
+
+// violation returns the counterexample, without its trace, of the
+// states of impl after a trace after which spec is in its normal state
+// s, or nil if they behave as spec allows: it diverges, refuses events,
+// or engages in an event spec does not allow, in this order, and of
+// those the first in the order of events.
+func (r *refinement) violation(s int, states []int) *Counterexample {
+	impl := r.impl.l
+	if r.m == failuresDivergences {
+		for _, u := range states {
+			if r.divergent[u] {
+				return &Counterexample{Divergence: true}
+			}
+		}
+	}
+	if r.m != traces {
+		var refusals [][]string
+		for _, u := range states {
+			if acc := impl.initials(u); impl.stable(u) && !r.spec.accepts(s, acc) {
+				refusals = append(refusals, minus(r.sigma, acc))
+			}
+		}
+		if len(refusals) > 0 {
+			sort.Slice(refusals, func(i, j int) bool { return less(refusals[i], refusals[j]) })
+			return &Counterexample{Refusal: refusals[0]}
+		}
+	}
+	var events []string
+	for _, u := range states {
+		events = union(events, impl.initials(u))
+	}
+	for _, e := range events {
+		if r.spec.after(s, e) < 0 {
+			return &Counterexample{Event: e}
+		}
+	}
+	return nil
+}
+
+// smallest returns the smallest counterexample, as a shrinking pass
+// once a counterexample was found: the first in the order of their
+// traces, the shortest first, and then by their events. It explores the
+// normal forms of spec and impl together breadth first, by the events
+// in order, such that a state is reached by the smallest of the traces
+// leading to it.
+func (r *refinement) smallest() *Counterexample {
+	type node struct {
+		spec, impl int
+		parent     int
+		event      string
+	}
+	nodes := []node{{spec: r.spec.initial(), impl: r.impl.initial(), parent: -1}}
+	seen := map[[2]int]bool{{nodes[0].spec, nodes[0].impl}: true}
+	for i := 0; i < len(nodes); i++ {
+		n := nodes[i]
+		if r.m == failuresDivergences && r.spec.divergent(n.spec) {
+			continue
+		}
+		if c := r.violation(n.spec, r.impl.sets[n.impl]); c != nil {
+			for ; i > 0; i = nodes[i].parent {
+				c.Trace = append([]string{nodes[i].event}, c.Trace...)
+			}
+			return c
+		}
+		var events []string
+		for _, u := range r.impl.sets[n.impl] {
+			events = union(events, r.impl.l.initials(u))
+		}
+		for _, e := range events {
+			next := node{spec: r.spec.after(n.spec, e), impl: r.impl.after(n.impl, e), parent: i, event: e}
+			if key := [2]int{next.spec, next.impl}; !seen[key] {
+				seen[key] = true
+				nodes = append(nodes, next)
+			}
+		}
+	}
+	return nil
+}
+
+// less reports whether the events a precede the events b, the shorter
+// first.
+func less(a, b []string) bool {
+	if len(a) != len(b) {
+		return len(a) < len(b)
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return a[i] < b[i]
+		}
+	}
+	return false
+}
+
+// DF returns the most nondeterministic process free of deadlocks over
+// the events, which may choose to engage in any of them forever, or to
+// terminate:
+//
+//   DF = (⊓ e : events • e → DF) ⊓ SKIP
+func DF(events []string) *LTS {
+	// the state of the choice, the states after choosing every
+	// event, the state after choosing to terminate, and the state
+	// once terminated.
+	l := &LTS{Trans: [][]Transition{nil}}
+	for _, e := range events {
+		if e == Tau || e == Tick {
+			continue
+		}
+		l.Trans[0] = append(l.Trans[0], Transition{Event: Tau, To: len(l.Trans)})
+		l.Trans = append(l.Trans, []Transition{{Event: e, To: 0}})
+	}
+	skip := len(l.Trans)
+	l.Trans[0] = append(l.Trans[0], Transition{Event: Tau, To: skip})
+	l.Trans = append(l.Trans, []Transition{{Event: Tick, To: skip + 1}}, nil)
+	return l
+}
+
+// stable reports whether the state s of l has no Tau transitions.
+func (l *LTS) stable(s int) bool {
+	for _, t := range l.Trans[s] {
+		if t.Event == Tau {
+			return false
+		}
+	}
+	return true
+}
+
+// initials returns the events of the transitions from the state s of
+// l, sorted.
+func (l *LTS) initials(s int) []string {
+	var events []string
+	for _, t := range l.Trans[s] {
+		if t.Event != Tau && !contains(events, t.Event) {
+			events = append(events, t.Event)
+		}
+	}
+	sort.Strings(events)
+	return events
+}
+
+// divergent returns whether every state of l diverges: whether it
+// reaches a cycle of Tau transitions by Tau transitions.
+func (l *LTS) divergent() []bool {
+	// the states without Tau transitions converge, and so do, in
+	// turn, those whose Tau transitions all lead to converging
+	// states, the others diverge.
+	converges := make([]bool, l.States())
+	pending := make([]int, l.States()) // the Tau transitions not known to converge
+	from := make([][]int, l.States())
+	var queue []int
+	for s, ts := range l.Trans {
+		for _, t := range ts {
+			if t.Event == Tau {
+				pending[s]++
+				from[t.To] = append(from[t.To], s)
+			}
+		}
+		if pending[s] == 0 {
+			queue = append(queue, s)
+		}
+	}
+	for len(queue) > 0 {
+		s := queue[0]
+		queue = queue[1:]
+		converges[s] = true
+		for _, u := range from[s] {
+			if pending[u]--; pending[u] == 0 {
+				queue = append(queue, u)
+			}
+		}
+	}
+	divergent := make([]bool, l.States())
+	for s := range divergent {
+		divergent[s] = !converges[s]
+	}
+	return divergent
+}
+
+// union returns the events of a or b, sorted.
+func union(a, b []string) []string {
+	events := append([]string(nil), a...)
+	for _, e := range b {
+		if !contains(events, e) {
+			events = append(events, e)
+		}
+	}
+	sort.Strings(events)
+	return events
+}
+
+// minus returns the events of a which are not in b.
+func minus(a, b []string) []string {
+	events := []string{}
+	for _, e := range a {
+		if !contains(b, e) {
+			events = append(events, e)
+		}
+	}
+	return events
+}
+
+func contains(events []string, e string) bool {
+	for _, x := range events {
+		if x == e {
+			return true
+		}
+	}
+	return false
+}
+
+// normal is the normal form of an LTS, built as needed: every state of
+// the normal form is the set of states the LTS may be in after a trace,
+// closed under Tau transitions, hence there is a single state after a
+// trace.
+type normal struct {
+	l          *LTS
+	ids        map[string]int
+	sets       [][]int
+	nexts      []map[string]int // the state after an event, -1 if none
+	divergents []bool           // of the states of l, once needed
+}
+
+func newNormal(l *LTS) *normal {
+	return &normal{l: l, ids: map[string]int{}}
+}
+
+// accepts reports whether a state of l in the state s of the normal
+// form is stable and accepts only events of acc: whether it refuses
+// all the events not in acc.
+func (n *normal) accepts(s int, acc []string) bool {
+	for _, u := range n.sets[s] {
+		if !n.l.stable(u) {
+			continue
+		}
+		ok := true
+		for _, e := range n.l.initials(u) {
+			ok = ok && contains(acc, e)
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+// divergent reports whether a state of l in the state s of the normal
+// form diverges.
+func (n *normal) divergent(s int) bool {
+	if n.divergents == nil {
+		n.divergents = n.l.divergent()
+	}
+	for _, u := range n.sets[s] {
+		if n.divergents[u] {
+			return true
+		}
+	}
+	return false
+}
+
+// initial returns the initial state of the normal form, the empty set
+// for an LTS without states.
+func (n *normal) initial() int {
+	if n.l.States() == 0 {
+		return n.state(nil)
+	}
+	return n.state([]int{0})
+}
+
+// state returns the state of the Tau closure of the states of l.
+func (n *normal) state(states []int) int {
+	set := n.l.closure(states)
+	key := fmt.Sprint(set)
+	id, ok := n.ids[key]
+	if !ok {
+		id = len(n.sets)
+		n.ids[key] = id
+		n.sets = append(n.sets, set)
+		n.nexts = append(n.nexts, map[string]int{})
+	}
+	return id
+}
+
+// after returns the state of the normal form after the event e from
+// the state s, -1 if the LTS cannot engage in e.
+func (n *normal) after(s int, e string) int {
+	if next, ok := n.nexts[s][e]; ok {
+		return next
+	}
+	var states []int
+	for _, u := range n.sets[s] {
+		for _, t := range n.l.Trans[u] {
+			if t.Event == e {
+				states = append(states, t.To)
+			}
+		}
+	}
+	next := -1
+	if len(states) > 0 {
+		next = n.state(states)
+	}
+	n.nexts[s][e] = next
+	return next
+}
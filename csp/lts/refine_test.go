@@ -0,0 +1,173 @@
+package lts_test
+
+import (
+	"testing"
+
+	"github.com/changkun/gobase/csp/lts"
+)
+
+func TestRefines(t *testing.T) {
+	// chaos may engage in any event of buffer, forever.
+	chaos := &lts.LTS{Trans: [][]lts.Transition{nil}}
+	for _, e := range buffer.Alphabet() {
+		chaos.Trans[0] = append(chaos.Trans[0], lts.Transition{Event: e, To: 0})
+	}
+	// zeros buffers zeros only, after deciding to.
+	zeros := &lts.LTS{Trans: [][]lts.Transition{
+		{{Event: lts.Tau, To: 1}},
+		{{Event: "in.0", To: 2}},
+		{{Event: "out.0", To: 0}},
+	}}
+	// zerosOrEnd may also terminate, after a first value.
+	zerosOrEnd := &lts.LTS{Trans: [][]lts.Transition{
+		{{Event: "in.0", To: 1}},
+		{{Event: "out.0", To: 2}},
+		{{Event: "in.0", To: 1}, {Event: lts.Tick, To: 3}},
+		nil,
+	}}
+
+	tests := []struct {
+		spec, impl *lts.LTS
+		want       string
+	}{
+		{spec: chaos, impl: buffer},
+		{spec: buffer, impl: buffer},
+		{spec: buffer, impl: zeros},
+		{spec: buffer, impl: zerosOrEnd},
+		{spec: zeros, impl: buffer, want: "after ⟨⟩: in.1"},
+		{spec: zerosOrEnd, impl: zeros},
+		{spec: zeros, impl: zerosOrEnd, want: "after in.0, out.0: ✓"},
+		{spec: &lts.LTS{}, impl: zeros, want: "after ⟨⟩: in.0"},
+	}
+	for i, tt := range tests {
+		got := ""
+		if c := lts.Refines(tt.spec, tt.impl); c != nil {
+			got = c.String()
+		}
+		if got != tt.want {
+			t.Fatalf("%v: %d: expected: %q, got: %q", t.Name(), i, tt.want, got)
+		}
+	}
+}
+
+func TestRefinesFailures(t *testing.T) {
+	// external chooses between in.0 and in.1, internal decides on
+	// either first.
+	external := &lts.LTS{Trans: [][]lts.Transition{
+		{{Event: "in.0", To: 1}, {Event: "in.1", To: 1}},
+		nil,
+	}}
+	internal := &lts.LTS{Trans: [][]lts.Transition{
+		{{Event: lts.Tau, To: 1}, {Event: lts.Tau, To: 2}},
+		{{Event: "in.0", To: 3}},
+		{{Event: "in.1", To: 3}},
+		nil,
+	}}
+	// zeros deadlocks after a value.
+	zeros := &lts.LTS{Trans: [][]lts.Transition{
+		{{Event: "in.0", To: 1}},
+		{{Event: "out.0", To: 2}},
+		nil,
+	}}
+	// spin diverges after a value.
+	spin := &lts.LTS{Trans: [][]lts.Transition{
+		{{Event: "in.0", To: 1}},
+		{{Event: lts.Tau, To: 1}},
+	}}
+
+	tests := []struct {
+		spec, impl *lts.LTS
+		want       string
+	}{
+		{spec: external, impl: external},
+		{spec: internal, impl: external},
+		{spec: external, impl: internal, want: "after ⟨⟩: refuses {in.0}"},
+		{spec: internal, impl: internal},
+		{spec: zeros, impl: internal, want: "after ⟨⟩: refuses {in.0, out.0}"},
+		{spec: lts.DF(buffer.Alphabet()), impl: buffer},
+		{spec: lts.DF(zeros.Alphabet()), impl: zeros, want: "after in.0, out.0: refuses {in.0, out.0, ✓}"},
+		{spec: lts.DF(spin.Alphabet()), impl: spin},
+		// spin has no stable state after a value to refuse anything.
+		{spec: spin, impl: zeros, want: "after in.0: refuses {in.0}"},
+		{spec: zeros, impl: spin},
+	}
+	for i, tt := range tests {
+		got := ""
+		if c := lts.RefinesFailures(tt.spec, tt.impl); c != nil {
+			got = c.String()
+		}
+		if got != tt.want {
+			t.Fatalf("%v: %d: expected: %q, got: %q", t.Name(), i, tt.want, got)
+		}
+	}
+}
+
+func TestRefinesFD(t *testing.T) {
+	// spin diverges after a value, unless it outputs it.
+	spin := &lts.LTS{Trans: [][]lts.Transition{
+		{{Event: "in.0", To: 1}},
+		{{Event: lts.Tau, To: 2}, {Event: "out.0", To: 0}},
+		{{Event: lts.Tau, To: 1}},
+	}}
+	// once is spin without diverging.
+	once := &lts.LTS{Trans: [][]lts.Transition{
+		{{Event: "in.0", To: 1}},
+		{{Event: "out.0", To: 0}},
+	}}
+	// chaos diverges at once, and so allows any behaviour.
+	chaos := &lts.LTS{Trans: [][]lts.Transition{
+		{{Event: lts.Tau, To: 0}},
+	}}
+
+	tests := []struct {
+		spec, impl *lts.LTS
+		want       string
+	}{
+		{spec: once, impl: once},
+		{spec: spin, impl: spin},
+		{spec: spin, impl: once},
+		{spec: once, impl: spin, want: "after in.0: diverges"},
+		{spec: lts.DF(spin.Alphabet()), impl: spin, want: "after in.0: diverges"},
+		{spec: chaos, impl: spin},
+		{spec: chaos, impl: buffer},
+		{spec: once, impl: buffer, want: "after ⟨⟩: refuses {in.0, in.1, out.0, out.1}"},
+	}
+	for i, tt := range tests {
+		got := ""
+		if c := lts.RefinesFD(tt.spec, tt.impl); c != nil {
+			got = c.String()
+		}
+		if got != tt.want {
+			t.Fatalf("%v: %d: expected: %q, got: %q", t.Name(), i, tt.want, got)
+		}
+	}
+	// without divergences, spin refines once in the stable failures
+	// model.
+	if c := lts.RefinesFailures(once, spin); c != nil {
+		t.Fatalf("%v: unexpected counterexample: %v", t.Name(), c)
+	}
+}
+
+func TestRefinesSmallest(t *testing.T) {
+	// spec engages in a and b only.
+	spec := &lts.LTS{Trans: [][]lts.Transition{
+		{{Event: "a", To: 0}, {Event: "b", To: 0}},
+	}}
+	// impl engages in x after b or a, found first by its transitions,
+	// and in c after two steps of its own.
+	impl := &lts.LTS{Trans: [][]lts.Transition{
+		{{Event: "b", To: 1}, {Event: lts.Tau, To: 2}, {Event: "a", To: 3}},
+		{{Event: "x", To: 4}},
+		{{Event: lts.Tau, To: 5}},
+		{{Event: "x", To: 4}},
+		nil,
+		{{Event: "c", To: 4}},
+	}}
+	if c := lts.Refines(spec, impl); c == nil || c.String() != "after ⟨⟩: c" {
+		t.Fatalf("%v: expected: %v, got: %v", t.Name(), "after ⟨⟩: c", c)
+	}
+	impl.Trans[5] = nil
+	if c := lts.Refines(spec, impl); c == nil || c.String() != "after a: x" {
+		t.Fatalf("%v: expected: %v, got: %v", t.Name(), "after a: x", c)
+	}
+}
@@ -0,0 +1,208 @@
+package net
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/golang/protobuf/proto"
+	anypb "github.com/golang/protobuf/ptypes/any"
+)
+
+// Codec encodes the values of a channel, as they travel in its frames.
+// Marshal and Unmarshal are given a pointer to a value of the type of
+// the channel. Peers of a channel must use the same codec.
+type Codec interface {
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+// The codecs of package net.
+var (
+	// Gob encodes values by encoding/gob, the codec of New. Values of
+	// interface types are those of the types registered by Register.
+	Gob Codec = gobCodec{}
+	// JSON encodes values by encoding/json, such as for peers in other
+	// languages. A value of an interface type is encoded as
+	//
+	//   {"type": name, "value": value}
+	//
+	// of the name its type is registered by, by Register.
+	JSON Codec = jsonCodec{}
+	// Proto encodes values by protocol buffers: the type of a channel
+	// is a pointer to a message, or an interface type whose values are
+	// such, encoded as an Any message of the name its type is
+	// registered by, by Register, as its type URL.
+	Proto Codec = protoCodec{}
+)
+
+// the registry of the types of values of interface types.
+var registry = struct {
+	sync.RWMutex
+	types map[string]reflect.Type
+	names map[reflect.Type]string
+}{types: map[string]reflect.Type{}, names: map[reflect.Type]string{}}
+
+// Register records the concrete type of value under name, such that the
+// codecs encode the values of that type sent on channels of interface
+// types, and decode them into values of that type. Peers register the
+// same types under the same names. Register panics if the type or the
+// name was registered differently, as of gob.RegisterName, which it
+// also calls.
+func Register(name string, value any) {
+	t := reflect.TypeOf(value)
+	registry.Lock()
+	defer registry.Unlock()
+	if u, ok := registry.types[name]; ok && u != t {
+		panic(fmt.Sprintf("net: registering duplicate types for %q: %v != %v", name, u, t))
+	}
+	if n, ok := registry.names[t]; ok && n != name {
+		panic(fmt.Sprintf("net: registering duplicate names for %v: %q != %q", t, n, name))
+	}
+	gob.RegisterName(name, value)
+	registry.types[name] = t
+	registry.names[t] = name
+}
+
+// registered returns the name the type t is registered by.
+func registered(t reflect.Type) (string, error) {
+	registry.RLock()
+	defer registry.RUnlock()
+	name, ok := registry.names[t]
+	if !ok {
+		return "", fmt.Errorf("net: type %v not registered", t)
+	}
+	return name, nil
+}
+
+// lookup returns the type registered by the name, which is assignable to
+// the interface type i.
+func lookup(name string, i reflect.Type) (reflect.Type, error) {
+	registry.RLock()
+	defer registry.RUnlock()
+	t, ok := registry.types[name]
+	if !ok {
+		return nil, fmt.Errorf("net: type %q not registered", name)
+	}
+	if !t.AssignableTo(i) {
+		return nil, fmt.Errorf("net: type %v is not a %v", t, i)
+	}
+	return t, nil
+}
+
+type gobCodec struct{}
+
+func (gobCodec) Marshal(v any) ([]byte, error) {
+	var b bytes.Buffer
+	if err := gob.NewEncoder(&b).Encode(v); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+func (gobCodec) Unmarshal(data []byte, v any) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+type jsonCodec struct{}
+
+// envelope is the encoding of a value of an interface type by JSON.
+type envelope struct {
+	Type  string          `json:"type"`
+	Value json.RawMessage `json:"value"`
+}
+
+func (jsonCodec) Marshal(v any) ([]byte, error) {
+	rv := reflect.ValueOf(v).Elem()
+	if rv.Kind() != reflect.Interface || rv.IsNil() {
+		return json.Marshal(v)
+	}
+	name, err := registered(rv.Elem().Type())
+	if err != nil {
+		return nil, err
+	}
+	value, err := json.Marshal(rv.Elem().Interface())
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(envelope{Type: name, Value: value})
+}
+
+func (jsonCodec) Unmarshal(data []byte, v any) error {
+	rv := reflect.ValueOf(v).Elem()
+	if rv.Kind() != reflect.Interface || string(data) == "null" {
+		return json.Unmarshal(data, v)
+	}
+	var env envelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		return err
+	}
+	t, err := lookup(env.Type, rv.Type())
+	if err != nil {
+		return err
+	}
+	value := reflect.New(t)
+	if err := json.Unmarshal(env.Value, value.Interface()); err != nil {
+		return err
+	}
+	rv.Set(value.Elem())
+	return nil
+}
+
+type protoCodec struct{}
+
+func (protoCodec) Marshal(v any) ([]byte, error) {
+	rv := reflect.ValueOf(v).Elem()
+	if rv.Kind() != reflect.Interface {
+		m, ok := rv.Interface().(proto.Message)
+		if !ok {
+			return nil, fmt.Errorf("net: %v is not a protocol buffer message", rv.Type())
+		}
+		return proto.Marshal(m)
+	}
+	m, ok := rv.Interface().(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("net: %T is not a protocol buffer message", rv.Interface())
+	}
+	name, err := registered(rv.Elem().Type())
+	if err != nil {
+		return nil, err
+	}
+	value, err := proto.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	return proto.Marshal(&anypb.Any{TypeUrl: name, Value: value})
+}
+
+func (protoCodec) Unmarshal(data []byte, v any) error {
+	rv := reflect.ValueOf(v).Elem()
+	t := rv.Type()
+	if rv.Kind() == reflect.Interface {
+		var a anypb.Any
+		if err := proto.Unmarshal(data, &a); err != nil {
+			return err
+		}
+		var err error
+		if t, err = lookup(a.TypeUrl, t); err != nil {
+			return err
+		}
+		data = a.Value
+	}
+	if t.Kind() != reflect.Ptr {
+		return fmt.Errorf("net: %v is not a protocol buffer message", t)
+	}
+	value := reflect.New(t.Elem())
+	m, ok := value.Interface().(proto.Message)
+	if !ok {
+		return fmt.Errorf("net: %v is not a protocol buffer message", t)
+	}
+	if err := proto.Unmarshal(data, m); err != nil {
+		return err
+	}
+	rv.Set(value)
+	return nil
+}
@@ -0,0 +1,160 @@
+package net_test
+
+import (
+	"context"
+	gonet "net"
+	"testing"
+
+	"github.com/changkun/gobase/csp/net"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/wrappers"
+)
+
+type card struct {
+	Number int
+	Text   string
+}
+
+// shape is the type of the values of a channel of several types.
+type shape interface{ area() int }
+
+type square struct{ Side int }
+
+func (s square) area() int { return s.Side * s.Side }
+
+type rect struct{ W, H int }
+
+func (r *rect) area() int { return r.W * r.H }
+
+func init() {
+	net.Register("square", square{})
+	net.Register("rect", &rect{})
+	net.Register("google.protobuf.StringValue", &wrappers.StringValue{})
+	net.Register("google.protobuf.Int64Value", &wrappers.Int64Value{})
+}
+
+// roundTrip encodes v by the codec, and decodes it into a value of its
+// type.
+func roundTrip[T any](codec net.Codec, v T) (T, error) {
+	var got T
+	b, err := codec.Marshal(&v)
+	if err != nil {
+		return got, err
+	}
+	err = codec.Unmarshal(b, &got)
+	return got, err
+}
+
+func TestCodecs(t *testing.T) {
+	for _, codec := range []net.Codec{net.Gob, net.JSON} {
+		got, err := roundTrip(codec, card{1, "a"})
+		if err != nil || got != (card{1, "a"}) {
+			t.Fatalf("%v: %T expected: %v, got: %v, %v", t.Name(), codec, card{1, "a"}, got, err)
+		}
+		for _, s := range []shape{square{2}, &rect{2, 3}} {
+			got, err := roundTrip(codec, s)
+			if err != nil || got.area() != s.area() {
+				t.Fatalf("%v: %T expected: %v, got: %v, %v", t.Name(), codec, s, got, err)
+			}
+		}
+	}
+
+	got, err := roundTrip(net.Proto, &wrappers.StringValue{Value: "a"})
+	if err != nil || got.Value != "a" {
+		t.Fatalf("%v: expected: %v, got: %v, %v", t.Name(), "a", got, err)
+	}
+	for _, m := range []proto.Message{&wrappers.StringValue{Value: "a"}, &wrappers.Int64Value{Value: 1}} {
+		got, err := roundTrip(net.Proto, m)
+		if err != nil || !proto.Equal(got, m) {
+			t.Fatalf("%v: expected: %v, got: %v, %v", t.Name(), m, got, err)
+		}
+	}
+}
+
+func TestJSON(t *testing.T) {
+	// the wire format of JSON is that of encoding/json.
+	tests := []struct {
+		v    any
+		want string
+	}{
+		{&card{1, "a"}, `{"Number":1,"Text":"a"}`},
+		{new(shape), `null`},
+		{func() *shape { var s shape = square{2}; return &s }(), `{"type":"square","value":{"Side":2}}`},
+	}
+	for _, tt := range tests {
+		b, err := net.JSON.Marshal(tt.v)
+		if err != nil || string(b) != tt.want {
+			t.Fatalf("%v: expected: %v, got: %s, %v", t.Name(), tt.want, b, err)
+		}
+	}
+}
+
+func TestCodecErrors(t *testing.T) {
+	type triangle struct{ shape }
+	for _, codec := range []net.Codec{net.Gob, net.JSON} {
+		if _, err := roundTrip[shape](codec, triangle{}); err == nil {
+			t.Fatalf("%v: %T expected an error encoding an unregistered type", t.Name(), codec)
+		}
+	}
+	if err := net.JSON.Unmarshal([]byte(`{"type":"triangle","value":{}}`), new(shape)); err == nil {
+		t.Fatalf("%v: expected an error decoding an unregistered type", t.Name())
+	}
+	if err := net.JSON.Unmarshal([]byte(`{"type":"google.protobuf.StringValue","value":{}}`), new(shape)); err == nil {
+		t.Fatalf("%v: expected an error decoding a type of another interface", t.Name())
+	}
+	if _, err := roundTrip(net.Proto, card{}); err == nil {
+		t.Fatalf("%v: expected an error encoding a value which is not a message", t.Name())
+	}
+}
+
+func TestNetChanCodec(t *testing.T) {
+	a, b := gonet.Pipe()
+	x := net.NewCodec[shape](net.NewConn(a), net.JSON)
+	y := net.NewCodec[shape](net.NewConn(b), net.JSON)
+	defer x.Close()
+	defer y.Close()
+	go func() {
+		x.Send() <- square{3}
+		x.Send() <- &rect{2, 5}
+		close(x.Send())
+	}()
+	sum := 0
+	for s := range y.Recv() {
+		sum += s.area()
+	}
+	if sum != 19 {
+		t.Fatalf("%v: expected: %v, got: %v", t.Name(), 19, sum)
+	}
+	if err := y.Err(); err != nil {
+		t.Fatalf("%v: unexpected error: %v", t.Name(), err)
+	}
+}
+
+func TestListenerCodec(t *testing.T) {
+	l, err := net.Listen[*wrappers.StringValue]("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("%v: unexpected error: %v", t.Name(), err)
+	}
+	defer l.Close()
+	l.Codec = net.Proto
+	go func() {
+		X, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer X.Close()
+		X.Send() <- &wrappers.StringValue{Value: "a"}
+		close(X.Send())
+		for range X.Recv() {
+		}
+	}()
+	X, err := net.DialCodec[*wrappers.StringValue](context.Background(), "tcp", l.Addr().String(), net.Proto)
+	if err != nil {
+		t.Fatalf("%v: unexpected error: %v", t.Name(), err)
+	}
+	defer X.Close()
+	close(X.Send())
+	if got := <-X.Recv(); got.GetValue() != "a" {
+		t.Fatalf("%v: expected: %v, got: %v", t.Name(), "a", got)
+	}
+}
@@ -0,0 +1,198 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// source: frame.proto
+
+package grpcnet
+
+import (
+	context "context"
+	fmt "fmt"
+	proto "github.com/golang/protobuf/proto"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+	math "math"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.ProtoPackageIsVersion3 // please upgrade the proto package
+
+// Frame is a frame of a network channel.
+type Frame struct {
+	Data                 []byte   `protobuf:"bytes,1,opt,name=data,proto3" json:"data,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Frame) Reset()         { *m = Frame{} }
+func (m *Frame) String() string { return proto.CompactTextString(m) }
+func (*Frame) ProtoMessage()    {}
+func (*Frame) Descriptor() ([]byte, []int) {
+	return fileDescriptor_5379e2b825e15002, []int{0}
+}
+
+func (m *Frame) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Frame.Unmarshal(m, b)
+}
+func (m *Frame) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Frame.Marshal(b, m, deterministic)
+}
+func (m *Frame) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Frame.Merge(m, src)
+}
+func (m *Frame) XXX_Size() int {
+	return xxx_messageInfo_Frame.Size(m)
+}
+func (m *Frame) XXX_DiscardUnknown() {
+	xxx_messageInfo_Frame.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Frame proto.InternalMessageInfo
+
+func (m *Frame) GetData() []byte {
+	if m != nil {
+		return m.Data
+	}
+	return nil
+}
+
+func init() {
+	proto.RegisterType((*Frame)(nil), "grpcnet.Frame")
+}
+
+func init() { proto.RegisterFile("frame.proto", fileDescriptor_5379e2b825e15002) }
+
+var fileDescriptor_5379e2b825e15002 = []byte{
+	// 111 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xe2, 0xe2, 0x4e, 0x2b, 0x4a, 0xcc,
+	0x4d, 0xd5, 0x2b, 0x28, 0xca, 0x2f, 0xc9, 0x17, 0x62, 0x4f, 0x2f, 0x2a, 0x48, 0xce, 0x4b, 0x2d,
+	0x51, 0x92, 0xe6, 0x62, 0x75, 0x03, 0x89, 0x0b, 0x09, 0x71, 0xb1, 0xa4, 0x24, 0x96, 0x24, 0x4a,
+	0x30, 0x2a, 0x30, 0x6a, 0xf0, 0x04, 0x81, 0xd9, 0x46, 0x56, 0x5c, 0xec, 0xce, 0x19, 0x89, 0x79,
+	0x79, 0xa9, 0x39, 0x42, 0xfa, 0x5c, 0xec, 0xce, 0xf9, 0x79, 0x79, 0xa9, 0xc9, 0x25, 0x42, 0x7c,
+	0x7a, 0x50, 0xcd, 0x7a, 0x60, 0x9d, 0x52, 0x68, 0x7c, 0x25, 0x06, 0x0d, 0x46, 0x03, 0xc6, 0x24,
+	0x36, 0xb0, 0x45, 0xc6, 0x80, 0x01, 0x00, 0xeb, 0xd6, 0xd2, 0x30, 0x77, 0x00, 0x00, 0x00,
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConn
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion4
+
+// ChannelClient is the client API for Channel service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
+type ChannelClient interface {
+	// Connect streams the frames of the client to the server, and those
+	// of the server to the client.
+	Connect(ctx context.Context, opts ...grpc.CallOption) (Channel_ConnectClient, error)
+}
+
+type channelClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewChannelClient(cc *grpc.ClientConn) ChannelClient {
+	return &channelClient{cc}
+}
+
+func (c *channelClient) Connect(ctx context.Context, opts ...grpc.CallOption) (Channel_ConnectClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_Channel_serviceDesc.Streams[0], "/grpcnet.Channel/Connect", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &channelConnectClient{stream}
+	return x, nil
+}
+
+type Channel_ConnectClient interface {
+	Send(*Frame) error
+	Recv() (*Frame, error)
+	grpc.ClientStream
+}
+
+type channelConnectClient struct {
+	grpc.ClientStream
+}
+
+func (x *channelConnectClient) Send(m *Frame) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *channelConnectClient) Recv() (*Frame, error) {
+	m := new(Frame)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// ChannelServer is the server API for Channel service.
+type ChannelServer interface {
+	// Connect streams the frames of the client to the server, and those
+	// of the server to the client.
+	Connect(Channel_ConnectServer) error
+}
+
+// UnimplementedChannelServer can be embedded to have forward compatible implementations.
+type UnimplementedChannelServer struct {
+}
+
+func (*UnimplementedChannelServer) Connect(srv Channel_ConnectServer) error {
+	return status.Errorf(codes.Unimplemented, "method Connect not implemented")
+}
+
+func RegisterChannelServer(s *grpc.Server, srv ChannelServer) {
+	s.RegisterService(&_Channel_serviceDesc, srv)
+}
+
+func _Channel_Connect_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(ChannelServer).Connect(&channelConnectServer{stream})
+}
+
+type Channel_ConnectServer interface {
+	Send(*Frame) error
+	Recv() (*Frame, error)
+	grpc.ServerStream
+}
+
+type channelConnectServer struct {
+	grpc.ServerStream
+}
+
+func (x *channelConnectServer) Send(m *Frame) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *channelConnectServer) Recv() (*Frame, error) {
+	m := new(Frame)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+var _Channel_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "grpcnet.Channel",
+	HandlerType: (*ChannelServer)(nil),
+	Methods:     []grpc.MethodDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Connect",
+			Handler:       _Channel_Connect_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "frame.proto",
+}
@@ -0,0 +1,181 @@
+// Package grpcnet carries network channels over gRPC, as a stream of
+// the frames of frame.proto, such that their traffic traverses the
+// service meshes, authentication and load balancers of gRPC services.
+// A Server is the service of a grpc.Server, which accepts the channels
+// other processes Dial:
+//
+//   s := grpc.NewServer(grpc.Creds(creds))
+//   srv := grpcnet.NewServer[rune]()
+//   grpcnet.RegisterChannelServer(s, srv)
+//   go s.Serve(l)
+//   X, err := srv.Accept(ctx)
+//   go csp.S34_ASSEMBLE(X.Recv(), lineprinter)
+//
+//   // on the machine of cardfile
+//   X, err := grpcnet.Dial[rune](ctx, "printers:7000", grpc.WithTransportCredentials(creds))
+//   go csp.S33_DISASSEMBLE(cardfile, X.Send())
+//
+// The channels are those of package net, with the same semantics.
+package grpcnet
+
+//go:generate protoc --go_out=plugins=grpc:. frame.proto
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/changkun/gobase/csp/net"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ErrServerClosed is the failure of Accept of a closed Server.
+var ErrServerClosed = errors.New("grpcnet: server closed")
+
+// Dial connects to the channel a Server accepts at the target, as of
+// grpc.DialContext, whose options, such as credentials, apply to the
+// connection of the channel. It encodes values by net.Gob.
+func Dial[T any](ctx context.Context, target string, opts ...grpc.DialOption) (*net.NetChan[T], error) {
+	return DialCodec[T](ctx, target, net.Gob, opts...)
+}
+
+// DialCodec connects as Dial, encoding values by the codec.
+func DialCodec[T any](ctx context.Context, target string, codec net.Codec, opts ...grpc.DialOption) (*net.NetChan[T], error) {
+	cc, err := grpc.DialContext(ctx, target, opts...)
+	if err != nil {
+		return nil, err
+	}
+	// the stream lives as long as the channel, not as ctx.
+	stream, err := NewChannelClient(cc).Connect(context.Background())
+	if err != nil {
+		cc.Close()
+		return nil, err
+	}
+	return net.NewCodec[T](&clientConn{stream: stream, cc: cc}, codec), nil
+}
+
+// clientConn is the connection of a channel a client dialled.
+type clientConn struct {
+	stream Channel_ConnectClient
+	cc     *grpc.ClientConn
+	mu     sync.Mutex // of sends
+}
+
+func (c *clientConn) WriteFrame(frame []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stream.Send(&Frame{Data: frame})
+}
+
+func (c *clientConn) ReadFrame() ([]byte, error) {
+	f, err := c.stream.Recv()
+	if err != nil {
+		return nil, err
+	}
+	return f.Data, nil
+}
+
+func (c *clientConn) Close() error {
+	c.mu.Lock()
+	c.stream.CloseSend()
+	c.mu.Unlock()
+	return c.cc.Close()
+}
+
+// Server is a ChannelServer, which accepts the channels of the streams
+// of its clients.
+type Server[T any] struct {
+	// Codec is the codec of the channels accepted, net.Gob if nil. It
+	// is set before s serves.
+	Codec net.Codec
+
+	conns chan *net.NetChan[T]
+	done  chan struct{}
+	once  sync.Once
+}
+
+// NewServer returns a server accepting channels of values of type T.
+func NewServer[T any]() *Server[T] {
+	return &Server[T]{conns: make(chan *net.NetChan[T]), done: make(chan struct{})}
+}
+
+// Connect serves the stream of a client, until its channel is accepted
+// and closed.
+func (s *Server[T]) Connect(stream Channel_ConnectServer) error {
+	conn := &serverConn{stream: stream, closed: make(chan struct{})}
+	codec := s.Codec
+	if codec == nil {
+		codec = net.Gob
+	}
+	c := net.NewCodec[T](conn, codec)
+	select {
+	case s.conns <- c:
+	case <-s.done:
+		c.Close()
+		return status.Error(codes.Unavailable, ErrServerClosed.Error())
+	case <-stream.Context().Done():
+		c.Close()
+		return stream.Context().Err()
+	}
+	select {
+	case <-conn.closed:
+		return nil
+	case <-stream.Context().Done():
+		c.Close()
+		return stream.Context().Err()
+	}
+}
+
+// Accept waits for the next channel a client dialled, and returns it.
+// It fails if ctx is done first, or s is closed.
+func (s *Server[T]) Accept(ctx context.Context) (*net.NetChan[T], error) {
+	select {
+	case c := <-s.conns:
+		return c, nil
+	case <-s.done:
+		return nil, ErrServerClosed
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Close stops accepting channels. The channels accepted stay open.
+func (s *Server[T]) Close() error {
+	s.once.Do(func() { close(s.done) })
+	return nil
+}
+
+// serverConn is the connection of a channel a server accepted, which
+// ends once its Connect returns.
+type serverConn struct {
+	stream Channel_ConnectServer
+	mu     sync.Mutex // of sends
+	closed chan struct{}
+	once   sync.Once
+}
+
+func (c *serverConn) WriteFrame(frame []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	select {
+	case <-c.closed:
+		return net.ErrClosed
+	default:
+	}
+	return c.stream.Send(&Frame{Data: frame})
+}
+
+func (c *serverConn) ReadFrame() ([]byte, error) {
+	f, err := c.stream.Recv()
+	if err != nil {
+		return nil, err
+	}
+	return f.Data, nil
+}
+
+func (c *serverConn) Close() error {
+	c.once.Do(func() { close(c.closed) })
+	return nil
+}
@@ -0,0 +1,66 @@
+package grpcnet_test
+
+import (
+	"context"
+	"errors"
+	gonet "net"
+	"testing"
+
+	"github.com/changkun/gobase/csp/net/grpcnet"
+	"google.golang.org/grpc"
+)
+
+func TestDialServer(t *testing.T) {
+	l, err := gonet.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("%v: unexpected error: %v", t.Name(), err)
+	}
+	s := grpc.NewServer()
+	defer s.Stop()
+	srv := grpcnet.NewServer[int]()
+	grpcnet.RegisterChannelServer(s, srv)
+	go s.Serve(l)
+
+	// the server doubles the values of the client.
+	go func() {
+		c, err := srv.Accept(context.Background())
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		for v := range c.Recv() {
+			c.Send() <- 2 * v
+		}
+		close(c.Send())
+	}()
+
+	c, err := grpcnet.Dial[int](context.Background(), l.Addr().String(), grpc.WithInsecure())
+	if err != nil {
+		t.Fatalf("%v: unexpected error: %v", t.Name(), err)
+	}
+	defer c.Close()
+	go func() {
+		for i := 1; i <= 3; i++ {
+			c.Send() <- i
+		}
+		close(c.Send())
+	}()
+	sum := 0
+	for v := range c.Recv() {
+		sum += v
+	}
+	if sum != 12 {
+		t.Fatalf("%v: expected: %v, got: %v", t.Name(), 12, sum)
+	}
+	if err := c.Err(); err != nil {
+		t.Fatalf("%v: unexpected error: %v", t.Name(), err)
+	}
+}
+
+func TestServerClose(t *testing.T) {
+	srv := grpcnet.NewServer[int]()
+	srv.Close()
+	if _, err := srv.Accept(context.Background()); !errors.Is(err, grpcnet.ErrServerClosed) {
+		t.Fatalf("%v: expected: %v, got: %v", t.Name(), grpcnet.ErrServerClosed, err)
+	}
+}
@@ -0,0 +1,258 @@
+// Package net extends channels of processes across machines: a
+// NetChan presents the remote end of a connection as an ordinary pair
+// of Go channels, such that a DISASSEMBLE process on one machine feeds
+// an ASSEMBLE process on another as it would over a local channel:
+//
+//   // on the machine of lineprinter
+//   l, err := net.Listen[rune]("tcp", ":7000")
+//   X, err := l.Accept()
+//   go csp.S34_ASSEMBLE(X.Recv(), lineprinter)
+//
+//   // on the machine of cardfile
+//   X, err := net.Dial[rune](ctx, "tcp", "printers:7000")
+//   go csp.S33_DISASSEMBLE(cardfile, X.Send())
+//
+// Values sent on the Send half of a channel arrive, in order, on the
+// Recv half of its peer, and closing the Send half closes the Recv half
+// of the peer once all values arrived. A value is acknowledged once the
+// receiving process took it, and the next value is taken from the Send
+// half only then: as for the channels of the paper, a process cannot
+// run ahead of its peer by more than the value in transit.
+//
+// Values are encoded by a Codec, Gob unless chosen otherwise for the
+// channel, such as JSON for peers in other languages. The messages of a
+// channel travel over a Conn, framed, such as over TCP by Dial and
+// Listen.
+package net
+
+import (
+	"errors"
+	"io"
+	"sync"
+)
+
+// ErrClosed is the failure of a channel closed locally, by its Close.
+var ErrClosed = errors.New("net: channel closed")
+
+// Conn is a connection carrying the messages of a channel, each a frame
+// of bytes, reliably and in order. WriteFrame may be called by several
+// goroutines at once.
+type Conn interface {
+	WriteFrame(frame []byte) error
+	ReadFrame() ([]byte, error)
+	Close() error
+}
+
+// the kinds of frames, the first byte of a frame.
+const (
+	frameValue = 'v' // a value, encoded
+	frameAck   = 'a' // the receipt of a value
+	frameClose = 'c' // the end of the values
+)
+
+// NetChan is a channel to a process on another machine, over a
+// connection: its Send half sends values to the peer, and its Recv
+// half receives the values of the peer.
+type NetChan[T any] struct {
+	conn   Conn
+	codec  Codec
+	send   chan T
+	recv   chan T
+	values chan T // of the peer, to deliver
+	acks   chan struct{}
+	done   chan struct{} // closed once c is closed
+	stop   sync.Once     // of done
+	closed sync.Once     // of conn
+	wrote  chan struct{} // closed once write returned
+
+	mu  sync.Mutex
+	err error
+}
+
+// New returns a channel over the connection conn, whose peer is the
+// channel at the other end of conn, encoding values by Gob.
+func New[T any](conn Conn) *NetChan[T] { return NewCodec[T](conn, Gob) }
+
+// NewCodec returns a channel over the connection conn, as New, encoding
+// values by the codec.
+func NewCodec[T any](conn Conn, codec Codec) *NetChan[T] {
+	c := &NetChan[T]{conn: conn, codec: codec, send: make(chan T), recv: make(chan T), values: make(chan T, 1),
+		acks: make(chan struct{}, 1), done: make(chan struct{}), wrote: make(chan struct{})}
+	go c.write()
+	go c.read()
+	go c.deliver()
+	return c
+}
+
+// Send returns the sending half of c. Closing it tells the peer that
+// no more values follow.
+func (c *NetChan[T]) Send() chan<- T { return c.send }
+
+// Recv returns the receiving half of c, which is closed once the peer
+// closed its sending half, or the connection failed.
+func (c *NetChan[T]) Recv() <-chan T { return c.recv }
+
+// Close closes the connection of c, once the peer was told that no
+// more values follow if the Send half was closed. Values in transit
+// are lost, and sends on c block forever.
+func (c *NetChan[T]) Close() error {
+	c.stop.Do(func() { close(c.done) })
+	<-c.wrote
+	var err error
+	c.closed.Do(func() { err = c.conn.Close() })
+	c.mu.Lock()
+	if c.err == nil {
+		c.err = ErrClosed
+	}
+	c.mu.Unlock()
+	return err
+}
+
+// Err returns the failure of c, nil if none: of the connection, of a
+// value which cannot be encoded or decoded, or ErrClosed.
+func (c *NetChan[T]) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+// fail records the first failure of c, and closes it.
+func (c *NetChan[T]) fail(err error) {
+	c.mu.Lock()
+	if c.err == nil {
+		c.err = err
+	}
+	c.mu.Unlock()
+	c.stop.Do(func() { close(c.done) })
+	c.closed.Do(func() { c.conn.Close() })
+}
+
+// write sends the values of the Send half, each once the previous one
+// was acknowledged, and tells the peer once it is closed, even if c is
+// closed too.
+func (c *NetChan[T]) write() {
+	defer close(c.wrote)
+	for {
+		var v T
+		var ok bool
+		select {
+		case v, ok = <-c.send:
+		case <-c.done:
+			c.flush()
+			return
+		}
+		if !ok {
+			if err := c.conn.WriteFrame([]byte{frameClose}); err != nil {
+				c.fail(err)
+			}
+			return
+		}
+		b, err := c.codec.Marshal(&v)
+		if err != nil {
+			c.fail(err)
+			return
+		}
+		if err := c.conn.WriteFrame(append([]byte{frameValue}, b...)); err != nil {
+			c.fail(err)
+			return
+		}
+		select {
+		case <-c.acks:
+		case <-c.done:
+			c.flush()
+			return
+		}
+	}
+}
+
+// flush tells the peer that no more values follow if the Send half is
+// closed, once c is.
+func (c *NetChan[T]) flush() {
+	select {
+	case _, ok := <-c.send:
+		if !ok {
+			c.conn.WriteFrame([]byte{frameClose})
+		}
+	default:
+	}
+}
+
+// read reads the frames of the peer: the values, which deliver delivers,
+// and the acknowledgements of its values, until the connection ends,
+// which it may once the peer closed its sending half.
+func (c *NetChan[T]) read() {
+	open := true
+	defer func() {
+		if open {
+			close(c.values)
+		}
+	}()
+	for {
+		frame, err := c.conn.ReadFrame()
+		if err != nil {
+			select {
+			case <-c.done:
+			default:
+				if err == io.EOF && open {
+					err = io.ErrUnexpectedEOF
+				}
+				if err != io.EOF {
+					c.fail(err)
+				}
+			}
+			return
+		}
+		if len(frame) == 0 {
+			c.fail(errors.New("net: empty frame"))
+			return
+		}
+		switch frame[0] {
+		case frameAck:
+			select {
+			case c.acks <- struct{}{}:
+			default:
+				c.fail(errors.New("net: unexpected acknowledgement"))
+				return
+			}
+		case frameClose:
+			if open {
+				open = false
+				close(c.values)
+			}
+		case frameValue:
+			var v T
+			if err := c.codec.Unmarshal(frame[1:], &v); err != nil {
+				c.fail(err)
+				return
+			}
+			// the peer sends a value once the previous one was
+			// acknowledged.
+			select {
+			case c.values <- v:
+			default:
+				c.fail(errors.New("net: unexpected value"))
+				return
+			}
+		default:
+			c.fail(errors.New("net: unknown frame"))
+			return
+		}
+	}
+}
+
+// deliver delivers the values of the peer to the Recv half, and
+// acknowledges them once taken.
+func (c *NetChan[T]) deliver() {
+	defer close(c.recv)
+	for v := range c.values {
+		select {
+		case c.recv <- v:
+		case <-c.done:
+			return
+		}
+		if err := c.conn.WriteFrame([]byte{frameAck}); err != nil {
+			c.fail(err)
+			return
+		}
+	}
+}
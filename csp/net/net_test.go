@@ -0,0 +1,90 @@
+package net_test
+
+import (
+	"errors"
+	"io"
+	gonet "net"
+	"testing"
+	"time"
+
+	"github.com/changkun/gobase/csp/net"
+)
+
+// pair returns two channels, each the peer of the other.
+func pair[T any]() (*net.NetChan[T], *net.NetChan[T]) {
+	a, b := gonet.Pipe()
+	return net.New[T](net.NewConn(a)), net.New[T](net.NewConn(b))
+}
+
+func TestNetChan(t *testing.T) {
+	a, b := pair[[]string]()
+	defer a.Close()
+	defer b.Close()
+	go func() {
+		a.Send() <- []string{"a"}
+		a.Send() <- []string{"b", "c"}
+		close(a.Send())
+	}()
+	go func() {
+		b.Send() <- []string{"d"}
+		close(b.Send())
+	}()
+	var got []string
+	for v := range b.Recv() {
+		got = append(got, v...)
+	}
+	for v := range a.Recv() {
+		got = append(got, v...)
+	}
+	if want := "[a b c d]"; fmtSlice(got) != want {
+		t.Fatalf("%v: expected: %v, got: %v", t.Name(), want, fmtSlice(got))
+	}
+	if err := b.Err(); err != nil {
+		t.Fatalf("%v: unexpected error: %v", t.Name(), err)
+	}
+}
+
+func TestNetChanSync(t *testing.T) {
+	// the second value is taken only once the first was received.
+	a, b := pair[int]()
+	defer a.Close()
+	defer b.Close()
+	a.Send() <- 1
+	select {
+	case a.Send() <- 2:
+		t.Fatalf("%v: expected the second send to block", t.Name())
+	case <-time.After(20 * time.Millisecond):
+	}
+	if got := <-b.Recv(); got != 1 {
+		t.Fatalf("%v: expected: %v, got: %v", t.Name(), 1, got)
+	}
+	a.Send() <- 2
+	if got := <-b.Recv(); got != 2 {
+		t.Fatalf("%v: expected: %v, got: %v", t.Name(), 2, got)
+	}
+}
+
+func TestNetChanErrors(t *testing.T) {
+	a, b := pair[int]()
+	a.Close()
+	if _, ok := <-b.Recv(); ok {
+		t.Fatalf("%v: expected the receiving half closed", t.Name())
+	}
+	if err := b.Err(); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("%v: expected: %v, got: %v", t.Name(), io.ErrUnexpectedEOF, err)
+	}
+	if err := a.Err(); !errors.Is(err, net.ErrClosed) {
+		t.Fatalf("%v: expected: %v, got: %v", t.Name(), net.ErrClosed, err)
+	}
+}
+
+func fmtSlice(s []string) string {
+	out := "["
+	for i, v := range s {
+		if i > 0 {
+			out += " "
+		}
+		out += v
+	}
+	return out + "]"
+}
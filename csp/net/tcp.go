@@ -0,0 +1,113 @@
+package net
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	gonet "net"
+	"sync"
+)
+
+// MaxFrame is the size of the largest frame NewConn reads.
+const MaxFrame = 16 << 20
+
+// stream is a Conn over a stream of bytes, which prefixes every frame
+// by its length.
+type stream struct {
+	rwc io.ReadWriteCloser
+	r   *bufio.Reader
+	mu  sync.Mutex // of writes
+}
+
+// NewConn returns a Conn over the stream of bytes rwc, such as a TCP
+// connection, whose frames are prefixed by their length.
+func NewConn(rwc io.ReadWriteCloser) Conn {
+	return &stream{rwc: rwc, r: bufio.NewReader(rwc)}
+}
+
+func (s *stream) WriteFrame(frame []byte) error {
+	b := make([]byte, 4+len(frame))
+	binary.BigEndian.PutUint32(b, uint32(len(frame)))
+	copy(b[4:], frame)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := s.rwc.Write(b)
+	return err
+}
+
+func (s *stream) ReadFrame() ([]byte, error) {
+	var n [4]byte
+	if _, err := io.ReadFull(s.r, n[:]); err != nil {
+		return nil, err
+	}
+	size := binary.BigEndian.Uint32(n[:])
+	if size > MaxFrame {
+		return nil, fmt.Errorf("net: frame of %d bytes", size)
+	}
+	frame := make([]byte, size)
+	if _, err := io.ReadFull(s.r, frame); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return frame, nil
+}
+
+func (s *stream) Close() error { return s.rwc.Close() }
+
+// Dial connects to the channel a Listener accepts at the address on
+// the named network, such as tcp, as of net.Dial, encoding values by
+// Gob.
+func Dial[T any](ctx context.Context, network, address string) (*NetChan[T], error) {
+	return DialCodec[T](ctx, network, address, Gob)
+}
+
+// DialCodec connects as Dial, encoding values by the codec.
+func DialCodec[T any](ctx context.Context, network, address string, codec Codec) (*NetChan[T], error) {
+	var d gonet.Dialer
+	c, err := d.DialContext(ctx, network, address)
+	if err != nil {
+		return nil, err
+	}
+	return NewCodec[T](NewConn(c), codec), nil
+}
+
+// Listener accepts the channels other processes Dial.
+type Listener[T any] struct {
+	// Codec is the codec of the channels accepted, Gob if nil.
+	Codec Codec
+
+	l gonet.Listener
+}
+
+// Listen listens for channels at the address on the named network,
+// such as tcp, as of net.Listen.
+func Listen[T any](network, address string) (*Listener[T], error) {
+	l, err := gonet.Listen(network, address)
+	if err != nil {
+		return nil, err
+	}
+	return &Listener[T]{l: l}, nil
+}
+
+// Accept waits for the next channel dialled, and returns it.
+func (l *Listener[T]) Accept() (*NetChan[T], error) {
+	c, err := l.l.Accept()
+	if err != nil {
+		return nil, err
+	}
+	codec := l.Codec
+	if codec == nil {
+		codec = Gob
+	}
+	return NewCodec[T](NewConn(c), codec), nil
+}
+
+// Addr returns the address l listens at.
+func (l *Listener[T]) Addr() gonet.Addr { return l.l.Addr() }
+
+// Close stops listening. The channels accepted stay open.
+func (l *Listener[T]) Close() error { return l.l.Close() }
@@ -0,0 +1,66 @@
+package net_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/changkun/gobase/csp"
+	"github.com/changkun/gobase/csp/net"
+)
+
+func TestDialListen(t *testing.T) {
+	// DISASSEMBLE feeds ASSEMBLE over TCP.
+	l, err := net.Listen[rune]("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("%v: unexpected error: %v", t.Name(), err)
+	}
+	defer l.Close()
+	lineprinter := make(chan string)
+	go func() {
+		X, err := l.Accept()
+		if err != nil {
+			close(lineprinter)
+			return
+		}
+		defer X.Close()
+		csp.S34_ASSEMBLE(X.Recv(), lineprinter)
+	}()
+
+	X, err := net.Dial[rune](context.Background(), "tcp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("%v: unexpected error: %v", t.Name(), err)
+	}
+	defer X.Close()
+	cardfile := make(chan []rune)
+	go func() {
+		cardfile <- []rune(strings.Repeat("a", 80))
+		cardfile <- []rune(strings.Repeat("b", 80))
+		close(cardfile)
+	}()
+	go csp.S33_DISASSEMBLE(cardfile, X.Send())
+
+	var lines []string
+	for line := range lineprinter {
+		lines = append(lines, line)
+	}
+	want := []string{
+		strings.Repeat("a", 80) + " " + strings.Repeat("b", 44),
+		strings.Repeat("b", 36) + " " + strings.Repeat(" ", 88),
+	}
+	if len(lines) != len(want) || lines[0] != want[0] || lines[1] != want[1] {
+		t.Fatalf("%v: expected: %q, got: %q", t.Name(), want, lines)
+	}
+}
+
+func TestDialError(t *testing.T) {
+	l, err := net.Listen[int]("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("%v: unexpected error: %v", t.Name(), err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+	if _, err := net.Dial[int](context.Background(), "tcp", addr); err == nil {
+		t.Fatalf("%v: expected an error dialling a closed listener", t.Name())
+	}
+}
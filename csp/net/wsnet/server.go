@@ -0,0 +1,94 @@
+package wsnet
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+
+	"github.com/changkun/gobase/csp/net"
+	"golang.org/x/net/websocket"
+)
+
+// ErrServerClosed is the failure of Accept of a closed Server.
+var ErrServerClosed = errors.New("wsnet: server closed")
+
+// Server is an http.Handler, which accepts the channels of the sessions
+// of its clients, of any origin.
+type Server[T any] struct {
+	opts  Options
+	conns chan *net.NetChan[T]
+	done  chan struct{}
+	once  sync.Once
+
+	mu       sync.Mutex
+	sessions map[string]*session
+}
+
+// NewServer returns a server accepting channels of values of type T,
+// whose sessions have the options opts.
+func NewServer[T any](opts Options) *Server[T] {
+	return &Server[T]{opts: opts, conns: make(chan *net.NetChan[T]), done: make(chan struct{}),
+		sessions: map[string]*session{}}
+}
+
+// ServeHTTP serves the connection of a client, until it is lost.
+func (s *Server[T]) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	websocket.Server{Handler: s.serve}.ServeHTTP(w, r)
+}
+
+func (s *Server[T]) serve(ws *websocket.Conn) {
+	q := ws.Request().URL.Query()
+	id := q.Get("session")
+	if id == "" {
+		return
+	}
+	s.mu.Lock()
+	sess := s.sessions[id]
+	if sess == nil && q.Get("resume") == "" {
+		sess = newSession(s.opts)
+		sess.onEnd = func() {
+			s.mu.Lock()
+			delete(s.sessions, id)
+			s.mu.Unlock()
+		}
+		s.sessions[id] = sess
+		s.mu.Unlock()
+		gone := sess.attach(ws)
+		c := net.NewCodec[T](sess, s.opts.codec())
+		select {
+		case s.conns <- c:
+		case <-s.done:
+			c.Close()
+		case <-sess.ctx.Done():
+		}
+		<-gone
+		return
+	}
+	s.mu.Unlock()
+	if sess == nil {
+		// the session ended while its client reconnected.
+		websocket.Message.Send(ws, []byte{msgEnd})
+		return
+	}
+	<-sess.attach(ws)
+}
+
+// Accept waits for the next channel a client dialled, and returns it.
+// It fails if ctx is done first, or s is closed.
+func (s *Server[T]) Accept(ctx context.Context) (*net.NetChan[T], error) {
+	select {
+	case c := <-s.conns:
+		return c, nil
+	case <-s.done:
+		return nil, ErrServerClosed
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Close stops accepting channels. The channels accepted stay open.
+func (s *Server[T]) Close() error {
+	s.once.Do(func() { close(s.done) })
+	return nil
+}
@@ -0,0 +1,53 @@
+package wsnet_test
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/changkun/gobase/csp/net/wsnet"
+)
+
+func TestServerClose(t *testing.T) {
+	srv := wsnet.NewServer[int](wsnet.Options{})
+	srv.Close()
+	if _, err := srv.Accept(context.Background()); !errors.Is(err, wsnet.ErrServerClosed) {
+		t.Fatalf("%v: expected: %v, got: %v", t.Name(), wsnet.ErrServerClosed, err)
+	}
+}
+
+func TestServerEnded(t *testing.T) {
+	srv := wsnet.NewServer[int](wsnet.Options{})
+	defer srv.Close()
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+	url := "ws" + strings.TrimPrefix(ts.URL, "http") + "/"
+
+	// the server closes the channel, which the client sees ended.
+	go func() {
+		c, err := srv.Accept(context.Background())
+		if err != nil {
+			return
+		}
+		c.Send() <- 1
+		close(c.Send())
+		c.Close()
+	}()
+	c, err := wsnet.Dial[int](context.Background(), url, wsnet.Options{})
+	if err != nil {
+		t.Fatalf("%v: unexpected error: %v", t.Name(), err)
+	}
+	defer c.Close()
+	var got []int
+	for v := range c.Recv() {
+		got = append(got, v)
+	}
+	if len(got) != 1 || got[0] != 1 {
+		t.Fatalf("%v: expected: %v, got: %v", t.Name(), []int{1}, got)
+	}
+	if err := c.Err(); err != nil {
+		t.Fatalf("%v: unexpected error: %v", t.Name(), err)
+	}
+}
@@ -0,0 +1,484 @@
+// Package wsnet carries network channels over WebSocket, such that
+// peers in browsers, such as WASM builds of processes, take part in a
+// network of processes. A Server is the http.Handler of the channels
+// other processes Dial:
+//
+//   srv := wsnet.NewServer[rune](wsnet.Options{Keepalive: 10 * time.Second, Resume: time.Minute})
+//   go http.ListenAndServe(":7000", srv)
+//   X, err := srv.Accept(ctx)
+//   go csp.S34_ASSEMBLE(X.Recv(), lineprinter)
+//
+//   // on the machine of cardfile
+//   X, err := wsnet.Dial[rune](ctx, "ws://printers:7000/", wsnet.Options{
+//       Keepalive: 10 * time.Second,
+//       Reconnect: wsnet.Retry(supervise.Exponential(100*time.Millisecond, 10*time.Second), 0),
+//   })
+//   go csp.S33_DISASSEMBLE(cardfile, X.Send())
+//
+// The channels are those of package net, with the same semantics, over
+// a session which outlives its connections: a client whose connection
+// is lost reconnects as its Reconnect policy decides, and the session
+// resumes where it stopped, without losing or repeating a frame.
+//
+// Every message of a session is binary, its first byte its kind:
+//
+//   'f' frame    a frame of the channel, the n-th of the session
+//   'p' n        a ping, of a peer which received n frames
+//   'q' n        the pong answering a ping
+//   'r' n        the resumption of a connection, by a peer which
+//                received n frames, sent first on every connection
+//   'e'          the end of the session
+//
+// where n is a big-endian uint64. A peer answers a resumption by sending
+// the frames from the n-th on again, and may forget the frames its peer
+// received. A client names its session by the query of its URL:
+// session=id, and resume=1 when it reconnects.
+package wsnet
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/changkun/gobase/csp/net"
+	"golang.org/x/net/websocket"
+)
+
+// Options are the options of the sessions of a client or server.
+type Options struct {
+	// Keepalive is the interval of the pings of a connection, none if
+	// zero.
+	Keepalive time.Duration
+	// Timeout is how long a pinged connection may stay silent before it
+	// is lost. If zero, twice Keepalive is used.
+	Timeout time.Duration
+	// Reconnect decides, for a client, whether to make the n-th attempt,
+	// counted from 1, to reconnect a lost connection, and after which
+	// delay; err is the failure of the connection or the previous
+	// attempt. If nil, a lost connection fails the channel.
+	Reconnect func(n int, err error) (time.Duration, bool)
+	// Resume is how long a server waits for a client to resume the
+	// session of a lost connection, before the channel fails.
+	Resume time.Duration
+	// Codec is the codec of the channels, net.Gob if nil.
+	Codec net.Codec
+}
+
+// codec returns the codec of the channels of opts.
+func (opts Options) codec() net.Codec {
+	if opts.Codec == nil {
+		return net.Gob
+	}
+	return opts.Codec
+}
+
+// Retry returns a reconnect policy which waits for backoff(n) before
+// the n-th attempt, such as supervise.Exponential, and gives up after
+// max attempts, 0 means never.
+func Retry(backoff func(n int) time.Duration, max int) func(n int, err error) (time.Duration, bool) {
+	return func(n int, err error) (time.Duration, bool) {
+		if max > 0 && n > max {
+			return 0, false
+		}
+		return backoff(n), true
+	}
+}
+
+// the kinds of messages, the first byte of a message.
+const (
+	msgFrame  = 'f'
+	msgPing   = 'p'
+	msgPong   = 'q'
+	msgResume = 'r'
+	msgEnd    = 'e'
+)
+
+// maxUnacked is the number of frames sent after which a session pings
+// its peer, to forget those received.
+const maxUnacked = 64
+
+// Dial connects to the channel a Server accepts at the URL, such as
+// ws://printers:7000/ or wss://printers/channels, within ctx.
+func Dial[T any](ctx context.Context, url string, opts Options) (*net.NetChan[T], error) {
+	config, err := websocket.NewConfig(url, url)
+	if err != nil {
+		return nil, err
+	}
+	var id [16]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return nil, err
+	}
+	q := config.Location.Query()
+	q.Set("session", hex.EncodeToString(id[:]))
+	config.Location.RawQuery = q.Encode()
+	ws, err := config.DialContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	s := newSession(opts)
+	resume := *config
+	loc := *config.Location
+	q.Set("resume", "1")
+	loc.RawQuery = q.Encode()
+	resume.Location = &loc
+	s.redial = func() (*websocket.Conn, error) { return resume.DialContext(s.ctx) }
+	s.attach(ws)
+	return net.NewCodec[T](s, opts.codec()), nil
+}
+
+// session is the connection of a channel, over the connections of its
+// client in turn. It is a net.Conn.
+type session struct {
+	opts   Options
+	redial func() (*websocket.Conn, error) // of a client, nil for a server
+	onEnd  func()                          // called once s ended
+	frames chan []byte                     // received, to read
+	ended  chan struct{}                   // closed once the peer ended s
+	rmu    sync.Mutex                      // of the delivery of frames
+
+	// ctx is done once s ended, locally or by failure.
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu       sync.Mutex
+	ws       *websocket.Conn // the connection, nil while lost
+	gone     chan struct{}   // closed once ws is lost
+	gen      int             // of ws
+	resuming bool            // until the peer resumed ws, if not the first
+	broken   bool            // once a write to ws failed
+	unacked  [][]byte        // the messages of the frames from acked on
+	acked    uint64          // the frames the peer received
+	sent     uint64          // the frames sent
+	received uint64          // the frames received
+	closed   bool            // by the peer
+	err      error
+}
+
+func newSession(opts Options) *session {
+	s := &session{opts: opts, frames: make(chan []byte, 1), ended: make(chan struct{})}
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	return s
+}
+
+// timeout returns how long a pinged connection may stay silent.
+func (s *session) timeout() time.Duration {
+	if s.opts.Timeout > 0 {
+		return s.opts.Timeout
+	}
+	return 2 * s.opts.Keepalive
+}
+
+// attach makes ws the connection of s, and returns a channel closed once
+// it is lost.
+func (s *session) attach(ws *websocket.Conn) <-chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	gone := make(chan struct{})
+	if s.err != nil || s.closed {
+		ws.Close()
+		close(gone)
+		return gone
+	}
+	if s.ws != nil {
+		// the peer resumed before the previous connection was lost.
+		close(s.gone)
+		s.ws.Close()
+	}
+	// the frames of a connection after the first are sent once the peer
+	// told which it received.
+	s.ws, s.gone, s.resuming, s.broken = ws, gone, s.gen > 0, false
+	s.gen++
+	if s.opts.Keepalive > 0 {
+		ws.SetReadDeadline(time.Now().Add(s.timeout()))
+		go s.keepalive(ws, gone)
+	}
+	go s.read(ws)
+	s.send(ws, count(msgResume, s.received))
+	return gone
+}
+
+// send sends the message over ws, if it is the connection of s. Once a
+// write failed, nothing more is sent over ws: the reader of ws finds
+// whether the connection is lost, or the peer ended s, as it will have
+// sent first. s.mu is held.
+func (s *session) send(ws *websocket.Conn, msg []byte) {
+	if ws == nil || s.ws != ws || s.broken {
+		return
+	}
+	if err := websocket.Message.Send(ws, msg); err != nil {
+		s.broken = true
+	}
+}
+
+// count returns the message of the kind, of the number of frames n.
+func count(kind byte, n uint64) []byte {
+	msg := make([]byte, 9)
+	msg[0] = kind
+	binary.BigEndian.PutUint64(msg[1:], n)
+	return msg
+}
+
+// lost gives up the connection ws of s after its failure err, if it is
+// still the connection of s, and reconnects or waits for the peer to
+// do, or fails s. s.mu is held.
+func (s *session) lost(ws *websocket.Conn, err error) {
+	if s.ws != ws {
+		return
+	}
+	s.ws = nil
+	close(s.gone)
+	ws.Close()
+	if s.err != nil || s.closed {
+		return
+	}
+	switch {
+	case s.redial != nil && s.opts.Reconnect != nil:
+		go s.reconnect(err)
+	case s.redial == nil && s.opts.Resume > 0:
+		gen := s.gen
+		time.AfterFunc(s.opts.Resume, func() {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			if s.ws == nil && s.gen == gen {
+				s.fail(err)
+			}
+		})
+	default:
+		s.fail(err)
+	}
+}
+
+// reconnect reconnects a client after the failure err of its
+// connection, as its Reconnect policy decides.
+func (s *session) reconnect(err error) {
+	for n := 1; ; n++ {
+		d, ok := s.opts.Reconnect(n, err)
+		if !ok {
+			s.mu.Lock()
+			s.fail(err)
+			s.mu.Unlock()
+			return
+		}
+		select {
+		case <-time.After(d):
+		case <-s.ctx.Done():
+			return
+		}
+		var ws *websocket.Conn
+		if ws, err = s.redial(); err == nil {
+			s.attach(ws)
+			return
+		}
+	}
+}
+
+// fail records the failure err of s, and ends it. s.mu is held.
+func (s *session) fail(err error) {
+	if s.err != nil {
+		return
+	}
+	s.err = err
+	if s.ws != nil {
+		close(s.gone)
+		s.ws.Close()
+		s.ws = nil
+	}
+	s.cancel()
+	if s.onEnd != nil {
+		s.onEnd()
+	}
+}
+
+// keepalive pings the peer over ws, until ws is gone.
+func (s *session) keepalive(ws *websocket.Conn, gone <-chan struct{}) {
+	t := time.NewTicker(s.opts.Keepalive)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+		case <-gone:
+			return
+		}
+		s.mu.Lock()
+		s.send(ws, count(msgPing, s.received))
+		s.mu.Unlock()
+	}
+}
+
+// read reads the messages of the peer over ws, until ws is lost.
+func (s *session) read(ws *websocket.Conn) {
+	for {
+		var msg []byte
+		if err := websocket.Message.Receive(ws, &msg); err != nil {
+			s.mu.Lock()
+			s.lost(ws, err)
+			s.mu.Unlock()
+			return
+		}
+		if s.opts.Keepalive > 0 {
+			ws.SetReadDeadline(time.Now().Add(s.timeout()))
+		}
+		if len(msg) == 0 {
+			s.protocol(ws, "empty message")
+			return
+		}
+		if msg[0] == msgFrame {
+			if !s.deliver(ws, msg[1:]) {
+				return
+			}
+			continue
+		}
+		if !s.handle(ws, msg) {
+			return
+		}
+	}
+}
+
+// deliver delivers the frame the peer sent over ws, and reports whether
+// ws is still the connection of s.
+func (s *session) deliver(ws *websocket.Conn, frame []byte) bool {
+	// the frames of a connection are delivered before those of the
+	// next.
+	s.rmu.Lock()
+	defer s.rmu.Unlock()
+	s.mu.Lock()
+	if s.ws != ws {
+		s.mu.Unlock()
+		return false
+	}
+	s.received++
+	s.mu.Unlock()
+	select {
+	case s.frames <- frame:
+		return true
+	case <-s.ctx.Done():
+		return false
+	}
+}
+
+// handle handles the message of the peer over ws, other than a frame,
+// and reports whether ws is still the connection of s.
+func (s *session) handle(ws *websocket.Conn, msg []byte) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ws != ws {
+		return false
+	}
+	switch msg[0] {
+	case msgEnd:
+		s.closed = true
+		close(s.ended)
+		s.lost(ws, io.EOF)
+		return false
+	case msgPing, msgPong, msgResume:
+	default:
+		s.protocolLocked(ws, fmt.Sprintf("unknown message %q", msg[0]))
+		return false
+	}
+	if len(msg) != 9 {
+		s.protocolLocked(ws, fmt.Sprintf("message %q of %d bytes", msg[0], len(msg)))
+		return false
+	}
+	n := binary.BigEndian.Uint64(msg[1:])
+	if n < s.acked || n > s.sent {
+		s.protocolLocked(ws, fmt.Sprintf("%d frames received of %d", n, s.sent))
+		return false
+	}
+	s.unacked = s.unacked[n-s.acked:]
+	s.acked = n
+	switch msg[0] {
+	case msgPing:
+		s.send(ws, count(msgPong, s.received))
+	case msgResume:
+		if !s.resuming {
+			break
+		}
+		// the frames sent while resuming, or lost with the previous
+		// connection, are sent again.
+		s.resuming = false
+		for _, m := range s.unacked {
+			s.send(ws, m)
+		}
+	}
+	return true
+}
+
+// protocol fails s by a violation of the protocol by the peer over ws.
+func (s *session) protocol(ws *websocket.Conn, violation string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.protocolLocked(ws, violation)
+}
+
+func (s *session) protocolLocked(ws *websocket.Conn, violation string) {
+	if s.ws == ws {
+		s.fail(errors.New("wsnet: " + violation))
+	}
+}
+
+// WriteFrame sends the frame over the connection of s, or once it
+// resumed. Frames sent after the peer ended s are dropped.
+func (s *session) WriteFrame(frame []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	if s.closed {
+		return nil
+	}
+	msg := append([]byte{msgFrame}, frame...)
+	s.unacked = append(s.unacked, msg)
+	s.sent++
+	if s.ws == nil || s.resuming {
+		return nil
+	}
+	s.send(s.ws, msg)
+	if len(s.unacked) >= maxUnacked {
+		s.send(s.ws, count(msgPing, s.received))
+	}
+	return nil
+}
+
+// ReadFrame returns the next frame of the peer, or io.EOF once the peer
+// ended s and all its frames were read.
+func (s *session) ReadFrame() ([]byte, error) {
+	select {
+	case f := <-s.frames:
+		return f, nil
+	default:
+	}
+	select {
+	case f := <-s.frames:
+		return f, nil
+	case <-s.ended:
+		select {
+		case f := <-s.frames:
+			return f, nil
+		default:
+			return nil, io.EOF
+		}
+	case <-s.ctx.Done():
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return nil, s.err
+	}
+}
+
+// Close ends s, and tells the peer.
+func (s *session) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err == nil && !s.closed {
+		s.send(s.ws, []byte{msgEnd})
+	}
+	s.fail(net.ErrClosed)
+	return nil
+}
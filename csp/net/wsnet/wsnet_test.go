@@ -0,0 +1,217 @@
+package wsnet_test
+
+import (
+	"context"
+	"io"
+	gonet "net"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/changkun/gobase/csp/net/wsnet"
+)
+
+// proxy forwards the connections to the address, until they are cut.
+type proxy struct {
+	l     gonet.Listener
+	mu    sync.Mutex
+	conns []gonet.Conn
+}
+
+func newProxy(t *testing.T, address string) *proxy {
+	l, err := gonet.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("%v: unexpected error: %v", t.Name(), err)
+	}
+	p := &proxy{l: l}
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			d, err := gonet.Dial("tcp", address)
+			if err != nil {
+				c.Close()
+				continue
+			}
+			p.mu.Lock()
+			p.conns = append(p.conns, c, d)
+			p.mu.Unlock()
+			go io.Copy(c, d)
+			go io.Copy(d, c)
+		}
+	}()
+	return p
+}
+
+func (p *proxy) url() string { return "ws://" + p.l.Addr().String() + "/" }
+
+// cut closes the connections forwarded so far.
+func (p *proxy) cut() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, c := range p.conns {
+		c.Close()
+	}
+	p.conns = nil
+}
+
+func (p *proxy) Close() { p.l.Close(); p.cut() }
+
+// double serves the channels of srv, doubling their values.
+func double(srv *wsnet.Server[int]) {
+	for {
+		c, err := srv.Accept(context.Background())
+		if err != nil {
+			return
+		}
+		go func() {
+			defer c.Close()
+			for v := range c.Recv() {
+				c.Send() <- 2 * v
+			}
+			close(c.Send())
+		}()
+	}
+}
+
+func TestDialServer(t *testing.T) {
+	srv := wsnet.NewServer[int](wsnet.Options{})
+	defer srv.Close()
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+	go double(srv)
+
+	c, err := wsnet.Dial[int](context.Background(), "ws"+strings.TrimPrefix(ts.URL, "http")+"/", wsnet.Options{})
+	if err != nil {
+		t.Fatalf("%v: unexpected error: %v", t.Name(), err)
+	}
+	defer c.Close()
+	go func() {
+		for i := 1; i <= 3; i++ {
+			c.Send() <- i
+		}
+		close(c.Send())
+	}()
+	sum := 0
+	for v := range c.Recv() {
+		sum += v
+	}
+	if sum != 12 {
+		t.Fatalf("%v: expected: %v, got: %v", t.Name(), 12, sum)
+	}
+	if err := c.Err(); err != nil {
+		t.Fatalf("%v: unexpected error: %v", t.Name(), err)
+	}
+}
+
+func TestReconnect(t *testing.T) {
+	srv := wsnet.NewServer[int](wsnet.Options{Resume: 5 * time.Second})
+	defer srv.Close()
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+	go double(srv)
+	p := newProxy(t, ts.Listener.Addr().String())
+	defer p.Close()
+
+	var attempts []int
+	var mu sync.Mutex
+	c, err := wsnet.Dial[int](context.Background(), p.url(), wsnet.Options{
+		Reconnect: func(n int, err error) (time.Duration, bool) {
+			mu.Lock()
+			attempts = append(attempts, n)
+			mu.Unlock()
+			return 10 * time.Millisecond, n <= 100
+		},
+	})
+	if err != nil {
+		t.Fatalf("%v: unexpected error: %v", t.Name(), err)
+	}
+	defer c.Close()
+
+	// every value survives the loss of the connection it was sent over.
+	for i := 1; i <= 5; i++ {
+		c.Send() <- i
+		p.cut()
+		if got := <-c.Recv(); got != 2*i {
+			t.Fatalf("%v: expected: %v, got: %v", t.Name(), 2*i, got)
+		}
+	}
+	close(c.Send())
+	if _, ok := <-c.Recv(); ok {
+		t.Fatalf("%v: expected the channel to be closed", t.Name())
+	}
+	if err := c.Err(); err != nil {
+		t.Fatalf("%v: unexpected error: %v", t.Name(), err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(attempts) == 0 {
+		t.Fatalf("%v: expected reconnections", t.Name())
+	}
+}
+
+func TestKeepalive(t *testing.T) {
+	srv := wsnet.NewServer[int](wsnet.Options{})
+	defer srv.Close()
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	// the server accepts, but never answers pings.
+	l, err := gonet.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("%v: unexpected error: %v", t.Name(), err)
+	}
+	defer l.Close()
+	go func() {
+		c, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		d, err := gonet.Dial("tcp", ts.Listener.Addr().String())
+		if err != nil {
+			return
+		}
+		defer d.Close()
+		// forward the handshake, then nothing.
+		go io.Copy(d, c)
+		buf := make([]byte, 4096)
+		n, _ := d.Read(buf)
+		c.Write(buf[:n])
+		time.Sleep(time.Second)
+	}()
+
+	c, err := wsnet.Dial[int](context.Background(), "ws://"+l.Addr().String()+"/", wsnet.Options{
+		Keepalive: 10 * time.Millisecond,
+		Timeout:   50 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("%v: unexpected error: %v", t.Name(), err)
+	}
+	defer c.Close()
+	select {
+	case <-c.Recv():
+	case <-time.After(500 * time.Millisecond):
+		t.Fatalf("%v: expected the silent connection to be lost", t.Name())
+	}
+	if c.Err() == nil {
+		t.Fatalf("%v: expected an error", t.Name())
+	}
+}
+
+func TestRetry(t *testing.T) {
+	retry := wsnet.Retry(func(n int) time.Duration { return time.Duration(n) }, 2)
+	for n, want := range []bool{true, true, false} {
+		d, ok := retry(n+1, io.EOF)
+		if ok != want {
+			t.Fatalf("%v: attempt %v expected: %v, got: %v", t.Name(), n+1, want, ok)
+		}
+		if ok && d != time.Duration(n+1) {
+			t.Fatalf("%v: attempt %v expected: %v, got: %v", t.Name(), n+1, time.Duration(n+1), d)
+		}
+	}
+}
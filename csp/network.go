@@ -0,0 +1,250 @@
+package csp
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// NetworkController keeps track of a process network: every process
+// started by Go, Par or a pipeline under the context of the controller,
+// and every channel a pipeline allocates, is registered with it. This
+// allows to shut down the whole network from one place.
+type NetworkController struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu    sync.Mutex
+	procs []*Running
+	chans []namedChan
+}
+
+// namedChan is a channel of a network and its name.
+type namedChan struct {
+	name string
+	ch   reflect.Value
+	up   int // the index of the channel upstream of ch in its pipeline, or -1
+	// exited is closed once the process sending to ch returned, nil for
+	// the channels of TrackChan.
+	exited <-chan struct{}
+}
+
+// controllerKey is the context key of the network controller.
+type controllerKey struct{}
+
+// NewNetworkController returns a network controller whose processes
+// run under a context derived from ctx.
+func NewNetworkController(ctx context.Context) *NetworkController {
+	n := &NetworkController{}
+	n.ctx, n.cancel = context.WithCancel(context.WithValue(ctx, controllerKey{}, n))
+	return n
+}
+
+// controller returns the network controller of ctx, if any.
+func controller(ctx context.Context) *NetworkController {
+	n, _ := ctx.Value(controllerKey{}).(*NetworkController)
+	return n
+}
+
+// Go starts p as part of the network, as Go does.
+func (n *NetworkController) Go(p Process) *Running {
+	return Go(n.ctx, p)
+}
+
+// Processes returns all processes of the network that are still
+// running, in the order they were started.
+func (n *NetworkController) Processes() []*Running {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.prune()
+	return append([]*Running(nil), n.procs...)
+}
+
+// Shutdown shuts down the network. It cancels the context of all
+// processes, then drains the channels of the network, so that processes
+// blocked sending to a process that is gone can proceed to terminate,
+// and waits for all processes to terminate. The network is closed down
+// in the order of its dependencies: a channel of a pipeline is closed
+// once the stage sending to it returned, if the stage did not close it
+// itself, such that the stages receiving from it terminate even if the
+// stage ignores its context; and it is drained only once the channel
+// upstream of it is closed, such that a stage is released when the
+// stages it receives from have terminated. The channels registered by
+// TrackChan are drained at once, and never closed by Shutdown.
+//
+// If ctx is done before all processes terminated, Shutdown returns a
+// ShutdownError that reports the processes that failed to exit.
+func (n *NetworkController) Shutdown(ctx context.Context) error {
+	n.cancel()
+
+	done := make(chan struct{})
+	defer close(done)
+	n.mu.Lock()
+	drained := make([]chan struct{}, len(n.chans))
+	for i := range drained {
+		drained[i] = make(chan struct{})
+	}
+	for i, c := range n.chans {
+		var up <-chan struct{}
+		if c.up >= 0 {
+			up = drained[c.up]
+		}
+		go func(ch reflect.Value, up <-chan struct{}, drained chan<- struct{}) {
+			defer close(drained)
+			if up != nil {
+				select {
+				case <-up:
+				case <-done:
+					return
+				}
+			}
+			drainAny(ch, done)
+		}(c.ch, up, drained[i])
+		if c.exited != nil {
+			go func(ch reflect.Value, exited <-chan struct{}) {
+				select {
+				case <-exited:
+					closeAny(ch)
+				case <-done:
+				}
+			}(c.ch, c.exited)
+		}
+	}
+	n.mu.Unlock()
+
+	for {
+		n.mu.Lock()
+		n.prune()
+		procs := append([]*Running(nil), n.procs...)
+		n.mu.Unlock()
+		if len(procs) == 0 {
+			return nil
+		}
+		select {
+		case <-procs[0].Done():
+		case <-ctx.Done():
+			if procs := n.Processes(); len(procs) > 0 {
+				return &ShutdownError{Running: procs}
+			}
+			return nil
+		}
+	}
+}
+
+// track registers a started process.
+func (n *NetworkController) track(r *Running) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.prune()
+	n.procs = append(n.procs, r)
+}
+
+// TrackChan registers the channel ch, named name, with the network. It
+// is listed by Channels, and drained by Shutdown as the channels of
+// pipelines are. TrackChan panics if ch is not a channel.
+func (n *NetworkController) TrackChan(name string, ch interface{}) {
+	v := reflect.ValueOf(ch)
+	if v.Kind() != reflect.Chan {
+		panic(fmt.Sprintf("csp: %T is not a channel", ch))
+	}
+	n.trackChan(name, v, reflect.Value{}, nil)
+}
+
+// trackChan registers a channel of the network, whose upstream channel
+// in its pipeline is up, if any, and whose sender closes exited once it
+// returned.
+func (n *NetworkController) trackChan(name string, ch, up reflect.Value, exited <-chan struct{}) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	c := namedChan{name: name, ch: ch, up: -1, exited: exited}
+	if up.IsValid() {
+		for i := range n.chans {
+			if n.chans[i].ch.Pointer() == up.Pointer() {
+				c.up = i
+			}
+		}
+	}
+	n.chans = append(n.chans, c)
+}
+
+// ChannelInfo describes a channel of a network.
+type ChannelInfo struct {
+	Name string
+	// Len is the number of values buffered in the channel, Cap the
+	// size of its buffer.
+	Len, Cap int
+}
+
+// Channels returns the channels of the network, those allocated by its
+// pipelines, named after the stages they connect such as
+// S33_DISASSEMBLE→S31_COPY, and those registered by TrackChan, in the
+// order they were registered.
+func (n *NetworkController) Channels() []ChannelInfo {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	infos := make([]ChannelInfo, len(n.chans))
+	for i, c := range n.chans {
+		infos[i] = ChannelInfo{Name: c.name, Len: c.ch.Len(), Cap: c.ch.Cap()}
+	}
+	return infos
+}
+
+// prune forgets about terminated processes, n.mu must be held.
+func (n *NetworkController) prune() {
+	procs := n.procs[:0]
+	for _, r := range n.procs {
+		select {
+		case <-r.Done():
+		default:
+			procs = append(procs, r)
+		}
+	}
+	for i := len(procs); i < len(n.procs); i++ {
+		n.procs[i] = nil
+	}
+	n.procs = procs
+}
+
+// drainAny discards values from the channel ch until it is closed or
+// done is closed.
+func drainAny(ch reflect.Value, done <-chan struct{}) {
+	cases := []reflect.SelectCase{
+		{Dir: reflect.SelectRecv, Chan: ch},
+		{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(done)},
+	}
+	for {
+		chosen, _, ok := reflect.Select(cases)
+		if chosen == 1 || !ok {
+			return
+		}
+	}
+}
+
+// closeAny closes the channel ch, whose sender returned, unless the
+// sender closed it already.
+func closeAny(ch reflect.Value) {
+	defer func() {
+		// the channel was closed.
+		recover()
+	}()
+	ch.Close()
+}
+
+// ShutdownError is the error of a shutdown that did not complete in
+// time, it records the processes that failed to exit.
+type ShutdownError struct {
+	Running []*Running
+}
+
+func (e *ShutdownError) Error() string {
+	labels := make([]string, len(e.Running))
+	for i, r := range e.Running {
+		labels[i] = r.Label()
+		if labels[i] == "" {
+			labels[i] = "(unlabelled)"
+		}
+	}
+	return fmt.Sprintf("csp: %d processes failed to exit: %s", len(labels), strings.Join(labels, ", "))
+}
@@ -0,0 +1,190 @@
+package csp_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/changkun/gobase/csp"
+)
+
+func TestNetworkControllerShutdown(t *testing.T) {
+	n := csp.NewNetworkController(context.Background())
+
+	// the sink gives up after three values, which blocks COPY and the
+	// source forever.
+	received := make(chan struct{})
+	p := csp.Pipeline().
+		Source(func(ctx context.Context, out chan<- rune) {
+			defer close(out)
+			for {
+				select {
+				case out <- 'x':
+				case <-ctx.Done():
+					return
+				}
+			}
+		}).
+		Stage(csp.S31_COPY).
+		Sink(func(in <-chan rune) {
+			for i := 0; i < 3; i++ {
+				<-in
+			}
+			close(received)
+		})
+	n.Go(csp.Named("reformat", p))
+	<-received
+
+	if len(n.Processes()) == 0 {
+		t.Fatalf("%v: expected running processes", t.Name())
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := n.Shutdown(ctx); err != nil {
+		t.Fatalf("%v: unexpected error: %v", t.Name(), err)
+	}
+	if procs := n.Processes(); len(procs) != 0 {
+		t.Fatalf("%v: expected no running processes, got: %v", t.Name(), len(procs))
+	}
+}
+
+func TestNetworkControllerStuck(t *testing.T) {
+	n := csp.NewNetworkController(context.Background())
+	release := make(chan struct{})
+	defer close(release)
+
+	n.Go(csp.Par(
+		csp.Named("stuck", csp.ProcessFunc(func(ctx context.Context) error {
+			<-release
+			return nil
+		})),
+		csp.Named("cooperative", csp.STOP),
+	))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err := n.Shutdown(ctx)
+	var serr *csp.ShutdownError
+	if !errors.As(err, &serr) {
+		t.Fatalf("%v: expected shutdown error, got: %v", t.Name(), err)
+	}
+	labels := map[string]bool{}
+	for _, r := range serr.Running {
+		labels[r.Label()] = true
+	}
+	if !labels["stuck"] || labels["cooperative"] {
+		t.Fatalf("%v: expected stuck process only, got: %v", t.Name(), err)
+	}
+}
+
+func TestNetworkControllerChannels(t *testing.T) {
+	n := csp.NewNetworkController(context.Background())
+	jobs := make(chan int, 3)
+	jobs <- 1
+	n.TrackChan("jobs", jobs)
+
+	// the sink never receives, the source fills the buffer.
+	sent := make(chan struct{})
+	p := csp.Pipeline().
+		Source(func(ctx context.Context, out chan<- rune) {
+			defer close(out)
+			out <- 'a'
+			out <- 'b'
+			close(sent)
+			<-ctx.Done()
+		}).Buffer(2).
+		Sink(func(ctx context.Context, in <-chan rune) {
+			<-ctx.Done()
+		})
+	n.Go(p)
+	<-sent
+
+	got := n.Channels()
+	if len(got) != 2 || got[0] != (csp.ChannelInfo{Name: "jobs", Len: 1, Cap: 3}) {
+		t.Fatalf("%v: unexpected channels: %v", t.Name(), got)
+	}
+	if got[1].Len != 2 || got[1].Cap != 2 || !strings.Contains(got[1].Name, "→") {
+		t.Fatalf("%v: unexpected pipeline channel: %v", t.Name(), got[1])
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := n.Shutdown(ctx); err != nil {
+		t.Fatalf("%v: unexpected error: %v", t.Name(), err)
+	}
+}
+
+func TestNetworkControllerShutdownOrder(t *testing.T) {
+	n := csp.NewNetworkController(context.Background())
+	release := make(chan struct{})
+
+	// the source closes its channel only once released, hence COPY
+	// stays blocked on its own, full channel until then.
+	p := csp.Pipeline().
+		Source(func(ctx context.Context, out chan<- rune) {
+			defer close(out)
+			out <- 'a'
+			<-ctx.Done()
+			<-release
+		}).
+		Stage(csp.S31_COPY).Buffer(1).
+		Sink(func(ctx context.Context, in <-chan rune) {
+			<-ctx.Done()
+		})
+	n.Go(p)
+	for got := n.Channels(); len(got) < 2 || got[1].Len == 0; got = n.Channels() {
+		time.Sleep(time.Millisecond)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	errc := make(chan error)
+	go func() { errc <- n.Shutdown(ctx) }()
+	time.Sleep(20 * time.Millisecond)
+	if got := n.Channels(); got[1].Len != 1 {
+		t.Fatalf("%v: expected %v undrained before %v is closed, got: %v", t.Name(), got[1].Name, got[0].Name, got)
+	}
+	close(release)
+	if err := <-errc; err != nil {
+		t.Fatalf("%v: unexpected error: %v", t.Name(), err)
+	}
+}
+
+func TestNetworkControllerShutdownIgnoringContext(t *testing.T) {
+	n := csp.NewNetworkController(context.Background())
+
+	// hang ignores its context and returns without closing its output,
+	// the sink receives until its input is closed.
+	hang := func(in <-chan rune, out chan<- rune) {
+		for c := range in {
+			out <- c
+		}
+	}
+	started := make(chan struct{})
+	p := csp.Pipeline().
+		Source(func(ctx context.Context, out chan<- rune) {
+			defer close(out)
+			for {
+				select {
+				case out <- 'x':
+				case <-ctx.Done():
+					return
+				}
+			}
+		}).
+		Stage(hang).
+		Sink(func(in <-chan rune) {
+			close(started)
+			for range in {
+			}
+		})
+	n.Go(p)
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := n.Shutdown(ctx); err != nil {
+		t.Fatalf("%v: unexpected error: %v", t.Name(), err)
+	}
+}
@@ -0,0 +1,128 @@
+// Package otelcsp instruments process networks with OpenTelemetry
+// tracing. A Chan carries the trace context of its sender along with
+// every value, so that the hops of a message through the stages of a
+// pipeline, such as DISASSEMBLE→SQUASH→ASSEMBLE, are spans of a single
+// trace: the send of a value is a span, the receive of the value a
+// span child of it, and a stage that sends under the context returned
+// by Recv continues the trace of the value it received:
+//
+//   west, east := otelcsp.NewChan[rune](tp, "west→X", 0), otelcsp.NewChan[rune](tp, "X→east", 0)
+//   squash := csp.Named("X", csp.ProcessFunc(func(ctx context.Context) error {
+//       defer east.Close()
+//       for {
+//           ctx, c, ok := west.Recv(ctx)
+//           if !ok {
+//               return nil
+//           }
+//           if err := east.Send(ctx, c); err != nil {
+//               return err
+//           }
+//       }
+//   }))
+//   err := csp.Par(otelcsp.Process(tp, squash), ...).Run(ctx)
+//
+// Process wraps a process in a span of its lifetime.
+package otelcsp
+
+import (
+	"context"
+	"time"
+
+	"github.com/changkun/gobase/csp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentation is the name of the instrumentation scope of the
+// tracers of the package.
+const instrumentation = "github.com/changkun/gobase/csp/otelcsp"
+
+// Chan is a channel whose values carry the trace context of their
+// sender.
+type Chan[T any] struct {
+	tracer trace.Tracer
+	name   string
+	ch     chan message[T]
+}
+
+// message is a value in transit and the span context of its send.
+type message[T any] struct {
+	sc trace.SpanContext
+	v  T
+}
+
+// NewChan returns a channel named name, with a buffer of size values,
+// whose spans are started by tracers of tp.
+func NewChan[T any](tp trace.TracerProvider, name string, size int) *Chan[T] {
+	return &Chan[T]{tracer: tp.Tracer(instrumentation), name: name, ch: make(chan message[T], size)}
+}
+
+// Send sends v in a span "send name" child of the span of ctx, which
+// lasts until v is delivered. It fails with ctx.Err() if ctx is done
+// first.
+func (c *Chan[T]) Send(ctx context.Context, v T) error {
+	ctx, span := c.tracer.Start(ctx, "send "+c.name,
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(attribute.String("csp.channel", c.name)))
+	defer span.End()
+	select {
+	case c.ch <- message[T]{span.SpanContext(), v}:
+		return nil
+	case <-ctx.Done():
+		span.SetStatus(codes.Error, ctx.Err().Error())
+		return ctx.Err()
+	}
+}
+
+// Recv receives a value in a span "receive name" child of the span of
+// its send, which lasts from the call of Recv to the receipt of the
+// value, and returns a context derived from ctx carrying that span. ok
+// is false if the channel is closed or ctx is done.
+func (c *Chan[T]) Recv(ctx context.Context) (_ context.Context, v T, ok bool) {
+	start := time.Now()
+	var m message[T]
+	select {
+	case m, ok = <-c.ch:
+	case <-ctx.Done():
+	}
+	if !ok {
+		return ctx, v, false
+	}
+	ctx, span := c.tracer.Start(trace.ContextWithSpanContext(ctx, m.sc), "receive "+c.name,
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithTimestamp(start),
+		trace.WithAttributes(attribute.String("csp.channel", c.name)))
+	span.End()
+	return ctx, m.v, true
+}
+
+// Close closes the channel.
+func (c *Chan[T]) Close() {
+	close(c.ch)
+}
+
+// Process returns p running in a span of its lifetime, named after the
+// label of p, whose status is the error of p.
+func Process(tp trace.TracerProvider, p csp.Process) csp.Process {
+	label := csp.Label(p)
+	name := label
+	if name == "" {
+		name = "process"
+	}
+	tracer := tp.Tracer(instrumentation)
+	q := csp.ProcessFunc(func(ctx context.Context) error {
+		ctx, span := tracer.Start(ctx, name, trace.WithAttributes(attribute.String("csp.process", label)))
+		defer span.End()
+		err := p.Run(ctx)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		return err
+	})
+	if label == "" {
+		return q
+	}
+	return csp.Named(label, q)
+}
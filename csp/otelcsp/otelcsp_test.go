@@ -0,0 +1,119 @@
+package otelcsp_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/changkun/gobase/csp"
+	"github.com/changkun/gobase/csp/otelcsp"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestChan(t *testing.T) {
+	sr := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+	west := otelcsp.NewChan[rune](tp, "west→X", 0)
+	east := otelcsp.NewChan[rune](tp, "X→east", 0)
+
+	// every value starts a trace of its own in west.
+	var got []rune
+	err := csp.Par(
+		csp.Named("west", csp.ProcessFunc(func(ctx context.Context) error {
+			defer west.Close()
+			for _, c := range "ab" {
+				ctx, span := tp.Tracer("test").Start(ctx, "card")
+				err := west.Send(ctx, c)
+				span.End()
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})),
+		otelcsp.Process(tp, csp.Named("X", csp.ProcessFunc(func(ctx context.Context) error {
+			defer east.Close()
+			for {
+				ctx, c, ok := west.Recv(ctx)
+				if !ok {
+					return nil
+				}
+				if err := east.Send(ctx, c); err != nil {
+					return err
+				}
+			}
+		}))),
+		csp.Named("east", csp.ProcessFunc(func(ctx context.Context) error {
+			for {
+				_, c, ok := east.Recv(ctx)
+				if !ok {
+					return nil
+				}
+				got = append(got, c)
+			}
+		})),
+	).Run(context.Background())
+	if err != nil {
+		t.Fatalf("%v: unexpected error: %v", t.Name(), err)
+	}
+	if string(got) != "ab" {
+		t.Fatalf("%v: expected: %v, got: %v", t.Name(), "ab", string(got))
+	}
+
+	// the hops of a value form a chain of spans in the trace of its
+	// card.
+	spans := sr.Ended()
+	byID := map[string]sdktrace.ReadOnlySpan{}
+	for _, s := range spans {
+		byID[s.SpanContext().SpanID().String()] = s
+	}
+	chains := map[string]bool{}
+	for _, s := range spans {
+		if s.Name() != "receive X→east" {
+			continue
+		}
+		chain := ""
+		for p := s; p != nil; {
+			if p.SpanContext().TraceID() != s.SpanContext().TraceID() {
+				t.Fatalf("%v: span %v is not in the trace of %v", t.Name(), p.Name(), s.Name())
+			}
+			chain = p.Name() + "/" + chain
+			p = byID[p.Parent().SpanID().String()]
+		}
+		chains[chain] = true
+	}
+	want := "card/send west→X/receive west→X/send X→east/receive X→east/"
+	if len(chains) != 1 || !chains[want] {
+		t.Fatalf("%v: expected: %v, got: %v", t.Name(), want, chains)
+	}
+	var traces = map[string]bool{}
+	for _, s := range spans {
+		if s.Name() == "card" {
+			traces[s.SpanContext().TraceID().String()] = true
+		}
+	}
+	if len(traces) != 2 {
+		t.Fatalf("%v: expected: 2 traces, got: %v", t.Name(), len(traces))
+	}
+}
+
+func TestProcess(t *testing.T) {
+	sr := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+	failed := errors.New("failed")
+	p := otelcsp.Process(tp, csp.Named("X", csp.ProcessFunc(func(context.Context) error {
+		return failed
+	})))
+	if csp.Label(p) != "X" {
+		t.Fatalf("%v: expected: %v, got: %v", t.Name(), "X", csp.Label(p))
+	}
+	if err := p.Run(context.Background()); !errors.Is(err, failed) {
+		t.Fatalf("%v: expected: %v, got: %v", t.Name(), failed, err)
+	}
+	spans := sr.Ended()
+	if len(spans) != 1 || spans[0].Name() != "X" || spans[0].Status().Code != codes.Error {
+		t.Fatalf("%v: unexpected spans: %v", t.Name(), spans)
+	}
+}
@@ -0,0 +1,114 @@
+package csp
+
+import "context"
+
+// Pipe feeds input through the given stages connected in series, as in
+// [west::DISASSEMBLE||X::COPY||east::ASSEMBLE], and returns everything
+// the last stage outputs. Every stage must close its out channel once
+// its in channel is closed.
+func Pipe(input string, stages ...func(in <-chan rune, out chan<- rune)) string {
+	s, _ := PipeCtx(context.Background(), input, stages...)
+	return s
+}
+
+// PipeCtx is like Pipe, but aborts and returns ctx.Err() if ctx is done
+// before the pipeline completes.
+//
+// On cancellation the pipeline is torn down in the background: the
+// input of every stage is closed and its output is drained until the
+// stage either closes it or returns. Hence a stage that hangs because
+// its neighbours stopped communicating, or because it forgot to close
+// its output, does not leak. A stage blocking on anything other than
+// its own in and out channels cannot be torn down.
+func PipeCtx(ctx context.Context, input string, stages ...func(in <-chan rune, out chan<- rune)) (string, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	src := make(chan rune)
+	go func() {
+		defer close(src)
+		for _, c := range input {
+			select {
+			case src <- c:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var out <-chan rune = src
+	done := make(chan struct{})
+	close(done) // the source never outlives the pipeline
+	for i, stage := range stages {
+		in := out
+		if i > 0 {
+			ch := make(chan rune)
+			go relay(ctx, out, done, ch)
+			in = ch
+		}
+		ch := make(chan rune)
+		out, done = ch, make(chan struct{})
+		go func(stage func(in <-chan rune, out chan<- rune), in <-chan rune, out chan<- rune, done chan struct{}) {
+			stage(in, out)
+			close(done)
+		}(stage, in, ch, done)
+	}
+
+	received := []rune{}
+	for {
+		select {
+		case c, ok := <-out:
+			if !ok {
+				// the output may have been closed by the teardown
+				// of a relay.
+				if err := ctx.Err(); err != nil {
+					return "", err
+				}
+				return string(received), nil
+			}
+			received = append(received, c)
+		case <-ctx.Done():
+			go drain(out, done)
+			return "", ctx.Err()
+		}
+	}
+}
+
+// relay forwards runes from the output of a stage to the input of its
+// successor. Once ctx is done, it closes the successor's input and
+// drains the output of the stage.
+func relay(ctx context.Context, from <-chan rune, done <-chan struct{}, to chan<- rune) {
+	for {
+		select {
+		case c, ok := <-from:
+			if !ok {
+				close(to)
+				return
+			}
+			select {
+			case to <- c:
+				continue
+			case <-ctx.Done():
+			}
+		case <-ctx.Done():
+		}
+		close(to)
+		drain(from, done)
+		return
+	}
+}
+
+// drain discards runes from ch until it is closed or its producer
+// stage has returned, as indicated by done.
+func drain(ch <-chan rune, done <-chan struct{}) {
+	for {
+		select {
+		case _, ok := <-ch:
+			if !ok {
+				return
+			}
+		case <-done:
+			return
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package csp_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/changkun/gobase/csp"
+	"github.com/changkun/gobase/leaktest"
+)
+
+func TestPipe(t *testing.T) {
+	characters := "Hello,* ** *CSP.***"
+	expected := "Hello,* ↑ *CSP.↑*"
+
+	got := csp.Pipe(characters, csp.S31_COPY, csp.S32_SQUASH_EX, csp.S31_COPY)
+	if got != expected {
+		t.Fatalf("%v: expected: %v, got: %v", t.Name(), expected, got)
+	}
+	if got := csp.Pipe(characters); got != characters {
+		t.Fatalf("%v: expected: %v, got: %v", t.Name(), characters, got)
+	}
+}
+
+func TestPipeCtx(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	defer leaktest.CheckContext(ctx, t)()
+
+	// hang forwards its input but never closes its output, hence the
+	// pipeline blocks forever.
+	hang := func(in <-chan rune, out chan<- rune) {
+		for c := range in {
+			out <- c
+		}
+	}
+	tests := [][]func(in <-chan rune, out chan<- rune){
+		{csp.S31_COPY, hang},
+		{hang, csp.S31_COPY},
+		{csp.S31_COPY, hang, csp.S32_SQUASH_EX},
+	}
+	for _, stages := range tests {
+		dctx, dcancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		_, err := csp.PipeCtx(dctx, strings.Repeat("Hello, CSP.", 100), stages...)
+		dcancel()
+		if err != context.DeadlineExceeded {
+			t.Fatalf("%v: expected: %v, got: %v", t.Name(), context.DeadlineExceeded, err)
+		}
+	}
+
+	// stall forwards its input also to a stalled consumer, it is torn
+	// down once the consumer resumes.
+	sink, release := BlockingSink()
+	stall := func(in <-chan rune, out chan<- rune) {
+		defer close(out)
+		defer close(sink)
+		for c := range in {
+			sink <- c
+			out <- c
+		}
+	}
+	dctx, dcancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer dcancel()
+	_, err := csp.PipeCtx(dctx, "Hello, CSP.", csp.S31_COPY, stall, csp.S31_COPY)
+	release()
+	if err != context.DeadlineExceeded {
+		t.Fatalf("%v: expected: %v, got: %v", t.Name(), context.DeadlineExceeded, err)
+	}
+}
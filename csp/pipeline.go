@@ -0,0 +1,305 @@
+package csp
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"runtime"
+	"strings"
+
+	"github.com/changkun/gobase/csp/trace"
+)
+
+// PipelineBuilder builds a pipeline of processes connected in series,
+// such as [west::DISASSEMBLE||X::COPY||east::ASSEMBLE]. It allocates
+// the channels between neighbouring processes and checks that their
+// element types match. A pipeline is created by Pipeline and completed
+// by Sink:
+//
+//   p := csp.Pipeline().Source(cardfile).
+//       Stage(csp.S33_DISASSEMBLE).Stage(csp.S31_COPY).Buffer(80).Stage(csp.S34_ASSEMBLE).
+//       Sink(lineprinter)
+//   err := p.Run(ctx)
+//
+// Type mismatches are programming errors and are rejected with a
+// panic when the offending element is added.
+type PipelineBuilder struct {
+	src   reflect.Value
+	elems []pipelineElem
+	elem  reflect.Type
+}
+
+// pipelineElem is a source, stage or sink function of a pipeline.
+type pipelineElem struct {
+	label  string
+	fn     reflect.Value
+	in     bool // fn takes an input channel
+	out    bool // fn takes an output channel
+	ctx    bool // fn takes a context as first argument
+	err    bool // fn returns an error
+	buffer int  // buffer size of the output channel
+}
+
+// Pipeline returns an empty pipeline builder.
+func Pipeline() *PipelineBuilder {
+	return &PipelineBuilder{}
+}
+
+// Source sets the source of the pipeline. src is either a channel the
+// first stage receives from, or a function
+//
+//   func(out chan<- T)
+//   func(ctx context.Context, out chan<- T) [error]
+//
+// that sends the input of the pipeline to out and closes it.
+func (b *PipelineBuilder) Source(src interface{}) *PipelineBuilder {
+	if b.elem != nil {
+		panic("csp: pipeline source already set")
+	}
+	v := reflect.ValueOf(src)
+	if v.Kind() == reflect.Chan {
+		if v.Type().ChanDir()&reflect.RecvDir == 0 {
+			panic(fmt.Sprintf("csp: pipeline source %T is not a receive channel", src))
+		}
+		b.src, b.elem = v, v.Type().Elem()
+		return b
+	}
+	e, _, out := b.parse(src, false, true)
+	b.elems = append(b.elems, e)
+	b.elem = out
+	return b
+}
+
+// Stage appends a stage to the pipeline. A stage is a function
+//
+//   func(in <-chan A, out chan<- B)
+//   func(ctx context.Context, in <-chan A, out chan<- B) [error]
+//
+// that receives from in, where A is the element type output by the
+// previous stage, and closes out once in is closed. The stage is
+// labelled by the name of the function.
+func (b *PipelineBuilder) Stage(stage interface{}) *PipelineBuilder {
+	e, in, out := b.parse(stage, true, true)
+	b.check(e, in)
+	b.elems = append(b.elems, e)
+	b.elem = out
+	return b
+}
+
+// Buffer sets the buffer size of the channel the last source or stage
+// function sends to. By default channels are unbuffered.
+func (b *PipelineBuilder) Buffer(size int) *PipelineBuilder {
+	if len(b.elems) == 0 {
+		panic("csp: no pipeline stage to buffer")
+	}
+	if size < 0 {
+		panic("csp: negative buffer size")
+	}
+	b.elems[len(b.elems)-1].buffer = size
+	return b
+}
+
+// Sink completes the pipeline and returns it as a process. sink is
+// either a channel the last stage sends to, or a function
+//
+//   func(in <-chan T)
+//   func(ctx context.Context, in <-chan T) [error]
+//
+// that receives the output of the pipeline until in is closed.
+//
+// The process terminates once all its stages have terminated and fails
+// as Par does. Running it again runs the pipeline on fresh channels.
+// Under the context of a NetworkController, the channels are
+// registered with the controller. Under the context of a recorder of
+// package trace, the communications between stages are recorded on
+// channels named after them, such as S33_DISASSEMBLE→S31_COPY, by taps
+// which relay every channel to the next stage, as trace.Tap does.
+// Under the context of a trace.Replayer, the taps replay its trace.
+func (b *PipelineBuilder) Sink(sink interface{}) Process {
+	elems := append([]pipelineElem(nil), b.elems...)
+	src := b.src
+
+	v := reflect.ValueOf(sink)
+	if v.Kind() != reflect.Chan {
+		e, in, _ := b.parse(sink, true, false)
+		b.check(e, in)
+		elems = append(elems, e)
+		v = reflect.Value{}
+	} else {
+		if v.Type().ChanDir()&reflect.SendDir == 0 {
+			panic(fmt.Sprintf("csp: pipeline sink %T is not a send channel", sink))
+		}
+		if len(elems) == 0 || !elems[len(elems)-1].out {
+			panic("csp: no pipeline stage sends to the sink")
+		}
+		if v.Type().Elem() != b.elem {
+			panic(fmt.Sprintf("csp: pipeline sink %T does not accept %v", sink, b.elem))
+		}
+	}
+
+	return pipeline{src: src, sink: v, elems: elems}
+}
+
+// pipeline is a pipeline completed by Sink.
+type pipeline struct {
+	src, sink reflect.Value // the channels the pipeline receives from and sends to, if any
+	elems     []pipelineElem
+}
+
+// Run runs the stages of the pipeline on fresh channels.
+func (p pipeline) Run(ctx context.Context) error {
+	procs := make([]Process, len(p.elems))
+	rec, rp := trace.FromContext(ctx), trace.ReplayerFromContext(ctx)
+	in := p.src
+	for i, e := range p.elems {
+		var out reflect.Value
+		var exited chan struct{}
+		if e.out {
+			out = p.sink
+			if i < len(p.elems)-1 {
+				out, exited = e.makeChan(ctx, e.label+"→"+p.elems[i+1].label, in)
+			}
+		}
+		procs[i] = Named(e.label, exiting(e.process(in, out), exited))
+		in = out
+		if (rec != nil || rp != nil) && i < len(p.elems)-1 {
+			// relay the channel to the next stage by a tap.
+			in, exited = e.makeChan(ctx, e.label+"→"+p.elems[i+1].label, out)
+			procs = append(procs, exiting(tap(rec, e.label, p.elems[i+1].label, out, in), exited))
+		}
+	}
+	return Par(procs...).Run(ctx)
+}
+
+// makeChan returns a new channel for the output of e, registered as
+// name with the network controller of ctx, if any, as downstream of the
+// channel up. The process sending to the channel closes exited once it
+// returned, exited is nil without a controller.
+func (e pipelineElem) makeChan(ctx context.Context, name string, up reflect.Value) (ch reflect.Value, exited chan struct{}) {
+	ch = reflect.MakeChan(reflect.ChanOf(reflect.BothDir, e.outType()), e.buffer)
+	if n := controller(ctx); n != nil {
+		exited = make(chan struct{})
+		n.trackChan(name, ch, up, exited)
+	}
+	return ch, exited
+}
+
+// exiting returns p, which closes exited once it returned, if exited is
+// not nil.
+func exiting(p Process, exited chan<- struct{}) Process {
+	if exited == nil {
+		return p
+	}
+	return ProcessFunc(func(ctx context.Context) error {
+		defer close(exited)
+		return p.Run(ctx)
+	})
+}
+
+// outType returns the element type of the output channel of e.
+func (e pipelineElem) outType() reflect.Type {
+	return e.fn.Type().In(e.arg(e.in)).Elem()
+}
+
+// tap returns the process relaying the channel from the stage src to
+// the stage dst, as by trace.TapAny.
+func tap(r *trace.Recorder, src, dst string, in, out reflect.Value) Process {
+	return ProcessFunc(func(ctx context.Context) error {
+		trace.TapAny(ctx, r, src+"→"+dst, src, dst, in, out)
+		return nil
+	})
+}
+
+// parse returns the pipeline element of the function fn, which takes an
+// input and/or an output channel as requested, together with their
+// element types.
+func (b *PipelineBuilder) parse(fn interface{}, in, out bool) (e pipelineElem, inType, outType reflect.Type) {
+	v := reflect.ValueOf(fn)
+	if v.Kind() != reflect.Func {
+		panic(fmt.Sprintf("csp: pipeline element %T is not a function", fn))
+	}
+	t := v.Type()
+	e = pipelineElem{label: funcName(v), fn: v, in: in, out: out}
+	e.ctx = t.NumIn() > 0 && t.In(0) == reflect.TypeOf((*context.Context)(nil)).Elem()
+	e.err = t.NumOut() == 1 && t.Out(0) == reflect.TypeOf((*error)(nil)).Elem()
+
+	want := 0
+	for _, ok := range []bool{e.ctx, in, out} {
+		if ok {
+			want++
+		}
+	}
+	if t.NumIn() != want || t.NumOut() > 1 || (t.NumOut() == 1 && !e.err) {
+		panic(fmt.Sprintf("csp: invalid signature of pipeline element %v: %v", e.label, t))
+	}
+	if in {
+		c := t.In(e.arg(false))
+		if c.Kind() != reflect.Chan || c.ChanDir()&reflect.RecvDir == 0 {
+			panic(fmt.Sprintf("csp: input of pipeline element %v is not a receive channel: %v", e.label, c))
+		}
+		inType = c.Elem()
+	}
+	if out {
+		c := t.In(e.arg(in))
+		if c.Kind() != reflect.Chan || c.ChanDir()&reflect.SendDir == 0 {
+			panic(fmt.Sprintf("csp: output of pipeline element %v is not a send channel: %v", e.label, c))
+		}
+		outType = c.Elem()
+	}
+	return e, inType, outType
+}
+
+// check verifies that e can receive the output of the pipeline built so
+// far.
+func (b *PipelineBuilder) check(e pipelineElem, in reflect.Type) {
+	if b.elem == nil {
+		panic(fmt.Sprintf("csp: pipeline element %v has no source", e.label))
+	}
+	if in != b.elem {
+		panic(fmt.Sprintf("csp: pipeline element %v receives %v, but the pipeline sends %v", e.label, in, b.elem))
+	}
+}
+
+// arg returns the index of the input channel argument of e, or of the
+// output channel argument if afterIn reports that e has an input
+// channel preceding it.
+func (e pipelineElem) arg(afterIn bool) int {
+	i := 0
+	if e.ctx {
+		i++
+	}
+	if afterIn {
+		i++
+	}
+	return i
+}
+
+// process returns the process calling the function of e with the given
+// input and output channels.
+func (e pipelineElem) process(in, out reflect.Value) Process {
+	return ProcessFunc(func(ctx context.Context) error {
+		args := make([]reflect.Value, 0, 3)
+		if e.ctx {
+			args = append(args, reflect.ValueOf(ctx))
+		}
+		if e.in {
+			args = append(args, in)
+		}
+		if e.out {
+			args = append(args, out)
+		}
+		res := e.fn.Call(args)
+		if e.err && !res[0].IsNil() {
+			return res[0].Interface().(error)
+		}
+		return nil
+	})
+}
+
+// funcName returns the name of the function fn without its package
+// path, e.g. S31_COPY.
+func funcName(fn reflect.Value) string {
+	name := runtime.FuncForPC(fn.Pointer()).Name()
+	name = name[strings.LastIndex(name, "/")+1:]
+	return name[strings.Index(name, ".")+1:]
+}
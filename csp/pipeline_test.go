@@ -0,0 +1,132 @@
+package csp_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/changkun/gobase/csp"
+	"github.com/changkun/gobase/csp/trace"
+)
+
+func TestPipeline(t *testing.T) {
+	upper := func(in <-chan rune, out chan<- string) {
+		for c := range in {
+			out <- strings.ToUpper(string(c))
+		}
+		close(out)
+	}
+
+	got := []string{}
+	p := csp.Pipeline().
+		Source(func(out chan<- rune) {
+			for _, c := range "a**b" {
+				out <- c
+			}
+			close(out)
+		}).
+		Stage(csp.S32_SQUASH).Buffer(4).
+		Stage(csp.S31_COPYCtx).
+		Stage(upper).
+		Sink(func(in <-chan string) {
+			for s := range in {
+				got = append(got, s)
+			}
+		})
+
+	for i := 0; i < 2; i++ {
+		got = got[:0]
+		if err := p.Run(context.Background()); err != nil {
+			t.Fatalf("%v: unexpected error: %v", t.Name(), err)
+		}
+		if want := []string{"A", "↑", "B"}; !reflect.DeepEqual(want, got) {
+			t.Fatalf("%v: expected: %v, got: %v", t.Name(), want, got)
+		}
+	}
+}
+
+// letters sends the letters of "ab" to out.
+func letters(out chan<- rune) {
+	for _, c := range "ab" {
+		out <- c
+	}
+	close(out)
+}
+
+func TestPipelineTrace(t *testing.T) {
+	out := make(chan rune, 2)
+	p := csp.Pipeline().Source(letters).Stage(csp.S31_COPY).Sink(out)
+	r := trace.NewRecorder()
+	if err := p.Run(trace.NewContext(context.Background(), r)); err != nil {
+		t.Fatalf("%v: unexpected error: %v", t.Name(), err)
+	}
+
+	// the communications of every process are recorded in order, as
+	// the taps relay them, possibly after the process terminated.
+	want := map[string]string{
+		"letters":  "send letters→S31_COPY 'a', send letters→S31_COPY 'b', close letters→S31_COPY",
+		"S31_COPY": "receive letters→S31_COPY 'a', receive letters→S31_COPY 'b'",
+	}
+	got := map[string][]string{}
+	for _, e := range r.Events() {
+		if e.Kind == trace.Spawn || e.Kind == trace.Terminate {
+			continue
+		}
+		s := strings.SplitN(e.String(), " ", 3)[2]
+		got[e.Process] = append(got[e.Process], s)
+	}
+	for label, want := range want {
+		if got := strings.Join(got[label], ", "); got != want {
+			t.Fatalf("%v: %v: expected: %v, got: %v", t.Name(), label, want, got)
+		}
+	}
+	if got := string([]rune{<-out, <-out}); got != "ab" {
+		t.Fatalf("%v: expected: ab, got: %v", t.Name(), got)
+	}
+}
+
+func TestPipelineError(t *testing.T) {
+	errFailed := errors.New("failed")
+	fail := func(ctx context.Context, in <-chan rune, out chan<- rune) error {
+		for range in {
+		}
+		close(out)
+		return errFailed
+	}
+
+	in, out := make(chan rune), make(chan rune)
+	close(in)
+	go func() {
+		for range out {
+		}
+	}()
+	err := csp.Pipeline().Source(in).Stage(fail).Sink(out).Run(context.Background())
+	var perr *csp.ProcessError
+	if !errors.Is(err, errFailed) || !errors.As(err, &perr) || !strings.HasPrefix(perr.Label, "TestPipelineError") {
+		t.Fatalf("%v: expected labelled error, got: %v", t.Name(), err)
+	}
+}
+
+func TestPipelineTypeMismatch(t *testing.T) {
+	tests := []struct {
+		name  string
+		build func()
+	}{
+		{"stage", func() { csp.Pipeline().Source(make(chan int)).Stage(csp.S31_COPY) }},
+		{"sink", func() { csp.Pipeline().Source(make(chan rune)).Stage(csp.S31_COPY).Sink(make(chan string)) }},
+		{"no source", func() { csp.Pipeline().Stage(csp.S31_COPY) }},
+		{"signature", func() { csp.Pipeline().Source(make(chan rune)).Stage(func(in <-chan rune) {}) }},
+	}
+	for _, tt := range tests {
+		func() {
+			defer func() {
+				if r := recover(); r == nil {
+					t.Fatalf("%v: %v: expected panic", t.Name(), tt.name)
+				}
+			}()
+			tt.build()
+		}()
+	}
+}
@@ -0,0 +1,104 @@
+package csp
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrPoisoned is returned by communications on a poisoned channel.
+var ErrPoisoned = errors.New("csp: channel poisoned")
+
+// Poisoner is a channel that can be poisoned.
+type Poisoner interface {
+	Poison()
+}
+
+// Poisonable is a channel that can be poisoned to shut down the
+// process network it is part of. Closing a channel only tells its
+// receiver that no more values follow, hence termination propagates
+// forward only. Poison instead fails every subsequent communication on
+// the channel on both ends, so that its sender learns about it as well
+// as its receiver. A process that encounters ErrPoisoned is expected
+// to poison all of its channels and terminate, which propagates the
+// poison upstream and downstream through the whole network.
+type Poisonable[T any] struct {
+	ch     chan T
+	poison chan struct{}
+	once   sync.Once
+}
+
+// NewPoisonable returns a poisonable channel with the given buffer
+// size.
+func NewPoisonable[T any](size int) *Poisonable[T] {
+	return &Poisonable[T]{ch: make(chan T, size), poison: make(chan struct{})}
+}
+
+// Send sends v on the channel. It fails with ErrPoisoned if the channel
+// is or becomes poisoned before v is received.
+func (p *Poisonable[T]) Send(v T) error {
+	select {
+	case <-p.poison:
+		return ErrPoisoned
+	default:
+	}
+	select {
+	case p.ch <- v:
+		return nil
+	case <-p.poison:
+		return ErrPoisoned
+	}
+}
+
+// Recv receives a value from the channel. It fails with ErrPoisoned if
+// the channel is or becomes poisoned before a value is sent. Values
+// still buffered when the channel is poisoned are discarded.
+func (p *Poisonable[T]) Recv() (T, error) {
+	var zero T
+	select {
+	case <-p.poison:
+		return zero, ErrPoisoned
+	default:
+	}
+	select {
+	case v := <-p.ch:
+		return v, nil
+	case <-p.poison:
+		return zero, ErrPoisoned
+	}
+}
+
+// Poison poisons the channel. Poisoning a poisoned channel has no
+// effect.
+func (p *Poisonable[T]) Poison() {
+	p.once.Do(func() { close(p.poison) })
+}
+
+// Poisoned returns a channel that is closed once the channel is
+// poisoned.
+func (p *Poisonable[T]) Poisoned() <-chan struct{} {
+	return p.poison
+}
+
+// PoisonAll poisons all channels, as a process does after it
+// encountered ErrPoisoned on any of them.
+func PoisonAll(chans ...Poisoner) {
+	for _, ch := range chans {
+		ch.Poison()
+	}
+}
+
+// CopyPoisonable is the COPY process of Section 3.1 on poisonable
+// channels. It copies values from in to out until either channel is
+// poisoned, then poisons both and fails with ErrPoisoned.
+func CopyPoisonable[T any](in, out *Poisonable[T]) error {
+	defer PoisonAll(in, out)
+	for {
+		v, err := in.Recv()
+		if err != nil {
+			return err
+		}
+		if err := out.Send(v); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package csp_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/changkun/gobase/csp"
+	"github.com/changkun/gobase/leaktest"
+)
+
+func TestPoisonable(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	defer leaktest.CheckContext(ctx, t)()
+
+	// source -> X(1) -> X(2) -> X(3) -> sink, X(2) is poisoned from
+	// outside while values are flowing.
+	chans := make([]*csp.Poisonable[int], 4)
+	for i := range chans {
+		chans[i] = csp.NewPoisonable[int](0)
+	}
+
+	errs := make(chan error, 5)
+	go func() {
+		for i := 0; ; i++ {
+			if err := chans[0].Send(i); err != nil {
+				errs <- err
+				return
+			}
+		}
+	}()
+	for i := 0; i < 3; i++ {
+		go func(i int) { errs <- csp.CopyPoisonable(chans[i], chans[i+1]) }(i)
+	}
+	received := make(chan int, 1)
+	go func() {
+		for {
+			v, err := chans[3].Recv()
+			if err != nil {
+				errs <- err
+				return
+			}
+			select {
+			case received <- v:
+			default:
+			}
+		}
+	}()
+
+	<-received
+	chans[2].Poison()
+	for i := 0; i < 5; i++ {
+		if err := <-errs; !errors.Is(err, csp.ErrPoisoned) {
+			t.Fatalf("%v: expected: %v, got: %v", t.Name(), csp.ErrPoisoned, err)
+		}
+	}
+	for i, ch := range chans {
+		select {
+		case <-ch.Poisoned():
+		default:
+			t.Fatalf("%v: expected channel %v to be poisoned", t.Name(), i)
+		}
+	}
+}
+
+func TestPoisonableBuffered(t *testing.T) {
+	ch := csp.NewPoisonable[string](1)
+	if err := ch.Send("csp"); err != nil {
+		t.Fatalf("%v: unexpected error: %v", t.Name(), err)
+	}
+	ch.Poison()
+	ch.Poison()
+	if _, err := ch.Recv(); !errors.Is(err, csp.ErrPoisoned) {
+		t.Fatalf("%v: expected: %v, got: %v", t.Name(), csp.ErrPoisoned, err)
+	}
+	if err := ch.Send("csp"); !errors.Is(err, csp.ErrPoisoned) {
+		t.Fatalf("%v: expected: %v, got: %v", t.Name(), csp.ErrPoisoned, err)
+	}
+}
@@ -0,0 +1,268 @@
+package csp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"strings"
+
+	"github.com/changkun/gobase/csp/trace"
+)
+
+// Process is a sequential process, the <proc> of a parallel command.
+// Run executes the process until it terminates or ctx is done, and
+// reports why it failed, if so.
+type Process interface {
+	Run(ctx context.Context) error
+}
+
+// ProcessFunc adapts an ordinary function to a Process.
+type ProcessFunc func(ctx context.Context) error
+
+// Run calls f(ctx).
+func (f ProcessFunc) Run(ctx context.Context) error {
+	return f(ctx)
+}
+
+// task adapts a process that runs to completion regardless of any
+// context, such as the processes of the paper, to a Process.
+func task(f func()) Process {
+	return ProcessFunc(func(context.Context) error {
+		f()
+		return nil
+	})
+}
+
+// named is a process with a <proc label>.
+type named struct {
+	label string
+	Process
+}
+
+// Named labels p, as in the parallel command [label::p||...]. The label
+// is used to identify the process in errors and diagnostics.
+func Named(label string, p Process) Process {
+	return named{label, p}
+}
+
+// Label returns the label of p given by Named, or an empty string if p
+// is not labelled.
+func Label(p Process) string {
+	if n, ok := p.(named); ok {
+		return n.label
+	}
+	return ""
+}
+
+// ProcessError is the error of a failed process, it records the label
+// of the process.
+type ProcessError struct {
+	Label string
+	Err   error
+}
+
+func (e *ProcessError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Label, e.Err)
+}
+
+// Unwrap returns the underlying error of the process.
+func (e *ProcessError) Unwrap() error {
+	return e.Err
+}
+
+// labelled wraps a non-nil err of the process labelled by label into a
+// ProcessError, errors of unlabelled processes are returned as is.
+func labelled(label string, err error) error {
+	if err == nil || label == "" {
+		return err
+	}
+	return &ProcessError{Label: label, Err: err}
+}
+
+// Running is a process started by Go.
+type Running struct {
+	label  string
+	cancel context.CancelFunc
+	done   chan struct{}
+	err    error
+}
+
+// Go starts p in a new goroutine and returns immediately. The process
+// runs until it terminates, or until ctx is done or Stop is called. A
+// panic of the process does not crash the program, instead the process
+// fails with a PanicError. If ctx belongs to a NetworkController, the
+// process is registered with it. A labelled process is listed by
+// Processes while it is running, and its start and termination are
+// recorded by the recorder of ctx, if any, as by package trace.
+func Go(ctx context.Context, p Process) *Running {
+	ctx, cancel := context.WithCancel(ctx)
+	r := &Running{label: Label(p), cancel: cancel, done: make(chan struct{})}
+	if n := controller(ctx); n != nil {
+		n.track(r)
+	}
+	go func() {
+		defer close(r.done)
+		defer cancel()
+		register(r)
+		defer unregister(r)
+		rec := trace.FromContext(ctx)
+		if rec != nil && r.label != "" {
+			rec.Record(trace.Event{Kind: trace.Spawn, Process: r.label})
+		}
+		err := run(ctx, p)
+		if rec != nil && r.label != "" {
+			rec.Record(trace.Event{Kind: trace.Terminate, Process: r.label, Err: err})
+		}
+		r.err = labelled(r.label, err)
+	}()
+	return r
+}
+
+// run runs p, turning a panic of p into a PanicError.
+func run(ctx context.Context, p Process) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			err = &PanicError{Value: v, Stack: debug.Stack()}
+		}
+	}()
+	return p.Run(ctx)
+}
+
+// Label returns the label of the running process.
+func (r *Running) Label() string {
+	return r.label
+}
+
+// Done returns a channel that is closed once the process terminates.
+func (r *Running) Done() <-chan struct{} {
+	return r.done
+}
+
+// Wait waits for the process to terminate and returns its error.
+func (r *Running) Wait() error {
+	<-r.done
+	return r.err
+}
+
+// Stop cancels the context of the process and waits for it to
+// terminate.
+func (r *Running) Stop() error {
+	r.cancel()
+	return r.Wait()
+}
+
+// Par returns the parallel command [P||Q||R] of procs: a process that
+// runs all procs concurrently and terminates once all of them have
+// terminated. As every process is started by Go, a panicking process
+// fails with a PanicError. The first process to fail cancels the others,
+// whose cancellation is not a failure of their own. If a single process
+// failed, Par fails with its error, if several failed, with a ParError
+// of all their errors.
+func Par(procs ...Process) Process {
+	return par{procs}
+}
+
+// par is the parallel command of Par.
+type par struct {
+	procs []Process
+}
+
+// Run runs the processes of the parallel command concurrently.
+func (p par) Run(ctx context.Context) error {
+	parent := ctx
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	running := make([]*Running, len(p.procs))
+	done := make(chan *Running, len(p.procs))
+	for i, q := range p.procs {
+		r := Go(ctx, q)
+		running[i] = r
+		go func() {
+			<-r.Done()
+			done <- r
+		}()
+	}
+	var first *Running // the first to fail
+	for range running {
+		if r := <-done; r.Wait() != nil && first == nil {
+			first = r
+			cancel()
+		}
+	}
+	var errs []error
+	for _, r := range running {
+		err := r.Wait()
+		if err == nil || r != first && first != nil && parent.Err() == nil && errors.Is(err, context.Canceled) {
+			continue
+		}
+		errs = append(errs, err)
+	}
+	switch len(errs) {
+	case 0:
+		return nil
+	case 1:
+		return errs[0]
+	}
+	return &ParError{Errs: errs}
+}
+
+// ParError is the error of a parallel command of which several
+// processes failed. It records the errors of all failed processes in
+// order of the processes.
+type ParError struct {
+	Errs []error
+}
+
+func (e *ParError) Error() string {
+	msgs := make([]string, len(e.Errs))
+	for i, err := range e.Errs {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Unwrap returns the errors of all failed processes.
+func (e *ParError) Unwrap() []error {
+	return e.Errs
+}
+
+// PanicError is the error of a process that panicked, it records the
+// value passed to panic and the stack of the panicking goroutine.
+type PanicError struct {
+	Value interface{}
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+// Seq returns the sequential composition P;Q;R of procs: a process
+// that runs procs one after another, and stops at the first failed
+// one.
+func Seq(procs ...Process) Process {
+	return ProcessFunc(func(ctx context.Context) error {
+		for _, p := range procs {
+			if err := p.Run(ctx); err != nil {
+				return labelled(Label(p), err)
+			}
+		}
+		return nil
+	})
+}
+
+// SKIP is the process that does nothing and terminates immediately. It
+// is the unit of sequential composition: Seq(SKIP, P) behaves as P.
+var SKIP Process = ProcessFunc(func(context.Context) error {
+	return nil
+})
+
+// STOP is the process that never engages in any communication and
+// never terminates, i.e. it is deadlocked. As a goroutine cannot be
+// blocked forever without leaking it, STOP blocks until its context is
+// done and then fails with ctx.Err().
+var STOP Process = ProcessFunc(func(ctx context.Context) error {
+	<-ctx.Done()
+	return ctx.Err()
+})
@@ -0,0 +1,244 @@
+package csp_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/changkun/gobase/csp"
+)
+
+func TestParReformat(t *testing.T) {
+	cardfile, lineprinter := make(chan []rune), make(chan string)
+	west, east := make(chan rune), make(chan rune)
+
+	// [west::DISASSEMBLE||X::COPY||east::ASSEMBLE]
+	reformat := csp.Par(
+		csp.Named("west", csp.ProcessFunc(func(ctx context.Context) error {
+			csp.S33_DISASSEMBLECtx(ctx, cardfile, west)
+			return nil
+		})),
+		csp.Named("X", csp.ProcessFunc(func(ctx context.Context) error {
+			csp.S31_COPYCtx(ctx, west, east)
+			return nil
+		})),
+		csp.Named("east", csp.ProcessFunc(func(ctx context.Context) error {
+			csp.S34_ASSEMBLECtx(ctx, east, lineprinter)
+			return nil
+		})),
+	)
+	r := csp.Go(context.Background(), reformat)
+	go func() {
+		cardfile <- []rune("Hello,CSP")
+		close(cardfile)
+	}()
+
+	received := []string{}
+	for l := range lineprinter {
+		received = append(received, l)
+	}
+	if err := r.Wait(); err != nil {
+		t.Fatalf("%v: unexpected error: %v", t.Name(), err)
+	}
+	want := []string{"Hello,CSP                                                                                                                    "}
+	if !reflect.DeepEqual(want, received) {
+		t.Fatalf("%v: expected: %v, got: %v", t.Name(), want, received)
+	}
+}
+
+func TestParError(t *testing.T) {
+	errFailed := errors.New("failed")
+	ok := csp.ProcessFunc(func(ctx context.Context) error { return nil })
+	fail := csp.ProcessFunc(func(ctx context.Context) error { return errFailed })
+
+	err := csp.Go(context.Background(), csp.Par(csp.Named("P", ok), csp.Named("Q", fail))).Wait()
+	if !errors.Is(err, errFailed) {
+		t.Fatalf("%v: expected: %v, got: %v", t.Name(), errFailed, err)
+	}
+	var perr *csp.ProcessError
+	if !errors.As(err, &perr) || perr.Label != "Q" {
+		t.Fatalf("%v: expected error of process Q, got: %v", t.Name(), err)
+	}
+	if got, want := err.Error(), "Q: failed"; got != want {
+		t.Fatalf("%v: expected: %v, got: %v", t.Name(), want, got)
+	}
+}
+
+func TestParErrors(t *testing.T) {
+	errP, errR := errors.New("P failed"), errors.New("R failed")
+	fail := func(err error) csp.Process {
+		return csp.ProcessFunc(func(ctx context.Context) error { return err })
+	}
+
+	err := csp.Go(context.Background(), csp.Par(
+		csp.Named("P", fail(errP)), csp.Named("Q", fail(nil)), csp.Named("R", fail(errR)),
+	)).Wait()
+	var perr *csp.ParError
+	if !errors.As(err, &perr) || len(perr.Errs) != 2 {
+		t.Fatalf("%v: expected errors of P and R, got: %v", t.Name(), err)
+	}
+	if !errors.Is(err, errP) || !errors.Is(err, errR) {
+		t.Fatalf("%v: expected: %v and %v, got: %v", t.Name(), errP, errR, err)
+	}
+	if got, want := err.Error(), "P: P failed; R: R failed"; got != want {
+		t.Fatalf("%v: expected: %v, got: %v", t.Name(), want, got)
+	}
+}
+
+func TestParPanic(t *testing.T) {
+	done := false
+	err := csp.Go(context.Background(), csp.Par(
+		csp.Named("P", csp.ProcessFunc(func(ctx context.Context) error { panic("boom") })),
+		csp.Named("Q", csp.ProcessFunc(func(ctx context.Context) error {
+			done = true
+			return nil
+		})),
+	)).Wait()
+
+	var pe *csp.PanicError
+	if !errors.As(err, &pe) || pe.Value != "boom" {
+		t.Fatalf("%v: expected panic error, got: %v", t.Name(), err)
+	}
+	var perr *csp.ProcessError
+	if !errors.As(err, &perr) || perr.Label != "P" {
+		t.Fatalf("%v: expected error of process P, got: %v", t.Name(), err)
+	}
+	if !done {
+		t.Fatalf("%v: expected Q to run to completion", t.Name())
+	}
+}
+
+func TestParPanicCancels(t *testing.T) {
+	// a panicking process fails Par, although its sibling would never
+	// terminate by itself.
+	err := csp.Par(
+		csp.Named("a", csp.ProcessFunc(func(ctx context.Context) error { panic("boom") })),
+		csp.Named("b", csp.STOP),
+	).Run(context.Background())
+
+	var pe *csp.PanicError
+	if !errors.As(err, &pe) || pe.Value != "boom" {
+		t.Fatalf("%v: expected panic error, got: %v", t.Name(), err)
+	}
+	var perr *csp.ProcessError
+	if !errors.As(err, &perr) || perr.Label != "a" {
+		t.Fatalf("%v: expected error of process a, got: %v", t.Name(), err)
+	}
+}
+
+func TestParFailureCancels(t *testing.T) {
+	errFailed := errors.New("failed")
+	err := csp.Par(
+		csp.Named("P", csp.STOP),
+		csp.Named("Q", csp.ProcessFunc(func(ctx context.Context) error { return errFailed })),
+	).Run(context.Background())
+	if want := "Q: failed"; err == nil || err.Error() != want {
+		t.Fatalf("%v: expected: %v, got: %v", t.Name(), want, err)
+	}
+
+	// a cancellation of Par itself is a failure of every process.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = csp.Par(csp.Named("P", csp.STOP), csp.Named("Q", csp.STOP)).Run(ctx)
+	var perr *csp.ParError
+	if !errors.As(err, &perr) || len(perr.Errs) != 2 {
+		t.Fatalf("%v: expected errors of P and Q, got: %v", t.Name(), err)
+	}
+}
+
+func TestGoPanic(t *testing.T) {
+	// DISASSEMBLE panics as its output has been closed.
+	cardfile, X := make(chan []rune, 1), make(chan rune, 1)
+	cardfile <- []rune("Hello,CSP")
+	close(cardfile)
+	close(X)
+
+	err := csp.Go(context.Background(), csp.Named("west", csp.ProcessFunc(func(ctx context.Context) error {
+		csp.S33_DISASSEMBLE(cardfile, X)
+		return nil
+	}))).Wait()
+
+	var perr *csp.ProcessError
+	if !errors.As(err, &perr) || perr.Label != "west" {
+		t.Fatalf("%v: expected error of process west, got: %v", t.Name(), err)
+	}
+	var pe *csp.PanicError
+	if !errors.As(err, &pe) {
+		t.Fatalf("%v: expected panic error, got: %v", t.Name(), err)
+	}
+	if !strings.Contains(string(pe.Stack), "S33_DISASSEMBLE") {
+		t.Fatalf("%v: expected stack of S33_DISASSEMBLE, got: %s", t.Name(), pe.Stack)
+	}
+}
+
+func TestSeq(t *testing.T) {
+	order := []string{}
+	step := func(label string, err error) csp.Process {
+		return csp.Named(label, csp.ProcessFunc(func(ctx context.Context) error {
+			order = append(order, label)
+			return err
+		}))
+	}
+
+	errFailed := errors.New("failed")
+	err := csp.Go(context.Background(), csp.Seq(step("P", nil), step("Q", errFailed), step("R", nil))).Wait()
+	if !errors.Is(err, errFailed) {
+		t.Fatalf("%v: expected: %v, got: %v", t.Name(), errFailed, err)
+	}
+	if want := []string{"P", "Q"}; !reflect.DeepEqual(want, order) {
+		t.Fatalf("%v: expected: %v, got: %v", t.Name(), want, order)
+	}
+}
+
+func TestSKIP(t *testing.T) {
+	ran := false
+	p := csp.ProcessFunc(func(ctx context.Context) error {
+		ran = true
+		return nil
+	})
+	if err := csp.Seq(csp.SKIP, p, csp.SKIP).Run(context.Background()); err != nil || !ran {
+		t.Fatalf("%v: expected SKIP;P;SKIP to behave as P, got: %v", t.Name(), err)
+	}
+	if err := csp.Par(csp.SKIP, csp.SKIP).Run(context.Background()); err != nil {
+		t.Fatalf("%v: unexpected error: %v", t.Name(), err)
+	}
+}
+
+func TestSTOP(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	ran := false
+	p := csp.ProcessFunc(func(ctx context.Context) error {
+		ran = true
+		return nil
+	})
+	err := csp.Seq(csp.STOP, p).Run(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("%v: expected: %v, got: %v", t.Name(), context.DeadlineExceeded, err)
+	}
+	if ran {
+		t.Fatalf("%v: expected STOP;P never to run P", t.Name())
+	}
+}
+
+func TestRunningStop(t *testing.T) {
+	r := csp.Go(context.Background(), csp.Named("blocked", csp.ProcessFunc(func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})))
+	if r.Label() != "blocked" {
+		t.Fatalf("%v: expected label blocked, got: %v", t.Name(), r.Label())
+	}
+	select {
+	case <-r.Done():
+		t.Fatalf("%v: process terminated before Stop", t.Name())
+	default:
+	}
+	if err := r.Stop(); !errors.Is(err, context.Canceled) {
+		t.Fatalf("%v: expected: %v, got: %v", t.Name(), context.Canceled, err)
+	}
+}
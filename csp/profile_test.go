@@ -0,0 +1,36 @@
+package csp_test
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/changkun/gobase/csp"
+)
+
+func TestProfile(t *testing.T) {
+	base := runtime.NumGoroutine()
+
+	r := csp.Profile(func() {
+		cardfile, lineprinter := make(chan []rune), make(chan string)
+		go csp.S35_Reformat(cardfile, lineprinter)
+		go func() {
+			for i := 0; i < 2000; i++ {
+				cardfile <- []rune("1234567890123456789012345678901234567890123456789012345678901234567890")
+			}
+			close(cardfile)
+		}()
+		for range lineprinter {
+		}
+	})
+
+	// feeder, S35_Reformat (ASSEMBLE), DISASSEMBLE and COPY.
+	if r.PeakGoroutines < base+3 {
+		t.Fatalf("%v: expected peak goroutines at least %v, got %v", t.Name(), base+3, r.PeakGoroutines)
+	}
+	if r.Elapsed <= 0 {
+		t.Fatalf("%v: expected positive elapsed time, got %v", t.Name(), r.Elapsed)
+	}
+	if r.Allocs == 0 || r.AllocBytes == 0 {
+		t.Fatalf("%v: expected allocations, got %v objects, %v bytes", t.Name(), r.Allocs, r.AllocBytes)
+	}
+}
@@ -0,0 +1,176 @@
+// Package promcsp exposes the channels of process networks as
+// Prometheus metrics, for pipelines of package csp that run inside
+// long-lived services. A Chan counts the messages it carries and its
+// closes, gauges the values in its buffer and the senders blocked on
+// it, and observes how long its values wait between their send and
+// their receipt, each labelled by the name of the channel:
+//
+//   m, err := promcsp.NewMetrics(prometheus.DefaultRegisterer)
+//   if err != nil {
+//       return err
+//   }
+//   west := promcsp.NewChan[rune](m, "west→X", 10)
+//   ...
+//   if err := west.Send(ctx, c); err != nil {
+//       return err
+//   }
+//
+// The metrics are:
+//
+//   csp_channel_messages_total          counter    values received
+//   csp_channel_closes_total            counter    closes
+//   csp_channel_occupancy               gauge      values in the buffer
+//   csp_channel_blocked_senders         gauge      senders waiting
+//   csp_channel_queue_latency_seconds   histogram  from send to receipt
+package promcsp
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics are the metrics of the channels of a registerer.
+type Metrics struct {
+	messages  *prometheus.CounterVec
+	closes    *prometheus.CounterVec
+	occupancy *occupancy
+	blocked   *prometheus.GaugeVec
+	latency   *prometheus.HistogramVec
+}
+
+// NewMetrics returns the metrics of channels, registered with reg. It
+// fails if reg already has metrics of the same names.
+func NewMetrics(reg prometheus.Registerer) (*Metrics, error) {
+	labels := []string{"channel"}
+	m := &Metrics{
+		messages: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "csp_channel_messages_total",
+			Help: "Number of values received from the channel.",
+		}, labels),
+		closes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "csp_channel_closes_total",
+			Help: "Number of closes of the channel.",
+		}, labels),
+		occupancy: &occupancy{
+			desc: prometheus.NewDesc("csp_channel_occupancy",
+				"Number of values in the buffer of the channel.", labels, nil),
+			lens: map[string][]func() int{},
+		},
+		blocked: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "csp_channel_blocked_senders",
+			Help: "Number of senders waiting to send to the channel.",
+		}, labels),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "csp_channel_queue_latency_seconds",
+			Help:    "Time from the send of a value to its receipt.",
+			Buckets: prometheus.ExponentialBuckets(1e-6, 10, 8),
+		}, labels),
+	}
+	for _, c := range []prometheus.Collector{m.messages, m.closes, m.occupancy, m.blocked, m.latency} {
+		if err := reg.Register(c); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// occupancy is the gauge of the values in the buffers of the channels,
+// which are counted when the metrics are collected.
+type occupancy struct {
+	desc *prometheus.Desc
+
+	mu   sync.Mutex
+	lens map[string][]func() int // of the channels by name
+}
+
+func (o *occupancy) Describe(ch chan<- *prometheus.Desc) {
+	ch <- o.desc
+}
+
+func (o *occupancy) Collect(ch chan<- prometheus.Metric) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for name, lens := range o.lens {
+		n := 0
+		for _, l := range lens {
+			n += l()
+		}
+		ch <- prometheus.MustNewConstMetric(o.desc, prometheus.GaugeValue, float64(n), name)
+	}
+}
+
+// Chan is a channel whose communications are metrics.
+type Chan[T any] struct {
+	ch       chan message[T]
+	messages prometheus.Counter
+	closes   prometheus.Counter
+	blocked  prometheus.Gauge
+	latency  prometheus.Observer
+}
+
+// message is a value in transit and the time of its send.
+type message[T any] struct {
+	t time.Time
+	v T
+}
+
+// NewChan returns a channel named name, with a buffer of size values,
+// whose communications are metrics of m. The metrics of channels of
+// the same name add up, and m keeps the channel to gauge its buffer.
+func NewChan[T any](m *Metrics, name string, size int) *Chan[T] {
+	c := &Chan[T]{
+		ch:       make(chan message[T], size),
+		messages: m.messages.WithLabelValues(name),
+		closes:   m.closes.WithLabelValues(name),
+		blocked:  m.blocked.WithLabelValues(name),
+		latency:  m.latency.WithLabelValues(name),
+	}
+	m.occupancy.mu.Lock()
+	m.occupancy.lens[name] = append(m.occupancy.lens[name], func() int { return len(c.ch) })
+	m.occupancy.mu.Unlock()
+	return c
+}
+
+// Send sends v, and counts as a blocked sender until v is delivered or
+// in the buffer. It fails with ctx.Err() if ctx is done first.
+func (c *Chan[T]) Send(ctx context.Context, v T) error {
+	m := message[T]{time.Now(), v}
+	select {
+	case c.ch <- m:
+		return nil
+	default:
+	}
+	c.blocked.Inc()
+	defer c.blocked.Dec()
+	select {
+	case c.ch <- m:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Recv receives a value, ok is false if the channel is closed or ctx is
+// done.
+func (c *Chan[T]) Recv(ctx context.Context) (v T, ok bool) {
+	var m message[T]
+	select {
+	case m, ok = <-c.ch:
+	case <-ctx.Done():
+	}
+	if !ok {
+		return v, false
+	}
+	c.messages.Inc()
+	c.latency.Observe(time.Since(m.t).Seconds())
+	return m.v, true
+}
+
+// Close closes the channel.
+func (c *Chan[T]) Close() {
+	close(c.ch)
+	c.closes.Inc()
+}
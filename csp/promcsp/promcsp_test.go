@@ -0,0 +1,149 @@
+package promcsp_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/changkun/gobase/csp/promcsp"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestChan(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	m, err := promcsp.NewMetrics(reg)
+	if err != nil {
+		t.Fatalf("%v: unexpected error: %v", t.Name(), err)
+	}
+	c := promcsp.NewChan[int](m, "west→X", 2)
+	ctx := context.Background()
+
+	// two values fill the buffer, the third blocks its sender.
+	for i := 1; i <= 2; i++ {
+		if err := c.Send(ctx, i); err != nil {
+			t.Fatalf("%v: unexpected error: %v", t.Name(), err)
+		}
+	}
+	sent := make(chan error)
+	go func() { sent <- c.Send(ctx, 3) }()
+	for deadline := time.Now().Add(5 * time.Second); ; {
+		if blocked(t, reg) == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%v: the third sender is not blocked", t.Name())
+		}
+		time.Sleep(time.Millisecond)
+	}
+	err = testutil.GatherAndCompare(reg, strings.NewReader(`
+# HELP csp_channel_blocked_senders Number of senders waiting to send to the channel.
+# TYPE csp_channel_blocked_senders gauge
+csp_channel_blocked_senders{channel="west→X"} 1
+# HELP csp_channel_messages_total Number of values received from the channel.
+# TYPE csp_channel_messages_total counter
+csp_channel_messages_total{channel="west→X"} 0
+# HELP csp_channel_occupancy Number of values in the buffer of the channel.
+# TYPE csp_channel_occupancy gauge
+csp_channel_occupancy{channel="west→X"} 2
+`), "csp_channel_blocked_senders", "csp_channel_messages_total", "csp_channel_occupancy")
+	if err != nil {
+		t.Fatalf("%v: unexpected metrics: %v", t.Name(), err)
+	}
+
+	var got []int
+	v, _ := c.Recv(ctx)
+	got = append(got, v)
+	if err := <-sent; err != nil {
+		t.Fatalf("%v: unexpected error: %v", t.Name(), err)
+	}
+	c.Close()
+	for {
+		v, ok := c.Recv(ctx)
+		if !ok {
+			break
+		}
+		got = append(got, v)
+	}
+	if len(got) != 3 || got[0] != 1 || got[1] != 2 || got[2] != 3 {
+		t.Fatalf("%v: expected: %v, got: %v", t.Name(), []int{1, 2, 3}, got)
+	}
+	err = testutil.GatherAndCompare(reg, strings.NewReader(`
+# HELP csp_channel_blocked_senders Number of senders waiting to send to the channel.
+# TYPE csp_channel_blocked_senders gauge
+csp_channel_blocked_senders{channel="west→X"} 0
+# HELP csp_channel_closes_total Number of closes of the channel.
+# TYPE csp_channel_closes_total counter
+csp_channel_closes_total{channel="west→X"} 1
+# HELP csp_channel_messages_total Number of values received from the channel.
+# TYPE csp_channel_messages_total counter
+csp_channel_messages_total{channel="west→X"} 3
+# HELP csp_channel_occupancy Number of values in the buffer of the channel.
+# TYPE csp_channel_occupancy gauge
+csp_channel_occupancy{channel="west→X"} 0
+`), "csp_channel_blocked_senders", "csp_channel_closes_total", "csp_channel_messages_total", "csp_channel_occupancy")
+	if err != nil {
+		t.Fatalf("%v: unexpected metrics: %v", t.Name(), err)
+	}
+
+	// every received value is observed by the histogram.
+	mfs, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("%v: unexpected error: %v", t.Name(), err)
+	}
+	var count uint64
+	for _, mf := range mfs {
+		if mf.GetName() == "csp_channel_queue_latency_seconds" {
+			count = mf.GetMetric()[0].GetHistogram().GetSampleCount()
+		}
+	}
+	if count != 3 {
+		t.Fatalf("%v: expected: %v, got: %v", t.Name(), 3, count)
+	}
+}
+
+// blocked returns the number of blocked senders of the only channel of
+// reg.
+func blocked(t *testing.T, reg *prometheus.Registry) float64 {
+	mfs, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("%v: unexpected error: %v", t.Name(), err)
+	}
+	for _, mf := range mfs {
+		if mf.GetName() == "csp_channel_blocked_senders" {
+			return mf.GetMetric()[0].GetGauge().GetValue()
+		}
+	}
+	return 0
+}
+
+func TestChanCanceled(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	m, err := promcsp.NewMetrics(reg)
+	if err != nil {
+		t.Fatalf("%v: unexpected error: %v", t.Name(), err)
+	}
+	c := promcsp.NewChan[int](m, "c", 0)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := c.Send(ctx, 1); err != context.Canceled {
+		t.Fatalf("%v: expected: %v, got: %v", t.Name(), context.Canceled, err)
+	}
+	if _, ok := c.Recv(ctx); ok {
+		t.Fatalf("%v: expected no value", t.Name())
+	}
+	if got := blocked(t, reg); got != 0 {
+		t.Fatalf("%v: expected: %v, got: %v", t.Name(), 0, got)
+	}
+}
+
+func TestNewMetricsTwice(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	if _, err := promcsp.NewMetrics(reg); err != nil {
+		t.Fatalf("%v: unexpected error: %v", t.Name(), err)
+	}
+	if _, err := promcsp.NewMetrics(reg); err == nil {
+		t.Fatalf("%v: expected an error registering the metrics twice", t.Name())
+	}
+}
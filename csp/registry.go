@@ -0,0 +1,221 @@
+package csp
+
+import (
+	"bytes"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// State is the state of a live process.
+type State int
+
+const (
+	// StateRunning is the state of a running or runnable process.
+	StateRunning State = iota
+	// StateSend is the state of a process blocked on an output
+	// command.
+	StateSend
+	// StateRecv is the state of a process blocked on an input
+	// command.
+	StateRecv
+	// StateSelect is the state of a process blocked on an
+	// alternative command.
+	StateSelect
+	// StateBlocked is the state of a process blocked on anything
+	// else, such as a lock, a timer or I/O.
+	StateBlocked
+)
+
+func (s State) String() string {
+	switch s {
+	case StateRunning:
+		return "running"
+	case StateSend:
+		return "blocked-on-send"
+	case StateRecv:
+		return "blocked-on-receive"
+	case StateSelect:
+		return "blocked-on-select"
+	default:
+		return "blocked"
+	}
+}
+
+// ProcessInfo describes a live process.
+type ProcessInfo struct {
+	Label     string
+	Goroutine int    // the id of the goroutine running the process
+	State     State  // the state of the process
+	Wait      string // the reason of the runtime why the process waits, if blocked
+	// Func and Location are the function and the source location the
+	// process is executing. For a blocked process, this is the
+	// communication it is blocked on: the runtime does not tell which
+	// channel a goroutine waits for, the location of the input or
+	// output command identifies it instead.
+	Func     string
+	Location string
+}
+
+// registry is the registry of all live labelled processes.
+var registry = struct {
+	sync.Mutex
+	seq   int
+	procs map[*Running]registered
+}{procs: map[*Running]registered{}}
+
+// registered is the registration of a labelled process.
+type registered struct {
+	seq, goroutine int
+}
+
+// register registers the process r, which is run by the calling
+// goroutine, if it is labelled.
+func register(r *Running) {
+	if r.label == "" {
+		return
+	}
+	id := goid()
+	registry.Lock()
+	defer registry.Unlock()
+	registry.seq++
+	registry.procs[r] = registered{seq: registry.seq, goroutine: id}
+}
+
+// unregister removes the process r from the registry.
+func unregister(r *Running) {
+	if r.label == "" {
+		return
+	}
+	registry.Lock()
+	defer registry.Unlock()
+	delete(registry.procs, r)
+}
+
+// Processes returns the live labelled processes started by Go,
+// including the components of parallel commands and pipelines, in the
+// order they were started. Processes is meant for debugging, it
+// inspects the stacks of all goroutines and stops the world meanwhile.
+func Processes() []ProcessInfo {
+	return processes(nil)
+}
+
+// processes returns the live labelled processes for which keep reports
+// true, all of them if keep is nil, in the order they were started.
+func processes(keep func(*Running) bool) []ProcessInfo {
+	registry.Lock()
+	type entry struct {
+		label string
+		registered
+	}
+	entries := make([]entry, 0, len(registry.procs))
+	for r, reg := range registry.procs {
+		if keep == nil || keep(r) {
+			entries = append(entries, entry{r.label, reg})
+		}
+	}
+	registry.Unlock()
+	sort.Slice(entries, func(i, j int) bool { return entries[i].seq < entries[j].seq })
+
+	stacks := goroutines()
+	infos := make([]ProcessInfo, 0, len(entries))
+	for _, e := range entries {
+		info, ok := stacks[e.goroutine]
+		if !ok {
+			continue // terminated meanwhile
+		}
+		info.Label = e.label
+		infos = append(infos, info)
+	}
+	return infos
+}
+
+// goid returns the id of the calling goroutine.
+func goid() int {
+	buf := make([]byte, 64)
+	buf = buf[:runtime.Stack(buf, false)]
+	// goroutine 18 [running]:
+	fields := bytes.Fields(buf)
+	if len(fields) < 2 {
+		return 0
+	}
+	id, _ := strconv.Atoi(string(fields[1]))
+	return id
+}
+
+// goroutines returns the state of all goroutines by their id, as
+// parsed from their stacks.
+func goroutines() map[int]ProcessInfo {
+	buf := make([]byte, 1<<16)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+
+	infos := map[int]ProcessInfo{}
+	for _, stack := range strings.Split(string(buf), "\n\n") {
+		lines := strings.Split(strings.TrimSpace(stack), "\n")
+		// goroutine 18 [chan send, 2 minutes]:
+		header := lines[0]
+		if !strings.HasPrefix(header, "goroutine ") {
+			continue
+		}
+		open, end := strings.Index(header, "["), strings.LastIndex(header, "]")
+		if open < 0 || end < open {
+			continue
+		}
+		id, err := strconv.Atoi(strings.TrimSpace(header[len("goroutine "):open]))
+		if err != nil {
+			continue
+		}
+		wait := header[open+1 : end]
+		if i := strings.Index(wait, ","); i >= 0 {
+			wait = wait[:i]
+		}
+
+		info := ProcessInfo{Goroutine: id, State: state(wait)}
+		if info.State != StateRunning {
+			info.Wait = wait
+		}
+		// frames are pairs of lines: the function and its location.
+		for i := 1; i+1 < len(lines); i += 2 {
+			fn := lines[i]
+			if strings.HasPrefix(fn, "runtime.") || strings.HasPrefix(fn, "reflect.") {
+				continue
+			}
+			if p := strings.LastIndex(fn, "("); p > 0 {
+				fn = fn[:p]
+			}
+			loc := strings.TrimSpace(lines[i+1])
+			if p := strings.LastIndex(loc, " +0x"); p > 0 {
+				loc = loc[:p]
+			}
+			info.Func, info.Location = fn, loc
+			break
+		}
+		infos[id] = info
+	}
+	return infos
+}
+
+// state returns the state of a goroutine waiting for the given reason.
+func state(wait string) State {
+	switch {
+	case wait == "running" || wait == "runnable" || wait == "syscall":
+		return StateRunning
+	case strings.HasPrefix(wait, "chan send"):
+		return StateSend
+	case strings.HasPrefix(wait, "chan receive"):
+		return StateRecv
+	case strings.HasPrefix(wait, "select"):
+		return StateSelect
+	default:
+		return StateBlocked
+	}
+}
@@ -0,0 +1,65 @@
+package csp_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/changkun/gobase/csp"
+)
+
+func TestProcesses(t *testing.T) {
+	west, east := make(chan rune), make(chan rune)
+	ctx, cancel := context.WithCancel(context.Background())
+	r := csp.Go(ctx, csp.Par(
+		csp.Named("west", csp.ProcessFunc(func(ctx context.Context) error {
+			west <- 'a'
+			return nil
+		})),
+		csp.Named("east", csp.ProcessFunc(func(ctx context.Context) error {
+			<-east
+			return nil
+		})),
+		csp.Named("X", csp.STOP),
+	))
+
+	want := map[string]csp.State{
+		"west": csp.StateSend,
+		"east": csp.StateRecv,
+		"X":    csp.StateRecv,
+	}
+	var infos []csp.ProcessInfo
+	for deadline := time.Now().Add(time.Second); ; {
+		infos = csp.Processes()
+		got := map[string]csp.State{}
+		for _, info := range infos {
+			got[info.Label] = info.State
+		}
+		if len(got) == len(want) && got["west"] == want["west"] && got["east"] == want["east"] && got["X"] == want["X"] {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%v: expected: %v, got: %v", t.Name(), want, infos)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	for _, info := range infos {
+		if info.Label == "west" && !strings.Contains(info.Location, "registry_test.go") {
+			t.Fatalf("%v: expected location in registry_test.go, got: %v", t.Name(), info.Location)
+		}
+		if info.Label == "west" && info.State.String() != "blocked-on-send" {
+			t.Fatalf("%v: expected: %v, got: %v", t.Name(), "blocked-on-send", info.State)
+		}
+	}
+
+	<-west
+	east <- 'b'
+	cancel()
+	r.Wait()
+	for _, info := range csp.Processes() {
+		if info.Label == "west" || info.Label == "east" || info.Label == "X" {
+			t.Fatalf("%v: expected terminated processes to be unregistered, got: %v", t.Name(), info)
+		}
+	}
+}
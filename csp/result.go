@@ -0,0 +1,115 @@
+package csp
+
+import "fmt"
+
+// Result is a value or an error flowing through a pipeline. A process
+// that cannot process a value sends a Result carrying the error
+// downstream instead of aborting, and forwards errors it receives
+// unchanged, so that the end of the pipeline decides how to handle
+// them.
+type Result[T any] struct {
+	Value T
+	Err   error
+}
+
+// SendErr sends the failed result of err to out.
+func SendErr[T any](out chan<- Result[T], err error) {
+	out <- Result[T]{Err: err}
+}
+
+// FirstError receives results from in until it is closed. It returns
+// the values received before the first error, and the first error. The
+// remaining results are discarded, hence the senders never block.
+func FirstError[T any](in <-chan Result[T]) ([]T, error) {
+	values := []T{}
+	var err error
+	for r := range in {
+		switch {
+		case err != nil:
+		case r.Err != nil:
+			err = r.Err
+		default:
+			values = append(values, r.Value)
+		}
+	}
+	return values, err
+}
+
+// SplitErrors separates the values of results received from in from
+// their errors: values are sent to values and errors to errs. Both are
+// closed once in is closed.
+func SplitErrors[T any](in <-chan Result[T], values chan<- T, errs chan<- error) {
+	for r := range in {
+		if r.Err != nil {
+			errs <- r.Err
+			continue
+		}
+		values <- r.Value
+	}
+	close(values)
+	close(errs)
+}
+
+// CardError reports a card of the cardfile that does not fit the 80
+// columns of a card.
+type CardError struct {
+	Card int // the number of the card, counted from 1
+	Len  int // the number of characters of the card
+}
+
+func (e *CardError) Error() string {
+	return fmt.Sprintf("csp: card %d has %d characters, more than 80", e.Card, e.Len)
+}
+
+// S33_DISASSEMBLEErr is like S33_DISASSEMBLE, but reports a card of
+// more than 80 characters with a CardError instead of truncating it.
+// The characters of such a card are not output.
+func S33_DISASSEMBLEErr(cardfile <-chan []rune, X chan<- Result[rune]) {
+	card := 0
+	for cardimage := range cardfile {
+		card++
+		if len(cardimage) > 80 {
+			SendErr(X, &CardError{Card: card, Len: len(cardimage)})
+			continue
+		}
+		for _, c := range cardimage {
+			X <- Result[rune]{Value: c}
+		}
+		X <- Result[rune]{Value: ' '}
+	}
+	close(X)
+}
+
+// S34_ASSEMBLEErr is like S34_ASSEMBLE, but forwards the errors it
+// receives to the lineprinter as soon as they arrive, the current
+// line is continued afterwards.
+func S34_ASSEMBLEErr(X <-chan Result[rune], lineprinter chan<- Result[string]) {
+	lineimage := make([]rune, 0, 125)
+	for r := range X {
+		if r.Err != nil {
+			SendErr(lineprinter, r.Err)
+			continue
+		}
+		lineimage = append(lineimage, r.Value)
+		if len(lineimage) == 125 {
+			lineprinter <- Result[string]{Value: string(lineimage)}
+			lineimage = lineimage[:0]
+		}
+	}
+	if len(lineimage) > 0 {
+		for len(lineimage) < 125 {
+			lineimage = append(lineimage, ' ')
+		}
+		lineprinter <- Result[string]{Value: string(lineimage)}
+	}
+	close(lineprinter)
+}
+
+// S35_ReformatErr is like S35_Reformat, built from the error
+// reporting variants of DISASSEMBLE and ASSEMBLE.
+func S35_ReformatErr(cardfile <-chan []rune, lineprinter chan<- Result[string]) {
+	west, east := make(chan Result[rune]), make(chan Result[rune])
+	go S33_DISASSEMBLEErr(cardfile, west)
+	go Copy(west, east)
+	S34_ASSEMBLEErr(east, lineprinter)
+}
@@ -0,0 +1,85 @@
+package csp_test
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/changkun/gobase/csp"
+)
+
+func TestFirstError(t *testing.T) {
+	errFailed := errors.New("failed")
+	in := make(chan csp.Result[int])
+	go func() {
+		in <- csp.Result[int]{Value: 1}
+		in <- csp.Result[int]{Value: 2}
+		csp.SendErr(in, errFailed)
+		in <- csp.Result[int]{Value: 3}
+		csp.SendErr(in, errors.New("ignored"))
+		close(in)
+	}()
+
+	values, err := csp.FirstError(in)
+	if err != errFailed {
+		t.Fatalf("%v: expected: %v, got: %v", t.Name(), errFailed, err)
+	}
+	if want := []int{1, 2}; !reflect.DeepEqual(want, values) {
+		t.Fatalf("%v: expected: %v, got: %v", t.Name(), want, values)
+	}
+}
+
+func TestSplitErrors(t *testing.T) {
+	in, values, errs := make(chan csp.Result[string]), make(chan string), make(chan error, 2)
+	go csp.SplitErrors(in, values, errs)
+	go func() {
+		in <- csp.Result[string]{Value: "Hello"}
+		csp.SendErr(in, errors.New("failed"))
+		in <- csp.Result[string]{Value: "CSP"}
+		close(in)
+	}()
+
+	got := []string{}
+	for v := range values {
+		got = append(got, v)
+	}
+	if want := []string{"Hello", "CSP"}; !reflect.DeepEqual(want, got) {
+		t.Fatalf("%v: expected: %v, got: %v", t.Name(), want, got)
+	}
+	n := 0
+	for range errs {
+		n++
+	}
+	if n != 1 {
+		t.Fatalf("%v: expected: %v errors, got: %v", t.Name(), 1, n)
+	}
+}
+
+func TestS35_ReformatErr(t *testing.T) {
+	cardfile, lineprinter := make(chan []rune), make(chan csp.Result[string])
+	go csp.S35_ReformatErr(cardfile, lineprinter)
+	go func() {
+		cardfile <- []rune("Hello,")
+		cardfile <- []rune(strings.Repeat("x", 81))
+		cardfile <- []rune("CSP")
+		close(cardfile)
+	}()
+
+	lines, errs := []string{}, []error{}
+	for r := range lineprinter {
+		if r.Err != nil {
+			errs = append(errs, r.Err)
+			continue
+		}
+		lines = append(lines, r.Value)
+	}
+	want := []string{"Hello, CSP " + strings.Repeat(" ", 114)}
+	if !reflect.DeepEqual(want, lines) {
+		t.Fatalf("%v: expected: %q, got: %q", t.Name(), want, lines)
+	}
+	var cerr *csp.CardError
+	if len(errs) != 1 || !errors.As(errs[0], &cerr) || cerr.Card != 2 || cerr.Len != 81 {
+		t.Fatalf("%v: expected error of card 2, got: %v", t.Name(), errs)
+	}
+}
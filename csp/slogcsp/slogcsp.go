@@ -0,0 +1,193 @@
+// Package slogcsp logs the processes and communications of process
+// networks as structured records of log/slog, instead of the printf
+// debugging of their examples. A Chan logs the sends, receives and
+// close of its values with the name of the channel and a summary of
+// the value, and Process the start and termination of a process, whose
+// label the records of the communications of the process then carry:
+//
+//   lg := slogcsp.New(slog.Default())
+//   lg.Every = 100 // one communication in a hundred
+//   west := slogcsp.NewChan[rune](lg, "west→X", 0)
+//   squash := lg.Process(csp.Named("X", csp.ProcessFunc(func(ctx context.Context) error {
+//       for {
+//           c, ok := west.Recv(ctx)
+//           ...
+//       }
+//   })))
+//
+// which logs, by the text handler,
+//
+//   level=INFO msg=spawn process=X
+//   level=DEBUG msg=receive process=X channel=west→X value='a'
+//
+// A value is either logged at its send and its receipt, or not at all.
+package slogcsp
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/changkun/gobase/csp"
+)
+
+// Logger logs processes and channels to a slog.Logger.
+type Logger struct {
+	// Level is the level of the records of communications and closes,
+	// slog.LevelDebug by New. Processes are logged at slog.LevelInfo,
+	// and at slog.LevelError if they fail.
+	Level slog.Level
+	// Every samples the communications of each channel, logging one in
+	// Every of its values, all of them if zero.
+	Every int
+	// Interval samples the communications of each channel, logging at
+	// most one of its values per Interval, all of them if zero.
+	Interval time.Duration
+	// Summary is the length in runes values are cut to, 64 if zero.
+	Summary int
+
+	l *slog.Logger
+}
+
+// New returns a logger logging to l.
+func New(l *slog.Logger) *Logger {
+	return &Logger{Level: slog.LevelDebug, l: l}
+}
+
+type labelKey struct{}
+
+// Process returns p logging its start and termination, with the label
+// of p, which the records of the communications of p under the context
+// of its run carry.
+func (lg *Logger) Process(p csp.Process) csp.Process {
+	label := csp.Label(p)
+	q := csp.ProcessFunc(func(ctx context.Context) error {
+		if label != "" {
+			ctx = context.WithValue(ctx, labelKey{}, label)
+		}
+		lg.log(ctx, slog.LevelInfo, "spawn")
+		err := p.Run(ctx)
+		if err != nil {
+			lg.log(ctx, slog.LevelError, "terminate", slog.Any("err", err))
+		} else {
+			lg.log(ctx, slog.LevelInfo, "terminate")
+		}
+		return err
+	})
+	if label == "" {
+		return q
+	}
+	return csp.Named(label, q)
+}
+
+// log logs msg with the label of the process of ctx, if any, and the
+// attributes attrs.
+func (lg *Logger) log(ctx context.Context, level slog.Level, msg string, attrs ...slog.Attr) {
+	if label, ok := ctx.Value(labelKey{}).(string); ok {
+		attrs = append([]slog.Attr{slog.String("process", label)}, attrs...)
+	}
+	lg.l.LogAttrs(ctx, level, msg, attrs...)
+}
+
+// summary formats v for a record, a rune as a character literal, cut
+// to Summary runes.
+func (lg *Logger) summary(v interface{}) string {
+	s := fmt.Sprint(v)
+	if c, ok := v.(rune); ok {
+		s = strconv.QuoteRune(c)
+	}
+	n := lg.Summary
+	if n == 0 {
+		n = 64
+	}
+	if r := []rune(s); len(r) > n {
+		s = string(r[:n]) + "…"
+	}
+	return s
+}
+
+// Chan is a channel whose communications are logged.
+type Chan[T any] struct {
+	lg   *Logger
+	name string
+	ch   chan message[T]
+
+	mu   sync.Mutex // of the sampling
+	n    int        // values sent
+	last time.Time  // of the last logged value
+}
+
+// message is a value in transit, and whether it is logged.
+type message[T any] struct {
+	logged bool
+	v      T
+}
+
+// NewChan returns a channel named name, with a buffer of size values,
+// whose communications are logged by lg.
+func NewChan[T any](lg *Logger, name string, size int) *Chan[T] {
+	return &Chan[T]{lg: lg, name: name, ch: make(chan message[T], size)}
+}
+
+// sample reports whether the next value sent is logged.
+func (c *Chan[T]) sample(ctx context.Context) bool {
+	if !c.lg.l.Enabled(ctx, c.lg.Level) {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.n++
+	if c.lg.Every > 1 && (c.n-1)%c.lg.Every != 0 {
+		return false
+	}
+	if c.lg.Interval > 0 {
+		now := time.Now()
+		if !c.last.IsZero() && now.Sub(c.last) < c.lg.Interval {
+			return false
+		}
+		c.last = now
+	}
+	return true
+}
+
+// Send sends v, unless ctx is done first, in which case it fails with
+// ctx.Err().
+func (c *Chan[T]) Send(ctx context.Context, v T) error {
+	m := message[T]{c.sample(ctx), v}
+	select {
+	case c.ch <- m:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	if m.logged {
+		c.lg.log(ctx, c.lg.Level, "send", slog.String("channel", c.name), slog.String("value", c.lg.summary(v)))
+	}
+	return nil
+}
+
+// Recv receives a value, ok is false if the channel is closed or ctx is
+// done.
+func (c *Chan[T]) Recv(ctx context.Context) (v T, ok bool) {
+	var m message[T]
+	select {
+	case m, ok = <-c.ch:
+	case <-ctx.Done():
+	}
+	if !ok {
+		return v, false
+	}
+	if m.logged {
+		c.lg.log(ctx, c.lg.Level, "receive", slog.String("channel", c.name), slog.String("value", c.lg.summary(m.v)))
+	}
+	return m.v, true
+}
+
+// Close closes the channel, which is always logged, on behalf of the
+// process of ctx.
+func (c *Chan[T]) Close(ctx context.Context) {
+	close(c.ch)
+	c.lg.log(ctx, c.lg.Level, "close", slog.String("channel", c.name))
+}
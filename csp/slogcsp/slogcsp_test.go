@@ -0,0 +1,127 @@
+package slogcsp_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/changkun/gobase/csp"
+	"github.com/changkun/gobase/csp/slogcsp"
+)
+
+// newLogger returns a logger of all levels writing to b, without times.
+func newLogger(b *bytes.Buffer) *slog.Logger {
+	return slog.New(slog.NewTextHandler(b, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey && len(groups) == 0 {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}))
+}
+
+func TestChan(t *testing.T) {
+	var b bytes.Buffer
+	lg := slogcsp.New(newLogger(&b))
+	lg.Every = 2
+	c := slogcsp.NewChan[rune](lg, "west→X", 3)
+
+	err := lg.Process(csp.Named("X", csp.ProcessFunc(func(ctx context.Context) error {
+		for _, r := range "abc" {
+			if err := c.Send(ctx, r); err != nil {
+				return err
+			}
+		}
+		c.Close(ctx)
+		for {
+			if _, ok := c.Recv(ctx); !ok {
+				return nil
+			}
+		}
+	}))).Run(context.Background())
+	if err != nil {
+		t.Fatalf("%v: unexpected error: %v", t.Name(), err)
+	}
+
+	want := `level=INFO msg=spawn process=X
+level=DEBUG msg=send process=X channel=west→X value='a'
+level=DEBUG msg=send process=X channel=west→X value='c'
+level=DEBUG msg=close process=X channel=west→X
+level=DEBUG msg=receive process=X channel=west→X value='a'
+level=DEBUG msg=receive process=X channel=west→X value='c'
+level=INFO msg=terminate process=X
+`
+	if got := b.String(); got != want {
+		t.Fatalf("%v: expected:\n%v\ngot:\n%v", t.Name(), want, got)
+	}
+}
+
+func TestChanInterval(t *testing.T) {
+	var b bytes.Buffer
+	lg := slogcsp.New(newLogger(&b))
+	lg.Interval = time.Hour
+	lg.Summary = 3
+	c := slogcsp.NewChan[string](lg, "c", 2)
+	ctx := context.Background()
+	for _, s := range []string{"abcdef", "ghi"} {
+		if err := c.Send(ctx, s); err != nil {
+			t.Fatalf("%v: unexpected error: %v", t.Name(), err)
+		}
+	}
+
+	// only the first value is logged, cut to 3 runes.
+	want := "level=DEBUG msg=send channel=c value=abc…\n"
+	if got := b.String(); got != want {
+		t.Fatalf("%v: expected: %q, got: %q", t.Name(), want, got)
+	}
+}
+
+func TestChanLevel(t *testing.T) {
+	var b bytes.Buffer
+	lg := slogcsp.New(slog.New(slog.NewTextHandler(&b, nil)))
+	c := slogcsp.NewChan[int](lg, "c", 1)
+	ctx := context.Background()
+	if err := c.Send(ctx, 1); err != nil {
+		t.Fatalf("%v: unexpected error: %v", t.Name(), err)
+	}
+	if v, ok := c.Recv(ctx); !ok || v != 1 {
+		t.Fatalf("%v: expected: %v, got: %v", t.Name(), 1, v)
+	}
+	if b.Len() != 0 {
+		t.Fatalf("%v: expected no records below the level of the handler, got: %v", t.Name(), b.String())
+	}
+
+	// a full channel fails the send once ctx is done.
+	if err := c.Send(ctx, 2); err != nil {
+		t.Fatalf("%v: unexpected error: %v", t.Name(), err)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := c.Send(ctx, 3); err != context.Canceled {
+		t.Fatalf("%v: expected: %v, got: %v", t.Name(), context.Canceled, err)
+	}
+}
+
+func TestProcessError(t *testing.T) {
+	var b bytes.Buffer
+	lg := slogcsp.New(newLogger(&b))
+	want := errors.New("jam")
+	p := lg.Process(csp.Named("west", csp.ProcessFunc(func(ctx context.Context) error {
+		return want
+	})))
+	if got := csp.Label(p); got != "west" {
+		t.Fatalf("%v: expected: %v, got: %v", t.Name(), "west", got)
+	}
+	if err := p.Run(context.Background()); !errors.Is(err, want) {
+		t.Fatalf("%v: expected: %v, got: %v", t.Name(), want, err)
+	}
+	if got := b.String(); !strings.Contains(got, "level=ERROR msg=terminate process=west err=jam") {
+		t.Fatalf("%v: expected the failure to be logged, got:\n%v", t.Name(), got)
+	}
+}
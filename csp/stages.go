@@ -0,0 +1,289 @@
+package csp
+
+import (
+	"container/heap"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Scatter distributes runes from in round-robin over outs. The
+// original stream position of every rune is sent on the lane's
+// parallel index channel right before the rune itself, so that
+// GatherOrdered can reassemble the original order regardless of how
+// fast each lane processes its runes. All outs and indices are closed
+// once in is closed.
+//
+// The number of index channels must match the number of lanes.
+func Scatter(in <-chan rune, outs []chan<- rune, indices []chan<- int) {
+	if len(outs) != len(indices) {
+		panic("csp: number of lanes and index channels mismatch")
+	}
+	i := 0
+	for c := range in {
+		k := i % len(outs)
+		indices[k] <- i
+		outs[k] <- c
+		i++
+	}
+	for k := range outs {
+		close(outs[k])
+		close(indices[k])
+	}
+}
+
+// GatherOrdered reassembles the runes of lanes created by Scatter into
+// their original order and sends them to result. Every lane must emit
+// exactly one rune on outs[k] per index received on indices[k], in the
+// same order the lane received them; lanes may run at arbitrary speed
+// relative to each other. result is closed once all index channels
+// are closed. GatherOrdered panics if a lane closes its output before
+// it emitted a rune for every index, rather than leave a gap in result.
+func GatherOrdered(outs []<-chan rune, indices []<-chan int, result chan<- rune) {
+	if len(outs) != len(indices) {
+		panic("csp: number of lanes and index channels mismatch")
+	}
+
+	type tagged struct {
+		i  int
+		c  rune
+		ok bool // false if the lane closed its output early
+	}
+	merged := make(chan tagged)
+	wg := sync.WaitGroup{}
+	wg.Add(len(outs))
+	for k := range outs {
+		go func(k int) {
+			defer wg.Done()
+			for i := range indices[k] {
+				c, ok := <-outs[k]
+				merged <- tagged{i, c, ok}
+				if !ok {
+					return
+				}
+			}
+		}(k)
+	}
+	go func() {
+		wg.Wait()
+		close(merged)
+	}()
+
+	next := 0
+	pending := map[int]rune{}
+	for t := range merged {
+		if !t.ok {
+			panic("csp: lane closed its output early")
+		}
+		pending[t.i] = t.c
+		for {
+			c, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			result <- c
+			next++
+		}
+	}
+	close(result)
+}
+
+// MatchPattern forwards runes from in to out unchanged while running a
+// streaming Knuth-Morris-Pratt matcher over them. onMatch is invoked
+// with the position of the last rune of each occurrence of pattern,
+// counted in runes from 0. Occurrences are non-overlapping: once a
+// match is reported the matcher restarts from scratch, hence "aaaa"
+// contains two occurrences of "aa" rather than three. The matcher only
+// keeps state proportional to the length of pattern. out is closed once
+// in is closed.
+//
+// An empty pattern is rejected with a panic.
+func MatchPattern(in <-chan rune, out chan<- rune, pattern []rune, onMatch func(pos int)) {
+	if len(pattern) == 0 {
+		panic("csp: empty pattern")
+	}
+
+	// fail[i] is the length of the longest proper prefix of
+	// pattern[:i+1] that is also a suffix of it.
+	fail := make([]int, len(pattern))
+	for i, k := 1, 0; i < len(pattern); i++ {
+		for k > 0 && pattern[i] != pattern[k] {
+			k = fail[k-1]
+		}
+		if pattern[i] == pattern[k] {
+			k++
+		}
+		fail[i] = k
+	}
+
+	pos, k := 0, 0
+	for c := range in {
+		out <- c
+		for k > 0 && c != pattern[k] {
+			k = fail[k-1]
+		}
+		if c == pattern[k] {
+			k++
+		}
+		if k == len(pattern) {
+			onMatch(pos)
+			k = 0
+		}
+		pos++
+	}
+	close(out)
+}
+
+// LeakyBucket forwards values from in to out at a smoothed rate using a
+// token bucket. The bucket holds up to burst tokens and starts full;
+// tokens are replenished at ratePerSec. A value is forwarded only when
+// a token is available, otherwise it is held, and so is the upstream,
+// until the next token arrives. A burst of 1 therefore behaves as a
+// strict rate limit of one value every 1/ratePerSec seconds. out is
+// closed once in is closed.
+//
+// A non-positive ratePerSec or burst is rejected with a panic.
+func LeakyBucket(in <-chan int, out chan<- int, ratePerSec, burst int) {
+	if ratePerSec <= 0 {
+		panic("csp: non-positive rate")
+	}
+	if burst <= 0 {
+		panic("csp: non-positive burst")
+	}
+
+	tokens, last := float64(burst), time.Now()
+	for v := range in {
+		now := time.Now()
+		tokens += now.Sub(last).Seconds() * float64(ratePerSec)
+		if tokens > float64(burst) {
+			tokens = float64(burst)
+		}
+		last = now
+
+		if tokens < 1 {
+			wait := time.Duration((1 - tokens) / float64(ratePerSec) * float64(time.Second))
+			time.Sleep(wait)
+			tokens, last = 1, last.Add(wait)
+		}
+		tokens--
+		out <- v
+	}
+	close(out)
+}
+
+// MergeDedup merges runes from all sources into out, suppressing any
+// rune value that has already been emitted, i.e. out receives the
+// distinct union of the sources. out is closed once all sources are
+// closed.
+//
+// MergeDedup remembers every distinct value it has emitted, hence its
+// memory is bounded only by the number of distinct runes. Use
+// MergeDedupWindow if the memory must be bounded.
+func MergeDedup(out chan<- rune, sources ...<-chan rune) {
+	seen := map[rune]struct{}{}
+	for c := range mergeRunes(sources) {
+		if _, ok := seen[c]; ok {
+			continue
+		}
+		seen[c] = struct{}{}
+		out <- c
+	}
+	close(out)
+}
+
+// MergeDedupWindow is the bounded variant of MergeDedup. It only
+// remembers the last window distinct values it has emitted, therefore
+// a duplicate is suppressed only if it arrives before its first
+// occurrence has been evicted from the window. A window <= 0 remembers
+// nothing and suppresses no duplicates, out receives the runes as by
+// Merge.
+func MergeDedupWindow(out chan<- rune, window int, sources ...<-chan rune) {
+	seen := map[rune]struct{}{}
+	order := make([]rune, 0, max(window, 0))
+	for c := range mergeRunes(sources) {
+		if _, ok := seen[c]; ok {
+			continue
+		}
+		if window > 0 && len(order) == window {
+			delete(seen, order[0])
+			order = order[1:]
+		}
+		if window > 0 {
+			seen[c] = struct{}{}
+			order = append(order, c)
+		}
+		out <- c
+	}
+	close(out)
+}
+
+// mergeRunes fans in all sources to the returned channel, which is
+// closed once all sources are closed.
+func mergeRunes(sources []<-chan rune) <-chan rune {
+	merged := make(chan rune)
+	go Merge(merged, sources...)
+	return merged
+}
+
+// SortWindow reorders a nearly sorted stream of ints with bounded
+// memory. It buffers up to window values and, whenever the buffer is
+// full, emits the least buffered value. At EOF the remaining buffer is
+// flushed in sorted order and out is closed. The output is fully
+// sorted if no value is displaced by window or more positions from its
+// sorted position. A window <= 1 passes values through unchanged.
+func SortWindow(in <-chan int, out chan<- int, window int) {
+	h := &intHeap{}
+	for v := range in {
+		heap.Push(h, v)
+		if h.Len() >= window {
+			out <- heap.Pop(h).(int)
+		}
+	}
+	for h.Len() > 0 {
+		out <- heap.Pop(h).(int)
+	}
+	close(out)
+}
+
+// intHeap is a min-heap of ints implementing heap.Interface.
+type intHeap []int
+
+func (h intHeap) Len() int            { return len(h) }
+func (h intHeap) Less(i, j int) bool  { return h[i] < h[j] }
+func (h intHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *intHeap) Push(x interface{}) { *h = append(*h, x.(int)) }
+func (h *intHeap) Pop() interface{} {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+// Progress forwards runes from in to out while reporting the running
+// total of UTF-8 encoded bytes passed so far. report is invoked each
+// time another everyBytes bytes have passed, and once more with the
+// exact total after in is closed, regardless of whether the total is a
+// multiple of everyBytes. A multibyte rune crossing several boundaries
+// results in a single report. out is closed once in is closed.
+//
+// A non-positive everyBytes is rejected with a panic.
+func Progress(in <-chan rune, out chan<- rune, everyBytes int, report func(total int)) {
+	if everyBytes <= 0 {
+		panic("csp: non-positive progress interval")
+	}
+
+	total, next := 0, everyBytes
+	for c := range in {
+		out <- c
+		total += utf8.RuneLen(c)
+		if total >= next {
+			report(total)
+			for next <= total {
+				next += everyBytes
+			}
+		}
+	}
+	close(out)
+	report(total)
+}
@@ -0,0 +1,308 @@
+package csp_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/changkun/gobase/csp"
+)
+
+func TestScatterGatherOrdered(t *testing.T) {
+	characters := "Hello, CSP. Communicating sequential processes."
+	lanes := 4
+
+	in, result := make(chan rune), make(chan rune)
+	outs := make([]chan<- rune, lanes)
+	indicesIn, indicesOut := make([]chan<- int, lanes), make([]<-chan int, lanes)
+	processed := make([]<-chan rune, lanes)
+	for k := 0; k < lanes; k++ {
+		lane, index, result := make(chan rune), make(chan int), make(chan rune)
+		outs[k] = lane
+		indicesIn[k], indicesOut[k] = index, index
+		processed[k] = result
+
+		// each lane is slower than its predecessor.
+		go func(k int, lane <-chan rune, result chan<- rune) {
+			for c := range lane {
+				time.Sleep(time.Duration(k) * time.Millisecond)
+				result <- c
+			}
+			close(result)
+		}(k, lane, result)
+	}
+
+	go csp.Scatter(in, outs, indicesIn)
+	go csp.GatherOrdered(processed, indicesOut, result)
+	go func() {
+		for _, c := range characters {
+			in <- c
+		}
+		close(in)
+	}()
+
+	received := make([]rune, 0, len(characters))
+	for r := range result {
+		received = append(received, r)
+	}
+	if string(received) != characters {
+		t.Fatalf("%v: expected: %v, got: %v", t.Name(), characters, string(received))
+	}
+}
+
+func TestGatherOrderedClosedEarly(t *testing.T) {
+	// the lane closes its output after the first of two runes.
+	out, index := make(chan rune, 1), make(chan int, 2)
+	out <- 'a'
+	close(out)
+	index <- 0
+	index <- 1
+	close(index)
+
+	defer func() {
+		if r := recover(); r != "csp: lane closed its output early" {
+			t.Fatalf("%v: expected panic of a lane closed early, got: %v", t.Name(), r)
+		}
+	}()
+	csp.GatherOrdered([]<-chan rune{out}, []<-chan int{index}, make(chan rune, 2))
+}
+
+func TestMatchPattern(t *testing.T) {
+	tests := []struct {
+		stream  string
+		pattern string
+		want    []int
+	}{
+		{stream: "Hello, CSP.", pattern: "Go", want: []int{}},
+		{stream: "Hello, CSP.", pattern: "CSP", want: []int{9}},
+		{stream: "abababcab abc", pattern: "abc", want: []int{6, 12}},
+		{stream: "aaaa", pattern: "aa", want: []int{1, 3}},
+		{stream: "ab", pattern: "abc", want: []int{}},
+		{stream: "", pattern: "a", want: []int{}},
+	}
+
+	for _, tt := range tests {
+		in, out := make(chan rune), make(chan rune)
+		got := []int{}
+		go csp.MatchPattern(in, out, []rune(tt.pattern), func(pos int) {
+			got = append(got, pos)
+		})
+		go func(stream string) {
+			for _, c := range stream {
+				in <- c
+			}
+			close(in)
+		}(tt.stream)
+
+		received := []rune{}
+		for c := range out {
+			received = append(received, c)
+		}
+		if string(received) != tt.stream {
+			t.Fatalf("%v: expected: %v, got: %v", t.Name(), tt.stream, string(received))
+		}
+		if !reflect.DeepEqual(tt.want, got) {
+			t.Fatalf("%v: matches of %q in %q, expected: %v, got: %v", t.Name(), tt.pattern, tt.stream, tt.want, got)
+		}
+	}
+}
+
+func TestMatchPatternEmpty(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil {
+			t.Fatalf("%v: expected panic on empty pattern", t.Name())
+		}
+	}()
+	csp.MatchPattern(make(chan rune), make(chan rune), nil, func(int) {})
+}
+
+func TestLeakyBucket(t *testing.T) {
+	tests := []struct {
+		n, rate, burst int
+		want           time.Duration
+	}{
+		// the first value passes immediately, the others wait 10ms each.
+		{n: 21, rate: 100, burst: 1, want: 200 * time.Millisecond},
+		// the first 10 values pass as a burst.
+		{n: 30, rate: 100, burst: 10, want: 200 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		in, out := make(chan int), make(chan int)
+		go csp.LeakyBucket(in, out, tt.rate, tt.burst)
+		go func(n int) {
+			for i := 0; i < n; i++ {
+				in <- i
+			}
+			close(in)
+		}(tt.n)
+
+		start := time.Now()
+		i := 0
+		for v := range out {
+			if v != i {
+				t.Fatalf("%v: expected %v, got %v", t.Name(), i, v)
+			}
+			i++
+		}
+		elapsed := time.Since(start)
+		if i != tt.n {
+			t.Fatalf("%v: expected %v values, got %v", t.Name(), tt.n, i)
+		}
+		if elapsed < tt.want*9/10 || elapsed > tt.want*2 {
+			t.Fatalf("%v: expected about %v, got %v", t.Name(), tt.want, elapsed)
+		}
+	}
+}
+
+func TestLeakyBucketInvalidRate(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil {
+			t.Fatalf("%v: expected panic on non-positive rate", t.Name())
+		}
+	}()
+	csp.LeakyBucket(make(chan int), make(chan int), 0, 1)
+}
+
+func TestMergeDedup(t *testing.T) {
+	a, b, out := make(chan rune), make(chan rune), make(chan rune)
+	go csp.MergeDedup(out, a, b)
+	send := func(ch chan rune, s string) {
+		for _, c := range s {
+			ch <- c
+		}
+		close(ch)
+	}
+	go send(a, "aabcdd")
+	go send(b, "cdeeff")
+
+	got := map[rune]int{}
+	for c := range out {
+		got[c]++
+	}
+	want := map[rune]int{'a': 1, 'b': 1, 'c': 1, 'd': 1, 'e': 1, 'f': 1}
+	if !reflect.DeepEqual(want, got) {
+		t.Fatalf("%v: expected: %v, got: %v", t.Name(), want, got)
+	}
+}
+
+func TestMergeDedupWindow(t *testing.T) {
+	tests := []struct {
+		window int
+		want   string
+	}{
+		// 'a' and 'b' are evicted by 'c' and 'a' and emitted again.
+		{window: 2, want: "abcab"},
+		// no window suppresses no duplicates.
+		{window: 0, want: "aabacab"},
+		{window: -1, want: "aabacab"},
+	}
+	for _, tt := range tests {
+		a, out := make(chan rune), make(chan rune)
+		go csp.MergeDedupWindow(out, tt.window, a)
+		go func() {
+			for _, c := range "aabacab" {
+				a <- c
+			}
+			close(a)
+		}()
+
+		received := []rune{}
+		for c := range out {
+			received = append(received, c)
+		}
+		if string(received) != tt.want {
+			t.Fatalf("%v: window %v expected: %v, got: %v", t.Name(), tt.window, tt.want, string(received))
+		}
+	}
+}
+
+func TestSortWindow(t *testing.T) {
+	window := 5
+
+	// reversing blocks of window values displaces each value by less
+	// than window positions.
+	stream := make([]int, 0, 100)
+	for i := 0; i < 100; i += window {
+		for j := i + window - 1; j >= i; j-- {
+			stream = append(stream, j)
+		}
+	}
+
+	tests := []struct {
+		window int
+		want   []int
+	}{
+		{window: window, want: func() []int {
+			s := make([]int, 100)
+			for i := range s {
+				s[i] = i
+			}
+			return s
+		}()},
+		{window: 1, want: stream},
+		{window: 0, want: stream},
+	}
+
+	for _, tt := range tests {
+		in, out := make(chan int), make(chan int)
+		go csp.SortWindow(in, out, tt.window)
+		go func() {
+			for _, v := range stream {
+				in <- v
+			}
+			close(in)
+		}()
+
+		got := []int{}
+		for v := range out {
+			got = append(got, v)
+		}
+		if !reflect.DeepEqual(tt.want, got) {
+			t.Fatalf("%v: window %v, expected: %v, got: %v", t.Name(), tt.window, tt.want, got)
+		}
+	}
+}
+
+func TestProgress(t *testing.T) {
+	tests := []struct {
+		stream string
+		every  int
+		want   []int
+	}{
+		{stream: "Hello, CSP.", every: 4, want: []int{4, 8, 11}},
+		{stream: "Hello, CSP.", every: 11, want: []int{11, 11}},
+		{stream: "↑↑↑", every: 4, want: []int{6, 9, 9}},
+		{stream: "", every: 4, want: []int{0}},
+	}
+
+	for _, tt := range tests {
+		in, out := make(chan rune), make(chan rune)
+		got := []int{}
+		done := make(chan struct{})
+		go func(every int) {
+			csp.Progress(in, out, every, func(total int) {
+				got = append(got, total)
+			})
+			close(done)
+		}(tt.every)
+		go func(stream string) {
+			for _, c := range stream {
+				in <- c
+			}
+			close(in)
+		}(tt.stream)
+
+		received := []rune{}
+		for c := range out {
+			received = append(received, c)
+		}
+		<-done
+		if string(received) != tt.stream {
+			t.Fatalf("%v: expected: %v, got: %v", t.Name(), tt.stream, string(received))
+		}
+		if !reflect.DeepEqual(tt.want, got) {
+			t.Fatalf("%v: expected reports: %v, got: %v", t.Name(), tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+// Package supervise restarts failed processes of a process network.
+//
+// A Supervisor is a process that runs a fixed list of child processes
+// in parallel, as the parallel command [P||Q||R] does. Unlike a
+// parallel command, it restarts a child that failed, i.e. returned an
+// error or panicked, according to its strategy:
+//
+//   OneForOne   restarts only the failed child.
+//   RestForOne  restarts the failed child and all children started
+//               after it, which usually depend on it, e.g. the stages
+//               downstream of a failed pipeline stage.
+//
+// Restarts are delayed by a backoff, and a supervisor gives up once a
+// child was restarted too often. A child that terminates successfully
+// is not restarted, the supervisor terminates once all children have.
+package supervise
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/changkun/gobase/csp"
+)
+
+// Strategy is the restart strategy of a supervisor.
+type Strategy int
+
+const (
+	// OneForOne restarts only the failed child.
+	OneForOne Strategy = iota
+	// RestForOne restarts the failed child and all children after it.
+	RestForOne
+)
+
+// Supervisor is a process supervising its children.
+type Supervisor struct {
+	// Strategy is the restart strategy.
+	Strategy Strategy
+	// Backoff returns the delay before the n-th restart of a child,
+	// counted from 1. If nil, Exponential(10ms, time.Second) is used.
+	Backoff func(n int) time.Duration
+	// MaxRestarts is the maximum number of restarts of a single child
+	// before the supervisor gives up, 0 means unlimited.
+	MaxRestarts int
+
+	children []csp.Process
+}
+
+// New returns a supervisor of children with the given strategy.
+func New(strategy Strategy, children ...csp.Process) *Supervisor {
+	return &Supervisor{Strategy: strategy, children: children}
+}
+
+// Exponential returns a backoff that doubles the delay with every
+// restart, starting from base, up to max.
+func Exponential(base, max time.Duration) func(n int) time.Duration {
+	return func(n int) time.Duration {
+		d := base
+		for i := 1; i < n && d < max; i++ {
+			d *= 2
+		}
+		if d > max {
+			d = max
+		}
+		return d
+	}
+}
+
+// exit is the termination of the gen-th run of the i-th child.
+type exit struct {
+	i, gen int
+	err    error
+}
+
+// Run runs the supervisor until all children terminated successfully,
+// ctx is done, or a child exceeded MaxRestarts. In the latter case Run
+// fails with the last error of that child.
+func (s *Supervisor) Run(ctx context.Context) error {
+	backoff := s.Backoff
+	if backoff == nil {
+		backoff = Exponential(10*time.Millisecond, time.Second)
+	}
+
+	n := len(s.children)
+	running := make([]*csp.Running, n)
+	gens := make([]int, n)
+	restarts := make([]int, n)
+	live := 0
+
+	exits := make(chan exit)
+	quit := make(chan struct{})
+	defer close(quit)
+	start := func(i int) {
+		gens[i]++
+		r := csp.Go(ctx, s.children[i])
+		running[i] = r
+		live++
+		go func(gen int) {
+			err := r.Wait()
+			select {
+			case exits <- exit{i, gen, err}:
+			case <-quit:
+			}
+		}(gens[i])
+	}
+	stop := func(i int) {
+		if running[i] == nil {
+			return
+		}
+		running[i].Stop()
+		running[i] = nil
+		gens[i]++ // the exit of the stopped run is stale
+		live--
+	}
+	stopAll := func() {
+		for i := range running {
+			stop(i)
+		}
+	}
+
+	for i := range s.children {
+		start(i)
+	}
+	for live > 0 {
+		var e exit
+		select {
+		case e = <-exits:
+		case <-ctx.Done():
+			stopAll()
+			return ctx.Err()
+		}
+		if e.gen != gens[e.i] {
+			continue
+		}
+		running[e.i] = nil
+		live--
+		if e.err == nil {
+			continue
+		}
+		if ctx.Err() != nil {
+			stopAll()
+			return ctx.Err()
+		}
+
+		restarts[e.i]++
+		if s.MaxRestarts > 0 && restarts[e.i] > s.MaxRestarts {
+			stopAll()
+			return fmt.Errorf("supervise: too many restarts: %w", e.err)
+		}
+		if s.Strategy == RestForOne {
+			for j := e.i + 1; j < n; j++ {
+				stop(j)
+			}
+		}
+		select {
+		case <-time.After(backoff(restarts[e.i])):
+		case <-ctx.Done():
+			stopAll()
+			return ctx.Err()
+		}
+		start(e.i)
+		if s.Strategy == RestForOne {
+			for j := e.i + 1; j < n; j++ {
+				start(j)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package supervise_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/changkun/gobase/csp"
+	"github.com/changkun/gobase/csp/supervise"
+)
+
+// flaky returns a process that fails the first n runs, by returning an
+// error or by panicking, and then succeeds. runs records its runs.
+func flaky(n int, panics bool, runs *int) csp.Process {
+	return csp.ProcessFunc(func(ctx context.Context) error {
+		*runs++
+		if *runs > n {
+			return nil
+		}
+		if panics {
+			panic("boom")
+		}
+		return errors.New("failed")
+	})
+}
+
+func TestOneForOne(t *testing.T) {
+	a, b, c := 0, 0, 0
+	s := supervise.New(supervise.OneForOne, flaky(3, false, &a), flaky(0, false, &b), flaky(2, true, &c))
+	s.Backoff = func(int) time.Duration { return time.Millisecond }
+	if err := s.Run(context.Background()); err != nil {
+		t.Fatalf("%v: unexpected error: %v", t.Name(), err)
+	}
+	if got, want := []int{a, b, c}, []int{4, 1, 3}; !reflect.DeepEqual(want, got) {
+		t.Fatalf("%v: expected runs: %v, got: %v", t.Name(), want, got)
+	}
+}
+
+func TestRestForOne(t *testing.T) {
+	mu := sync.Mutex{}
+	runs := map[string]int{}
+	child := func(label string, fail bool) csp.Process {
+		return csp.Named(label, csp.ProcessFunc(func(ctx context.Context) error {
+			mu.Lock()
+			runs[label]++
+			n := runs[label]
+			mu.Unlock()
+			if fail && n == 1 {
+				return errors.New("failed")
+			}
+			if label == "east" {
+				return nil
+			}
+			<-ctx.Done()
+			return nil
+		}))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s := supervise.New(supervise.RestForOne, child("west", false), child("X", true), child("east", false))
+	s.Backoff = func(int) time.Duration { return time.Millisecond }
+	done := make(chan error)
+	go func() { done <- s.Run(ctx) }()
+
+	for {
+		mu.Lock()
+		n := runs["east"]
+		mu.Unlock()
+		if n == 2 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Fatalf("%v: expected: %v, got: %v", t.Name(), context.Canceled, err)
+	}
+	if want := map[string]int{"west": 1, "X": 2, "east": 2}; !reflect.DeepEqual(want, runs) {
+		t.Fatalf("%v: expected runs: %v, got: %v", t.Name(), want, runs)
+	}
+}
+
+func TestMaxRestarts(t *testing.T) {
+	runs := 0
+	s := supervise.New(supervise.OneForOne, flaky(10, true, &runs))
+	s.Backoff = func(int) time.Duration { return 0 }
+	s.MaxRestarts = 2
+
+	err := s.Run(context.Background())
+	var pe *csp.PanicError
+	if !errors.As(err, &pe) {
+		t.Fatalf("%v: expected panic error, got: %v", t.Name(), err)
+	}
+	if runs != 3 {
+		t.Fatalf("%v: expected: %v runs, got: %v", t.Name(), 3, runs)
+	}
+}
+
+func TestExponential(t *testing.T) {
+	backoff := supervise.Exponential(10*time.Millisecond, 50*time.Millisecond)
+	want := []time.Duration{10, 20, 40, 50, 50}
+	for i, w := range want {
+		if got := backoff(i + 1); got != w*time.Millisecond {
+			t.Fatalf("%v: restart %v, expected: %v, got: %v", t.Name(), i+1, w*time.Millisecond, got)
+		}
+	}
+}
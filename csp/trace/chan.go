@@ -0,0 +1,155 @@
+package trace
+
+import (
+	"context"
+	"reflect"
+	"time"
+)
+
+// Chan is a channel whose communications are recorded. A process sends
+// and receives by the methods of Chan, giving its label:
+//
+//   c := trace.NewChan(r, "X→Y", make(chan rune))
+//   go func() { c.Send(ctx, "X", 'a'); c.Close("X") }()
+//   v, ok := c.Recv(ctx, "Y")
+//
+// A nil recorder records nothing.
+type Chan[T any] struct {
+	r    *Recorder
+	name string
+	ch   chan T
+}
+
+// NewChan returns the channel ch named name, recorded by r.
+func NewChan[T any](r *Recorder, name string, ch chan T) *Chan[T] {
+	return &Chan[T]{r: r, name: name, ch: ch}
+}
+
+// C returns the underlying channel.
+func (c *Chan[T]) C() chan T {
+	return c.ch
+}
+
+// Send sends v on behalf of the process proc unless ctx is done first,
+// in which case it reports false.
+func (c *Chan[T]) Send(ctx context.Context, proc string, v T) bool {
+	start := c.start()
+	select {
+	case c.ch <- v:
+	case <-ctx.Done():
+		return false
+	}
+	if c.r != nil {
+		c.r.Record(Event{Kind: Send, Process: proc, Chan: c.name, Value: v, Start: start})
+	}
+	return true
+}
+
+// Recv receives a value on behalf of the process proc. ok is false if
+// the channel is closed or ctx is done.
+func (c *Chan[T]) Recv(ctx context.Context, proc string) (v T, ok bool) {
+	start := c.start()
+	select {
+	case v, ok = <-c.ch:
+	case <-ctx.Done():
+		return v, false
+	}
+	if ok && c.r != nil {
+		c.r.Record(Event{Kind: Receive, Process: proc, Chan: c.name, Value: v, Start: start})
+	}
+	return v, ok
+}
+
+// Close closes the channel on behalf of the process proc.
+func (c *Chan[T]) Close(proc string) {
+	close(c.ch)
+	if c.r != nil {
+		c.r.Record(Event{Kind: Close, Process: proc, Chan: c.name})
+	}
+}
+
+func (c *Chan[T]) start() time.Time {
+	if c.r == nil {
+		return time.Time{}
+	}
+	return c.r.Time()
+}
+
+// Tap relays the values of in to out, recording their sends by the
+// process src and their receives by the process dst on the channel
+// name, and closes out once in is closed, recording the close by src.
+// It returns once in is closed or ctx is done. A process network of
+// plain channels is traced by tapping its channels:
+//
+//   mid := make(chan rune)
+//   go trace.Tap(ctx, r, "X→Y", "X", "Y", x, mid)
+//   go Y(mid)
+//
+// A tap holds a single value in transit: a send is recorded once the
+// tap received the value, before the receiver inputs it. Under the
+// context of a Replayer, a tap replays the trace of the replayer, r
+// may be nil to replay without recording.
+func Tap[T any](ctx context.Context, r *Recorder, name, src, dst string, in <-chan T, out chan<- T) {
+	TapAny(ctx, r, name, src, dst, reflect.ValueOf(in), reflect.ValueOf(out))
+}
+
+// TapAny is the untyped variant of Tap for channels whose element type
+// is only known at runtime, in must be a channel that can be received
+// from and out one of the same element type that can be sent to.
+func TapAny(ctx context.Context, r *Recorder, name, src, dst string, in, out reflect.Value) {
+	rp := ReplayerFromContext(ctx)
+	turn := func() bool {
+		if rp == nil {
+			return true
+		}
+		_, err := rp.turn(ctx, name)
+		return err == nil
+	}
+	record := func(e Event) {
+		if r != nil {
+			r.Record(e)
+		}
+		if rp != nil {
+			rp.advance(e)
+		}
+	}
+	now := func() time.Time {
+		if r == nil {
+			return time.Time{}
+		}
+		return r.Time()
+	}
+
+	done := reflect.ValueOf(ctx.Done())
+	for {
+		if !turn() {
+			return
+		}
+		start := now()
+		chosen, v, ok := reflect.Select([]reflect.SelectCase{
+			{Dir: reflect.SelectRecv, Chan: in},
+			{Dir: reflect.SelectRecv, Chan: done},
+		})
+		if chosen == 1 {
+			return
+		}
+		if !ok {
+			out.Close()
+			record(Event{Kind: Close, Process: src, Chan: name})
+			return
+		}
+		record(Event{Kind: Send, Process: src, Chan: name, Value: v.Interface(), Start: start})
+		if !turn() {
+			return
+		}
+		start = now()
+		chosen, _, _ = reflect.Select([]reflect.SelectCase{
+			{Dir: reflect.SelectSend, Chan: out, Send: v},
+			{Dir: reflect.SelectRecv, Chan: done},
+		})
+		if chosen == 1 {
+			return
+		}
+		record(Event{Kind: Receive, Process: dst, Chan: name, Value: v.Interface(), Start: start})
+	}
+}
@@ -0,0 +1,114 @@
+package trace
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// chromeEvent is an event of the trace event format of the Chrome
+// trace viewer.
+type chromeEvent struct {
+	Name string                 `json:"name"`
+	Cat  string                 `json:"cat,omitempty"`
+	Ph   string                 `json:"ph"`
+	Ts   float64                `json:"ts"`
+	Dur  *float64               `json:"dur,omitempty"`
+	Pid  int                    `json:"pid"`
+	Tid  int                    `json:"tid"`
+	S    string                 `json:"s,omitempty"`
+	Args map[string]interface{} `json:"args,omitempty"`
+}
+
+// Chrome writes events in the JSON trace event format, which opens in
+// chrome://tracing and in Perfetto:
+//
+//   {"traceEvents":[
+//     {"name":"thread_name","ph":"M","ts":0,"pid":1,"tid":1,"args":{"name":"X"}},
+//     {"name":"X","cat":"process","ph":"X","ts":0,"dur":3,"pid":1,"tid":1},
+//     {"name":"send X→Y","cat":"send","ph":"X","ts":1,"dur":1,"pid":1,"tid":1,"args":{"value":"'a'"}},
+//     ...
+//   ],"displayTimeUnit":"ns"}
+//
+// Every process has a track, in the order the processes appear in the
+// trace, spanned by the lifetime of the process from its spawn to its
+// termination. A communication or selection is a span from the time
+// its process started to wait for it to the time it happened, so the
+// spans show where the processes were blocked. A close is an instant.
+// Times are in microseconds from the earliest time of the trace.
+func Chrome(w io.Writer, events []Event) error {
+	var origin, end time.Time
+	for _, e := range events {
+		if origin.IsZero() || e.Start.Before(origin) {
+			origin = e.Start
+		}
+		if e.Time.After(end) {
+			end = e.Time
+		}
+	}
+	ts := func(t time.Time) float64 {
+		return float64(t.Sub(origin).Nanoseconds()) / 1e3
+	}
+	span := func(start, end time.Time) *float64 {
+		d := float64(end.Sub(start).Nanoseconds()) / 1e3
+		return &d
+	}
+
+	// a track per process, and the lifetimes of the processes, from
+	// their spawn to their termination or the end of the trace.
+	var out []chromeEvent
+	tids := map[string]int{}
+	spawns := map[string]int{}
+	for _, e := range events {
+		if _, ok := tids[e.Process]; !ok {
+			tids[e.Process] = len(tids) + 1
+			out = append(out, chromeEvent{
+				Name: "thread_name", Ph: "M", Pid: 1, Tid: tids[e.Process],
+				Args: map[string]interface{}{"name": e.Process},
+			})
+		}
+		switch e.Kind {
+		case Spawn:
+			spawns[e.Process] = len(out)
+			out = append(out, chromeEvent{
+				Name: e.Process, Cat: "process", Ph: "X", Ts: ts(e.Time),
+				Dur: span(e.Time, end), Pid: 1, Tid: tids[e.Process],
+			})
+		case Terminate:
+			i, ok := spawns[e.Process]
+			if !ok {
+				continue
+			}
+			*out[i].Dur = ts(e.Time) - out[i].Ts
+			if e.Err != nil {
+				out[i].Args = map[string]interface{}{"error": e.Err.Error()}
+			}
+		}
+	}
+
+	for _, e := range events {
+		switch e.Kind {
+		case Send, Receive:
+			out = append(out, chromeEvent{
+				Name: e.Kind.String() + " " + e.Chan, Cat: e.Kind.String(), Ph: "X",
+				Ts: ts(e.Start), Dur: span(e.Start, e.Time), Pid: 1, Tid: tids[e.Process],
+				Args: map[string]interface{}{"value": format(e.Value)},
+			})
+		case Select:
+			out = append(out, chromeEvent{
+				Name: "select " + e.Guard, Cat: "select", Ph: "X",
+				Ts: ts(e.Start), Dur: span(e.Start, e.Time), Pid: 1, Tid: tids[e.Process],
+			})
+		case Close:
+			out = append(out, chromeEvent{
+				Name: "close " + e.Chan, Cat: "close", Ph: "i",
+				Ts: ts(e.Time), Pid: 1, Tid: tids[e.Process], S: "t",
+			})
+		}
+	}
+
+	return json.NewEncoder(w).Encode(struct {
+		TraceEvents     []chromeEvent `json:"traceEvents"`
+		DisplayTimeUnit string        `json:"displayTimeUnit"`
+	}{out, "ns"})
+}
@@ -0,0 +1,45 @@
+package trace_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/changkun/gobase/csp/trace"
+)
+
+func TestChrome(t *testing.T) {
+	at := func(us int) time.Time { return time.Unix(0, int64(us)*1e3) }
+	r := trace.NewRecorder()
+	for _, e := range []trace.Event{
+		{Kind: trace.Spawn, Process: "X", Time: at(0)},
+		{Kind: trace.Spawn, Process: "Y", Time: at(0)},
+		{Kind: trace.Send, Process: "X", Chan: "X→Y", Value: 'a', Start: at(1), Time: at(3)},
+		{Kind: trace.Receive, Process: "Y", Chan: "X→Y", Value: 'a', Start: at(2), Time: at(3)},
+		{Kind: trace.Close, Process: "X", Chan: "X→Y", Time: at(4)},
+		{Kind: trace.Terminate, Process: "X", Time: at(5)},
+		{Kind: trace.Select, Process: "Y", Guard: "X?c", Start: at(3), Time: at(5)},
+		{Kind: trace.Terminate, Process: "Y", Err: errors.New("failed"), Time: at(6)},
+	} {
+		r.Record(e)
+	}
+
+	var b strings.Builder
+	if err := trace.Chrome(&b, r.Events()); err != nil {
+		t.Fatalf("%v: unexpected error: %v", t.Name(), err)
+	}
+	want := `{"traceEvents":[` +
+		`{"name":"thread_name","ph":"M","ts":0,"pid":1,"tid":1,"args":{"name":"X"}},` +
+		`{"name":"X","cat":"process","ph":"X","ts":0,"dur":5,"pid":1,"tid":1},` +
+		`{"name":"thread_name","ph":"M","ts":0,"pid":1,"tid":2,"args":{"name":"Y"}},` +
+		`{"name":"Y","cat":"process","ph":"X","ts":0,"dur":6,"pid":1,"tid":2,"args":{"error":"failed"}},` +
+		`{"name":"send X→Y","cat":"send","ph":"X","ts":1,"dur":2,"pid":1,"tid":1,"args":{"value":"'a'"}},` +
+		`{"name":"receive X→Y","cat":"receive","ph":"X","ts":2,"dur":1,"pid":1,"tid":2,"args":{"value":"'a'"}},` +
+		`{"name":"close X→Y","cat":"close","ph":"i","ts":4,"pid":1,"tid":1,"s":"t"},` +
+		`{"name":"select X?c","cat":"select","ph":"X","ts":3,"dur":2,"pid":1,"tid":2}` +
+		`],"displayTimeUnit":"ns"}` + "\n"
+	if got := b.String(); got != want {
+		t.Fatalf("%v: expected:\n%v\ngot:\n%v", t.Name(), want, got)
+	}
+}
@@ -0,0 +1,104 @@
+package trace
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+)
+
+// ring is the ring buffer of the events of a flight recorder. An event
+// takes the next sequence number, and the slot of that number, which it
+// shares with the events a multiple of the size of the ring before and
+// after it.
+type ring struct {
+	next  atomic.Int64 // the last sequence number taken
+	slots []atomic.Pointer[Event]
+}
+
+// record records e, and returns it with its sequence number.
+func (b *ring) record(e Event) Event {
+	seq := b.next.Add(1)
+	e.Seq = int(seq)
+	b.slots[int((seq-1)%int64(len(b.slots)))].Store(&e)
+	return e
+}
+
+// events returns the events of the ring in order, skipping those being
+// recorded or already overwritten.
+func (b *ring) events() []Event {
+	hi := b.next.Load()
+	lo := hi - int64(len(b.slots)) + 1
+	if lo < 1 {
+		lo = 1
+	}
+	var events []Event
+	for seq := lo; seq <= hi; seq++ {
+		if e := b.slots[int((seq-1)%int64(len(b.slots)))].Load(); e != nil && int64(e.Seq) == seq {
+			events = append(events, *e)
+		}
+	}
+	return events
+}
+
+func (b *ring) reset() {
+	for i := range b.slots {
+		b.slots[i].Store(nil)
+	}
+}
+
+// NewFlightRecorder returns a recorder that retains only the last n
+// events of a run, which it always records, such as the recorder of
+// the context of a long-lived network:
+//
+//   r := trace.NewFlightRecorder(1000)
+//   go trace.DumpOnSignal(ctx, r, os.Stderr)
+//   n := csp.NewNetworkController(trace.NewContext(ctx, r))
+//
+// so that the communications leading to a failure, such as a
+// deadlock, can be analysed post mortem. A flight recorder records
+// without locking, hence the functions of Notify are called as its
+// events are recorded, but not necessarily in order.
+func NewFlightRecorder(n int) *Recorder {
+	if n <= 0 {
+		panic("trace: flight recorder of no events")
+	}
+	return &Recorder{ring: &ring{slots: make([]atomic.Pointer[Event], n)}}
+}
+
+// Dump writes the events recorded so far to w, one per line with its
+// time, such as
+//
+//   15:04:05.000001 3 X send X→Y 'a'
+func (r *Recorder) Dump(w io.Writer) error {
+	for _, e := range r.Events() {
+		if _, err := fmt.Fprintf(w, "%s %v\n", e.Time.Format("15:04:05.000000"), e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DumpOnSignal dumps the events of r to w whenever the program receives
+// one of sigs, syscall.SIGQUIT if none, until ctx is done. The signals
+// no longer have their default effect, a SIGQUIT does not terminate the
+// program, until DumpOnSignal returns.
+func DumpOnSignal(ctx context.Context, r *Recorder, w io.Writer, sigs ...os.Signal) {
+	if len(sigs) == 0 {
+		sigs = []os.Signal{syscall.SIGQUIT}
+	}
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, sigs...)
+	defer signal.Stop(c)
+	for {
+		select {
+		case <-c:
+			r.Dump(w)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package trace_test
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/changkun/gobase/csp/trace"
+)
+
+func TestFlightRecorder(t *testing.T) {
+	r := trace.NewFlightRecorder(3)
+	for i := 1; i <= 5; i++ {
+		r.Record(trace.Event{Kind: trace.Send, Process: "X", Chan: "X→Y", Value: i})
+	}
+
+	// only the last 3 events are retained.
+	events := r.Events()
+	if len(events) != 3 {
+		t.Fatalf("%v: expected: %v, got: %v", t.Name(), 3, len(events))
+	}
+	for i, e := range events {
+		if e.Seq != i+3 || e.Value != i+3 {
+			t.Fatalf("%v: expected: %v, got: %v", t.Name(), i+3, e)
+		}
+	}
+
+	r.Reset()
+	if events := r.Events(); len(events) != 0 {
+		t.Fatalf("%v: expected no events, got: %v", t.Name(), events)
+	}
+	r.Record(trace.Event{Kind: trace.Close, Process: "X", Chan: "X→Y"})
+	if events := r.Events(); len(events) != 1 || events[0].Seq != 6 {
+		t.Fatalf("%v: expected the event 6, got: %v", t.Name(), events)
+	}
+}
+
+func TestFlightRecorderConcurrent(t *testing.T) {
+	r := trace.NewFlightRecorder(100)
+	var notified sync.Map
+	r.Notify(func(e trace.Event) { notified.Store(e.Seq, true) })
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		i := i
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				r.Record(trace.Event{Kind: trace.Send, Process: fmt.Sprint(i), Value: j})
+				if j%100 == 0 {
+					r.Events()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	events := r.Events()
+	if len(events) != 100 {
+		t.Fatalf("%v: expected: %v, got: %v", t.Name(), 100, len(events))
+	}
+	for i, e := range events {
+		if e.Seq != 7901+i {
+			t.Fatalf("%v: expected: %v, got: %v", t.Name(), 7901+i, e.Seq)
+		}
+	}
+	for seq := 1; seq <= 8000; seq++ {
+		if _, ok := notified.Load(seq); !ok {
+			t.Fatalf("%v: event %v not notified", t.Name(), seq)
+		}
+	}
+}
+
+func TestDump(t *testing.T) {
+	r := &trace.Recorder{Now: func() time.Time { return time.Date(2020, 1, 1, 15, 4, 5, 1000, time.UTC) }}
+	r.Record(trace.Event{Kind: trace.Send, Process: "X", Chan: "X→Y", Value: 'a'})
+	r.Record(trace.Event{Kind: trace.Terminate, Process: "Y"})
+	var b bytes.Buffer
+	if err := r.Dump(&b); err != nil {
+		t.Fatalf("%v: unexpected error: %v", t.Name(), err)
+	}
+	want := "15:04:05.000001 1 X send X→Y 'a'\n15:04:05.000001 2 Y terminate\n"
+	if got := b.String(); got != want {
+		t.Fatalf("%v: expected: %q, got: %q", t.Name(), want, got)
+	}
+}
@@ -0,0 +1,66 @@
+//go:build unix
+
+package trace_test
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/changkun/gobase/csp/trace"
+)
+
+// syncBuffer is a strings.Builder safe for concurrent use.
+type syncBuffer struct {
+	mu sync.Mutex
+	b  strings.Builder
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.b.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.b.String()
+}
+
+func TestDumpOnSignal(t *testing.T) {
+	r := trace.NewFlightRecorder(10)
+	r.Record(trace.Event{Kind: trace.Spawn, Process: "X"})
+	var b syncBuffer
+
+	// the test catches the signal too, which would else terminate it
+	// before DumpOnSignal catches it.
+	caught := make(chan os.Signal, 1)
+	signal.Notify(caught, syscall.SIGUSR1)
+	defer signal.Stop(caught)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		trace.DumpOnSignal(ctx, r, &b, syscall.SIGUSR1)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	// the signal is sent until DumpOnSignal catches it and dumps.
+	for deadline := time.Now().Add(5 * time.Second); !strings.Contains(b.String(), "1 X spawn"); {
+		if time.Now().After(deadline) {
+			t.Fatalf("%v: expected a dump, got: %q", t.Name(), b.String())
+		}
+		syscall.Kill(syscall.Getpid(), syscall.SIGUSR1)
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,99 @@
+package trace
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Mermaid writes events as a Mermaid sequence diagram, which renders
+// in Markdown documents and issues of many code hosts:
+//
+//   sequenceDiagram
+//       participant p1 as X
+//       participant p2 as Y
+//       p1->>p2: 'a'
+//       Note over p2: X?c
+//       p1-)p2: close
+//       Note over p2: terminate: input from terminated process X
+//
+// Every process has a lifeline, in the order the processes appear in
+// the trace. A communication is an arrow from its sender to its
+// receiver, drawn where the sender output it, and the close of a
+// channel a dashed arrow to its receiver, if it had any. The selected
+// guards and the termination of failed processes are notes.
+func Mermaid(w io.Writer, events []Event) error {
+	bw := bufio.NewWriter(w)
+	ids := map[string]string{}
+	var labels []string
+	id := func(label string) string {
+		if x, ok := ids[label]; ok {
+			return x
+		}
+		labels = append(labels, label)
+		ids[label] = fmt.Sprintf("p%d", len(labels))
+		return ids[label]
+	}
+
+	// the receivers of the channels, and the communications of the
+	// sends.
+	receivers := map[string]string{}
+	comms := map[int]Event{}
+	pending := map[string][]int{}
+	for i, e := range events {
+		switch e.Kind {
+		case Send:
+			pending[e.Chan] = append(pending[e.Chan], i)
+		case Receive:
+			receivers[e.Chan] = e.Process
+			if q := pending[e.Chan]; len(q) > 0 {
+				comms[q[0]] = e
+				pending[e.Chan] = q[1:]
+			}
+		}
+	}
+
+	var lines []string
+	for i, e := range events {
+		switch e.Kind {
+		case Send:
+			r, ok := comms[i]
+			if !ok {
+				// the value was never received.
+				lines = append(lines, fmt.Sprintf("Note over %s: %s %s", id(e.Process), escape(e.Chan), escape(format(e.Value))))
+				continue
+			}
+			lines = append(lines, fmt.Sprintf("%s->>%s: %s", id(e.Process), id(r.Process), escape(format(e.Value))))
+		case Close:
+			if r, ok := receivers[e.Chan]; ok {
+				lines = append(lines, fmt.Sprintf("%s-)%s: close", id(e.Process), id(r)))
+			} else {
+				lines = append(lines, fmt.Sprintf("Note over %s: close %s", id(e.Process), escape(e.Chan)))
+			}
+		case Select:
+			lines = append(lines, fmt.Sprintf("Note over %s: %s", id(e.Process), escape(e.Guard)))
+		case Spawn:
+			id(e.Process)
+		case Terminate:
+			if e.Err != nil {
+				lines = append(lines, fmt.Sprintf("Note over %s: terminate: %s", id(e.Process), escape(e.Err.Error())))
+			}
+		}
+	}
+
+	fmt.Fprintln(bw, "sequenceDiagram")
+	for _, label := range labels {
+		fmt.Fprintf(bw, "    participant %s as %s\n", ids[label], escape(label))
+	}
+	for _, line := range lines {
+		fmt.Fprintf(bw, "    %s\n", line)
+	}
+	return bw.Flush()
+}
+
+// escape escapes the characters which end a statement of Mermaid or
+// start an entity code.
+func escape(s string) string {
+	return strings.NewReplacer("#", "#35;", ";", "#59;", "\n", " ").Replace(s)
+}
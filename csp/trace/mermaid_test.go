@@ -0,0 +1,43 @@
+package trace_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/changkun/gobase/csp/trace"
+)
+
+func TestMermaid(t *testing.T) {
+	r := trace.NewRecorder()
+	for _, e := range []trace.Event{
+		{Kind: trace.Spawn, Process: "X"},
+		{Kind: trace.Spawn, Process: "Y"},
+		{Kind: trace.Send, Process: "X", Chan: "X→Y", Value: 'a'},
+		{Kind: trace.Receive, Process: "Y", Chan: "X→Y", Value: 'a'},
+		{Kind: trace.Select, Process: "Y", Guard: "X?c; c≠'#'"},
+		{Kind: trace.Send, Process: "X", Chan: "X→Z", Value: 1},
+		{Kind: trace.Close, Process: "X", Chan: "X→Y"},
+		{Kind: trace.Terminate, Process: "X"},
+		{Kind: trace.Terminate, Process: "Y", Err: errors.New("failed")},
+	} {
+		r.Record(e)
+	}
+
+	var b strings.Builder
+	if err := trace.Mermaid(&b, r.Events()); err != nil {
+		t.Fatalf("%v: unexpected error: %v", t.Name(), err)
+	}
+	want := `sequenceDiagram
+    participant p1 as X
+    participant p2 as Y
+    p1->>p2: 'a'
+    Note over p2: X?c#59; c≠'#35;'
+    Note over p1: X→Z 1
+    p1-)p2: close
+    Note over p2: terminate: failed
+`
+	if got := b.String(); got != want {
+		t.Fatalf("%v: expected:\n%v\ngot:\n%v", t.Name(), want, got)
+	}
+}
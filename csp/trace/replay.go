@@ -0,0 +1,121 @@
+package trace
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Replayer replays the communications of a recorded trace: under a
+// context returned by NewReplayContext, taps relay their channels in
+// the order of the trace, which forces a network of plain channels,
+// such as a pipeline, to take its communications in the same order as
+// the recorded run:
+//
+//   rp := trace.NewReplayer(events)
+//   err := p.Run(trace.NewReplayContext(ctx, rp))
+//   if err := rp.Err(); err != nil {
+//       // the run diverged from the trace
+//   }
+//
+// A tap waits until the next communication of the trace is on its
+// channel. Once the run diverges from the trace, as a tap relays a
+// value other than the recorded one, or once the trace is exhausted,
+// the taps relay freely. Channels of the trace the run has no tap for
+// block the replay, until ctx is done.
+type Replayer struct {
+	mu      sync.Mutex
+	events  []Event       // sends, receives and closes
+	pos     int           // of the next event
+	changed chan struct{} // closed and renewed once pos or err change
+	err     error
+}
+
+// NewReplayer returns a replayer of the communications of events.
+func NewReplayer(events []Event) *Replayer {
+	rp := &Replayer{changed: make(chan struct{})}
+	for _, e := range events {
+		switch e.Kind {
+		case Send, Receive, Close:
+			rp.events = append(rp.events, e)
+		}
+	}
+	return rp
+}
+
+// DivergenceError is the error of a run which diverged from the trace
+// it replays.
+type DivergenceError struct {
+	Want, Got Event
+}
+
+func (e *DivergenceError) Error() string {
+	return fmt.Sprintf("trace: run diverged at event %d: expected %v %s %s, got %v %s %s",
+		e.Want.Seq, e.Want.Kind, e.Want.Chan, format(e.Want.Value), e.Got.Kind, e.Got.Chan, format(e.Got.Value))
+}
+
+// Err returns the DivergenceError of the run, or nil if it did not
+// diverge so far.
+func (rp *Replayer) Err() error {
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+	return rp.err
+}
+
+// turn waits until the next communication of the trace is on the
+// channel name, and reports whether the replay goes on. It reports
+// false once the replay is over, and fails if ctx is done first.
+func (rp *Replayer) turn(ctx context.Context, name string) (bool, error) {
+	for {
+		rp.mu.Lock()
+		if rp.err != nil || rp.pos == len(rp.events) {
+			rp.mu.Unlock()
+			return false, nil
+		}
+		if rp.events[rp.pos].Chan == name {
+			rp.mu.Unlock()
+			return true, nil
+		}
+		changed := rp.changed
+		rp.mu.Unlock()
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return false, ctx.Err()
+		}
+	}
+}
+
+// advance moves past the next communication of the trace, which is e
+// unless the run diverged.
+func (rp *Replayer) advance(e Event) {
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+	if rp.err != nil || rp.pos == len(rp.events) {
+		return
+	}
+	want := rp.events[rp.pos]
+	if want.Kind != e.Kind || want.Chan != e.Chan || fmt.Sprint(want.Value) != fmt.Sprint(e.Value) {
+		rp.err = &DivergenceError{Want: want, Got: e}
+	} else {
+		rp.pos++
+	}
+	close(rp.changed)
+	rp.changed = make(chan struct{})
+}
+
+// replayerKey is the context key of the replayer.
+type replayerKey struct{}
+
+// NewReplayContext returns a context derived from ctx under which taps
+// replay the trace of rp.
+func NewReplayContext(ctx context.Context, rp *Replayer) context.Context {
+	return context.WithValue(ctx, replayerKey{}, rp)
+}
+
+// ReplayerFromContext returns the replayer of ctx, or nil if taps under
+// ctx do not replay a trace.
+func ReplayerFromContext(ctx context.Context) *Replayer {
+	rp, _ := ctx.Value(replayerKey{}).(*Replayer)
+	return rp
+}
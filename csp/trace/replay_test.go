@@ -0,0 +1,87 @@
+package trace_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/changkun/gobase/csp/trace"
+)
+
+// merge runs the network [X::x!1;x!2 || Y::y!3;y!4 || M::*[x?v → out!v □ y?v → out!v]]
+// with tapped channels and returns what M output.
+func merge(ctx context.Context, r *trace.Recorder) []int {
+	x, y := make(chan int), make(chan int)
+	tx, ty := make(chan int), make(chan int)
+	go func() { x <- 1; x <- 2; close(x) }()
+	go func() { y <- 3; y <- 4; close(y) }()
+	go trace.Tap(ctx, r, "X→M", "X", "M", x, tx)
+	go trace.Tap(ctx, r, "Y→M", "Y", "M", y, ty)
+	var out []int
+	for tx != nil || ty != nil {
+		select {
+		case v, ok := <-tx:
+			if !ok {
+				tx = nil
+				continue
+			}
+			out = append(out, v)
+		case v, ok := <-ty:
+			if !ok {
+				ty = nil
+				continue
+			}
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func TestReplayer(t *testing.T) {
+	comm := func(ch string, v int) []trace.Event {
+		return []trace.Event{
+			{Kind: trace.Send, Process: ch[:1], Chan: ch, Value: v},
+			{Kind: trace.Receive, Process: "M", Chan: ch, Value: v},
+		}
+	}
+	var events []trace.Event
+	for _, c := range []struct {
+		ch string
+		v  int
+	}{{"Y→M", 3}, {"X→M", 1}, {"X→M", 2}, {"Y→M", 4}} {
+		events = append(events, comm(c.ch, c.v)...)
+	}
+
+	for i := 0; i < 10; i++ {
+		r := trace.NewRecorder()
+		rp := trace.NewReplayer(events)
+		got := merge(trace.NewReplayContext(context.Background(), rp), r)
+		if want := []int{3, 1, 2, 4}; !reflect.DeepEqual(got, want) {
+			t.Fatalf("%v: expected: %v, got: %v", t.Name(), want, got)
+		}
+		if err := rp.Err(); err != nil {
+			t.Fatalf("%v: unexpected error: %v", t.Name(), err)
+		}
+
+		// the replay records the same trace.
+		replayed := r.Events()
+		for j, e := range events {
+			if e.Kind != replayed[j].Kind || e.Chan != replayed[j].Chan || e.Value != replayed[j].Value {
+				t.Fatalf("%v: %d: expected: %v, got: %v", t.Name(), j, e, replayed[j])
+			}
+		}
+	}
+
+	// the run diverges as Y outputs 3 instead of 2, and completes.
+	events[0].Value, events[0].Seq = 2, 1
+	rp := trace.NewReplayer(events)
+	got := merge(trace.NewReplayContext(context.Background(), rp), nil)
+	if len(got) != 4 {
+		t.Fatalf("%v: expected 4 values, got: %v", t.Name(), got)
+	}
+	var d *trace.DivergenceError
+	if err := rp.Err(); !errors.As(err, &d) || d.Want.Seq != 1 {
+		t.Fatalf("%v: expected divergence at event 1, got: %v", t.Name(), err)
+	}
+}
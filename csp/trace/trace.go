@@ -0,0 +1,248 @@
+// Package trace records the traces of runs of process networks: the
+// communications of their processes in the order they happened,
+// together with the start and termination of the processes and the
+// guards their alternative commands selected.
+//
+// A Recorder collects the events of a run. Package csp records the
+// start and termination of every labelled process started by Go or
+// Par, and the communications between the stages of a pipeline, under
+// a context returned by NewContext, and so does the interpreter of
+// package lang for its processes:
+//
+//   r := trace.NewRecorder()
+//   err := p.Run(trace.NewContext(ctx, r))
+//   for _, e := range r.Events() {
+//       fmt.Println(e)
+//   }
+//
+// Processes written against plain channels record their
+// communications by a Chan wrapping the channel, or by a Tap relaying
+// from one channel to another. A flight recorder retains only the last
+// events of a run, for post-mortem analysis at little cost. Mermaid
+// draws a trace as a sequence diagram, Chrome exports it to the Chrome
+// trace viewer and Perfetto.
+package trace
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Kind is the kind of an event.
+type Kind int
+
+const (
+	// Send is an output of a value on a channel.
+	Send Kind = iota
+	// Receive is an input of a value from a channel.
+	Receive
+	// Close is the close of a channel by its sender.
+	Close
+	// Spawn is the start of a process.
+	Spawn
+	// Terminate is the termination of a process.
+	Terminate
+	// Select is the selection of a guard by an alternative command.
+	Select
+)
+
+func (k Kind) String() string {
+	switch k {
+	case Send:
+		return "send"
+	case Receive:
+		return "receive"
+	case Close:
+		return "close"
+	case Spawn:
+		return "spawn"
+	case Terminate:
+		return "terminate"
+	case Select:
+		return "select"
+	}
+	return fmt.Sprintf("Kind(%d)", int(k))
+}
+
+// Event is an event of a trace.
+type Event struct {
+	// Seq is the position of the event in its trace, from 1.
+	Seq  int
+	Kind Kind
+	// Process is the label of the process of the event.
+	Process string
+	// Chan is the name of the channel of a communication or close,
+	// such as X→Y for the channel from process X to process Y.
+	Chan string
+	// Value is the value of a communication.
+	Value interface{}
+	// Guard describes the guard of a Select.
+	Guard string
+	// Err is the error of a Terminate, if the process failed.
+	Err error
+	// Start is the time the process started to wait for the
+	// communication or selection, Time the time the event happened.
+	Start, Time time.Time
+}
+
+// String formats e without its times, such as
+//
+//   3 X send X→Y 'a'
+//   4 Y receive X→Y 'a'
+//   9 Y select X?c
+//   10 Y terminate: input from terminated process X
+func (e Event) String() string {
+	s := fmt.Sprintf("%d %s %v", e.Seq, e.Process, e.Kind)
+	switch e.Kind {
+	case Send, Receive:
+		s += " " + e.Chan + " " + format(e.Value)
+	case Close:
+		s += " " + e.Chan
+	case Select:
+		s += " " + e.Guard
+	case Terminate:
+		if e.Err != nil {
+			s += ": " + e.Err.Error()
+		}
+	}
+	return s
+}
+
+// format formats the value v of a communication, a rune, the int32 of a
+// channel of characters, as a character literal such as 'a'.
+func format(v interface{}) string {
+	if c, ok := v.(rune); ok {
+		return strconv.QuoteRune(c)
+	}
+	return fmt.Sprint(v)
+}
+
+// Recorder records the events of a run. It is safe for concurrent use.
+type Recorder struct {
+	// Now returns the time of an event, time.Now if nil.
+	Now func() time.Time
+
+	mu     sync.Mutex
+	events []Event
+	notify []func(Event)
+
+	ring      *ring // of a flight recorder
+	notifying atomic.Bool
+}
+
+// NewRecorder returns an empty recorder.
+func NewRecorder() *Recorder {
+	return &Recorder{}
+}
+
+// Record appends e to the trace, setting its Seq and, unless set, its
+// times.
+func (r *Recorder) Record(e Event) {
+	if r.ring != nil {
+		r.recordRing(e)
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if e.Time.IsZero() {
+		e.Time = r.now()
+	}
+	if e.Start.IsZero() {
+		e.Start = e.Time
+	}
+	e.Seq = len(r.events) + 1
+	r.events = append(r.events, e)
+	for _, f := range r.notify {
+		f(e)
+	}
+}
+
+// recordRing records e in the ring of a flight recorder.
+func (r *Recorder) recordRing(e Event) {
+	if e.Time.IsZero() {
+		e.Time = r.now()
+	}
+	if e.Start.IsZero() {
+		e.Start = e.Time
+	}
+	e = r.ring.record(e)
+	if !r.notifying.Load() {
+		return
+	}
+	r.mu.Lock()
+	notify := r.notify
+	r.mu.Unlock()
+	for _, f := range notify {
+		f(e)
+	}
+}
+
+// Notify calls f with every event recorded from now on, in order, as
+// the event is recorded. f must not call the methods of r, and the
+// recording process waits for f to return, hence a slow f slows down
+// the run.
+func (r *Recorder) Notify(f func(Event)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.notify = append(r.notify, f)
+	r.notifying.Store(true)
+}
+
+// Events returns the events recorded so far, in order, those retained
+// by a flight recorder.
+func (r *Recorder) Events() []Event {
+	if r.ring != nil {
+		return r.ring.events()
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Event(nil), r.events...)
+}
+
+// Reset discards the events recorded so far.
+func (r *Recorder) Reset() {
+	if r.ring != nil {
+		r.ring.reset()
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = nil
+}
+
+// Time returns the current time as of r, for the Start of an event.
+func (r *Recorder) Time() time.Time {
+	if r.ring != nil {
+		return r.now()
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.now()
+}
+
+func (r *Recorder) now() time.Time {
+	if r.Now != nil {
+		return r.Now()
+	}
+	return time.Now()
+}
+
+// recorderKey is the context key of the recorder.
+type recorderKey struct{}
+
+// NewContext returns a context derived from ctx under which runs are
+// recorded by r.
+func NewContext(ctx context.Context, r *Recorder) context.Context {
+	return context.WithValue(ctx, recorderKey{}, r)
+}
+
+// FromContext returns the recorder of ctx, or nil if runs under ctx are
+// not recorded.
+func FromContext(ctx context.Context) *Recorder {
+	r, _ := ctx.Value(recorderKey{}).(*Recorder)
+	return r
+}
@@ -0,0 +1,87 @@
+package trace_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/changkun/gobase/csp/trace"
+)
+
+func TestChan(t *testing.T) {
+	r := trace.NewRecorder()
+	ctx := context.Background()
+	c := trace.NewChan(r, "X→Y", make(chan rune))
+	go func() {
+		for _, v := range "ab" {
+			c.Send(ctx, "X", v)
+		}
+		c.Close("X")
+	}()
+	for {
+		if _, ok := c.Recv(ctx, "Y"); !ok {
+			break
+		}
+	}
+	r.Record(trace.Event{Kind: trace.Select, Process: "Y", Guard: "X?c"})
+	r.Record(trace.Event{Kind: trace.Terminate, Process: "Y", Err: errors.New("failed")})
+
+	// the events of every process are recorded in order.
+	want := map[string]string{
+		"X": "send X→Y 'a', send X→Y 'b', close X→Y",
+		"Y": "receive X→Y 'a', receive X→Y 'b', select X?c, terminate: failed",
+	}
+	got := map[string][]string{}
+	for i, e := range r.Events() {
+		if e.Seq != i+1 {
+			t.Fatalf("%v: expected: %v, got: %v", t.Name(), i+1, e.Seq)
+		}
+		s := strings.SplitN(e.String(), " ", 3)[2]
+		got[e.Process] = append(got[e.Process], s)
+	}
+	for label, want := range want {
+		if got := strings.Join(got[label], ", "); got != want {
+			t.Fatalf("%v: %v: expected: %v, got: %v", t.Name(), label, want, got)
+		}
+	}
+}
+
+func TestTap(t *testing.T) {
+	now := time.Unix(0, 0)
+	r := &trace.Recorder{Now: func() time.Time {
+		now = now.Add(time.Second)
+		return now
+	}}
+	in, out := make(chan int), make(chan int)
+	go func() {
+		for i := 1; i <= 2; i++ {
+			in <- i
+		}
+		close(in)
+	}()
+	go trace.Tap(context.Background(), r, "X→Y", "X", "Y", in, out)
+	for range out {
+	}
+
+	want := []string{"1 X send X→Y 1", "2 Y receive X→Y 1", "3 X send X→Y 2", "4 Y receive X→Y 2", "5 X close X→Y"}
+	events := r.Events()
+	got := []string{}
+	for _, e := range events {
+		got = append(got, e.String())
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("%v: expected:\n%v\ngot:\n%v", t.Name(), strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+	for _, e := range events {
+		if e.Kind != trace.Close && !e.Start.Before(e.Time) {
+			t.Fatalf("%v: %v: expected start before time, got: %v, %v", t.Name(), e, e.Start, e.Time)
+		}
+	}
+
+	r.Reset()
+	if n := len(r.Events()); n != 0 {
+		t.Fatalf("%v: expected no events after reset, got: %v", t.Name(), n)
+	}
+}
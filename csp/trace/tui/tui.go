@@ -0,0 +1,266 @@
+// Package tui animates the message flow of a running process network
+// in a terminal. A Display follows the events of a trace.Recorder and
+// redraws the network as it runs: its processes as boxes, and its
+// channels with the number of values they carried and the last one,
+// flashing whenever they carry another:
+//
+//   ┌──────┐ ┌───┐ ┌──────┐
+//   │ west │ │ X │ │ east │
+//   └──────┘ └───┘ └──────┘
+//
+//   west→X  ══▶  12 'a'
+//   X→east  ──▶  11 'b'
+//
+// A display is fed by Notify of the recorder of the run:
+//
+//   r := trace.NewRecorder()
+//   d := tui.New(os.Stderr)
+//   d.Delay = 200 * time.Millisecond
+//   r.Notify(d.Record)
+//   go d.Run(ctx)
+//   err := p.Run(trace.NewContext(ctx, r))
+//
+// Processes that terminated are dimmed, those that failed red.
+package tui
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/changkun/gobase/csp/trace"
+)
+
+// Display is the terminal view of a process network.
+type Display struct {
+	// Interval is the time between two frames, 100ms if zero.
+	Interval time.Duration
+	// Flash is how long a channel and its processes are highlighted
+	// after a communication, 500ms if zero.
+	Flash time.Duration
+	// Delay pauses the receiving process after every communication,
+	// which slows down the run so that it can be watched.
+	Delay time.Duration
+	// Width is the width of the terminal, 80 if zero.
+	Width int
+	// Now returns the current time, time.Now if nil.
+	Now func() time.Time
+
+	w io.Writer
+
+	mu     sync.Mutex
+	procs  []*proc
+	labels map[string]*proc
+	chans  []*channel
+	names  map[string]*channel
+}
+
+// proc is a process of the display.
+type proc struct {
+	label string
+	done  bool
+	err   error
+	last  time.Time // of its last communication
+}
+
+// channel is a channel of the display.
+type channel struct {
+	name   string
+	count  int
+	value  interface{}
+	closed bool
+	last   time.Time // of its last communication
+}
+
+// New returns a display drawing to w, which is a terminal.
+func New(w io.Writer) *Display {
+	return &Display{w: w, labels: map[string]*proc{}, names: map[string]*channel{}}
+}
+
+// Record updates the display by e, it is called by the Notify of the
+// recorder of the run.
+func (d *Display) Record(e trace.Event) {
+	d.mu.Lock()
+	now := d.now()
+	p := &proc{}
+	if e.Process != "" {
+		p = d.proc(e.Process)
+	}
+	switch e.Kind {
+	case trace.Send, trace.Receive:
+		p.last = now
+		c := d.channel(e.Chan)
+		c.last, c.value = now, e.Value
+		if e.Kind == trace.Receive {
+			c.count++
+		}
+	case trace.Close:
+		d.channel(e.Chan).closed = true
+	case trace.Terminate:
+		p.done, p.err = true, e.Err
+	}
+	d.mu.Unlock()
+
+	if e.Kind == trace.Receive && d.Delay > 0 {
+		time.Sleep(d.Delay)
+	}
+}
+
+// proc returns the process labelled label, d.mu must be held.
+func (d *Display) proc(label string) *proc {
+	p, ok := d.labels[label]
+	if !ok {
+		p = &proc{label: label}
+		d.labels[label] = p
+		d.procs = append(d.procs, p)
+	}
+	return p
+}
+
+// channel returns the channel name, d.mu must be held.
+func (d *Display) channel(name string) *channel {
+	c, ok := d.names[name]
+	if !ok {
+		c = &channel{name: name}
+		d.names[name] = c
+		d.chans = append(d.chans, c)
+	}
+	return c
+}
+
+// Run redraws the display every Interval until ctx is done, and draws
+// a last frame then. It returns the first error writing a frame.
+func (d *Display) Run(ctx context.Context) error {
+	interval := d.Interval
+	if interval == 0 {
+		interval = 100 * time.Millisecond
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			if err := d.Draw(); err != nil {
+				return err
+			}
+		case <-ctx.Done():
+			return d.Draw()
+		}
+	}
+}
+
+// ANSI escape sequences of the display.
+const (
+	clear   = "\x1b[H\x1b[2J"
+	reset   = "\x1b[0m"
+	bold    = "\x1b[1m"
+	dim     = "\x1b[2m"
+	reverse = "\x1b[7m"
+	red     = "\x1b[31m"
+)
+
+// Draw clears the terminal and draws the current frame.
+func (d *Display) Draw() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	now := d.now()
+	flash := d.Flash
+	if flash == 0 {
+		flash = 500 * time.Millisecond
+	}
+	width := d.Width
+	if width == 0 {
+		width = 80
+	}
+	active := func(last time.Time) bool {
+		return !last.IsZero() && now.Sub(last) < flash
+	}
+
+	var b strings.Builder
+	b.WriteString(clear)
+
+	// the boxes of the processes, as many in a row as fit.
+	for i := 0; i < len(d.procs); {
+		n, w := 0, 0
+		for ; i+n < len(d.procs); n++ {
+			bw := utf8.RuneCountInString(d.procs[i+n].label) + 5
+			if n > 0 && w+bw > width {
+				break
+			}
+			w += bw
+		}
+		row := d.procs[i : i+n]
+		for j, line := range []string{"┌─%s─┐", "│ %s │", "└─%s─┘"} {
+			for k, p := range row {
+				if k > 0 {
+					b.WriteByte(' ')
+				}
+				s := p.label
+				if j != 1 {
+					s = strings.Repeat("─", utf8.RuneCountInString(p.label))
+				}
+				style := ""
+				switch {
+				case p.err != nil:
+					style = red
+				case p.done:
+					style = dim
+				case active(p.last):
+					style = bold
+				}
+				b.WriteString(style + fmt.Sprintf(line, s) + reset)
+			}
+			b.WriteByte('\n')
+		}
+		i += n
+	}
+
+	// the channels, with their counters and last values.
+	if len(d.chans) > 0 {
+		b.WriteByte('\n')
+	}
+	pad := 0
+	for _, c := range d.chans {
+		if n := utf8.RuneCountInString(c.name); n > pad {
+			pad = n
+		}
+	}
+	for _, c := range d.chans {
+		arrow := "──▶"
+		if active(c.last) {
+			arrow = reverse + "══▶" + reset
+		}
+		fmt.Fprintf(&b, "%s%s  %s %4d", c.name, strings.Repeat(" ", pad-utf8.RuneCountInString(c.name)), arrow, c.count)
+		if c.value != nil {
+			b.WriteString(" " + format(c.value))
+		}
+		if c.closed {
+			b.WriteString(" closed")
+		}
+		b.WriteByte('\n')
+	}
+
+	_, err := io.WriteString(d.w, b.String())
+	return err
+}
+
+func (d *Display) now() time.Time {
+	if d.Now != nil {
+		return d.Now()
+	}
+	return time.Now()
+}
+
+// format formats the value v of a communication as trace does, a rune
+// as a character literal.
+func format(v interface{}) string {
+	if c, ok := v.(rune); ok {
+		return strconv.QuoteRune(c)
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,52 @@
+package tui_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/changkun/gobase/csp/trace"
+	"github.com/changkun/gobase/csp/trace/tui"
+)
+
+func TestDisplay(t *testing.T) {
+	now := time.Unix(0, 0)
+	var b strings.Builder
+	d := tui.New(&b)
+	d.Width = 16
+	d.Now = func() time.Time { return now }
+	r := trace.NewRecorder()
+	r.Notify(d.Record)
+
+	r.Record(trace.Event{Kind: trace.Spawn, Process: "west"})
+	r.Record(trace.Event{Kind: trace.Spawn, Process: "X"})
+	r.Record(trace.Event{Kind: trace.Spawn, Process: "east"})
+	for _, c := range "ab" {
+		r.Record(trace.Event{Kind: trace.Send, Process: "west", Chan: "west→X", Value: c})
+		r.Record(trace.Event{Kind: trace.Receive, Process: "X", Chan: "west→X", Value: c})
+	}
+	r.Record(trace.Event{Kind: trace.Close, Process: "west", Chan: "west→X"})
+	r.Record(trace.Event{Kind: trace.Terminate, Process: "west"})
+	now = now.Add(time.Second)
+	r.Record(trace.Event{Kind: trace.Send, Process: "X", Chan: "X→east", Value: 'a'})
+	r.Record(trace.Event{Kind: trace.Receive, Process: "east", Chan: "X→east", Value: 'a'})
+	r.Record(trace.Event{Kind: trace.Terminate, Process: "east", Err: errors.New("failed")})
+
+	if err := d.Draw(); err != nil {
+		t.Fatalf("%v: unexpected error: %v", t.Name(), err)
+	}
+	want := "\x1b[H\x1b[2J" +
+		"\x1b[2m┌──────┐\x1b[0m \x1b[1m┌───┐\x1b[0m\n" +
+		"\x1b[2m│ west │\x1b[0m \x1b[1m│ X │\x1b[0m\n" +
+		"\x1b[2m└──────┘\x1b[0m \x1b[1m└───┘\x1b[0m\n" +
+		"\x1b[31m┌──────┐\x1b[0m\n" +
+		"\x1b[31m│ east │\x1b[0m\n" +
+		"\x1b[31m└──────┘\x1b[0m\n" +
+		"\n" +
+		"west→X  ──▶    2 'b' closed\n" +
+		"X→east  \x1b[7m══▶\x1b[0m    1 'a'\n"
+	if got := b.String(); got != want {
+		t.Fatalf("%v: expected:\n%q\ngot:\n%q", t.Name(), want, got)
+	}
+}